@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ButyrinIA/system/internal/export"
+	"github.com/ButyrinIA/system/internal/markdown"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// runExport выполняет команду export: записывает пост с деревом комментариев HTML-страницей
+// в файл или в out и возвращает код завершения
+func runExport(store storage.Storage, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	postID := flags.String("post", "", "ID выгружаемого поста")
+	viewerID := flags.String("viewer", "", "пользователь, от имени которого выбираются комментарии; по умолчанию анонимный посетитель")
+	file := flags.String("out", "-", "файл результата, \"-\" для вывода в stdout")
+	flags.Parse(args)
+	if *postID == "" {
+		log.Println("Не указан -post")
+		return 2
+	}
+
+	doc, err := export.New(store, markdown.New(1000), export.Options{}).Build(context.Background(), *postID, *viewerID)
+	if err != nil {
+		log.Printf("Не удалось выгрузить пост %s: %v", *postID, err)
+		return 1
+	}
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			log.Printf("Не удалось создать файл выгрузки: %v", err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := (export.HTML{}).Write(out, doc); err != nil {
+		log.Printf("Не удалось записать выгрузку: %v", err)
+		return 2
+	}
+	return 0
+}
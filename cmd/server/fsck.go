@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/storage/postgres"
+)
+
+// runFsck выполняет команду fsck: проверяет согласованность данных PostgreSQL, печатает отчёт в out
+// и возвращает код завершения - 1, если остались неисправленные проблемы
+func runFsck(cfg *config.Config, opts postgres.Options, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "исправить найденные проблемы, которые можно исправить автоматически")
+	flags.Parse(args)
+	if cfg.Storage != config.StoragePostgres {
+		log.Printf("Команда fsck поддерживается только для хранилища %s", config.StoragePostgres)
+		return 2
+	}
+
+	// Проверка не меняет схему и не запускает фоновые задачи хранилища
+	opts.SkipMigrations = true
+	opts.PartitionComments = false
+	opts.CountReconcileInterval = 0
+	opts.CommentArchiveMonths = 0
+	store, err := postgres.New(cfg.Postgres.DSN, opts)
+	if err != nil {
+		log.Printf("Не удалось инициализировать PostgreSQL: %v", err)
+		return 2
+	}
+	defer store.Close()
+
+	report, err := store.Fsck(context.Background(), *repair)
+	if err != nil {
+		log.Printf("Проверка согласованности прервана: %v", err)
+		return 2
+	}
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(out, "no issues found")
+		return 0
+	}
+	for _, issue := range report.Issues {
+		status := "found"
+		switch {
+		case issue.Repaired > 0:
+			status = fmt.Sprintf("repaired %d", issue.Repaired)
+		case !issue.Repairable:
+			status = "manual fix required"
+		}
+		fmt.Fprintf(out, "%s: %d %s (%s)\n", issue.Check, issue.Count, issue.Description, status)
+		fmt.Fprintf(out, "  e.g. %s\n", strings.Join(issue.Examples, ", "))
+	}
+	if report.Unresolved() > 0 {
+		if !*repair {
+			fmt.Fprintln(out, "run fsck -repair to fix repairable issues")
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ButyrinIA/system/internal/importer"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// runImport выполняет команду import: переносит комментарии из выгрузки Disqus или WordPress
+// в хранилище store, печатает итог в out и возвращает код завершения
+func runImport(store storage.Storage, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "формат выгрузки: disqus или wordpress")
+	file := flags.String("file", "-", "файл выгрузки, \"-\" для чтения из stdin")
+	dryRun := flags.Bool("dry-run", false, "только показать, что будет импортировано")
+	flags.Parse(args)
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Printf("Не удалось открыть выгрузку: %v", err)
+			return 2
+		}
+		defer f.Close()
+		in = f
+	}
+	threads, err := importer.Parse(*format, in)
+	if err != nil {
+		log.Printf("Не удалось разобрать выгрузку: %v", err)
+		return 2
+	}
+	report, err := importer.New(store, importer.Options{DryRun: *dryRun}).Import(context.Background(), *format, threads)
+	if err != nil {
+		log.Printf("Импорт прерван: %v", err)
+		return 2
+	}
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(out, "%s %d posts and %d comments; already imported: %d posts, %d comments; orphaned replies moved to root: %d\n",
+		verb, report.Posts, report.Comments, report.ExistingPosts, report.ExistingComments, report.Orphans)
+	return 0
+}
@@ -1,45 +1,258 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/encryption"
+	"github.com/ButyrinIA/system/internal/gctuning"
+	"github.com/ButyrinIA/system/internal/secrets"
 	"github.com/ButyrinIA/system/internal/server"
 	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/encrypted"
 	"github.com/ButyrinIA/system/internal/storage/memory"
 	"github.com/ButyrinIA/system/internal/storage/postgres"
+	"github.com/ButyrinIA/system/internal/storage/writebehind"
 )
 
 func main() {
-	configPath := flag.String("config", "config.yaml", "путь к файлу конфигурации")
-	storageType := flag.String("storage", "memory", "тип хранилища: memory или postgres")
+	configPath := flag.String("config", "", "путь к файлу конфигурации, \"-\" для чтения из stdin; по умолчанию $CONFIG, config.yaml или встроенные значения")
+	storageType := flag.String("storage", "", "тип хранилища: memory или postgres; переопределяет storage из конфигурации")
+	checkSchema := flag.Bool("check-schema", false, "проверить схему PostgreSQL без её изменения и завершиться")
+	reencrypt := flag.Bool("reencrypt", false, "перешифровать содержимое основным ключом из encryption.keyFile и завершиться")
 	flag.Parse()
 
-	cfg, err := config.Load(*configPath)
+	cfg, source, err := config.Resolve(*configPath)
 	if err != nil {
-		log.Fatalf("Не удалось загрузить конфигурацию: %v", err)
+		log.Fatalf("Не удалось загрузить конфигурацию из %s: %v", source, err)
+	}
+	if *storageType != "" {
+		cfg.Storage = *storageType
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Конфигурация из %s содержит ошибки: %v", source, err)
+	}
+	resolver, err := newSecretResolver(cfg)
+	if err != nil {
+		log.Fatalf("Не удалось настроить источники секретов: %v", err)
+	}
+	secretRefs, err := resolver.ResolveFields(context.Background(), cfg.SecretFields())
+	if err != nil {
+		log.Fatalf("Не удалось прочитать секреты: %v", err)
+	}
+	effective, err := cfg.Redacted()
+	if err != nil {
+		log.Fatalf("Не удалось вывести конфигурацию: %v", err)
+	}
+	log.Printf("Конфигурация загружена из %s, действующие значения:\n%s", source, effective)
+
+	pgOptions := postgres.Options{
+		MaxConns:                 cfg.Postgres.MaxConns,
+		ConnectTimeout:           cfg.Postgres.ConnectTimeout,
+		MaxRetries:               cfg.Postgres.MaxRetries,
+		RetryBackoff:             cfg.Postgres.RetryBackoff,
+		MaxBackoff:               cfg.Postgres.MaxBackoff,
+		SkipMigrations:           cfg.Postgres.SkipMigrations,
+		StatementTimeout:         cfg.Postgres.StatementTimeout,
+		QueryTimeout:             cfg.Postgres.QueryTimeout,
+		SlowQueryThreshold:       cfg.Postgres.SlowQueryThreshold,
+		LogQueryParams:           cfg.Postgres.LogQueryParams,
+		CountReconcileInterval:   cfg.Postgres.CountReconcileInterval,
+		CommentArchiveMonths:     cfg.Postgres.CommentArchiveMonths,
+		CommentArchiveInterval:   cfg.Postgres.CommentArchiveInterval,
+		CommentArchiveBatchSize:  cfg.Postgres.CommentArchiveBatchSize,
+		PartitionComments:        cfg.Postgres.PartitionComments,
+		CommentPartitionsAhead:   cfg.Postgres.CommentPartitionsAhead,
+		CommentPartitionInterval: cfg.Postgres.CommentPartitionInterval,
+		CommentDedupeWindow:      cfg.Comments.DedupeWindow,
+	}
+
+	if flag.Arg(0) == "fsck" {
+		os.Exit(runFsck(cfg, pgOptions, flag.Args()[1:], os.Stdout))
+	}
+
+	if *checkSchema {
+		pgOptions.SkipMigrations = true
+		store, err := postgres.New(cfg.Postgres.DSN, pgOptions)
+		if err != nil {
+			log.Fatalf("Проверка схемы не пройдена: %v", err)
+		}
+		store.Close()
+		log.Println("Схема PostgreSQL соответствует ожидаемой")
+		return
 	}
 
 	var store storage.Storage
-	switch *storageType {
-	case "postgres":
+	switch cfg.Storage {
+	case config.StoragePostgres:
 		log.Println("Инициализация хранилища PostgreSQL")
-		store, err = postgres.New(cfg.Postgres.DSN)
+		store, err = postgres.New(cfg.Postgres.DSN, pgOptions)
 		if err != nil {
 			log.Fatalf("Не удалось инициализировать PostgreSQL: %v", err)
 		}
-	case "memory":
+	case config.StorageMemory:
 		log.Println("Инициализация хранилища Memory")
-		store = memory.New()
+		memStore := memory.New()
+		memStore.SetDedupeWindow(cfg.Comments.DedupeWindow)
+		store = memStore
 	default:
-		log.Fatalf("Неизвестный тип хранилища: %s", *storageType)
+		log.Fatalf("Неизвестный тип хранилища: %s", cfg.Storage)
+	}
+	// Буфер стоит под шифрованием, чтобы журнал на диске хранил зашифрованное содержимое
+	if cfg.WriteBehind.Enabled {
+		buffered, err := writebehind.New(store, writebehind.Options{
+			QueueSize:     cfg.WriteBehind.QueueSize,
+			FlushInterval: cfg.WriteBehind.FlushInterval,
+			MaxBatch:      cfg.WriteBehind.MaxBatch,
+			JournalPath:   cfg.WriteBehind.JournalPath,
+		})
+		if err != nil {
+			log.Fatalf("Не удалось включить отложенную запись комментариев: %v", err)
+		}
+		store = buffered
 	}
 	defer store.Close()
 
+	if cfg.Encryption.Enabled {
+		keyring, err := encryption.FileProvider{Path: cfg.Encryption.KeyFile}.Keyring(context.Background())
+		if err != nil {
+			log.Fatalf("Не удалось загрузить ключи шифрования: %v", err)
+		}
+		envelope, err := encryption.New(keyring)
+		if err != nil {
+			log.Fatalf("Не удалось инициализировать шифрование: %v", err)
+		}
+		log.Printf("Шифрование содержимого включено, основной ключ %s", keyring.Primary)
+		encryptedStore := encrypted.New(store, envelope)
+		if *reencrypt {
+			result, err := encryptedStore.Reencrypt(context.Background(), cfg.Encryption.ReencryptBatchSize)
+			if err != nil {
+				log.Fatalf("Перешифрование прервано после %d записей: %v", result.Rotated, err)
+			}
+			log.Printf("Перешифровано записей: %d, пропущено: %d", result.Rotated, result.Skipped)
+			return
+		}
+		store = encryptedStore
+	} else if *reencrypt {
+		log.Fatalf("Для перешифрования нужно включить encryption в конфигурации")
+	}
+
+	switch flag.Arg(0) {
+	case "replay":
+		code := runReplay(cfg, store, flag.Args()[1:], os.Stdout)
+		store.Close()
+		os.Exit(code)
+	case "import":
+		code := runImport(store, flag.Args()[1:], os.Stdout)
+		store.Close()
+		os.Exit(code)
+	case "export":
+		code := runExport(store, flag.Args()[1:], os.Stdout)
+		store.Close()
+		os.Exit(code)
+	case "reindex":
+		code := runReindex(cfg, store, flag.Args()[1:], os.Stdout)
+		store.Close()
+		os.Exit(code)
+	}
+
+	tuner := gctuning.Apply(gctuning.Options{
+		GCPercent:      cfg.Runtime.GCPercent,
+		MemoryLimit:    cfg.Runtime.MemoryLimit,
+		BallastBytes:   cfg.Runtime.BallastBytes,
+		SampleInterval: cfg.Runtime.GCPauseSampleInterval,
+	})
+	defer tuner.Close()
+
 	srv := server.New(cfg, store)
+	srv.SetGCTuner(tuner)
+	if ref, ok := secretRefs["auth.jwtSecret"]; ok {
+		resolver.OnChange(ref, srv.SetJWTSecret)
+	}
+	if len(secretRefs) > 0 && cfg.Secrets.RefreshInterval > 0 {
+		resolver.Start()
+		defer resolver.Close()
+	}
+	go toggleMaintenanceOnSignal(srv)
+	stopped := make(chan struct{})
+	go shutdownOnSignal(srv, cfg.Server.ShutdownTimeout, stopped)
 	log.Println("Запуск сервера")
-	if err := srv.Run(); err != nil {
+	ln, err := srv.Listen()
+	if err != nil {
 		log.Fatalf("Не удалось запустить сервер: %v", err)
 	}
+	notifyReady()
+	if err := srv.Serve(ln); err != nil {
+		log.Fatalf("Не удалось запустить сервер: %v", err)
+	}
+	<-stopped
+}
+
+// shutdownOnSignal останавливает сервер по SIGINT или SIGTERM, давая запросам завершиться за timeout,
+// и закрывает stopped после остановки. По SIGHUP сервер перезапускается без разрыва соединений:
+// слушающий сокет передаётся новому процессу, и прежний останавливается, когда новый готов.
+// Готовности нового процесса ждём тоже не дольше timeout
+func shutdownOnSignal(srv *server.Server, timeout time.Duration, stopped chan<- struct{}) {
+	defer close(stopped)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	stop := srv.Shutdown
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			log.Printf("Получен %v, остановка сервера", sig)
+			break
+		}
+		log.Println("Получен SIGHUP, перезапуск с передачей сокета новому процессу")
+		if err := handoff(srv, timeout); err != nil {
+			log.Printf("Перезапуск отменён, сервер продолжает работу: %v", err)
+			continue
+		}
+		log.Println("Новый процесс готов, остановка прежнего")
+		stop = srv.HandOver
+		break
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := stop(ctx); err != nil {
+		log.Printf("Сервер остановлен до завершения всех запросов: %v", err)
+	}
+}
+
+// toggleMaintenanceOnSignal переключает режим обслуживания по каждому SIGUSR2
+func toggleMaintenanceOnSignal(srv *server.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	for range signals {
+		log.Printf("Получен SIGUSR2, режим обслуживания: %t", srv.ToggleMaintenance())
+	}
+}
+
+// newSecretResolver подключает к env и file источники секретов, настроенные в конфигурации
+func newSecretResolver(cfg *config.Config) (*secrets.Resolver, error) {
+	providers := map[string]secrets.Provider{}
+	if cfg.Secrets.Vault.Addr != "" {
+		vault, err := secrets.NewVault(secrets.VaultOptions{
+			Addr:      cfg.Secrets.Vault.Addr,
+			TokenFile: cfg.Secrets.Vault.TokenFile,
+			Namespace: cfg.Secrets.Vault.Namespace,
+		})
+		if err != nil {
+			return nil, err
+		}
+		providers[secrets.SchemeVault] = vault
+	}
+	if cfg.Secrets.AWS.Region != "" || os.Getenv("AWS_REGION") != "" {
+		aws, err := secrets.NewAWS(secrets.AWSOptions{Region: cfg.Secrets.AWS.Region, Endpoint: cfg.Secrets.AWS.Endpoint})
+		if err != nil {
+			return nil, err
+		}
+		providers[secrets.SchemeAWS] = aws
+	}
+	return secrets.NewResolver(providers, secrets.Options{RefreshInterval: cfg.Secrets.RefreshInterval}), nil
 }
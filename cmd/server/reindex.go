@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/search"
+	"github.com/ButyrinIA/system/internal/server"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// runReindex выполняет команду reindex: заново индексирует все посты в поисковом движке из search
+// и возвращает код завершения. Встроенный индекс memory живёт в процессе сервера, поэтому
+// переиндексация доступна только для внешних движков
+func runReindex(cfg *config.Config, store storage.Storage, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+	batchSize := flags.Int("batch", cfg.Search.BatchSize, "сколько постов передаётся движку за раз")
+	flags.Parse(args)
+
+	switch cfg.Search.Engine {
+	case "":
+		log.Println("Поисковый движок не настроен: задайте search.engine")
+		return 2
+	case config.SearchMemory:
+		log.Println("Встроенный индекс заполняется самим сервером, переиндексация нужна только для внешних движков")
+		return 2
+	}
+	engine, err := server.NewSearchEngine(cfg)
+	if err != nil {
+		log.Printf("Не удалось создать поисковый движок: %v", err)
+		return 2
+	}
+
+	indexed, err := search.Reindex(context.Background(), store, engine, *batchSize)
+	if err != nil {
+		log.Printf("Переиндексация прервана после %d постов: %v", indexed, err)
+		return 1
+	}
+	fmt.Fprintf(out, "Проиндексировано постов: %d\n", indexed)
+	return 0
+}
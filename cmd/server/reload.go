@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/server"
+)
+
+// envReadyFD - дескриптор канала, в который новый процесс сообщает прежнему, что начал принимать соединения
+const envReadyFD = "SERVER_READY_FD"
+
+// handoff запускает новый экземпляр того же исполняемого файла с теми же аргументами и передаёт ему
+// слушающий сокет сервера. Возвращает nil, когда новый процесс открыл сокет и готов принимать
+// соединения; после этого прежний процесс может останавливаться. Если новый процесс не готов за timeout
+// или завершился, он останавливается, а прежний продолжает работу
+func handoff(srv *server.Server, timeout time.Duration) error {
+	listener, err := srv.ListenerFile()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create readiness pipe: %v", err)
+	}
+	defer ready.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		readyWriter.Close()
+		return fmt.Errorf("failed to find executable: %v", err)
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// ExtraFiles получают дескрипторы 3, 4, ... в порядке перечисления
+	cmd.ExtraFiles = []*os.File{listener, readyWriter}
+	cmd.Env = append(os.Environ(), server.EnvListenFDs+"=1", server.EnvListenPID+"=", envReadyFD+"=4")
+	err = cmd.Start()
+	// Копия у нового процесса остаётся; своя закрывается, чтобы чтение завершилось, если он упадёт
+	readyWriter.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start new process: %v", err)
+	}
+	log.Printf("Запущен новый процесс %d, ожидание его готовности", cmd.Process.Pid)
+
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := ready.Read(buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("new process exited before becoming ready")
+			}
+			result <- err
+			return
+		}
+		result <- nil
+	}()
+	select {
+	case err = <-result:
+	case <-time.After(timeout):
+		err = fmt.Errorf("new process is not ready after %v", timeout)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	// Новый процесс живёт дольше прежнего; его завершение собирает init
+	go cmd.Wait()
+	return nil
+}
+
+// notifyReady сообщает прежнему процессу, передавшему сокет, что новый процесс принимает соединения
+func notifyReady() {
+	value := os.Getenv(envReadyFD)
+	if value == "" {
+		return
+	}
+	os.Unsetenv(envReadyFD)
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Некорректный %s %q", envReadyFD, value)
+		return
+	}
+	pipe := os.NewFile(uintptr(fd), "ready")
+	defer pipe.Close()
+	if _, err := pipe.Write([]byte{1}); err != nil {
+		log.Printf("Не удалось сообщить прежнему процессу о готовности: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/server"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// runReplay выполняет команду replay: воспроизводит записанные мутации на хранилище store,
+// печатает расхождения в out и возвращает код завершения - 1, если ответы разошлись
+func runReplay(cfg *config.Config, store storage.Storage, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := flags.String("file", cfg.Recording.File, "файл с записанными мутациями, \"-\" для чтения из stdin")
+	flags.Parse(args)
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Printf("Не удалось открыть запись мутаций: %v", err)
+			return 2
+		}
+		defer f.Close()
+		in = f
+	}
+	result, err := server.Replay(context.Background(), cfg, store, in)
+	if err != nil {
+		log.Printf("Воспроизведение прервано: %v", err)
+		return 2
+	}
+	for _, diff := range result.Diffs {
+		fmt.Fprintln(out, diff)
+	}
+	fmt.Fprintf(out, "replayed %d mutations, %d differ\n", result.Total, len(result.Diffs))
+	if len(result.Diffs) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/ButyrinIA/system/internal/client"
+)
+
+const usage = `Использование: system-cli [-addr URL] [-token JWT] <команда> [флаги]
+
+Команды:
+  login            получить JWT и вывести его (export SYSTEM_TOKEN=$(system-cli login))
+  create-post      создать пост
+  create-comment   создать комментарий или ответ
+  list-posts       вывести страницу постов
+  watch            выводить новые комментарии поста по подписке commentAdded
+
+Без -token и SYSTEM_TOKEN токен запрашивается автоматически.
+Результаты выводятся в stdout в формате JSON, по одному объекту на строку.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	global := flag.NewFlagSet("system-cli", flag.ContinueOnError)
+	global.Usage = func() {
+		fmt.Fprint(global.Output(), usage)
+		global.PrintDefaults()
+	}
+	addr := global.String("addr", envOr("SYSTEM_ADDR", "http://localhost:8080"), "адрес сервера")
+	token := global.String("token", os.Getenv("SYSTEM_TOKEN"), "JWT для авторизации")
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return fmt.Errorf("не указана команда")
+	}
+
+	c := client.New(*addr, *token)
+	command, args := global.Arg(0), global.Args()[1:]
+	if command == "login" {
+		token, err := c.Login(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, token)
+		return nil
+	}
+	if c.Token == "" {
+		if _, err := c.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	switch command {
+	case "create-post":
+		return createPost(ctx, c, args, out)
+	case "create-comment":
+		return createComment(ctx, c, args, out)
+	case "list-posts":
+		return listPosts(ctx, c, args, out)
+	case "watch":
+		return watch(ctx, c, args, out)
+	default:
+		global.Usage()
+		return fmt.Errorf("неизвестная команда: %s", command)
+	}
+}
+
+func createPost(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("create-post", flag.ContinueOnError)
+	title := fs.String("title", "", "заголовок поста")
+	content := fs.String("content", "", "содержимое поста")
+	format := fs.String("format", "PLAIN", "формат содержимого: PLAIN или MARKDOWN")
+	noComments := fs.Bool("no-comments", false, "запретить комментарии")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *title == "" {
+		return fmt.Errorf("не указан -title")
+	}
+
+	var data struct {
+		CreatePost json.RawMessage `json:"createPost"`
+	}
+	err := c.Do(ctx, `mutation($title: String!, $content: String!, $allowComments: Boolean!, $format: ContentFormat) {
+		createPost(title: $title, content: $content, allowComments: $allowComments, format: $format) {
+			id title authorId allowComments format createdAt
+		}
+	}`, map[string]any{"title": *title, "content": *content, "allowComments": !*noComments, "format": *format}, &data)
+	if err != nil {
+		return err
+	}
+	return printJSON(out, data.CreatePost)
+}
+
+func createComment(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("create-comment", flag.ContinueOnError)
+	postID := fs.String("post", "", "ID поста")
+	parentID := fs.String("parent", "", "ID родительского комментария для ответа")
+	content := fs.String("content", "", "текст комментария")
+	format := fs.String("format", "PLAIN", "формат содержимого: PLAIN или MARKDOWN")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *postID == "" || *content == "" {
+		return fmt.Errorf("необходимо указать -post и -content")
+	}
+	variables := map[string]any{"postId": *postID, "content": *content, "format": *format}
+	if *parentID != "" {
+		variables["parentId"] = *parentID
+	}
+
+	var data struct {
+		CreateComment json.RawMessage `json:"createComment"`
+	}
+	err := c.Do(ctx, `mutation($postId: ID!, $parentId: ID, $content: String!, $format: ContentFormat) {
+		createComment(postId: $postId, parentId: $parentId, content: $content, format: $format) {
+			id postId parentId authorId content format createdAt
+		}
+	}`, variables, &data)
+	if err != nil {
+		return err
+	}
+	return printJSON(out, data.CreateComment)
+}
+
+func listPosts(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("list-posts", flag.ContinueOnError)
+	limit := fs.Int("limit", 10, "количество постов на странице")
+	cursor := fs.String("cursor", "", "курсор следующей страницы")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	variables := map[string]any{"limit": *limit}
+	if *cursor != "" {
+		variables["cursor"] = *cursor
+	}
+
+	var data struct {
+		Posts json.RawMessage `json:"posts"`
+	}
+	err := c.Do(ctx, `query($limit: Int!, $cursor: String) {
+		posts(limit: $limit, cursor: $cursor) {
+			totalCount nextCursor
+			posts { id title authorId allowComments createdAt }
+		}
+	}`, variables, &data)
+	if err != nil {
+		return err
+	}
+	return printJSON(out, data.Posts)
+}
+
+func watch(ctx context.Context, c *client.Client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	postID := fs.String("post", "", "ID поста")
+	since := fs.String("since", "", "ID последнего полученного комментария для повтора пропущенных")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *postID == "" {
+		return fmt.Errorf("не указан -post")
+	}
+	variables := map[string]any{"postId": *postID}
+	if *since != "" {
+		variables["sinceEventId"] = *since
+	}
+
+	fmt.Fprintf(os.Stderr, "Подписка на комментарии поста %s, Ctrl+C для выхода\n", *postID)
+	err := c.Subscribe(ctx, `subscription($postId: ID!, $sinceEventId: ID) {
+		commentAdded(postId: $postId, sinceEventId: $sinceEventId) {
+			id postId parentId authorId content createdAt
+		}
+	}`, variables, func(data json.RawMessage) error {
+		var event struct {
+			CommentAdded json.RawMessage `json:"commentAdded"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return err
+		}
+		return printJSON(out, event.CommentAdded)
+	})
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// printJSON выводит значение одной строкой, чтобы вывод удобно разбирался jq
+func printJSON(out io.Writer, value json.RawMessage) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,142 @@
+package allowlist
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrCodeNotAllowed - код ошибки для операций, отсутствующих в списке разрешённых
+const ErrCodeNotAllowed = "OPERATION_NOT_ALLOWED"
+
+// Allowlist хранит заранее зарегистрированные документы операций по их sha256-хешу
+// и отклоняет все остальные запросы
+type Allowlist struct {
+	operations map[string]string
+	mu         sync.RWMutex
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = &Allowlist{}
+
+// New создаёт пустой список разрешённых операций
+func New() *Allowlist {
+	log.Println("Создание нового Allowlist")
+	return &Allowlist{
+		operations: make(map[string]string),
+	}
+}
+
+// Hash вычисляет ключ документа операции (sha256 в hex, как в APQ)
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add регистрирует документ операции и возвращает его хеш
+func (a *Allowlist) Add(query string) string {
+	hash := Hash(query)
+	a.mu.Lock()
+	a.operations[hash] = query
+	a.mu.Unlock()
+	log.Printf("Операция зарегистрирована в Allowlist: %s", hash)
+	return hash
+}
+
+// LoadDir загружает все файлы *.graphql и *.gql из каталога
+func (a *Allowlist) LoadDir(dir string) error {
+	log.Printf("Загрузка разрешённых операций из каталога: %s", dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Ошибка чтения каталога операций: %v", err)
+		return fmt.Errorf("failed to read operations dir: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if ext != ".graphql" && ext != ".gql" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Printf("Ошибка чтения файла операции %s: %v", entry.Name(), err)
+			return fmt.Errorf("failed to read operation %s: %v", entry.Name(), err)
+		}
+		a.Add(string(data))
+	}
+	log.Printf("Загружено разрешённых операций: %d", a.Len())
+	return nil
+}
+
+// LoadStorage загружает разрешённые операции из таблицы хранилища
+func (a *Allowlist) LoadStorage(ctx context.Context, store storage.Storage) error {
+	log.Println("Загрузка разрешённых операций из хранилища")
+	queries, err := store.ListAllowedOperations(ctx)
+	if err != nil {
+		log.Printf("Ошибка чтения разрешённых операций из хранилища: %v", err)
+		return fmt.Errorf("failed to list allowed operations: %v", err)
+	}
+	for _, query := range queries {
+		a.Add(query)
+	}
+	log.Printf("Загружено разрешённых операций: %d", a.Len())
+	return nil
+}
+
+// Get возвращает документ операции по хешу
+func (a *Allowlist) Get(hash string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	query, ok := a.operations[hash]
+	return query, ok
+}
+
+// Len возвращает количество зарегистрированных операций
+func (a *Allowlist) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.operations)
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (a *Allowlist) ExtensionName() string {
+	return "OperationAllowlist"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (a *Allowlist) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters пропускает только зарегистрированные операции.
+// Расширение подключается после APQ, поэтому запросы, присланные только хешем,
+// к этому моменту уже содержат полный текст документа.
+func (a *Allowlist) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	hash := Hash(rawParams.Query)
+	if _, ok := a.Get(hash); !ok {
+		log.Printf("Операция отклонена Allowlist: %s", hash)
+		return notAllowed()
+	}
+	return nil
+}
+
+func notAllowed() *gqlerror.Error {
+	err := gqlerror.Errorf("operation is not in the allowlist")
+	errcode.Set(err, ErrCodeNotAllowed)
+	return err
+}
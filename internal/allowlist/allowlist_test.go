@@ -0,0 +1,64 @@
+package allowlist
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowlist(t *testing.T) {
+	t.Run("Registered operation passes", func(t *testing.T) {
+		list := New()
+		query := "query { posts(limit: 10) { totalCount } }"
+		hash := list.Add(query)
+		assert.Equal(t, Hash(query), hash)
+
+		err := list.MutateOperationParameters(context.Background(), &graphql.RawParams{Query: query})
+		assert.Nil(t, err, "Зарегистрированная операция должна выполняться")
+	})
+
+	t.Run("Unknown operation rejected", func(t *testing.T) {
+		list := New()
+		list.Add("query { posts(limit: 10) { totalCount } }")
+
+		err := list.MutateOperationParameters(context.Background(), &graphql.RawParams{Query: "query { post(id: \"1\") { id } }"})
+		assert.NotNil(t, err, "Незарегистрированная операция должна отклоняться")
+		assert.Equal(t, ErrCodeNotAllowed, err.Extensions["code"])
+	})
+
+	t.Run("LoadDir", func(t *testing.T) {
+		dir := t.TempDir()
+		query := "mutation { createPost(title: \"a\", content: \"b\", allowComments: true) { id } }"
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "create_post.graphql"), []byte(query), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("не операция"), 0o644))
+
+		list := New()
+		assert.NoError(t, list.LoadDir(dir))
+		assert.Equal(t, 1, list.Len())
+		stored, ok := list.Get(Hash(query))
+		assert.True(t, ok)
+		assert.Equal(t, query, stored)
+	})
+
+	t.Run("LoadStorage", func(t *testing.T) {
+		store := memory.New()
+		query := "query { posts(limit: 10) { totalCount } }"
+		assert.NoError(t, store.AddAllowedOperation(context.Background(), query))
+
+		list := New()
+		assert.NoError(t, list.LoadStorage(context.Background(), store))
+		assert.Equal(t, 1, list.Len())
+		err := list.MutateOperationParameters(context.Background(), &graphql.RawParams{Query: query})
+		assert.Nil(t, err, "Операция из хранилища должна выполняться")
+	})
+
+	t.Run("LoadDir missing directory", func(t *testing.T) {
+		list := New()
+		assert.Error(t, list.LoadDir(filepath.Join(t.TempDir(), "missing")))
+	})
+}
@@ -0,0 +1,184 @@
+// Package auth выдаёт и проверяет токены доступа (JWT) и передаёт пользователя запроса в контекст.
+// Им пользуются GraphQL-эндпоинты, подписки через WebSocket и HTTP-эндпоинты вне GraphQL, поэтому
+// правила аутентификации у них одинаковые
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenTTL - срок действия токенов, выдаваемых Issue
+const TokenTTL = 24 * time.Hour
+
+var (
+	// ErrMalformedHeader - заголовок Authorization задан, но не в виде Bearer <токен>
+	ErrMalformedHeader = errors.New("Неверный формат заголовка авторизации")
+	// ErrInvalidToken - токен не прошёл проверку; конкретная причина в тексте ошибки
+	ErrInvalidToken = errors.New("Недействительный токен")
+)
+
+// Key - ключ подписи JWT; заменяется без перезапуска, когда секрет перечитан из внешнего источника
+type Key struct {
+	mu  sync.RWMutex
+	key []byte
+}
+
+// NewKey создаёт ключ подписи
+func NewKey(key []byte) *Key {
+	return &Key{key: key}
+}
+
+// Get возвращает текущий ключ
+func (k *Key) Get() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.key
+}
+
+// Set заменяет ключ; токены, подписанные прежним ключом, перестают приниматься
+func (k *Key) Set(key []byte) {
+	k.mu.Lock()
+	k.key = key
+	k.mu.Unlock()
+}
+
+// Authenticator проверяет токен и возвращает ID пользователя и его роль; роль может быть пустой
+type Authenticator interface {
+	Authenticate(token string) (userID, role string, err error)
+}
+
+// AuthenticatorFunc позволяет использовать функцию как Authenticator
+type AuthenticatorFunc func(token string) (string, string, error)
+
+// Authenticate реализует Authenticator
+func (f AuthenticatorFunc) Authenticate(token string) (string, string, error) {
+	return f(token)
+}
+
+// JWT возвращает Authenticator, проверяющий JWT текущим ключом key
+func JWT(key *Key) Authenticator {
+	return AuthenticatorFunc(func(token string) (string, string, error) {
+		return Validate(key.Get(), token)
+	})
+}
+
+// Identity - пользователь, прошедший аутентификацию; пустой UserID означает анонимный запрос
+type Identity struct {
+	UserID string
+	Role   string
+	// ExpiresAt - срок действия токена; нулевой, если срок не задан
+	ExpiresAt time.Time
+}
+
+// FromHeader проверяет значение заголовка Authorization. Пустой заголовок - анонимный запрос без ошибки
+func FromHeader(a Authenticator, header string) (Identity, error) {
+	if header == "" {
+		return Identity{}, nil
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		log.Printf("Неверный формат заголовка авторизации: %s", fingerprint(header))
+		return Identity{}, ErrMalformedHeader
+	}
+	userID, role, err := a.Authenticate(token)
+	if err != nil {
+		log.Printf("Недействительный токен: %v", err)
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	expiresAt, _ := Expiry(token)
+	return Identity{UserID: userID, Role: role, ExpiresAt: expiresAt}, nil
+}
+
+// NewContext передаёт пользователя в контекст под ключами userID и role, которые читают резолверы.
+// Контекст анонимного запроса не меняется
+func NewContext(ctx context.Context, id Identity) context.Context {
+	if id.UserID == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, "userID", id.UserID)
+	return context.WithValue(ctx, "role", id.Role)
+}
+
+// UserID возвращает ID пользователя из контекста; пустая строка - анонимный запрос
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value("userID").(string)
+	return userID
+}
+
+// Validate проверяет токен и возвращает ID пользователя и его роль; роль может быть пустой
+func Validate(secret []byte, token string) (string, string, error) {
+	log.Printf("Валидация токена %s", fingerprint(token))
+	if token == "" {
+		log.Println("Ошибка: пустой токен")
+		return "", "", errors.New("пустой токен")
+	}
+	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			log.Printf("Ошибка: неожиданный метод подписи: %v", token.Header["alg"])
+			return nil, fmt.Errorf("неожиданный метод подписи: %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil {
+		log.Printf("Ошибка парсинга токена: %v", err)
+		return "", "", err
+	}
+	if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok && parsedToken.Valid {
+		userID, ok := claims["user_id"].(string)
+		if !ok {
+			log.Println("Ошибка: user_id не найден в токене")
+			return "", "", errors.New("user_id не найден в токене")
+		}
+		role, _ := claims["role"].(string)
+		log.Printf("Токен валиден, userID: %s, role: %s", userID, role)
+		return userID, role, nil
+	}
+	log.Println("Ошибка: недействительный токен")
+	return "", "", errors.New("недействительный токен")
+}
+
+// Issue выдаёт пользователю токен, подписанный secret, сроком на TokenTTL
+func Issue(secret []byte, userID string) (string, error) {
+	log.Printf("Генерация токена для userID: %s", userID)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(TokenTTL).Unix(),
+	})
+	tokenString, err := token.SignedString(secret)
+	if err != nil {
+		log.Printf("Ошибка при подписи токена: %v", err)
+		return "", err
+	}
+	log.Printf("Токен %s успешно создан", fingerprint(tokenString))
+	return tokenString, nil
+}
+
+// fingerprint возвращает отпечаток токена для журнала: по нему записи об одном токене можно сопоставить,
+// но сам токен восстановить нельзя
+func fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// Expiry возвращает срок действия уже проверенного токена или false, если срок не задан
+func Expiry(token string) (time.Time, bool) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return time.Time{}, false
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}, false
+	}
+	return exp.Time, true
+}
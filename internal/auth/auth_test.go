@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token, err := Issue([]byte(config.DevJWTSecret), "user1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte("your-secret-key"), nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, parsedToken.Valid)
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	assert.True(t, ok)
+	assert.Equal(t, "user1", claims["user_id"])
+}
+
+func TestValidateJWT(t *testing.T) {
+	token, err := Issue([]byte(config.DevJWTSecret), "user1")
+	assert.NoError(t, err)
+
+	userID, role, err := Validate([]byte(config.DevJWTSecret), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", userID)
+	assert.Empty(t, role)
+
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "mod1",
+		"role":    "moderator",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(config.DevJWTSecret))
+	assert.NoError(t, err)
+	userID, role, err = Validate([]byte(config.DevJWTSecret), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "mod1", userID)
+	assert.Equal(t, "moderator", role)
+}
+
+func TestValidateJWT_Invalid(t *testing.T) {
+	_, _, err := Validate([]byte(config.DevJWTSecret), "invalid-token")
+	assert.ErrorIs(t, err, jwt.ErrTokenMalformed)
+	_, _, err = Validate([]byte(config.DevJWTSecret), "")
+	assert.EqualError(t, err, "пустой токен")
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "user1",
+		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+	})
+	wrongKeyToken, _ := token.SignedString([]byte("wrong-key"))
+	_, _, err = Validate([]byte(config.DevJWTSecret), wrongKeyToken)
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+}
+
+func TestFingerprint(t *testing.T) {
+	token, err := Issue([]byte(config.DevJWTSecret), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint(token), fingerprint(token))
+	assert.NotContains(t, fingerprint(token), token[:10], "Журнал не содержит начала токена")
+	assert.NotEqual(t, fingerprint(token), fingerprint(token+"x"))
+}
+
+func TestFromHeader(t *testing.T) {
+	key := NewKey([]byte(config.DevJWTSecret))
+	token, err := Issue(key.Get(), "user1")
+	require.NoError(t, err)
+
+	id, err := FromHeader(JWT(key), "")
+	require.NoError(t, err)
+	assert.Equal(t, Identity{}, id, "Без заголовка запрос анонимный")
+
+	_, err = FromHeader(JWT(key), "Token "+token)
+	assert.ErrorIs(t, err, ErrMalformedHeader)
+
+	id, err = FromHeader(JWT(key), "Bearer "+token)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", id.UserID)
+	assert.WithinDuration(t, time.Now().Add(TokenTTL), id.ExpiresAt, time.Minute)
+
+	key.Set([]byte("rotated"))
+	_, err = FromHeader(JWT(key), "Bearer "+token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "Проверка идёт текущим ключом")
+}
+
+func TestMiddleware(t *testing.T) {
+	authenticator := AuthenticatorFunc(func(token string) (string, string, error) {
+		if token != "good" {
+			return "", "", errors.New("unknown token")
+		}
+		return "user1", "moderator", nil
+	})
+	var rejected error
+	handler := Middleware(authenticator, func(w http.ResponseWriter, r *http.Request, err error) {
+		rejected = err
+		w.WriteHeader(http.StatusUnauthorized)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value("role").(string)
+		w.Write([]byte(UserID(r.Context()) + "/" + role))
+	}))
+	serve := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, "/", serve("").Body.String())
+	assert.Equal(t, "user1/moderator", serve("Bearer good").Body.String())
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer bad").Code)
+	assert.ErrorIs(t, rejected, ErrInvalidToken)
+}
+
+func TestFromInitPayload(t *testing.T) {
+	key := NewKey([]byte(config.DevJWTSecret))
+	token, err := Issue(key.Get(), "user1")
+	require.NoError(t, err)
+	for _, field := range []string{"Authorization", "authorization"} {
+		id, err := FromInitPayload(JWT(key), transport.InitPayload{field: "Bearer " + token})
+		require.NoError(t, err)
+		assert.Equal(t, "user1", id.UserID)
+		assert.Equal(t, "user1", UserID(NewContext(context.Background(), id)))
+	}
+	id, err := FromInitPayload(JWT(key), transport.InitPayload{})
+	require.NoError(t, err)
+	assert.Empty(t, id.UserID)
+}
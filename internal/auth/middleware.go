@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// Middleware проверяет заголовок Authorization HTTP-запроса и передаёт пользователя в контекст, см. UserID.
+// Запрос без заголовка передаётся дальше анонимным, а с недействительным заголовком - в reject,
+// который отвечает ошибкой в формате эндпоинта
+func Middleware(a Authenticator, reject func(http.ResponseWriter, *http.Request, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := FromHeader(a, r.Header.Get("Authorization"))
+			if err != nil {
+				reject(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+		})
+	}
+}
+
+// FromInitPayload проверяет токен из поля Authorization сообщения connection_init WebSocket.
+// Соединение без токена анонимное; ExpiresAt результата - когда соединение следует закрыть
+func FromInitPayload(a Authenticator, payload transport.InitPayload) (Identity, error) {
+	return FromHeader(a, payload.Authorization())
+}
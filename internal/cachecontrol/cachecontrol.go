@@ -0,0 +1,204 @@
+// Package cachecontrol вычисляет политику кэширования ответа GraphQL по подсказкам @cacheControl схемы.
+// Ответ можно кэшировать не дольше самого короткого maxAge среди полей запроса и только приватно,
+// если хотя бы одно поле помечено scope: PRIVATE
+package cachecontrol
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// ExtensionKey - ключ политики в extensions ответа
+const ExtensionKey = "cacheControl"
+
+// directiveName - директива схемы с подсказками кэширования
+const directiveName = "cacheControl"
+
+// Scope определяет, можно ли хранить ответ в общих кэшах
+type Scope string
+
+const (
+	// ScopePublic - ответ одинаков для всех и может храниться в CDN
+	ScopePublic Scope = "PUBLIC"
+	// ScopePrivate - ответ зависит от пользователя и хранится только в его кэше
+	ScopePrivate Scope = "PRIVATE"
+)
+
+// Policy - итоговая политика ответа; MaxAge в секундах, 0 запрещает кэширование
+type Policy struct {
+	MaxAge int   `json:"maxAge"`
+	Scope  Scope `json:"scope"`
+}
+
+// Options задаёт расширение
+type Options struct {
+	// Private сообщает, что ответ целиком зависит от автора запроса, например авторизованного
+	// пользователя, который видит свои скрытые посты и комментарии; такой ответ всегда PRIVATE
+	Private func(ctx context.Context) bool
+	// MinMaxAge - нижняя граница MaxAge в секундах для публичных ответов без ошибок, например для эндпоинта
+	// анонимного чтения, где устаревание на этот срок допустимо; 0 оставляет срок из подсказок
+	MinMaxAge int
+}
+
+// Extension собирает подсказки полей запроса и добавляет политику в extensions ответа.
+// Политика считается только для запросов: мутации и подписки не кэшируются
+type Extension struct {
+	opts   Options
+	schema *ast.Schema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+	graphql.ResponseInterceptor
+} = &Extension{}
+
+// New создаёт расширение для handler.Server.Use
+func New(opts Options) *Extension {
+	return &Extension{opts: opts}
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (e *Extension) ExtensionName() string {
+	return "CacheControl"
+}
+
+// Validate реализует graphql.HandlerExtension и запоминает схему для чтения подсказок типов
+func (e *Extension) Validate(schema graphql.ExecutableSchema) error {
+	e.schema = schema.Schema()
+	return nil
+}
+
+type policyKey struct{}
+
+// InterceptOperation создаёт накопитель политики для запроса
+func (e *Extension) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil || oc.Operation.Operation != ast.Query {
+		return next(ctx)
+	}
+	return next(context.WithValue(ctx, policyKey{}, &accumulator{}))
+}
+
+// InterceptField учитывает подсказку разрешаемого поля
+func (e *Extension) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	if acc, ok := ctx.Value(policyKey{}).(*accumulator); ok {
+		if fc := graphql.GetFieldContext(ctx); fc != nil && fc.Field.Definition != nil {
+			acc.add(e.hint(fc))
+		}
+	}
+	return next(ctx)
+}
+
+// InterceptResponse добавляет политику в extensions; ответ с ошибками не кэшируется
+func (e *Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	acc, ok := ctx.Value(policyKey{}).(*accumulator)
+	if !ok || resp == nil {
+		return resp
+	}
+	if len(resp.Errors) > 0 {
+		zero := 0
+		acc.add(hint{maxAge: &zero})
+	}
+	if e.opts.Private != nil && e.opts.Private(ctx) {
+		acc.add(hint{private: true})
+	}
+	if resp.Extensions == nil {
+		resp.Extensions = make(map[string]any)
+	}
+	policy := acc.policy()
+	if len(resp.Errors) == 0 && policy.Scope == ScopePublic && policy.MaxAge < e.opts.MinMaxAge {
+		policy.MaxAge = e.opts.MinMaxAge
+	}
+	resp.Extensions[ExtensionKey] = policy
+	return resp
+}
+
+// hint - подсказка поля; maxAge nil означает, что поле не ограничивает срок кэширования
+type hint struct {
+	maxAge  *int
+	private bool
+	inherit bool
+}
+
+// hint определяет подсказку поля. Подсказка поля важнее подсказки возвращаемого типа; поле составного
+// типа без подсказок и корневое поле запрещают кэширование, остальные скалярные поля наследуют срок родителя
+func (e *Extension) hint(fc *graphql.FieldContext) hint {
+	def := fc.Field.Definition
+	h := parseHint(def.Directives.ForName(directiveName))
+	if h.maxAge != nil || h.inherit {
+		return h
+	}
+	typ := e.schema.Types[def.Type.Name()]
+	if typ != nil && (typ.Kind == ast.Object || typ.Kind == ast.Interface || typ.Kind == ast.Union) {
+		th := parseHint(typ.Directives.ForName(directiveName))
+		h.private = h.private || th.private
+		h.maxAge = th.maxAge
+	}
+	if h.maxAge == nil && (typ == nil || typ.Kind != ast.Scalar && typ.Kind != ast.Enum || e.isRoot(fc.Object)) {
+		zero := 0
+		h.maxAge = &zero
+	}
+	return h
+}
+
+func (e *Extension) isRoot(object string) bool {
+	return e.schema.Query != nil && e.schema.Query.Name == object
+}
+
+// parseHint разбирает аргументы директивы @cacheControl
+func parseHint(d *ast.Directive) hint {
+	var h hint
+	if d == nil {
+		return h
+	}
+	if arg := d.Arguments.ForName("maxAge"); arg != nil && arg.Value != nil {
+		if maxAge, err := strconv.Atoi(arg.Value.Raw); err == nil {
+			h.maxAge = &maxAge
+		}
+	}
+	if arg := d.Arguments.ForName("scope"); arg != nil && arg.Value != nil {
+		h.private = arg.Value.Raw == string(ScopePrivate)
+	}
+	if arg := d.Arguments.ForName("inheritMaxAge"); arg != nil && arg.Value != nil {
+		h.inherit = arg.Value.Raw == "true"
+	}
+	return h
+}
+
+// accumulator объединяет подсказки полей; поля разрешаются параллельно
+type accumulator struct {
+	mu      sync.Mutex
+	maxAge  *int
+	private bool
+}
+
+func (a *accumulator) add(h hint) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if h.maxAge != nil && (a.maxAge == nil || *h.maxAge < *a.maxAge) {
+		maxAge := *h.maxAge
+		a.maxAge = &maxAge
+	}
+	a.private = a.private || h.private
+}
+
+// policy возвращает итоговую политику; запрос без ограничивающих полей не кэшируется
+func (a *accumulator) policy() Policy {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := Policy{Scope: ScopePublic}
+	if a.maxAge != nil {
+		p.MaxAge = *a.maxAge
+	}
+	if a.private {
+		p.Scope = ScopePrivate
+	}
+	return p
+}
@@ -0,0 +1,106 @@
+package cachecontrol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	mygraphql "github.com/ButyrinIA/system/internal/graphql"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtension(t *testing.T) {
+	store := memory.New()
+	require.NoError(t, store.CreatePost(context.Background(), &models.Post{ID: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55", Title: "Пост", AuthorID: "user1", AllowComments: true, CreatedAt: time.Now()}))
+	newServer := func(opts Options) *handler.Server {
+		srv := handler.New(mygraphql.NewExecutableSchema(mygraphql.Config{
+			Resolvers:  mygraphql.NewResolver(store, nil),
+			Directives: mygraphql.Directives(),
+		}))
+		srv.AddTransport(transport.POST{})
+		srv.Use(New(opts))
+		srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+			if userID := graphql.GetOperationContext(ctx).Headers.Get("X-User"); userID != "" {
+				ctx = context.WithValue(ctx, "userID", userID)
+			}
+			ctx = context.WithValue(ctx, "commentLoader", mygraphql.NewCommentLoader(store))
+			return next(ctx)
+		})
+		return srv
+	}
+	private := func(ctx context.Context) bool {
+		userID, _ := ctx.Value("userID").(string)
+		return userID != ""
+	}
+	srv := newServer(Options{Private: private})
+	execute := func(srv *handler.Server, query, userID string) (*Policy, bool) {
+		t.Helper()
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req.Header.Set("X-User", userID)
+		}
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		var resp struct {
+			Errors     []any `json:"errors"`
+			Extensions struct {
+				CacheControl *Policy `json:"cacheControl"`
+			} `json:"extensions"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp.Extensions.CacheControl, len(resp.Errors) > 0
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		userID string
+		want   Policy
+		errors bool
+	}{
+		{"Срок из типов", `{ posts(limit: 10) { posts { title } } }`, "", Policy{MaxAge: 30, Scope: ScopePublic}, false},
+		{"Скалярные поля наследуют срок", `{ post(id: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55") { title tags } }`, "", Policy{MaxAge: 60, Scope: ScopePublic}, false},
+		{"Самый короткий срок", `{ post(id: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55") { title comments(limit: 10) { comments { content } } } }`, "", Policy{MaxAge: 30, Scope: ScopePublic}, false},
+		{"Дерево категорий", `{ categories { name children { name } } }`, "", Policy{MaxAge: 300, Scope: ScopePublic}, false},
+		{"Приватное поле", `{ post(id: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55") { unreadCommentCount } }`, "", Policy{MaxAge: 60, Scope: ScopePrivate}, false},
+		{"Тип без подсказки", `{ me { id } }`, "", Policy{MaxAge: 0, Scope: ScopePrivate}, false},
+		{"Корневое скалярное поле", `{ __typename }`, "", Policy{MaxAge: 0, Scope: ScopePublic}, false},
+		{"Авторизованный пользователь", `{ posts(limit: 10) { totalCount } }`, "user1", Policy{MaxAge: 30, Scope: ScopePrivate}, false},
+		{"Ошибка", `{ post(id: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55") { comments(limit: 10, cursor: "bad") { totalCount } } }`, "", Policy{MaxAge: 0, Scope: ScopePublic}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, failed := execute(srv, tt.query, tt.userID)
+			assert.Equal(t, tt.errors, failed)
+			require.NotNil(t, policy)
+			assert.Equal(t, tt.want, *policy)
+		})
+	}
+
+	policy, _ := execute(srv, `mutation { markThreadRead(postId: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55") { id } }`, "user1")
+	assert.Nil(t, policy, "Мутации не получают политику кэширования")
+
+	// Нижняя граница срока поднимает только публичные ответы без ошибок
+	floored := newServer(Options{Private: private, MinMaxAge: 120})
+	policy, _ = execute(floored, `{ posts(limit: 10) { posts { title } } }`, "")
+	assert.Equal(t, Policy{MaxAge: 120, Scope: ScopePublic}, *policy)
+	policy, _ = execute(floored, `{ categories { name } }`, "")
+	assert.Equal(t, Policy{MaxAge: 300, Scope: ScopePublic}, *policy)
+	policy, _ = execute(floored, `{ posts(limit: 10) { totalCount } }`, "user1")
+	assert.Equal(t, Policy{MaxAge: 30, Scope: ScopePrivate}, *policy)
+	policy, _ = execute(floored, `{ post(id: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55") { comments(limit: 10, cursor: "bad") { totalCount } } }`, "")
+	assert.Equal(t, Policy{MaxAge: 0, Scope: ScopePublic}, *policy)
+}
@@ -0,0 +1,206 @@
+// Package client реализует минимальный GraphQL-клиент сервера для скриптов и CLI
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client отправляет GraphQL-запросы на сервер по адресу BaseURL
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// New создаёт клиента для сервера с адресом baseURL, например http://localhost:8080
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTP: http.DefaultClient}
+}
+
+// Error - ошибка GraphQL из ответа сервера
+type Error struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+func (e Error) Error() string {
+	if code, ok := e.Extensions["code"].(string); ok {
+		return code + ": " + e.Message
+	}
+	return e.Message
+}
+
+// Errors - список ошибок GraphQL; возвращается, если ответ содержит errors
+type Errors []Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+type request struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors Errors          `json:"errors"`
+}
+
+// Login получает тестовый JWT через /token и сохраняет его в клиенте
+func (c *Client) Login(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/token", nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request token: %s", res.Status)
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token: %v", err)
+	}
+	c.Token = body.Token
+	return body.Token, nil
+}
+
+// Do выполняет запрос или мутацию и декодирует data в out.
+// Если сервер вернул ошибки, data всё равно декодируется, а ошибки возвращаются как Errors
+func (c *Client) Do(ctx context.Context, query string, variables map[string]any, out any) error {
+	payload, err := json.Marshal(request{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/query", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send query: %v", err)
+	}
+	defer res.Body.Close()
+
+	var resp response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("failed to decode response (%s): %v", res.Status, err)
+	}
+	if out != nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return fmt.Errorf("failed to decode data: %v", err)
+		}
+	}
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	return nil
+}
+
+// wsMessage - сообщение протокола graphql-transport-ws
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Subscribe открывает подписку по протоколу graphql-transport-ws и вызывает handle
+// для data каждого события. Возвращается, когда сервер завершил подписку, отменён ctx
+// или handle вернул ошибку
+func (c *Client) Subscribe(ctx context.Context, query string, variables map[string]any, handle func(data json.RawMessage) error) error {
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.DialContext(ctx, "ws"+strings.TrimPrefix(c.BaseURL, "http")+"/query", nil)
+	if err != nil {
+		return fmt.Errorf("failed to open websocket: %v", err)
+	}
+	defer conn.Close()
+	// Закрытие соединения прерывает блокирующее чтение при отмене контекста
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	initPayload := map[string]any{}
+	if c.Token != "" {
+		initPayload["Authorization"] = "Bearer " + c.Token
+	}
+	if err := conn.WriteJSON(map[string]any{"type": "connection_init", "payload": initPayload}); err != nil {
+		return fmt.Errorf("failed to init connection: %v", err)
+	}
+	var ack wsMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		return c.readError(ctx, err)
+	}
+	if ack.Type != "connection_ack" {
+		return fmt.Errorf("connection rejected: %s %s", ack.Type, ack.Payload)
+	}
+
+	subscribe := map[string]any{
+		"id":      "1",
+		"type":    "subscribe",
+		"payload": request{Query: query, Variables: variables},
+	}
+	if err := conn.WriteJSON(subscribe); err != nil {
+		return fmt.Errorf("failed to subscribe: %v", err)
+	}
+
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return c.readError(ctx, err)
+		}
+		switch msg.Type {
+		case "next":
+			var resp response
+			if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+				return fmt.Errorf("failed to decode event: %v", err)
+			}
+			if len(resp.Errors) > 0 {
+				return resp.Errors
+			}
+			if err := handle(resp.Data); err != nil {
+				return err
+			}
+		case "error":
+			var errs Errors
+			if err := json.Unmarshal(msg.Payload, &errs); err != nil {
+				return fmt.Errorf("subscription failed: %s", msg.Payload)
+			}
+			return errs
+		case "complete":
+			return nil
+		case "ping":
+			if err := conn.WriteJSON(wsMessage{Type: "pong"}); err != nil {
+				return fmt.Errorf("failed to answer ping: %v", err)
+			}
+		}
+	}
+}
+
+// readError возвращает ошибку контекста, если чтение прервано его отменой
+func (c *Client) readError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("failed to read websocket message: %v", err)
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/server"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(server.New(&config.Config{}, memory.New()).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClient_LoginAndDo(t *testing.T) {
+	ts := newTestServer(t)
+	c := New(ts.URL+"/", "")
+	ctx := context.Background()
+
+	token, err := c.Login(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, token, c.Token)
+
+	var data struct {
+		CreatePost struct {
+			ID       string `json:"id"`
+			AuthorID string `json:"authorId"`
+		} `json:"createPost"`
+	}
+	err = c.Do(ctx, `mutation { createPost(title: "CLI", content: "", allowComments: true) { id authorId } }`, nil, &data)
+	require.NoError(t, err)
+	assert.NotEmpty(t, data.CreatePost.ID)
+	assert.Equal(t, "user1", data.CreatePost.AuthorID)
+}
+
+func TestClient_DoReturnsGraphQLErrors(t *testing.T) {
+	ts := newTestServer(t)
+	c := New(ts.URL, "")
+
+	var data struct {
+		Post *struct {
+			ID string `json:"id"`
+		} `json:"post"`
+	}
+	err := c.Do(context.Background(), `query { post(id: "00000000-0000-4000-8000-000000000000") { id } }`, nil, &data)
+	var errs Errors
+	require.ErrorAs(t, err, &errs, "Запрос несуществующего поста должен вернуть ошибку GraphQL")
+	assert.Equal(t, "NOT_FOUND", errs[0].Extensions["code"])
+	assert.Nil(t, data.Post)
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	ts := newTestServer(t)
+	c := New(ts.URL, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.Login(ctx)
+	require.NoError(t, err)
+
+	var post struct {
+		CreatePost struct {
+			ID string `json:"id"`
+		} `json:"createPost"`
+	}
+	require.NoError(t, c.Do(ctx, `mutation { createPost(title: "CLI", content: "", allowComments: true) { id } }`, nil, &post))
+	postID := post.CreatePost.ID
+
+	received := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Subscribe(ctx, `subscription($postId: ID!) { commentAdded(postId: $postId) { content } }`,
+			map[string]any{"postId": postID}, func(data json.RawMessage) error {
+				var event struct {
+					CommentAdded struct {
+						Content string `json:"content"`
+					} `json:"commentAdded"`
+				}
+				if err := json.Unmarshal(data, &event); err != nil {
+					return err
+				}
+				received <- event.CommentAdded.Content
+				return nil
+			})
+	}()
+
+	// Комментарий отправляется повторно, пока подписка не зарегистрирована на сервере
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case content := <-received:
+			assert.Contains(t, content, "Из CLI")
+			cancel()
+			assert.ErrorIs(t, <-done, context.Canceled, "Отмена контекста должна завершать подписку")
+			return
+		case err := <-done:
+			t.Fatalf("Подписка завершилась раньше события: %v", err)
+		case <-tick.C:
+			err := c.Do(context.Background(), `mutation($postId: ID!, $content: String!) { createComment(postId: $postId, content: $content) { id } }`,
+				map[string]any{"postId": postID, "content": "Из CLI " + time.Now().String()}, nil)
+			require.NoError(t, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package clock отделяет бизнес-логику от системных часов: компоненты получают Clock при создании,
+// а тесты подставляют Fake и двигают время явно
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock возвращает текущее время и откладывает вызовы по этим же часам
+type Clock interface {
+	Now() time.Time
+	// AfterFunc вызывает f в отдельной горутине, когда часы продвинутся на d
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer - вызов, отложенный AfterFunc
+type Timer interface {
+	// Stop отменяет вызов и сообщает, что он ещё не произошёл
+	Stop() bool
+}
+
+// realClock - системные часы
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Real возвращает системные часы
+func Real() Clock {
+	return realClock{}
+}
+
+// Fake - часы, которые стоят, пока их не сдвинут. Отложенные вызовы происходят, когда Advance или Set
+// доводят часы до их срока. Безопасны для одновременного использования
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer - вызов, отложенный Fake.AfterFunc
+type fakeTimer struct {
+	fake *Fake
+	at   time.Time
+	f    func()
+}
+
+// NewFake создаёт часы, показывающие now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now реализует Clock
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// AfterFunc реализует Clock; при d <= 0 f вызывается сразу
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	f.mu.Lock()
+	t := &fakeTimer{fake: f, at: f.now.Add(d), f: fn}
+	f.timers = append(f.timers, t)
+	f.mu.Unlock()
+	f.fire()
+	return t
+}
+
+// Advance сдвигает часы на d; отрицательное d переводит их назад
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+	f.fire()
+}
+
+// Set устанавливает часы на now
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	f.now = now
+	f.mu.Unlock()
+	f.fire()
+}
+
+// fire запускает отложенные вызовы, срок которых наступил
+func (f *Fake) fire() {
+	f.mu.Lock()
+	var due []*fakeTimer
+	waiting := f.timers[:0]
+	for _, t := range f.timers {
+		if t.at.After(f.now) {
+			waiting = append(waiting, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	f.timers = waiting
+	f.mu.Unlock()
+	for _, t := range due {
+		go t.f()
+	}
+}
+
+// Stop реализует Timer
+func (t *fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	for i, waiting := range t.fake.timers {
+		if waiting == t {
+			t.fake.timers = append(t.fake.timers[:i], t.fake.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
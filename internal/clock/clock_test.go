@@ -0,0 +1,50 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	assert.Equal(t, start, fake.Now())
+	assert.Equal(t, start, fake.Now(), "Часы стоят, пока их не сдвинут")
+
+	fake.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), fake.Now())
+
+	fake.Advance(-time.Hour)
+	assert.Equal(t, start.Add(-59*time.Minute), fake.Now())
+
+	fake.Set(start)
+	assert.Equal(t, start, fake.Now())
+}
+
+func TestFake_AfterFunc(t *testing.T) {
+	fake := NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	fired := make(chan string, 3)
+	fake.AfterFunc(time.Minute, func() { fired <- "минута" })
+	stopped := fake.AfterFunc(time.Minute, func() { fired <- "отменённый" })
+	fake.AfterFunc(time.Hour, func() { fired <- "час" })
+
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop(), "Отменённый вызов не отменяется повторно")
+	fake.Advance(59 * time.Second)
+	assert.Never(t, func() bool { return len(fired) > 0 }, 50*time.Millisecond, 10*time.Millisecond, "До срока вызовов нет")
+
+	fake.Advance(time.Second)
+	assert.Equal(t, "минута", <-fired)
+	fake.Set(fake.Now().Add(time.Hour))
+	assert.Equal(t, "час", <-fired)
+	assert.Never(t, func() bool { return len(fired) > 0 }, 50*time.Millisecond, 10*time.Millisecond, "Отменённый вызов не происходит")
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	now := Real().Now()
+	assert.False(t, now.Before(before))
+	assert.WithinDuration(t, time.Now(), now, time.Second)
+}
@@ -0,0 +1,285 @@
+// Package cluster связывает несколько узлов сервера без Redis и другого внешнего брокера, чтобы
+// комментарий, опубликованный на узле A, получали подписчики, подключённые к узлу B. Рассчитан на
+// небольшие установки из двух-трёх узлов, которые знают адреса друг друга.
+//
+// Узел пересылает каждое событие подписок соседям POST-запросом на EventsPath с общим секретом
+// в заголовке Authorization: Bearer, а сосед публикует его своим подписчикам, не пересылая дальше.
+// Пересылка асинхронная и без повторов: если сосед недоступен, его подписчики пропускают событие
+// и получают комментарий при следующей загрузке поста.
+//
+// Режимов пересылки два:
+//   - broadcast (по умолчанию) - событие получают все соседи; подписчик может быть подключён к любому узлу;
+//   - sticky - подписки поста закреплены за одним узлом кольца согласованного хеширования (Ring) по
+//     ID поста из заголовка X-Post-Id, поэтому событие пересылается только узлу-владельцу. Закрепление
+//     выполняет балансировщик, хеширующий X-Post-Id (например, hash $http_x_post_id consistent в nginx),
+//     или сами узлы: Route проксирует запрос с X-Post-Id, включая WebSocket, узлу-владельцу.
+//     Запросы без X-Post-Id обслуживаются узлом, который их принял, и в режиме sticky события
+//     других узлов до них не доходят
+package cluster
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+)
+
+// EventsPath - путь внутреннего эндпоинта, принимающего события соседей
+const EventsPath = "/internal/cluster/events"
+
+// ForwardedHeader - заголовок запроса, проксированного Route: в нём ID узла, который переслал запрос.
+// Такой запрос обслуживается на месте, даже если кольцо узлов у соседей расходится
+const ForwardedHeader = "X-Cluster-Forwarded"
+
+// maxEventSize ограничивает тело события соседа
+const maxEventSize = 1 << 20
+
+// ErrUnauthorized возвращается Receive, если запрос пришёл без общего секрета узлов
+var ErrUnauthorized = errors.New("invalid cluster secret")
+
+// Peer - соседний узел
+type Peer struct {
+	ID string `yaml:"id"`
+	// URL - базовый адрес узла, по которому доступны EventsPath и GraphQL-эндпоинты
+	URL string `yaml:"url"`
+}
+
+// Event - событие подписок, пересылаемое между узлами. Comment - комментарий в JSON-представлении
+// GraphQL, его разбирает получатель
+type Event struct {
+	Origin  string          `json:"origin"`
+	PostID  string          `json:"postId"`
+	Hidden  bool            `json:"hidden,omitempty"`
+	Comment json.RawMessage `json:"comment"`
+}
+
+// Options задаёт узел; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// NodeID - ID этого узла, одинаковый с его записью в Peers соседей
+	NodeID string
+	Peers  []Peer
+	// Secret - общий секрет узлов для внутреннего эндпоинта
+	Secret string
+	// Sticky включает закрепление подписок поста за узлом-владельцем, иначе события получают все соседи
+	Sticky bool
+	// Timeout - время ожидания ответа соседа на пересланное событие
+	Timeout time.Duration
+	// QueueSize - сколько событий может ждать отправки одному соседу; сверх него события отбрасываются
+	QueueSize int
+	// Replicas - число точек узла на кольце согласованного хеширования
+	Replicas int
+	Client   *http.Client
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = 2 * time.Second
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	if o.Replicas <= 0 {
+		o.Replicas = 100
+	}
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: o.Timeout}
+	}
+	return o
+}
+
+// peer - сосед с очередью событий и прокси для закреплённых запросов
+type peer struct {
+	Peer
+	endpoint string
+	queue    chan Event
+	proxy    *httputil.ReverseProxy
+}
+
+// Node - этот узел в кластере: пересылает события соседям, принимает их события и закрепляет запросы
+type Node struct {
+	opts  Options
+	ring  *Ring
+	peers map[string]*peer
+
+	stop chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// New создаёт узел и запускает отправку событий соседям
+func New(opts Options) (*Node, error) {
+	opts = opts.withDefaults()
+	log.Printf("Создание узла кластера %s: соседей %d, закрепление подписок: %v", opts.NodeID, len(opts.Peers), opts.Sticky)
+	if opts.NodeID == "" {
+		return nil, errors.New("cluster node id is required")
+	}
+	n := &Node{opts: opts, peers: make(map[string]*peer, len(opts.Peers)), stop: make(chan struct{})}
+	nodes := []string{opts.NodeID}
+	for _, p := range opts.Peers {
+		if p.ID == opts.NodeID {
+			continue
+		}
+		target, err := url.Parse(p.URL)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("invalid url of cluster peer %s: %q", p.ID, p.URL)
+		}
+		n.peers[p.ID] = &peer{
+			Peer:     p,
+			endpoint: strings.TrimSuffix(p.URL, "/") + EventsPath,
+			queue:    make(chan Event, opts.QueueSize),
+			proxy:    newProxy(p.ID, target),
+		}
+		nodes = append(nodes, p.ID)
+	}
+	n.ring = NewRing(nodes, opts.Replicas)
+	for _, p := range n.peers {
+		n.wg.Add(1)
+		go n.send(p)
+	}
+	return n, nil
+}
+
+// ID возвращает ID этого узла
+func (n *Node) ID() string {
+	return n.opts.NodeID
+}
+
+// Owner возвращает узел, за которым закреплены подписки поста
+func (n *Node) Owner(postID string) string {
+	return n.ring.Owner(postID)
+}
+
+// Forward ставит событие этого узла в очереди отправки соседям и не ждёт ответа.
+// В режиме sticky событие получает только узел-владелец поста
+func (n *Node) Forward(event Event) {
+	event.Origin = n.opts.NodeID
+	targets := n.peers
+	if n.opts.Sticky {
+		owner := n.ring.Owner(event.PostID)
+		if owner == n.opts.NodeID {
+			return
+		}
+		targets = map[string]*peer{owner: n.peers[owner]}
+	}
+	for _, p := range targets {
+		select {
+		case p.queue <- event:
+		default:
+			metrics.ClusterForwarded.WithLabelValues("dropped").Inc()
+			log.Printf("Очередь событий узла %s заполнена, событие postID=%s отброшено", p.ID, event.PostID)
+		}
+	}
+}
+
+// send отправляет события очереди соседу по одному, сохраняя их порядок
+func (n *Node) send(p *peer) {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case event := <-p.queue:
+			if err := n.post(p, event); err != nil {
+				metrics.ClusterForwarded.WithLabelValues("error").Inc()
+				log.Printf("Ошибка пересылки события postID=%s узлу %s: %v", event.PostID, p.ID, err)
+				continue
+			}
+			metrics.ClusterForwarded.WithLabelValues("sent").Inc()
+		}
+	}
+}
+
+func (n *Node) post(p *peer, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.opts.Secret)
+	resp, err := n.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Receive проверяет секрет запроса соседа и разбирает событие из его тела
+func (n *Node) Receive(r *http.Request) (Event, error) {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(n.opts.Secret)) != 1 {
+		return Event{}, ErrUnauthorized
+	}
+	var event Event
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxEventSize)).Decode(&event); err != nil {
+		return Event{}, fmt.Errorf("invalid event: %w", err)
+	}
+	if event.Origin == "" || event.PostID == "" || len(event.Comment) == 0 {
+		return Event{}, errors.New("invalid event: origin, postId and comment are required")
+	}
+	if event.Origin == n.opts.NodeID {
+		return Event{}, errors.New("invalid event: event originated on this node")
+	}
+	metrics.ClusterReceived.Inc()
+	return event, nil
+}
+
+// Route проксирует запрос с ID поста узлу-владельцу поста, если это не этот узел. Вне режима sticky,
+// без ID поста и для уже проксированных запросов next вызывается сразу
+func (n *Node) Route(next http.Handler) http.Handler {
+	if !n.opts.Sticky {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		postID := PostID(r)
+		if postID == "" || r.Header.Get(ForwardedHeader) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		owner := n.ring.Owner(postID)
+		p, ok := n.peers[owner]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Header.Set(ForwardedHeader, n.opts.NodeID)
+		p.proxy.ServeHTTP(w, r)
+	})
+}
+
+// newProxy создаёт прокси к соседу; ReverseProxy передаёт и обновление соединения до WebSocket
+func newProxy(id string, target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("Ошибка проксирования запроса %s узлу %s: %v", r.URL.Path, id, err)
+		http.Error(w, "cluster peer unavailable", http.StatusBadGateway)
+	}
+	return proxy
+}
+
+// Stop останавливает отправку событий; события, ещё не отправленные соседям, отбрасываются
+func (n *Node) Stop() {
+	n.once.Do(func() {
+		close(n.stop)
+		n.wg.Wait()
+	})
+}
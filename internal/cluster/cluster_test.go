@@ -0,0 +1,197 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	t.Run("Owner does not depend on node order", func(t *testing.T) {
+		a := NewRing([]string{"a", "b", "c"}, 50)
+		b := NewRing([]string{"c", "a", "b"}, 50)
+		for i := 0; i < 1000; i++ {
+			key := "post" + strconv.Itoa(i)
+			assert.Equal(t, a.Owner(key), b.Owner(key))
+		}
+	})
+
+	t.Run("Adding a node moves only its keys", func(t *testing.T) {
+		before := NewRing([]string{"a", "b"}, 100)
+		after := NewRing([]string{"a", "b", "c"}, 100)
+		counts := map[string]int{}
+		for i := 0; i < 3000; i++ {
+			key := "post" + strconv.Itoa(i)
+			owner := after.Owner(key)
+			counts[owner]++
+			if owner != "c" {
+				assert.Equal(t, before.Owner(key), owner, "Ключ переезжает только на новый узел")
+			}
+		}
+		for _, node := range []string{"a", "b", "c"} {
+			assert.Greater(t, counts[node], 500, "Ключи распределяются между всеми узлами")
+		}
+	})
+
+	t.Run("Empty ring", func(t *testing.T) {
+		assert.Equal(t, "", NewRing(nil, 0).Owner("post1"))
+	})
+}
+
+func TestPostID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/query?postId=from-query", nil)
+	assert.Equal(t, "from-query", PostID(req))
+	req.Header.Set(PostIDHeader, "from-header")
+	assert.Equal(t, "from-header", PostID(req), "Заголовок важнее параметра")
+}
+
+// receiver - сосед, который запоминает принятые события
+func receiver(t *testing.T, node **Node) (*httptest.Server, chan Event) {
+	events := make(chan Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := (*node).Receive(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		events <- event
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, events
+}
+
+func TestForward(t *testing.T) {
+	t.Run("Broadcast to all peers", func(t *testing.T) {
+		var b, c *Node
+		srvB, eventsB := receiver(t, &b)
+		srvC, eventsC := receiver(t, &c)
+		peers := []Peer{{ID: "a", URL: "http://127.0.0.1:1"}, {ID: "b", URL: srvB.URL}, {ID: "c", URL: srvC.URL}}
+		var err error
+		b, err = New(Options{NodeID: "b", Peers: peers, Secret: "secret"})
+		require.NoError(t, err)
+		defer b.Stop()
+		c, err = New(Options{NodeID: "c", Peers: peers, Secret: "secret"})
+		require.NoError(t, err)
+		defer c.Stop()
+		a, err := New(Options{NodeID: "a", Peers: peers, Secret: "secret"})
+		require.NoError(t, err)
+		defer a.Stop()
+
+		a.Forward(Event{PostID: "post1", Comment: json.RawMessage(`{"id":"1"}`)})
+		for _, events := range []chan Event{eventsB, eventsC} {
+			select {
+			case event := <-events:
+				assert.Equal(t, "a", event.Origin)
+				assert.Equal(t, "post1", event.PostID)
+				assert.JSONEq(t, `{"id":"1"}`, string(event.Comment))
+			case <-time.After(time.Second):
+				t.Fatal("Таймаут ожидания события")
+			}
+		}
+	})
+
+	t.Run("Sticky forwards only to owner", func(t *testing.T) {
+		var b *Node
+		srvB, events := receiver(t, &b)
+		peers := []Peer{{ID: "a", URL: "http://127.0.0.1:1"}, {ID: "b", URL: srvB.URL}}
+		var err error
+		b, err = New(Options{NodeID: "b", Peers: peers, Secret: "secret", Sticky: true})
+		require.NoError(t, err)
+		defer b.Stop()
+		a, err := New(Options{NodeID: "a", Peers: peers, Secret: "secret", Sticky: true})
+		require.NoError(t, err)
+		defer a.Stop()
+
+		var own, foreign string
+		for i := 0; own == "" || foreign == ""; i++ {
+			postID := "post" + strconv.Itoa(i)
+			if a.Owner(postID) == "a" {
+				own = postID
+			} else {
+				foreign = postID
+			}
+		}
+		a.Forward(Event{PostID: own, Comment: json.RawMessage(`{}`)})
+		a.Forward(Event{PostID: foreign, Comment: json.RawMessage(`{}`)})
+		select {
+		case event := <-events:
+			assert.Equal(t, foreign, event.PostID, "Событие своего поста остаётся на узле")
+		case <-time.After(time.Second):
+			t.Fatal("Таймаут ожидания события")
+		}
+	})
+}
+
+func TestReceive(t *testing.T) {
+	node, err := New(Options{NodeID: "b", Peers: []Peer{{ID: "a", URL: "http://a"}}, Secret: "secret"})
+	require.NoError(t, err)
+	defer node.Stop()
+	request := func(secret string, event Event) *http.Request {
+		body, err := json.Marshal(event)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, EventsPath, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		return req
+	}
+	valid := Event{Origin: "a", PostID: "post1", Comment: json.RawMessage(`{}`)}
+
+	_, err = node.Receive(request("wrong", valid))
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = node.Receive(request("secret", Event{Origin: "b", PostID: "post1", Comment: json.RawMessage(`{}`)}))
+	assert.Error(t, err, "Собственное событие узла не принимается")
+	_, err = node.Receive(request("secret", Event{Origin: "a", Comment: json.RawMessage(`{}`)}))
+	assert.Error(t, err)
+	event, err := node.Receive(request("secret", valid))
+	require.NoError(t, err)
+	assert.Equal(t, "post1", event.PostID)
+}
+
+func TestRoute(t *testing.T) {
+	var served []string
+	handler := func(id string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = append(served, id+":"+r.Header.Get(ForwardedHeader))
+		})
+	}
+	var b *Node
+	srvB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Route(handler("b")).ServeHTTP(w, r)
+	}))
+	defer srvB.Close()
+	peers := []Peer{{ID: "a", URL: "http://127.0.0.1:1"}, {ID: "b", URL: srvB.URL}}
+	b, err := New(Options{NodeID: "b", Peers: peers, Secret: "secret", Sticky: true})
+	require.NoError(t, err)
+	defer b.Stop()
+	a, err := New(Options{NodeID: "a", Peers: peers, Secret: "secret", Sticky: true})
+	require.NoError(t, err)
+	defer a.Stop()
+	route := a.Route(handler("a"))
+
+	var own, foreign string
+	for i := 0; own == "" || foreign == ""; i++ {
+		postID := "post" + strconv.Itoa(i)
+		if a.Owner(postID) == "a" {
+			own = postID
+		} else {
+			foreign = postID
+		}
+	}
+	for _, postID := range []string{"", own, foreign} {
+		req := httptest.NewRequest(http.MethodPost, "/query", nil)
+		if postID != "" {
+			req.Header.Set(PostIDHeader, postID)
+		}
+		rr := httptest.NewRecorder()
+		route.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	assert.Equal(t, []string{"a:", "a:", "b:a"}, served, "Запрос чужого поста обслуживает владелец, не проксируя дальше")
+}
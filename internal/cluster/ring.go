@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// PostIDHeader - заголовок с ID поста, по которому запрос закрепляется за узлом. Клиент подписки
+// передаёт его в запросе на открытие WebSocket, а балансировщик или Route направляет запрос узлу-владельцу
+const PostIDHeader = "X-Post-Id"
+
+// Ring - кольцо согласованного хеширования: каждый узел занимает на кольце replicas точек, а ключ
+// принадлежит узлу первой точки по часовой стрелке. При добавлении или удалении узла меняют владельца
+// только ключи соседних с ним отрезков
+type Ring struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewRing создаёт кольцо из узлов nodes; replicas <= 0 заменяется значением по умолчанию
+func NewRing(nodes []string, replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	r := &Ring{owners: make(map[uint32]string, len(nodes)*replicas)}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			point := hash(node + "#" + strconv.Itoa(i))
+			// Совпадение точек разрешается в пользу меньшего ID, чтобы кольцо не зависело от порядка узлов
+			if owner, ok := r.owners[point]; ok && owner < node {
+				continue
+			} else if !ok {
+				r.points = append(r.points, point)
+			}
+			r.owners[point] = node
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Owner возвращает узел, которому принадлежит ключ; у пустого кольца - пустую строку
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// PostID возвращает ID поста, по которому закрепляется запрос: из заголовка X-Post-Id или,
+// для клиентов, которые не могут задать заголовок WebSocket, из параметра postId
+func PostID(r *http.Request) string {
+	if postID := r.Header.Get(PostIDHeader); postID != "" {
+		return postID
+	}
+	return r.URL.Query().Get("postId")
+}
+
+// hash отображает ключ на кольцо. FNV у коротких похожих ключей вроде "node#1", "node#2" даёт
+// скученные точки, поэтому используется начало SHA-256
+func hash(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
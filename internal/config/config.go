@@ -1,30 +1,798 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
 	"os"
+	"time"
 
+	"github.com/ButyrinIA/system/internal/cluster"
+	"github.com/ButyrinIA/system/internal/faults"
+	"github.com/ButyrinIA/system/internal/flags"
 	"gopkg.in/yaml.v3"
 )
 
+// EnvVar - переменная окружения, в которой можно передать YAML конфигурации целиком
+const EnvVar = "CONFIG"
+
+// DefaultPath - файл, который читается, если путь не задан явно и CONFIG пуст
+const DefaultPath = "config.yaml"
+
+// DevJWTSecret - ключ подписи JWT для разработки; в production должен быть заменён
+const DevJWTSecret = "your-secret-key"
+
+// Поддерживаемые типы хранилища
+const (
+	StorageMemory   = "memory"
+	StoragePostgres = "postgres"
+)
+
+// EnvProduction - значение environment, для которого действуют строгие проверки
+const EnvProduction = "production"
+
 type Config struct {
-	Server struct {
+	// Environment - окружение развёртывания: development, staging или production
+	Environment string `yaml:"environment"`
+	// Storage - тип хранилища: memory или postgres; для postgres нужна секция postgres
+	Storage string `yaml:"storage"`
+	Server  struct {
 		Port string `yaml:"port"`
+		// Compression - сжимать ответы gzip для клиентов, которые его принимают
+		Compression bool `yaml:"compression"`
+		// CompressionMinSize - ответы меньше этого размера в байтах не сжимаются
+		CompressionMinSize int `yaml:"compressionMinSize"`
+		// CacheMaxAge - верхняя граница max-age в Cache-Control ответов на GET-запросы; сам срок задают
+		// подсказки @cacheControl схемы. 0 запрещает кэширование
+		CacheMaxAge time.Duration `yaml:"cacheMaxAge"`
+		// Maintenance - запуск в режиме обслуживания: мутации отклоняются, пока режим не выключат
+		// мутацией setMaintenanceMode или сигналом SIGUSR2
+		Maintenance bool `yaml:"maintenance"`
+		// MaxBatchSize - наибольшее число операций в пакетном запросе (JSON-массиве операций); 0 отключает пакетные запросы
+		MaxBatchSize int `yaml:"maxBatchSize"`
+		// ShutdownTimeout - сколько ждать завершения HTTP-запросов при остановке по SIGINT или SIGTERM
+		ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+		// DrainPeriod - сколько в начале остановки сервер продолжает принимать запросы, отвечая на /readyz
+		// статусом draining, чтобы балансировщик успел вывести его из ротации. WebSocket-соединения
+		// за это время закрываются равномерно, а не все сразу. Входит в ShutdownTimeout; 0 отключает
+		DrainPeriod time.Duration `yaml:"drainPeriod"`
+		// ReusePort - открывать порт с SO_REUSEPORT, чтобы новый процесс мог слушать его, пока прежний завершается
+		ReusePort bool `yaml:"reusePort"`
 	} `yaml:"server"`
+	// Subscriptions - параметры WebSocket-соединений подписок
+	Subscriptions struct {
+		// KeepAliveInterval - период сообщений ka протокола graphql-ws; 0 отключает их
+		KeepAliveInterval time.Duration `yaml:"keepAliveInterval"`
+		// PingInterval - период ping протокола graphql-transport-ws; клиент, не ответивший pong
+		// за два периода, отключается. 0 отключает ping
+		PingInterval time.Duration `yaml:"pingInterval"`
+		// InitTimeout - сколько ждать connection_init после подключения; 0 - без ограничения
+		InitTimeout time.Duration `yaml:"initTimeout"`
+		// ReconnectMinBackoff и ReconnectMaxBackoff - границы задержки перед переподключением,
+		// которые сервер сообщает клиентам в расширении reconnect ответов подписок
+		ReconnectMinBackoff time.Duration `yaml:"reconnectMinBackoff"`
+		ReconnectMaxBackoff time.Duration `yaml:"reconnectMaxBackoff"`
+	} `yaml:"subscriptions"`
+	// Tenants - определение сообщества запроса для настроек tenantSettings
+	Tenants struct {
+		// Header - заголовок с ID сообщества; его должен выставлять прокси, например по домену.
+		// Запросы без заголовка относятся к сообществу default
+		Header string `yaml:"header"`
+		// Quota - лимиты хранилища каждого сообщества; новые посты и комментарии сверх лимита отклоняются
+		Quota TenantQuota `yaml:"quota"`
+		// QuotaOverrides - лимиты отдельных сообществ вместо quota
+		QuotaOverrides map[string]TenantQuota `yaml:"quotaOverrides"`
+	} `yaml:"tenants"`
+	Auth struct {
+		JWTSecret string `yaml:"jwtSecret"`
+	} `yaml:"auth"`
+	// Secrets - источники секретов. Вместо значения поля из SecretFields можно указать ссылку
+	// env:NAME, file:/path, vault:<путь>#<поле> или aws:<секрет>[#<поле>], она читается при запуске
+	Secrets struct {
+		// RefreshInterval - как часто перечитываются секреты по ссылкам; изменившийся ключ JWT
+		// применяется без перезапуска, остальные секреты - после перезапуска. 0 отключает перечитывание
+		RefreshInterval time.Duration `yaml:"refreshInterval"`
+		Vault           struct {
+			Addr string `yaml:"addr"`
+			// TokenFile - файл с токеном, например от Vault Agent; без него используется VAULT_TOKEN
+			TokenFile string `yaml:"tokenFile"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"vault"`
+		AWS struct {
+			// Region - регион Secrets Manager; без него используется AWS_REGION. Учётные данные - из AWS_ACCESS_KEY_ID,
+			// AWS_SECRET_ACCESS_KEY и AWS_SESSION_TOKEN
+			Region   string `yaml:"region"`
+			Endpoint string `yaml:"endpoint"`
+		} `yaml:"aws"`
+	} `yaml:"secrets"`
 	Postgres struct {
 		DSN string `yaml:"dsn"`
+		// MaxConns - наибольшее число подключений в пуле; запросы и фоновые задачи берут из него свои подключения
+		MaxConns               int           `yaml:"maxConns"`
+		ConnectTimeout         time.Duration `yaml:"connectTimeout"`
+		MaxRetries             int           `yaml:"maxRetries"`
+		RetryBackoff           time.Duration `yaml:"retryBackoff"`
+		MaxBackoff             time.Duration `yaml:"maxBackoff"`
+		SkipMigrations         bool          `yaml:"skipMigrations"`
+		StatementTimeout       time.Duration `yaml:"statementTimeout"`
+		QueryTimeout           time.Duration `yaml:"queryTimeout"`
+		SlowQueryThreshold     time.Duration `yaml:"slowQueryThreshold"`
+		LogQueryParams         bool          `yaml:"logQueryParams"`
+		CountReconcileInterval time.Duration `yaml:"countReconcileInterval"`
+		// CommentArchiveMonths - комментарии старше стольких месяцев переносятся в архивную таблицу; 0 отключает перенос
+		CommentArchiveMonths    int           `yaml:"commentArchiveMonths"`
+		CommentArchiveInterval  time.Duration `yaml:"commentArchiveInterval"`
+		CommentArchiveBatchSize int           `yaml:"commentArchiveBatchSize"`
+		// PartitionComments переводит comments на помесячные секции по created_at. Перенос существующих
+		// комментариев выполняется при миграции и блокирует таблицу; на больших таблицах на это время
+		// нужно увеличить statementTimeout и queryTimeout
+		PartitionComments        bool          `yaml:"partitionComments"`
+		CommentPartitionsAhead   int           `yaml:"commentPartitionsAhead"`
+		CommentPartitionInterval time.Duration `yaml:"commentPartitionInterval"`
 	} `yaml:"postgres"`
+	IDs struct {
+		// Format - формат идентификаторов новых сущностей: uuid (случайный), uuidv7, ulid или snowflake;
+		// последние три упорядочены по времени создания
+		Format string `yaml:"format"`
+		// Node - номер экземпляра сервера для snowflake, у каждого экземпляра свой
+		Node int `yaml:"node"`
+	} `yaml:"ids"`
+	Comments struct {
+		DedupeWindow time.Duration `yaml:"dedupeWindow"`
+	} `yaml:"comments"`
+	// Content - лимиты длины постов и комментариев и анонсы постов
+	Content struct {
+		// Unit - единица длины: runes (символы) или bytes (байты UTF-8)
+		Unit string `yaml:"unit"`
+		// Limits - лимиты длины в каждом сообществе
+		Limits ContentLimits `yaml:"limits"`
+		// LimitOverrides - лимиты отдельных сообществ вместо limits
+		LimitOverrides map[string]ContentLimits `yaml:"limitOverrides"`
+		// ExcerptLength - длина анонса поста в поле excerpt, в графемах вместе с многоточием
+		ExcerptLength int `yaml:"excerptLength"`
+		// Summarizer - внешний сервис кратких содержаний: после создания поста анонс в фоне заменяется
+		// ответом сервиса на POST {"title", "text", "language", "maxLength"} с полем summary
+		Summarizer struct {
+			Enabled   bool          `yaml:"enabled"`
+			URL       string        `yaml:"url"`
+			Timeout   time.Duration `yaml:"timeout"`
+			Workers   int           `yaml:"workers"`
+			QueueSize int           `yaml:"queueSize"`
+		} `yaml:"summarizer"`
+		// Duplicates - поиск постов с похожим заголовком перед созданием поста
+		Duplicates struct {
+			// Enforcement - warn: похожие посты только показываются клиентом по checkDuplicate,
+			// block: createPost отклоняет пост с DUPLICATE
+			Enforcement string `yaml:"enforcement"`
+			// Threshold - наименьшее сходство заголовков по триграммам, от 0.3 до 1
+			Threshold float64 `yaml:"threshold"`
+			// MaxLimit - наибольшее число похожих постов в ответе
+			MaxLimit int `yaml:"maxLimit"`
+		} `yaml:"duplicates"`
+	} `yaml:"content"`
+	Encryption struct {
+		// Enabled - хранить содержимое постов и комментариев зашифрованным
+		Enabled bool `yaml:"enabled"`
+		// KeyFile - YAML с основным и прежними ключами шифрования ключей, см. encryption.FileProvider
+		KeyFile string `yaml:"keyFile"`
+		// ReencryptBatchSize - сколько записей читает за раз перешифрование (-reencrypt)
+		ReencryptBatchSize int `yaml:"reencryptBatchSize"`
+	} `yaml:"encryption"`
+	// Purge - обезличивание содержимого пользователей мутацией purgeUserContent
+	Purge struct {
+		// BatchSize и BatchInterval ограничивают нагрузку на базу: сколько записей обрабатывается за раз
+		// и пауза между пачками
+		BatchSize     int           `yaml:"batchSize"`
+		BatchInterval time.Duration `yaml:"batchInterval"`
+		// Retention - как долго доступен статус завершённой задачи
+		Retention time.Duration `yaml:"retention"`
+	} `yaml:"purge"`
+	Cache struct {
+		PostTTL  time.Duration `yaml:"postTTL"`
+		PostSize int           `yaml:"postSize"`
+	} `yaml:"cache"`
+	// Storm - сглаживание всплесков комментариев: объединение вставок в пачки и сводки событий подписок
+	Storm struct {
+		Enabled bool `yaml:"enabled"`
+		// WriteThreshold - число новых комментариев в секунду на узле, начиная с которого вставки объединяются в пачки
+		WriteThreshold int `yaml:"writeThreshold"`
+		// BatchWindow - сколько вставка ждёт попутчиков; MaxBatch - размер пачки, которая пишется не дожидаясь окна
+		BatchWindow time.Duration `yaml:"batchWindow"`
+		MaxBatch    int           `yaml:"maxBatch"`
+		// EventThreshold - число событий подписок в секунду на пост, начиная с которого подписчики получают сводки
+		EventThreshold int `yaml:"eventThreshold"`
+		// DigestInterval - как часто рассылаются сводки; DigestMaxComments - сколько последних комментариев в сводке
+		DigestInterval    time.Duration `yaml:"digestInterval"`
+		DigestMaxComments int           `yaml:"digestMaxComments"`
+	} `yaml:"storm"`
+	// Cluster - доставка событий подписок между узлами без внешнего брокера, см. пакет cluster
+	Cluster struct {
+		Enabled bool `yaml:"enabled"`
+		// NodeID - ID этого узла; соседи знают его под этим же ID
+		NodeID string         `yaml:"nodeID"`
+		Peers  []cluster.Peer `yaml:"peers"`
+		// Secret - общий секрет узлов для внутреннего эндпоинта событий
+		Secret string `yaml:"secret"`
+		// Mode - broadcast (события получают все соседи) или sticky (подписки поста закреплены за узлом по X-Post-Id)
+		Mode string `yaml:"mode"`
+		// Timeout - время ожидания ответа соседа; QueueSize - сколько событий может ждать отправки одному соседу
+		Timeout   time.Duration `yaml:"timeout"`
+		QueueSize int           `yaml:"queueSize"`
+	} `yaml:"cluster"`
+	// WriteBehind - отложенная запись комментариев через очередь с журналом на диске, см. пакет writebehind.
+	// Несовместима с ненулевым comments.dedupeWindow: повтор нашёлся бы уже после ответа вызывающему
+	WriteBehind struct {
+		Enabled bool `yaml:"enabled"`
+		// QueueSize - сколько комментариев может ждать записи; сверх него комментарии пишутся сразу
+		QueueSize int `yaml:"queueSize"`
+		// FlushInterval - наибольшее время ожидания комментария в очереди; MaxBatch - размер пачки записи
+		FlushInterval time.Duration `yaml:"flushInterval"`
+		MaxBatch      int           `yaml:"maxBatch"`
+		// JournalPath - файл журнала, из которого комментарии восстанавливаются после падения процесса
+		JournalPath string `yaml:"journalPath"`
+	} `yaml:"writeBehind"`
+	// Flags - флаги постепенного включения возможностей, см. пакет flags
+	Flags struct {
+		Definitions map[string]flags.Flag `yaml:"definitions"`
+		// RemoteURL - адрес JSON с флагами, которые заменяют одноимённые из definitions; пустой отключает источник
+		RemoteURL string `yaml:"remoteURL"`
+		// RefreshInterval - как часто перечитывается RemoteURL
+		RefreshInterval time.Duration `yaml:"refreshInterval"`
+	} `yaml:"flags"`
+	// Faults - внедрение ошибок и задержек для проверки устойчивости; в production не включается
+	Faults struct {
+		Enabled bool `yaml:"enabled"`
+		// Storage - сбои вызовов хранилища; операции - имена методов storage.Storage
+		Storage faults.Options `yaml:"storage"`
+		// HTTP - сбои HTTP-запросов, которые получают 503; операции - пути запросов
+		HTTP faults.Options `yaml:"http"`
+	} `yaml:"faults"`
+	// CostBudget - бюджет суммарной стоимости GraphQL-операций пользователя, см. пакет costbudget
+	CostBudget struct {
+		Enabled bool `yaml:"enabled"`
+		// Capacity - стоимость операций, которую пользователь может потратить за Period
+		Capacity int `yaml:"capacity"`
+		// Period - время, за которое израсходованный бюджет восстанавливается полностью
+		Period time.Duration `yaml:"period"`
+	} `yaml:"costBudget"`
+	// Partial - режим лучшего усилия для запросов у срока, см. пакет partial
+	Partial struct {
+		Enabled bool `yaml:"enabled"`
+		// Budget - бюджет времени запроса
+		Budget time.Duration `yaml:"budget"`
+		// Reserve - за сколько до конца бюджета счётчики становятся приблизительными, а глубокие агрегаты - null
+		Reserve time.Duration `yaml:"reserve"`
+	} `yaml:"partial"`
+	Quotas struct {
+		Enabled     bool                   `yaml:"enabled"`
+		DefaultRole string                 `yaml:"defaultRole"`
+		Roles       map[string]QuotaLimits `yaml:"roles"`
+	} `yaml:"quotas"`
+	Allowlist struct {
+		Enabled bool `yaml:"enabled"`
+		// Source - откуда загружаются разрешённые операции: dir - файлы *.graphql и *.gql из Dir,
+		// storage - таблица allowed_operations хранилища
+		Source string `yaml:"source"`
+		Dir    string `yaml:"dir"`
+	} `yaml:"allowlist"`
+	ErrorReporting struct {
+		SentryDSN   string `yaml:"sentryDSN"`
+		Environment string `yaml:"environment"`
+	} `yaml:"errorReporting"`
+	LinkPreview struct {
+		Enabled      bool          `yaml:"enabled"`
+		Timeout      time.Duration `yaml:"timeout"`
+		Workers      int           `yaml:"workers"`
+		AllowDomains []string      `yaml:"allowDomains"`
+		DenyDomains  []string      `yaml:"denyDomains"`
+	} `yaml:"linkPreview"`
+	Spam struct {
+		Enabled bool `yaml:"enabled"`
+		// Provider - сервис проверки: bayes (локальный классификатор) или akismet
+		Provider string `yaml:"provider"`
+		// Async - проверять комментарии в фоне, не задерживая их создание
+		Async   bool          `yaml:"async"`
+		Timeout time.Duration `yaml:"timeout"`
+		Workers int           `yaml:"workers"`
+		Akismet struct {
+			APIKey   string `yaml:"apiKey"`
+			Site     string `yaml:"site"`
+			Endpoint string `yaml:"endpoint"`
+		} `yaml:"akismet"`
+		Bayes struct {
+			Threshold float64 `yaml:"threshold"`
+			// TrainingLimit - сколько последних проверенных комментариев каждого класса загружается при старте
+			TrainingLimit int `yaml:"trainingLimit"`
+		} `yaml:"bayes"`
+	} `yaml:"spam"`
+	Toxicity struct {
+		Enabled bool `yaml:"enabled"`
+		// URL - адрес метода comments:analyze сервиса в формате Perspective API; пустой - Perspective API Google
+		URL       string        `yaml:"url"`
+		APIKey    string        `yaml:"apiKey"`
+		Timeout   time.Duration `yaml:"timeout"`
+		Workers   int           `yaml:"workers"`
+		QueueSize int           `yaml:"queueSize"`
+		// HoldThreshold - оценка от 0 до 1, начиная с которой комментарий задерживается до решения модератора
+		HoldThreshold float64 `yaml:"holdThreshold"`
+	} `yaml:"toxicity"`
+	Translation struct {
+		Enabled bool `yaml:"enabled"`
+		// Provider - сервис машинного перевода; пока поддерживается только libretranslate
+		Provider string        `yaml:"provider"`
+		Endpoint string        `yaml:"endpoint"`
+		APIKey   string        `yaml:"apiKey"`
+		Timeout  time.Duration `yaml:"timeout"`
+		// CacheSize и CacheTTL - сколько переводов хранится и как долго
+		CacheSize int           `yaml:"cacheSize"`
+		CacheTTL  time.Duration `yaml:"cacheTTL"`
+	} `yaml:"translation"`
+	Email struct {
+		// SMTPAddr - адрес SMTP-сервера в виде host:port; без него письма пишутся в лог
+		SMTPAddr string `yaml:"smtpAddr"`
+		From     string `yaml:"from"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"email"`
+	Digest struct {
+		Enabled bool `yaml:"enabled"`
+		// Interval - как часто проверяется, кому из подписчиков пора отправить сводку
+		Interval    time.Duration `yaml:"interval"`
+		MaxComments int           `yaml:"maxComments"`
+	} `yaml:"digest"`
+	SearchAlerts struct {
+		Enabled bool `yaml:"enabled"`
+		// Interval - как часто сохранённые поиски проверяются на новые посты
+		Interval time.Duration `yaml:"interval"`
+		MaxPosts int           `yaml:"maxPosts"`
+	} `yaml:"searchAlerts"`
+	Search struct {
+		// Engine - движок поиска постов по тексту: пустая строка оставляет поиск условиями хранилища,
+		// memory - встроенный индекс в памяти процесса, elasticsearch или opensearch - внешний кластер
+		Engine   string        `yaml:"engine"`
+		URL      string        `yaml:"url"`
+		Index    string        `yaml:"index"`
+		Username string        `yaml:"username"`
+		Password string        `yaml:"password"`
+		Timeout  time.Duration `yaml:"timeout"`
+		// MaxResults - сколько самых релевантных постов движок возвращает на один поиск
+		MaxResults int `yaml:"maxResults"`
+		// QueueSize - сколько изменённых постов может ждать фоновой индексации
+		QueueSize int `yaml:"queueSize"`
+		// BatchSize - сколько постов индексируется за раз в фоне и при переиндексации
+		BatchSize int `yaml:"batchSize"`
+	} `yaml:"search"`
+	Push struct {
+		Enabled bool `yaml:"enabled"`
+		// Timeout - ограничение на отправку одного уведомления
+		Timeout   time.Duration `yaml:"timeout"`
+		Workers   int           `yaml:"workers"`
+		QueueSize int           `yaml:"queueSize"`
+		// Платформы без настроек не получают уведомлений; если не настроена ни одна, уведомления пишутся в лог
+		FCM struct {
+			// CredentialsFile - JSON-ключ сервисного аккаунта Firebase
+			CredentialsFile string `yaml:"credentialsFile"`
+		} `yaml:"fcm"`
+		APNs struct {
+			// KeyFile - ключ .p8 для аутентификации по токену
+			KeyFile    string `yaml:"keyFile"`
+			KeyID      string `yaml:"keyID"`
+			TeamID     string `yaml:"teamID"`
+			Topic      string `yaml:"topic"`
+			Production bool   `yaml:"production"`
+		} `yaml:"apns"`
+		WebPush struct {
+			// VAPIDPrivateKey - закрытый ключ P-256 в base64url
+			VAPIDPrivateKey string `yaml:"vapidPrivateKey"`
+			Subject         string `yaml:"subject"`
+		} `yaml:"webPush"`
+	} `yaml:"push"`
+	// Recording - запись мутаций в файл для проверки переноса данных на другое хранилище командой replay
+	Recording struct {
+		Enabled bool `yaml:"enabled"`
+		// File - файл JSON Lines, в который дописываются мутации
+		File string `yaml:"file"`
+	} `yaml:"recording"`
+	// Embed - виджет комментариев для встраивания на внешние сайты через iframe и oEmbed
+	Embed struct {
+		Enabled bool `yaml:"enabled"`
+		// BaseURL - внешний адрес сервера, из которого строятся ссылки на виджет в ответах oEmbed
+		BaseURL string `yaml:"baseURL"`
+		// LoginURL - страница входа, на которую ведёт виджет, чтобы оставить комментарий
+		LoginURL string `yaml:"loginURL"`
+		// Width и Height - размер iframe, если сайт не запросил меньший
+		Width  int `yaml:"width"`
+		Height int `yaml:"height"`
+	} `yaml:"embed"`
+	// Anonymous - комментарии без учётной записи. Запрос без авторизации получает cookie со случайным
+	// идентификатором; в хранилище попадает только его HMAC, а в обсуждении автор показывается
+	// псевдонимом, постоянным в пределах поста. Политика anonymousPolicy сообщества продолжает действовать
+	Anonymous struct {
+		Enabled bool `yaml:"enabled"`
+		// Secret - ключ HMAC идентификатора; его смена превращает всех анонимных авторов в новых
+		Secret     string `yaml:"secret"`
+		CookieName string `yaml:"cookieName"`
+		// CookieMaxAge - срок хранения cookie в браузере
+		CookieMaxAge time.Duration `yaml:"cookieMaxAge"`
+	} `yaml:"anonymous"`
+	// PublicAPI - эндпоинт /public/query для анонимного чтения. Авторизация на нём не проверяется, выполняются
+	// только запросы с разрешёнными корневыми полями, ответы кэшируются дольше, а бюджет стоимости операций
+	// с одного IP меньше, чем у /query
+	PublicAPI struct {
+		Enabled bool `yaml:"enabled"`
+		// Queries - разрешённые корневые поля Query; комментарии читаются через поля постов
+		Queries []string `yaml:"queries"`
+		// CacheMinAge - нижняя граница max-age кэшируемых ответов, даже если подсказки @cacheControl задают меньший срок
+		CacheMinAge time.Duration `yaml:"cacheMinAge"`
+		// CacheMaxAge - верхняя граница max-age, как Server.CacheMaxAge
+		CacheMaxAge time.Duration `yaml:"cacheMaxAge"`
+		// Capacity и Period - бюджет стоимости операций одного IP, см. CostBudget
+		Capacity int           `yaml:"capacity"`
+		Period   time.Duration `yaml:"period"`
+	} `yaml:"publicAPI"`
+	// Ranking - стратегии ранжирования комментариев поста, см. пакет ranking. Эксперименты включаются
+	// флагами ranking-controversial и ranking-ml-score
+	Ranking struct {
+		// MaxCandidates - сколько последних комментариев поста переупорядочивают стратегии controversial и ml-score
+		MaxCandidates int `yaml:"maxCandidates"`
+		// Tenants - стратегия по умолчанию для сообществ: chronological, best, controversial или ml-score
+		Tenants map[string]string `yaml:"tenants"`
+		// MLScoreURL - адрес модели для стратегии ml-score; пустой отключает стратегию
+		MLScoreURL string `yaml:"mlScoreURL"`
+		// MLScoreTimeout - сколько ждать ответа модели, после чего используется best
+		MLScoreTimeout time.Duration `yaml:"mlScoreTimeout"`
+	} `yaml:"ranking"`
+	// SLO - цели уровня обслуживания GraphQL-операций; по ним в /metrics публикуются SLI и бюджеты ошибок
+	// для алертов по скорости расхода бюджета, см. пакет slo
+	SLO struct {
+		Enabled bool `yaml:"enabled"`
+		// Classes - цели по классам операций: query и mutation
+		Classes map[string]SLOClass `yaml:"classes"`
+	} `yaml:"slo"`
+	// Profiling - профили pprof под /debug/pprof/ для непрерывного профилирования (Pyroscope, Parca)
+	// с метками GraphQL-операций и рассылки подписок
+	Profiling struct {
+		Enabled bool `yaml:"enabled"`
+		// Token - токен Bearer, с которым профилировщик забирает профили
+		Token string `yaml:"token"`
+		// MutexProfileFraction - в профиль mutex попадает в среднем 1 из N блокировок; 0 отключает профиль
+		MutexProfileFraction int `yaml:"mutexProfileFraction"`
+		// BlockProfileRate - в профиль block попадает в среднем одно ожидание на каждые N наносекунд; 0 отключает профиль
+		BlockProfileRate int `yaml:"blockProfileRate"`
+	} `yaml:"profiling"`
+	// Runtime - настройки сборщика мусора для нагруженных развёртываний, см. пакет gctuning
+	Runtime struct {
+		// GCPercent - GOGC; 0 оставляет значение из окружения, -1 отключает сборку до достижения MemoryLimit
+		GCPercent int `yaml:"gcPercent"`
+		// MemoryLimit - GOMEMLIMIT в байтах; 0 оставляет значение из окружения
+		MemoryLimit int64 `yaml:"memoryLimit"`
+		// BallastBytes - размер балласта кучи в байтах; 0 - без балласта
+		BallastBytes int64 `yaml:"ballastBytes"`
+		// GCPauseSampleInterval - период чтения пауз GC для метрики http_request_gc_pause_seconds; 0 отключает метрику
+		GCPauseSampleInterval time.Duration `yaml:"gcPauseSampleInterval"`
+	} `yaml:"runtime"`
 }
 
+// Поддерживаемые форматы идентификаторов
+const (
+	IDFormatUUID      = "uuid"
+	IDFormatUUIDv7    = "uuidv7"
+	IDFormatULID      = "ulid"
+	IDFormatSnowflake = "snowflake"
+)
+
+// Поддерживаемые сервисы проверки на спам
+const (
+	SpamBayes   = "bayes"
+	SpamAkismet = "akismet"
+)
+
+// Источники разрешённых операций allowlist
+const (
+	AllowlistSourceDir     = "dir"
+	AllowlistSourceStorage = "storage"
+)
+
+// TranslationLibreTranslate - сервис машинного перевода LibreTranslate
+const TranslationLibreTranslate = "libretranslate"
+
+// Поддерживаемые движки поиска постов
+const (
+	SearchMemory        = "memory"
+	SearchElasticsearch = "elasticsearch"
+	SearchOpenSearch    = "opensearch"
+)
+
+// Режимы доставки событий подписок между узлами кластера
+const (
+	ClusterModeBroadcast = "broadcast"
+	ClusterModeSticky    = "sticky"
+)
+
+// TenantQuota - лимиты хранилища сообщества; 0 означает отсутствие ограничения
+type TenantQuota struct {
+	Posts    int64 `yaml:"posts"`
+	Comments int64 `yaml:"comments"`
+	// Bytes - суммарный размер заголовков и текстов постов и комментариев
+	Bytes int64 `yaml:"bytes"`
+}
+
+// ContentLimits - наибольшая длина заголовка поста, текста поста и текста комментария в единицах content.unit;
+// 0 означает отсутствие ограничения
+type ContentLimits struct {
+	Title   int `yaml:"title"`
+	Post    int `yaml:"post"`
+	Comment int `yaml:"comment"`
+}
+
+// Единицы длины текстов
+const (
+	ContentUnitRunes = "runes"
+	ContentUnitBytes = "bytes"
+)
+
+// Режимы проверки постов на повтор
+const (
+	DuplicatesWarn  = "warn"
+	DuplicatesBlock = "block"
+)
+
+// QuotaLimits - лимиты роли; 0 означает отсутствие ограничения
+type QuotaLimits struct {
+	PostsPerDay       int `yaml:"postsPerDay"`
+	CommentsPerMinute int `yaml:"commentsPerMinute"`
+}
+
+// SLOClass - цели класса операций: доли операций, которые должны быть успешными и быстрыми
+type SLOClass struct {
+	// Availability - доля операций без ошибок сервера, например 0.999
+	Availability float64 `yaml:"availability"`
+	// Latency - доля операций, выполненных быстрее LatencyThreshold
+	Latency          float64       `yaml:"latency"`
+	LatencyThreshold time.Duration `yaml:"latencyThreshold"`
+}
+
+// Default возвращает встроенную конфигурацию, с которой сервер запускается без файла
+func Default() *Config {
+	var cfg Config
+	cfg.Environment = "development"
+	cfg.Storage = StorageMemory
+	cfg.Server.Port = "8080"
+	cfg.Server.Compression = true
+	cfg.Server.CompressionMinSize = 1024
+	cfg.Server.CacheMaxAge = 5 * time.Minute
+	cfg.Server.MaxBatchSize = 10
+	cfg.Server.ShutdownTimeout = 10 * time.Second
+	cfg.Subscriptions.KeepAliveInterval = 30 * time.Second
+	cfg.Subscriptions.PingInterval = 30 * time.Second
+	cfg.Subscriptions.InitTimeout = 10 * time.Second
+	cfg.Subscriptions.ReconnectMinBackoff = time.Second
+	cfg.Subscriptions.ReconnectMaxBackoff = 30 * time.Second
+	cfg.Tenants.Header = "X-Tenant-ID"
+	cfg.Secrets.RefreshInterval = 5 * time.Minute
+	cfg.IDs.Format = IDFormatUUID
+	cfg.Postgres.MaxConns = 10
+	cfg.Postgres.ConnectTimeout = 5 * time.Second
+	cfg.Postgres.MaxRetries = 10
+	cfg.Postgres.RetryBackoff = 500 * time.Millisecond
+	cfg.Postgres.MaxBackoff = 10 * time.Second
+	cfg.Postgres.StatementTimeout = 5 * time.Second
+	cfg.Postgres.QueryTimeout = 10 * time.Second
+	cfg.Postgres.SlowQueryThreshold = 200 * time.Millisecond
+	cfg.Postgres.CountReconcileInterval = 10 * time.Minute
+	cfg.Postgres.CommentArchiveInterval = time.Hour
+	cfg.Postgres.CommentArchiveBatchSize = 1000
+	cfg.Postgres.CommentPartitionsAhead = 3
+	cfg.Postgres.CommentPartitionInterval = 24 * time.Hour
+	cfg.Comments.DedupeWindow = 5 * time.Second
+	cfg.Content.Unit = ContentUnitRunes
+	cfg.Content.Limits = ContentLimits{Title: 200, Post: 2000, Comment: 2000}
+	cfg.Content.ExcerptLength = 200
+	cfg.Content.Summarizer.Timeout = 10 * time.Second
+	cfg.Content.Summarizer.Workers = 2
+	cfg.Content.Summarizer.QueueSize = 100
+	cfg.Content.Duplicates.Enforcement = DuplicatesWarn
+	cfg.Content.Duplicates.Threshold = 0.6
+	cfg.Content.Duplicates.MaxLimit = 20
+	cfg.Encryption.ReencryptBatchSize = 100
+	cfg.Purge.BatchSize = 100
+	cfg.Purge.BatchInterval = time.Second
+	cfg.Purge.Retention = 7 * 24 * time.Hour
+	cfg.Cache.PostTTL = 5 * time.Second
+	cfg.Cache.PostSize = 1000
+	cfg.Storm.WriteThreshold = 200
+	cfg.Storm.BatchWindow = 20 * time.Millisecond
+	cfg.Storm.MaxBatch = 50
+	cfg.Storm.EventThreshold = 50
+	cfg.Storm.DigestInterval = time.Second
+	cfg.Storm.DigestMaxComments = 20
+	cfg.Cluster.Mode = ClusterModeBroadcast
+	cfg.Cluster.Timeout = 2 * time.Second
+	cfg.Cluster.QueueSize = 1000
+	cfg.WriteBehind.QueueSize = 1000
+	cfg.WriteBehind.FlushInterval = 50 * time.Millisecond
+	cfg.WriteBehind.MaxBatch = 100
+	cfg.WriteBehind.JournalPath = "comments.wal"
+	cfg.Flags.RefreshInterval = time.Minute
+	cfg.Faults.HTTP.Operations = []string{"/query"}
+	cfg.CostBudget.Capacity = 10000
+	cfg.CostBudget.Period = time.Minute
+	cfg.Partial.Budget = 2 * time.Second
+	cfg.Partial.Reserve = 500 * time.Millisecond
+	cfg.Quotas.Enabled = true
+	cfg.Quotas.DefaultRole = "user"
+	cfg.Quotas.Roles = map[string]QuotaLimits{
+		"user":      {PostsPerDay: 20, CommentsPerMinute: 10},
+		"moderator": {},
+	}
+	cfg.Allowlist.Source = AllowlistSourceDir
+	cfg.Allowlist.Dir = "operations"
+	cfg.LinkPreview.Enabled = true
+	cfg.LinkPreview.Timeout = 5 * time.Second
+	cfg.LinkPreview.Workers = 2
+	cfg.Spam.Provider = SpamBayes
+	cfg.Spam.Async = true
+	cfg.Spam.Timeout = 5 * time.Second
+	cfg.Spam.Workers = 2
+	cfg.Spam.Bayes.Threshold = 0.9
+	cfg.Spam.Bayes.TrainingLimit = 1000
+	cfg.Toxicity.Timeout = 5 * time.Second
+	cfg.Toxicity.Workers = 2
+	cfg.Toxicity.QueueSize = 100
+	cfg.Toxicity.HoldThreshold = 0.9
+	cfg.Translation.Provider = TranslationLibreTranslate
+	cfg.Translation.Timeout = 10 * time.Second
+	cfg.Translation.CacheSize = 10000
+	cfg.Translation.CacheTTL = 24 * time.Hour
+	cfg.Digest.Interval = time.Hour
+	cfg.Digest.MaxComments = 50
+	cfg.SearchAlerts.Interval = 15 * time.Minute
+	cfg.SearchAlerts.MaxPosts = 20
+	cfg.Search.Index = "posts"
+	cfg.Search.Timeout = 5 * time.Second
+	cfg.Search.MaxResults = 1000
+	cfg.Search.QueueSize = 1000
+	cfg.Search.BatchSize = 100
+	cfg.Push.Timeout = 5 * time.Second
+	cfg.Push.Workers = 2
+	cfg.Push.QueueSize = 100
+	cfg.Recording.File = "mutations.jsonl"
+	cfg.Embed.Width = 600
+	cfg.Embed.Height = 400
+	cfg.Anonymous.CookieName = "anon_id"
+	cfg.Anonymous.CookieMaxAge = 365 * 24 * time.Hour
+	cfg.Runtime.GCPauseSampleInterval = 100 * time.Millisecond
+	cfg.PublicAPI.Queries = []string{"posts", "post"}
+	cfg.PublicAPI.CacheMinAge = time.Minute
+	cfg.PublicAPI.CacheMaxAge = 10 * time.Minute
+	cfg.PublicAPI.Capacity = 2000
+	cfg.PublicAPI.Period = time.Minute
+	cfg.Ranking.MaxCandidates = 500
+	cfg.Ranking.MLScoreTimeout = 300 * time.Millisecond
+	cfg.SLO.Enabled = true
+	cfg.SLO.Classes = map[string]SLOClass{
+		"query":    {Availability: 0.999, Latency: 0.99, LatencyThreshold: 300 * time.Millisecond},
+		"mutation": {Availability: 0.999, Latency: 0.99, LatencyThreshold: time.Second},
+	}
+	return &cfg
+}
+
+// Parse разбирает YAML поверх значений по умолчанию: отсутствующие ключи сохраняют значения Default.
+// Неизвестные ключи считаются ошибкой, чтобы опечатки не игнорировались молча
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return cfg, nil
+}
+
+// Load читает конфигурацию из файла; путь "-" означает stdin
 func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return Parse(data)
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+// Resolve выбирает источник конфигурации: явный путь, затем переменная CONFIG,
+// затем DefaultPath, если файл существует, иначе встроенные значения.
+// Вторым значением возвращается описание источника для лога
+func Resolve(path string) (*Config, string, error) {
+	if path != "" {
+		cfg, err := Load(path)
+		if path == "-" {
+			return cfg, "stdin", err
+		}
+		return cfg, path, err
+	}
+	if env := os.Getenv(EnvVar); env != "" {
+		cfg, err := Parse([]byte(env))
+		return cfg, "$" + EnvVar, err
+	}
+	cfg, err := Load(DefaultPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), "встроенных значений", nil
+	}
+	return cfg, DefaultPath, err
+}
+
+// SecretFields возвращает поля, которые могут содержать ссылки на секреты, по их путям в YAML
+func (c *Config) SecretFields() map[string]*string {
+	return map[string]*string{
+		"postgres.dsn":                 &c.Postgres.DSN,
+		"auth.jwtSecret":               &c.Auth.JWTSecret,
+		"errorReporting.sentryDSN":     &c.ErrorReporting.SentryDSN,
+		"spam.akismet.apiKey":          &c.Spam.Akismet.APIKey,
+		"toxicity.apiKey":              &c.Toxicity.APIKey,
+		"translation.apiKey":           &c.Translation.APIKey,
+		"email.password":               &c.Email.Password,
+		"push.webPush.vapidPrivateKey": &c.Push.WebPush.VAPIDPrivateKey,
+		"anonymous.secret":             &c.Anonymous.Secret,
+		"profiling.token":              &c.Profiling.Token,
+		"search.password":              &c.Search.Password,
+		"cluster.secret":               &c.Cluster.Secret,
 	}
+}
 
-	return &cfg, nil
+// Redacted возвращает YAML конфигурации со скрытыми паролями и ключами для вывода в лог
+func (c *Config) Redacted() (string, error) {
+	redacted := *c
+	if c.Postgres.DSN != "" {
+		if u, err := url.Parse(c.Postgres.DSN); err == nil && u.User != nil {
+			redacted.Postgres.DSN = u.Redacted()
+		} else if err != nil {
+			redacted.Postgres.DSN = "xxxxx"
+		}
+	}
+	if c.Auth.JWTSecret != "" {
+		redacted.Auth.JWTSecret = "xxxxx"
+	}
+	if c.ErrorReporting.SentryDSN != "" {
+		redacted.ErrorReporting.SentryDSN = "xxxxx"
+	}
+	if c.Spam.Akismet.APIKey != "" {
+		redacted.Spam.Akismet.APIKey = "xxxxx"
+	}
+	if c.Toxicity.APIKey != "" {
+		redacted.Toxicity.APIKey = "xxxxx"
+	}
+	if c.Translation.APIKey != "" {
+		redacted.Translation.APIKey = "xxxxx"
+	}
+	if c.Email.Password != "" {
+		redacted.Email.Password = "xxxxx"
+	}
+	if c.Push.WebPush.VAPIDPrivateKey != "" {
+		redacted.Push.WebPush.VAPIDPrivateKey = "xxxxx"
+	}
+	if c.Anonymous.Secret != "" {
+		redacted.Anonymous.Secret = "xxxxx"
+	}
+	if c.Profiling.Token != "" {
+		redacted.Profiling.Token = "xxxxx"
+	}
+	if c.Search.Password != "" {
+		redacted.Search.Password = "xxxxx"
+	}
+	if c.Cluster.Secret != "" {
+		redacted.Cluster.Secret = "xxxxx"
+	}
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %v", err)
+	}
+	return string(data), nil
 }
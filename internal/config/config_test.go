@@ -0,0 +1,636 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_KeepsDefaultsForMissingKeys(t *testing.T) {
+	cfg, err := Parse([]byte("server:\n  port: \"9090\"\ncache:\n  postTTL: 1s\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "9090", cfg.Server.Port)
+	assert.Equal(t, time.Second, cfg.Cache.PostTTL)
+	assert.Equal(t, 1000, cfg.Cache.PostSize, "Незаданный ключ должен сохранить значение по умолчанию")
+	assert.Equal(t, 10, cfg.Postgres.MaxRetries)
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	t.Run("embedded defaults", func(t *testing.T) {
+		t.Setenv(EnvVar, "")
+		cfg, source, err := Resolve("")
+		require.NoError(t, err)
+		assert.Equal(t, "встроенных значений", source)
+		assert.Equal(t, Default(), cfg)
+	})
+
+	t.Run("env var", func(t *testing.T) {
+		t.Setenv(EnvVar, "server:\n  port: \"7070\"\n")
+		cfg, source, err := Resolve("")
+		require.NoError(t, err)
+		assert.Equal(t, "$"+EnvVar, source)
+		assert.Equal(t, "7070", cfg.Server.Port)
+	})
+
+	t.Run("default file before embedded defaults", func(t *testing.T) {
+		t.Setenv(EnvVar, "")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, DefaultPath), []byte("server:\n  port: \"6060\"\n"), 0o644))
+		defer os.Remove(filepath.Join(dir, DefaultPath))
+		cfg, source, err := Resolve("")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultPath, source)
+		assert.Equal(t, "6060", cfg.Server.Port)
+	})
+
+	t.Run("explicit path wins over env var", func(t *testing.T) {
+		path := filepath.Join(dir, "custom.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("server:\n  port: \"5050\"\n"), 0o644))
+		t.Setenv(EnvVar, "server:\n  port: \"7070\"\n")
+		cfg, _, err := Resolve(path)
+		require.NoError(t, err)
+		assert.Equal(t, "5050", cfg.Server.Port)
+	})
+
+	t.Run("missing explicit path", func(t *testing.T) {
+		_, _, err := Resolve(filepath.Join(dir, "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func TestRedacted_HidesSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Postgres.DSN = "postgres://user:secret@db:5432/posts?sslmode=disable"
+	cfg.ErrorReporting.SentryDSN = "https://key@sentry.example/1"
+	cfg.Spam.Akismet.APIKey = "akismet-key"
+	cfg.Translation.APIKey = "translation-key"
+	cfg.Toxicity.APIKey = "toxicity-key"
+	cfg.Email.Password = "smtp-password"
+	cfg.Push.WebPush.VAPIDPrivateKey = "vapid-private"
+
+	out, err := cfg.Redacted()
+	require.NoError(t, err)
+	assert.NotContains(t, out, "smtp-password")
+	assert.NotContains(t, out, ":secret@")
+	assert.NotContains(t, out, "key@")
+	assert.NotContains(t, out, "akismet-key")
+	assert.NotContains(t, out, "translation-key")
+	assert.NotContains(t, out, "toxicity-key")
+	assert.NotContains(t, out, "vapid-private")
+	assert.Contains(t, out, "postgres://user:xxxxx@db:5432/posts")
+	assert.Equal(t, "postgres://user:secret@db:5432/posts?sslmode=disable", cfg.Postgres.DSN, "Исходная конфигурация не должна меняться")
+}
+
+func TestParse_RejectsUnknownFields(t *testing.T) {
+	_, err := Parse([]byte("server:\n  prot: \"8080\"\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "prot")
+}
+
+func TestParse_EmptyInputGivesDefaults(t *testing.T) {
+	cfg, err := Parse(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("defaults are valid for memory", func(t *testing.T) {
+		assert.NoError(t, Default().Validate())
+	})
+
+	t.Run("repository config is valid for both storages", func(t *testing.T) {
+		cfg, err := Load("../../config.yaml")
+		require.NoError(t, err)
+		assert.NoError(t, cfg.Validate())
+		cfg.Storage = StoragePostgres
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("drain period must fit shutdown timeout", func(t *testing.T) {
+		cfg := Default()
+		cfg.Server.DrainPeriod = cfg.Server.ShutdownTimeout
+		assert.ErrorContains(t, cfg.Validate(), "server.drainPeriod")
+
+		cfg.Server.DrainPeriod = cfg.Server.ShutdownTimeout / 2
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("postgres requires dsn", func(t *testing.T) {
+		cfg := Default()
+		cfg.Storage = StoragePostgres
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "postgres.dsn")
+	})
+
+	t.Run("postgres pool needs connections", func(t *testing.T) {
+		cfg := Default()
+		cfg.Postgres.MaxConns = 0
+		assert.ErrorContains(t, cfg.Validate(), "postgres.maxConns")
+	})
+
+	t.Run("id format must be known", func(t *testing.T) {
+		cfg := Default()
+		cfg.IDs.Format = IDFormatSnowflake
+		cfg.IDs.Node = 7
+		assert.NoError(t, cfg.Validate())
+
+		cfg.IDs.Node = 1024
+		assert.ErrorContains(t, cfg.Validate(), "ids.node")
+
+		cfg.IDs.Node = 0
+		cfg.IDs.Format = "uuidv1"
+		assert.ErrorContains(t, cfg.Validate(), "ids.format")
+	})
+
+	t.Run("vault references require vault address", func(t *testing.T) {
+		cfg := Default()
+		cfg.Auth.JWTSecret = "vault:kv/data/system#jwt_secret"
+		cfg.Email.Password = "env:SMTP_PASSWORD"
+		assert.ErrorContains(t, cfg.Validate(), "secrets.vault.addr")
+
+		cfg.Secrets.Vault.Addr = "https://vault.example.com:8200"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("encryption requires key file", func(t *testing.T) {
+		cfg := Default()
+		cfg.Encryption.Enabled = true
+		assert.ErrorContains(t, cfg.Validate(), "encryption.keyFile")
+
+		cfg.Encryption.KeyFile = "keys.yaml"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("production requires jwt secret", func(t *testing.T) {
+		cfg := Default()
+		cfg.Environment = EnvProduction
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "auth.jwtSecret")
+
+		cfg.Auth.JWTSecret = DevJWTSecret
+		assert.Error(t, cfg.Validate(), "Ключ для разработки недопустим в production")
+
+		cfg.Auth.JWTSecret = "prod-secret"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("faults are disabled in production", func(t *testing.T) {
+		cfg := Default()
+		cfg.Faults.Enabled = true
+		cfg.Faults.Storage.ErrorRate = 1.5
+		cfg.Faults.HTTP.Latency = -time.Second
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "faults.storage.errorRate")
+		assert.Contains(t, err.Error(), "faults.http.latency")
+
+		cfg.Faults.Storage.ErrorRate = 0.1
+		cfg.Faults.HTTP.Latency = time.Second
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Environment = EnvProduction
+		cfg.Auth.JWTSecret = "prod-secret"
+		assert.ErrorContains(t, cfg.Validate(), "faults.enabled")
+	})
+
+	t.Run("akismet requires key and site", func(t *testing.T) {
+		cfg := Default()
+		cfg.Spam.Enabled = true
+		cfg.Spam.Provider = SpamAkismet
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "spam.akismet.apiKey")
+		assert.Contains(t, err.Error(), "spam.akismet.site")
+
+		cfg.Spam.Akismet.APIKey = "key"
+		cfg.Spam.Akismet.Site = "https://example.com"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("storm requires positive limits", func(t *testing.T) {
+		cfg := Default()
+		cfg.Storm.Enabled = true
+		assert.NoError(t, cfg.Validate())
+		cfg.Storm.BatchWindow = 0
+		cfg.Storm.DigestMaxComments = 500
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "storm.batchWindow")
+		assert.Contains(t, err.Error(), "storm.digestMaxComments")
+	})
+
+	t.Run("cluster requires node, peers and secret", func(t *testing.T) {
+		cfg := Default()
+		cfg.Cluster.Enabled = true
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cluster.nodeID")
+		assert.Contains(t, err.Error(), "cluster.peers")
+		assert.Contains(t, err.Error(), "cluster.secret")
+
+		cfg.Cluster.NodeID = "a"
+		cfg.Cluster.Secret = "secret"
+		cfg.Cluster.Peers = []cluster.Peer{{ID: "b", URL: "http://b:8080"}}
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Cluster.Peers = append(cfg.Cluster.Peers, cluster.Peer{ID: "a", URL: "b:8080"})
+		cfg.Cluster.Mode = "gossip"
+		err = cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cluster.peers[1].id")
+		assert.Contains(t, err.Error(), "cluster.peers[1].url")
+		assert.Contains(t, err.Error(), "cluster.mode")
+	})
+
+	t.Run("write-behind requires journal", func(t *testing.T) {
+		cfg := Default()
+		cfg.WriteBehind.Enabled = true
+		cfg.Comments.DedupeWindow = 0
+		assert.NoError(t, cfg.Validate())
+		cfg.WriteBehind.JournalPath = ""
+		cfg.WriteBehind.MaxBatch = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "writeBehind.journalPath")
+		assert.Contains(t, err.Error(), "writeBehind.maxBatch")
+	})
+
+	t.Run("write-behind excludes comment dedupe", func(t *testing.T) {
+		cfg := Default()
+		cfg.WriteBehind.Enabled = true
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "comments.dedupeWindow")
+	})
+
+	t.Run("partial reserve must fit budget", func(t *testing.T) {
+		cfg := Default()
+		cfg.Partial.Enabled = true
+		assert.NoError(t, cfg.Validate())
+		cfg.Partial.Reserve = cfg.Partial.Budget
+		assert.ErrorContains(t, cfg.Validate(), "partial.reserve")
+	})
+
+	t.Run("toxicity requires endpoint or key and valid threshold", func(t *testing.T) {
+		cfg := Default()
+		cfg.Toxicity.Enabled = true
+		cfg.Toxicity.HoldThreshold = 1.5
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "toxicity.apiKey")
+		assert.Contains(t, err.Error(), "toxicity.holdThreshold")
+
+		cfg.Toxicity.APIKey = "key"
+		cfg.Toxicity.HoldThreshold = 0.8
+		assert.NoError(t, cfg.Validate())
+		cfg.Toxicity.APIKey = ""
+		cfg.Toxicity.URL = "scorer:8080"
+		assert.ErrorContains(t, cfg.Validate(), "toxicity.url")
+		cfg.Toxicity.URL = "http://scorer:8080/analyze"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("translation requires known provider", func(t *testing.T) {
+		cfg := Default()
+		cfg.Translation.Enabled = true
+		cfg.Translation.Provider = "deepl"
+		cfg.Translation.CacheSize = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "translation.provider")
+		assert.Contains(t, err.Error(), "translation.cacheSize")
+
+		cfg.Translation.Provider = TranslationLibreTranslate
+		cfg.Translation.CacheSize = 100
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("allowlist source", func(t *testing.T) {
+		cfg := Default()
+		cfg.Allowlist.Enabled = true
+		cfg.Allowlist.Source = "redis"
+		assert.ErrorContains(t, cfg.Validate(), "allowlist.source")
+
+		cfg.Allowlist.Source = AllowlistSourceDir
+		cfg.Allowlist.Dir = ""
+		assert.ErrorContains(t, cfg.Validate(), "allowlist.dir")
+
+		cfg.Allowlist.Source = AllowlistSourceStorage
+		assert.NoError(t, cfg.Validate(), "Каталог не нужен при загрузке из хранилища")
+	})
+
+	t.Run("search engine requires URL and positive limits", func(t *testing.T) {
+		cfg := Default()
+		cfg.Search.Engine = "solr"
+		assert.ErrorContains(t, cfg.Validate(), "search.engine")
+
+		cfg.Search.Engine = SearchOpenSearch
+		cfg.Search.QueueSize = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "search.url")
+		assert.Contains(t, err.Error(), "search.queueSize")
+
+		cfg.Search.URL = "https://search.example.com:9200"
+		cfg.Search.QueueSize = 100
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("purge requires positive batch settings", func(t *testing.T) {
+		cfg := Default()
+		cfg.Purge.BatchSize = 0
+		cfg.Purge.BatchInterval = -time.Second
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "purge.batchSize")
+		assert.Contains(t, err.Error(), "purge.batchInterval")
+	})
+
+	t.Run("reconnect backoff bounds must be ordered", func(t *testing.T) {
+		cfg := Default()
+		cfg.Subscriptions.ReconnectMaxBackoff = 500 * time.Millisecond
+		assert.ErrorContains(t, cfg.Validate(), "subscriptions.reconnectMaxBackoff")
+		cfg.Subscriptions.ReconnectMaxBackoff = time.Minute
+		cfg.Subscriptions.PingInterval = -time.Second
+		assert.ErrorContains(t, cfg.Validate(), "subscriptions.pingInterval")
+	})
+
+	t.Run("tenant header must be a header name", func(t *testing.T) {
+		cfg := Default()
+		cfg.Tenants.Header = ""
+		assert.ErrorContains(t, cfg.Validate(), "tenants.header")
+		cfg.Tenants.Header = "X Tenant"
+		assert.ErrorContains(t, cfg.Validate(), "tenants.header")
+	})
+
+	t.Run("tenant quotas must not be negative", func(t *testing.T) {
+		cfg := Default()
+		cfg.Tenants.Quota.Bytes = -1
+		cfg.Tenants.QuotaOverrides = map[string]TenantQuota{"forum": {Posts: -1}}
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "tenants.quota:")
+		assert.Contains(t, err.Error(), "tenants.quotaOverrides.forum")
+	})
+
+	t.Run("content limits", func(t *testing.T) {
+		cfg := Default()
+		cfg.Content.Unit = "graphemes"
+		cfg.Content.Limits.Title = -1
+		cfg.Content.LimitOverrides = map[string]ContentLimits{"forum": {Comment: -1}}
+		cfg.Content.ExcerptLength = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "content.unit")
+		assert.Contains(t, err.Error(), "content.limits:")
+		assert.Contains(t, err.Error(), "content.limitOverrides.forum")
+		assert.Contains(t, err.Error(), "content.excerptLength")
+	})
+
+	t.Run("summarizer requires url", func(t *testing.T) {
+		cfg := Default()
+		cfg.Content.Summarizer.Enabled = true
+		assert.ErrorContains(t, cfg.Validate(), "content.summarizer.url")
+		cfg.Content.Summarizer.URL = "http://summarizer:8080/summarize"
+		assert.NoError(t, cfg.Validate())
+		cfg.Content.Summarizer.Workers = 0
+		assert.ErrorContains(t, cfg.Validate(), "content.summarizer.workers")
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		cfg := Default()
+		cfg.Content.Duplicates.Enforcement = "reject"
+		assert.ErrorContains(t, cfg.Validate(), "content.duplicates.enforcement")
+		cfg.Content.Duplicates.Enforcement = DuplicatesBlock
+		assert.NoError(t, cfg.Validate())
+		cfg.Content.Duplicates.Threshold = 0.2
+		assert.ErrorContains(t, cfg.Validate(), "content.duplicates.threshold")
+	})
+
+	t.Run("comment archive requires batch settings", func(t *testing.T) {
+		cfg := Default()
+		cfg.Postgres.CommentArchiveBatchSize = 0
+		assert.NoError(t, cfg.Validate(), "Без commentArchiveMonths архив отключён")
+
+		cfg.Postgres.CommentArchiveMonths = 6
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "postgres.commentArchiveBatchSize")
+
+		cfg.Postgres.CommentArchiveMonths = -1
+		assert.ErrorContains(t, cfg.Validate(), "postgres.commentArchiveMonths")
+	})
+
+	t.Run("comment partitions ahead must not be negative", func(t *testing.T) {
+		cfg := Default()
+		cfg.Postgres.PartitionComments = true
+		assert.NoError(t, cfg.Validate())
+		cfg.Postgres.CommentPartitionsAhead = -1
+		assert.ErrorContains(t, cfg.Validate(), "postgres.commentPartitionsAhead")
+	})
+
+	t.Run("smtp requires sender address", func(t *testing.T) {
+		cfg := Default()
+		cfg.Email.SMTPAddr = "smtp.example.com"
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "email.smtpAddr")
+		assert.Contains(t, err.Error(), "email.from")
+
+		cfg.Email.SMTPAddr = "smtp.example.com:587"
+		cfg.Email.From = "noreply@example.com"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("push providers require their identifiers", func(t *testing.T) {
+		cfg := Default()
+		cfg.Push.Enabled = true
+		cfg.Push.APNs.KeyFile = "apns.p8"
+		cfg.Push.WebPush.VAPIDPrivateKey = "key"
+		err := cfg.Validate()
+		require.Error(t, err)
+		for _, field := range []string{"push.apns.keyID", "push.apns.teamID", "push.apns.topic", "push.webPush.subject"} {
+			assert.Contains(t, err.Error(), field)
+		}
+
+		cfg.Push.APNs.KeyID = "KEY"
+		cfg.Push.APNs.TeamID = "TEAM"
+		cfg.Push.APNs.Topic = "com.example.app"
+		cfg.Push.WebPush.Subject = "mailto:push@example.com"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("recording requires file", func(t *testing.T) {
+		cfg := Default()
+		cfg.Recording.Enabled = true
+		cfg.Recording.File = ""
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "recording.file")
+
+		cfg.Recording.File = "mutations.jsonl"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("embed requires absolute urls", func(t *testing.T) {
+		cfg := Default()
+		cfg.Embed.Enabled = true
+		cfg.Embed.BaseURL = "/comments"
+		cfg.Embed.Height = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "embed.baseURL")
+		assert.Contains(t, err.Error(), "embed.loginURL")
+		assert.Contains(t, err.Error(), "width and height")
+
+		cfg.Embed.BaseURL = "https://comments.example.com"
+		cfg.Embed.LoginURL = "https://example.com/login"
+		cfg.Embed.Height = 400
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("anonymous requires secret", func(t *testing.T) {
+		cfg := Default()
+		cfg.Anonymous.Enabled = true
+		cfg.Anonymous.CookieName = "anon id"
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "anonymous.secret")
+		assert.Contains(t, err.Error(), "anonymous.cookieName")
+
+		cfg.Anonymous.Secret = "0123456789abcdef"
+		cfg.Anonymous.CookieName = "anon_id"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("runtime gc settings", func(t *testing.T) {
+		cfg := Default()
+		cfg.Runtime.GCPercent = -1
+		cfg.Runtime.BallastBytes = -1
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "runtime.memoryLimit")
+		assert.Contains(t, err.Error(), "ballastBytes")
+
+		cfg.Runtime.MemoryLimit = 1 << 30
+		cfg.Runtime.BallastBytes = 256 << 20
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("profiling requires token", func(t *testing.T) {
+		cfg := Default()
+		cfg.Profiling.Enabled = true
+		cfg.Profiling.BlockProfileRate = -1
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "profiling.token")
+		assert.Contains(t, err.Error(), "blockProfileRate")
+
+		cfg.Profiling.Token = "0123456789abcdef"
+		cfg.Profiling.BlockProfileRate = 0
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("slo objectives", func(t *testing.T) {
+		cfg := Default()
+		cfg.SLO.Classes = map[string]SLOClass{
+			"subscription": {Availability: 0.99, Latency: 0.99, LatencyThreshold: time.Second},
+			"query":        {Availability: 1, Latency: 0.99},
+		}
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "slo.classes.subscription")
+		assert.Contains(t, err.Error(), "slo.classes.query: availability")
+		assert.Contains(t, err.Error(), "slo.classes.query.latencyThreshold")
+
+		cfg.SLO.Enabled = false
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("cost budget requires capacity and period", func(t *testing.T) {
+		cfg := Default()
+		cfg.CostBudget.Enabled = true
+		cfg.CostBudget.Capacity = 0
+		cfg.CostBudget.Period = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "costBudget.capacity")
+		assert.Contains(t, err.Error(), "costBudget.period")
+
+		assert.NoError(t, Default().Validate())
+	})
+
+	t.Run("public api requires queries and budget", func(t *testing.T) {
+		cfg := Default()
+		cfg.PublicAPI.Enabled = true
+		assert.NoError(t, cfg.Validate())
+
+		cfg.PublicAPI.Queries = nil
+		cfg.PublicAPI.CacheMinAge = time.Hour
+		cfg.PublicAPI.Capacity = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		for _, field := range []string{"publicAPI.queries", "publicAPI.cacheMinAge", "publicAPI.capacity"} {
+			assert.Contains(t, err.Error(), field)
+		}
+	})
+
+	t.Run("ranking tenants require known strategies", func(t *testing.T) {
+		cfg := Default()
+		cfg.Ranking.Tenants = map[string]string{"forum": "controversial"}
+		assert.NoError(t, cfg.Validate())
+
+		cfg.Ranking.Tenants = map[string]string{"forum": "random", "news": "ml-score"}
+		cfg.Ranking.MaxCandidates = 0
+		err := cfg.Validate()
+		require.Error(t, err)
+		for _, field := range []string{"ranking.tenants.forum", "ranking.tenants.news", "ranking.maxCandidates"} {
+			assert.Contains(t, err.Error(), field)
+		}
+
+		cfg.Ranking.Tenants = map[string]string{"news": "ml-score"}
+		cfg.Ranking.MaxCandidates = 500
+		cfg.Ranking.MLScoreURL = "http://ranker:8080/score"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("reports all problems at once", func(t *testing.T) {
+		cfg := Default()
+		cfg.Server.Port = "http"
+		cfg.Postgres.MaxRetries = -1
+		cfg.Cache.PostSize = 0
+		cfg.LinkPreview.Workers = 0
+		cfg.Storage = "sqlite"
+		err := cfg.Validate()
+		require.Error(t, err)
+		for _, field := range []string{"server.port", "postgres.maxRetries", "cache.postSize", "linkPreview.workers", "storage"} {
+			assert.Contains(t, err.Error(), field)
+		}
+	})
+}
+
+func TestParse_StorageFromFile(t *testing.T) {
+	cfg, err := Parse([]byte("storage: postgres\npostgres:\n  dsn: \"postgres://db/posts\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, StoragePostgres, cfg.Storage)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]byte("storage: postgres\n"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, cfg.Validate(), "postgres.dsn")
+
+	_, err = Parse([]byte("storage: mongo\nmongo:\n  uri: \"mongodb://db\"\n"))
+	assert.ErrorContains(t, err, "mongo", "Секция неподдерживаемого хранилища должна отклоняться")
+}
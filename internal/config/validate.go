@@ -0,0 +1,644 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/faults"
+	"github.com/ButyrinIA/system/internal/ranking"
+	"github.com/ButyrinIA/system/internal/secrets"
+)
+
+// headerNamePattern - допустимое имя HTTP-заголовка
+var headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// cookieNamePattern - допустимое имя cookie
+var cookieNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// minAnonymousSecret - наименьшая длина ключа HMAC анонимных идентификаторов
+const minAnonymousSecret = 16
+
+// minProfilingToken - наименьшая длина токена доступа к профилям pprof
+const minProfilingToken = 16
+
+// maxRankingCandidates - наибольшее число комментариев, переупорядочиваемых стратегиями ранжирования в памяти
+const maxRankingCandidates = 5000
+
+// maxDigestComments - наибольший размер сводки подписок: буфер каждого подписчика вмещает сводку целиком
+const maxDigestComments = 100
+
+// Validate проверяет согласованность конфигурации и возвращает все найденные проблемы
+// одной ошибкой, по строке на каждую
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+	nonNegative := func(field string, d time.Duration) {
+		if d < 0 {
+			add(field, "must not be negative, got %v", d)
+		}
+	}
+
+	switch c.Environment {
+	case "development", "staging", EnvProduction:
+	default:
+		add("environment", "must be one of development, staging, production, got %q", c.Environment)
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 0 || port > 65535 {
+		add("server.port", "must be a number between 0 and 65535, got %q", c.Server.Port)
+	}
+
+	if c.Server.CompressionMinSize < 0 {
+		add("server.compressionMinSize", "must not be negative, got %d", c.Server.CompressionMinSize)
+	}
+	nonNegative("server.cacheMaxAge", c.Server.CacheMaxAge)
+	if c.Server.MaxBatchSize < 0 {
+		add("server.maxBatchSize", "must not be negative, got %d", c.Server.MaxBatchSize)
+	}
+	nonNegative("server.shutdownTimeout", c.Server.ShutdownTimeout)
+	nonNegative("server.drainPeriod", c.Server.DrainPeriod)
+	if c.Server.DrainPeriod > 0 && c.Server.DrainPeriod >= c.Server.ShutdownTimeout {
+		add("server.drainPeriod", "must be less than server.shutdownTimeout %v, got %v", c.Server.ShutdownTimeout, c.Server.DrainPeriod)
+	}
+
+	nonNegative("subscriptions.keepAliveInterval", c.Subscriptions.KeepAliveInterval)
+	nonNegative("subscriptions.pingInterval", c.Subscriptions.PingInterval)
+	nonNegative("subscriptions.initTimeout", c.Subscriptions.InitTimeout)
+	nonNegative("subscriptions.reconnectMinBackoff", c.Subscriptions.ReconnectMinBackoff)
+	if c.Subscriptions.ReconnectMaxBackoff < c.Subscriptions.ReconnectMinBackoff {
+		add("subscriptions.reconnectMaxBackoff", "must not be less than reconnectMinBackoff %v, got %v", c.Subscriptions.ReconnectMinBackoff, c.Subscriptions.ReconnectMaxBackoff)
+	}
+
+	if !headerNamePattern.MatchString(c.Tenants.Header) {
+		add("tenants.header", "must be a valid HTTP header name, got %q", c.Tenants.Header)
+	}
+	tenantQuota := func(field string, q TenantQuota) {
+		if q.Posts < 0 || q.Comments < 0 || q.Bytes < 0 {
+			add(field, "limits must not be negative")
+		}
+	}
+	tenantQuota("tenants.quota", c.Tenants.Quota)
+	for tenant, q := range c.Tenants.QuotaOverrides {
+		tenantQuota("tenants.quotaOverrides."+tenant, q)
+	}
+
+	if c.Environment == EnvProduction && (c.Auth.JWTSecret == "" || c.Auth.JWTSecret == DevJWTSecret) {
+		add("auth.jwtSecret", "is required in production and must differ from the development key")
+	}
+
+	nonNegative("secrets.refreshInterval", c.Secrets.RefreshInterval)
+	for field, value := range c.SecretFields() {
+		if ref, ok := secrets.ParseRef(*value); ok && ref.Scheme == secrets.SchemeVault && c.Secrets.Vault.Addr == "" {
+			add("secrets.vault.addr", "is required to resolve %s from vault", field)
+		}
+	}
+	if c.Secrets.Vault.Addr != "" {
+		if _, err := url.Parse(c.Secrets.Vault.Addr); err != nil {
+			add("secrets.vault.addr", "is not a valid URL: %v", err)
+		}
+	}
+
+	switch c.Storage {
+	case StorageMemory:
+	case StoragePostgres:
+		if c.Postgres.DSN == "" {
+			add("postgres.dsn", "is required when storage is postgres; add a postgres section with dsn")
+		} else if _, err := url.Parse(c.Postgres.DSN); err != nil {
+			add("postgres.dsn", "is not a valid URL: %v", err)
+		}
+	default:
+		add("storage", "must be %s or %s, got %q", StorageMemory, StoragePostgres, c.Storage)
+	}
+	switch c.IDs.Format {
+	case IDFormatUUID, IDFormatUUIDv7, IDFormatULID, IDFormatSnowflake:
+	default:
+		add("ids.format", "must be one of %s, %s, %s, %s, got %q", IDFormatUUID, IDFormatUUIDv7, IDFormatULID, IDFormatSnowflake, c.IDs.Format)
+	}
+	if c.IDs.Node < 0 || c.IDs.Node > 1023 {
+		add("ids.node", "must be between 0 and 1023, got %d", c.IDs.Node)
+	}
+
+	if c.Postgres.MaxRetries < 0 {
+		add("postgres.maxRetries", "must not be negative, got %d", c.Postgres.MaxRetries)
+	}
+	if c.Postgres.MaxConns < 1 {
+		add("postgres.maxConns", "must be positive, got %d", c.Postgres.MaxConns)
+	}
+	nonNegative("postgres.connectTimeout", c.Postgres.ConnectTimeout)
+	nonNegative("postgres.retryBackoff", c.Postgres.RetryBackoff)
+	nonNegative("postgres.maxBackoff", c.Postgres.MaxBackoff)
+	nonNegative("postgres.statementTimeout", c.Postgres.StatementTimeout)
+	nonNegative("postgres.queryTimeout", c.Postgres.QueryTimeout)
+	nonNegative("postgres.slowQueryThreshold", c.Postgres.SlowQueryThreshold)
+	nonNegative("postgres.countReconcileInterval", c.Postgres.CountReconcileInterval)
+	if c.Postgres.CommentPartitionsAhead < 0 {
+		add("postgres.commentPartitionsAhead", "must not be negative, got %d", c.Postgres.CommentPartitionsAhead)
+	}
+	nonNegative("postgres.commentPartitionInterval", c.Postgres.CommentPartitionInterval)
+	if c.Postgres.CommentArchiveMonths < 0 {
+		add("postgres.commentArchiveMonths", "must not be negative, got %d", c.Postgres.CommentArchiveMonths)
+	}
+	if c.Postgres.CommentArchiveMonths > 0 {
+		if c.Postgres.CommentArchiveInterval <= 0 {
+			add("postgres.commentArchiveInterval", "must be positive when commentArchiveMonths is set, got %v", c.Postgres.CommentArchiveInterval)
+		}
+		if c.Postgres.CommentArchiveBatchSize <= 0 {
+			add("postgres.commentArchiveBatchSize", "must be positive when commentArchiveMonths is set, got %d", c.Postgres.CommentArchiveBatchSize)
+		}
+	}
+
+	nonNegative("comments.dedupeWindow", c.Comments.DedupeWindow)
+
+	if c.Content.Unit != ContentUnitRunes && c.Content.Unit != ContentUnitBytes {
+		add("content.unit", "must be %q or %q, got %q", ContentUnitRunes, ContentUnitBytes, c.Content.Unit)
+	}
+	contentLimits := func(field string, l ContentLimits) {
+		if l.Title < 0 || l.Post < 0 || l.Comment < 0 {
+			add(field, "limits must not be negative")
+		}
+	}
+	contentLimits("content.limits", c.Content.Limits)
+	for tenant, l := range c.Content.LimitOverrides {
+		contentLimits("content.limitOverrides."+tenant, l)
+	}
+	if c.Content.ExcerptLength <= 0 {
+		add("content.excerptLength", "must be positive, got %d", c.Content.ExcerptLength)
+	}
+	if c.Content.Summarizer.Enabled {
+		if u, err := url.Parse(c.Content.Summarizer.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("content.summarizer.url", "must be an absolute URL when the summarizer is enabled, got %q", c.Content.Summarizer.URL)
+		}
+		if c.Content.Summarizer.Timeout <= 0 {
+			add("content.summarizer.timeout", "must be positive when the summarizer is enabled, got %v", c.Content.Summarizer.Timeout)
+		}
+		if c.Content.Summarizer.Workers <= 0 {
+			add("content.summarizer.workers", "must be positive when the summarizer is enabled, got %d", c.Content.Summarizer.Workers)
+		}
+		if c.Content.Summarizer.QueueSize <= 0 {
+			add("content.summarizer.queueSize", "must be positive when the summarizer is enabled, got %d", c.Content.Summarizer.QueueSize)
+		}
+	}
+	if c.Content.Duplicates.Enforcement != DuplicatesWarn && c.Content.Duplicates.Enforcement != DuplicatesBlock {
+		add("content.duplicates.enforcement", "must be %q or %q, got %q", DuplicatesWarn, DuplicatesBlock, c.Content.Duplicates.Enforcement)
+	}
+	// Ниже порога pg_trgm по умолчанию индекс триграмм не находит кандидатов
+	if c.Content.Duplicates.Threshold < 0.3 || c.Content.Duplicates.Threshold > 1 {
+		add("content.duplicates.threshold", "must be between 0.3 and 1, got %v", c.Content.Duplicates.Threshold)
+	}
+	if c.Content.Duplicates.MaxLimit <= 0 {
+		add("content.duplicates.maxLimit", "must be positive, got %d", c.Content.Duplicates.MaxLimit)
+	}
+
+	if c.Encryption.Enabled {
+		if c.Encryption.KeyFile == "" {
+			add("encryption.keyFile", "is required when encryption is enabled")
+		}
+		if c.Encryption.ReencryptBatchSize <= 0 {
+			add("encryption.reencryptBatchSize", "must be positive when encryption is enabled, got %d", c.Encryption.ReencryptBatchSize)
+		}
+	}
+
+	if c.Purge.BatchSize <= 0 {
+		add("purge.batchSize", "must be positive, got %d", c.Purge.BatchSize)
+	}
+	if c.Purge.BatchInterval <= 0 {
+		add("purge.batchInterval", "must be positive, got %v", c.Purge.BatchInterval)
+	}
+	if c.Purge.Retention <= 0 {
+		add("purge.retention", "must be positive, got %v", c.Purge.Retention)
+	}
+
+	nonNegative("cache.postTTL", c.Cache.PostTTL)
+	if c.Cache.PostTTL > 0 && c.Cache.PostSize <= 0 {
+		add("cache.postSize", "must be positive when cache.postTTL is set, got %d", c.Cache.PostSize)
+	}
+
+	for name, flag := range c.Flags.Definitions {
+		if flag.Percentage < 0 || flag.Percentage > 100 {
+			add("flags.definitions."+name+".percentage", "must be between 0 and 100, got %d", flag.Percentage)
+		}
+	}
+	if c.Flags.RemoteURL != "" {
+		if _, err := url.Parse(c.Flags.RemoteURL); err != nil {
+			add("flags.remoteURL", "is not a valid URL: %v", err)
+		}
+		if c.Flags.RefreshInterval <= 0 {
+			add("flags.refreshInterval", "must be positive when flags.remoteURL is set, got %v", c.Flags.RefreshInterval)
+		}
+	}
+
+	if c.Faults.Enabled && c.Environment == EnvProduction {
+		add("faults.enabled", "must be false in production")
+	}
+	faultOptions := func(field string, opts faults.Options) {
+		if opts.ErrorRate < 0 || opts.ErrorRate > 1 {
+			add(field+".errorRate", "must be between 0 and 1, got %v", opts.ErrorRate)
+		}
+		if opts.LatencyRate < 0 || opts.LatencyRate > 1 {
+			add(field+".latencyRate", "must be between 0 and 1, got %v", opts.LatencyRate)
+		}
+		nonNegative(field+".latency", opts.Latency)
+	}
+	faultOptions("faults.storage", c.Faults.Storage)
+	faultOptions("faults.http", c.Faults.HTTP)
+
+	if c.Partial.Enabled {
+		if c.Partial.Budget <= 0 {
+			add("partial.budget", "must be positive when partial responses are enabled, got %v", c.Partial.Budget)
+		}
+		if c.Partial.Reserve <= 0 || c.Partial.Reserve >= c.Partial.Budget {
+			add("partial.reserve", "must be positive and less than partial.budget, got %v", c.Partial.Reserve)
+		}
+	}
+
+	if c.CostBudget.Enabled {
+		if c.CostBudget.Capacity <= 0 {
+			add("costBudget.capacity", "must be positive when costBudget is enabled, got %d", c.CostBudget.Capacity)
+		}
+		if c.CostBudget.Period <= 0 {
+			add("costBudget.period", "must be positive when costBudget is enabled, got %v", c.CostBudget.Period)
+		}
+	}
+
+	if c.Quotas.Enabled {
+		if _, ok := c.Quotas.Roles[c.Quotas.DefaultRole]; !ok {
+			add("quotas.defaultRole", "must name a role listed in quotas.roles, got %q", c.Quotas.DefaultRole)
+		}
+		for role, limits := range c.Quotas.Roles {
+			if limits.PostsPerDay < 0 || limits.CommentsPerMinute < 0 {
+				add("quotas.roles."+role, "limits must not be negative")
+			}
+		}
+	}
+
+	switch c.Allowlist.Source {
+	case AllowlistSourceDir:
+		if c.Allowlist.Enabled && c.Allowlist.Dir == "" {
+			add("allowlist.dir", "is required when allowlist is enabled")
+		}
+	case AllowlistSourceStorage:
+	default:
+		add("allowlist.source", "must be %s or %s, got %q", AllowlistSourceDir, AllowlistSourceStorage, c.Allowlist.Source)
+	}
+
+	if c.ErrorReporting.SentryDSN != "" {
+		if _, err := url.Parse(c.ErrorReporting.SentryDSN); err != nil {
+			add("errorReporting.sentryDSN", "is not a valid URL: %v", err)
+		}
+	}
+
+	if c.LinkPreview.Enabled {
+		if c.LinkPreview.Workers <= 0 {
+			add("linkPreview.workers", "must be positive when link previews are enabled, got %d", c.LinkPreview.Workers)
+		}
+		if c.LinkPreview.Timeout <= 0 {
+			add("linkPreview.timeout", "must be positive when link previews are enabled, got %v", c.LinkPreview.Timeout)
+		}
+	}
+
+	if c.Spam.Enabled {
+		switch c.Spam.Provider {
+		case SpamBayes:
+			if c.Spam.Bayes.Threshold <= 0 || c.Spam.Bayes.Threshold >= 1 {
+				add("spam.bayes.threshold", "must be between 0 and 1, got %v", c.Spam.Bayes.Threshold)
+			}
+			if c.Spam.Bayes.TrainingLimit < 0 {
+				add("spam.bayes.trainingLimit", "must not be negative, got %d", c.Spam.Bayes.TrainingLimit)
+			}
+		case SpamAkismet:
+			if c.Spam.Akismet.APIKey == "" {
+				add("spam.akismet.apiKey", "is required when spam.provider is akismet")
+			}
+			if c.Spam.Akismet.Site == "" {
+				add("spam.akismet.site", "is required when spam.provider is akismet")
+			}
+			if c.Spam.Akismet.Endpoint != "" {
+				if _, err := url.Parse(c.Spam.Akismet.Endpoint); err != nil {
+					add("spam.akismet.endpoint", "is not a valid URL: %v", err)
+				}
+			}
+		default:
+			add("spam.provider", "must be %s or %s, got %q", SpamBayes, SpamAkismet, c.Spam.Provider)
+		}
+		if c.Spam.Timeout <= 0 {
+			add("spam.timeout", "must be positive when spam checks are enabled, got %v", c.Spam.Timeout)
+		}
+		if c.Spam.Async && c.Spam.Workers <= 0 {
+			add("spam.workers", "must be positive when spam.async is set, got %d", c.Spam.Workers)
+		}
+	}
+
+	if c.Storm.Enabled {
+		if c.Storm.WriteThreshold <= 0 {
+			add("storm.writeThreshold", "must be positive when storm shaping is enabled, got %d", c.Storm.WriteThreshold)
+		}
+		if c.Storm.BatchWindow <= 0 {
+			add("storm.batchWindow", "must be positive when storm shaping is enabled, got %v", c.Storm.BatchWindow)
+		}
+		if c.Storm.MaxBatch <= 0 {
+			add("storm.maxBatch", "must be positive when storm shaping is enabled, got %d", c.Storm.MaxBatch)
+		}
+		if c.Storm.EventThreshold <= 0 {
+			add("storm.eventThreshold", "must be positive when storm shaping is enabled, got %d", c.Storm.EventThreshold)
+		}
+		if c.Storm.DigestInterval <= 0 {
+			add("storm.digestInterval", "must be positive when storm shaping is enabled, got %v", c.Storm.DigestInterval)
+		}
+		if c.Storm.DigestMaxComments <= 0 || c.Storm.DigestMaxComments > maxDigestComments {
+			add("storm.digestMaxComments", "must be in [1, %d], got %d", maxDigestComments, c.Storm.DigestMaxComments)
+		}
+	}
+
+	if c.Cluster.Enabled {
+		if c.Cluster.NodeID == "" {
+			add("cluster.nodeID", "is required when cluster is enabled")
+		}
+		if len(c.Cluster.Peers) == 0 {
+			add("cluster.peers", "must list at least one peer when cluster is enabled")
+		}
+		seen := map[string]bool{c.Cluster.NodeID: true}
+		for i, peer := range c.Cluster.Peers {
+			field := fmt.Sprintf("cluster.peers[%d]", i)
+			if peer.ID == "" || seen[peer.ID] {
+				add(field+".id", "must be non-empty and unique among nodes, got %q", peer.ID)
+			}
+			seen[peer.ID] = true
+			if u, err := url.Parse(peer.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add(field+".url", "must be an absolute http(s) URL, got %q", peer.URL)
+			}
+		}
+		// Внутренний эндпоинт публикует события подписчикам, поэтому без секрета он недоступен
+		if c.Cluster.Secret == "" {
+			add("cluster.secret", "is required when cluster is enabled")
+		}
+		switch c.Cluster.Mode {
+		case ClusterModeBroadcast, ClusterModeSticky:
+		default:
+			add("cluster.mode", "must be %q or %q, got %q", ClusterModeBroadcast, ClusterModeSticky, c.Cluster.Mode)
+		}
+		if c.Cluster.Timeout <= 0 {
+			add("cluster.timeout", "must be positive when cluster is enabled, got %v", c.Cluster.Timeout)
+		}
+		if c.Cluster.QueueSize <= 0 {
+			add("cluster.queueSize", "must be positive when cluster is enabled, got %d", c.Cluster.QueueSize)
+		}
+	}
+
+	if c.WriteBehind.Enabled {
+		if c.WriteBehind.QueueSize <= 0 {
+			add("writeBehind.queueSize", "must be positive when write-behind is enabled, got %d", c.WriteBehind.QueueSize)
+		}
+		if c.WriteBehind.FlushInterval <= 0 {
+			add("writeBehind.flushInterval", "must be positive when write-behind is enabled, got %v", c.WriteBehind.FlushInterval)
+		}
+		if c.WriteBehind.MaxBatch <= 0 {
+			add("writeBehind.maxBatch", "must be positive when write-behind is enabled, got %d", c.WriteBehind.MaxBatch)
+		}
+		// Без журнала подтверждённые комментарии терялись бы при падении процесса
+		if c.WriteBehind.JournalPath == "" {
+			add("writeBehind.journalPath", "is required when write-behind is enabled")
+		}
+		// Повтор находится только при записи пачки, когда вызывающий уже получил ответ
+		if c.Comments.DedupeWindow > 0 {
+			add("writeBehind.enabled", "cannot be combined with comments.dedupeWindow %v: duplicates are only detected after the comment is acknowledged", c.Comments.DedupeWindow)
+		}
+	}
+
+	if c.Toxicity.Enabled {
+		if c.Toxicity.URL != "" {
+			if u, err := url.Parse(c.Toxicity.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				add("toxicity.url", "must be an absolute URL, got %q", c.Toxicity.URL)
+			}
+		} else if c.Toxicity.APIKey == "" {
+			add("toxicity.apiKey", "is required when toxicity.url is not set")
+		}
+		if c.Toxicity.Timeout <= 0 {
+			add("toxicity.timeout", "must be positive when toxicity scoring is enabled, got %v", c.Toxicity.Timeout)
+		}
+		if c.Toxicity.Workers <= 0 {
+			add("toxicity.workers", "must be positive when toxicity scoring is enabled, got %d", c.Toxicity.Workers)
+		}
+		if c.Toxicity.QueueSize <= 0 {
+			add("toxicity.queueSize", "must be positive when toxicity scoring is enabled, got %d", c.Toxicity.QueueSize)
+		}
+		if c.Toxicity.HoldThreshold <= 0 || c.Toxicity.HoldThreshold > 1 {
+			add("toxicity.holdThreshold", "must be in (0, 1], got %v", c.Toxicity.HoldThreshold)
+		}
+	}
+
+	if c.Translation.Enabled {
+		if c.Translation.Provider != TranslationLibreTranslate {
+			add("translation.provider", "must be %s, got %q", TranslationLibreTranslate, c.Translation.Provider)
+		}
+		if c.Translation.Endpoint != "" {
+			if _, err := url.Parse(c.Translation.Endpoint); err != nil {
+				add("translation.endpoint", "is not a valid URL: %v", err)
+			}
+		}
+		if c.Translation.Timeout <= 0 {
+			add("translation.timeout", "must be positive when translation is enabled, got %v", c.Translation.Timeout)
+		}
+		if c.Translation.CacheSize <= 0 {
+			add("translation.cacheSize", "must be positive when translation is enabled, got %d", c.Translation.CacheSize)
+		}
+		if c.Translation.CacheTTL <= 0 {
+			add("translation.cacheTTL", "must be positive when translation is enabled, got %v", c.Translation.CacheTTL)
+		}
+	}
+
+	if c.Email.SMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Email.SMTPAddr); err != nil {
+			add("email.smtpAddr", "must be host:port, got %q", c.Email.SMTPAddr)
+		}
+		if c.Email.From == "" {
+			add("email.from", "is required when email.smtpAddr is set")
+		}
+	}
+
+	if c.Digest.Enabled {
+		if c.Digest.Interval <= 0 {
+			add("digest.interval", "must be positive when digests are enabled, got %v", c.Digest.Interval)
+		}
+		if c.Digest.MaxComments <= 0 {
+			add("digest.maxComments", "must be positive when digests are enabled, got %d", c.Digest.MaxComments)
+		}
+	}
+
+	if c.SearchAlerts.Enabled {
+		if c.SearchAlerts.Interval <= 0 {
+			add("searchAlerts.interval", "must be positive when search alerts are enabled, got %v", c.SearchAlerts.Interval)
+		}
+		if c.SearchAlerts.MaxPosts <= 0 {
+			add("searchAlerts.maxPosts", "must be positive when search alerts are enabled, got %d", c.SearchAlerts.MaxPosts)
+		}
+	}
+
+	switch c.Search.Engine {
+	case "", SearchMemory:
+	case SearchElasticsearch, SearchOpenSearch:
+		if c.Search.URL == "" {
+			add("search.url", "is required for %s", c.Search.Engine)
+		} else if u, err := url.Parse(c.Search.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("search.url", "must be an absolute URL, got %q", c.Search.URL)
+		}
+		if c.Search.Index == "" {
+			add("search.index", "is required for %s", c.Search.Engine)
+		}
+		if c.Search.Timeout <= 0 {
+			add("search.timeout", "must be positive, got %v", c.Search.Timeout)
+		}
+	default:
+		add("search.engine", "must be empty, %s, %s or %s, got %q", SearchMemory, SearchElasticsearch, SearchOpenSearch, c.Search.Engine)
+	}
+	if c.Search.Engine != "" {
+		if c.Search.MaxResults <= 0 {
+			add("search.maxResults", "must be positive when a search engine is set, got %d", c.Search.MaxResults)
+		}
+		if c.Search.QueueSize <= 0 {
+			add("search.queueSize", "must be positive when a search engine is set, got %d", c.Search.QueueSize)
+		}
+		if c.Search.BatchSize <= 0 {
+			add("search.batchSize", "must be positive when a search engine is set, got %d", c.Search.BatchSize)
+		}
+	}
+
+	if c.Push.Enabled {
+		if c.Push.Timeout <= 0 {
+			add("push.timeout", "must be positive when push is enabled, got %v", c.Push.Timeout)
+		}
+		if c.Push.Workers <= 0 {
+			add("push.workers", "must be positive when push is enabled, got %d", c.Push.Workers)
+		}
+		if c.Push.QueueSize <= 0 {
+			add("push.queueSize", "must be positive when push is enabled, got %d", c.Push.QueueSize)
+		}
+		if c.Push.APNs.KeyFile != "" {
+			if c.Push.APNs.KeyID == "" {
+				add("push.apns.keyID", "is required when push.apns.keyFile is set")
+			}
+			if c.Push.APNs.TeamID == "" {
+				add("push.apns.teamID", "is required when push.apns.keyFile is set")
+			}
+			if c.Push.APNs.Topic == "" {
+				add("push.apns.topic", "is required when push.apns.keyFile is set")
+			}
+		}
+		if c.Push.WebPush.VAPIDPrivateKey != "" && c.Push.WebPush.Subject == "" {
+			add("push.webPush.subject", "is required when push.webPush.vapidPrivateKey is set")
+		}
+	}
+
+	if c.Recording.Enabled && c.Recording.File == "" {
+		add("recording.file", "is required when recording is enabled")
+	}
+
+	if c.Embed.Enabled {
+		absolute := func(field, value string) {
+			if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+				add(field, "must be an absolute URL when embed is enabled, got %q", value)
+			}
+		}
+		absolute("embed.baseURL", c.Embed.BaseURL)
+		absolute("embed.loginURL", c.Embed.LoginURL)
+		if c.Embed.Width <= 0 || c.Embed.Height <= 0 {
+			add("embed", "width and height must be positive, got %dx%d", c.Embed.Width, c.Embed.Height)
+		}
+	}
+
+	if c.Anonymous.Enabled {
+		if len(c.Anonymous.Secret) < minAnonymousSecret {
+			add("anonymous.secret", "must be at least %d characters when anonymous commenting is enabled", minAnonymousSecret)
+		}
+		if !cookieNamePattern.MatchString(c.Anonymous.CookieName) {
+			add("anonymous.cookieName", "must be a valid cookie name, got %q", c.Anonymous.CookieName)
+		}
+		if c.Anonymous.CookieMaxAge <= 0 {
+			add("anonymous.cookieMaxAge", "must be positive, got %v", c.Anonymous.CookieMaxAge)
+		}
+	}
+
+	if c.PublicAPI.Enabled {
+		if len(c.PublicAPI.Queries) == 0 {
+			add("publicAPI.queries", "must list at least one query when publicAPI is enabled")
+		}
+		if c.PublicAPI.CacheMinAge < 0 || c.PublicAPI.CacheMinAge > c.PublicAPI.CacheMaxAge {
+			add("publicAPI.cacheMinAge", "must be between 0 and cacheMaxAge %v, got %v", c.PublicAPI.CacheMaxAge, c.PublicAPI.CacheMinAge)
+		}
+		if c.PublicAPI.Capacity <= 0 {
+			add("publicAPI.capacity", "must be positive when publicAPI is enabled, got %d", c.PublicAPI.Capacity)
+		}
+		if c.PublicAPI.Period <= 0 {
+			add("publicAPI.period", "must be positive when publicAPI is enabled, got %v", c.PublicAPI.Period)
+		}
+	}
+
+	if c.Ranking.MaxCandidates <= 0 || c.Ranking.MaxCandidates > maxRankingCandidates {
+		add("ranking.maxCandidates", "must be between 1 and %d, got %d", maxRankingCandidates, c.Ranking.MaxCandidates)
+	}
+	for tenant, strategy := range c.Ranking.Tenants {
+		switch strategy {
+		case ranking.Chronological, ranking.Best, ranking.Controversial:
+		case ranking.MLScore:
+			if c.Ranking.MLScoreURL == "" {
+				add("ranking.tenants."+tenant, "ml-score requires ranking.mlScoreURL")
+			}
+		default:
+			add("ranking.tenants."+tenant, "unknown strategy %q", strategy)
+		}
+	}
+	if c.Ranking.MLScoreURL != "" {
+		if _, err := url.Parse(c.Ranking.MLScoreURL); err != nil {
+			add("ranking.mlScoreURL", "is not a valid URL: %v", err)
+		}
+		if c.Ranking.MLScoreTimeout <= 0 {
+			add("ranking.mlScoreTimeout", "must be positive when ranking.mlScoreURL is set, got %v", c.Ranking.MLScoreTimeout)
+		}
+	}
+
+	if c.Runtime.GCPercent < -1 {
+		add("runtime.gcPercent", "must be -1 or greater, got %d", c.Runtime.GCPercent)
+	}
+	if c.Runtime.GCPercent == -1 && c.Runtime.MemoryLimit <= 0 {
+		add("runtime.memoryLimit", "must be set when garbage collection is disabled with gcPercent -1")
+	}
+	if c.Runtime.MemoryLimit < 0 || c.Runtime.BallastBytes < 0 {
+		add("runtime", "memoryLimit and ballastBytes must not be negative")
+	}
+	nonNegative("runtime.gcPauseSampleInterval", c.Runtime.GCPauseSampleInterval)
+
+	if c.Profiling.Enabled {
+		if len(c.Profiling.Token) < minProfilingToken {
+			add("profiling.token", "must be at least %d characters when profiling is enabled", minProfilingToken)
+		}
+		if c.Profiling.MutexProfileFraction < 0 || c.Profiling.BlockProfileRate < 0 {
+			add("profiling", "mutexProfileFraction and blockProfileRate must not be negative")
+		}
+	}
+
+	if c.SLO.Enabled {
+		for class, objective := range c.SLO.Classes {
+			field := "slo.classes." + class
+			if class != "query" && class != "mutation" {
+				add(field, "unknown operation class, expected query or mutation")
+			}
+			if objective.Availability <= 0 || objective.Availability >= 1 || objective.Latency <= 0 || objective.Latency >= 1 {
+				add(field, "availability and latency must be between 0 and 1 exclusive, got %v and %v", objective.Availability, objective.Latency)
+			}
+			if objective.LatencyThreshold <= 0 {
+				add(field+".latencyThreshold", "must be positive, got %v", objective.LatencyThreshold)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config:\n%w", errors.Join(errs...))
+}
@@ -0,0 +1,304 @@
+// Package costbudget ограничивает суммарную стоимость GraphQL-операций пользователя сверх сложности
+// отдельного запроса. Стоимость операции - её сложность по схеме; у каждого пользователя (анонимного - по IP)
+// есть бюджет, который тратится на выполненные операции и равномерно восстанавливается за Period.
+// Операция, на которую бюджета не хватает, отклоняется с кодом BUDGET_EXHAUSTED
+package costbudget
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ExtensionKey - ключ extensions ответа с Usage операции
+const ExtensionKey = "costBudget"
+
+// Заголовки ответа с Usage последней операции HTTP-запроса
+const (
+	HeaderLimit     = "X-Cost-Budget-Limit"
+	HeaderRemaining = "X-Cost-Budget-Remaining"
+	HeaderCost      = "X-Cost-Budget-Cost"
+)
+
+// Limit - размер бюджета и время его полного восстановления
+type Limit struct {
+	Capacity int
+	Period   time.Duration
+}
+
+// refill возвращает скорость восстановления бюджета в единицах стоимости в секунду
+func (l Limit) refill() float64 {
+	return float64(l.Capacity) / l.Period.Seconds()
+}
+
+// Store хранит бюджеты. Take восстанавливает бюджет key на момент now по limit и списывает cost,
+// если его хватает; возвращает остаток и признак списания. Реализация должна быть атомарной для одного key
+type Store interface {
+	Take(ctx context.Context, key string, cost int, limit Limit, now time.Time) (float64, bool, error)
+}
+
+// MemoryStore хранит бюджеты в памяти процесса; при нескольких экземплярах сервера у каждого свой бюджет.
+// Бюджет, не тронутый дольше Period, полностью восстановлен, поэтому такие записи удаляются
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets *expirable.LRU[string, bucket]
+}
+
+// bucket - остаток бюджета на момент updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore создаёт хранилище на size пользователей с записями не дольше ttl
+func NewMemoryStore(size int, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{buckets: expirable.NewLRU[string, bucket](size, nil, ttl)}
+}
+
+// Take реализует Store
+func (s *MemoryStore) Take(ctx context.Context, key string, cost int, limit Limit, now time.Time) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets.Get(key)
+	if !ok {
+		b = bucket{tokens: float64(limit.Capacity), updated: now}
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Capacity), b.tokens+elapsed.Seconds()*limit.refill())
+		b.updated = now
+	}
+	taken := b.tokens >= float64(cost)
+	if taken {
+		b.tokens -= float64(cost)
+	}
+	s.buckets.Add(key, b)
+	return b.tokens, taken, nil
+}
+
+// Options задаёт параметры бюджета; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	Limit Limit
+	// Store - хранилище бюджетов, по умолчанию MemoryStore
+	Store Store
+	// Clock - источник времени восстановления бюджета, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Limit.Capacity <= 0 {
+		o.Limit.Capacity = 10000
+	}
+	if o.Limit.Period <= 0 {
+		o.Limit.Period = time.Minute
+	}
+	if o.Store == nil {
+		o.Store = NewMemoryStore(100000, o.Limit.Period)
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// Usage - учёт операции, который возвращается в extensions ответа
+type Usage struct {
+	// Cost - стоимость операции
+	Cost int `json:"cost"`
+	// Remaining - остаток бюджета после операции
+	Remaining int `json:"remaining"`
+	// Limit - размер бюджета
+	Limit int `json:"limit"`
+	// ResetAt - время в RFC3339, к которому бюджет восстановится полностью, если не тратить его дальше
+	ResetAt string `json:"resetAt"`
+}
+
+// Budget - расширение GraphQL-сервера, которое учитывает стоимость операций. Подключается после
+// аутентификации: пользователь берётся из userID в контексте операции
+type Budget struct {
+	opts Options
+	es   graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.OperationInterceptor
+	graphql.HandlerExtension
+} = &Budget{}
+
+// New создаёт учёт стоимости операций
+func New(opts Options) *Budget {
+	opts = opts.withDefaults()
+	log.Printf("Создание Cost Budget: бюджет=%d за %v", opts.Limit.Capacity, opts.Limit.Period)
+	return &Budget{opts: opts}
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (b *Budget) ExtensionName() string {
+	return "CostBudget"
+}
+
+// Validate реализует graphql.HandlerExtension и запоминает схему для расчёта сложности
+func (b *Budget) Validate(schema graphql.ExecutableSchema) error {
+	b.es = schema
+	return nil
+}
+
+// InterceptOperation списывает стоимость операции с бюджета пользователя и отклоняет операцию, если его не хватает.
+// Подписка оплачивается один раз при подключении. Если хранилище бюджетов недоступно, операция выполняется
+func (b *Budget) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	key := subject(ctx)
+	oc := graphql.GetOperationContext(ctx)
+	if key == "" || oc.Operation == nil {
+		return next(ctx)
+	}
+	cost := complexity.Calculate(ctx, b.es, oc.Operation, oc.Variables)
+	now := b.opts.Clock.Now()
+	remaining, taken, err := b.opts.Store.Take(ctx, key, cost, b.opts.Limit, now)
+	if err != nil {
+		log.Printf("Не удалось учесть стоимость операции %s, она выполняется без учёта: %v", oc.OperationName, err)
+		return next(ctx)
+	}
+	usage := Usage{Cost: cost, Remaining: int(remaining), Limit: b.opts.Limit.Capacity, ResetAt: b.resetAt(remaining, now)}
+	if report, ok := ctx.Value(reportKey{}).(*report); ok {
+		report.set(usage)
+	}
+	if !taken {
+		log.Printf("Бюджет %s исчерпан: стоимость операции %s %d, остаток %d", key, oc.OperationName, cost, usage.Remaining)
+		metrics.CostBudgetRejections.Inc()
+		return graphql.OneShot(&graphql.Response{
+			Errors:     gqlerror.List{b.exhausted(usage, remaining)},
+			Extensions: map[string]any{ExtensionKey: usage},
+		})
+	}
+	handler := next(ctx)
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		if resp != nil {
+			if resp.Extensions == nil {
+				resp.Extensions = map[string]any{}
+			}
+			resp.Extensions[ExtensionKey] = usage
+		}
+		return resp
+	}
+}
+
+// resetAt возвращает время с точностью до секунды, к которому бюджет с остатком remaining восстановится полностью
+func (b *Budget) resetAt(remaining float64, now time.Time) string {
+	seconds := math.Ceil((float64(b.opts.Limit.Capacity) - remaining) / b.opts.Limit.refill())
+	return now.Add(time.Duration(seconds) * time.Second).UTC().Format(time.RFC3339)
+}
+
+// exhausted возвращает ошибку BUDGET_EXHAUSTED с числом секунд, через которое бюджета хватит на операцию.
+// Операция дороже всего бюджета не выполнится никогда, и retryAfter для неё не задаётся
+func (b *Budget) exhausted(usage Usage, remaining float64) *gqlerror.Error {
+	err := gqlerror.Errorf("cost budget exhausted: operation costs %d, remaining %d of %d", usage.Cost, usage.Remaining, usage.Limit)
+	errcode.Set(err, gqlerrors.CodeBudgetExhausted)
+	if usage.Cost <= usage.Limit {
+		err.Extensions["retryAfter"] = int(math.Ceil((float64(usage.Cost) - remaining) / b.opts.Limit.refill()))
+	}
+	return err
+}
+
+// subject возвращает ключ бюджета: пользователь, а для анонимных запросов - IP клиента
+func subject(ctx context.Context) string {
+	if userID, _ := ctx.Value("userID").(string); userID != "" {
+		return "user:" + userID
+	}
+	if ip, _ := ctx.Value("clientIP").(string); ip != "" {
+		return "ip:" + ip
+	}
+	return ""
+}
+
+// reportKey - ключ контекста HTTP-запроса с report
+type reportKey struct{}
+
+// report передаёт Usage из GraphQL-операции в заголовки HTTP-ответа
+type report struct {
+	mu    sync.Mutex
+	usage *Usage
+}
+
+func (r *report) set(usage Usage) {
+	r.mu.Lock()
+	r.usage = &usage
+	r.mu.Unlock()
+}
+
+func (r *report) get() *Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage
+}
+
+// Headers добавляет к HTTP-ответу заголовки X-Cost-Budget-* с учётом операции. Заголовки выставляются
+// при отправке статуса, поэтому обёртки, которые копят ответ целиком, должны стоять внутри.
+// WebSocket-соединения не затрагиваются
+func Headers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rep := &report{}
+		ctx := context.WithValue(r.Context(), reportKey{}, rep)
+		next.ServeHTTP(&headerWriter{ResponseWriter: w, report: rep}, r.WithContext(ctx))
+	})
+}
+
+// headerWriter выставляет заголовки бюджета перед отправкой статуса
+type headerWriter struct {
+	http.ResponseWriter
+	report *report
+	wrote  bool
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	if !w.wrote {
+		w.wrote = true
+		if usage := w.report.get(); usage != nil {
+			h := w.Header()
+			h.Set(HeaderLimit, strconv.Itoa(usage.Limit))
+			h.Set(HeaderRemaining, strconv.Itoa(usage.Remaining))
+			h.Set(HeaderCost, strconv.Itoa(usage.Cost))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerWriter) Write(p []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush реализует http.Flusher
+func (w *headerWriter) Flush() {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного ResponseWriter
+func (w *headerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
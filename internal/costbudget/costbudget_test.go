@@ -0,0 +1,90 @@
+package costbudget
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var schema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+	type Query { posts: [Post!]! }
+	type Post { id: ID! title: String! }
+`})
+
+// run выполняет операцию через Budget от имени userID и возвращает ответ
+func run(t *testing.T, b *Budget, userID, query string) *graphql.Response {
+	doc, errs := gqlparser.LoadQuery(schema, query)
+	require.Nil(t, errs)
+	ctx := context.WithValue(context.Background(), "userID", userID)
+	ctx = graphql.WithOperationContext(ctx, &graphql.OperationContext{Operation: doc.Operations[0]})
+	handler := b.InterceptOperation(ctx, func(ctx context.Context) graphql.ResponseHandler {
+		return graphql.OneShot(&graphql.Response{Data: []byte(`{}`)})
+	})
+	return handler(ctx)
+}
+
+func TestBudget(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := New(Options{Limit: Limit{Capacity: 10, Period: 10 * time.Second}, Clock: clk})
+	require.NoError(t, b.Validate(&graphql.ExecutableSchemaMock{
+		SchemaFunc: func() *ast.Schema { return schema },
+		ComplexityFunc: func(ctx context.Context, typeName, fieldName string, childComplexity int, args map[string]any) (int, bool) {
+			return 0, false
+		},
+	}))
+	query := `{ posts { id title } }` // стоимость 3
+
+	for _, remaining := range []int{7, 4, 1} {
+		resp := run(t, b, "1", query)
+		require.Empty(t, resp.Errors)
+		resetAt := clk.Now().Add(time.Duration(10-remaining) * time.Second).Format(time.RFC3339)
+		assert.Equal(t, Usage{Cost: 3, Remaining: remaining, Limit: 10, ResetAt: resetAt}, resp.Extensions[ExtensionKey])
+	}
+
+	resp := run(t, b, "1", query)
+	require.Len(t, resp.Errors, 1)
+	assert.Nil(t, resp.Data)
+	assert.Equal(t, gqlerrors.CodeBudgetExhausted, resp.Errors[0].Extensions["code"])
+	assert.Equal(t, 2, resp.Errors[0].Extensions["retryAfter"], "Недостающие 2 единицы восстанавливаются за 2 секунды")
+	assert.Equal(t, Usage{Cost: 3, Remaining: 1, Limit: 10, ResetAt: "2026-01-01T00:00:09Z"}, resp.Extensions[ExtensionKey])
+
+	assert.Empty(t, run(t, b, "2", query).Errors, "У каждого пользователя свой бюджет")
+
+	clk.Advance(2 * time.Second)
+	resp = run(t, b, "1", query)
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, 0, resp.Extensions[ExtensionKey].(Usage).Remaining)
+
+	clk.Advance(time.Hour)
+	resp = run(t, b, "1", query)
+	assert.Equal(t, 7, resp.Extensions[ExtensionKey].(Usage).Remaining, "Бюджет восстанавливается не больше чем до Capacity")
+}
+
+func TestHeaders(t *testing.T) {
+	handler := Headers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("graphql") != "" {
+			r.Context().Value(reportKey{}).(*report).set(Usage{Cost: 5, Remaining: 95, Limit: 100})
+		}
+		w.Write([]byte(`{}`))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/query?graphql=1", nil))
+	assert.Equal(t, "100", rr.Header().Get(HeaderLimit))
+	assert.Equal(t, "95", rr.Header().Get(HeaderRemaining))
+	assert.Equal(t, "5", rr.Header().Get(HeaderCost))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/query", nil))
+	assert.Empty(t, rr.Header().Get(HeaderLimit), "Без учёта операции заголовки не добавляются")
+}
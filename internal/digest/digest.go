@@ -0,0 +1,214 @@
+// Package digest периодически отправляет подписчикам постов письма со сводкой новых комментариев.
+// Частота сводки и адрес берутся из настроек пользователя; сводка без новых комментариев не отправляется
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/email"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// periods - минимальный интервал между сводками для каждой частоты; NEVER здесь отсутствует
+var periods = map[string]time.Duration{
+	models.DigestDaily:  24 * time.Hour,
+	models.DigestWeekly: 7 * 24 * time.Hour,
+}
+
+// maxExcerptLength - длина фрагмента комментария в письме в символах
+const maxExcerptLength = 200
+
+// Options задаёт параметры рассылки; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Interval - как часто проверяется, кому пора отправить сводку
+	Interval time.Duration
+	// MaxComments - наибольшее число комментариев в одной сводке; остальные в неё не попадают
+	MaxComments int
+	// Clock - источник времени для расписания сводок, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Interval <= 0 {
+		o.Interval = time.Hour
+	}
+	if o.MaxComments <= 0 {
+		o.MaxComments = 50
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// Service рассылает сводки по расписанию
+type Service struct {
+	store  storage.Storage
+	sender email.Sender
+	opts   Options
+	clock  clock.Clock
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// New создаёт сервис рассылки; сводки начинают отправляться после Start
+func New(store storage.Storage, sender email.Sender, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Digest Service: interval=%v, maxComments=%d", opts.Interval, opts.MaxComments)
+	return &Service{
+		store:  store,
+		sender: sender,
+		opts:   opts,
+		clock:  opts.Clock,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start запускает фоновую рассылку, которая проверяет подписчиков каждые Interval до вызова Close
+func (s *Service) Start() {
+	go s.run()
+}
+
+// Close останавливает фоновую рассылку и дожидается завершения текущего прохода
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		log.Println("Digest Service остановлен")
+	})
+}
+
+func (s *Service) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if _, err := s.SendDue(context.Background()); err != nil {
+				log.Printf("Фоновая рассылка сводок не удалась: %v", err)
+			}
+		}
+	}
+}
+
+// SendDue отправляет сводки подписчикам, у которых с прошлой сводки прошёл период их частоты,
+// и возвращает число отправленных писем. Сводка, которую не удалось отправить, повторяется при следующем проходе
+func (s *Service) SendDue(ctx context.Context) (int, error) {
+	subscribers, err := s.store.ListDigestSubscribers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list digest subscribers: %v", err)
+	}
+	now := s.clock.Now()
+	sent := 0
+	for _, subscriber := range subscribers {
+		ok, err := s.sendTo(ctx, subscriber, now)
+		if err != nil {
+			log.Printf("Ошибка отправки сводки пользователю %s: %v", subscriber.UserID, err)
+			metrics.DigestEmails.WithLabelValues("error").Inc()
+			continue
+		}
+		if ok {
+			sent++
+			metrics.DigestEmails.WithLabelValues("sent").Inc()
+		}
+	}
+	log.Printf("Проход рассылки сводок завершён: подписчиков %d, отправлено %d", len(subscribers), sent)
+	return sent, nil
+}
+
+// sendTo отправляет сводку одному подписчику, если она ему положена, и сообщает, было ли отправлено письмо.
+// Проверенный период отмечается и без письма, чтобы следующая сводка не включала уже просмотренное время
+func (s *Service) sendTo(ctx context.Context, subscriber models.DigestSubscriber, now time.Time) (bool, error) {
+	prefs, err := s.store.GetPreferences(ctx, subscriber.UserID)
+	if err != nil {
+		return false, err
+	}
+	period, ok := periods[prefs.DigestFrequency]
+	if !ok || prefs.Email == "" {
+		return false, nil
+	}
+	if !subscriber.LastSentAt.IsZero() && now.Sub(subscriber.LastSentAt) < period {
+		return false, nil
+	}
+	comments, err := s.store.GetDigestComments(ctx, subscriber.UserID, subscriber.LastSentAt, now, s.opts.MaxComments)
+	if err != nil {
+		return false, err
+	}
+	if len(comments) > 0 {
+		msg := email.Message{
+			To:      prefs.Email,
+			Subject: subject(prefs.Locale, len(comments)),
+			Body:    s.body(ctx, prefs.Locale, comments),
+		}
+		if err := s.sender.Send(ctx, msg); err != nil {
+			return false, err
+		}
+		log.Printf("Сводка из %d комментариев отправлена пользователю %s", len(comments), subscriber.UserID)
+	}
+	return len(comments) > 0, s.store.MarkDigestSent(ctx, subscriber.UserID, now)
+}
+
+// subject возвращает тему письма на языке пользователя
+func subject(locale string, count int) string {
+	if locale == "en" {
+		return fmt.Sprintf("New comments on posts you follow: %d", count)
+	}
+	return fmt.Sprintf("Новые комментарии к постам, на которые вы подписаны: %d", count)
+}
+
+// body группирует комментарии по постам; комментарии приходят из хранилища уже упорядоченными по постам
+func (s *Service) body(ctx context.Context, locale string, comments []models.Comment) string {
+	var b strings.Builder
+	postID := ""
+	for _, c := range comments {
+		if c.PostID != postID {
+			postID = c.PostID
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(s.postTitle(ctx, postID) + "\n")
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", models.DisplayAuthorID(c.PostID, c.AuthorID), excerpt(c.Content))
+	}
+	if len(comments) == s.opts.MaxComments {
+		if locale == "en" {
+			b.WriteString("\nOnly the first comments are shown; open the posts to read the rest.\n")
+		} else {
+			b.WriteString("\nПоказаны только первые комментарии; остальные можно прочитать в постах.\n")
+		}
+	}
+	return b.String()
+}
+
+// postTitle возвращает заголовок поста или, если пост не удалось загрузить, его ID
+func (s *Service) postTitle(ctx context.Context, postID string) string {
+	post, err := s.store.GetPost(ctx, postID)
+	if err != nil {
+		log.Printf("Ошибка получения поста %s для сводки: %v", postID, err)
+		return postID
+	}
+	return post.Title
+}
+
+// excerpt обрезает текст комментария до maxExcerptLength символов
+func excerpt(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(content) <= maxExcerptLength {
+		return content
+	}
+	return string([]rune(content)[:maxExcerptLength]) + "…"
+}
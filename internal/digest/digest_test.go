@@ -0,0 +1,86 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/email"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSender запоминает отправленные письма и может имитировать сбой
+type recordingSender struct {
+	sent []email.Message
+	err  error
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg email.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestSendDue(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	start := time.Now()
+	post := &models.Post{ID: "post1", Title: "Запуск ракеты", AuthorID: "author", AllowComments: true, CreatedAt: start}
+	require.NoError(t, store.CreatePost(ctx, post))
+	require.NoError(t, store.SubscribeToPost(ctx, "reader", post.ID, start))
+	require.NoError(t, store.SubscribeToPost(ctx, "silent", post.ID, start))
+	require.NoError(t, store.SavePreferences(ctx, &models.Preferences{UserID: "reader", Email: "reader@example.com", DigestFrequency: models.DigestDaily, Locale: "ru"}))
+	require.NoError(t, store.SavePreferences(ctx, &models.Preferences{UserID: "silent", Email: "silent@example.com", DigestFrequency: models.DigestNever}))
+	addComment := func(id string, at time.Time) {
+		t.Helper()
+		require.NoError(t, store.CreateComment(ctx, &models.Comment{ID: id, PostID: post.ID, AuthorID: "author", Content: "Комментарий " + id, CreatedAt: at}))
+	}
+	addComment("c1", start.Add(time.Minute))
+
+	sender := &recordingSender{}
+	fake := clock.NewFake(start.Add(time.Hour))
+	service := New(store, sender, Options{Clock: fake})
+
+	sent, err := service.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent, "Пользователь с частотой NEVER сводок не получает")
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "reader@example.com", sender.sent[0].To)
+	assert.Contains(t, sender.sent[0].Subject, "1")
+	assert.Contains(t, sender.sent[0].Body, "Запуск ракеты")
+	assert.Contains(t, sender.sent[0].Body, "Комментарий c1")
+
+	addComment("c2", fake.Now().Add(time.Minute))
+	fake.Advance(time.Hour)
+	sent, err = service.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent, "Ежедневная сводка не отправляется раньше суток")
+
+	fake.Advance(24 * time.Hour)
+	sender.err = errors.New("smtp unavailable")
+	sent, err = service.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	sender.err = nil
+	sent, err = service.SendDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent, "Неотправленная сводка повторяется при следующем проходе")
+	require.Len(t, sender.sent, 2)
+	assert.Contains(t, sender.sent[1].Body, "Комментарий c2")
+	assert.NotContains(t, sender.sent[1].Body, "Комментарий c1", "Комментарии прошлой сводки не повторяются")
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.Equal(t, "две строки", excerpt("две\n  строки"))
+	long := excerpt(strings.Repeat("я", maxExcerptLength+10))
+	assert.Equal(t, maxExcerptLength+1, len([]rune(long)))
+}
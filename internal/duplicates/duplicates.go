@@ -0,0 +1,106 @@
+// Package duplicates ищет посты с заголовком, похожим на заголовок нового поста, чтобы предупредить
+// автора о возможном повторе до публикации. Сходство считается по триграммам заголовков, как в pg_trgm.
+// В режиме EnforcementWarn клиент показывает похожие посты из запроса checkDuplicate и автор решает сам,
+// в режиме EnforcementBlock createPost отклоняет пост, у которого нашлись похожие
+package duplicates
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Enforcement - что происходит с постом, для которого нашлись похожие
+type Enforcement string
+
+const (
+	// EnforcementWarn - пост создаётся, похожие посты только показываются клиентом
+	EnforcementWarn Enforcement = "warn"
+	// EnforcementBlock - пост не создаётся
+	EnforcementBlock Enforcement = "block"
+)
+
+// ErrDuplicate возвращается Check, если пост не создаётся из-за похожих постов
+var ErrDuplicate = errors.New("a post with a similar title already exists")
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	Enforcement Enforcement
+	// Threshold - наименьшее сходство заголовков от 0 до 1, при котором пост считается похожим;
+	// значения ниже storage.RelatedSimilarityThreshold действуют как storage.RelatedSimilarityThreshold
+	Threshold float64
+	// MaxLimit - наибольшее число возвращаемых похожих постов; большие limit в запросах ограничиваются им
+	MaxLimit int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Enforcement == "" {
+		o.Enforcement = EnforcementWarn
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = 0.6
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 20
+	}
+	return o
+}
+
+// Service ищет похожие посты и решает, можно ли создать пост
+type Service struct {
+	store storage.Storage
+	opts  Options
+}
+
+// New создаёт сервис поиска похожих постов поверх хранилища
+func New(store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Duplicates Service: режим %s, порог сходства %.2f", opts.Enforcement, opts.Threshold)
+	return &Service{store: store, opts: opts}
+}
+
+// Blocks сообщает, отклоняет ли createPost посты, для которых нашлись похожие
+func (s *Service) Blocks() bool {
+	return s.opts.Enforcement == EnforcementBlock
+}
+
+// Find возвращает до limit видимых viewerID постов с заголовком, похожим на title, от самых похожих
+func (s *Service) Find(ctx context.Context, title, viewerID string, limit int) ([]storage.SimilarPost, error) {
+	if limit > s.opts.MaxLimit {
+		limit = s.opts.MaxLimit
+	}
+	similar, err := s.store.SimilarPosts(ctx, title, viewerID, s.opts.Threshold, limit)
+	if err != nil {
+		metrics.DuplicateChecks.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	if len(similar) == 0 {
+		metrics.DuplicateChecks.WithLabelValues("unique").Inc()
+	} else {
+		metrics.DuplicateChecks.WithLabelValues("similar").Inc()
+	}
+	return similar, nil
+}
+
+// Check проверяет заголовок нового поста перед созданием. В режиме EnforcementBlock при найденных
+// похожих постах возвращает их вместе с ErrDuplicate, в режиме EnforcementWarn ничего не ищет.
+// Ошибка хранилища не мешает созданию поста: она записывается в журнал, а Check возвращает nil
+func (s *Service) Check(ctx context.Context, title, viewerID string) ([]storage.SimilarPost, error) {
+	if !s.Blocks() {
+		return nil, nil
+	}
+	similar, err := s.Find(ctx, title, viewerID, s.opts.MaxLimit)
+	if err != nil {
+		log.Printf("Ошибка поиска постов, похожих на %q, пост создаётся без проверки: %v", title, err)
+		return nil, nil
+	}
+	if len(similar) == 0 {
+		return nil, nil
+	}
+	log.Printf("Пост %q отклонён: найдено похожих постов: %d", title, len(similar))
+	metrics.DuplicateChecks.WithLabelValues("blocked").Inc()
+	return similar, ErrDuplicate
+}
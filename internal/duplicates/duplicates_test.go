@@ -0,0 +1,69 @@
+package duplicates
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStorage не может найти похожие посты
+type failingStorage struct {
+	storage.Storage
+}
+
+func (failingStorage) SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]storage.SimilarPost, error) {
+	return nil, errors.New("unavailable")
+}
+
+func newStore(t *testing.T, titles ...string) storage.Storage {
+	store := memory.New()
+	for i, title := range titles {
+		post := &models.Post{ID: uuid.New().String(), Title: title, Content: "Содержимое", Format: models.FormatPlain, AuthorID: "author", CreatedAt: time.Now().Add(time.Duration(i) * time.Second)}
+		require.NoError(t, store.CreatePost(context.Background(), post))
+	}
+	return store
+}
+
+func TestFind(t *testing.T) {
+	store := newStore(t, "Запуск ракеты", "Запуск ракеты отложен", "Ракеты", "Пирог")
+	ctx := context.Background()
+
+	similar, err := New(store, Options{}).Find(ctx, "запуск  ракеты", "", 10)
+	require.NoError(t, err)
+	require.Len(t, similar, 2, "Порог по умолчанию отсекает слабо похожие заголовки")
+	assert.Equal(t, "Запуск ракеты", similar[0].Post.Title)
+	assert.Equal(t, "Запуск ракеты отложен", similar[1].Post.Title)
+
+	similar, err = New(store, Options{Threshold: 0.3, MaxLimit: 2}).Find(ctx, "Запуск ракеты", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, similar, 2, "limit ограничивается MaxLimit")
+}
+
+func TestCheck(t *testing.T) {
+	store := newStore(t, "Запуск ракеты")
+	ctx := context.Background()
+
+	similar, err := New(store, Options{}).Check(ctx, "Запуск ракеты", "user1")
+	assert.NoError(t, err, "В режиме warn пост не отклоняется")
+	assert.Empty(t, similar)
+
+	block := New(store, Options{Enforcement: EnforcementBlock})
+	assert.True(t, block.Blocks())
+	similar, err = block.Check(ctx, "Запуск ракеты", "user1")
+	assert.ErrorIs(t, err, ErrDuplicate)
+	require.Len(t, similar, 1)
+	_, err = block.Check(ctx, "Рецепт борща", "user1")
+	assert.NoError(t, err)
+
+	similar, err = New(failingStorage{store}, Options{Enforcement: EnforcementBlock}).Check(ctx, "Запуск ракеты", "user1")
+	assert.NoError(t, err, "Ошибка хранилища не мешает созданию поста")
+	assert.Empty(t, similar)
+}
@@ -0,0 +1,357 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/server"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/ButyrinIA/system/internal/storage/postgres"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// TestE2E запускает полный сервер поверх каждого хранилища и проверяет поведение по сети
+func TestE2E(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		runSuite(t, memory.New())
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		testcontainers.SkipIfProviderIsNotHealthy(t)
+		runSuite(t, startPostgres(t))
+	})
+}
+
+func startPostgres(t *testing.T) storage.Storage {
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "postgres:13",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "user",
+				"POSTGRES_PASSWORD": "password",
+				"POSTGRES_DB":       "posts",
+			},
+			WaitingFor: wait.ForListeningPort("5432/tcp"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err, "Не удалось запустить контейнер PostgreSQL")
+	t.Cleanup(func() { container.Terminate(ctx) })
+
+	host, err := container.Host(ctx)
+	require.NoError(t, err)
+	port, err := container.MappedPort(ctx, "5432")
+	require.NoError(t, err)
+
+	store, err := postgres.New("postgres://user:password@"+host+":"+port.Port()+"/posts?sslmode=disable", postgres.Options{MaxRetries: 5})
+	require.NoError(t, err, "Не удалось инициализировать PostgresStorage")
+	return store
+}
+
+func runSuite(t *testing.T, store storage.Storage) {
+	t.Cleanup(func() { store.Close() })
+	cfg := &config.Config{}
+	cfg.Server.Port = "0"
+	cfg.Server.Compression = true
+	cfg.Server.CacheMaxAge = time.Minute
+	ts := httptest.NewServer(server.New(cfg, store).Handler())
+	t.Cleanup(ts.Close)
+	c := &client{t: t, url: ts.URL}
+
+	token := c.token()
+
+	var postID string
+	t.Run("createPost and post", func(t *testing.T) {
+		resp := c.do(token, `mutation($title: String!) { createPost(title: $title, content: "**жирный**", allowComments: true, format: MARKDOWN) { id authorId format } }`,
+			map[string]any{"title": "E2E"})
+		require.Empty(t, resp.Errors)
+		post := resp.field("createPost")
+		postID = post["id"].(string)
+		assert.Equal(t, "user1", post["authorId"])
+		assert.Equal(t, "MARKDOWN", post["format"])
+
+		resp = c.do(token, `query($id: ID!) { post(id: $id) { title contentHTML comments(limit: 10) { totalCount } } }`, map[string]any{"id": postID})
+		require.Empty(t, resp.Errors)
+		post = resp.field("post")
+		assert.Equal(t, "E2E", post["title"])
+		assert.Contains(t, post["contentHTML"], "<strong>жирный</strong>")
+	})
+
+	t.Run("subscription receives new comment", func(t *testing.T) {
+		require.NotEmpty(t, postID)
+		sub := c.subscribe(token, `subscription($postId: ID!) { commentAdded(postId: $postId) { id content } }`, map[string]any{"postId": postID})
+		defer sub.Close()
+
+		resp := c.do(token, `mutation($postId: ID!) { createComment(postId: $postId, content: "Первый") { id } }`, map[string]any{"postId": postID})
+		require.Empty(t, resp.Errors)
+		commentID := resp.field("createComment")["id"]
+
+		sub.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg struct {
+			Type    string `json:"type"`
+			Payload struct {
+				Data struct {
+					CommentAdded map[string]any `json:"commentAdded"`
+				} `json:"data"`
+			} `json:"payload"`
+		}
+		require.NoError(t, sub.ReadJSON(&msg))
+		assert.Equal(t, "next", msg.Type)
+		assert.Equal(t, commentID, msg.Payload.Data.CommentAdded["id"])
+		assert.Equal(t, "Первый", msg.Payload.Data.CommentAdded["content"])
+	})
+
+	t.Run("comments pagination", func(t *testing.T) {
+		resp := c.do(token, `query($id: ID!) { post(id: $id) { comments(limit: 10) { totalCount comments { content } } } }`, map[string]any{"id": postID})
+		require.Empty(t, resp.Errors)
+		comments := resp.field("post")["comments"].(map[string]any)
+		assert.EqualValues(t, 1, comments["totalCount"])
+	})
+
+	t.Run("defer delivers comments incrementally", func(t *testing.T) {
+		parts := c.doIncremental(token, `query($id: ID!) { post(id: $id) { title ... @defer(label: "comments") { comments(limit: 10) { comments { content } } } } }`,
+			map[string]any{"id": postID})
+		require.GreaterOrEqual(t, len(parts), 2, "Комментарии приходят отдельной частью ответа")
+
+		var initial struct {
+			Data    map[string]map[string]any `json:"data"`
+			HasNext bool                      `json:"hasNext"`
+		}
+		require.NoError(t, json.Unmarshal(parts[0], &initial))
+		assert.True(t, initial.HasNext)
+		assert.Equal(t, "E2E", initial.Data["post"]["title"])
+		assert.Nil(t, initial.Data["post"]["comments"], "Первая часть не ждёт комментариев")
+
+		var incremental struct {
+			Incremental []struct {
+				Label string         `json:"label"`
+				Path  []any          `json:"path"`
+				Data  map[string]any `json:"data"`
+			} `json:"incremental"`
+			HasNext bool `json:"hasNext"`
+		}
+		require.NoError(t, json.Unmarshal(parts[len(parts)-1], &incremental))
+		assert.False(t, incremental.HasNext)
+		require.Len(t, incremental.Incremental, 1)
+		assert.Equal(t, "comments", incremental.Incremental[0].Label)
+		assert.Equal(t, []any{"post"}, incremental.Incremental[0].Path)
+		assert.Contains(t, incremental.Incremental[0].Data, "comments")
+	})
+
+	t.Run("stream returns the whole list", func(t *testing.T) {
+		resp := c.do(token, `query($id: ID!) { post(id: $id) { comments(limit: 10) { comments @stream(initialCount: 0) { content } } } }`, map[string]any{"id": postID})
+		require.Empty(t, resp.Errors)
+		comments := resp.field("post")["comments"].(map[string]any)["comments"].([]any)
+		assert.Len(t, comments, 1)
+
+		resp = c.do(token, `query($id: ID!) { post(id: $id) { comments(limit: 10) { comments @stream(initialCount: -1) { content } } } }`, map[string]any{"id": postID})
+		require.NotEmpty(t, resp.Errors)
+		assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("persisted query over GET is cacheable", func(t *testing.T) {
+		query := `query($id: ID!) { post(id: $id) { title } }`
+		sum := sha256.Sum256([]byte(query))
+		params := url.Values{
+			"variables":  {`{"id":"` + postID + `"}`},
+			"extensions": {`{"persistedQuery":{"version":1,"sha256Hash":"` + hex.EncodeToString(sum[:]) + `"}}`},
+		}
+		res := c.get(params, nil)
+		assert.Contains(t, string(res.body), "PersistedQueryNotFound")
+
+		// Регистрация документа и обычный ответ
+		params.Set("query", query)
+		res = c.get(params, nil)
+		assert.Contains(t, string(res.body), `"title":"E2E"`)
+
+		params.Del("query")
+		res = c.get(params, map[string]string{"Accept-Encoding": "gzip"})
+		require.Equal(t, http.StatusOK, res.status)
+		assert.Equal(t, "gzip", res.header.Get("Content-Encoding"))
+		assert.Equal(t, "public, max-age=60", res.header.Get("Cache-Control"))
+		etag := res.header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		res = c.get(params, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, res.status)
+
+		mutation := url.Values{"query": {`mutation { markThreadRead(postId: "` + postID + `") { id } }`}}
+		res = c.get(mutation, nil)
+		assert.Equal(t, http.StatusNotAcceptable, res.status, "Мутации по GET не выполняются")
+	})
+
+	t.Run("invalid token rejected", func(t *testing.T) {
+		resp := c.do("not-a-jwt", `query { posts(limit: 1) { totalCount } }`, nil)
+		assert.NotEmpty(t, resp.Errors, "Недействительный токен должен давать ошибку")
+	})
+
+	t.Run("validation error code", func(t *testing.T) {
+		resp := c.do(token, `mutation($postId: ID!, $content: String!) { createComment(postId: $postId, content: $content) { id } }`,
+			map[string]any{"postId": postID, "content": strings.Repeat("a", 2001)})
+		require.NotEmpty(t, resp.Errors)
+		assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("malformed id rejected", func(t *testing.T) {
+		resp := c.do(token, `query($id: ID!) { post(id: $id) { title } }`, map[string]any{"id": "post1"})
+		require.NotEmpty(t, resp.Errors)
+		assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions["code"])
+
+		resp = c.do(token, `query($id: ID!) { post(id: $id) { title } }`, map[string]any{"id": strings.ToUpper(postID)})
+		require.NotEmpty(t, resp.Errors, "Идентификатор принимается только в каноническом виде")
+		assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("readiness", func(t *testing.T) {
+		res, err := http.Get(ts.URL + "/readyz")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
+
+// client - минимальный GraphQL-клиент для тестов
+type client struct {
+	t   *testing.T
+	url string
+}
+
+type gqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+	t *testing.T
+}
+
+func (r *gqlResponse) field(name string) map[string]any {
+	var value map[string]any
+	require.NoError(r.t, json.Unmarshal(r.Data[name], &value))
+	return value
+}
+
+func (c *client) token() string {
+	res, err := http.Get(c.url + "/token")
+	require.NoError(c.t, err)
+	defer res.Body.Close()
+	var body map[string]string
+	require.NoError(c.t, json.NewDecoder(res.Body).Decode(&body))
+	require.NotEmpty(c.t, body["token"])
+	return body["token"]
+}
+
+func (c *client) do(token, query string, variables map[string]any) *gqlResponse {
+	payload, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	require.NoError(c.t, err)
+	req, err := http.NewRequest(http.MethodPost, c.url+"/query", bytes.NewReader(payload))
+	require.NoError(c.t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer res.Body.Close()
+	resp := &gqlResponse{t: c.t}
+	require.NoError(c.t, json.NewDecoder(res.Body).Decode(resp))
+	return resp
+}
+
+// getResponse - ответ на GET-запрос без разбора тела
+type getResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// get выполняет GraphQL-запрос по GET без авторизации. Тело возвращается в том виде, в каком пришло:
+// заданный явно Accept-Encoding отключает автоматическую распаковку
+func (c *client) get(params url.Values, headers map[string]string) getResponse {
+	req, err := http.NewRequest(http.MethodGet, c.url+"/query?"+params.Encode(), nil)
+	require.NoError(c.t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(c.t, err)
+	return getResponse{status: res.StatusCode, header: res.Header, body: body}
+}
+
+// doIncremental выполняет запрос с Accept: multipart/mixed и возвращает JSON всех частей ответа по порядку
+func (c *client) doIncremental(token, query string, variables map[string]any) []json.RawMessage {
+	payload, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	require.NoError(c.t, err)
+	req, err := http.NewRequest(http.MethodPost, c.url+"/query", bytes.NewReader(payload))
+	require.NoError(c.t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "multipart/mixed;deferSpec=20220824, application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer res.Body.Close()
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	require.NoError(c.t, err)
+	require.Equal(c.t, "multipart/mixed", mediaType)
+
+	var parts []json.RawMessage
+	reader := multipart.NewReader(res.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(c.t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(c.t, err)
+		parts = append(parts, body)
+	}
+}
+
+// subscribe открывает подписку по протоколу graphql-transport-ws и ждёт подтверждения
+func (c *client) subscribe(token, query string, variables map[string]any) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(c.url, "http")+"/query", nil)
+	require.NoError(c.t, err)
+
+	require.NoError(c.t, conn.WriteJSON(map[string]any{
+		"type":    "connection_init",
+		"payload": map[string]any{"Authorization": "Bearer " + token},
+	}))
+	var ack struct {
+		Type string `json:"type"`
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	require.NoError(c.t, conn.ReadJSON(&ack))
+	require.Equal(c.t, "connection_ack", ack.Type)
+
+	require.NoError(c.t, conn.WriteJSON(map[string]any{
+		"id":      "1",
+		"type":    "subscribe",
+		"payload": map[string]any{"query": query, "variables": variables},
+	}))
+	// Даём серверу зарегистрировать подписку до публикации
+	time.Sleep(100 * time.Millisecond)
+	return conn
+}
@@ -0,0 +1,89 @@
+// Package email отправляет письма пользователям через SMTP-сервер или, без него, пишет их в лог
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+)
+
+// Message - текстовое письмо одному получателю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender доставляет письма
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender пишет письма в стандартный лог вместо отправки
+type LogSender struct{}
+
+// NewLogSender создаёт отправитель, пишущий письма в лог
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send реализует Sender
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("Письмо для %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// SMTPOptions задаёт SMTP-сервер и адрес отправителя; без Username письма отправляются без авторизации
+type SMTPOptions struct {
+	// Addr - адрес сервера в виде host:port
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// SMTPSender отправляет письма через SMTP-сервер
+type SMTPSender struct {
+	opts SMTPOptions
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTP создаёт отправитель через SMTP-сервер
+func NewSMTP(opts SMTPOptions) *SMTPSender {
+	log.Printf("Создание SMTP Sender: addr=%s, from=%s", opts.Addr, opts.From)
+	return &SMTPSender{opts: opts, send: smtp.SendMail}
+}
+
+// Send реализует Sender
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.opts.Username != "" {
+		host, _, err := net.SplitHostPort(s.opts.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address: %v", err)
+		}
+		auth = smtp.PlainAuth("", s.opts.Username, s.opts.Password, host)
+	}
+	if err := s.send(s.opts.Addr, auth, s.opts.From, []string{msg.To}, compose(s.opts.From, msg)); err != nil {
+		log.Printf("Ошибка отправки письма для %s: %v", msg.To, err)
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// compose собирает письмо в формате RFC 5322; тема кодируется для заголовков, тело передаётся в UTF-8
+func compose(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(msg.Body)
+	return buf.Bytes()
+}
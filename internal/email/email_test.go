@@ -0,0 +1,39 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPSender_Send(t *testing.T) {
+	sender := NewSMTP(SMTPOptions{Addr: "smtp.example.com:587", From: "noreply@example.com", Username: "user", Password: "secret"})
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotAuth smtp.Auth
+	var gotMsg []byte
+	sender.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, a, from, to, msg
+		return nil
+	}
+
+	err := sender.Send(context.Background(), Message{To: "reader@example.com", Subject: "Новые комментарии", Body: "Текст"})
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", gotAddr)
+	assert.Equal(t, "noreply@example.com", gotFrom)
+	assert.Equal(t, []string{"reader@example.com"}, gotTo)
+	assert.NotNil(t, gotAuth)
+	headers, body, ok := strings.Cut(string(gotMsg), "\r\n\r\n")
+	require.True(t, ok)
+	assert.Contains(t, headers, "Subject: =?utf-8?q?")
+	assert.NotContains(t, headers, "Новые", "Тема кодируется для заголовка")
+	assert.Equal(t, "Текст", body)
+
+	sender.send = func(string, smtp.Auth, string, []string, []byte) error { return errors.New("connection refused") }
+	assert.Error(t, sender.Send(context.Background(), Message{To: "reader@example.com"}))
+}
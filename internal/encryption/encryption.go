@@ -0,0 +1,252 @@
+// Package encryption шифрует отдельные поля конвертной схемой: каждое значение шифруется своим
+// случайным ключом данных AES-256-GCM, а ключ данных - ключом шифрования ключей (KEK) из Keyring.
+// При смене KEK достаточно перешифровать ключи данных, само содержимое не меняется
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// prefix отмечает зашифрованное значение; значения без него считаются открытым текстом,
+// записанным до включения шифрования
+const prefix = "enc1:"
+
+// keySize - размер ключей шифрования ключей и ключей данных в байтах (AES-256)
+const keySize = 32
+
+// ErrUnknownKey возвращается для значения, зашифрованного ключом, которого нет в Keyring
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// ErrMalformed возвращается для повреждённого зашифрованного значения
+var ErrMalformed = errors.New("malformed encrypted value")
+
+// Keyring - набор ключей шифрования ключей. Новые значения шифруются ключом Primary,
+// остальные ключи нужны для чтения значений, записанных до ротации
+type Keyring struct {
+	Primary string
+	Keys    map[string][]byte
+}
+
+// Validate проверяет, что основной ключ есть в наборе, а все ключи имеют размер AES-256
+func (k *Keyring) Validate() error {
+	if k.Primary == "" {
+		return fmt.Errorf("primary key id is empty")
+	}
+	if _, ok := k.Keys[k.Primary]; !ok {
+		return fmt.Errorf("primary key %q is not in the keyring", k.Primary)
+	}
+	for id, key := range k.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("key id %q must be non-empty and must not contain ':'", id)
+		}
+		if len(key) != keySize {
+			return fmt.Errorf("key %q must be %d bytes, got %d", id, keySize, len(key))
+		}
+	}
+	return nil
+}
+
+// KeyProvider загружает набор ключей: из файла или из внешнего сервиса управления ключами
+type KeyProvider interface {
+	Keyring(ctx context.Context) (*Keyring, error)
+}
+
+// FileProvider читает набор ключей из YAML-файла вида
+//
+//	primary: "2026-03"
+//	keys:
+//	  "2026-03": <32 байта в base64>
+//	  "2025-11": <32 байта в base64>
+type FileProvider struct {
+	Path string
+}
+
+// Keyring реализует KeyProvider
+func (p FileProvider) Keyring(ctx context.Context) (*Keyring, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	var file struct {
+		Primary string            `yaml:"primary"`
+		Keys    map[string]string `yaml:"keys"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %v", err)
+	}
+	keyring := &Keyring{Primary: file.Primary, Keys: make(map[string][]byte, len(file.Keys))}
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %v", id, err)
+		}
+		keyring.Keys[id] = key
+	}
+	if err := keyring.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid key file: %v", err)
+	}
+	return keyring, nil
+}
+
+// Envelope шифрует и расшифровывает значения ключами из Keyring
+type Envelope struct {
+	keyring *Keyring
+	rand    io.Reader
+}
+
+// New создаёт Envelope; набор ключей должен пройти Validate
+func New(keyring *Keyring) (*Envelope, error) {
+	if err := keyring.Validate(); err != nil {
+		return nil, err
+	}
+	return &Envelope{keyring: keyring, rand: rand.Reader}, nil
+}
+
+// IsEncrypted сообщает, записано ли value в зашифрованном виде
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID возвращает ключ, которым зашифровано value, или пустую строку для открытого текста
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(value[len(prefix):], ":")
+	return id
+}
+
+// NeedsRotation сообщает, нужно ли перешифровать value основным ключом: значение не зашифровано
+// или зашифровано одним из прежних ключей
+func (e *Envelope) NeedsRotation(value string) bool {
+	return KeyID(value) != e.keyring.Primary
+}
+
+// Encrypt шифрует plaintext новым ключом данных под основным ключом.
+// Результат: enc1:<id ключа>:<base64 из nonce и обёрнутого ключа данных, nonce и шифротекста>
+func (e *Envelope) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(e.rand, dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %v", err)
+	}
+	sealed, err := seal(dataKey, []byte(plaintext), nil, e.rand)
+	if err != nil {
+		return "", err
+	}
+	return e.wrap(dataKey, sealed)
+}
+
+// Decrypt возвращает открытый текст value; значение без признака шифрования возвращается как есть
+func (e *Envelope) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	id, dataKey, sealed, err := e.open(value)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := unseal(dataKey, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value with key %q: %v", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// Rotate переводит value на основной ключ. Зашифрованное значение сохраняет ключ данных и шифротекст,
+// заново оборачивается только ключ данных; открытый текст шифруется
+func (e *Envelope) Rotate(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return e.Encrypt(value)
+	}
+	_, dataKey, sealed, err := e.open(value)
+	if err != nil {
+		return "", err
+	}
+	return e.wrap(dataKey, sealed)
+}
+
+// wrap оборачивает ключ данных основным ключом и собирает значение с шифротекстом sealed.
+// Идентификатор ключа входит в проверяемые данные GCM, поэтому подменить его в значении нельзя
+func (e *Envelope) wrap(dataKey, sealed []byte) (string, error) {
+	wrapped, err := seal(e.keyring.Keys[e.keyring.Primary], dataKey, []byte(e.keyring.Primary), e.rand)
+	if err != nil {
+		return "", err
+	}
+	return prefix + e.keyring.Primary + ":" + base64.RawStdEncoding.EncodeToString(append(wrapped, sealed...)), nil
+}
+
+// open разбирает зашифрованное значение и разворачивает его ключ данных
+func (e *Envelope) open(value string) (string, []byte, []byte, error) {
+	id, encoded, ok := strings.Cut(value[len(prefix):], ":")
+	if !ok {
+		return "", nil, nil, ErrMalformed
+	}
+	kek, ok := e.keyring.Keys[id]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	// Обёрнутый ключ данных: nonce, ключ и тег GCM
+	wrappedSize := nonceSize + keySize + tagSize
+	if len(raw) < wrappedSize+nonceSize+tagSize {
+		return "", nil, nil, ErrMalformed
+	}
+	dataKey, err := unseal(kek, raw[:wrappedSize], []byte(id))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to unwrap data key with key %q: %v", id, err)
+	}
+	return id, dataKey, raw[wrappedSize:], nil
+}
+
+// Размеры nonce и тега AES-GCM
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// seal шифрует plaintext ключом key и возвращает nonce вместе с шифротекстом
+func seal(key, plaintext, additional []byte, random io.Reader) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// unseal расшифровывает результат seal
+func unseal(key, sealed, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < nonceSize+tagSize {
+		return nil, ErrMalformed
+	}
+	return aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,149 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKeyring(primary string, ids ...string) *Keyring {
+	keyring := &Keyring{Primary: primary, Keys: map[string][]byte{}}
+	for i, id := range ids {
+		keyring.Keys[id] = bytes.Repeat([]byte{byte(i + 1)}, keySize)
+	}
+	return keyring
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	envelope, err := New(testKeyring("k1", "k1"))
+	require.NoError(t, err)
+
+	value, err := envelope.Encrypt("Секретный текст")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(value))
+	assert.Equal(t, "k1", KeyID(value))
+	assert.NotContains(t, value, "Секретный")
+
+	again, err := envelope.Encrypt("Секретный текст")
+	require.NoError(t, err)
+	assert.NotEqual(t, value, again, "Каждое значение шифруется своим ключом данных и nonce")
+
+	plaintext, err := envelope.Decrypt(value)
+	require.NoError(t, err)
+	assert.Equal(t, "Секретный текст", plaintext)
+
+	empty, err := envelope.Encrypt("")
+	require.NoError(t, err)
+	plaintext, err = envelope.Decrypt(empty)
+	require.NoError(t, err)
+	assert.Empty(t, plaintext)
+}
+
+func TestEnvelope_PlaintextPassesThrough(t *testing.T) {
+	envelope, err := New(testKeyring("k1", "k1"))
+	require.NoError(t, err)
+
+	plaintext, err := envelope.Decrypt("записано до включения шифрования")
+	require.NoError(t, err)
+	assert.Equal(t, "записано до включения шифрования", plaintext)
+	assert.True(t, envelope.NeedsRotation("записано до включения шифрования"))
+}
+
+func TestEnvelope_Rotate(t *testing.T) {
+	old, err := New(testKeyring("k1", "k1"))
+	require.NoError(t, err)
+	value, err := old.Encrypt("текст")
+	require.NoError(t, err)
+
+	rotated, err := New(testKeyring("k2", "k1", "k2"))
+	require.NoError(t, err)
+	assert.True(t, rotated.NeedsRotation(value))
+
+	value, err = rotated.Rotate(value)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", KeyID(value))
+	assert.False(t, rotated.NeedsRotation(value))
+
+	// Без прежнего ключа значение после ротации по-прежнему читается
+	current := testKeyring("k2", "k1", "k2")
+	delete(current.Keys, "k1")
+	withoutOld, err := New(current)
+	require.NoError(t, err)
+	plaintext, err := withoutOld.Decrypt(value)
+	require.NoError(t, err)
+	assert.Equal(t, "текст", plaintext)
+
+	value, err = rotated.Rotate("открытый текст")
+	require.NoError(t, err)
+	plaintext, err = withoutOld.Decrypt(value)
+	require.NoError(t, err)
+	assert.Equal(t, "открытый текст", plaintext)
+}
+
+func TestEnvelope_Errors(t *testing.T) {
+	envelope, err := New(testKeyring("k1", "k1"))
+	require.NoError(t, err)
+	value, err := envelope.Encrypt("текст")
+	require.NoError(t, err)
+
+	other, err := New(testKeyring("k3", "k3"))
+	require.NoError(t, err)
+	_, err = other.Decrypt(value)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = envelope.Decrypt(prefix + "k1")
+	assert.ErrorIs(t, err, ErrMalformed)
+	_, err = envelope.Decrypt(prefix + "k1:AAAA")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	tampered := value[:len(value)-2] + "AA"
+	if tampered == value {
+		tampered = value[:len(value)-2] + "BB"
+	}
+	_, err = envelope.Decrypt(tampered)
+	assert.Error(t, err, "Изменённый шифротекст не проходит проверку GCM")
+
+	// Подмена идентификатора ключа тоже обнаруживается: он входит в проверяемые данные
+	sameKey := testKeyring("k1", "k1")
+	sameKey.Keys["k2"] = sameKey.Keys["k1"]
+	both, err := New(sameKey)
+	require.NoError(t, err)
+	_, err = both.Decrypt(strings.Replace(value, prefix+"k1:", prefix+"k2:", 1))
+	assert.Error(t, err)
+}
+
+func TestKeyring_Validate(t *testing.T) {
+	assert.NoError(t, testKeyring("k1", "k1", "k2").Validate())
+	assert.Error(t, testKeyring("", "k1").Validate())
+	assert.Error(t, testKeyring("k2", "k1").Validate(), "Основного ключа нет в наборе")
+	assert.Error(t, testKeyring("a:b", "a:b").Validate())
+
+	short := testKeyring("k1", "k1")
+	short.Keys["k1"] = short.Keys["k1"][:16]
+	assert.Error(t, short.Validate())
+}
+
+func TestFileProvider(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, keySize))
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("primary: \"2026-03\"\nkeys:\n  \"2026-03\": "+key+"\n"), 0o600))
+
+	keyring, err := FileProvider{Path: path}.Keyring(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "2026-03", keyring.Primary)
+	assert.Equal(t, bytes.Repeat([]byte{7}, keySize), keyring.Keys["2026-03"])
+
+	require.NoError(t, os.WriteFile(path, []byte("primary: \"2026-04\"\nkeys:\n  \"2026-03\": "+key+"\n"), 0o600))
+	_, err = FileProvider{Path: path}.Keyring(context.Background())
+	assert.ErrorContains(t, err, "2026-04")
+
+	_, err = FileProvider{Path: filepath.Join(t.TempDir(), "missing.yaml")}.Keyring(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+// Package excerpt строит анонсы постов для списков и рассылок, чтобы им не нужно было полное содержимое.
+// Анонс строится при создании поста из текста без разметки: целые предложения, если они помещаются,
+// иначе начало текста по границе слова. Если подключён Summarizer, анонс в фоне заменяется кратким
+// содержанием от внешнего сервиса
+package excerpt
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/markdown"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/textsize"
+)
+
+// Document - пост, для которого строится краткое содержание
+type Document struct {
+	Title string
+	// Text - содержимое поста без разметки
+	Text string
+	// Language - код ISO 639-1 или пустая строка, если язык неизвестен
+	Language string
+	// MaxLength - наибольшая длина ответа в символах; более длинный ответ обрезается
+	MaxLength int
+}
+
+// Summarizer строит краткое содержание поста, например языковой моделью
+type Summarizer interface {
+	Summarize(ctx context.Context, doc Document) (string, error)
+}
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Length - наибольшая длина анонса в графемах вместе с многоточием
+	Length    int
+	Timeout   time.Duration
+	Workers   int
+	QueueSize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Length <= 0 {
+		o.Length = 200
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.Workers <= 0 {
+		o.Workers = 2
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	return o
+}
+
+// Service строит анонсы и, если задан Summarizer, заменяет их краткими содержаниями фоновыми обработчиками
+type Service struct {
+	summarizer Summarizer
+	store      storage.Storage
+	opts       Options
+	jobs       chan models.Post
+	wg         sync.WaitGroup
+	once       sync.Once
+}
+
+// New создаёт сервис; summarizer может быть nil, тогда анонсы строятся только из текста
+func New(summarizer Summarizer, store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Excerpt Service: длина анонса %d, краткие содержания: %t", opts.Length, summarizer != nil)
+	s := &Service{summarizer: summarizer, store: store, opts: opts}
+	if summarizer == nil {
+		return s
+	}
+	s.jobs = make(chan models.Post, opts.QueueSize)
+	for i := 0; i < opts.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// Make возвращает анонс поста из его содержимого
+func (s *Service) Make(post *models.Post) string {
+	return Make(post.Format, post.Content, s.opts.Length)
+}
+
+// Enqueue ставит сохранённый пост на построение краткого содержания и возвращает false, если
+// Summarizer не задан или очередь переполнена. Такой пост остаётся с анонсом из Make
+func (s *Service) Enqueue(post *models.Post) bool {
+	if s.summarizer == nil {
+		return false
+	}
+	select {
+	case s.jobs <- *post:
+		log.Printf("Пост %s поставлен в очередь кратких содержаний", post.ID)
+		return true
+	default:
+		log.Printf("Очередь кратких содержаний переполнена, пост %s пропущен", post.ID)
+		metrics.PostSummaries.WithLabelValues("dropped").Inc()
+		return false
+	}
+}
+
+// Close останавливает обработчики после завершения текущих запросов
+func (s *Service) Close() {
+	if s.summarizer == nil {
+		return
+	}
+	s.once.Do(func() {
+		close(s.jobs)
+		s.wg.Wait()
+		log.Println("Excerpt Service остановлен")
+	})
+}
+
+func (s *Service) worker() {
+	defer s.wg.Done()
+	for post := range s.jobs {
+		s.process(post)
+	}
+}
+
+// process запрашивает краткое содержание и сохраняет его вместо анонса. Пустой ответ анонс не меняет
+func (s *Service) process(post models.Post) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	summary, err := s.summarizer.Summarize(ctx, Document{
+		Title:     post.Title,
+		Text:      markdown.PlainText(post.Format, post.Content),
+		Language:  post.Language,
+		MaxLength: s.opts.Length,
+	})
+	if err != nil {
+		log.Printf("Ошибка построения краткого содержания поста %s: %v", post.ID, err)
+		metrics.PostSummaries.WithLabelValues("error").Inc()
+		return
+	}
+	summary = Make(models.FormatPlain, summary, s.opts.Length)
+	if summary == "" {
+		metrics.PostSummaries.WithLabelValues("empty").Inc()
+		return
+	}
+	if err := s.store.SetPostExcerpt(context.Background(), post.ID, summary); err != nil {
+		log.Printf("Ошибка сохранения краткого содержания поста %s: %v", post.ID, err)
+		metrics.PostSummaries.WithLabelValues("error").Inc()
+		return
+	}
+	metrics.PostSummaries.WithLabelValues("ok").Inc()
+}
+
+// Make возвращает анонс содержимого в формате format не длиннее length графем: текст без разметки
+// с пробельными символами, сжатыми до одного пробела. Длинный текст сокращается до целых предложений,
+// если они занимают хотя бы половину length, иначе обрезается по границе слова с многоточием
+func Make(format, content string, length int) string {
+	text := strings.Join(strings.Fields(markdown.PlainText(format, content)), " ")
+	if textsize.Graphemes(text) <= length {
+		return text
+	}
+	if end := sentences(text, length); end > 0 && textsize.Graphemes(text[:end]) >= length/2 {
+		return text[:end]
+	}
+	// Truncate не добавляет ничего, кроме многоточия, поэтому cut - начало text
+	cut := strings.TrimSuffix(textsize.Truncate(text, length), textsize.Ellipsis)
+	if text[len(cut)] != ' ' {
+		if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " ,;:-–—") + textsize.Ellipsis
+}
+
+// sentences возвращает длину в байтах самого длинного начала text из целых предложений, которое
+// не длиннее length графем, или 0. Предложение заканчивается знаком . ! ? или …, за которым идут пробел
+// и не строчная буква: так сокращения вроде «т. е.» не разрывают предложение
+func sentences(text string, length int) int {
+	end := 0
+	for i, r := range text {
+		if !strings.ContainsRune(".!?…", r) {
+			continue
+		}
+		next := i + utf8.RuneLen(r)
+		if next < len(text) {
+			if text[next] != ' ' {
+				continue
+			}
+			if following, _ := utf8.DecodeRuneInString(text[next+1:]); unicode.IsLower(following) {
+				continue
+			}
+		}
+		if textsize.Graphemes(text[:next]) > length {
+			break
+		}
+		end = next
+	}
+	return end
+}
@@ -0,0 +1,118 @@
+package excerpt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// summarizerFunc позволяет задать Summarizer функцией
+type summarizerFunc func(doc Document) (string, error)
+
+func (f summarizerFunc) Summarize(ctx context.Context, doc Document) (string, error) {
+	return f(doc)
+}
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		length  int
+		want    string
+	}{
+		{"Short text", models.FormatPlain, "Короткий\n\nпост", 50, "Короткий пост"},
+		{"Markdown stripped", models.FormatMarkdown, "**Жирный** текст со [ссылкой](https://example.com)", 50, "Жирный текст со ссылкой"},
+		{"Whole sentences", models.FormatPlain, "Первое предложение. Второе предложение! Третье не помещается.", 45, "Первое предложение. Второе предложение!"},
+		{"Abbreviation is not a sentence end", models.FormatPlain, "Анонс, т. е. начало поста. Продолжение текста.", 30, "Анонс, т. е. начало поста."},
+		{"Word boundary", models.FormatPlain, "Одно очень длинное предложение без точки до самого конца", 20, "Одно очень длинное…"},
+		{"Short first sentence falls back to words", models.FormatPlain, "Да. Это длинное продолжение поста без точек", 20, "Да. Это длинное…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Make(tt.format, tt.content, tt.length))
+		})
+	}
+}
+
+func TestService(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	newPost := func(title, content string) *models.Post {
+		post := &models.Post{ID: uuid.New().String(), Title: title, Content: content, Format: models.FormatMarkdown, Language: "ru", AuthorID: "author", CreatedAt: time.Now()}
+		post.Excerpt = Make(post.Format, post.Content, 40)
+		require.NoError(t, store.CreatePost(ctx, post))
+		return post
+	}
+	summarized := newPost("Пост", "# Заголовок\n\nДлинный **текст** поста")
+	failing := newPost("Сбой", "Сервис не ответил")
+	empty := newPost("Пусто", "Сервис ничего не вернул")
+
+	var docs []Document
+	summarizer := summarizerFunc(func(doc Document) (string, error) {
+		docs = append(docs, doc)
+		switch doc.Title {
+		case "Сбой":
+			return "", errors.New("unavailable")
+		case "Пусто":
+			return "  ", nil
+		}
+		return "Краткое содержание поста, которое сервис вернул длиннее, чем просили", nil
+	})
+	service := New(summarizer, store, Options{Length: 40, Workers: 1})
+	for _, post := range []*models.Post{summarized, failing, empty} {
+		assert.True(t, service.Enqueue(post))
+	}
+	service.Close()
+
+	require.Len(t, docs, 3)
+	assert.Equal(t, Document{Title: "Пост", Text: "Заголовок\nДлинный текст поста", Language: "ru", MaxLength: 40}, docs[0])
+	got, err := store.GetPost(ctx, summarized.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Краткое содержание поста, которое…", got.Excerpt, "Длинный ответ обрезается")
+	got, err = store.GetPost(ctx, failing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Сервис не ответил", got.Excerpt, "Ошибка сервиса оставляет анонс из текста")
+	got, err = store.GetPost(ctx, empty.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Сервис ничего не вернул", got.Excerpt)
+
+	without := New(nil, store, Options{})
+	assert.False(t, without.Enqueue(summarized), "Без Summarizer очереди нет")
+	without.Close()
+}
+
+func TestHTTP(t *testing.T) {
+	var received summaryRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = summaryRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		if received.Title == "broken" {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"summary":"Кратко о посте"}`))
+	}))
+	defer server.Close()
+
+	summarizer := NewHTTP(HTTPOptions{URL: server.URL, Timeout: time.Second})
+	summary, err := summarizer.Summarize(context.Background(), Document{Title: "Пост", Text: "Текст", Language: "ru", MaxLength: 100})
+	require.NoError(t, err)
+	assert.Equal(t, "Кратко о посте", summary)
+	assert.Equal(t, summaryRequest{Title: "Пост", Text: "Текст", Language: "ru", MaxLength: 100}, received)
+
+	_, err = summarizer.Summarize(context.Background(), Document{Title: "broken"})
+	assert.ErrorContains(t, err, "503")
+	assert.False(t, strings.Contains(err.Error(), "decode"))
+}
@@ -0,0 +1,75 @@
+package excerpt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxSummaryResponse ограничивает объём читаемого ответа сервиса
+const maxSummaryResponse = 64 << 10
+
+// HTTPOptions задаёт адрес сервиса кратких содержаний
+type HTTPOptions struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// HTTP запрашивает краткое содержание у внешнего сервиса: запрос
+// POST {"title", "text", "language", "maxLength"}, краткое содержание берётся из поля summary ответа
+type HTTP struct {
+	opts   HTTPOptions
+	client *http.Client
+}
+
+var _ Summarizer = &HTTP{}
+
+// NewHTTP создаёт клиент сервиса кратких содержаний
+func NewHTTP(opts HTTPOptions) *HTTP {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &HTTP{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// summaryRequest - тело запроса к сервису
+type summaryRequest struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	// Language не передаётся для неизвестного языка
+	Language  string `json:"language,omitempty"`
+	MaxLength int    `json:"maxLength"`
+}
+
+// Summarize реализует Summarizer
+func (h *HTTP) Summarize(ctx context.Context, doc Document) (string, error) {
+	body, err := json.Marshal(summaryRequest(doc))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode summary request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build summary request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("summary request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("summary service returned %d: %s", resp.StatusCode, data)
+	}
+	var result struct {
+		Summary string `json:"summary"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSummaryResponse)).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode summary response: %v", err)
+	}
+	return result.Summary, nil
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"html/template"
+	"io"
+)
+
+// embedTemplate - компактная страница для iframe; ссылки открываются вне iframe
+var embedTemplate = template.Must(template.Must(htmlTemplate.Clone()).New("embed").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<base target="_blank">
+<title>{{.Post.Title}}</title>
+{{if .OEmbedURL}}<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Post.Title}}">
+{{end}}<style>
+body { font-family: sans-serif; margin: 0.5rem; line-height: 1.4; font-size: 0.9375rem; }
+.meta { color: #666; font-size: 0.8125rem; }
+ol.comments { list-style: none; padding-left: 0; }
+ol.comments ol.comments { padding-left: 1rem; border-left: 2px solid #ddd; }
+.comment { margin: 0.75rem 0; }
+</style>
+</head>
+<body>
+<header>
+<strong>Comments ({{.CommentCount}})</strong>
+<a class="login" href="{{.LoginURL}}" rel="noopener">Log in to comment</a>
+</header>
+{{template "thread" .Comments}}
+</body>
+</html>`))
+
+// Embed записывает документ страницей виджета для встраивания через iframe: только комментарии
+// без содержимого поста, со ссылкой на вход. Виджет только показывает обсуждение
+type Embed struct {
+	// LoginURL - страница входа, где пользователь может оставить комментарий
+	LoginURL string
+	// OEmbedURL - адрес описания виджета oEmbed для ссылки обнаружения; пустой - без ссылки
+	OEmbedURL string
+}
+
+var _ Format = Embed{}
+
+// ContentType реализует Format
+func (Embed) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+// Extension реализует Format
+func (Embed) Extension() string {
+	return "html"
+}
+
+// Write реализует Format
+func (e Embed) Write(w io.Writer, doc *Document) error {
+	return embedTemplate.Execute(w, struct {
+		*Document
+		Embed
+	}{doc, e})
+}
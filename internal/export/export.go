@@ -0,0 +1,139 @@
+// Package export сохраняет пост вместе со всем деревом комментариев в самостоятельный документ
+// для архива и ответов на юридические запросы. Содержимое отбирается по правилам видимости
+// зрителя: скрытые комментарии (теневой бан, проверка модератором, спам) видны только их автору
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/markdown"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Document - пост с деревом комментариев, подготовленный к выводу
+type Document struct {
+	Post *models.Post
+	// PostHTML - содержимое поста после рендеринга
+	PostHTML string
+	Comments []*Node
+	// CommentCount - количество комментариев во всём дереве
+	CommentCount int
+	// Viewer - пользователь, от имени которого выбрано содержимое; пустой - анонимный зритель
+	Viewer     string
+	ExportedAt time.Time
+}
+
+// Node - комментарий с ответами
+type Node struct {
+	Comment *models.Comment
+	HTML    string
+	Replies []*Node
+}
+
+// Format записывает Document в конкретном формате, например HTML или PDF
+type Format interface {
+	// ContentType - MIME-тип результата
+	ContentType() string
+	// Extension - расширение файла результата без точки
+	Extension() string
+	Write(w io.Writer, doc *Document) error
+}
+
+// Options - параметры выгрузки
+type Options struct {
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// Service собирает документы выгрузки
+type Service struct {
+	store    storage.Storage
+	renderer *markdown.Renderer
+	opts     Options
+}
+
+// New создаёт Service; содержимое рендерится renderer, как в ответах API
+func New(store storage.Storage, renderer *markdown.Renderer, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Println("Создание Export Service")
+	return &Service{store: store, renderer: renderer, opts: opts}
+}
+
+// Build собирает пост postID и все видимые viewerID комментарии в порядке создания.
+// Пост, скрытый от зрителя, не выгружается: возвращается storage.ErrPostNotFound
+func (s *Service) Build(ctx context.Context, postID, viewerID string) (*Document, error) {
+	post, err := storage.ViewPost(ctx, s.store, postID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	postHTML, err := s.renderer.Render(post.Format, post.Content)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Post: post, PostHTML: postHTML, Viewer: viewerID, ExportedAt: s.opts.Clock.Now()}
+	if doc.Comments, err = s.tree(ctx, doc, postID); err != nil {
+		return nil, err
+	}
+	log.Printf("Выгрузка поста %s собрана: комментариев %d", postID, doc.CommentCount)
+	return doc, nil
+}
+
+// tree читает комментарии поста одним потоком IterateComments и собирает из видимых зрителю дерево.
+// Ответы на невидимые комментарии не выгружаются, как и ветки под ними
+func (s *Service) tree(ctx context.Context, doc *Document, postID string) ([]*Node, error) {
+	var visible []*Node
+	err := s.store.IterateComments(ctx, postID, func(comment *models.Comment) error {
+		if storage.CommentVisibleTo(comment, doc.Viewer) {
+			visible = append(visible, &Node{Comment: comment})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load comments: %v", err)
+	}
+	// Родитель привязывается после чтения всех комментариев: у импортированных ответ может быть старше родителя
+	byID := make(map[string]*Node, len(visible))
+	for _, node := range visible {
+		byID[node.Comment.ID] = node
+	}
+	var roots []*Node
+	for _, node := range visible {
+		if node.Comment.ParentID == nil {
+			roots = append(roots, node)
+		} else if parent, ok := byID[*node.Comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+	if err := s.render(doc, roots); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// render рендерит комментарии веток nodes и учитывает их в CommentCount
+func (s *Service) render(doc *Document, nodes []*Node) error {
+	for _, node := range nodes {
+		html, err := s.renderer.Render(node.Comment.Format, node.Comment.Content)
+		if err != nil {
+			return err
+		}
+		node.HTML = html
+		doc.CommentCount++
+		if err := s.render(doc, node.Replies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/markdown"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "post1", Title: "Пост", Content: "**Важно**", Format: models.FormatMarkdown, AuthorID: "user1", AllowComments: true, CreatedAt: base}))
+	comment := func(id string, parentID *string, authorID, content string, hidden bool, minutes int) {
+		require.NoError(t, store.CreateComment(ctx, &models.Comment{
+			ID: id, PostID: "post1", ParentID: parentID, AuthorID: authorID, Content: content,
+			Format: models.FormatPlain, Hidden: hidden, CreatedAt: base.Add(time.Duration(minutes) * time.Minute),
+		}))
+	}
+	root := "c1"
+	comment("c1", nil, "user2", "Корень <script>", false, 1)
+	comment("c2", &root, "user3", "Ответ", false, 2)
+	comment("c3", &root, "banned", "Теневой бан", true, 3)
+	comment("c4", nil, "user2", "Второй", false, 4)
+	held := "c3"
+	comment("c5", &held, "user2", "Ответ на скрытый", false, 5)
+
+	service := New(store, markdown.New(10), Options{Clock: clock.NewFake(base.Add(time.Hour))})
+	doc, err := service.Build(ctx, "post1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, doc.CommentCount, "Скрытый комментарий не виден анонимному посетителю")
+	require.Len(t, doc.Comments, 2, "Ответ на скрытый комментарий не выгружается")
+	assert.Equal(t, "c1", doc.Comments[0].Comment.ID)
+	require.Len(t, doc.Comments[0].Replies, 1)
+	assert.Equal(t, "c2", doc.Comments[0].Replies[0].Comment.ID)
+	assert.Equal(t, "<p><strong>Важно</strong></p>\n", doc.PostHTML)
+
+	doc, err = service.Build(ctx, "post1", "banned")
+	require.NoError(t, err)
+	assert.Equal(t, 5, doc.CommentCount, "Автор видит свой скрытый комментарий и ответы на него")
+
+	var buf bytes.Buffer
+	require.NoError(t, HTML{}.Write(&buf, doc))
+	page := buf.String()
+	assert.Contains(t, page, `<li class="comment" id="comment-c3">`)
+	assert.Contains(t, page, "Корень &lt;script&gt;")
+	assert.NotContains(t, page, "<script>")
+	assert.Contains(t, page, "as seen by banned")
+
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "held", Title: "На проверке", AuthorID: "user1", Hidden: true, CreatedAt: base}))
+	_, err = service.Build(ctx, "held", "")
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
@@ -0,0 +1,67 @@
+package export
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// htmlTemplate - самостоятельная страница без внешних стилей и скриптов
+var htmlTemplate = template.Must(template.New("document").Funcs(template.FuncMap{
+	"time":   func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"author": models.DisplayAuthorID,
+	"safe":   func(s string) template.HTML { return template.HTML(s) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Post.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; line-height: 1.5; }
+.meta { color: #666; font-size: 0.875rem; }
+ol.comments { list-style: none; padding-left: 0; }
+ol.comments ol.comments { padding-left: 1.5rem; border-left: 2px solid #ddd; }
+.comment { margin: 1rem 0; }
+</style>
+</head>
+<body>
+<article id="post-{{.Post.ID}}">
+<h1>{{.Post.Title}}</h1>
+<p class="meta">{{.Post.AuthorID}} · <time datetime="{{time .Post.CreatedAt}}">{{time .Post.CreatedAt}}</time></p>
+<div class="content">{{safe .PostHTML}}</div>
+</article>
+<section>
+<h2>Comments ({{.CommentCount}})</h2>
+{{template "thread" .Comments}}
+</section>
+<footer class="meta">Post {{.Post.ID}} exported {{time .ExportedAt}}{{if .Viewer}} as seen by {{.Viewer}}{{else}} as seen by anonymous visitors{{end}}</footer>
+</body>
+</html>
+{{define "thread"}}{{if .}}<ol class="comments">
+{{range .}}<li class="comment" id="comment-{{.Comment.ID}}">
+<p class="meta">{{author .Comment.PostID .Comment.AuthorID}} · <time datetime="{{time .Comment.CreatedAt}}">{{time .Comment.CreatedAt}}</time></p>
+<div class="content">{{safe .HTML}}</div>
+{{template "thread" .Replies}}</li>
+{{end}}</ol>{{end}}{{end}}`))
+
+// HTML записывает документ самостоятельной HTML-страницей
+type HTML struct{}
+
+var _ Format = HTML{}
+
+// ContentType реализует Format
+func (HTML) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+// Extension реализует Format
+func (HTML) Extension() string {
+	return "html"
+}
+
+// Write реализует Format
+func (HTML) Write(w io.Writer, doc *Document) error {
+	return htmlTemplate.Execute(w, doc)
+}
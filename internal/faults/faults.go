@@ -0,0 +1,84 @@
+// Package faults внедряет искусственные ошибки и задержки, чтобы проверять повторы, circuit breaker-ы
+// и поведение клиентов при частичных отказах. Сбои включаются только вне production
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+)
+
+// ErrInjected - ошибка, которую возвращает внедрённый сбой
+var ErrInjected = errors.New("injected fault")
+
+// Options задаёт частоту и задержку сбоев. Доли - от 0 до 1
+type Options struct {
+	// ErrorRate - доля вызовов, которые завершаются ошибкой ErrInjected
+	ErrorRate float64 `yaml:"errorRate"`
+	// LatencyRate - доля вызовов, которые задерживаются на Latency; задержка не исключает ошибку
+	LatencyRate float64       `yaml:"latencyRate"`
+	Latency     time.Duration `yaml:"latency"`
+	// Operations - операции, к которым применяются сбои: методы хранилища или пути HTTP. Пустой список - все
+	Operations []string `yaml:"operations"`
+}
+
+// Injector решает для каждого вызова, задержать ли его и завершить ли ошибкой
+type Injector struct {
+	target string
+	opts   Options
+	// roll возвращает случайное число от 0 до 1; заменяется в тестах
+	roll func() float64
+}
+
+// New создаёт внедрение сбоев; target - метка в метриках, например storage или http
+func New(target string, opts Options) *Injector {
+	log.Printf("Создание Fault Injector %s: ошибки=%v, задержки=%v по %v, операции=%v", target, opts.ErrorRate, opts.LatencyRate, opts.Latency, opts.Operations)
+	return &Injector{target: target, opts: opts, roll: rand.Float64}
+}
+
+// Enabled сообщает, может ли внедрение хоть что-то изменить
+func (i *Injector) Enabled() bool {
+	return i.opts.ErrorRate > 0 || (i.opts.LatencyRate > 0 && i.opts.Latency > 0)
+}
+
+// Inject задерживает вызов operation и возвращает ErrInjected с заданными вероятностями.
+// Если контекст отменён во время задержки, возвращается его ошибка
+func (i *Injector) Inject(ctx context.Context, operation string) error {
+	if len(i.opts.Operations) > 0 && !slices.Contains(i.opts.Operations, operation) {
+		return nil
+	}
+	if i.opts.Latency > 0 && i.roll() < i.opts.LatencyRate {
+		metrics.FaultsInjected.WithLabelValues(i.target, "latency").Inc()
+		timer := time.NewTimer(i.opts.Latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if i.roll() < i.opts.ErrorRate {
+		metrics.FaultsInjected.WithLabelValues(i.target, "error").Inc()
+		log.Printf("Внедрён сбой %s в %s", i.target, operation)
+		return fmt.Errorf("%w in %s", ErrInjected, operation)
+	}
+	return nil
+}
+
+// Middleware внедряет сбои в HTTP-запросы по их пути; сбойный запрос получает 503 Service Unavailable
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := i.Inject(r.Context(), r.URL.Path); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
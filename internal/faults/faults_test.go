@@ -0,0 +1,63 @@
+package faults
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjector(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Rates", func(t *testing.T) {
+		assert.NoError(t, New("test", Options{}).Inject(ctx, "GetPost"))
+		assert.False(t, New("test", Options{LatencyRate: 1}).Enabled(), "Доля задержек без их длительности ничего не меняет")
+
+		failing := New("test", Options{ErrorRate: 1})
+		assert.True(t, failing.Enabled())
+		assert.ErrorIs(t, failing.Inject(ctx, "GetPost"), ErrInjected)
+
+		injector := New("test", Options{ErrorRate: 0.5})
+		injector.roll = func() float64 { return 0.7 }
+		assert.NoError(t, injector.Inject(ctx, "GetPost"))
+		injector.roll = func() float64 { return 0.2 }
+		assert.ErrorIs(t, injector.Inject(ctx, "GetPost"), ErrInjected)
+	})
+
+	t.Run("Operations", func(t *testing.T) {
+		injector := New("test", Options{ErrorRate: 1, Operations: []string{"CreatePost"}})
+		assert.NoError(t, injector.Inject(ctx, "GetPost"))
+		assert.ErrorIs(t, injector.Inject(ctx, "CreatePost"), ErrInjected)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		injector := New("test", Options{LatencyRate: 1, Latency: 20 * time.Millisecond})
+		start := time.Now()
+		assert.NoError(t, injector.Inject(ctx, "GetPost"))
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+		injector = New("test", Options{LatencyRate: 1, Latency: time.Minute})
+		canceled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, injector.Inject(canceled, "GetPost"), context.DeadlineExceeded, "Задержка прерывается отменой контекста")
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		handler := New("test", Options{ErrorRate: 1, Operations: []string{"/query"}}).Middleware(next)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/query", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
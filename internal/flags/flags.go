@@ -0,0 +1,275 @@
+// Package flags включает рискованные возможности для части пользователей. Флаги задаются в конфигурации
+// и могут заменяться удалённым источником; для каждого запроса значения вычисляются один раз
+// и передаются в контексте, поэтому в пределах запроса флаг не меняется
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+)
+
+// Известные флаги
+const (
+	// PostCache - чтение постов по ID через общий кеш storage/cache
+	PostCache = "post-cache"
+	// RelatedRecency - ранжирование похожих постов с поправкой на их свежесть
+	RelatedRecency = "related-recency"
+	// RankingControversial - эксперимент: комментарии поста упорядочиваются стратегией ranking.Controversial
+	RankingControversial = "ranking-controversial"
+	// RankingMLScore - эксперимент: комментарии поста упорядочиваются оценкой внешней модели ranking.MLScore
+	RankingMLScore = "ranking-ml-score"
+)
+
+// Flag - правило включения флага
+type Flag struct {
+	// Percentage - доля пользователей в процентах, для которых флаг включён. Пользователь попадает в долю
+	// по хешу своего ID и имени флага, поэтому при увеличении доли включённые ранее остаются включёнными.
+	// Анонимным запросам флаг включается только при 100
+	Percentage int `yaml:"percentage" json:"percentage"`
+	// Users - пользователи, для которых флаг включён независимо от доли
+	Users []string `yaml:"users" json:"users"`
+	// Tenants - сообщества, для всех запросов которых флаг включён независимо от доли
+	Tenants []string `yaml:"tenants" json:"tenants"`
+}
+
+// Defaults - флаги, не заданные ни в конфигурации, ни удалённым источником. Остальные флаги по умолчанию выключены
+var Defaults = map[string]Flag{
+	PostCache: {Percentage: 100},
+}
+
+// EnabledFor сообщает, включён ли флаг name для пользователя userID
+func (f Flag) EnabledFor(name, userID string) bool {
+	if f.Percentage >= 100 {
+		return true
+	}
+	if userID == "" {
+		return false
+	}
+	if slices.Contains(f.Users, userID) {
+		return true
+	}
+	return bucket(name, userID) < f.Percentage
+}
+
+// bucket возвращает номер от 0 до 99, в который попадает пользователь для флага
+func bucket(name, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + "\x00" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// Provider загружает флаги из удалённого источника
+type Provider interface {
+	Flags(ctx context.Context) (map[string]Flag, error)
+}
+
+// HTTPProvider читает флаги JSON-объектом {"flags": {"<имя>": {"percentage": 10, "users": ["..."]}}}
+type HTTPProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+// Flags реализует Provider
+func (p HTTPProvider) Flags(ctx context.Context) (map[string]Flag, error) {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flags request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("flags request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("flags source returned %d: %s", resp.StatusCode, body)
+	}
+	var result struct {
+		Flags map[string]Flag `json:"flags"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode flags: %v", err)
+	}
+	return result.Flags, nil
+}
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Static - флаги из конфигурации
+	Static map[string]Flag
+	// Remote - удалённый источник; его флаги заменяют одноимённые из Static
+	Remote Provider
+	// Interval - как часто перечитывается удалённый источник
+	Interval time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	return o
+}
+
+// Service хранит действующие флаги и вычисляет их для пользователей
+type Service struct {
+	opts Options
+
+	mu     sync.RWMutex
+	remote map[string]Flag
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// New создаёт сервис флагов; удалённый источник читается после Start
+func New(opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Feature Flags Service: флагов в конфигурации %d, удалённый источник: %t", len(opts.Static), opts.Remote != nil)
+	return &Service{
+		opts: opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Lookup возвращает правило флага: из удалённого источника, конфигурации или Defaults
+func (s *Service) Lookup(name string) (Flag, bool) {
+	s.mu.RLock()
+	flag, ok := s.remote[name]
+	s.mu.RUnlock()
+	if ok {
+		return flag, true
+	}
+	if flag, ok := s.opts.Static[name]; ok {
+		return flag, true
+	}
+	flag, ok = Defaults[name]
+	return flag, ok
+}
+
+// Refresh перечитывает удалённый источник; при ошибке остаются прежние флаги
+func (s *Service) Refresh(ctx context.Context) error {
+	if s.opts.Remote == nil {
+		return nil
+	}
+	remote, err := s.opts.Remote.Flags(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.remote = remote
+	s.mu.Unlock()
+	return nil
+}
+
+// Start читает удалённый источник и перечитывает его каждые Interval до вызова Close
+func (s *Service) Start() {
+	if s.opts.Remote == nil {
+		close(s.done)
+		return
+	}
+	if err := s.Refresh(context.Background()); err != nil {
+		log.Printf("Не удалось загрузить флаги из удалённого источника, используются флаги конфигурации: %v", err)
+	}
+	go s.run()
+}
+
+// Close останавливает перечитывание удалённого источника
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+}
+
+func (s *Service) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Refresh(context.Background()); err != nil {
+				log.Printf("Не удалось перечитать флаги, используются прежние: %v", err)
+			}
+		}
+	}
+}
+
+// For возвращает вычисление флагов для запроса пользователя userID; пустой userID - анонимный запрос
+func (s *Service) For(userID string) *Evaluation {
+	return s.ForTenant(userID, "")
+}
+
+// ForTenant возвращает вычисление флагов для запроса пользователя userID в сообществе tenant
+func (s *Service) ForTenant(userID, tenant string) *Evaluation {
+	return &Evaluation{service: s, userID: userID, tenant: tenant, values: make(map[string]bool)}
+}
+
+// Evaluation - флаги одного запроса. Каждый флаг вычисляется при первом обращении и дальше не меняется
+type Evaluation struct {
+	service *Service
+	userID  string
+	tenant  string
+
+	mu     sync.Mutex
+	values map[string]bool
+}
+
+// Enabled сообщает, включён ли флаг name в этом запросе
+func (e *Evaluation) Enabled(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if value, ok := e.values[name]; ok {
+		return value
+	}
+	flag, _ := e.service.Lookup(name)
+	value := e.tenant != "" && slices.Contains(flag.Tenants, e.tenant) || flag.EnabledFor(name, e.userID)
+	e.values[name] = value
+	result := "off"
+	if value {
+		result = "on"
+	}
+	metrics.FeatureFlagEvaluations.WithLabelValues(name, result).Inc()
+	return value
+}
+
+// evaluationKey - ключ контекста с флагами запроса
+type evaluationKey struct{}
+
+// NewContext возвращает контекст с флагами запроса
+func NewContext(ctx context.Context, evaluation *Evaluation) context.Context {
+	return context.WithValue(ctx, evaluationKey{}, evaluation)
+}
+
+// FromContext возвращает флаги запроса или nil
+func FromContext(ctx context.Context) *Evaluation {
+	evaluation, _ := ctx.Value(evaluationKey{}).(*Evaluation)
+	return evaluation
+}
+
+// Enabled сообщает, включён ли флаг name для запроса ctx. Вне запроса, например в фоновых задачах,
+// действуют Defaults для анонимного пользователя
+func Enabled(ctx context.Context, name string) bool {
+	if evaluation := FromContext(ctx); evaluation != nil {
+		return evaluation.Enabled(name)
+	}
+	return Defaults[name].EnabledFor(name, "")
+}
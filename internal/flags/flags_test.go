@@ -0,0 +1,105 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlag_EnabledFor(t *testing.T) {
+	assert.True(t, Flag{Percentage: 100}.EnabledFor("f", ""), "При 100 флаг включён и анонимным запросам")
+	assert.False(t, Flag{Percentage: 99}.EnabledFor("f", ""))
+	assert.False(t, Flag{}.EnabledFor("f", "user1"))
+	assert.True(t, Flag{Users: []string{"user1"}}.EnabledFor("f", "user1"))
+
+	enabled := func(percentage int) map[string]bool {
+		result := map[string]bool{}
+		for i := range 1000 {
+			userID := fmt.Sprintf("user%d", i)
+			if (Flag{Percentage: percentage}).EnabledFor("f", userID) {
+				result[userID] = true
+			}
+		}
+		return result
+	}
+	ten, fifty := enabled(10), enabled(50)
+	assert.InDelta(t, 100, len(ten), 40, "Примерно 10%% пользователей")
+	assert.InDelta(t, 500, len(fifty), 80, "Примерно 50%% пользователей")
+	for userID := range ten {
+		assert.True(t, fifty[userID], "Увеличение доли не выключает включённых ранее: %s", userID)
+	}
+}
+
+// staticProvider возвращает заданные флаги или ошибку
+type staticProvider struct {
+	flags map[string]Flag
+	err   error
+}
+
+func (p *staticProvider) Flags(ctx context.Context) (map[string]Flag, error) {
+	return p.flags, p.err
+}
+
+func TestService(t *testing.T) {
+	remote := &staticProvider{flags: map[string]Flag{"remote-only": {Percentage: 100}, "both": {Percentage: 100}}}
+	service := New(Options{
+		Static: map[string]Flag{"both": {}, "static-only": {Percentage: 100}, PostCache: {}},
+		Remote: remote,
+	})
+	assert.False(t, service.For("user1").Enabled("remote-only"), "До Start удалённый источник не прочитан")
+
+	service.Start()
+	defer service.Close()
+	evaluation := service.For("user1")
+	assert.True(t, evaluation.Enabled("remote-only"))
+	assert.True(t, evaluation.Enabled("both"), "Удалённый источник заменяет флаги конфигурации")
+	assert.True(t, evaluation.Enabled("static-only"))
+	assert.False(t, evaluation.Enabled(PostCache), "Конфигурация заменяет Defaults")
+	assert.False(t, evaluation.Enabled("unknown"))
+
+	remote.flags = map[string]Flag{}
+	require.NoError(t, service.Refresh(context.Background()))
+	assert.True(t, evaluation.Enabled("both"), "В пределах запроса значение не меняется")
+	assert.False(t, service.For("user1").Enabled("both"))
+
+	remote.err = fmt.Errorf("unavailable")
+	assert.Error(t, service.Refresh(context.Background()))
+	assert.True(t, service.For("user1").Enabled("static-only"))
+}
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"flags":{"related-recency":{"percentage":25,"users":["user1"]}}}`))
+	}))
+	defer server.Close()
+
+	got, err := HTTPProvider{URL: server.URL}.Flags(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Flag{RelatedRecency: {Percentage: 25, Users: []string{"user1"}}}, got)
+
+	_, err = HTTPProvider{URL: server.URL + "/missing\x7f"}.Flags(context.Background())
+	assert.Error(t, err)
+}
+
+func TestEnabled(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, Enabled(ctx, PostCache), "Без флагов запроса действуют Defaults")
+	assert.False(t, Enabled(ctx, RelatedRecency))
+
+	service := New(Options{Static: map[string]Flag{RelatedRecency: {Users: []string{"user1"}}}})
+	assert.True(t, Enabled(NewContext(ctx, service.For("user1")), RelatedRecency))
+	assert.False(t, Enabled(NewContext(ctx, service.For("user2")), RelatedRecency))
+}
+
+func TestEnabledForTenant(t *testing.T) {
+	service := New(Options{Static: map[string]Flag{RankingControversial: {Tenants: []string{"forum"}}}})
+	assert.True(t, service.ForTenant("", "forum").Enabled(RankingControversial), "Флаг сообщества включается и анонимным запросам")
+	assert.True(t, service.ForTenant("user1", "forum").Enabled(RankingControversial))
+	assert.False(t, service.ForTenant("user1", "other").Enabled(RankingControversial))
+	assert.False(t, service.For("user1").Enabled(RankingControversial))
+}
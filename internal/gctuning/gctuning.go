@@ -0,0 +1,138 @@
+// Package gctuning настраивает сборщик мусора без пересборки сервера (GOGC, GOMEMLIMIT, балласт кучи)
+// и оценивает, сколько пауз GC пришлось на каждый запрос. Паузы считываются из runtime/metrics
+// периодически, поэтому пауза приписывается запросам, которые выполнялись в момент её обнаружения
+package gctuning
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"runtime/debug"
+	runtimemetrics "runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+)
+
+// pauseMetric - гистограмма пауз, в которые сборщик мусора останавливал программу
+const pauseMetric = "/sched/pauses/total/gc:seconds"
+
+// Options - настройки сборщика мусора; нулевые значения оставляют настройки окружения
+type Options struct {
+	// GCPercent - GOGC: рост кучи в процентах, после которого запускается сборка; -1 отключает сборку
+	// до достижения MemoryLimit
+	GCPercent int
+	// MemoryLimit - GOMEMLIMIT в байтах: мягкий предел памяти, при приближении к которому сборка учащается
+	MemoryLimit int64
+	// BallastBytes - размер балласта: выделенной, но не используемой памяти, которая увеличивает кучу
+	// и реже запускает сборку при маленькой рабочей куче
+	BallastBytes int64
+	// SampleInterval - период чтения пауз GC; 0 отключает учёт пауз в запросах
+	SampleInterval time.Duration
+}
+
+// Tuner держит балласт и учитывает паузы GC
+type Tuner struct {
+	opts    Options
+	ballast []byte
+	// pauses - оценка суммарной длительности пауз GC в наносекундах с момента Apply
+	pauses atomic.Int64
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Apply применяет настройки к процессу и запускает учёт пауз
+func Apply(opts Options) *Tuner {
+	log.Printf("Настройка сборщика мусора: GOGC=%d, GOMEMLIMIT=%d, балласт=%d байт, период учёта пауз=%v",
+		opts.GCPercent, opts.MemoryLimit, opts.BallastBytes, opts.SampleInterval)
+	if opts.GCPercent != 0 {
+		debug.SetGCPercent(opts.GCPercent)
+	}
+	if opts.MemoryLimit > 0 {
+		debug.SetMemoryLimit(opts.MemoryLimit)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Tuner{opts: opts, cancel: cancel, done: make(chan struct{})}
+	if opts.BallastBytes > 0 {
+		// Балласт не заполняется, поэтому страницы не попадают в резидентную память
+		t.ballast = make([]byte, opts.BallastBytes)
+	}
+	metrics.GCBallastBytes.Set(float64(len(t.ballast)))
+	if opts.SampleInterval > 0 {
+		go t.sample(ctx)
+	} else {
+		close(t.done)
+	}
+	return t
+}
+
+// Close останавливает учёт пауз и освобождает балласт
+func (t *Tuner) Close() {
+	t.cancel()
+	<-t.done
+	t.ballast = nil
+	metrics.GCBallastBytes.Set(0)
+}
+
+// sample периодически добавляет к pauses паузы GC, случившиеся с прошлого чтения
+func (t *Tuner) sample(ctx context.Context) {
+	defer close(t.done)
+	ticker := time.NewTicker(t.opts.SampleInterval)
+	defer ticker.Stop()
+	samples := []runtimemetrics.Sample{{Name: pauseMetric}}
+	var previous []uint64
+	for {
+		runtimemetrics.Read(samples)
+		if samples[0].Value.Kind() == runtimemetrics.KindFloat64Histogram {
+			histogram := samples[0].Value.Float64Histogram()
+			if previous != nil {
+				t.pauses.Add(int64(pauseDelta(histogram, previous) * float64(time.Second)))
+			}
+			previous = append(previous[:0], histogram.Counts...)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pauseDelta оценивает суммарную длительность пауз, добавившихся в гистограмму после previous:
+// каждая пауза считается равной середине своего интервала
+func pauseDelta(histogram *runtimemetrics.Float64Histogram, previous []uint64) float64 {
+	var total float64
+	for i, count := range histogram.Counts {
+		if i >= len(previous) || count <= previous[i] {
+			continue
+		}
+		low, high := histogram.Buckets[i], histogram.Buckets[i+1]
+		if math.IsInf(low, -1) {
+			low = 0
+		}
+		if math.IsInf(high, 1) {
+			high = low
+		}
+		total += float64(count-previous[i]) * (low + high) / 2
+	}
+	return total
+}
+
+// Middleware учитывает в http_request_gc_pause_seconds паузы GC, пришедшиеся на запрос.
+// WebSocket-соединения живут долго и не учитываются
+func (t *Tuner) Middleware(next http.Handler) http.Handler {
+	if t == nil || t.opts.SampleInterval <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := t.pauses.Load()
+		next.ServeHTTP(w, r)
+		metrics.RequestGCPause.Observe(time.Duration(t.pauses.Load() - start).Seconds())
+	})
+}
@@ -0,0 +1,53 @@
+package gctuning
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	runtimemetrics "runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseDelta(t *testing.T) {
+	histogram := &runtimemetrics.Float64Histogram{
+		Counts:  []uint64{1, 3, 2, 1},
+		Buckets: []float64{math.Inf(-1), 0.001, 0.003, 0.01, math.Inf(1)},
+	}
+	// Добавились две паузы по ~2 мс и одна длиннее 10 мс, которая считается равной 10 мс
+	assert.InDelta(t, 0.014, pauseDelta(histogram, []uint64{1, 1, 2, 0}), 1e-9)
+	assert.Zero(t, pauseDelta(histogram, histogram.Counts))
+}
+
+// pauseSum возвращает сумму наблюдений http_request_gc_pause_seconds
+func pauseSum(t *testing.T) float64 {
+	var m dto.Metric
+	require.NoError(t, metrics.RequestGCPause.Write(&m))
+	return m.GetHistogram().GetSampleSum()
+}
+
+func TestMiddleware(t *testing.T) {
+	tuner := Apply(Options{BallastBytes: 1 << 20, SampleInterval: time.Hour})
+	assert.Equal(t, float64(1<<20), testutil.ToFloat64(metrics.GCBallastBytes))
+	handler := tuner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tuner.pauses.Add(int64(2 * time.Millisecond))
+	}))
+
+	before := pauseSum(t)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/query", nil))
+	assert.InDelta(t, 0.002, pauseSum(t)-before, 1e-9)
+
+	upgrade := httptest.NewRequest(http.MethodGet, "/query", nil)
+	upgrade.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), upgrade)
+	assert.InDelta(t, 0.002, pauseSum(t)-before, 1e-9, "WebSocket-соединения не учитываются")
+
+	tuner.Close()
+	assert.Zero(t, testutil.ToFloat64(metrics.GCBallastBytes))
+}
@@ -0,0 +1,94 @@
+package gqlerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Коды ошибок, возвращаемые клиентам в extensions.code
+const (
+	CodeBadUserInput    = "BAD_USER_INPUT"
+	CodeUnauthenticated = "UNAUTHENTICATED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeNotFound        = "NOT_FOUND"
+	CodeInternal        = "INTERNAL"
+	CodeQuotaExceeded   = "QUOTA_EXCEEDED"
+	CodeContentBlocked  = "CONTENT_BLOCKED"
+	CodeMaintenance     = "MAINTENANCE"
+	CodeBudgetExhausted = "BUDGET_EXHAUSTED"
+	CodePartial         = "PARTIAL"
+	CodeDuplicate       = "DUPLICATE"
+)
+
+// Error - ошибка резолвера с кодом для клиента
+type Error struct {
+	Code string
+	Err  error
+	// Extensions - дополнительные поля для extensions ответа помимо code
+	Extensions map[string]interface{}
+}
+
+// Error возвращает текст исходной ошибки
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap возвращает исходную ошибку
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New создаёт ошибку с кодом
+func New(code string, message string) error {
+	return &Error{Code: code, Err: errors.New(message)}
+}
+
+// Errorf создаёт ошибку с кодом по формату
+func Errorf(code string, format string, args ...interface{}) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// WithExtensions создаёт ошибку с кодом и дополнительными полями extensions
+func WithExtensions(code string, err error, extensions map[string]interface{}) error {
+	return &Error{Code: code, Err: err, Extensions: extensions}
+}
+
+// Code возвращает код ошибки или INTERNAL, если код не задан
+func Code(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// Presenter преобразует ошибку резолвера в gqlerror с кодом в extensions
+func Presenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	var e *Error
+	if errors.As(err, &e) {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		for key, value := range e.Extensions {
+			gqlErr.Extensions[key] = value
+		}
+		gqlErr.Extensions["code"] = e.Code
+	}
+	return gqlErr
+}
+
+// AddFieldError добавляет ошибку к текущему полю, не прерывая выполнение запроса.
+// Резолвер после этого возвращает частичные данные вместо ошибки.
+func AddFieldError(ctx context.Context, code string, err error) {
+	if graphql.GetFieldContext(ctx) == nil {
+		log.Printf("Контекст поля отсутствует, ошибка не добавлена в ответ: %v", err)
+		return
+	}
+	graphql.AddError(ctx, &Error{Code: code, Err: err})
+}
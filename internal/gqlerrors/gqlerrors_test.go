@@ -0,0 +1,56 @@
+package gqlerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestCode(t *testing.T) {
+	err := New(CodeBadUserInput, "title exceeds 200 characters")
+	assert.Equal(t, CodeBadUserInput, Code(err))
+	assert.Equal(t, "title exceeds 200 characters", err.Error())
+
+	wrapped := fmt.Errorf("обёртка: %w", Errorf(CodeNotFound, "post %s not found", "1"))
+	assert.Equal(t, CodeNotFound, Code(wrapped))
+
+	assert.Equal(t, CodeInternal, Code(errors.New("неизвестная ошибка")))
+}
+
+func TestPresenter(t *testing.T) {
+	gqlErr := Presenter(context.Background(), New(CodeForbidden, "comments are disabled for this post"))
+	assert.Equal(t, "comments are disabled for this post", gqlErr.Message)
+	assert.Equal(t, CodeForbidden, gqlErr.Extensions["code"])
+
+	withExt := Presenter(context.Background(), WithExtensions(CodeQuotaExceeded, errors.New("quota exceeded"), map[string]interface{}{"resetAt": "2026-01-01T00:00:00Z"}))
+	assert.Equal(t, CodeQuotaExceeded, withExt.Extensions["code"])
+	assert.Equal(t, "2026-01-01T00:00:00Z", withExt.Extensions["resetAt"])
+
+	plain := Presenter(context.Background(), errors.New("ошибка"))
+	assert.Nil(t, plain.Extensions["code"])
+}
+
+func TestAddFieldError(t *testing.T) {
+	ctx := graphql.WithResponseContext(context.Background(), Presenter, nil)
+	ctx = graphql.WithFieldContext(ctx, &graphql.FieldContext{
+		Field: graphql.CollectedField{Field: &ast.Field{Alias: "comments"}},
+	})
+
+	AddFieldError(ctx, CodeInternal, errors.New("failed to load comments"))
+
+	errs := graphql.GetErrors(ctx)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "failed to load comments", errs[0].Message)
+	assert.Equal(t, CodeInternal, errs[0].Extensions["code"])
+	assert.Equal(t, ast.Path{ast.PathName("comments")}, errs[0].Path)
+
+	// Без контекста поля ошибка только логируется
+	assert.NotPanics(t, func() {
+		AddFieldError(context.Background(), CodeInternal, errors.New("ошибка"))
+	})
+}
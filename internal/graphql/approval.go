@@ -0,0 +1,124 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// newApprovalStatus возвращает статус премодерации нового комментария к посту; комментарии модераторов
+// публикуются сразу
+func newApprovalStatus(ctx context.Context, post *models.Post) string {
+	if !post.RequireApproval {
+		return ""
+	}
+	if role, _ := ctx.Value("role").(string); role == roleModerator {
+		return models.ApprovalApproved
+	}
+	return models.ApprovalPending
+}
+
+// toApprovalStatus возвращает значение ApprovalStatus или nil для комментария без премодерации
+func toApprovalStatus(status string) *ApprovalStatus {
+	if status == "" {
+		return nil
+	}
+	value := ApprovalStatus(status)
+	return &value
+}
+
+// SetPostRequireApproval реализует мутацию setPostRequireApproval
+func (r *mutationResolver) SetPostRequireApproval(ctx context.Context, postID string, required bool) (*Post, error) {
+	log.Printf("Запуск мутации setPostRequireApproval: postID=%s, required=%t", postID, required)
+	post, err := r.editablePost(ctx, postID, "failed to set post approval")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Storage.SetPostRequireApproval(ctx, postID, required); err != nil {
+		log.Printf("Ошибка при изменении премодерации поста %s: %v", postID, err)
+		return nil, categoryError("failed to set post approval", err)
+	}
+	updated := *post
+	updated.RequireApproval = required
+	return toPost(&updated), nil
+}
+
+// PendingComments реализует запрос pendingComments
+func (r *queryResolver) PendingComments(ctx context.Context, postID *string, limit int) ([]*Comment, error) {
+	log.Printf("Запрос pendingComments: postID=%v, limit=%d", postID, limit)
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	filter := ""
+	if postID != nil {
+		filter = *postID
+	}
+	comments, err := r.Storage.ListPendingComments(ctx, filter, limit)
+	if err != nil {
+		log.Printf("Ошибка при получении комментариев на премодерации: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to list pending comments: %v", err)
+	}
+	result := make([]*Comment, len(comments))
+	for i := range comments {
+		result[i] = toComment(&comments[i])
+	}
+	return result, nil
+}
+
+// ApproveComment реализует мутацию approveComment
+func (r *mutationResolver) ApproveComment(ctx context.Context, commentID string) (*Comment, error) {
+	log.Printf("Запуск мутации approveComment: commentID=%s", commentID)
+	return r.reviewComment(ctx, commentID, true)
+}
+
+// RejectComment реализует мутацию rejectComment
+func (r *mutationResolver) RejectComment(ctx context.Context, commentID string) (*Comment, error) {
+	log.Printf("Запуск мутации rejectComment: commentID=%s", commentID)
+	return r.reviewComment(ctx, commentID, false)
+}
+
+// reviewComment сохраняет решение модератора по комментарию с премодерацией. Решение можно изменить;
+// ставший видимым комментарий публикуется подписчикам поста, как новый
+func (r *mutationResolver) reviewComment(ctx context.Context, commentID string, approve bool) (*Comment, error) {
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	comment, err := r.Storage.GetComment(ctx, commentID)
+	if err != nil {
+		log.Printf("Ошибка при получении комментария %s: %v", commentID, err)
+		return nil, commentError("failed to review comment", err)
+	}
+	if comment.ApprovalStatus == "" {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "comment does not require approval")
+	}
+	status, hidden := models.ApprovalRejected, true
+	if approve {
+		status = models.ApprovalApproved
+		// Одобрение не открывает комментарий автора под теневым баном и спам
+		hidden, err = r.Storage.IsShadowBanned(ctx, comment.AuthorID)
+		if err != nil {
+			log.Printf("Ошибка при проверке теневого бана пользователя %s: %v", comment.AuthorID, err)
+			return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to review comment: %v", err)
+		}
+		hidden = hidden || comment.SpamStatus == models.SpamStatusSpam
+	}
+	if err := r.Storage.SetApprovalStatus(ctx, commentID, status, hidden); err != nil {
+		log.Printf("Ошибка при сохранении статуса премодерации комментария %s: %v", commentID, err)
+		return nil, commentError("failed to review comment", err)
+	}
+	wasHidden := comment.Hidden
+	updated := *comment
+	updated.ApprovalStatus, updated.Hidden = status, hidden
+	result := toComment(&updated)
+	if wasHidden && !hidden {
+		r.SubscriptionHandler.publish(comment.PostID, result)
+		if r.Push != nil {
+			if post, err := r.Storage.GetPost(ctx, comment.PostID); err == nil {
+				r.Push.CommentCreated(post, &updated)
+			}
+		}
+	}
+	return result, nil
+}
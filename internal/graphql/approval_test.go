@@ -0,0 +1,95 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentApproval(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("author", "")
+	commenter := userContext("user1", "")
+	reader := userContext("reader", "")
+	mod := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, post.RequireApproval)
+
+	_, err = mutation.SetPostRequireApproval(commenter, post.ID, true)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err), "Премодерацию включает автор поста или модератор")
+	post, err = mutation.SetPostRequireApproval(author, post.ID, true)
+	require.NoError(t, err)
+	assert.True(t, post.RequireApproval)
+
+	ctx, cancel := context.WithCancel(reader)
+	defer cancel()
+	events, err := resolver.Subscription().CommentAdded(ctx, post.ID, nil, nil)
+	require.NoError(t, err)
+
+	first, err := mutation.CreateComment(commenter, post.ID, nil, "Первый", nil, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, first.ApprovalStatus)
+	assert.Equal(t, ApprovalStatusPending, *first.ApprovalStatus)
+	second, err := mutation.CreateComment(commenter, post.ID, nil, "Второй", nil, nil, nil)
+	require.NoError(t, err)
+	own, err := mutation.CreateComment(mod, post.ID, nil, "От модератора", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ApprovalStatusApproved, *own.ApprovalStatus, "Комментарии модераторов публикуются сразу")
+	assert.Equal(t, own.ID, (<-events).ID, "Подписчики не получают комментарии на премодерации")
+
+	visible, err := store.GetComments(viewerContext(reader), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	require.Len(t, visible.Comments, 1)
+	mine, err := store.GetComments(viewerContext(commenter), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	assert.Len(t, mine.Comments, 3, "Автор видит свои комментарии на премодерации")
+
+	pending, err := resolver.Query().PendingComments(mod, &post.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, first.ID, pending[0].ID)
+	_, err = resolver.Query().PendingComments(commenter, nil, 10)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+
+	approved, err := mutation.ApproveComment(mod, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ApprovalStatusApproved, *approved.ApprovalStatus)
+	assert.Equal(t, first.ID, (<-events).ID, "Одобренный комментарий получают подписчики")
+	rejected, err := mutation.RejectComment(mod, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ApprovalStatusRejected, *rejected.ApprovalStatus)
+	select {
+	case comment := <-events:
+		t.Fatalf("Отклонённый комментарий не публикуется: %s", comment.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	visible, err = store.GetComments(viewerContext(reader), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	assert.Len(t, visible.Comments, 2)
+	pending, err = resolver.Query().PendingComments(mod, nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	_, err = mutation.ApproveComment(commenter, second.ID)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	_, err = mutation.ApproveComment(mod, "missing")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	// После отключения премодерации новые комментарии публикуются без статуса
+	_, err = mutation.SetPostRequireApproval(mod, post.ID, false)
+	require.NoError(t, err)
+	plain, err := mutation.CreateComment(commenter, post.ID, nil, "Без премодерации", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, plain.ApprovalStatus)
+	_, err = mutation.ApproveComment(mod, plain.ID)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
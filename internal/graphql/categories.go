@@ -0,0 +1,99 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// maxCategoryNameLength - максимальная длина названия категории в символах
+const maxCategoryNameLength = 100
+
+// Categories реализует запрос categories
+func (r *queryResolver) Categories(ctx context.Context) ([]*Category, error) {
+	log.Println("Запрос categories")
+	categories, err := r.Storage.ListCategories(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении категорий: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to list categories: %v", err)
+	}
+	return categoryTree(categories), nil
+}
+
+// CreateCategory реализует мутацию createCategory
+func (r *mutationResolver) CreateCategory(ctx context.Context, name string, parentID *string) (*Category, error) {
+	log.Printf("Запуск мутации createCategory: name=%s, parentID=%v", name, parentID)
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "category name is empty")
+	}
+	if utf8.RuneCountInString(name) > maxCategoryNameLength {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "category name exceeds %d characters", maxCategoryNameLength)
+	}
+	category := &models.Category{
+		ID:        r.IDs.New(),
+		Name:      name,
+		ParentID:  parentID,
+		CreatedAt: r.Clock.Now(),
+	}
+	if err := r.Storage.CreateCategory(ctx, category); err != nil {
+		log.Printf("Ошибка при создании категории: %v", err)
+		return nil, categoryError("failed to create category", err)
+	}
+	log.Printf("Категория создана: ID=%s, Path=%s", category.ID, category.Path)
+	return &Category{ID: category.ID, Name: category.Name, ParentID: category.ParentID, Children: []*Category{}}, nil
+}
+
+// SetPostCategory реализует мутацию setPostCategory
+func (r *mutationResolver) SetPostCategory(ctx context.Context, postID string, categoryID *string) (*Post, error) {
+	log.Printf("Запуск мутации setPostCategory: postID=%s, categoryID=%v", postID, categoryID)
+	post, err := r.editablePost(ctx, postID, "failed to set post category")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Storage.SetPostCategory(ctx, postID, categoryID); err != nil {
+		log.Printf("Ошибка при изменении категории поста %s: %v", postID, err)
+		return nil, categoryError("failed to set post category", err)
+	}
+	r.invalidateRelated(postID)
+	updated := *post
+	updated.CategoryID = categoryID
+	return toPost(&updated), nil
+}
+
+// categoryTree собирает дерево категорий из списка в порядке создания; дети сохраняют этот порядок
+func categoryTree(categories []models.Category) []*Category {
+	nodes := make(map[string]*Category, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &Category{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Children: []*Category{}}
+	}
+	roots := []*Category{}
+	for _, c := range categories {
+		node := nodes[c.ID]
+		if c.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		if parent, ok := nodes[*c.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return roots
+}
+
+// categoryError возвращает NOT_FOUND для отсутствующих поста или категории, иначе INTERNAL
+func categoryError(message string, err error) error {
+	if errors.Is(err, storage.ErrCategoryNotFound) || errors.Is(err, storage.ErrPostNotFound) {
+		return gqlerrors.Errorf(gqlerrors.CodeNotFound, "%s: %v", message, err)
+	}
+	return gqlerrors.Errorf(gqlerrors.CodeInternal, "%s: %v", message, err)
+}
@@ -0,0 +1,99 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategories(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	mod := userContext("mod1", "moderator")
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+
+	_, err := mutation.CreateCategory(user, "Наука", nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	_, err = mutation.CreateCategory(mod, "  ", nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	missing := "missing"
+	_, err = mutation.CreateCategory(mod, "Сирота", &missing)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	science, err := mutation.CreateCategory(mod, "Наука", nil)
+	require.NoError(t, err)
+	physics, err := mutation.CreateCategory(mod, "Физика", &science.ID)
+	require.NoError(t, err)
+	_, err = mutation.CreateCategory(mod, "Оптика", &physics.ID)
+	require.NoError(t, err)
+	_, err = mutation.CreateCategory(mod, "Спорт", nil)
+	require.NoError(t, err)
+
+	tree, err := resolver.Query().Categories(user)
+	require.NoError(t, err)
+	require.Len(t, tree, 2)
+	assert.Equal(t, "Наука", tree[0].Name)
+	assert.Equal(t, "Спорт", tree[1].Name)
+	assert.Empty(t, tree[1].Children)
+	require.Len(t, tree[0].Children, 1)
+	assert.Equal(t, "Физика", tree[0].Children[0].Name)
+	require.Len(t, tree[0].Children[0].Children, 1)
+	assert.Equal(t, "Оптика", tree[0].Children[0].Children[0].Name)
+}
+
+func TestPosts_CategoryFilter(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	mod := userContext("mod1", "moderator")
+	author := userContext("user2", "")
+	other := userContext("user1", "")
+	mutation := resolver.Mutation()
+	science, err := mutation.CreateCategory(mod, "Наука", nil)
+	require.NoError(t, err)
+	physics, err := mutation.CreateCategory(mod, "Физика", &science.ID)
+	require.NoError(t, err)
+
+	missing := "missing"
+	_, err = mutation.CreatePost(author, "Пост", "Содержимое", true, nil, &missing, nil, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	inScience, err := mutation.CreatePost(author, "Наука", "Содержимое", true, nil, &science.ID, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &science.ID, inScience.CategoryID)
+	inPhysics, err := mutation.CreatePost(author, "Физика", "Содержимое", true, nil, &physics.ID, nil, nil)
+	require.NoError(t, err)
+	uncategorized, err := mutation.CreatePost(author, "Без категории", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	page, err := resolver.Query().Posts(other, 10, nil, &science.ID, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 2, "По умолчанию учитываются подкатегории")
+	assert.Equal(t, inPhysics.ID, page.Posts[0].ID)
+	assert.Equal(t, inScience.ID, page.Posts[1].ID)
+	only := false
+	page, err = resolver.Query().Posts(other, 10, nil, &science.ID, &only, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 1)
+	assert.Equal(t, inScience.ID, page.Posts[0].ID)
+	_, err = resolver.Query().Posts(other, 10, nil, &missing, nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	_, err = mutation.SetPostCategory(other, uncategorized.ID, &physics.ID)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err), "Категорию меняет только автор или модератор")
+	updated, err := mutation.SetPostCategory(author, uncategorized.ID, &physics.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &physics.ID, updated.CategoryID)
+	_, err = mutation.SetPostCategory(mod, uncategorized.ID, &missing)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	updated, err = mutation.SetPostCategory(mod, inScience.ID, nil)
+	require.NoError(t, err)
+	assert.Nil(t, updated.CategoryID)
+
+	page, err = resolver.Query().Posts(other, 10, nil, &science.ID, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 2)
+	assert.Equal(t, uncategorized.ID, page.Posts[0].ID)
+	assert.Equal(t, inPhysics.ID, page.Posts[1].ID)
+}
@@ -0,0 +1,257 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+const (
+	// maxCollectionPosts - наибольшее число постов в серии
+	maxCollectionPosts = 100
+	// maxCollectionTitleLength - максимальная длина названия серии в символах
+	maxCollectionTitleLength = 200
+	// maxCollectionDescriptionLength - максимальная длина описания серии в символах
+	maxCollectionDescriptionLength = 2000
+)
+
+// Collection реализует запрос collection
+func (r *queryResolver) Collection(ctx context.Context, id string) (*Collection, error) {
+	log.Printf("Запрос collection с ID=%s", id)
+	collection, err := r.Storage.GetCollection(ctx, id)
+	if err != nil {
+		log.Printf("Ошибка при получении серии %s: %v", id, err)
+		return nil, collectionError("failed to get collection", err)
+	}
+	return toCollection(collection), nil
+}
+
+// Posts реализует поле posts в Collection
+func (r *collectionResolver) Posts(ctx context.Context, obj *Collection) ([]*Post, error) {
+	collection, err := r.Storage.GetCollection(ctx, obj.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении постов серии %s: %v", obj.ID, err)
+		return nil, collectionError("failed to load collection posts", err)
+	}
+	viewerID, _ := ctx.Value("userID").(string)
+	posts := make([]*models.Post, 0, len(collection.PostIDs))
+	for _, postID := range collection.PostIDs {
+		post, err := storage.ViewPost(ctx, r.Storage, postID, viewerID)
+		if errors.Is(err, storage.ErrPostNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Ошибка при получении поста %s серии %s: %v", postID, obj.ID, err)
+			return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to load collection posts: %v", err)
+		}
+		posts = append(posts, post)
+	}
+	return convertPosts(ctx, posts), nil
+}
+
+// Collections реализует поле collections в Post
+func (r *postResolver) Collections(ctx context.Context, obj *Post) ([]*Collection, error) {
+	collections, err := r.Storage.ListPostCollections(ctx, obj.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении серий поста %s: %v", obj.ID, err)
+		// Пост отображается и без серий: ошибка уходит в errors, поле получает пустой список
+		gqlerrors.AddFieldError(ctx, gqlerrors.CodeInternal, fmt.Errorf("failed to load collections: %v", err))
+		return []*Collection{}, nil
+	}
+	result := make([]*Collection, len(collections))
+	for i := range collections {
+		result[i] = toCollection(&collections[i])
+	}
+	return result, nil
+}
+
+// CreateCollection реализует мутацию createCollection
+func (r *mutationResolver) CreateCollection(ctx context.Context, title string, description *string) (*Collection, error) {
+	log.Printf("Запуск мутации createCollection: title=%s", title)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	now := r.Clock.Now()
+	collection := &models.Collection{
+		ID:        r.IDs.New(),
+		AuthorID:  userID,
+		PostIDs:   []string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := setCollectionText(collection, &title, description); err != nil {
+		return nil, err
+	}
+	if err := r.Storage.CreateCollection(ctx, collection); err != nil {
+		log.Printf("Ошибка при создании серии: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to create collection: %v", err)
+	}
+	log.Printf("Серия %s создана пользователем %s", collection.ID, userID)
+	return toCollection(collection), nil
+}
+
+// UpdateCollection реализует мутацию updateCollection
+func (r *mutationResolver) UpdateCollection(ctx context.Context, id string, title *string, description *string) (*Collection, error) {
+	log.Printf("Запуск мутации updateCollection: id=%s", id)
+	return r.changeCollection(ctx, id, "failed to update collection", func(collection *models.Collection) error {
+		return setCollectionText(collection, title, description)
+	})
+}
+
+// DeleteCollection реализует мутацию deleteCollection
+func (r *mutationResolver) DeleteCollection(ctx context.Context, id string) (bool, error) {
+	log.Printf("Запуск мутации deleteCollection: id=%s", id)
+	if _, err := r.editableCollection(ctx, id, "failed to delete collection"); err != nil {
+		return false, err
+	}
+	if err := r.Storage.DeleteCollection(ctx, id); err != nil {
+		log.Printf("Ошибка при удалении серии %s: %v", id, err)
+		return false, collectionError("failed to delete collection", err)
+	}
+	return true, nil
+}
+
+// AddPostToCollection реализует мутацию addPostToCollection
+func (r *mutationResolver) AddPostToCollection(ctx context.Context, collectionID string, postID string, position *int) (*Collection, error) {
+	log.Printf("Запуск мутации addPostToCollection: collectionID=%s, postID=%s, position=%v", collectionID, postID, position)
+	return r.changeCollection(ctx, collectionID, "failed to add post to collection", func(collection *models.Collection) error {
+		if slices.Contains(collection.PostIDs, postID) {
+			return gqlerrors.New(gqlerrors.CodeBadUserInput, "post is already in the collection")
+		}
+		if len(collection.PostIDs) >= maxCollectionPosts {
+			return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "at most %d posts are allowed in a collection", maxCollectionPosts)
+		}
+		index := len(collection.PostIDs)
+		if position != nil {
+			if *position < 1 || *position > len(collection.PostIDs)+1 {
+				return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "position must be between 1 and %d", len(collection.PostIDs)+1)
+			}
+			index = *position - 1
+		}
+		viewerID, _ := ctx.Value("userID").(string)
+		post, err := storage.ViewPost(ctx, r.Storage, postID, viewerID)
+		if err != nil {
+			log.Printf("Ошибка при получении поста с ID=%s: %v", postID, err)
+			return categoryError("failed to add post to collection", err)
+		}
+		if post.AuthorID != collection.AuthorID {
+			return gqlerrors.New(gqlerrors.CodeForbidden, "only posts of the collection author can be added")
+		}
+		collection.PostIDs = slices.Insert(collection.PostIDs, index, postID)
+		return nil
+	})
+}
+
+// RemovePostFromCollection реализует мутацию removePostFromCollection
+func (r *mutationResolver) RemovePostFromCollection(ctx context.Context, collectionID string, postID string) (*Collection, error) {
+	log.Printf("Запуск мутации removePostFromCollection: collectionID=%s, postID=%s", collectionID, postID)
+	return r.changeCollection(ctx, collectionID, "failed to remove post from collection", func(collection *models.Collection) error {
+		index := slices.Index(collection.PostIDs, postID)
+		if index < 0 {
+			return gqlerrors.New(gqlerrors.CodeNotFound, "post is not in the collection")
+		}
+		collection.PostIDs = slices.Delete(collection.PostIDs, index, index+1)
+		return nil
+	})
+}
+
+// ReorderCollection реализует мутацию reorderCollection
+func (r *mutationResolver) ReorderCollection(ctx context.Context, collectionID string, postIDs []string) (*Collection, error) {
+	log.Printf("Запуск мутации reorderCollection: collectionID=%s, postIDs=%v", collectionID, postIDs)
+	return r.changeCollection(ctx, collectionID, "failed to reorder collection", func(collection *models.Collection) error {
+		current := slices.Sorted(slices.Values(collection.PostIDs))
+		requested := slices.Sorted(slices.Values(postIDs))
+		if !slices.Equal(current, requested) {
+			return gqlerrors.New(gqlerrors.CodeBadUserInput, "postIds must list every post of the collection exactly once")
+		}
+		collection.PostIDs = slices.Clone(postIDs)
+		return nil
+	})
+}
+
+// changeCollection применяет change к серии, которую текущий пользователь может изменять, и сохраняет её
+func (r *mutationResolver) changeCollection(ctx context.Context, id, message string, change func(collection *models.Collection) error) (*Collection, error) {
+	collection, err := r.editableCollection(ctx, id, message)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(collection); err != nil {
+		return nil, err
+	}
+	collection.UpdatedAt = r.Clock.Now()
+	if err := r.Storage.UpdateCollection(ctx, collection); err != nil {
+		log.Printf("Ошибка при изменении серии %s: %v", id, err)
+		return nil, collectionError(message, err)
+	}
+	return toCollection(collection), nil
+}
+
+// editableCollection возвращает серию, которую текущий пользователь может изменять: автор или модератор
+func (r *mutationResolver) editableCollection(ctx context.Context, id, message string) (*models.Collection, error) {
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	collection, err := r.Storage.GetCollection(ctx, id)
+	if err != nil {
+		log.Printf("Ошибка при получении серии %s: %v", id, err)
+		return nil, collectionError(message, err)
+	}
+	if collection.AuthorID != userID {
+		if err := requireModerator(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return collection, nil
+}
+
+// setCollectionText проверяет и задаёт заданные название и описание серии
+func setCollectionText(collection *models.Collection, title, description *string) error {
+	if title != nil {
+		t := strings.TrimSpace(*title)
+		if t == "" {
+			return gqlerrors.New(gqlerrors.CodeBadUserInput, "collection title is empty")
+		}
+		if utf8.RuneCountInString(t) > maxCollectionTitleLength {
+			return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "collection title exceeds %d characters", maxCollectionTitleLength)
+		}
+		collection.Title = t
+	}
+	if description != nil {
+		d := strings.TrimSpace(*description)
+		if utf8.RuneCountInString(d) > maxCollectionDescriptionLength {
+			return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "collection description exceeds %d characters", maxCollectionDescriptionLength)
+		}
+		collection.Description = d
+	}
+	return nil
+}
+
+// collectionError возвращает NOT_FOUND для отсутствующих серии или поста, иначе INTERNAL
+func collectionError(message string, err error) error {
+	if errors.Is(err, storage.ErrCollectionNotFound) || errors.Is(err, storage.ErrPostNotFound) {
+		return gqlerrors.Errorf(gqlerrors.CodeNotFound, "%s: %v", message, err)
+	}
+	return gqlerrors.Errorf(gqlerrors.CodeInternal, "%s: %v", message, err)
+}
+
+func toCollection(collection *models.Collection) *Collection {
+	return &Collection{
+		ID:          collection.ID,
+		AuthorID:    collection.AuthorID,
+		Title:       collection.Title,
+		Description: collection.Description,
+		CreatedAt:   collection.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   collection.UpdatedAt.Format(time.RFC3339),
+	}
+}
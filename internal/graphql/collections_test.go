@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollections(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user1", "")
+	other := userContext("user2", "")
+	mod := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+	var parts []*Post
+	for _, title := range []string{"Часть 1", "Часть 2", "Часть 3"} {
+		post, err := mutation.CreatePost(author, title, "Содержимое", true, nil, nil, nil, nil)
+		require.NoError(t, err)
+		parts = append(parts, post)
+	}
+	private := PostVisibilityPrivate
+	draft, err := mutation.CreatePost(author, "Черновик", "Содержимое", true, nil, nil, nil, &private)
+	require.NoError(t, err)
+	foreign, err := mutation.CreatePost(other, "Чужой пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = mutation.CreateCollection(userContext("", ""), "Серия", nil)
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = mutation.CreateCollection(author, "  ", nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	description := "Большой текст по частям"
+	collection, err := mutation.CreateCollection(author, " Серия ", &description)
+	require.NoError(t, err)
+	assert.Equal(t, "Серия", collection.Title)
+	assert.Equal(t, "user1", collection.AuthorID)
+
+	titles := func() []string {
+		posts, err := resolver.Collection().Posts(author, collection)
+		require.NoError(t, err)
+		result := []string{}
+		for _, p := range posts {
+			result = append(result, p.Title)
+		}
+		return result
+	}
+	_, err = mutation.AddPostToCollection(author, collection.ID, parts[2].ID, nil)
+	require.NoError(t, err)
+	_, err = mutation.AddPostToCollection(author, collection.ID, parts[1].ID, nil)
+	require.NoError(t, err)
+	second := 2
+	_, err = mutation.AddPostToCollection(author, collection.ID, parts[0].ID, &second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Часть 3", "Часть 1", "Часть 2"}, titles())
+
+	_, err = mutation.AddPostToCollection(author, collection.ID, parts[1].ID, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err), "Пост уже в серии")
+	_, err = mutation.AddPostToCollection(author, collection.ID, foreign.ID, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err), "Чужие посты в серию не добавляются")
+	_, err = mutation.AddPostToCollection(other, collection.ID, foreign.ID, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err), "Чужую серию менять нельзя")
+	five := 5
+	_, err = mutation.AddPostToCollection(author, collection.ID, draft.ID, &five)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	_, err = mutation.ReorderCollection(author, collection.ID, []string{parts[0].ID, parts[1].ID})
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err), "Порядок должен включать все посты")
+	_, err = mutation.ReorderCollection(author, collection.ID, []string{parts[0].ID, parts[1].ID, parts[1].ID})
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	_, err = mutation.ReorderCollection(author, collection.ID, []string{parts[0].ID, parts[1].ID, parts[2].ID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Часть 1", "Часть 2", "Часть 3"}, titles())
+
+	_, err = mutation.AddPostToCollection(author, collection.ID, draft.ID, nil)
+	require.NoError(t, err)
+	posts, err := resolver.Collection().Posts(other, collection)
+	require.NoError(t, err)
+	assert.Len(t, posts, 3, "Недоступные посты пропускаются")
+	assert.Len(t, titles(), 4)
+
+	collections, err := resolver.Post().Collections(other, parts[1])
+	require.NoError(t, err)
+	require.Len(t, collections, 1)
+	assert.Equal(t, collection.ID, collections[0].ID)
+
+	_, err = mutation.RemovePostFromCollection(author, collection.ID, parts[1].ID)
+	require.NoError(t, err)
+	_, err = mutation.RemovePostFromCollection(author, collection.ID, parts[1].ID)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	collections, err = resolver.Post().Collections(other, parts[1])
+	require.NoError(t, err)
+	assert.Empty(t, collections)
+
+	title := "Переименовано модератором"
+	updated, err := mutation.UpdateCollection(mod, collection.ID, &title, nil)
+	require.NoError(t, err)
+	assert.Equal(t, title, updated.Title)
+	assert.Equal(t, description, updated.Description, "Незаданное описание сохраняется")
+
+	ok, err := mutation.DeleteCollection(author, collection.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = resolver.Query().Collection(other, collection.ID)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+}
@@ -0,0 +1,226 @@
+package graphql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/language"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/quota"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// toPost конвертирует пост хранилища в тип GraphQL
+func toPost(p *models.Post) *Post {
+	return fillPost(new(Post), p)
+}
+
+// fillPost заполняет dst постом хранилища и возвращает dst
+func fillPost(dst *Post, p *models.Post) *Post {
+	*dst = Post{
+		ID:              p.ID,
+		Title:           p.Title,
+		Content:         p.Content,
+		Format:          toContentFormat(p.Format),
+		AuthorID:        p.AuthorID,
+		AllowComments:   p.AllowComments,
+		CreatedAt:       p.CreatedAtRFC3339(),
+		Tags:            tagsOrEmpty(p.Tags),
+		CategoryID:      p.CategoryID,
+		Slug:            p.Slug,
+		Visibility:      toPostVisibility(p.Visibility),
+		Language:        languageOrNil(p.Language),
+		RequireApproval: p.RequireApproval,
+		Excerpt:         p.Excerpt,
+	}
+	return dst
+}
+
+// toComment конвертирует комментарий хранилища в тип GraphQL
+func toComment(c *models.Comment) *Comment {
+	return fillComment(new(Comment), c)
+}
+
+// fillComment заполняет dst комментарием хранилища и возвращает dst
+func fillComment(dst *Comment, c *models.Comment) *Comment {
+	*dst = Comment{
+		ID:              c.ID,
+		PostID:          c.PostID,
+		ParentID:        c.ParentID,
+		AuthorID:        models.DisplayAuthorID(c.PostID, c.AuthorID),
+		Content:         c.Content,
+		Format:          toContentFormat(c.Format),
+		CreatedAt:       c.CreatedAtRFC3339(),
+		Tags:            tagsOrEmpty(c.Tags),
+		Upvotes:         c.Upvotes,
+		Downvotes:       c.Downvotes,
+		Score:           c.Upvotes - c.Downvotes,
+		Language:        languageOrNil(c.Language),
+		QuotedCommentID: c.QuotedCommentID,
+		ApprovalStatus:  toApprovalStatus(c.ApprovalStatus),
+	}
+	return dst
+}
+
+// languageOrNil возвращает nil для неизвестного языка
+func languageOrNil(language string) *string {
+	if language == "" {
+		return nil
+	}
+	return &language
+}
+
+// tagsOrEmpty возвращает теги или пустой список для nil
+func tagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// toModerationRule конвертирует правило автомодерации в тип GraphQL
+func toModerationRule(rule *models.ModerationRule) *ModerationRule {
+	result := &ModerationRule{
+		ID:        rule.ID,
+		Kind:      ModerationRuleKind(rule.Kind),
+		Pattern:   rule.Pattern,
+		Action:    ModerationAction(rule.Action),
+		CreatedBy: rule.CreatedBy,
+		CreatedAt: rule.CreatedAt.Format(time.RFC3339),
+	}
+	if rule.Tag != "" {
+		tag := rule.Tag
+		result.Tag = &tag
+	}
+	return result
+}
+
+// fromModerationRuleInput конвертирует аргумент мутации в правило хранилища
+func fromModerationRuleInput(input ModerationRuleInput) *models.ModerationRule {
+	rule := &models.ModerationRule{
+		Kind:    string(input.Kind),
+		Pattern: input.Pattern,
+		Action:  string(input.Action),
+	}
+	if input.Tag != nil {
+		rule.Tag = *input.Tag
+	}
+	return rule
+}
+
+// toContentFormat возвращает формат содержимого, по умолчанию PLAIN
+func toContentFormat(format string) ContentFormat {
+	if format == "" {
+		return ContentFormatPlain
+	}
+	return ContentFormat(format)
+}
+
+// toPostVisibility возвращает видимость поста, по умолчанию PUBLIC
+func toPostVisibility(visibility string) PostVisibility {
+	if visibility == "" {
+		return PostVisibilityPublic
+	}
+	return PostVisibility(visibility)
+}
+
+// visibilityOrDefault возвращает видимость из аргумента мутации, по умолчанию PUBLIC
+func visibilityOrDefault(visibility *PostVisibility) string {
+	if visibility == nil || !visibility.IsValid() {
+		return models.VisibilityPublic
+	}
+	return string(*visibility)
+}
+
+// formatOrDefault возвращает формат из аргумента мутации, по умолчанию PLAIN
+func formatOrDefault(format *ContentFormat) string {
+	if format == nil || !format.IsValid() {
+		return models.FormatPlain
+	}
+	return string(*format)
+}
+
+// sortOrderOrDefault возвращает порядок сортировки из аргумента, по умолчанию DESC
+func sortOrderOrDefault(order *SortOrder) models.SortOrder {
+	if order == nil || !order.IsValid() {
+		return models.SortDesc
+	}
+	return models.SortOrder(*order)
+}
+
+// toPostFilter переводит условия запроса posts в storage.PostFilter; категория задаётся отдельно
+func toPostFilter(input *PostFilterInput) (storage.PostFilter, error) {
+	var filter storage.PostFilter
+	if input == nil {
+		return filter, nil
+	}
+	var err error
+	if filter.CreatedAfter, err = parseTimeArg("createdAfter", input.CreatedAfter); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = parseTimeArg("createdBefore", input.CreatedBefore); err != nil {
+		return filter, err
+	}
+	if input.Text != nil {
+		filter.Text = *input.Text
+	}
+	if input.AuthorID != nil {
+		filter.AuthorID = *input.AuthorID
+	}
+	if input.Tag != nil {
+		filter.Tag = *input.Tag
+	}
+	filter.HasComments = input.HasComments
+	filter.AllowComments = input.AllowComments
+	if input.Language != nil {
+		lang, ok := language.Normalize(*input.Language)
+		if !ok {
+			return filter, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid language %q", *input.Language)
+		}
+		filter.Language = lang
+	}
+	return filter, nil
+}
+
+// parseTimeArg разбирает необязательный аргумент name со временем в RFC3339
+func parseTimeArg(name string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid %s: %v", name, err)
+	}
+	return &parsed, nil
+}
+
+// commentsErrorCode возвращает BAD_USER_INPUT для ошибок курсора, иначе INTERNAL
+func commentsErrorCode(err error) string {
+	if errors.Is(err, storage.ErrInvalidCursor) || errors.Is(err, storage.ErrCursorOrderMismatch) ||
+		errors.Is(err, storage.ErrOffsetTooLarge) {
+		return gqlerrors.CodeBadUserInput
+	}
+	return gqlerrors.CodeInternal
+}
+
+// quotaError возвращает QUOTA_EXCEEDED с лимитом и временем сброса в extensions для исчерпанной квоты, иначе INTERNAL
+func quotaError(err error) error {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return gqlerrors.WithExtensions(gqlerrors.CodeQuotaExceeded, err, map[string]interface{}{
+			"action":  string(exceeded.Action),
+			"limit":   exceeded.Limit,
+			"resetAt": exceeded.ResetAt.Format(time.RFC3339),
+		})
+	}
+	var storageExceeded *quota.StorageExceededError
+	if errors.As(err, &storageExceeded) {
+		return gqlerrors.WithExtensions(gqlerrors.CodeQuotaExceeded, err, map[string]interface{}{
+			"tenant":   storageExceeded.TenantID,
+			"resource": storageExceeded.Resource,
+			"limit":    storageExceeded.Limit,
+		})
+	}
+	return gqlerrors.Errorf(gqlerrors.CodeInternal, "%v", err)
+}
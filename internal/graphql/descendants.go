@@ -0,0 +1,60 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/partial"
+)
+
+// DescendantCount реализует поле descendantCount в Comment
+func (r *commentResolver) DescendantCount(ctx context.Context, obj *Comment) (*int, error) {
+	if skipPartial(ctx) {
+		return nil, nil
+	}
+	count := r.loadDescendantCount(ctx, obj.ID)
+	return &count, nil
+}
+
+// HasMoreReplies реализует поле hasMoreReplies в Comment
+func (r *commentResolver) HasMoreReplies(ctx context.Context, obj *Comment) (*bool, error) {
+	if skipPartial(ctx) {
+		return nil, nil
+	}
+	more := r.loadDescendantCount(ctx, obj.ID) > 0
+	return &more, nil
+}
+
+// skipPartial пропускает глубокий агрегат, если срок запроса близко: поле получает null и ошибку PARTIAL
+func skipPartial(ctx context.Context) bool {
+	if !partial.Near(ctx) {
+		return false
+	}
+	partial.Skipped(ctx)
+	gqlerrors.AddFieldError(ctx, gqlerrors.CodePartial, errors.New("skipped: request deadline is near"))
+	return true
+}
+
+// loadDescendantCount загружает число ответов через DataLoader из контекста;
+// ошибка загрузки не ломает остальной ответ
+func (r *Resolver) loadDescendantCount(ctx context.Context, commentID string) int {
+	var count int
+	var err error
+	if loader, ok := ctx.Value("descendantLoader").(*DescendantLoader); ok {
+		count, err = loader.Load(ctx, commentID)()
+	} else {
+		log.Println("DescendantLoader не найден в контексте, загрузка напрямую из хранилища")
+		var counts map[string]int
+		counts, err = r.Storage.CountDescendants(ctx, []string{commentID})
+		count = counts[commentID]
+	}
+	if err != nil {
+		log.Printf("Ошибка при подсчёте ответов для %s: %v", commentID, err)
+		gqlerrors.AddFieldError(ctx, gqlerrors.CodeInternal, fmt.Errorf("failed to count replies: %v", err))
+		return 0
+	}
+	return count
+}
@@ -0,0 +1,46 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescendantCount(t *testing.T) {
+	store := new(mockStorage)
+	store.On("CountDescendants", mock.Anything, []string{"c1"}).Return(map[string]int{"c1": 37}, nil)
+	store.On("CountDescendants", mock.Anything, []string{"c2"}).Return(map[string]int{"c2": 0}, nil)
+	r := &commentResolver{&Resolver{Storage: store}}
+	ctx := context.Background()
+
+	count, err := r.DescendantCount(ctx, &Comment{ID: "c1"})
+	require.NoError(t, err)
+	require.NotNil(t, count)
+	assert.Equal(t, 37, *count)
+	more, err := r.HasMoreReplies(ctx, &Comment{ID: "c1"})
+	require.NoError(t, err)
+	require.NotNil(t, more)
+	assert.True(t, *more)
+	more, err = r.HasMoreReplies(ctx, &Comment{ID: "c2"})
+	require.NoError(t, err)
+	require.NotNil(t, more)
+	assert.False(t, *more)
+}
+
+func TestDescendantCount_Loader(t *testing.T) {
+	store := new(mockStorage)
+	store.On("CountDescendants", mock.Anything, []string{"c1"}).Return(nil, errors.New("db down"))
+	r := &commentResolver{&Resolver{Storage: store}}
+	ctx := context.WithValue(context.Background(), "descendantLoader", NewDescendantLoader(store))
+
+	// Ошибка загрузки не ломает ответ: поле получает 0
+	count, err := r.DescendantCount(ctx, &Comment{ID: "c1"})
+	require.NoError(t, err)
+	require.NotNil(t, count)
+	assert.Zero(t, *count)
+	store.AssertExpectations(t)
+}
@@ -0,0 +1,55 @@
+package graphql
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// maxDeviceTokenLength - максимальная длина токена устройства; подписка Web Push занимает около 500 символов
+const maxDeviceTokenLength = 4096
+
+// RegisterDeviceToken реализует мутацию registerDeviceToken
+func (r *mutationResolver) RegisterDeviceToken(ctx context.Context, token string, platform PushPlatform) (bool, error) {
+	log.Printf("Запуск мутации registerDeviceToken: platform=%s", platform)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: регистрация устройства без авторизации")
+		return false, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	if !platform.IsValid() {
+		return false, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid push platform %s", platform)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return false, gqlerrors.New(gqlerrors.CodeBadUserInput, "device token is empty")
+	}
+	if len(token) > maxDeviceTokenLength {
+		return false, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "device token exceeds %d characters", maxDeviceTokenLength)
+	}
+	device := &models.DeviceToken{Token: token, UserID: userID, Platform: string(platform), CreatedAt: r.Clock.Now()}
+	if err := r.Storage.RegisterDeviceToken(ctx, device); err != nil {
+		log.Printf("Ошибка при регистрации устройства пользователя %s: %v", userID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to register device token: %v", err)
+	}
+	log.Printf("Устройство %s зарегистрировано для пользователя %s", platform, userID)
+	return true, nil
+}
+
+// UnregisterDeviceToken реализует мутацию unregisterDeviceToken
+func (r *mutationResolver) UnregisterDeviceToken(ctx context.Context, token string) (bool, error) {
+	log.Println("Запуск мутации unregisterDeviceToken")
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: удаление устройства без авторизации")
+		return false, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	if err := r.Storage.UnregisterDeviceToken(ctx, userID, strings.TrimSpace(token)); err != nil {
+		log.Printf("Ошибка при удалении устройства пользователя %s: %v", userID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to unregister device token: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,88 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/push"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPushProvider запоминает отправленные push-уведомления
+type recordingPushProvider struct {
+	mu   sync.Mutex
+	sent []push.Message
+}
+
+func (p *recordingPushProvider) Send(ctx context.Context, msg push.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, msg)
+	return nil
+}
+
+func TestDeviceTokens(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	mutation := resolver.Mutation()
+	user := userContext("user1", "")
+
+	_, err := mutation.RegisterDeviceToken(context.Background(), "token", PushPlatformFcm)
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = mutation.RegisterDeviceToken(user, "  ", PushPlatformFcm)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	_, err = mutation.RegisterDeviceToken(user, "token", PushPlatform("SMS"))
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	ok, err := mutation.RegisterDeviceToken(user, "token", PushPlatformApns)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	tokens, err := store.ListDeviceTokens(context.Background(), "user1")
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, models.PlatformAPNs, tokens[0].Platform)
+
+	_, err = mutation.UnregisterDeviceToken(userContext("user2", ""), "token")
+	require.NoError(t, err)
+	tokens, err = store.ListDeviceTokens(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Len(t, tokens, 1, "Чужое устройство не удаляется")
+	_, err = mutation.UnregisterDeviceToken(user, "token")
+	require.NoError(t, err)
+	tokens, err = store.ListDeviceTokens(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+}
+
+func TestCreateComment_PushNotifications(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	provider := &recordingPushProvider{}
+	resolver.Push = push.New(store, map[string]push.Provider{models.PlatformFCM: provider}, push.Options{})
+	mutation := resolver.Mutation()
+	author := userContext("user1", "")
+	reader := userContext("user2", "")
+	_, err := mutation.RegisterDeviceToken(author, "author-phone", PushPlatformFcm)
+	require.NoError(t, err)
+
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(author, post.ID, nil, "Свой комментарий", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(reader, post.ID, nil, "Ответ автору", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.ShadowBanUser(userContext("mod1", "moderator"), "user2", nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(reader, post.ID, nil, "Скрытый ответ", nil, nil, nil)
+	require.NoError(t, err)
+	resolver.Push.Close()
+
+	require.Len(t, provider.sent, 1, "Уведомления о своих и скрытых комментариях не отправляются")
+	assert.Equal(t, "author-phone", provider.sent[0].Token)
+	assert.Equal(t, "Ответ автору", provider.sent[0].Body)
+}
@@ -0,0 +1,44 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// SubscribeToPost реализует мутацию subscribeToPost
+func (r *mutationResolver) SubscribeToPost(ctx context.Context, postID string) (bool, error) {
+	log.Printf("Запуск мутации subscribeToPost: postID=%s", postID)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: подписка на пост без авторизации")
+		return false, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	_, err := storage.ViewPost(ctx, r.Storage, postID, userID)
+	if err == nil {
+		err = r.Storage.SubscribeToPost(ctx, userID, postID, r.Clock.Now())
+	}
+	if err != nil {
+		log.Printf("Ошибка при подписке на пост %s: %v", postID, err)
+		return false, categoryError("failed to subscribe to post", err)
+	}
+	log.Printf("Пользователь %s подписан на пост %s", userID, postID)
+	return true, nil
+}
+
+// UnsubscribeFromPost реализует мутацию unsubscribeFromPost
+func (r *mutationResolver) UnsubscribeFromPost(ctx context.Context, postID string) (bool, error) {
+	log.Printf("Запуск мутации unsubscribeFromPost: postID=%s", postID)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: отписка от поста без авторизации")
+		return false, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	if err := r.Storage.UnsubscribeFromPost(ctx, userID, postID); err != nil {
+		log.Printf("Ошибка при отписке от поста %s: %v", postID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to unsubscribe from post: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,43 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeToPost(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user2", "")
+	reader := userContext("user1", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = mutation.SubscribeToPost(context.Background(), post.ID)
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = mutation.SubscribeToPost(reader, "missing")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	ok, err := mutation.SubscribeToPost(reader, post.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = mutation.CreateComment(author, post.ID, nil, "Новый комментарий", nil, nil, nil)
+	require.NoError(t, err)
+	comments, err := store.GetDigestComments(context.Background(), "user1", time.Time{}, time.Now(), 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+
+	ok, err = mutation.UnsubscribeFromPost(reader, post.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	subscribers, err := store.ListDigestSubscribers(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, subscribers)
+}
@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+)
+
+// Directives возвращает реализации директив запроса для NewExecutableSchema
+func Directives() DirectiveRoot {
+	return DirectiveRoot{Stream: stream, Auth: auth, Owner: owner}
+}
+
+// stream реализует директиву @stream. Исполнитель gqlgen не умеет отдавать элементы списка по одному,
+// поэтому директива проверяет аргументы и возвращает список целиком: спецификация инкрементальной
+// доставки разрешает серверу игнорировать @stream
+func stream(ctx context.Context, obj any, next graphql.Resolver, _ bool, _ *string, initialCount *int) (any, error) {
+	if initialCount != nil && *initialCount < 0 {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "initialCount must be non-negative")
+	}
+	return next(ctx)
+}
+
+// auth реализует директиву @auth: резолвер поля вызывается, только если у текущего пользователя роль requires.
+// USER требует только авторизации
+func auth(ctx context.Context, obj any, next graphql.Resolver, requires Role) (any, error) {
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Printf("Ошибка: поле %s требует авторизации", fieldPath(ctx))
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	switch requires {
+	case RoleModerator:
+		if err := requireModerator(ctx); err != nil {
+			return nil, err
+		}
+	case RoleAdmin:
+		if err := requireAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return next(ctx)
+}
+
+// owner реализует директиву @owner: поле доступно только пользователю, чей ID записан в поле field
+// родительского объекта. Поле ищется по имени в схеме, то есть по тегу json модели
+func owner(ctx context.Context, obj any, next graphql.Resolver, field string) (any, error) {
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Printf("Ошибка: поле %s требует авторизации", fieldPath(ctx))
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	ownerID, ok := ownerField(obj, field)
+	if !ok {
+		log.Printf("Ошибка: у родителя поля %s нет поля %s для @owner", fieldPath(ctx), field)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "owner field %q not found", field)
+	}
+	if ownerID != userID {
+		log.Printf("Ошибка: поле %s доступно только владельцу, запрос от %s", fieldPath(ctx), userID)
+		return nil, gqlerrors.New(gqlerrors.CodeForbidden, "field is available only to its owner")
+	}
+	return next(ctx)
+}
+
+// ownerField возвращает строковое поле структуры obj с тегом json name
+func ownerField(obj any, name string) (string, bool) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag != name {
+			continue
+		}
+		value := v.Field(i)
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return "", false
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.String {
+			return "", false
+		}
+		return value.String(), true
+	}
+	return "", false
+}
+
+// fieldPath возвращает путь текущего поля для журнала
+func fieldPath(ctx context.Context) string {
+	if fc := graphql.GetFieldContext(ctx); fc != nil {
+		return fc.Path().String()
+	}
+	return "?"
+}
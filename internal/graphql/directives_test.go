@@ -0,0 +1,68 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resolved - резолвер поля, вызов которого фиксирует called
+func resolved(called *bool) func(ctx context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		*called = true
+		return "value", nil
+	}
+}
+
+func TestAuthDirective(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		requires Role
+		code     string
+	}{
+		{"без авторизации", context.Background(), RoleUser, gqlerrors.CodeUnauthenticated},
+		{"пользователь", userContext("user1", ""), RoleUser, ""},
+		{"пользователь вместо модератора", userContext("user1", ""), RoleModerator, gqlerrors.CodeForbidden},
+		{"модератор", userContext("mod1", roleModerator), RoleModerator, ""},
+		{"модератор вместо администратора", userContext("mod1", roleModerator), RoleAdmin, gqlerrors.CodeForbidden},
+		{"администратор", userContext("admin1", roleAdmin), RoleAdmin, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			value, err := auth(tt.ctx, nil, resolved(&called), tt.requires)
+			if tt.code != "" {
+				assert.Equal(t, tt.code, gqlerrors.Code(err))
+				assert.False(t, called, "Резолвер не вызывается без доступа")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "value", value)
+		})
+	}
+}
+
+func TestOwnerDirective(t *testing.T) {
+	user := &User{ID: "user1"}
+	var called bool
+	_, err := owner(userContext("user1", ""), user, resolved(&called), "id")
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	called = false
+	_, err = owner(userContext("user2", roleAdmin), user, resolved(&called), "id")
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err), "Роль не заменяет владение")
+	assert.False(t, called)
+	_, err = owner(context.Background(), user, resolved(&called), "id")
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+
+	rule := &ModerationRule{ID: "rule1", CreatedBy: "mod1"}
+	_, err = owner(userContext("mod1", ""), rule, resolved(&called), "createdBy")
+	require.NoError(t, err, "Поле ищется по имени в схеме")
+	_, err = owner(userContext("user1", ""), user, resolved(&called), "missing")
+	assert.Equal(t, gqlerrors.CodeInternal, gqlerrors.Code(err))
+}
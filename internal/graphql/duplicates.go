@@ -0,0 +1,60 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/duplicates"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// CheckDuplicate реализует запрос checkDuplicate
+func (r *queryResolver) CheckDuplicate(ctx context.Context, title string, limit *int) (*DuplicateCheck, error) {
+	n := 5
+	if limit != nil {
+		n = *limit
+	}
+	log.Printf("Проверка заголовка на повтор: title=%q, limit=%d", title, n)
+	if n < 1 {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "limit must be positive")
+	}
+	userID, _ := ctx.Value("userID").(string)
+	similar, err := r.Duplicates.Find(ctx, title, userID, n)
+	if err != nil {
+		log.Printf("Ошибка при поиске постов, похожих на %q: %v", title, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to find similar posts: %v", err)
+	}
+	result := &DuplicateCheck{SimilarPosts: make([]*SimilarPost, len(similar)), Blocked: r.Duplicates.Blocks() && len(similar) > 0}
+	for i, s := range similar {
+		result.SimilarPosts[i] = &SimilarPost{Post: toPost(s.Post), Similarity: s.Similarity}
+	}
+	return result, nil
+}
+
+// checkDuplicate отклоняет новый пост с DUPLICATE, если сервер не допускает повторов и похожие посты нашлись.
+// Похожие посты передаются клиенту в extensions.similarPosts
+func (r *mutationResolver) checkDuplicate(ctx context.Context, title, userID string) error {
+	similar, err := r.Duplicates.Check(ctx, title, userID)
+	if !errors.Is(err, duplicates.ErrDuplicate) {
+		return nil
+	}
+	return gqlerrors.WithExtensions(gqlerrors.CodeDuplicate, err, map[string]interface{}{
+		"similarPosts": similarPostsExtension(similar),
+	})
+}
+
+// similarPostsExtension описывает похожие посты для extensions ошибки
+func similarPostsExtension(similar []storage.SimilarPost) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(similar))
+	for i, s := range similar {
+		result[i] = map[string]interface{}{
+			"id":         s.Post.ID,
+			"title":      s.Post.Title,
+			"slug":       s.Post.Slug,
+			"similarity": s.Similarity,
+		}
+	}
+	return result
+}
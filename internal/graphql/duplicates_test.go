@@ -0,0 +1,72 @@
+package graphql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/duplicates"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDuplicate(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user2", "")
+	other := userContext("user3", "")
+	mutation := resolver.Mutation()
+	public, err := mutation.CreatePost(author, "Запуск ракеты", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	private := PostVisibilityPrivate
+	draft, err := mutation.CreatePost(author, "Запуск ракеты отложен", "Содержимое", true, nil, nil, nil, &private)
+	require.NoError(t, err)
+
+	check, err := resolver.Query().CheckDuplicate(other, "Запуск ракеты", nil)
+	require.NoError(t, err)
+	require.Len(t, check.SimilarPosts, 1, "Чужой личный пост не показывается")
+	assert.Equal(t, public.ID, check.SimilarPosts[0].Post.ID)
+	assert.InDelta(t, 1, check.SimilarPosts[0].Similarity, 0.001)
+	assert.False(t, check.Blocked)
+
+	check, err = resolver.Query().CheckDuplicate(author, "Запуск ракеты", nil)
+	require.NoError(t, err)
+	require.Len(t, check.SimilarPosts, 2)
+	assert.Equal(t, draft.ID, check.SimilarPosts[1].Post.ID)
+
+	check, err = resolver.Query().CheckDuplicate(other, "Рецепт борща", nil)
+	require.NoError(t, err)
+	assert.Empty(t, check.SimilarPosts)
+
+	limit := 0
+	_, err = resolver.Query().CheckDuplicate(other, "Запуск ракеты", &limit)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
+
+func TestCreatePost_Duplicates(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user2", "")
+	mutation := resolver.Mutation()
+	original, err := mutation.CreatePost(author, "Запуск ракеты", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreatePost(author, "Запуск ракеты", "Повтор", true, nil, nil, nil, nil)
+	require.NoError(t, err, "В режиме warn повтор создаётся")
+
+	resolver.Duplicates = duplicates.New(store, duplicates.Options{Enforcement: duplicates.EnforcementBlock})
+	check, err := resolver.Query().CheckDuplicate(author, "Запуск ракеты", nil)
+	require.NoError(t, err)
+	assert.True(t, check.Blocked)
+
+	_, err = mutation.CreatePost(author, "Запуск ракеты!", "Ещё повтор", true, nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeDuplicate, gqlerrors.Code(err))
+	var gqlErr *gqlerrors.Error
+	require.True(t, errors.As(err, &gqlErr))
+	similar := gqlErr.Extensions["similarPosts"].([]map[string]interface{})
+	require.Len(t, similar, 2)
+	assert.Equal(t, original.Slug, similar[1]["slug"], "Сначала новые посты")
+
+	_, err = mutation.CreatePost(author, "Рецепт борща", "Содержимое", true, nil, nil, nil, nil)
+	assert.NoError(t, err)
+}
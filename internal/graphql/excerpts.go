@@ -0,0 +1,30 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/textsize"
+)
+
+// contentSizeError переводит ошибку лимита длины в ошибку запроса
+func contentSizeError(err error) error {
+	var tooLong *textsize.TooLongError
+	if errors.As(err, &tooLong) {
+		log.Printf("Ошибка: %s длиннее %d (%s)", tooLong.Field, tooLong.Limit, tooLong.Unit)
+		return gqlerrors.New(gqlerrors.CodeBadUserInput, tooLong.Error())
+	}
+	return gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to check content size: %v", err)
+}
+
+// Excerpt реализует поле excerpt в Post. Анонс сохраняется при создании поста, а для постов,
+// созданных до появления анонсов, строится из содержимого при чтении
+func (r *postResolver) Excerpt(ctx context.Context, obj *Post) (string, error) {
+	if obj.Excerpt != "" {
+		return obj.Excerpt, nil
+	}
+	return r.Excerpts.Make(&models.Post{Format: string(obj.Format), Content: obj.Content}), nil
+}
@@ -0,0 +1,68 @@
+package graphql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/excerpt"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/ButyrinIA/system/internal/textsize"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentLimits(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	mutation := resolver.Mutation()
+	user := userContext("user1", "")
+
+	// 200 кириллических символов занимают 400 байт, но укладываются в лимит
+	post, err := mutation.CreatePost(user, strings.Repeat("я", 200), strings.Repeat("ж", 2000), true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(user, post.ID, nil, strings.Repeat("ш", 2000), nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(user, post.ID, nil, strings.Repeat("ш", 2001), nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	assert.EqualError(t, err, "comment content exceeds 2000 characters")
+	_, err = mutation.UpdatePostTitle(user, post.ID, strings.Repeat("я", 201))
+	assert.EqualError(t, err, "title exceeds 200 characters")
+
+	t.Run("Tenant overrides", func(t *testing.T) {
+		resolver.ContentLimits = textsize.New(textsize.Options{
+			Unit:    textsize.Bytes,
+			Default: textsize.DefaultLimits,
+			Tenants: map[string]textsize.Limits{"forum": {Title: 10}},
+		})
+		forum := context.WithValue(user, "tenant", "forum")
+		_, err := mutation.CreatePost(forum, "Длинный заголовок", "Содержимое", true, nil, nil, nil, nil)
+		assert.EqualError(t, err, "title exceeds 10 bytes")
+		_, err = mutation.CreatePost(forum, "Пост", strings.Repeat("ж", 5000), true, nil, nil, nil, nil)
+		assert.NoError(t, err, "Лимит текста у сообщества не задан")
+		_, err = mutation.CreatePost(user, "Длинный заголовок", "Содержимое", true, nil, nil, nil, nil)
+		assert.NoError(t, err)
+	})
+}
+
+func TestPost_Excerpt(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	resolver.Excerpts = excerpt.New(nil, store, excerpt.Options{Length: 30})
+	markdown := ContentFormatMarkdown
+	post, err := resolver.Mutation().CreatePost(userContext("user1", ""), "Пост",
+		"# Введение\n\n**Первое** предложение. Второе предложение не поместится.", true, &markdown, nil, nil, nil)
+	require.NoError(t, err)
+	stored, err := store.GetPost(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Введение Первое предложение.", stored.Excerpt, "Анонс сохраняется при создании поста")
+	got, err := resolver.Post().Excerpt(context.Background(), post)
+	require.NoError(t, err)
+	assert.Equal(t, "Введение Первое предложение.", got)
+
+	// Пост, созданный до появления анонсов
+	legacy := &Post{Content: "Первая  строка\n\nвторая строка, которая не помещается", Format: ContentFormatPlain}
+	got, err = resolver.Post().Excerpt(context.Background(), legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "Первая строка вторая строка…", got)
+}
@@ -0,0 +1,28 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+)
+
+// FollowUser реализует мутацию followUser
+func (r *mutationResolver) FollowUser(ctx context.Context, userID string, follow *bool) (bool, error) {
+	following := follow == nil || *follow
+	log.Printf("Запуск мутации followUser: userID=%s, follow=%t", userID, following)
+	followerID, _ := ctx.Value("userID").(string)
+	if followerID == "" {
+		log.Println("Ошибка: подписка на пользователя без авторизации")
+		return false, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	if userID == followerID {
+		return false, gqlerrors.New(gqlerrors.CodeBadUserInput, "cannot follow yourself")
+	}
+	if err := r.Storage.SetFollow(ctx, followerID, userID, following); err != nil {
+		log.Printf("Ошибка при подписке %s на %s: %v", followerID, userID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to update follow: %v", err)
+	}
+	log.Printf("Подписка %s на %s: %t", followerID, userID, following)
+	return following, nil
+}
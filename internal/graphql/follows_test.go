@@ -0,0 +1,50 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostVisibility(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	mutation, query := resolver.Mutation(), resolver.Query()
+	author, reader := userContext("author", ""), userContext("reader", "")
+	followers := PostVisibilityFollowers
+	post, err := mutation.CreatePost(author, "Для подписчиков", "Содержимое", true, nil, nil, nil, &followers)
+	require.NoError(t, err)
+	assert.Equal(t, PostVisibilityFollowers, post.Visibility)
+	public, err := mutation.CreatePost(author, "Для всех", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, PostVisibilityPublic, public.Visibility)
+
+	_, err = query.Post(reader, post.ID)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = mutation.CreateComment(reader, post.ID, nil, "Комментарий", nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	_, err = mutation.FollowUser(context.Background(), "author", nil)
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = mutation.FollowUser(author, "author", nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	following, err := mutation.FollowUser(reader, "author", nil)
+	require.NoError(t, err)
+	assert.True(t, following)
+
+	got, err := query.Post(reader, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, got.ID)
+	_, err = mutation.CreateComment(reader, post.ID, nil, "Комментарий", nil, nil, nil)
+	require.NoError(t, err)
+
+	unfollow := false
+	following, err = mutation.FollowUser(reader, "author", &unfollow)
+	require.NoError(t, err)
+	assert.False(t, following)
+	_, err = query.Post(reader, post.ID)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+}
@@ -0,0 +1,223 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/ids"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// CommentsKey - ключ DataLoader комментариев: конкретная страница корневых комментариев поста в заданном порядке.
+// Viewer входит в ключ, так как загрузчик общий для запросов разных пользователей, а скрытые комментарии видны только автору
+type CommentsKey struct {
+	PostID string
+	Limit  int
+	Cursor string
+	Order  models.SortOrder
+	Viewer string
+	// Estimate разрешает приблизительный TotalCount, см. storage.WithEstimatedCounts
+	Estimate bool
+}
+
+// cursorPtr возвращает курсор ключа в виде, принимаемом хранилищем
+func (k CommentsKey) cursorPtr() *string {
+	if k.Cursor == "" {
+		return nil
+	}
+	cursor := k.Cursor
+	return &cursor
+}
+
+// CommentLoader пакетно загружает страницы комментариев постов
+type CommentLoader = dataloader.Loader[CommentsKey, *models.PaginatedComments]
+
+// NewCommentLoader создаёт DataLoader для комментариев; limit и cursor входят в ключ,
+// поэтому клиент получает именно запрошенную страницу
+func NewCommentLoader(store storage.Storage) *CommentLoader {
+	return dataloader.NewBatchedLoader(
+		func(ctx context.Context, keys []CommentsKey) []*dataloader.Result[*models.PaginatedComments] {
+			results := make([]*dataloader.Result[*models.PaginatedComments], len(keys))
+			for i, key := range keys {
+				keyCtx := storage.WithViewer(ctx, key.Viewer)
+				if key.Estimate {
+					keyCtx = storage.WithEstimatedCounts(keyCtx)
+				}
+				comments, err := store.GetComments(keyCtx, key.PostID, nil, key.Limit, key.cursorPtr(), key.Order)
+				if err != nil {
+					log.Printf("Ошибка загрузки комментариев для postID=%s: %v", key.PostID, err)
+					results[i] = &dataloader.Result[*models.PaginatedComments]{Error: err}
+				} else {
+					log.Printf("Получено комментариев для postID=%s: %d", key.PostID, len(comments.Comments))
+					results[i] = &dataloader.Result[*models.PaginatedComments]{Data: comments}
+				}
+			}
+			return results
+		},
+		dataloader.WithCache[CommentsKey, *models.PaginatedComments](&dataloader.NoCache[CommentsKey, *models.PaginatedComments]{}),
+	)
+}
+
+// CommentByIDLoader пакетно загружает отдельные комментарии по ID; для неизвестного ID возвращает nil
+type CommentByIDLoader = dataloader.Loader[string, *models.Comment]
+
+// NewCommentByIDLoader создаёт DataLoader комментариев по ID. Скрытые комментарии загружаются тоже,
+// их видимость проверяет резолвер
+func NewCommentByIDLoader(store storage.Storage) *CommentByIDLoader {
+	return dataloader.NewBatchedLoader(
+		func(ctx context.Context, keys []string) []*dataloader.Result[*models.Comment] {
+			results := make([]*dataloader.Result[*models.Comment], len(keys))
+			comments, err := store.GetCommentsByIDs(ctx, keys)
+			if err != nil {
+				log.Printf("Ошибка пакетной загрузки комментариев по ID: %v", err)
+			}
+			for i, key := range keys {
+				if err != nil {
+					results[i] = &dataloader.Result[*models.Comment]{Error: err}
+					continue
+				}
+				results[i] = &dataloader.Result[*models.Comment]{Data: comments[key]}
+			}
+			return results
+		},
+		dataloader.WithCache[string, *models.Comment](&dataloader.NoCache[string, *models.Comment]{}),
+	)
+}
+
+// ReactionLoader пакетно загружает количество реакций по ID поста или комментария
+type ReactionLoader = dataloader.Loader[string, []models.ReactionCount]
+
+// NewReactionLoader создаёт DataLoader для количества реакций
+func NewReactionLoader(store storage.Storage) *ReactionLoader {
+	return dataloader.NewBatchedLoader(
+		func(ctx context.Context, keys []string) []*dataloader.Result[[]models.ReactionCount] {
+			results := make([]*dataloader.Result[[]models.ReactionCount], len(keys))
+			counts, err := store.GetReactionCounts(ctx, keys)
+			if err != nil {
+				log.Printf("Ошибка пакетной загрузки реакций: %v", err)
+			}
+			for i, key := range keys {
+				if err != nil {
+					results[i] = &dataloader.Result[[]models.ReactionCount]{Error: err}
+					continue
+				}
+				results[i] = &dataloader.Result[[]models.ReactionCount]{Data: counts[key]}
+			}
+			return results
+		},
+		dataloader.WithCache[string, []models.ReactionCount](&dataloader.NoCache[string, []models.ReactionCount]{}),
+	)
+}
+
+// DescendantLoader пакетно загружает число ответов на любой глубине по ID комментария
+type DescendantLoader = dataloader.Loader[string, int]
+
+// NewDescendantLoader создаёт DataLoader числа ответов
+func NewDescendantLoader(store storage.Storage) *DescendantLoader {
+	return dataloader.NewBatchedLoader(
+		func(ctx context.Context, keys []string) []*dataloader.Result[int] {
+			results := make([]*dataloader.Result[int], len(keys))
+			counts, err := store.CountDescendants(ctx, keys)
+			if err != nil {
+				log.Printf("Ошибка пакетной загрузки числа ответов: %v", err)
+			}
+			for i, key := range keys {
+				if err != nil {
+					results[i] = &dataloader.Result[int]{Error: err}
+					continue
+				}
+				results[i] = &dataloader.Result[int]{Data: counts[key]}
+			}
+			return results
+		},
+		dataloader.WithCache[string, int](&dataloader.NoCache[string, int]{}),
+	)
+}
+
+// UnreadKey - ключ DataLoader непрочитанных комментариев: пост и пользователь, для которого ведётся отметка
+type UnreadKey struct {
+	PostID string
+	Viewer string
+}
+
+// UnreadLoader пакетно загружает число непрочитанных комментариев постов
+type UnreadLoader = dataloader.Loader[UnreadKey, int]
+
+// NewUnreadLoader создаёт DataLoader непрочитанных комментариев; ключи пакета группируются по пользователю,
+// и для каждого выполняется один запрос к хранилищу
+func NewUnreadLoader(store storage.Storage) *UnreadLoader {
+	return dataloader.NewBatchedLoader(
+		func(ctx context.Context, keys []UnreadKey) []*dataloader.Result[int] {
+			results := make([]*dataloader.Result[int], len(keys))
+			postIDs := make(map[string][]string)
+			for _, key := range keys {
+				postIDs[key.Viewer] = append(postIDs[key.Viewer], key.PostID)
+			}
+			counts := make(map[string]map[string]int, len(postIDs))
+			errs := make(map[string]error)
+			for viewer, ids := range postIDs {
+				counts[viewer], errs[viewer] = store.GetUnreadCommentCounts(ctx, viewer, ids)
+				if errs[viewer] != nil {
+					log.Printf("Ошибка пакетной загрузки непрочитанных комментариев пользователя %s: %v", viewer, errs[viewer])
+				}
+			}
+			for i, key := range keys {
+				if err := errs[key.Viewer]; err != nil {
+					results[i] = &dataloader.Result[int]{Error: err}
+					continue
+				}
+				results[i] = &dataloader.Result[int]{Data: counts[key.Viewer][key.PostID]}
+			}
+			return results
+		},
+		dataloader.WithCache[UnreadKey, int](&dataloader.NoCache[UnreadKey, int]{}),
+	)
+}
+
+// ShortIDKey - ключ DataLoader коротких идентификаторов: вид сущности (models.TargetPost или models.TargetComment) и её ID
+type ShortIDKey struct {
+	Kind string
+	ID   string
+}
+
+// ShortIDLoader пакетно загружает короткие идентификаторы постов и комментариев
+type ShortIDLoader = dataloader.Loader[ShortIDKey, string]
+
+// NewShortIDLoader создаёт DataLoader коротких идентификаторов; ключи пакета группируются по виду сущности,
+// и для каждого вида выполняется один запрос к хранилищу
+func NewShortIDLoader(store storage.Storage) *ShortIDLoader {
+	return dataloader.NewBatchedLoader(
+		func(ctx context.Context, keys []ShortIDKey) []*dataloader.Result[string] {
+			results := make([]*dataloader.Result[string], len(keys))
+			targets := make(map[string][]string)
+			for _, key := range keys {
+				targets[key.Kind] = append(targets[key.Kind], key.ID)
+			}
+			seqs := make(map[string]map[string]int64, len(targets))
+			errs := make(map[string]error)
+			for kind, kindIDs := range targets {
+				seqs[kind], errs[kind] = store.ShortIDs(ctx, kind, kindIDs)
+				if errs[kind] != nil {
+					log.Printf("Ошибка пакетной загрузки коротких идентификаторов %s: %v", kind, errs[kind])
+				}
+			}
+			for i, key := range keys {
+				if err := errs[key.Kind]; err != nil {
+					results[i] = &dataloader.Result[string]{Error: err}
+					continue
+				}
+				// Сущности без номера нет в ответе хранилища; нулевой номер дал бы пустой идентификатор
+				seq, ok := seqs[key.Kind][key.ID]
+				if !ok {
+					results[i] = &dataloader.Result[string]{Error: storage.ErrShortIDNotFound}
+					continue
+				}
+				results[i] = &dataloader.Result[string]{Data: ids.EncodeShort(seq)}
+			}
+			return results
+		},
+		dataloader.WithCache[ShortIDKey, string](&dataloader.NoCache[ShortIDKey, string]{}),
+	)
+}
@@ -0,0 +1,30 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+)
+
+// roleAdmin - роль пользователей, которым доступно управление сервером
+const roleAdmin = "admin"
+
+// SetMaintenanceMode реализует мутацию setMaintenanceMode
+func (r *mutationResolver) SetMaintenanceMode(ctx context.Context, enabled bool) (bool, error) {
+	log.Printf("Запуск мутации setMaintenanceMode: enabled=%t", enabled)
+	if err := requireAdmin(ctx); err != nil {
+		return false, err
+	}
+	r.Maintenance.Set(enabled)
+	return r.Maintenance.Enabled(), nil
+}
+
+// requireAdmin возвращает FORBIDDEN, если текущий пользователь не администратор
+func requireAdmin(ctx context.Context) error {
+	if role, _ := ctx.Value("role").(string); role != roleAdmin {
+		log.Printf("Ошибка: действие доступно только администраторам, роль %q", role)
+		return gqlerrors.New(gqlerrors.CodeForbidden, "admin role required")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMaintenanceMode(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	mutation := resolver.Mutation()
+
+	_, err := mutation.SetMaintenanceMode(userContext("mod1", roleModerator), true)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	assert.False(t, resolver.Maintenance.Enabled())
+
+	enabled, err := mutation.SetMaintenanceMode(userContext("admin1", roleAdmin), true)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.True(t, resolver.Maintenance.Enabled())
+
+	enabled, err = mutation.SetMaintenanceMode(userContext("admin1", roleAdmin), false)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+}
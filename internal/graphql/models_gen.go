@@ -2,43 +2,1235 @@
 
 package graphql
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type Category struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	ParentID *string     `json:"parentId,omitempty"`
+	Children []*Category `json:"children"`
+}
+
+type Collection struct {
+	ID          string  `json:"id"`
+	AuthorID    string  `json:"authorId"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Posts       []*Post `json:"posts"`
+	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+}
+
 type Comment struct {
+	ID                string             `json:"id"`
+	PostID            string             `json:"postId"`
+	ParentID          *string            `json:"parentId,omitempty"`
+	AuthorID          string             `json:"authorId"`
+	Content           string             `json:"content"`
+	Format            ContentFormat      `json:"format"`
+	ContentHTML       string             `json:"contentHTML"`
+	CreatedAt         string             `json:"createdAt"`
+	Replies           *PaginatedComments `json:"replies"`
+	DescendantCount   *int               `json:"descendantCount,omitempty"`
+	HasMoreReplies    *bool              `json:"hasMoreReplies,omitempty"`
+	ReactionCounts    []*ReactionCount   `json:"reactionCounts"`
+	Tags              []string           `json:"tags"`
+	Upvotes           int                `json:"upvotes"`
+	Downvotes         int                `json:"downvotes"`
+	Score             int                `json:"score"`
+	SpamStatus        *SpamStatus        `json:"spamStatus,omitempty"`
+	ToxicityScore     *float64           `json:"toxicityScore,omitempty"`
+	ApprovalStatus    *ApprovalStatus    `json:"approvalStatus,omitempty"`
+	Language          *string            `json:"language,omitempty"`
+	ShortID           string             `json:"shortId"`
+	QuotedCommentID   *string            `json:"quotedCommentId,omitempty"`
+	QuotedComment     *Comment           `json:"quotedComment,omitempty"`
+	ContentTranslated string             `json:"contentTranslated"`
+}
+
+type CommentPermalink struct {
+	Comment      *Comment         `json:"comment"`
+	Post         *Post            `json:"post"`
+	Root         *Comment         `json:"root"`
+	Order        SortOrder        `json:"order"`
+	RootPosition *CommentPosition `json:"rootPosition"`
+	Position     *CommentPosition `json:"position"`
+}
+
+type CommentPosition struct {
+	Index  int     `json:"index"`
+	Page   int     `json:"page"`
+	Cursor *string `json:"cursor,omitempty"`
+}
+
+type DuplicateCheck struct {
+	SimilarPosts []*SimilarPost `json:"similarPosts"`
+	Blocked      bool           `json:"blocked"`
+}
+
+type HeldContent struct {
+	ID            string   `json:"id"`
+	Post          *Post    `json:"post,omitempty"`
+	Comment       *Comment `json:"comment,omitempty"`
+	RuleID        string   `json:"ruleId"`
+	CreatedAt     string   `json:"createdAt"`
+	ToxicityScore *float64 `json:"toxicityScore,omitempty"`
+}
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"imageUrl"`
+	SiteName    string `json:"siteName"`
+}
+
+type ModerationRule struct {
 	ID        string             `json:"id"`
-	PostID    string             `json:"postId"`
-	ParentID  *string            `json:"parentId,omitempty"`
-	AuthorID  string             `json:"authorId"`
-	Content   string             `json:"content"`
+	Kind      ModerationRuleKind `json:"kind"`
+	Pattern   string             `json:"pattern"`
+	Action    ModerationAction   `json:"action"`
+	Tag       *string            `json:"tag,omitempty"`
+	CreatedBy string             `json:"createdBy"`
 	CreatedAt string             `json:"createdAt"`
-	Replies   *PaginatedComments `json:"replies"`
+}
+
+type ModerationRuleInput struct {
+	Kind    ModerationRuleKind `json:"kind"`
+	Pattern string             `json:"pattern"`
+	Action  ModerationAction   `json:"action"`
+	Tag     *string            `json:"tag,omitempty"`
 }
 
 type Mutation struct {
 }
 
 type PaginatedComments struct {
-	Comments   []*Comment `json:"comments"`
-	TotalCount int        `json:"totalCount"`
-	NextCursor *string    `json:"nextCursor,omitempty"`
+	Comments   []*Comment      `json:"comments"`
+	TotalCount int             `json:"totalCount"`
+	PageCount  int             `json:"pageCount"`
+	NextCursor *string         `json:"nextCursor,omitempty"`
+	Ranking    *CommentRanking `json:"ranking,omitempty"`
 }
 
 type PaginatedPosts struct {
 	Posts      []*Post `json:"posts"`
 	TotalCount int     `json:"totalCount"`
+	PageCount  int     `json:"pageCount"`
 	NextCursor *string `json:"nextCursor,omitempty"`
+	Snapshot   string  `json:"snapshot"`
 }
 
 type Post struct {
-	ID            string             `json:"id"`
-	Title         string             `json:"title"`
-	Content       string             `json:"content"`
-	AuthorID      string             `json:"authorId"`
-	AllowComments bool               `json:"allowComments"`
-	CreatedAt     string             `json:"createdAt"`
-	Comments      *PaginatedComments `json:"comments"`
+	ID                 string             `json:"id"`
+	Title              string             `json:"title"`
+	Content            string             `json:"content"`
+	Excerpt            string             `json:"excerpt"`
+	Format             ContentFormat      `json:"format"`
+	ContentHTML        string             `json:"contentHTML"`
+	AuthorID           string             `json:"authorId"`
+	AllowComments      bool               `json:"allowComments"`
+	CreatedAt          string             `json:"createdAt"`
+	Comments           *PaginatedComments `json:"comments"`
+	LinkPreviews       []*LinkPreview     `json:"linkPreviews"`
+	ReactionCounts     []*ReactionCount   `json:"reactionCounts"`
+	Tags               []string           `json:"tags"`
+	CategoryID         *string            `json:"categoryId,omitempty"`
+	Slug               string             `json:"slug"`
+	ShortID            string             `json:"shortId"`
+	Visibility         PostVisibility     `json:"visibility"`
+	RequireApproval    bool               `json:"requireApproval"`
+	Language           *string            `json:"language,omitempty"`
+	ContentTranslated  string             `json:"contentTranslated"`
+	RelatedPosts       []*Post            `json:"relatedPosts"`
+	UnreadCommentCount int                `json:"unreadCommentCount"`
+	References         *PaginatedPosts    `json:"references"`
+	ReferencedBy       *PaginatedPosts    `json:"referencedBy"`
+	Collections        []*Collection      `json:"collections"`
+}
+
+type PostFilterInput struct {
+	Text          *string `json:"text,omitempty"`
+	CreatedAfter  *string `json:"createdAfter,omitempty"`
+	CreatedBefore *string `json:"createdBefore,omitempty"`
+	AuthorID      *string `json:"authorId,omitempty"`
+	Tag           *string `json:"tag,omitempty"`
+	HasComments   *bool   `json:"hasComments,omitempty"`
+	AllowComments *bool   `json:"allowComments,omitempty"`
+	Language      *string `json:"language,omitempty"`
+}
+
+type Preferences struct {
+	EmailOnReply       bool            `json:"emailOnReply"`
+	EmailOnMention     bool            `json:"emailOnMention"`
+	DefaultCommentSort SortOrder       `json:"defaultCommentSort"`
+	Locale             string          `json:"locale"`
+	Email              *string         `json:"email,omitempty"`
+	DigestFrequency    DigestFrequency `json:"digestFrequency"`
+	PushOnReply        bool            `json:"pushOnReply"`
+	PushOnMention      bool            `json:"pushOnMention"`
+}
+
+type PreferencesInput struct {
+	EmailOnReply       *bool            `json:"emailOnReply,omitempty"`
+	EmailOnMention     *bool            `json:"emailOnMention,omitempty"`
+	DefaultCommentSort *SortOrder       `json:"defaultCommentSort,omitempty"`
+	Locale             *string          `json:"locale,omitempty"`
+	Email              *string          `json:"email,omitempty"`
+	DigestFrequency    *DigestFrequency `json:"digestFrequency,omitempty"`
+	PushOnReply        *bool            `json:"pushOnReply,omitempty"`
+	PushOnMention      *bool            `json:"pushOnMention,omitempty"`
+}
+
+type PurgeJob struct {
+	ID                string         `json:"id"`
+	UserID            string         `json:"userId"`
+	Status            PurgeJobStatus `json:"status"`
+	PostsProcessed    int            `json:"postsProcessed"`
+	CommentsProcessed int            `json:"commentsProcessed"`
+	StartedAt         string         `json:"startedAt"`
+	FinishedAt        *string        `json:"finishedAt,omitempty"`
+	Error             *string        `json:"error,omitempty"`
 }
 
 type Query struct {
 }
 
+type QueryStats struct {
+	Query       string  `json:"query"`
+	Calls       int     `json:"calls"`
+	Rows        int     `json:"rows"`
+	TotalTimeMs float64 `json:"totalTimeMs"`
+	MeanTimeMs  float64 `json:"meanTimeMs"`
+}
+
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+type SavedSearch struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	CategoryID           *string            `json:"categoryId,omitempty"`
+	IncludeSubcategories bool               `json:"includeSubcategories"`
+	Filter               *SavedSearchFilter `json:"filter"`
+	Alert                bool               `json:"alert"`
+	CreatedAt            string             `json:"createdAt"`
+}
+
+type SavedSearchFilter struct {
+	Text          *string `json:"text,omitempty"`
+	AuthorID      *string `json:"authorId,omitempty"`
+	Tag           *string `json:"tag,omitempty"`
+	HasComments   *bool   `json:"hasComments,omitempty"`
+	AllowComments *bool   `json:"allowComments,omitempty"`
+	Language      *string `json:"language,omitempty"`
+}
+
+type SimilarPost struct {
+	Post       *Post   `json:"post"`
+	Similarity float64 `json:"similarity"`
+}
+
+type StorageStats struct {
+	Backend              string        `json:"backend"`
+	Tables               []*TableStats `json:"tables"`
+	SlowQueriesAvailable bool          `json:"slowQueriesAvailable"`
+	SlowQueries          []*QueryStats `json:"slowQueries"`
+}
+
 type Subscription struct {
 }
+
+type SubscriptionConnection struct {
+	UserID      *string `json:"userId,omitempty"`
+	Tenant      string  `json:"tenant"`
+	ConnectedAt string  `json:"connectedAt"`
+	AgeSeconds  int     `json:"ageSeconds"`
+}
+
+type SubscriptionCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type SubscriptionsInfo struct {
+	Connections       int                       `json:"connections"`
+	Subscriptions     int                       `json:"subscriptions"`
+	ByPost            []*SubscriptionCount      `json:"byPost"`
+	ByTenant          []*SubscriptionCount      `json:"byTenant"`
+	ByUser            []*SubscriptionCount      `json:"byUser"`
+	OldestConnections []*SubscriptionConnection `json:"oldestConnections"`
+}
+
+type Suggestion struct {
+	Text   string         `json:"text"`
+	Kind   SuggestionKind `json:"kind"`
+	Count  int            `json:"count"`
+	PostID *string        `json:"postId,omitempty"`
+}
+
+type TableStats struct {
+	Name       string `json:"name"`
+	Rows       int    `json:"rows"`
+	DataBytes  int    `json:"dataBytes"`
+	IndexBytes int    `json:"indexBytes"`
+}
+
+type TenantSettings struct {
+	TenantID           string          `json:"tenantId"`
+	DefaultCommentSort SortOrder       `json:"defaultCommentSort"`
+	MaxNesting         int             `json:"maxNesting"`
+	AnonymousPolicy    AnonymousPolicy `json:"anonymousPolicy"`
+	SiteName           string          `json:"siteName"`
+	Tagline            string          `json:"tagline"`
+	LogoURL            string          `json:"logoUrl"`
+	PrimaryColor       string          `json:"primaryColor"`
+}
+
+type TenantSettingsInput struct {
+	DefaultCommentSort *SortOrder       `json:"defaultCommentSort,omitempty"`
+	MaxNesting         *int             `json:"maxNesting,omitempty"`
+	AnonymousPolicy    *AnonymousPolicy `json:"anonymousPolicy,omitempty"`
+	SiteName           *string          `json:"siteName,omitempty"`
+	Tagline            *string          `json:"tagline,omitempty"`
+	LogoURL            *string          `json:"logoUrl,omitempty"`
+	PrimaryColor       *string          `json:"primaryColor,omitempty"`
+}
+
+type TenantUsage struct {
+	TenantID    string `json:"tenantId"`
+	Posts       int    `json:"posts"`
+	Comments    int    `json:"comments"`
+	Bytes       int    `json:"bytes"`
+	MaxPosts    int    `json:"maxPosts"`
+	MaxComments int    `json:"maxComments"`
+	MaxBytes    int    `json:"maxBytes"`
+}
+
+type TypingEvent struct {
+	PostID string `json:"postId"`
+	UserID string `json:"userId"`
+	At     string `json:"at"`
+}
+
+type User struct {
+	ID          string       `json:"id"`
+	Preferences *Preferences `json:"preferences"`
+}
+
+type AnonymousPolicy string
+
+const (
+	AnonymousPolicyAllow    AnonymousPolicy = "ALLOW"
+	AnonymousPolicyReadOnly AnonymousPolicy = "READ_ONLY"
+	AnonymousPolicyDeny     AnonymousPolicy = "DENY"
+)
+
+var AllAnonymousPolicy = []AnonymousPolicy{
+	AnonymousPolicyAllow,
+	AnonymousPolicyReadOnly,
+	AnonymousPolicyDeny,
+}
+
+func (e AnonymousPolicy) IsValid() bool {
+	switch e {
+	case AnonymousPolicyAllow, AnonymousPolicyReadOnly, AnonymousPolicyDeny:
+		return true
+	}
+	return false
+}
+
+func (e AnonymousPolicy) String() string {
+	return string(e)
+}
+
+func (e *AnonymousPolicy) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AnonymousPolicy(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AnonymousPolicy", str)
+	}
+	return nil
+}
+
+func (e AnonymousPolicy) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AnonymousPolicy) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AnonymousPolicy) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending  ApprovalStatus = "PENDING"
+	ApprovalStatusApproved ApprovalStatus = "APPROVED"
+	ApprovalStatusRejected ApprovalStatus = "REJECTED"
+)
+
+var AllApprovalStatus = []ApprovalStatus{
+	ApprovalStatusPending,
+	ApprovalStatusApproved,
+	ApprovalStatusRejected,
+}
+
+func (e ApprovalStatus) IsValid() bool {
+	switch e {
+	case ApprovalStatusPending, ApprovalStatusApproved, ApprovalStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e ApprovalStatus) String() string {
+	return string(e)
+}
+
+func (e *ApprovalStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ApprovalStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ApprovalStatus", str)
+	}
+	return nil
+}
+
+func (e ApprovalStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ApprovalStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ApprovalStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type CacheControlScope string
+
+const (
+	CacheControlScopePublic  CacheControlScope = "PUBLIC"
+	CacheControlScopePrivate CacheControlScope = "PRIVATE"
+)
+
+var AllCacheControlScope = []CacheControlScope{
+	CacheControlScopePublic,
+	CacheControlScopePrivate,
+}
+
+func (e CacheControlScope) IsValid() bool {
+	switch e {
+	case CacheControlScopePublic, CacheControlScopePrivate:
+		return true
+	}
+	return false
+}
+
+func (e CacheControlScope) String() string {
+	return string(e)
+}
+
+func (e *CacheControlScope) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CacheControlScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CacheControlScope", str)
+	}
+	return nil
+}
+
+func (e CacheControlScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *CacheControlScope) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e CacheControlScope) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type CommentRanking string
+
+const (
+	CommentRankingChronological CommentRanking = "CHRONOLOGICAL"
+	CommentRankingBest          CommentRanking = "BEST"
+	CommentRankingControversial CommentRanking = "CONTROVERSIAL"
+	CommentRankingMlScore       CommentRanking = "ML_SCORE"
+)
+
+var AllCommentRanking = []CommentRanking{
+	CommentRankingChronological,
+	CommentRankingBest,
+	CommentRankingControversial,
+	CommentRankingMlScore,
+}
+
+func (e CommentRanking) IsValid() bool {
+	switch e {
+	case CommentRankingChronological, CommentRankingBest, CommentRankingControversial, CommentRankingMlScore:
+		return true
+	}
+	return false
+}
+
+func (e CommentRanking) String() string {
+	return string(e)
+}
+
+func (e *CommentRanking) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentRanking(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentRanking", str)
+	}
+	return nil
+}
+
+func (e CommentRanking) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *CommentRanking) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e CommentRanking) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ContentFormat string
+
+const (
+	ContentFormatPlain    ContentFormat = "PLAIN"
+	ContentFormatMarkdown ContentFormat = "MARKDOWN"
+)
+
+var AllContentFormat = []ContentFormat{
+	ContentFormatPlain,
+	ContentFormatMarkdown,
+}
+
+func (e ContentFormat) IsValid() bool {
+	switch e {
+	case ContentFormatPlain, ContentFormatMarkdown:
+		return true
+	}
+	return false
+}
+
+func (e ContentFormat) String() string {
+	return string(e)
+}
+
+func (e *ContentFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContentFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContentFormat", str)
+	}
+	return nil
+}
+
+func (e ContentFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ContentFormat) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ContentFormat) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type DigestFrequency string
+
+const (
+	DigestFrequencyNever  DigestFrequency = "NEVER"
+	DigestFrequencyDaily  DigestFrequency = "DAILY"
+	DigestFrequencyWeekly DigestFrequency = "WEEKLY"
+)
+
+var AllDigestFrequency = []DigestFrequency{
+	DigestFrequencyNever,
+	DigestFrequencyDaily,
+	DigestFrequencyWeekly,
+}
+
+func (e DigestFrequency) IsValid() bool {
+	switch e {
+	case DigestFrequencyNever, DigestFrequencyDaily, DigestFrequencyWeekly:
+		return true
+	}
+	return false
+}
+
+func (e DigestFrequency) String() string {
+	return string(e)
+}
+
+func (e *DigestFrequency) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DigestFrequency(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DigestFrequency", str)
+	}
+	return nil
+}
+
+func (e DigestFrequency) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DigestFrequency) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DigestFrequency) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ModerationAction string
+
+const (
+	ModerationActionBlock ModerationAction = "BLOCK"
+	ModerationActionHold  ModerationAction = "HOLD"
+	ModerationActionTag   ModerationAction = "TAG"
+)
+
+var AllModerationAction = []ModerationAction{
+	ModerationActionBlock,
+	ModerationActionHold,
+	ModerationActionTag,
+}
+
+func (e ModerationAction) IsValid() bool {
+	switch e {
+	case ModerationActionBlock, ModerationActionHold, ModerationActionTag:
+		return true
+	}
+	return false
+}
+
+func (e ModerationAction) String() string {
+	return string(e)
+}
+
+func (e *ModerationAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ModerationAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ModerationAction", str)
+	}
+	return nil
+}
+
+func (e ModerationAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ModerationAction) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ModerationAction) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ModerationRuleKind string
+
+const (
+	ModerationRuleKindKeyword ModerationRuleKind = "KEYWORD"
+	ModerationRuleKindRegex   ModerationRuleKind = "REGEX"
+)
+
+var AllModerationRuleKind = []ModerationRuleKind{
+	ModerationRuleKindKeyword,
+	ModerationRuleKindRegex,
+}
+
+func (e ModerationRuleKind) IsValid() bool {
+	switch e {
+	case ModerationRuleKindKeyword, ModerationRuleKindRegex:
+		return true
+	}
+	return false
+}
+
+func (e ModerationRuleKind) String() string {
+	return string(e)
+}
+
+func (e *ModerationRuleKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ModerationRuleKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ModerationRuleKind", str)
+	}
+	return nil
+}
+
+func (e ModerationRuleKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ModerationRuleKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ModerationRuleKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PostVisibility string
+
+const (
+	PostVisibilityPublic    PostVisibility = "PUBLIC"
+	PostVisibilityUnlisted  PostVisibility = "UNLISTED"
+	PostVisibilityFollowers PostVisibility = "FOLLOWERS"
+	PostVisibilityPrivate   PostVisibility = "PRIVATE"
+)
+
+var AllPostVisibility = []PostVisibility{
+	PostVisibilityPublic,
+	PostVisibilityUnlisted,
+	PostVisibilityFollowers,
+	PostVisibilityPrivate,
+}
+
+func (e PostVisibility) IsValid() bool {
+	switch e {
+	case PostVisibilityPublic, PostVisibilityUnlisted, PostVisibilityFollowers, PostVisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+func (e PostVisibility) String() string {
+	return string(e)
+}
+
+func (e *PostVisibility) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostVisibility(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostVisibility", str)
+	}
+	return nil
+}
+
+func (e PostVisibility) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PostVisibility) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PostVisibility) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PurgeJobStatus string
+
+const (
+	PurgeJobStatusRunning   PurgeJobStatus = "RUNNING"
+	PurgeJobStatusCompleted PurgeJobStatus = "COMPLETED"
+	PurgeJobStatusFailed    PurgeJobStatus = "FAILED"
+)
+
+var AllPurgeJobStatus = []PurgeJobStatus{
+	PurgeJobStatusRunning,
+	PurgeJobStatusCompleted,
+	PurgeJobStatusFailed,
+}
+
+func (e PurgeJobStatus) IsValid() bool {
+	switch e {
+	case PurgeJobStatusRunning, PurgeJobStatusCompleted, PurgeJobStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e PurgeJobStatus) String() string {
+	return string(e)
+}
+
+func (e *PurgeJobStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PurgeJobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PurgeJobStatus", str)
+	}
+	return nil
+}
+
+func (e PurgeJobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PurgeJobStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PurgeJobStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PushPlatform string
+
+const (
+	PushPlatformFcm     PushPlatform = "FCM"
+	PushPlatformApns    PushPlatform = "APNS"
+	PushPlatformWebpush PushPlatform = "WEBPUSH"
+)
+
+var AllPushPlatform = []PushPlatform{
+	PushPlatformFcm,
+	PushPlatformApns,
+	PushPlatformWebpush,
+}
+
+func (e PushPlatform) IsValid() bool {
+	switch e {
+	case PushPlatformFcm, PushPlatformApns, PushPlatformWebpush:
+		return true
+	}
+	return false
+}
+
+func (e PushPlatform) String() string {
+	return string(e)
+}
+
+func (e *PushPlatform) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PushPlatform(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PushPlatform", str)
+	}
+	return nil
+}
+
+func (e PushPlatform) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PushPlatform) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PushPlatform) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "USER"
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleUser,
+	RoleModerator,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "ASC"
+	SortOrderDesc SortOrder = "DESC"
+	SortOrderBest SortOrder = "BEST"
+)
+
+var AllSortOrder = []SortOrder{
+	SortOrderAsc,
+	SortOrderDesc,
+	SortOrderBest,
+}
+
+func (e SortOrder) IsValid() bool {
+	switch e {
+	case SortOrderAsc, SortOrderDesc, SortOrderBest:
+		return true
+	}
+	return false
+}
+
+func (e SortOrder) String() string {
+	return string(e)
+}
+
+func (e *SortOrder) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortOrder", str)
+	}
+	return nil
+}
+
+func (e SortOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortOrder) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortOrder) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SpamStatus string
+
+const (
+	SpamStatusPending SpamStatus = "PENDING"
+	SpamStatusHam     SpamStatus = "HAM"
+	SpamStatusSpam    SpamStatus = "SPAM"
+)
+
+var AllSpamStatus = []SpamStatus{
+	SpamStatusPending,
+	SpamStatusHam,
+	SpamStatusSpam,
+}
+
+func (e SpamStatus) IsValid() bool {
+	switch e {
+	case SpamStatusPending, SpamStatusHam, SpamStatusSpam:
+		return true
+	}
+	return false
+}
+
+func (e SpamStatus) String() string {
+	return string(e)
+}
+
+func (e *SpamStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SpamStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SpamStatus", str)
+	}
+	return nil
+}
+
+func (e SpamStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SpamStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SpamStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SuggestionKind string
+
+const (
+	SuggestionKindTitle  SuggestionKind = "TITLE"
+	SuggestionKindTag    SuggestionKind = "TAG"
+	SuggestionKindAuthor SuggestionKind = "AUTHOR"
+)
+
+var AllSuggestionKind = []SuggestionKind{
+	SuggestionKindTitle,
+	SuggestionKindTag,
+	SuggestionKindAuthor,
+}
+
+func (e SuggestionKind) IsValid() bool {
+	switch e {
+	case SuggestionKindTitle, SuggestionKindTag, SuggestionKindAuthor:
+		return true
+	}
+	return false
+}
+
+func (e SuggestionKind) String() string {
+	return string(e)
+}
+
+func (e *SuggestionKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SuggestionKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SuggestionKind", str)
+	}
+	return nil
+}
+
+func (e SuggestionKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SuggestionKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SuggestionKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type VoteValue string
+
+const (
+	VoteValueUp   VoteValue = "UP"
+	VoteValueDown VoteValue = "DOWN"
+	VoteValueNone VoteValue = "NONE"
+)
+
+var AllVoteValue = []VoteValue{
+	VoteValueUp,
+	VoteValueDown,
+	VoteValueNone,
+}
+
+func (e VoteValue) IsValid() bool {
+	switch e {
+	case VoteValueUp, VoteValueDown, VoteValueNone:
+		return true
+	}
+	return false
+}
+
+func (e VoteValue) String() string {
+	return string(e)
+}
+
+func (e *VoteValue) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VoteValue(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VoteValue", str)
+	}
+	return nil
+}
+
+func (e VoteValue) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *VoteValue) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e VoteValue) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,263 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/moderation"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// roleModerator - роль пользователей, которым доступны мутации модерации
+const roleModerator = "moderator"
+
+// ShadowBanUser реализует мутацию shadowBanUser
+func (r *mutationResolver) ShadowBanUser(ctx context.Context, userID string, banned *bool) (bool, error) {
+	ban := banned == nil || *banned
+	log.Printf("Запуск мутации shadowBanUser: userID=%s, banned=%t", userID, ban)
+	if err := requireModerator(ctx); err != nil {
+		return false, err
+	}
+	if err := r.Storage.SetShadowBan(ctx, userID, ban); err != nil {
+		log.Printf("Ошибка при изменении теневого бана пользователя %s: %v", userID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to update shadow ban: %v", err)
+	}
+	log.Printf("Теневой бан пользователя %s: %t", userID, ban)
+	return ban, nil
+}
+
+// BlockAnonymousAuthor реализует мутацию blockAnonymousAuthor: теневой бан накладывается на анонимный
+// идентификатор автора комментария, который модератору не показывается
+func (r *mutationResolver) BlockAnonymousAuthor(ctx context.Context, commentID string, blocked *bool) (bool, error) {
+	block := blocked == nil || *blocked
+	log.Printf("Запуск мутации blockAnonymousAuthor: commentID=%s, blocked=%t", commentID, block)
+	if err := requireModerator(ctx); err != nil {
+		return false, err
+	}
+	comment, err := r.Storage.GetComment(ctx, commentID)
+	if err != nil {
+		log.Printf("Ошибка при получении комментария с ID=%s: %v", commentID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to get comment: %v", err)
+	}
+	if !models.IsAnonymousAuthor(comment.AuthorID) {
+		log.Printf("Ошибка: автор комментария %s не анонимный", commentID)
+		return false, gqlerrors.New(gqlerrors.CodeBadUserInput, "comment author is not anonymous")
+	}
+	if err := r.Storage.SetShadowBan(ctx, comment.AuthorID, block); err != nil {
+		log.Printf("Ошибка при блокировке анонимного автора комментария %s: %v", commentID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to update shadow ban: %v", err)
+	}
+	log.Printf("Анонимный автор комментария %s заблокирован: %t", commentID, block)
+	return block, nil
+}
+
+// requireModerator возвращает FORBIDDEN, если текущий пользователь не модератор
+func requireModerator(ctx context.Context) error {
+	if role, _ := ctx.Value("role").(string); role != roleModerator {
+		log.Printf("Ошибка: мутация модерации недоступна для роли %q", role)
+		return gqlerrors.New(gqlerrors.CodeForbidden, "moderator role required")
+	}
+	return nil
+}
+
+// editablePost возвращает пост, который текущий пользователь может изменять: автор или модератор.
+// Скрытый от пользователя пост для него не существует
+func (r *mutationResolver) editablePost(ctx context.Context, postID, message string) (*models.Post, error) {
+	userID, _ := ctx.Value("userID").(string)
+	post, err := storage.ViewPost(ctx, r.Storage, postID, userID)
+	if err != nil {
+		log.Printf("Ошибка при получении поста с ID=%s: %v", postID, err)
+		return nil, categoryError(message, err)
+	}
+	if userID == "" || post.AuthorID != userID {
+		if err := requireModerator(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return post, nil
+}
+
+// viewerContext передаёт хранилищу текущего пользователя, чтобы он видел своё скрытое содержимое
+func viewerContext(ctx context.Context) context.Context {
+	viewerID, _ := ctx.Value("userID").(string)
+	return storage.WithViewer(ctx, viewerID)
+}
+
+// ModerationRules реализует запрос moderationRules
+func (r *queryResolver) ModerationRules(ctx context.Context) ([]*ModerationRule, error) {
+	log.Println("Запрос moderationRules")
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	rules, err := r.Storage.ListModerationRules(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении правил автомодерации: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to list moderation rules: %v", err)
+	}
+	result := make([]*ModerationRule, len(rules))
+	for i := range rules {
+		result[i] = toModerationRule(&rules[i])
+	}
+	return result, nil
+}
+
+// HeldContent реализует запрос heldContent
+func (r *queryResolver) HeldContent(ctx context.Context, limit int) ([]*HeldContent, error) {
+	log.Printf("Запрос heldContent с limit=%d", limit)
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	items, err := r.Storage.ListHeldContent(ctx, limit)
+	if err != nil {
+		log.Printf("Ошибка при получении очереди проверки: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to list held content: %v", err)
+	}
+	result := make([]*HeldContent, 0, len(items))
+	for _, item := range items {
+		held := &HeldContent{ID: item.ID, RuleID: item.RuleID, CreatedAt: item.CreatedAt.Format(time.RFC3339)}
+		switch item.TargetType {
+		case models.TargetPost:
+			post, err := r.Storage.GetPost(ctx, item.TargetID)
+			if err != nil {
+				log.Printf("Задержанный пост %s не найден: %v", item.TargetID, err)
+				continue
+			}
+			held.Post = toPost(post)
+		case models.TargetComment:
+			comment, err := r.Storage.GetComment(ctx, item.TargetID)
+			if err != nil {
+				log.Printf("Задержанный комментарий %s не найден: %v", item.TargetID, err)
+				continue
+			}
+			held.Comment = toComment(comment)
+		}
+		result = append(result, held)
+	}
+	r.fillToxicityScores(ctx, result)
+	return result, nil
+}
+
+// CreateModerationRule реализует мутацию createModerationRule
+func (r *mutationResolver) CreateModerationRule(ctx context.Context, input ModerationRuleInput) (*ModerationRule, error) {
+	log.Printf("Запуск мутации createModerationRule: kind=%s, action=%s", input.Kind, input.Action)
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	userID, _ := ctx.Value("userID").(string)
+	rule := fromModerationRuleInput(input)
+	rule.ID = r.IDs.New()
+	rule.CreatedBy = userID
+	rule.CreatedAt = r.Clock.Now()
+	if err := moderation.Validate(rule); err != nil {
+		log.Printf("Ошибка: некорректное правило автомодерации: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid moderation rule: %v", err)
+	}
+	if err := r.Storage.CreateModerationRule(ctx, rule); err != nil {
+		log.Printf("Ошибка при создании правила автомодерации: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to create moderation rule: %v", err)
+	}
+	r.invalidateRules()
+	return toModerationRule(rule), nil
+}
+
+// UpdateModerationRule реализует мутацию updateModerationRule
+func (r *mutationResolver) UpdateModerationRule(ctx context.Context, id string, input ModerationRuleInput) (*ModerationRule, error) {
+	log.Printf("Запуск мутации updateModerationRule: id=%s, kind=%s, action=%s", id, input.Kind, input.Action)
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	rule := fromModerationRuleInput(input)
+	rule.ID = id
+	if err := moderation.Validate(rule); err != nil {
+		log.Printf("Ошибка: некорректное правило автомодерации: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid moderation rule: %v", err)
+	}
+	if err := r.Storage.UpdateModerationRule(ctx, rule); err != nil {
+		log.Printf("Ошибка при обновлении правила автомодерации %s: %v", id, err)
+		return nil, ruleError("failed to update moderation rule", err)
+	}
+	r.invalidateRules()
+	return toModerationRule(rule), nil
+}
+
+// DeleteModerationRule реализует мутацию deleteModerationRule
+func (r *mutationResolver) DeleteModerationRule(ctx context.Context, id string) (bool, error) {
+	log.Printf("Запуск мутации deleteModerationRule: id=%s", id)
+	if err := requireModerator(ctx); err != nil {
+		return false, err
+	}
+	if err := r.Storage.DeleteModerationRule(ctx, id); err != nil {
+		log.Printf("Ошибка при удалении правила автомодерации %s: %v", id, err)
+		return false, ruleError("failed to delete moderation rule", err)
+	}
+	r.invalidateRules()
+	return true, nil
+}
+
+// ReviewHeldContent реализует мутацию reviewHeldContent
+func (r *mutationResolver) ReviewHeldContent(ctx context.Context, id string, approve bool) (bool, error) {
+	log.Printf("Запуск мутации reviewHeldContent: id=%s, approve=%t", id, approve)
+	if err := requireModerator(ctx); err != nil {
+		return false, err
+	}
+	if err := r.Storage.ResolveHeldContent(ctx, id, approve); err != nil {
+		log.Printf("Ошибка при проверке задержанного содержимого %s: %v", id, err)
+		if errors.Is(err, storage.ErrHeldItemNotFound) {
+			return false, gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to review held content: %v", err)
+		}
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to review held content: %v", err)
+	}
+	return true, nil
+}
+
+// moderate проверяет тексты правилами автомодерации, если они включены; BLOCK возвращается ошибкой
+func (r *mutationResolver) moderate(ctx context.Context, texts ...string) (moderation.Verdict, error) {
+	if r.Moderation == nil {
+		return moderation.Verdict{}, nil
+	}
+	verdict, err := r.Moderation.Evaluate(ctx, texts...)
+	if err != nil {
+		log.Printf("Ошибка проверки правилами автомодерации: %v", err)
+		return verdict, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to apply moderation rules: %v", err)
+	}
+	if verdict.Blocked() {
+		log.Printf("Содержимое отклонено правилом автомодерации %s", verdict.RuleID)
+		return verdict, gqlerrors.New(gqlerrors.CodeContentBlocked, "content rejected by moderation rules")
+	}
+	return verdict, nil
+}
+
+// hold ставит скрытое правилом содержимое в очередь проверки
+func (r *mutationResolver) hold(ctx context.Context, targetType, targetID string, verdict moderation.Verdict) error {
+	err := r.Storage.HoldContent(ctx, &models.HeldItem{
+		ID:         r.IDs.New(),
+		TargetType: targetType,
+		TargetID:   targetID,
+		RuleID:     verdict.RuleID,
+		CreatedAt:  r.Clock.Now(),
+	})
+	if err != nil {
+		log.Printf("Ошибка при постановке %s в очередь проверки: %v", targetID, err)
+		return gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to hold content for review: %v", err)
+	}
+	return nil
+}
+
+// invalidateRules применяет изменённые правила без ожидания периодического обновления
+func (r *mutationResolver) invalidateRules() {
+	if r.Moderation != nil {
+		r.Moderation.Invalidate()
+	}
+}
+
+// ruleError возвращает NOT_FOUND для отсутствующего правила, иначе INTERNAL
+func ruleError(message string, err error) error {
+	if errors.Is(err, storage.ErrRuleNotFound) {
+		return gqlerrors.Errorf(gqlerrors.CodeNotFound, "%s: %v", message, err)
+	}
+	return gqlerrors.Errorf(gqlerrors.CodeInternal, "%s: %v", message, err)
+}
@@ -0,0 +1,170 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/moderation"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newModerationResolver() *Resolver {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	resolver.Moderation = moderation.New(store, moderation.Options{})
+	return resolver
+}
+
+func userContext(userID, role string) context.Context {
+	return context.WithValue(context.WithValue(context.Background(), "userID", userID), "role", role)
+}
+
+func TestModerationRules_RequireModerator(t *testing.T) {
+	resolver := newModerationResolver()
+	ctx := userContext("user1", "")
+
+	_, err := resolver.Query().ModerationRules(ctx)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	_, err = resolver.Mutation().CreateModerationRule(ctx, ModerationRuleInput{Kind: ModerationRuleKindKeyword, Pattern: "спам", Action: ModerationActionBlock})
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	_, err = resolver.Query().HeldContent(ctx, 10)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+}
+
+func TestModerationRules_CRUD(t *testing.T) {
+	resolver := newModerationResolver()
+	ctx := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+
+	_, err := mutation.CreateModerationRule(ctx, ModerationRuleInput{Kind: ModerationRuleKindRegex, Pattern: "(", Action: ModerationActionBlock})
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err), "Некорректное выражение отклоняется при сохранении")
+
+	rule, err := mutation.CreateModerationRule(ctx, ModerationRuleInput{Kind: ModerationRuleKindKeyword, Pattern: "спам", Action: ModerationActionBlock})
+	require.NoError(t, err)
+	assert.Equal(t, "mod1", rule.CreatedBy)
+
+	tag := "ads"
+	updated, err := mutation.UpdateModerationRule(ctx, rule.ID, ModerationRuleInput{Kind: ModerationRuleKindKeyword, Pattern: "реклама", Action: ModerationActionTag, Tag: &tag})
+	require.NoError(t, err)
+	assert.Equal(t, ModerationActionTag, updated.Action)
+	assert.Equal(t, &tag, updated.Tag)
+
+	rules, err := resolver.Query().ModerationRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "реклама", rules[0].Pattern)
+
+	deleted, err := mutation.DeleteModerationRule(ctx, rule.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = mutation.DeleteModerationRule(ctx, rule.ID)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+}
+
+func TestModeration_AppliedOnCreate(t *testing.T) {
+	resolver := newModerationResolver()
+	mod := userContext("mod1", "moderator")
+	author := userContext("user2", "")
+	other := userContext("user1", "")
+	mutation := resolver.Mutation()
+	spoiler := "spoiler"
+	for _, input := range []ModerationRuleInput{
+		{Kind: ModerationRuleKindKeyword, Pattern: "казино", Action: ModerationActionBlock},
+		{Kind: ModerationRuleKindKeyword, Pattern: "скидка", Action: ModerationActionHold},
+		{Kind: ModerationRuleKindKeyword, Pattern: "финал", Action: ModerationActionTag, Tag: &spoiler},
+	} {
+		_, err := mutation.CreateModerationRule(mod, input)
+		require.NoError(t, err)
+	}
+
+	_, err := mutation.CreatePost(author, "Лучшее казино", "", true, nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeContentBlocked, gqlerrors.Code(err))
+
+	post, err := mutation.CreatePost(author, "Обсуждение", "Кто смотрел финал?", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spoiler"}, post.Tags)
+
+	held, err := mutation.CreatePost(author, "Скидка для всех", "", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = resolver.Query().Post(other, held.ID)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), "Задержанный пост скрыт от других пользователей")
+	_, err = resolver.Query().Post(author, held.ID)
+	assert.NoError(t, err, "Автор видит свой задержанный пост")
+
+	_, err = mutation.CreateComment(author, post.ID, nil, "Большая скидка", nil, nil, nil)
+	require.NoError(t, err)
+	replies, err := resolver.Storage.GetComments(viewerContext(other), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	assert.Empty(t, replies.Comments, "Задержанный комментарий скрыт от других пользователей")
+
+	queue, err := resolver.Query().HeldContent(mod, 10)
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+	assert.Equal(t, held.ID, queue[0].Post.ID)
+	require.NotNil(t, queue[1].Comment)
+
+	for _, item := range queue {
+		ok, err := mutation.ReviewHeldContent(mod, item.ID, true)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	_, err = resolver.Query().Post(other, held.ID)
+	assert.NoError(t, err, "Одобренный пост виден всем")
+	replies, err = resolver.Storage.GetComments(viewerContext(other), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	assert.Len(t, replies.Comments, 1, "Одобренный комментарий виден всем")
+
+	_, err = mutation.ReviewHeldContent(mod, queue[0].ID, true)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+}
+
+func TestAnonymousComments(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	mutation := resolver.Mutation()
+	anonymous := func(identity string) context.Context {
+		return context.WithValue(context.Background(), "anonymousID", identity)
+	}
+	first, err := mutation.CreatePost(userContext("author", ""), "Первый", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	second, err := mutation.CreatePost(userContext("author", ""), "Второй", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	a1, err := mutation.CreateComment(anonymous("identity-a"), first.ID, nil, "Первый", nil, nil, nil)
+	require.NoError(t, err)
+	a2, err := mutation.CreateComment(anonymous("identity-a"), first.ID, nil, "Второй", nil, nil, nil)
+	require.NoError(t, err)
+	b1, err := mutation.CreateComment(anonymous("identity-b"), first.ID, nil, "Третий", nil, nil, nil)
+	require.NoError(t, err)
+	a3, err := mutation.CreateComment(anonymous("identity-a"), second.ID, nil, "Четвёртый", nil, nil, nil)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^anon-[0-9a-f]{6}$`, a1.AuthorID)
+	assert.Equal(t, a1.AuthorID, a2.AuthorID, "Псевдоним постоянен в пределах поста")
+	assert.NotEqual(t, a1.AuthorID, b1.AuthorID)
+	assert.NotEqual(t, a1.AuthorID, a3.AuthorID, "В другом посте псевдоним другой")
+	stored, err := store.GetComment(context.Background(), a1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AnonymousAuthorID("identity-a"), stored.AuthorID)
+
+	_, err = mutation.BlockAnonymousAuthor(userContext("user1", ""), a1.ID, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	moderator := userContext("mod1", roleModerator)
+	registered, err := mutation.CreateComment(userContext("user2", ""), first.ID, nil, "Обычный", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.BlockAnonymousAuthor(moderator, registered.ID, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	blocked, err := mutation.BlockAnonymousAuthor(moderator, a1.ID, nil)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+	hidden, err := mutation.CreateComment(anonymous("identity-a"), second.ID, nil, "После блокировки", nil, nil, nil)
+	require.NoError(t, err)
+	stored, err = store.GetComment(context.Background(), hidden.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Hidden, "Блокировка действует во всех постах")
+}
@@ -0,0 +1,41 @@
+package graphql
+
+import (
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// pageCursor переводит номер страницы в постраничный курсор для порядка order. Без page возвращает cursor.
+// Первая страница не требует курсора, остальные читаются через OFFSET не глубже storage.MaxOffset
+func pageCursor(page *int, cursor *string, limit int, order models.SortOrder) (*string, error) {
+	if page == nil {
+		return cursor, nil
+	}
+	if cursor != nil {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "page and cursor cannot be used together")
+	}
+	if *page < 1 {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "page must be positive")
+	}
+	if limit < 1 {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "limit must be positive to use page")
+	}
+	if *page == 1 {
+		return nil, nil
+	}
+	offset := (*page - 1) * limit
+	if offset > storage.MaxOffset || offset/limit != *page-1 {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "page %d is too deep: at most %d items can be skipped, use nextCursor", *page, storage.MaxOffset)
+	}
+	encoded := storage.EncodeOffsetCursor(order, offset)
+	return &encoded, nil
+}
+
+// pageCount возвращает число страниц по limit элементов
+func pageCount(totalCount, limit int) int {
+	if limit < 1 {
+		return 0
+	}
+	return (totalCount + limit - 1) / limit
+}
@@ -0,0 +1,70 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPages(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+	var postIDs []string
+	for i := 0; i < 5; i++ {
+		post, err := mutation.CreatePost(user, fmt.Sprintf("Пост %d", i), "Содержимое", true, nil, nil, nil, nil)
+		require.NoError(t, err)
+		postIDs = append(postIDs, post.ID)
+	}
+	query := resolver.Query()
+	page := func(n int) *int { return &n }
+
+	result, err := query.Posts(user, 2, nil, nil, nil, page(2), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.TotalCount)
+	assert.Equal(t, 3, result.PageCount)
+	require.Len(t, result.Posts, 2)
+	assert.Equal(t, postIDs[2], result.Posts[0].ID, "Посты идут от новых к старым")
+	assert.Equal(t, postIDs[1], result.Posts[1].ID)
+	require.NotNil(t, result.NextCursor)
+	next, err := query.Posts(user, 2, result.NextCursor, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, next.Posts, 1)
+	assert.Equal(t, postIDs[0], next.Posts[0].ID)
+
+	result, err = query.Posts(user, 2, nil, nil, nil, page(4), nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Posts)
+
+	_, err = query.Posts(user, 2, nil, nil, nil, page(0), nil, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	cursor := "cursor"
+	_, err = query.Posts(user, 2, &cursor, nil, nil, page(2), nil, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err), "page и cursor взаимоисключающие")
+	_, err = query.Posts(user, 100, nil, nil, nil, page(102), nil, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err), "Глубокие страницы запрещены")
+
+	var commentIDs []string
+	for i := 0; i < 3; i++ {
+		comment, err := mutation.CreateComment(user, postIDs[0], nil, fmt.Sprintf("Комментарий %d", i), nil, nil, nil)
+		require.NoError(t, err)
+		commentIDs = append(commentIDs, comment.ID)
+	}
+	ctx := context.WithValue(user, "commentLoader", NewCommentLoader(store))
+	asc := SortOrderAsc
+	comments, err := resolver.Post().Comments(ctx, &Post{ID: postIDs[0]}, 2, nil, &asc, page(2), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, comments.PageCount)
+	require.Len(t, comments.Comments, 1)
+	assert.Equal(t, commentIDs[2], comments.Comments[0].ID)
+
+	comments, err = resolver.Post().Comments(ctx, &Post{ID: postIDs[0]}, 2, nil, &asc, page(-1), nil)
+	require.NoError(t, err)
+	assert.Empty(t, comments.Comments, "Ошибка страницы не скрывает пост")
+}
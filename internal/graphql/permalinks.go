@@ -0,0 +1,90 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// CommentPermalink реализует запрос commentPermalink. Комментарий, его пост и все предки должны быть
+// видны текущему пользователю, иначе комментарий считается несуществующим
+func (r *queryResolver) CommentPermalink(ctx context.Context, commentID string, limit int, order *SortOrder) (*CommentPermalink, error) {
+	log.Printf("Запрос commentPermalink: commentID=%s, limit=%d, order=%v", commentID, limit, order)
+	if limit < 1 {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "limit must be positive")
+	}
+	viewerID, _ := ctx.Value("userID").(string)
+	comment, err := r.visibleComment(ctx, commentID, viewerID)
+	if err != nil {
+		return nil, commentError("failed to resolve comment permalink", err)
+	}
+	post, err := storage.ViewPost(ctx, r.Storage, comment.PostID, viewerID)
+	if err != nil {
+		log.Printf("Ошибка при получении поста %s комментария %s: %v", comment.PostID, commentID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to resolve comment permalink: %v", err)
+	}
+
+	// Подъём к корню ветки; посещённые комментарии защищают от зацикленных parentId
+	root := comment
+	visited := map[string]bool{root.ID: true}
+	for root.ParentID != nil {
+		if visited[*root.ParentID] {
+			log.Printf("Цикл в ветке комментария %s на %s", commentID, *root.ParentID)
+			return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to resolve comment permalink: comment thread has a cycle")
+		}
+		visited[*root.ParentID] = true
+		if root, err = r.visibleComment(ctx, *root.ParentID, viewerID); err != nil {
+			return nil, commentError("failed to resolve comment permalink", err)
+		}
+	}
+
+	sortOrder := r.commentOrder(ctx, order)
+	position, err := r.commentPosition(ctx, comment.ID, limit, sortOrder)
+	if err != nil {
+		return nil, err
+	}
+	rootPosition := position
+	if root.ID != comment.ID {
+		if rootPosition, err = r.commentPosition(ctx, root.ID, limit, sortOrder); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Комментарий %s: корень %s на странице %d, комментарий на странице %d", commentID, root.ID, rootPosition.Page, position.Page)
+	return &CommentPermalink{
+		Comment:      toComment(comment),
+		Post:         toPost(post),
+		Root:         toComment(root),
+		Order:        SortOrder(sortOrder),
+		RootPosition: rootPosition,
+		Position:     position,
+	}, nil
+}
+
+// visibleComment возвращает комментарий или ErrCommentNotFound, если он скрыт от viewerID
+func (r *queryResolver) visibleComment(ctx context.Context, id, viewerID string) (*models.Comment, error) {
+	comment, err := r.Storage.GetComment(ctx, id)
+	if err == nil && !storage.CommentVisibleTo(comment, viewerID) {
+		err = storage.ErrCommentNotFound
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении комментария с ID=%s: %v", id, err)
+		return nil, err
+	}
+	return comment, nil
+}
+
+// commentPosition возвращает положение комментария среди комментариев того же уровня в порядке order.
+// Страницы глубже storage.MaxOffset доступны только по nextCursor, и курсор для них не возвращается
+func (r *queryResolver) commentPosition(ctx context.Context, commentID string, limit int, order models.SortOrder) (*CommentPosition, error) {
+	index, err := r.Storage.CountCommentsBefore(viewerContext(ctx), commentID, order)
+	if err != nil {
+		log.Printf("Ошибка при подсчёте позиции комментария %s: %v", commentID, err)
+		return nil, commentError("failed to resolve comment permalink", err)
+	}
+	page := index/limit + 1
+	cursor, _ := pageCursor(&page, nil, limit, order)
+	return &CommentPosition{Index: index, Page: page, Cursor: cursor}, nil
+}
@@ -0,0 +1,76 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentPermalink(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	resolver.Clock = fake
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(user, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	var roots []*Comment
+	for i := 0; i < 5; i++ {
+		fake.Advance(time.Second)
+		comment, err := mutation.CreateComment(user, post.ID, nil, fmt.Sprintf("Комментарий %d", i), nil, nil, nil)
+		require.NoError(t, err)
+		roots = append(roots, comment)
+	}
+	var replies []*Comment
+	for i := 0; i < 3; i++ {
+		fake.Advance(time.Second)
+		reply, err := mutation.CreateComment(user, post.ID, &roots[1].ID, fmt.Sprintf("Ответ %d", i), nil, nil, nil)
+		require.NoError(t, err)
+		replies = append(replies, reply)
+	}
+	nested, err := mutation.CreateComment(user, post.ID, &replies[2].ID, "Ответ на ответ", nil, nil, nil)
+	require.NoError(t, err)
+	query := resolver.Query()
+	asc := SortOrderAsc
+
+	link, err := query.CommentPermalink(user, replies[2].ID, 2, &asc)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, link.Post.ID)
+	assert.Equal(t, roots[1].ID, link.Root.ID)
+	assert.Equal(t, SortOrderAsc, link.Order)
+	assert.Equal(t, &CommentPosition{Index: 1, Page: 1}, link.RootPosition)
+	assert.Equal(t, 2, link.Position.Index)
+	assert.Equal(t, 2, link.Position.Page)
+	require.NotNil(t, link.Position.Cursor)
+
+	// Курсор ведёт на страницу, где находится комментарий
+	page, err := resolver.Comment().Replies(user, link.Root, 2, link.Position.Cursor, &asc, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Comments, 1)
+	assert.Equal(t, replies[2].ID, page.Comments[0].ID)
+
+	link, err = query.CommentPermalink(user, nested.ID, 2, nil)
+	require.NoError(t, err)
+	assert.Equal(t, roots[1].ID, link.Root.ID, "Корень находится через всех предков")
+	assert.Equal(t, SortOrderDesc, link.Order, "Без order - порядок по умолчанию")
+	assert.Equal(t, 3, link.RootPosition.Index)
+	assert.Equal(t, 2, link.RootPosition.Page)
+
+	link, err = query.CommentPermalink(user, roots[4].ID, 2, &asc)
+	require.NoError(t, err)
+	assert.Equal(t, link.RootPosition, link.Position, "Для корневого комментария позиции совпадают")
+	assert.Equal(t, 3, link.Position.Page)
+
+	_, err = query.CommentPermalink(user, "missing", 2, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = query.CommentPermalink(context.Background(), roots[0].ID, 0, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
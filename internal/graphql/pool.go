@@ -0,0 +1,123 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Пулы объектов GraphQL для страниц постов и комментариев. Объекты берутся из пулов только
+// в операции с ареной и возвращаются, когда ответ операции полностью сформирован
+var (
+	postPool    = sync.Pool{New: func() any { return new(Post) }}
+	commentPool = sync.Pool{New: func() any { return new(Comment) }}
+)
+
+// arenaKey - ключ контекста операции с arena
+type arenaKey struct{}
+
+// arena - объекты, выданные операции из пулов
+type arena struct {
+	mu       sync.Mutex
+	posts    []*Post
+	comments []*Comment
+}
+
+func (a *arena) post() *Post {
+	p := postPool.Get().(*Post)
+	a.mu.Lock()
+	a.posts = append(a.posts, p)
+	a.mu.Unlock()
+	return p
+}
+
+func (a *arena) comment() *Comment {
+	c := commentPool.Get().(*Comment)
+	a.mu.Lock()
+	a.comments = append(a.comments, c)
+	a.mu.Unlock()
+	return c
+}
+
+// release очищает объекты, чтобы пул не удерживал содержимое, и возвращает их в пулы
+func (a *arena) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, p := range a.posts {
+		*p = Post{}
+		postPool.Put(p)
+	}
+	for _, c := range a.comments {
+		*c = Comment{}
+		commentPool.Put(c)
+	}
+	a.posts, a.comments = nil, nil
+}
+
+// convertPosts конвертирует страницу постов, беря объекты из пула, если у операции есть арена
+func convertPosts(ctx context.Context, posts []*models.Post) []*Post {
+	a, _ := ctx.Value(arenaKey{}).(*arena)
+	result := make([]*Post, len(posts))
+	for i, p := range posts {
+		if a != nil {
+			result[i] = fillPost(a.post(), p)
+		} else {
+			result[i] = toPost(p)
+		}
+	}
+	return result
+}
+
+// convertComments конвертирует страницу комментариев, беря объекты из пула, если у операции есть арена
+func convertComments(ctx context.Context, comments []models.Comment) []*Comment {
+	a, _ := ctx.Value(arenaKey{}).(*arena)
+	result := make([]*Comment, len(comments))
+	for i := range comments {
+		if a != nil {
+			result[i] = fillComment(a.comment(), &comments[i])
+		} else {
+			result[i] = toComment(&comments[i])
+		}
+	}
+	return result
+}
+
+// Arena - расширение GraphQL-сервера, которое выдаёт запросам и мутациям арену для объектов страниц
+// и возвращает объекты в пулы после последнего ответа операции, в том числе после частей @defer.
+// Подписки арену не получают: их объекты живут, пока событие доставляется подписчикам
+type Arena struct{}
+
+var _ interface {
+	graphql.OperationInterceptor
+	graphql.HandlerExtension
+} = Arena{}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (Arena) ExtensionName() string {
+	return "ObjectArena"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (Arena) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation реализует graphql.OperationInterceptor
+func (Arena) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil || oc.Operation.Operation == ast.Subscription {
+		return next(ctx)
+	}
+	a := &arena{}
+	handler := next(context.WithValue(ctx, arenaKey{}, a))
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		if resp == nil || resp.HasNext == nil || !*resp.HasNext {
+			a.release()
+		}
+		return resp
+	}
+}
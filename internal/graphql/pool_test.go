@@ -0,0 +1,124 @@
+package graphql
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArena(t *testing.T) {
+	t.Run("Converted like without arena", func(t *testing.T) {
+		posts := benchmarkPosts(3)
+		ctx := context.WithValue(context.Background(), arenaKey{}, &arena{})
+		assert.Equal(t, convertPosts(context.Background(), posts), convertPosts(ctx, posts))
+
+		comments := benchmarkComments(3)
+		assert.Equal(t, convertComments(context.Background(), comments), convertComments(ctx, comments))
+	})
+
+	t.Run("Release clears objects", func(t *testing.T) {
+		a := &arena{}
+		ctx := context.WithValue(context.Background(), arenaKey{}, a)
+		posts := convertPosts(ctx, benchmarkPosts(2))
+		comments := convertComments(ctx, benchmarkComments(2))
+		a.release()
+
+		for _, p := range posts {
+			assert.Equal(t, Post{}, *p)
+		}
+		for _, c := range comments {
+			assert.Equal(t, Comment{}, *c)
+		}
+		assert.Empty(t, a.posts)
+		assert.Empty(t, a.comments)
+	})
+
+	t.Run("Precomputed time matches formatting", func(t *testing.T) {
+		post := benchmarkPosts(1)[0]
+		post.PrecomputeTimes()
+		assert.Equal(t, post.CreatedAt.Format(time.RFC3339), toPost(post).CreatedAt)
+
+		// Изменённое после форматирования время форматируется заново
+		post.CreatedAt = post.CreatedAt.Add(time.Hour)
+		assert.Equal(t, post.CreatedAt.Format(time.RFC3339), toPost(post).CreatedAt)
+	})
+}
+
+func benchmarkPosts(n int) []*models.Post {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	posts := make([]*models.Post, n)
+	for i := range posts {
+		posts[i] = &models.Post{
+			ID:            "post" + strconv.Itoa(i),
+			Title:         "Title " + strconv.Itoa(i),
+			Content:       "Content",
+			AuthorID:      "user1",
+			AllowComments: true,
+			CreatedAt:     created.Add(time.Duration(i) * time.Minute),
+			Language:      "en",
+		}
+		posts[i].PrecomputeTimes()
+	}
+	return posts
+}
+
+func benchmarkComments(n int) []models.Comment {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	comments := make([]models.Comment, n)
+	for i := range comments {
+		comments[i] = models.Comment{
+			ID:        "comment" + strconv.Itoa(i),
+			PostID:    "post1",
+			AuthorID:  "user1",
+			Content:   "Content",
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+			Language:  "en",
+		}
+		comments[i].PrecomputeTimes()
+	}
+	return comments
+}
+
+// BenchmarkConvertPosts сравнивает выделения памяти при конвертации страницы из 100 постов
+// с ареной и без неё
+func BenchmarkConvertPosts(b *testing.B) {
+	posts := benchmarkPosts(100)
+	b.Run("Unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			convertPosts(context.Background(), posts)
+		}
+	})
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			a := &arena{}
+			convertPosts(context.WithValue(context.Background(), arenaKey{}, a), posts)
+			a.release()
+		}
+	})
+}
+
+// BenchmarkConvertComments сравнивает выделения памяти при конвертации страницы из 100 комментариев
+// с ареной и без неё
+func BenchmarkConvertComments(b *testing.B) {
+	comments := benchmarkComments(100)
+	b.Run("Unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			convertComments(context.Background(), comments)
+		}
+	})
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			a := &arena{}
+			convertComments(context.WithValue(context.Background(), arenaKey{}, a), comments)
+			a.release()
+		}
+	})
+}
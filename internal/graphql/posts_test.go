@@ -0,0 +1,99 @@
+package graphql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPosts_Filter(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	mutation := resolver.Mutation()
+	first, err := mutation.CreatePost(userContext("user1", ""), "Первый", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	second, err := mutation.CreatePost(userContext("user2", ""), "Второй", "Содержимое", false, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(userContext("user2", ""), first.ID, nil, "Комментарий", nil, nil, nil)
+	require.NoError(t, err)
+	query := resolver.Query()
+
+	author := "user2"
+	page, err := query.Posts(userContext("user1", ""), 10, nil, nil, nil, nil, &PostFilterInput{AuthorID: &author}, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 1)
+	assert.Equal(t, second.ID, page.Posts[0].ID)
+
+	hasComments := true
+	after := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	page, err = query.Posts(userContext("user1", ""), 10, nil, nil, nil, nil, &PostFilterInput{CreatedAfter: &after, HasComments: &hasComments}, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 1)
+	assert.Equal(t, first.ID, page.Posts[0].ID)
+
+	before := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	page, err = query.Posts(userContext("user1", ""), 10, nil, nil, nil, nil, &PostFilterInput{CreatedBefore: &before}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Posts)
+
+	invalid := "вчера"
+	_, err = query.Posts(userContext("user1", ""), 10, nil, nil, nil, nil, &PostFilterInput{CreatedAfter: &invalid}, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
+
+func TestPosts_Snapshot(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	resolver.Clock = fake
+	mutation := resolver.Mutation()
+	user := userContext("user1", "")
+	var created []string
+	for i := 0; i < 4; i++ {
+		fake.Advance(time.Second)
+		post, err := mutation.CreatePost(user, "Пост", "Содержимое", true, nil, nil, nil, nil)
+		require.NoError(t, err)
+		created = append(created, post.ID)
+	}
+	query := resolver.Query()
+	two := 2
+
+	first, err := query.Posts(user, 2, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, first.Snapshot)
+	assert.Equal(t, []string{created[3], created[2]}, idsOf(first.Posts))
+
+	// Пока клиент листает, появляется новый пост
+	fake.Advance(time.Second)
+	_, err = mutation.CreatePost(user, "Новый", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	second, err := query.Posts(user, 2, nil, nil, nil, &two, nil, &first.Snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, []string{created[1], created[0]}, idsOf(second.Posts), "Страницы снимка не сдвигаются")
+	assert.Equal(t, 4, second.TotalCount)
+	assert.Equal(t, first.Snapshot, second.Snapshot)
+
+	shifted, err := query.Posts(user, 2, nil, nil, nil, &two, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{created[2], created[1]}, idsOf(shifted.Posts), "Без снимка новый пост сдвигает страницы")
+	assert.Equal(t, 5, shifted.TotalCount)
+
+	invalid := "не токен"
+	_, err = query.Posts(user, 2, nil, nil, nil, nil, nil, &invalid)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	cursor := encodeSnapshot(fake.Now())[1:]
+	_, err = query.Posts(user, 2, nil, nil, nil, nil, nil, &cursor)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
+
+func idsOf(posts []*Post) []string {
+	result := make([]string, len(posts))
+	for i, p := range posts {
+		result[i] = p.ID
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package graphql
+
+import (
+	"context"
+	"log"
+	"net/mail"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// supportedLocales - языки интерфейса, которые можно выбрать в настройках
+var supportedLocales = map[string]bool{
+	"ru": true,
+	"en": true,
+}
+
+// Me реализует запрос me
+func (r *queryResolver) Me(ctx context.Context) (*User, error) {
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Запрос me без авторизации")
+		return nil, nil
+	}
+	return &User{ID: userID}, nil
+}
+
+// Preferences реализует поле preferences в User
+func (r *userResolver) Preferences(ctx context.Context, obj *User) (*Preferences, error) {
+	prefs, err := r.userPreferences(ctx, obj.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении настроек пользователя %s: %v", obj.ID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get preferences: %v", err)
+	}
+	return toPreferences(prefs), nil
+}
+
+// UpdatePreferences реализует мутацию updatePreferences
+func (r *mutationResolver) UpdatePreferences(ctx context.Context, input PreferencesInput) (*Preferences, error) {
+	log.Printf("Запуск мутации updatePreferences: %+v", input)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: изменение настроек без авторизации")
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	if input.DefaultCommentSort != nil && !input.DefaultCommentSort.IsValid() {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid comment sort %s", *input.DefaultCommentSort)
+	}
+	if input.Locale != nil && !supportedLocales[*input.Locale] {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "locale %s is not supported", *input.Locale)
+	}
+	if input.DigestFrequency != nil && !input.DigestFrequency.IsValid() {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid digest frequency %s", *input.DigestFrequency)
+	}
+	var address string
+	if input.Email != nil && *input.Email != "" {
+		parsed, err := mail.ParseAddress(*input.Email)
+		if err != nil {
+			return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid email: %v", err)
+		}
+		address = parsed.Address
+	}
+	prefs, err := r.userPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка при получении настроек пользователя %s: %v", userID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get preferences: %v", err)
+	}
+	if input.EmailOnReply != nil {
+		prefs.EmailOnReply = *input.EmailOnReply
+	}
+	if input.EmailOnMention != nil {
+		prefs.EmailOnMention = *input.EmailOnMention
+	}
+	if input.DefaultCommentSort != nil {
+		prefs.DefaultCommentSort = models.SortOrder(*input.DefaultCommentSort)
+	}
+	if input.Locale != nil {
+		prefs.Locale = *input.Locale
+	}
+	if input.Email != nil {
+		prefs.Email = address
+	}
+	if input.DigestFrequency != nil {
+		prefs.DigestFrequency = string(*input.DigestFrequency)
+	}
+	if input.PushOnReply != nil {
+		prefs.PushOnReply = *input.PushOnReply
+	}
+	if input.PushOnMention != nil {
+		prefs.PushOnMention = *input.PushOnMention
+	}
+	prefs.UpdatedAt = r.Clock.Now()
+	if err := r.Storage.SavePreferences(ctx, prefs); err != nil {
+		log.Printf("Ошибка при сохранении настроек пользователя %s: %v", userID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to save preferences: %v", err)
+	}
+	log.Printf("Настройки пользователя %s обновлены", userID)
+	return toPreferences(prefs), nil
+}
+
+// userPreferences возвращает настройки пользователя; пока он их не сохранял, порядок комментариев
+// берётся из настроек сообщества
+func (r *Resolver) userPreferences(ctx context.Context, userID string) (*models.Preferences, error) {
+	prefs, err := r.Storage.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs.UpdatedAt.IsZero() {
+		prefs.DefaultCommentSort = tenantSettings(ctx, r.Storage).DefaultCommentSort
+	}
+	return prefs, nil
+}
+
+// commentOrder возвращает порядок комментариев из запроса, а без него - из настроек текущего пользователя
+// или, без авторизации, из настроек сообщества. Ошибка чтения настроек не ломает ответ: используется порядок по умолчанию
+func (r *Resolver) commentOrder(ctx context.Context, order *SortOrder) models.SortOrder {
+	if order != nil {
+		return sortOrderOrDefault(order)
+	}
+	var preferred SortOrder
+	if userID, _ := ctx.Value("userID").(string); userID == "" {
+		preferred = SortOrder(tenantSettings(ctx, r.Storage).DefaultCommentSort)
+	} else {
+		prefs, err := r.userPreferences(ctx, userID)
+		if err != nil {
+			log.Printf("Ошибка при получении настроек пользователя %s, используется порядок по умолчанию: %v", userID, err)
+			return models.SortDesc
+		}
+		preferred = SortOrder(prefs.DefaultCommentSort)
+	}
+	return sortOrderOrDefault(&preferred)
+}
+
+func toPreferences(prefs *models.Preferences) *Preferences {
+	result := &Preferences{
+		EmailOnReply:       prefs.EmailOnReply,
+		EmailOnMention:     prefs.EmailOnMention,
+		DefaultCommentSort: SortOrder(prefs.DefaultCommentSort),
+		Locale:             prefs.Locale,
+		DigestFrequency:    DigestFrequency(prefs.DigestFrequency),
+		PushOnReply:        prefs.PushOnReply,
+		PushOnMention:      prefs.PushOnMention,
+	}
+	if prefs.Email != "" {
+		address := prefs.Email
+		result.Email = &address
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdatePreferences(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+
+	me, err := resolver.Query().Me(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, me)
+	_, err = mutation.UpdatePreferences(context.Background(), PreferencesInput{})
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	locale := "fr"
+	_, err = mutation.UpdatePreferences(user, PreferencesInput{Locale: &locale})
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	me, err = resolver.Query().Me(user)
+	require.NoError(t, err)
+	prefs, err := resolver.User().Preferences(user, me)
+	require.NoError(t, err)
+	assert.Equal(t, &Preferences{EmailOnReply: true, EmailOnMention: true, DefaultCommentSort: SortOrderDesc, Locale: "ru", DigestFrequency: DigestFrequencyDaily, PushOnReply: true, PushOnMention: true}, prefs)
+
+	off := false
+	best := SortOrderBest
+	_, err = mutation.UpdatePreferences(user, PreferencesInput{EmailOnReply: &off, DefaultCommentSort: &best})
+	require.NoError(t, err)
+	locale = "en"
+	prefs, err = mutation.UpdatePreferences(user, PreferencesInput{Locale: &locale})
+	require.NoError(t, err)
+	assert.Equal(t, &Preferences{EmailOnReply: false, EmailOnMention: true, DefaultCommentSort: SortOrderBest, Locale: "en", DigestFrequency: DigestFrequencyDaily, PushOnReply: true, PushOnMention: true}, prefs, "Незаданные поля не меняются")
+
+	address := "Читатель <reader@example.com>"
+	weekly := DigestFrequencyWeekly
+	prefs, err = mutation.UpdatePreferences(user, PreferencesInput{Email: &address, DigestFrequency: &weekly})
+	require.NoError(t, err)
+	require.NotNil(t, prefs.Email)
+	assert.Equal(t, "reader@example.com", *prefs.Email)
+	assert.Equal(t, DigestFrequencyWeekly, prefs.DigestFrequency)
+	address = "not an address"
+	_, err = mutation.UpdatePreferences(user, PreferencesInput{Email: &address})
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	address = ""
+	prefs, err = mutation.UpdatePreferences(user, PreferencesInput{Email: &address})
+	require.NoError(t, err)
+	assert.Nil(t, prefs.Email, "Пустая строка удаляет адрес")
+	prefs, err = mutation.UpdatePreferences(user, PreferencesInput{PushOnMention: &off})
+	require.NoError(t, err)
+	assert.True(t, prefs.PushOnReply)
+	assert.False(t, prefs.PushOnMention)
+}
+
+func TestComments_DefaultSortFromPreferences(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(user, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	older, err := mutation.CreateComment(user, post.ID, nil, "Старый", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(user, post.ID, nil, "Новый", nil, nil, nil)
+	require.NoError(t, err)
+
+	asc := SortOrderAsc
+	_, err = mutation.UpdatePreferences(user, PreferencesInput{DefaultCommentSort: &asc})
+	require.NoError(t, err)
+	ctx := context.WithValue(user, "commentLoader", NewCommentLoader(store))
+	page, err := resolver.Post().Comments(ctx, post, 10, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Comments, 2)
+	assert.Equal(t, older.ID, page.Comments[0].ID, "Без order используется порядок из настроек")
+
+	desc := SortOrderDesc
+	page, err = resolver.Post().Comments(ctx, post, 10, nil, &desc, nil, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, older.ID, page.Comments[0].ID, "Явный order важнее настроек")
+}
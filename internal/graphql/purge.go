@@ -0,0 +1,61 @@
+package graphql
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/purge"
+)
+
+// PurgeUserContent реализует мутацию purgeUserContent
+func (r *mutationResolver) PurgeUserContent(ctx context.Context, userID string) (*PurgeJob, error) {
+	log.Printf("Запуск мутации purgeUserContent: userID=%s", userID)
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if r.Purge == nil {
+		return nil, gqlerrors.New(gqlerrors.CodeInternal, "content purge is not configured")
+	}
+	if userID == "" {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "userId is empty")
+	}
+	job := r.Purge.Start(userID)
+	return toPurgeJob(job), nil
+}
+
+// PurgeJob реализует запрос purgeJob
+func (r *queryResolver) PurgeJob(ctx context.Context, id string) (*PurgeJob, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if r.Purge == nil {
+		return nil, nil
+	}
+	job, ok := r.Purge.Get(id)
+	if !ok {
+		return nil, nil
+	}
+	return toPurgeJob(job), nil
+}
+
+// toPurgeJob конвертирует задачу обезличивания в тип GraphQL
+func toPurgeJob(job purge.Job) *PurgeJob {
+	result := &PurgeJob{
+		ID:                job.ID,
+		UserID:            job.UserID,
+		Status:            PurgeJobStatus(job.Status),
+		PostsProcessed:    job.Posts,
+		CommentsProcessed: job.Comments,
+		StartedAt:         job.StartedAt.Format(time.RFC3339),
+	}
+	if job.FinishedAt != nil {
+		finished := job.FinishedAt.Format(time.RFC3339)
+		result.FinishedAt = &finished
+	}
+	if job.Error != "" {
+		result.Error = &job.Error
+	}
+	return result
+}
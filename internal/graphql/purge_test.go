@@ -0,0 +1,52 @@
+package graphql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/purge"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeUserContent(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	resolver.Purge = purge.New(resolver.Storage, purge.Options{BatchSize: 1, BatchInterval: time.Millisecond})
+	defer resolver.Purge.Close()
+	author := userContext("user1", "")
+	admin := userContext("admin1", roleAdmin)
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Личный пост", "Мой адрес", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(author, post.ID, nil, "Комментарий", nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = mutation.PurgeUserContent(userContext("mod1", roleModerator), "user1")
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	_, err = resolver.Query().PurgeJob(author, "any")
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+
+	job, err := mutation.PurgeUserContent(admin, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, "user1", job.UserID)
+	require.Eventually(t, func() bool {
+		job, err = resolver.Query().PurgeJob(admin, job.ID)
+		return err == nil && job.Status == PurgeJobStatusCompleted
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, job.PostsProcessed)
+	assert.Equal(t, 1, job.CommentsProcessed)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Nil(t, job.Error)
+
+	got, err := resolver.Query().Post(author, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletedUserID, got.AuthorID)
+	assert.Empty(t, got.Content)
+
+	missing, err := resolver.Query().PurgeJob(admin, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// checkQuote отклоняет цитату комментария quotedID, если он не виден автору userID или относится к другому посту
+func (r *mutationResolver) checkQuote(ctx context.Context, postID, quotedID, userID string) error {
+	quoted, err := storage.ViewComment(ctx, r.Storage, quotedID, userID)
+	if errors.Is(err, storage.ErrCommentNotFound) {
+		log.Printf("Ошибка: цитируемый комментарий %s не найден", quotedID)
+		return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "quoted comment %s not found", quotedID)
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении цитируемого комментария %s: %v", quotedID, err)
+		return commentError("failed to create comment", err)
+	}
+	if quoted.PostID != postID {
+		log.Printf("Ошибка: цитируемый комментарий %s относится к посту %s, а не %s", quotedID, quoted.PostID, postID)
+		return gqlerrors.New(gqlerrors.CodeBadUserInput, "quoted comment belongs to another post")
+	}
+	return nil
+}
+
+// QuotedComment реализует поле quotedComment в Comment
+func (r *commentResolver) QuotedComment(ctx context.Context, obj *Comment) (*Comment, error) {
+	if obj.QuotedCommentID == nil {
+		return nil, nil
+	}
+	id := *obj.QuotedCommentID
+	var quoted *models.Comment
+	var err error
+	if loader, ok := ctx.Value("commentByIDLoader").(*CommentByIDLoader); ok {
+		quoted, err = loader.Load(ctx, id)()
+	} else {
+		log.Println("CommentByIDLoader не найден в контексте, загрузка напрямую из хранилища")
+		quoted, err = r.Storage.GetComment(ctx, id)
+		if errors.Is(err, storage.ErrCommentNotFound) {
+			quoted, err = nil, nil
+		}
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении цитируемого комментария %s: %v", id, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get quoted comment: %v", err)
+	}
+	viewerID, _ := ctx.Value("userID").(string)
+	if quoted == nil || !storage.CommentVisibleTo(quoted, viewerID) {
+		return nil, nil
+	}
+	return toComment(quoted), nil
+}
@@ -0,0 +1,66 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentQuotes(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user1", "")
+	banned := userContext("banned", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	other, err := mutation.CreatePost(author, "Другой пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	quoted, err := mutation.CreateComment(author, post.ID, nil, "Исходная мысль", nil, nil, nil)
+	require.NoError(t, err)
+
+	// Цитата не делает комментарий ответом
+	quoting, err := mutation.CreateComment(banned, post.ID, nil, "Не согласен", nil, nil, &quoted.ID)
+	require.NoError(t, err)
+	assert.Nil(t, quoting.ParentID)
+	assert.Equal(t, &quoted.ID, quoting.QuotedCommentID)
+	got, err := resolver.Comment().QuotedComment(author, quoting)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "Исходная мысль", got.Content)
+	none, err := resolver.Comment().QuotedComment(author, quoted)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	_, err = mutation.CreateComment(author, other.ID, nil, "Чужая цитата", nil, nil, &quoted.ID)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	missing := "missing"
+	_, err = mutation.CreateComment(author, post.ID, nil, "Цитата несуществующего", nil, nil, &missing)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	// Скрытый комментарий может процитировать только его автор, остальные не видят цитату
+	require.NoError(t, store.SetShadowBan(context.Background(), "banned", true))
+	hidden, err := mutation.CreateComment(banned, post.ID, nil, "Скрытый", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreateComment(author, post.ID, nil, "Цитата скрытого", nil, nil, &hidden.ID)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	selfQuote, err := mutation.CreateComment(banned, post.ID, nil, "Цитирую себя", nil, nil, &hidden.ID)
+	require.NoError(t, err)
+	got, err = resolver.Comment().QuotedComment(banned, selfQuote)
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+	got, err = resolver.Comment().QuotedComment(author, selfQuote)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// Через DataLoader цитаты загружаются одним запросом
+	ctx := context.WithValue(author, "commentByIDLoader", NewCommentByIDLoader(store))
+	got, err = resolver.Comment().QuotedComment(ctx, quoting)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, quoted.ID, got.ID)
+}
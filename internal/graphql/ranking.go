@@ -0,0 +1,100 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/ranking"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// rankingNames - стратегии пакета ranking по значениям CommentRanking
+var rankingNames = map[CommentRanking]string{
+	CommentRankingChronological: ranking.Chronological,
+	CommentRankingBest:          ranking.Best,
+	CommentRankingControversial: ranking.Controversial,
+	CommentRankingMlScore:       ranking.MLScore,
+}
+
+// toCommentRanking возвращает значение CommentRanking для стратегии name или nil для неизвестной стратегии
+func toCommentRanking(name string) *CommentRanking {
+	for value, strategy := range rankingNames {
+		if strategy == name {
+			return &value
+		}
+	}
+	return nil
+}
+
+// commentRanking назначает стратегию ранжирования корневых комментариев. Запрос с order использует
+// обычный порядок, а вместе с ranking отклоняется; без обоих аргументов стратегию могут назначить
+// эксперимент или настройка сообщества
+func (r *Resolver) commentRanking(ctx context.Context, order *SortOrder, strategy *CommentRanking) (ranking.Assignment, bool, error) {
+	if strategy != nil && order != nil {
+		return ranking.Assignment{}, false, gqlerrors.New(gqlerrors.CodeBadUserInput, "order and ranking cannot be used together")
+	}
+	if order != nil || r.Ranking == nil {
+		return ranking.Assignment{}, false, nil
+	}
+	requested := ""
+	if strategy != nil {
+		requested = rankingNames[*strategy]
+	}
+	assignment, ok := r.Ranking.Assign(ctx, requested)
+	return assignment, ok, nil
+}
+
+// rankedComments возвращает страницу корневых комментариев поста, упорядоченных в памяти стратегией name.
+// Упорядочиваются только MaxCandidates последних комментариев, поэтому страницы за их пределами пусты
+func (r *postResolver) rankedComments(ctx context.Context, obj *Post, limit int, cursor *string, page *int, name string) (*PaginatedComments, error) {
+	empty := &PaginatedComments{Comments: []*Comment{}, Ranking: toCommentRanking(name)}
+	offset, err := rankedOffset(limit, cursor, page, name)
+	if err != nil {
+		gqlerrors.AddFieldError(ctx, commentsErrorCode(err), err)
+		return empty, nil
+	}
+	candidates, err := r.Storage.GetComments(viewerContext(ctx), obj.ID, nil, r.Ranking.MaxCandidates(), nil, models.SortDesc)
+	if err != nil {
+		log.Printf("Ошибка при загрузке комментариев для ранжирования postID=%s: %v", obj.ID, err)
+		gqlerrors.AddFieldError(ctx, commentsErrorCode(err), fmt.Errorf("failed to load comments: %v", err))
+		return empty, nil
+	}
+	ranked := r.Ranking.Rank(ctx, name, candidates.Comments)
+	end := min(offset+max(limit, 0), len(ranked))
+	result := &PaginatedComments{
+		TotalCount: candidates.TotalCount,
+		PageCount:  pageCount(candidates.TotalCount, limit),
+		Ranking:    empty.Ranking,
+	}
+	if end < len(ranked) {
+		next := ranking.EncodeCursor(name, end)
+		result.NextCursor = &next
+	}
+	result.Comments = convertComments(ctx, ranked[min(offset, end):end])
+	log.Printf("Получено ранжированных комментариев для postID=%s: %d из %d, стратегия %s", obj.ID, len(result.Comments), len(ranked), name)
+	return result, nil
+}
+
+// rankedOffset возвращает число пропускаемых комментариев по курсору ранжированной ленты или номеру страницы
+func rankedOffset(limit int, cursor *string, page *int, name string) (int, error) {
+	if page != nil {
+		if _, err := pageCursor(page, cursor, limit, models.SortDesc); err != nil {
+			return 0, err
+		}
+		return (*page - 1) * limit, nil
+	}
+	if cursor == nil {
+		return 0, nil
+	}
+	offset, err := ranking.DecodeCursor(*cursor, name)
+	if err != nil {
+		return 0, err
+	}
+	if offset > storage.MaxOffset {
+		return 0, storage.ErrOffsetTooLarge
+	}
+	return offset, nil
+}
@@ -0,0 +1,94 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/ranking"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentRanking(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("author", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	calm, err := mutation.CreateComment(author, post.ID, nil, "Спокойный", nil, nil, nil)
+	require.NoError(t, err)
+	disputed, err := mutation.CreateComment(author, post.ID, nil, "Спорный", nil, nil, nil)
+	require.NoError(t, err)
+	split, err := mutation.CreateComment(author, post.ID, nil, "Немного спорный", nil, nil, nil)
+	require.NoError(t, err)
+	vote := func(commentID string, value VoteValue, users ...string) {
+		for _, user := range users {
+			_, err := mutation.VoteComment(userContext(user, ""), commentID, value)
+			require.NoError(t, err)
+		}
+	}
+	vote(calm.ID, VoteValueUp, "u1", "u2", "u3")
+	vote(disputed.ID, VoteValueUp, "u1", "u2")
+	vote(disputed.ID, VoteValueDown, "u3", "u4")
+	vote(split.ID, VoteValueUp, "u1", "u2")
+	vote(split.ID, VoteValueDown, "u3")
+
+	flagService := flags.New(flags.Options{Static: map[string]flags.Flag{flags.RankingControversial: {Users: []string{"tester"}}}})
+	request := func(userID string) context.Context {
+		ctx := context.WithValue(userContext(userID, ""), "commentLoader", NewCommentLoader(store))
+		return flags.NewContext(ctx, flagService.For(userID))
+	}
+	commentIDs := func(page *PaginatedComments) []string {
+		result := make([]string, len(page.Comments))
+		for i, c := range page.Comments {
+			result[i] = c.ID
+		}
+		return result
+	}
+	controversial := CommentRankingControversial
+
+	page, err := resolver.Post().Comments(request("reader"), post, 2, nil, nil, nil, &controversial)
+	require.NoError(t, err)
+	assert.Equal(t, []string{disputed.ID, split.ID}, commentIDs(page))
+	assert.Equal(t, 3, page.TotalCount)
+	assert.Equal(t, &controversial, page.Ranking)
+	require.NotNil(t, page.NextCursor)
+	page, err = resolver.Post().Comments(request("reader"), post, 2, page.NextCursor, nil, nil, &controversial)
+	require.NoError(t, err)
+	assert.Equal(t, []string{calm.ID}, commentIDs(page))
+	assert.Nil(t, page.NextCursor)
+
+	// Эксперимент назначается только без order и ranking
+	page, err = resolver.Post().Comments(request("tester"), post, 10, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{disputed.ID, split.ID, calm.ID}, commentIDs(page))
+	assert.Equal(t, &controversial, page.Ranking)
+	asc := SortOrderAsc
+	page, err = resolver.Post().Comments(request("tester"), post, 10, nil, &asc, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{calm.ID, disputed.ID, split.ID}, commentIDs(page))
+	assert.Nil(t, page.Ranking)
+
+	// Стратегии хранилища идут обычной загрузкой комментариев
+	best := CommentRankingBest
+	page, err = resolver.Post().Comments(request("reader"), post, 10, nil, nil, nil, &best)
+	require.NoError(t, err)
+	assert.Equal(t, []string{calm.ID, split.ID, disputed.ID}, commentIDs(page))
+	assert.Equal(t, &best, page.Ranking)
+
+	resolver.Ranking = ranking.New(ranking.Options{Tenants: map[string]string{"forum": ranking.Controversial}})
+	page, err = resolver.Post().Comments(context.WithValue(request("reader"), "tenant", "forum"), post, 10, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &controversial, page.Ranking, "Стратегия сообщества")
+
+	page, err = resolver.Post().Comments(request("reader"), post, 10, nil, &asc, nil, &controversial)
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments, "order и ranking вместе не используются")
+	mlScore := CommentRankingMlScore
+	page, err = resolver.Post().Comments(request("reader"), post, 10, nil, nil, nil, &mlScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{calm.ID, split.ID, disputed.ID}, commentIDs(page), "Без модели ml-score заменяется на best")
+}
@@ -0,0 +1,130 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// allowedReactions - допустимый набор эмодзи для реакций
+var allowedReactions = map[string]bool{
+	"👍":  true,
+	"👎":  true,
+	"❤️": true,
+	"😂":  true,
+	"😮":  true,
+	"😢":  true,
+	"🎉":  true,
+}
+
+// ReactionCounts реализует поле reactionCounts в Post
+func (r *postResolver) ReactionCounts(ctx context.Context, obj *Post) ([]*ReactionCount, error) {
+	return r.loadReactionCounts(ctx, obj.ID), nil
+}
+
+// ReactionCounts реализует поле reactionCounts в Comment
+func (r *commentResolver) ReactionCounts(ctx context.Context, obj *Comment) ([]*ReactionCount, error) {
+	return r.loadReactionCounts(ctx, obj.ID), nil
+}
+
+// ReactToPost реализует мутацию reactToPost
+func (r *mutationResolver) ReactToPost(ctx context.Context, postID string, emoji string) ([]*ReactionCount, error) {
+	log.Printf("Запуск мутации reactToPost: postID=%s, emoji=%s", postID, emoji)
+	if err := checkReaction(emoji); err != nil {
+		return nil, err
+	}
+	// Реагировать можно только на пост, который пользователь видит
+	if _, err := storage.ViewPost(ctx, r.Storage, postID, reactingUser(ctx)); err != nil {
+		log.Printf("Ошибка при получении поста с ID=%s: %v", postID, err)
+		return nil, categoryError("failed to get post", err)
+	}
+	return r.toggleReaction(ctx, postID, emoji)
+}
+
+// ReactToComment реализует мутацию reactToComment
+func (r *mutationResolver) ReactToComment(ctx context.Context, commentID string, emoji string) ([]*ReactionCount, error) {
+	log.Printf("Запуск мутации reactToComment: commentID=%s, emoji=%s", commentID, emoji)
+	if err := checkReaction(emoji); err != nil {
+		return nil, err
+	}
+	if _, err := storage.ViewComment(ctx, r.Storage, commentID, reactingUser(ctx)); err != nil {
+		log.Printf("Ошибка при получении комментария с ID=%s: %v", commentID, err)
+		return nil, commentError("failed to get comment", err)
+	}
+	return r.toggleReaction(ctx, commentID, emoji)
+}
+
+// checkReaction возвращает BAD_USER_INPUT для эмодзи не из allowedReactions
+func checkReaction(emoji string) error {
+	if !allowedReactions[emoji] {
+		log.Printf("Ошибка: недопустимая реакция %s", emoji)
+		return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "reaction %s is not allowed", emoji)
+	}
+	return nil
+}
+
+// toggleReaction ставит или снимает реакцию текущего пользователя и возвращает актуальные счётчики
+func (r *mutationResolver) toggleReaction(ctx context.Context, targetID string, emoji string) ([]*ReactionCount, error) {
+	userID := reactingUser(ctx)
+	added, err := r.Storage.ToggleReaction(ctx, &models.Reaction{
+		TargetID:  targetID,
+		UserID:    userID,
+		Emoji:     emoji,
+		CreatedAt: r.Clock.Now(),
+	})
+	if err != nil {
+		log.Printf("Ошибка при переключении реакции: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to toggle reaction: %v", err)
+	}
+	log.Printf("Реакция %s для %s: added=%t", emoji, targetID, added)
+
+	counts, err := r.Storage.GetReactionCounts(ctx, []string{targetID})
+	if err != nil {
+		log.Printf("Ошибка при получении реакций для %s: %v", targetID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to load reactions: %v", err)
+	}
+	return toReactionCounts(counts[targetID]), nil
+}
+
+// reactingUser возвращает пользователя из контекста или user1, если его нет
+func reactingUser(ctx context.Context) string {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		log.Println("userID не найден в контексте, используется user1")
+		userID = "user1"
+	}
+	return userID
+}
+
+// loadReactionCounts загружает счётчики через DataLoader из контекста;
+// ошибка загрузки не ломает остальной ответ
+func (r *Resolver) loadReactionCounts(ctx context.Context, targetID string) []*ReactionCount {
+	var counts []models.ReactionCount
+	var err error
+	if loader, ok := ctx.Value("reactionLoader").(*ReactionLoader); ok {
+		counts, err = loader.Load(ctx, targetID)()
+	} else {
+		log.Println("ReactionLoader не найден в контексте, загрузка напрямую из хранилища")
+		var byTarget map[string][]models.ReactionCount
+		byTarget, err = r.Storage.GetReactionCounts(ctx, []string{targetID})
+		counts = byTarget[targetID]
+	}
+	if err != nil {
+		log.Printf("Ошибка при загрузке реакций для %s: %v", targetID, err)
+		gqlerrors.AddFieldError(ctx, gqlerrors.CodeInternal, fmt.Errorf("failed to load reactions: %v", err))
+		return []*ReactionCount{}
+	}
+	return toReactionCounts(counts)
+}
+
+func toReactionCounts(counts []models.ReactionCount) []*ReactionCount {
+	result := make([]*ReactionCount, len(counts))
+	for i, c := range counts {
+		result[i] = &ReactionCount{Emoji: c.Emoji, Count: c.Count}
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReactToComment(t *testing.T) {
+	storage := &mockStorage{}
+	storage.On("GetComment", mock.Anything, "comment1").Return(&models.Comment{ID: "comment1", PostID: "post1"}, nil)
+	storage.On("GetPost", mock.Anything, "post1").Return(&models.Post{ID: "post1", AuthorID: "user1", Visibility: models.VisibilityPublic}, nil)
+	storage.On("ToggleReaction", mock.Anything, mock.MatchedBy(func(r *models.Reaction) bool {
+		return r.TargetID == "comment1" && r.UserID == "user2" && r.Emoji == "👍"
+	})).Return(true, nil)
+	storage.On("GetReactionCounts", mock.Anything, []string{"comment1"}).Return(map[string][]models.ReactionCount{
+		"comment1": {{Emoji: "👍", Count: 3}, {Emoji: "🎉", Count: 1}},
+	}, nil)
+
+	resolver := NewResolver(storage, nil)
+	ctx := context.WithValue(context.Background(), "userID", "user2")
+
+	result, err := resolver.Mutation().ReactToComment(ctx, "comment1", "👍")
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "👍", result[0].Emoji)
+	assert.Equal(t, 3, result[0].Count)
+	storage.AssertExpectations(t)
+}
+
+func TestReactToComment_NotAllowedEmoji(t *testing.T) {
+	storage := &mockStorage{}
+
+	resolver := NewResolver(storage, nil)
+	result, err := resolver.Mutation().ReactToComment(context.Background(), "comment1", "💩")
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "reaction 💩 is not allowed", err.Error())
+	storage.AssertNotCalled(t, "ToggleReaction", mock.Anything, mock.Anything)
+}
+
+func TestReactions_HiddenPost(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user1", "")
+	stranger := userContext("user2", "")
+	private := PostVisibilityPrivate
+	post, err := resolver.Mutation().CreatePost(author, "Заметка", "Содержимое", true, nil, nil, nil, &private)
+	require.NoError(t, err)
+	comment, err := resolver.Mutation().CreateComment(author, post.ID, nil, "Комментарий", nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().ReactToPost(stranger, post.ID, "👍")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = resolver.Mutation().ReactToComment(stranger, comment.ID, "👍")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = resolver.Mutation().VoteComment(stranger, comment.ID, VoteValueUp)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), "Комментарий личного поста не виден другим")
+
+	_, err = resolver.Mutation().ReactToComment(author, comment.ID, "👍")
+	assert.NoError(t, err)
+}
+
+func TestReactionCounts_Batched(t *testing.T) {
+	storage := &mockStorage{}
+	storage.On("GetReactionCounts", mock.Anything, mock.MatchedBy(func(ids []string) bool {
+		return len(ids) == 2
+	})).Return(map[string][]models.ReactionCount{
+		"post1":    {{Emoji: "❤️", Count: 2}},
+		"comment1": {},
+	}, nil).Once()
+
+	resolver := NewResolver(storage, nil)
+	ctx := context.WithValue(context.Background(), "reactionLoader", NewReactionLoader(storage))
+
+	done := make(chan []*ReactionCount, 1)
+	go func() {
+		counts, _ := resolver.Comment().ReactionCounts(ctx, &Comment{ID: "comment1"})
+		done <- counts
+	}()
+	postCounts, err := resolver.Post().ReactionCounts(ctx, &Post{ID: "post1"})
+	assert.NoError(t, err)
+	assert.Len(t, postCounts, 1)
+	assert.Equal(t, 2, postCounts[0].Count)
+	assert.Empty(t, <-done)
+	storage.AssertExpectations(t)
+}
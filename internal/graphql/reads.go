@@ -0,0 +1,54 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// MarkThreadRead реализует мутацию markThreadRead
+func (r *mutationResolver) MarkThreadRead(ctx context.Context, postID string) (*Post, error) {
+	log.Printf("Запуск мутации markThreadRead: postID=%s", postID)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: отметка о прочтении без авторизации")
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	post, err := storage.ViewPost(ctx, r.Storage, postID, userID)
+	if err == nil {
+		err = r.Storage.MarkThreadRead(ctx, userID, postID, r.Clock.Now())
+	}
+	if err != nil {
+		log.Printf("Ошибка при отметке о прочтении поста %s: %v", postID, err)
+		return nil, categoryError("failed to mark thread read", err)
+	}
+	log.Printf("Комментарии поста %s прочитаны пользователем %s", postID, userID)
+	return toPost(post), nil
+}
+
+// UnreadCommentCount реализует поле unreadCommentCount в Post; ошибка загрузки не ломает остальной ответ
+func (r *postResolver) UnreadCommentCount(ctx context.Context, obj *Post) (int, error) {
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return 0, nil
+	}
+	var count int
+	var err error
+	if loader, ok := ctx.Value("unreadLoader").(*UnreadLoader); ok {
+		count, err = loader.Load(ctx, UnreadKey{PostID: obj.ID, Viewer: userID})()
+	} else {
+		log.Println("UnreadLoader не найден в контексте, загрузка напрямую из хранилища")
+		var byPost map[string]int
+		byPost, err = r.Storage.GetUnreadCommentCounts(ctx, userID, []string{obj.ID})
+		count = byPost[obj.ID]
+	}
+	if err != nil {
+		log.Printf("Ошибка при загрузке непрочитанных комментариев поста %s: %v", obj.ID, err)
+		gqlerrors.AddFieldError(ctx, gqlerrors.CodeInternal, fmt.Errorf("failed to load unread comments: %v", err))
+		return 0, nil
+	}
+	return count, nil
+}
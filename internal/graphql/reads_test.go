@@ -0,0 +1,72 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkThreadRead(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	author := userContext("user2", "")
+	reader := userContext("user1", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	for _, content := range []string{"Первый", "Второй"} {
+		_, err = mutation.CreateComment(author, post.ID, nil, content, nil, nil, nil)
+		require.NoError(t, err)
+	}
+	_, err = mutation.CreateComment(reader, post.ID, nil, "Свой", nil, nil, nil)
+	require.NoError(t, err)
+
+	count, err := resolver.Post().UnreadCommentCount(reader, post)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "Свои комментарии не считаются непрочитанными")
+	count, err = resolver.Post().UnreadCommentCount(context.Background(), post)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	_, err = mutation.MarkThreadRead(context.Background(), post.ID)
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = mutation.MarkThreadRead(reader, "missing")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	_, err = mutation.MarkThreadRead(reader, post.ID)
+	require.NoError(t, err)
+	count, err = resolver.Post().UnreadCommentCount(reader, post)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	_, err = mutation.CreateComment(author, post.ID, nil, "Третий", nil, nil, nil)
+	require.NoError(t, err)
+	count, err = resolver.Post().UnreadCommentCount(reader, post)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestUnreadCommentCount_Batched(t *testing.T) {
+	storage := &mockStorage{}
+	storage.On("GetUnreadCommentCounts", mock.Anything, "user1", mock.MatchedBy(func(ids []string) bool {
+		return len(ids) == 2
+	})).Return(map[string]int{"post1": 3, "post2": 0}, nil).Once()
+
+	resolver := NewResolver(storage, nil)
+	ctx := context.WithValue(userContext("user1", ""), "unreadLoader", NewUnreadLoader(storage))
+
+	done := make(chan int, 1)
+	go func() {
+		count, _ := resolver.Post().UnreadCommentCount(ctx, &Post{ID: "post2"})
+		done <- count
+	}()
+	count, err := resolver.Post().UnreadCommentCount(ctx, &Post{ID: "post1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 0, <-done)
+	storage.AssertExpectations(t)
+}
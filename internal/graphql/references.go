@@ -0,0 +1,55 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/references"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// References реализует поле references в Post
+func (r *postResolver) References(ctx context.Context, obj *Post, limit int, cursor *string, snapshot *string) (*PaginatedPosts, error) {
+	log.Printf("Запрос постов, на которые ссылается postID=%s, limit=%d, cursor=%v", obj.ID, limit, cursor)
+	return r.referencePosts(ctx, limit, cursor, snapshot, storage.PostFilter{ReferencedBy: obj.ID})
+}
+
+// ReferencedBy реализует поле referencedBy в Post
+func (r *postResolver) ReferencedBy(ctx context.Context, obj *Post, limit int, cursor *string, snapshot *string) (*PaginatedPosts, error) {
+	log.Printf("Запрос постов, ссылающихся на postID=%s, limit=%d, cursor=%v", obj.ID, limit, cursor)
+	return r.referencePosts(ctx, limit, cursor, snapshot, storage.PostFilter{References: obj.ID})
+}
+
+// referencePosts возвращает страницу постов, связанных ссылками, с отбором filter
+func (r *postResolver) referencePosts(ctx context.Context, limit int, cursor *string, snapshot *string, filter storage.PostFilter) (*PaginatedPosts, error) {
+	asOf, token, err := r.postsSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	filter.AsOf = asOf
+	posts, err := r.Storage.ListPosts(viewerContext(ctx), limit, cursor, filter)
+	if err != nil {
+		log.Printf("Ошибка при получении связанных постов: %v", err)
+		return nil, categoryError("failed to list referenced posts", err)
+	}
+	result := &PaginatedPosts{
+		Posts:      convertPosts(ctx, posts.Posts),
+		TotalCount: posts.TotalCount,
+		PageCount:  pageCount(posts.TotalCount, limit),
+		NextCursor: posts.NextCursor,
+		Snapshot:   token,
+	}
+	return result, nil
+}
+
+// saveReferences сохраняет ссылки нового поста postID на другие посты. Пост создаётся и без них:
+// ошибка только пишется в лог
+func (r *mutationResolver) saveReferences(ctx context.Context, postID, content string) {
+	ids, err := references.Resolve(ctx, r.Storage, postID, content)
+	if err == nil && len(ids) > 0 {
+		err = r.Storage.SavePostReferences(ctx, postID, ids)
+	}
+	if err != nil {
+		log.Printf("Ошибка при сохранении ссылок поста %s на другие посты: %v", postID, err)
+	}
+}
@@ -0,0 +1,34 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostReferences(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+	cited, err := mutation.CreatePost(user, "Исходный пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	citing, err := mutation.CreatePost(user, "Ответный пост", "Продолжение https://example.com/posts/"+cited.Slug+" и post:missing", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	references, err := resolver.Post().References(user, citing, 10, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, references.Posts, 1)
+	assert.Equal(t, cited.ID, references.Posts[0].ID)
+	assert.NotEmpty(t, references.Snapshot)
+
+	backlinks, err := resolver.Post().ReferencedBy(user, cited, 10, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, backlinks.Posts, 1)
+	assert.Equal(t, citing.ID, backlinks.Posts[0].ID)
+
+	empty, err := resolver.Post().ReferencedBy(user, citing, 10, nil, nil)
+	require.NoError(t, err)
+	assert.Zero(t, empty.TotalCount)
+}
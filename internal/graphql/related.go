@@ -0,0 +1,43 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// RelatedPosts реализует поле relatedPosts в Post
+func (r *postResolver) RelatedPosts(ctx context.Context, obj *Post, limit *int) ([]*Post, error) {
+	n := 5
+	if limit != nil {
+		n = *limit
+	}
+	log.Printf("Запрос похожих постов для postID=%s, limit=%d", obj.ID, n)
+	if n < 1 {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "limit must be positive")
+	}
+	var posts []*models.Post
+	var err error
+	if r.Related != nil {
+		posts, err = r.Related.Get(ctx, obj.ID, n)
+	} else {
+		posts, err = r.Storage.RelatedPosts(ctx, obj.ID, n)
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении похожих постов для postID=%s: %v", obj.ID, err)
+		// Пост отображается и без рекомендаций: ошибка уходит в errors, поле получает пустой список
+		gqlerrors.AddFieldError(ctx, gqlerrors.CodeInternal, fmt.Errorf("failed to load related posts: %v", err))
+		return []*Post{}, nil
+	}
+	return convertPosts(ctx, posts), nil
+}
+
+// invalidateRelated сбрасывает кешированные похожие посты после изменения поста
+func (r *mutationResolver) invalidateRelated(postID string) {
+	if r.Related != nil {
+		r.Related.Invalidate(postID)
+	}
+}
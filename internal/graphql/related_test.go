@@ -0,0 +1,49 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/related"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelatedPosts(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	resolver.Related = related.New(store, related.Options{})
+	mod := userContext("mod1", "moderator")
+	author := userContext("user2", "")
+	mutation := resolver.Mutation()
+	category, err := mutation.CreateCategory(mod, "Космос", nil)
+	require.NoError(t, err)
+
+	post, err := mutation.CreatePost(author, "Запуск ракеты", "Содержимое", true, nil, &category.ID, nil, nil)
+	require.NoError(t, err)
+	similar, err := mutation.CreatePost(author, "Запуск ракеты перенесён", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	other, err := mutation.CreatePost(author, "Пирог", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	posts, err := resolver.Post().RelatedPosts(author, post, nil)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, similar.ID, posts[0].ID)
+
+	// Смена категории сбрасывает кешированный список
+	_, err = mutation.SetPostCategory(author, other.ID, &category.ID)
+	require.NoError(t, err)
+	_, err = mutation.SetPostCategory(author, post.ID, &category.ID)
+	require.NoError(t, err)
+	posts, err = resolver.Post().RelatedPosts(author, post, nil)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, similar.ID, posts[0].ID, "Похожий заголовок весит больше общей категории")
+	assert.Equal(t, other.ID, posts[1].ID)
+
+	limit := 0
+	_, err = resolver.Post().RelatedPosts(author, post, &limit)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
@@ -5,30 +5,71 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"sync"
-	"time"
 
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/duplicates"
+	"github.com/ButyrinIA/system/internal/excerpt"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/ids"
+	"github.com/ButyrinIA/system/internal/linkpreview"
+	"github.com/ButyrinIA/system/internal/maintenance"
+	"github.com/ButyrinIA/system/internal/markdown"
 	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/moderation"
+	"github.com/ButyrinIA/system/internal/partial"
+	"github.com/ButyrinIA/system/internal/purge"
+	"github.com/ButyrinIA/system/internal/push"
+	"github.com/ButyrinIA/system/internal/quota"
+	"github.com/ButyrinIA/system/internal/ranking"
+	"github.com/ButyrinIA/system/internal/related"
+	"github.com/ButyrinIA/system/internal/slug"
+	"github.com/ButyrinIA/system/internal/spam"
 	"github.com/ButyrinIA/system/internal/storage"
-	"github.com/google/uuid"
-	"github.com/graph-gophers/dataloader/v7"
+	"github.com/ButyrinIA/system/internal/suggest"
+	"github.com/ButyrinIA/system/internal/textsize"
+	"github.com/ButyrinIA/system/internal/toxicity"
+	"github.com/ButyrinIA/system/internal/translate"
 )
 
-// PostResolver определяет резолверы для полей типа Post
-type PostResolver interface {
-	Comments(ctx context.Context, obj *Post, limit int, cursor *string) (*PaginatedComments, error)
-}
-
-// CommentResolver определяет резолверы для полей типа Comment
-type CommentResolver interface {
-	Replies(ctx context.Context, obj *Comment, limit int, cursor *string) (*PaginatedComments, error)
-}
-
 // Resolver - основная структура, реализующая ResolverRoot
 type Resolver struct {
 	Storage             storage.Storage
 	SubscriptionHandler *subscriptionHandler
-	CommentLoader       *dataloader.Loader[string, *models.PaginatedComments]
+	CommentLoader       *CommentLoader
+	Renderer            *markdown.Renderer
+	LinkPreviews        *linkpreview.Service
+	Quotas              *quota.Service
+	Moderation          *moderation.Service
+	Spam                *spam.Service
+	Related             *related.Service
+	Push                *push.Service
+	// Suggestions - подсказки для строки поиска в запросе suggest
+	Suggestions *suggest.Service
+	// Translations - машинный перевод для contentTranslated; nil, если перевод не настроен
+	Translations *translate.Service
+	// Purge - фоновое обезличивание содержимого пользователей по мутации purgeUserContent
+	Purge *purge.Service
+	// TenantQuotas - учёт и лимиты хранилища сообществ; nil отключает учёт
+	TenantQuotas *quota.TenantService
+	// IDs создаёт идентификаторы новых постов, комментариев и остальных сущностей
+	IDs ids.Generator
+	// Clock - источник времени создания сущностей и ограничений по времени
+	Clock clock.Clock
+	// Maintenance - режим обслуживания, который переключает мутация setMaintenanceMode
+	Maintenance *maintenance.Mode
+	// Connections - открытые WebSocket-соединения для subscriptionsInfo и closeUserSubscriptions; nil, если
+	// сервер не принимает WebSocket
+	Connections ConnectionRegistry
+	// Ranking - стратегии ранжирования комментариев поста и эксперименты с ними
+	Ranking *ranking.Service
+	// Toxicity - фоновая оценка токсичности новых комментариев; nil, если оценка не настроена
+	Toxicity *toxicity.Service
+	// ContentLimits - лимиты длины заголовков и текстов постов и комментариев в сообществах
+	ContentLimits *textsize.Policy
+	// Excerpts строит анонсы новых постов и, если подключён внешний сервис, заменяет их краткими содержаниями
+	Excerpts *excerpt.Service
+	// Duplicates ищет посты с похожим заголовком для checkDuplicate и, если повторы запрещены, для createPost
+	Duplicates *duplicates.Service
 }
 
 // queryResolver реализует QueryResolver
@@ -51,19 +92,31 @@ type commentResolver struct {
 	*Resolver
 }
 
-// subscriptionHandler реализует SubscriptionResolver
-type subscriptionHandler struct {
-	commentChannels map[string][]chan *Comment
-	mu              sync.RWMutex
+// userResolver реализует UserResolver
+type userResolver struct {
+	*Resolver
+}
+
+// collectionResolver реализует CollectionResolver
+type collectionResolver struct {
+	*Resolver
 }
 
 // NewResolver создаёт новый Resolver
-func NewResolver(storage storage.Storage, commentLoader *dataloader.Loader[string, *models.PaginatedComments]) *Resolver {
+func NewResolver(storage storage.Storage, commentLoader *CommentLoader) *Resolver {
 	log.Println("Создание нового Resolver")
 	return &Resolver{
 		Storage:             storage,
-		SubscriptionHandler: newSubscriptionHandler(),
+		SubscriptionHandler: newSubscriptionHandler(storage),
 		CommentLoader:       commentLoader,
+		Renderer:            markdown.New(1000),
+		IDs:                 ids.Default(),
+		Clock:               clock.Real(),
+		Maintenance:         maintenance.New(false),
+		Ranking:             ranking.New(ranking.Options{}),
+		ContentLimits:       textsize.New(textsize.Options{Default: textsize.DefaultLimits}),
+		Excerpts:            excerpt.New(nil, storage, excerpt.Options{}),
+		Duplicates:          duplicates.New(storage, duplicates.Options{}),
 	}
 }
 
@@ -91,44 +144,59 @@ func (r *Resolver) Comment() CommentResolver {
 	return &commentResolver{r}
 }
 
+// User возвращает UserResolver
+func (r *Resolver) User() UserResolver {
+	log.Println("Инициализация UserResolver")
+	return &userResolver{r}
+}
+
+// Collection возвращает реализацию CollectionResolver
+func (r *Resolver) Collection() CollectionResolver {
+	log.Println("Инициализация CollectionResolver")
+	return &collectionResolver{r}
+}
+
 // Subscription возвращает SubscriptionResolver
 func (r *Resolver) Subscription() SubscriptionResolver {
 	log.Println("Инициализация SubscriptionResolver")
 	return r.SubscriptionHandler
 }
 
-// newSubscriptionHandler создаёт новый subscriptionHandler
-func newSubscriptionHandler() *subscriptionHandler {
-	log.Println("Создание нового subscriptionHandler")
-	return &subscriptionHandler{
-		commentChannels: make(map[string][]chan *Comment),
-	}
-}
-
 // Posts реализует запрос posts
-func (r *queryResolver) Posts(ctx context.Context, limit int, cursor *string) (*PaginatedPosts, error) {
-	log.Printf("Запрос posts с limit=%d, cursor=%v", limit, cursor)
-	posts, err := r.Storage.ListPosts(ctx, limit, cursor)
+func (r *queryResolver) Posts(ctx context.Context, limit int, cursor *string, categoryID *string, includeSubcategories *bool, page *int, filterInput *PostFilterInput, snapshot *string) (*PaginatedPosts, error) {
+	log.Printf("Запрос posts с limit=%d, cursor=%v, categoryID=%v, page=%v, filter=%+v, snapshot=%v", limit, cursor, categoryID, page, filterInput, snapshot)
+	cursor, err := pageCursor(page, cursor, limit, models.SortDesc)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := toPostFilter(filterInput)
+	if err != nil {
+		return nil, err
+	}
+	filter.IncludeSubcategories = includeSubcategories == nil || *includeSubcategories
+	if categoryID != nil {
+		filter.CategoryID = *categoryID
+	}
+	asOf, token, err := r.postsSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	filter.AsOf = asOf
+	posts, err := r.Storage.ListPosts(viewerContext(ctx), limit, cursor, filter)
 	if err != nil {
 		log.Printf("Ошибка при получении постов: %v", err)
-		return nil, fmt.Errorf("failed to list posts: %v", err)
+		return nil, categoryError("failed to list posts", err)
 	}
 	log.Printf("Получено постов: %d, TotalCount: %d, NextCursor: %v", len(posts.Posts), posts.TotalCount, posts.NextCursor)
 
 	result := &PaginatedPosts{
 		TotalCount: posts.TotalCount,
+		PageCount:  pageCount(posts.TotalCount, limit),
 		NextCursor: posts.NextCursor,
+		Snapshot:   token,
 	}
-	result.Posts = make([]*Post, len(posts.Posts))
+	result.Posts = convertPosts(ctx, posts.Posts)
 	for i, p := range posts.Posts {
-		result.Posts[i] = &Post{
-			ID:            p.ID,
-			Title:         p.Title,
-			Content:       p.Content,
-			AuthorID:      p.AuthorID,
-			AllowComments: p.AllowComments,
-			CreatedAt:     p.CreatedAt.Format(time.RFC3339),
-		}
 		log.Printf("Конвертирован пост %d: ID=%s, Title=%s", i, p.ID, p.Title)
 	}
 	return result, nil
@@ -137,227 +205,391 @@ func (r *queryResolver) Posts(ctx context.Context, limit int, cursor *string) (*
 // Post реализует запрос post
 func (r *queryResolver) Post(ctx context.Context, id string) (*Post, error) {
 	log.Printf("Запрос post с ID=%s", id)
-	post, err := r.Storage.GetPost(ctx, id)
+	// Задержанный до проверки пост и пост, который текущему пользователю не разрешено видеть,
+	// для него не существуют
+	viewerID, _ := ctx.Value("userID").(string)
+	post, err := storage.ViewPost(ctx, r.Storage, id, viewerID)
 	if err != nil {
 		log.Printf("Ошибка при получении поста с ID=%s: %v", id, err)
-		return nil, fmt.Errorf("failed to get post: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to get post: %v", err)
 	}
 	log.Printf("Получен пост: ID=%s, Title=%s", post.ID, post.Title)
-	return &Post{
-		ID:            post.ID,
-		Title:         post.Title,
-		Content:       post.Content,
-		AuthorID:      post.AuthorID,
-		AllowComments: post.AllowComments,
-		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return toPost(post), nil
 }
 
 // Comments реализует поле comments в Post с использованием DataLoader
-func (r *postResolver) Comments(ctx context.Context, obj *Post, limit int, cursor *string) (*PaginatedComments, error) {
-	log.Printf("Запрос комментариев для postID=%s, limit=%d, cursor=%v", obj.ID, limit, cursor)
-	commentLoader, ok := ctx.Value("commentLoader").(*dataloader.Loader[string, *models.PaginatedComments])
+func (r *postResolver) Comments(ctx context.Context, obj *Post, limit int, cursor *string, order *SortOrder, page *int, strategy *CommentRanking) (*PaginatedComments, error) {
+	log.Printf("Запрос комментариев для postID=%s, limit=%d, cursor=%v, order=%v, page=%v, ranking=%v", obj.ID, limit, cursor, order, page, strategy)
+	commentLoader, ok := ctx.Value("commentLoader").(*CommentLoader)
 	if !ok {
 		log.Println("Ошибка: CommentLoader не найден в контексте")
 		return nil, fmt.Errorf("commentLoader not found in context")
 	}
+	assignment, assigned, err := r.commentRanking(ctx, order, strategy)
+	if err != nil {
+		gqlerrors.AddFieldError(ctx, gqlerrors.Code(err), err)
+		return &PaginatedComments{Comments: []*Comment{}}, nil
+	}
+	if assigned {
+		storageOrder, ok := ranking.StorageOrder(assignment.Strategy)
+		if !ok {
+			return r.rankedComments(ctx, obj, limit, cursor, page, assignment.Strategy)
+		}
+		native := SortOrder(storageOrder)
+		order = &native
+	}
 
-	thunk := commentLoader.Load(ctx, obj.ID)
+	viewerID, _ := ctx.Value("userID").(string)
+	key := CommentsKey{PostID: obj.ID, Limit: limit, Order: r.commentOrder(ctx, order), Viewer: viewerID, Estimate: partial.Near(ctx)}
+	cursor, err = pageCursor(page, cursor, limit, key.Order)
+	if err != nil {
+		gqlerrors.AddFieldError(ctx, gqlerrors.Code(err), err)
+		return &PaginatedComments{Comments: []*Comment{}}, nil
+	}
+	if cursor != nil {
+		key.Cursor = *cursor
+	}
+	thunk := commentLoader.Load(ctx, key)
 	result, err := thunk()
 	if err != nil {
 		log.Printf("Ошибка при загрузке комментариев для postID=%s через DataLoader: %v", obj.ID, err)
-		return nil, fmt.Errorf("failed to load comments: %v", err)
+		// Пост отображается и без комментариев: ошибка уходит в errors, поле получает пустую страницу
+		gqlerrors.AddFieldError(ctx, commentsErrorCode(err), fmt.Errorf("failed to load comments: %v", err))
+		return &PaginatedComments{Comments: []*Comment{}}, nil
 	}
 
 	log.Printf("Получено комментариев для postID=%s: %d, TotalCount: %d, NextCursor: %v", obj.ID, len(result.Comments), result.TotalCount, result.NextCursor)
+	if result.Estimated {
+		partial.Estimated(ctx)
+	}
 	paginatedComments := &PaginatedComments{
 		TotalCount: result.TotalCount,
+		PageCount:  pageCount(result.TotalCount, limit),
 		NextCursor: result.NextCursor,
 	}
-	paginatedComments.Comments = make([]*Comment, len(result.Comments))
-	for i, c := range result.Comments {
-		paginatedComments.Comments[i] = &Comment{
-			ID:        c.ID,
-			PostID:    c.PostID,
-			ParentID:  c.ParentID,
-			AuthorID:  c.AuthorID,
-			Content:   c.Content,
-			CreatedAt: c.CreatedAt.Format(time.RFC3339),
-		}
-		log.Printf("Конвертирован комментарий %d: ID=%s, Content=%s", i, c.ID, c.Content)
+	if assigned {
+		paginatedComments.Ranking = toCommentRanking(assignment.Strategy)
+	}
+	paginatedComments.Comments = convertComments(ctx, result.Comments)
+	for i := range result.Comments {
+		log.Printf("Конвертирован комментарий %d: ID=%s, Content=%s", i, result.Comments[i].ID, result.Comments[i].Content)
 	}
 	return paginatedComments, nil
 }
 
 // Replies реализует поле replies в Comment
-func (r *commentResolver) Replies(ctx context.Context, obj *Comment, limit int, cursor *string) (*PaginatedComments, error) {
-	log.Printf("Запрос ответов для commentID=%s, postID=%s, limit=%d, cursor=%v", obj.ID, obj.PostID, limit, cursor)
-	comments, err := r.Storage.GetComments(ctx, obj.PostID, &obj.ID, limit, cursor)
+func (r *commentResolver) Replies(ctx context.Context, obj *Comment, limit int, cursor *string, order *SortOrder, page *int) (*PaginatedComments, error) {
+	log.Printf("Запрос ответов для commentID=%s, postID=%s, limit=%d, cursor=%v, order=%v, page=%v", obj.ID, obj.PostID, limit, cursor, order, page)
+	sortOrder := r.commentOrder(ctx, order)
+	cursor, err := pageCursor(page, cursor, limit, sortOrder)
+	if err != nil {
+		gqlerrors.AddFieldError(ctx, gqlerrors.Code(err), err)
+		return &PaginatedComments{Comments: []*Comment{}}, nil
+	}
+	repliesCtx := viewerContext(ctx)
+	if partial.Near(ctx) {
+		repliesCtx = storage.WithEstimatedCounts(repliesCtx)
+	}
+	comments, err := r.Storage.GetComments(repliesCtx, obj.PostID, &obj.ID, limit, cursor, sortOrder)
 	if err != nil {
 		log.Printf("Ошибка при получении ответов для commentID=%s: %v", obj.ID, err)
-		return nil, fmt.Errorf("failed to load comment replies: %v", err)
+		gqlerrors.AddFieldError(ctx, commentsErrorCode(err), fmt.Errorf("failed to load comment replies: %v", err))
+		return &PaginatedComments{Comments: []*Comment{}}, nil
 	}
 	log.Printf("Получено ответов для commentID=%s: %d, TotalCount: %d, NextCursor: %v", obj.ID, len(comments.Comments), comments.TotalCount, comments.NextCursor)
+	if comments.Estimated {
+		partial.Estimated(ctx)
+	}
 
 	result := &PaginatedComments{
 		TotalCount: comments.TotalCount,
+		PageCount:  pageCount(comments.TotalCount, limit),
 		NextCursor: comments.NextCursor,
 	}
-	result.Comments = make([]*Comment, len(comments.Comments))
-	for i, c := range comments.Comments {
-		result.Comments[i] = &Comment{
-			ID:        c.ID,
-			PostID:    c.PostID,
-			ParentID:  c.ParentID,
-			AuthorID:  c.AuthorID,
-			Content:   c.Content,
-			CreatedAt: c.CreatedAt.Format(time.RFC3339),
+	result.Comments = convertComments(ctx, comments.Comments)
+	for i := range comments.Comments {
+		log.Printf("Конвертирован ответ %d: ID=%s, Content=%s", i, comments.Comments[i].ID, comments.Comments[i].Content)
+	}
+	return result, nil
+}
+
+// ContentHTML реализует поле contentHTML в Post
+func (r *postResolver) ContentHTML(ctx context.Context, obj *Post) (string, error) {
+	rendered, err := r.Renderer.Render(string(obj.Format), obj.Content)
+	if err != nil {
+		log.Printf("Ошибка рендеринга поста ID=%s: %v", obj.ID, err)
+		return "", gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to render content: %v", err)
+	}
+	return rendered, nil
+}
+
+// LinkPreviews реализует поле linkPreviews в Post
+func (r *postResolver) LinkPreviews(ctx context.Context, obj *Post) ([]*LinkPreview, error) {
+	previews, err := r.Storage.GetLinkPreviews(ctx, obj.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении превью для postID=%s: %v", obj.ID, err)
+		gqlerrors.AddFieldError(ctx, gqlerrors.CodeInternal, fmt.Errorf("failed to load link previews: %v", err))
+		return []*LinkPreview{}, nil
+	}
+	result := make([]*LinkPreview, len(previews))
+	for i, p := range previews {
+		result[i] = &LinkPreview{
+			URL:         p.URL,
+			Title:       p.Title,
+			Description: p.Description,
+			ImageURL:    p.ImageURL,
+			SiteName:    p.SiteName,
 		}
-		log.Printf("Конвертирован ответ %d: ID=%s, Content=%s", i, c.ID, c.Content)
 	}
 	return result, nil
 }
 
+// ContentHTML реализует поле contentHTML в Comment
+func (r *commentResolver) ContentHTML(ctx context.Context, obj *Comment) (string, error) {
+	rendered, err := r.Renderer.Render(string(obj.Format), obj.Content)
+	if err != nil {
+		log.Printf("Ошибка рендеринга комментария ID=%s: %v", obj.ID, err)
+		return "", gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to render content: %v", err)
+	}
+	return rendered, nil
+}
+
 // CreatePost реализует мутацию createPost
-func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, allowComments bool) (*Post, error) {
-	log.Printf("Запуск мутации createPost: title=%s, allowComments=%t", title, allowComments)
-	if len(title) > 200 {
-		log.Println("Ошибка: заголовок превышает 200 символов")
-		return nil, errors.New("title exceeds 200 characters")
+func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, allowComments bool, format *ContentFormat, categoryID *string, language *string, visibility *PostVisibility) (*Post, error) {
+	log.Printf("Запуск мутации createPost: title=%s, allowComments=%t, format=%v, categoryID=%v, language=%v, visibility=%v", title, allowComments, format, categoryID, language, visibility)
+	tenantID := tenantFromContext(ctx)
+	if err := r.ContentLimits.CheckTitle(tenantID, title); err != nil {
+		return nil, contentSizeError(err)
 	}
-	if len(content) > 2000 {
-		log.Println("Ошибка: содержимое поста превышает 2000 символов")
-		return nil, errors.New("content exceeds 2000 characters")
+	if err := r.ContentLimits.CheckPost(tenantID, content); err != nil {
+		return nil, contentSizeError(err)
+	}
+	lang, err := contentLanguage(language, title+"\n"+content)
+	if err != nil {
+		return nil, err
 	}
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
 		log.Println("userID не найден в контексте, используется user1")
 		userID = "user1"
 	}
-	post := &Post{
-		ID:            uuid.New().String(),
+	// Категория проверяется до расхода квоты, чтобы ошибка в запросе не стоила пользователю поста
+	if categoryID != nil {
+		if _, err := r.Storage.GetCategory(ctx, *categoryID); err != nil {
+			log.Printf("Ошибка при получении категории с ID=%s: %v", *categoryID, err)
+			return nil, categoryError("failed to create post", err)
+		}
+	}
+	if err := r.checkDuplicate(ctx, title, userID); err != nil {
+		return nil, err
+	}
+	size := int64(len(title) + len(content))
+	if err := r.checkTenantQuota(ctx, models.TargetPost, size); err != nil {
+		return nil, err
+	}
+	// Квота расходуется до проверки правилами и возвращается, если пост так и не сохранён
+	if err := r.consumeQuota(ctx, userID, quota.ActionPost); err != nil {
+		return nil, err
+	}
+	verdict, err := r.moderate(ctx, title, content)
+	if err != nil {
+		r.refundQuota(ctx, userID, quota.ActionPost)
+		return nil, err
+	}
+	internalPost := &models.Post{
+		ID:            r.IDs.New(),
 		Title:         title,
 		Content:       content,
+		Format:        formatOrDefault(format),
 		AuthorID:      userID,
 		AllowComments: allowComments,
-		CreatedAt:     time.Now().Format(time.RFC3339),
-	}
-	internalPost := &models.Post{
-		ID:            post.ID,
-		Title:         post.Title,
-		Content:       post.Content,
-		AuthorID:      post.AuthorID,
-		AllowComments: post.AllowComments,
-		CreatedAt:     time.Now(),
+		CreatedAt:     r.Clock.Now(),
+		Hidden:        verdict.Held(),
+		Tags:          verdict.Tags,
+		CategoryID:    categoryID,
+		Slug:          slug.Make(title),
+		Language:      lang,
+		Visibility:    visibilityOrDefault(visibility),
 	}
+	internalPost.Excerpt = r.Excerpts.Make(internalPost)
 	log.Printf("Создание поста: %+v", internalPost)
 	if err := r.Storage.CreatePost(ctx, internalPost); err != nil {
 		log.Printf("Ошибка при создании поста: %v", err)
-		return nil, fmt.Errorf("failed to create post: %v", err)
+		r.refundQuota(ctx, userID, quota.ActionPost)
+		return nil, categoryError("failed to create post", err)
 	}
+	// Хранилище дополняет slug суффиксом при совпадении, поэтому пост конвертируется после сохранения
+	post := toPost(internalPost)
 	log.Printf("Пост успешно создан: %s", post.ID)
+	r.recordTenantUsage(ctx, models.TargetPost, size)
+	if verdict.Held() {
+		if err := r.hold(ctx, models.TargetPost, post.ID, verdict); err != nil {
+			return nil, err
+		}
+	}
+	r.saveReferences(ctx, post.ID, content)
+	if r.LinkPreviews != nil {
+		r.LinkPreviews.Enqueue(internalPost)
+	}
+	r.Excerpts.Enqueue(internalPost)
 	return post, nil
 }
 
 // CreateComment реализует мутацию createComment
-func (r *mutationResolver) CreateComment(ctx context.Context, postID string, parentID *string, content string) (*Comment, error) {
-	log.Printf("Запуск мутации createComment: postID=%s, parentID=%v, content=%s", postID, parentID, content)
-	if len(content) > 2000 {
-		log.Println("Ошибка: содержимое комментария превышает 2000 символов")
-		return nil, errors.New("comment content exceeds 2000 characters")
+func (r *mutationResolver) CreateComment(ctx context.Context, postID string, parentID *string, content string, format *ContentFormat, language *string, quotedCommentID *string) (*Comment, error) {
+	log.Printf("Запуск мутации createComment: postID=%s, parentID=%v, quotedCommentID=%v, content=%s, language=%v", postID, parentID, quotedCommentID, content, language)
+	if err := r.ContentLimits.CheckComment(tenantFromContext(ctx), content); err != nil {
+		return nil, contentSizeError(err)
+	}
+	lang, err := contentLanguage(language, content)
+	if err != nil {
+		return nil, err
 	}
 	userID, ok := ctx.Value("userID").(string)
-	if !ok {
+	if identity, _ := ctx.Value("anonymousID").(string); !ok && identity != "" {
+		// Анонимный автор: ID из хэша идентификатора cookie, в ответах - псевдоним в пределах поста
+		userID = models.AnonymousAuthorID(identity)
+	} else if !ok {
 		log.Println("userID не найден в контексте, используется user1")
 		userID = "user1"
 	}
-	post, err := r.Storage.GetPost(ctx, postID)
+	// Комментировать можно только пост, который пользователь видит
+	post, err := storage.ViewPost(ctx, r.Storage, postID, userID)
 	if err != nil {
 		log.Printf("Ошибка при получении поста с ID=%s: %v", postID, err)
-		return nil, fmt.Errorf("failed to get post: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to get post: %v", err)
 	}
 	if !post.AllowComments {
 		log.Printf("Ошибка: комментарии отключены для поста %s", postID)
-		return nil, errors.New("comments are disabled for this post")
+		return nil, gqlerrors.New(gqlerrors.CodeForbidden, "comments are disabled for this post")
 	}
-	comment := &Comment{
-		ID:        uuid.New().String(),
-		PostID:    postID,
-		ParentID:  parentID,
-		AuthorID:  userID,
-		Content:   content,
-		CreatedAt: time.Now().Format(time.RFC3339),
+	if parentID != nil {
+		if err := r.checkNesting(ctx, *parentID); err != nil {
+			return nil, err
+		}
 	}
+	if quotedCommentID != nil {
+		if err := r.checkQuote(ctx, postID, *quotedCommentID, userID); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.checkTenantQuota(ctx, models.TargetComment, int64(len(content))); err != nil {
+		return nil, err
+	}
+	// Квота расходуется до проверки правилами и возвращается, если комментарий так и не сохранён
+	if err := r.consumeQuota(ctx, userID, quota.ActionComment); err != nil {
+		return nil, err
+	}
+	verdict, err := r.moderate(ctx, content)
+	if err != nil {
+		r.refundQuota(ctx, userID, quota.ActionComment)
+		return nil, err
+	}
+	shadowBanned, err := r.Storage.IsShadowBanned(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка при проверке теневого бана пользователя %s: %v", userID, err)
+		r.refundQuota(ctx, userID, quota.ActionComment)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to create comment: %v", err)
+	}
+	approval := newApprovalStatus(ctx, post)
 	internalComment := &models.Comment{
-		ID:        comment.ID,
-		PostID:    comment.PostID,
-		ParentID:  comment.ParentID,
-		AuthorID:  comment.AuthorID,
-		Content:   comment.Content,
-		CreatedAt: time.Now(),
+		ID:              r.IDs.New(),
+		PostID:          postID,
+		ParentID:        parentID,
+		AuthorID:        userID,
+		Content:         content,
+		Format:          formatOrDefault(format),
+		CreatedAt:       r.Clock.Now(),
+		Hidden:          shadowBanned || verdict.Held() || approval == models.ApprovalPending,
+		Tags:            verdict.Tags,
+		Language:        lang,
+		QuotedCommentID: quotedCommentID,
+		ApprovalStatus:  approval,
 	}
+	r.screenSpam(ctx, internalComment)
+	comment := toComment(internalComment)
 	log.Printf("Создание комментария: %+v", internalComment)
 	if err := r.Storage.CreateComment(ctx, internalComment); err != nil {
+		// Повторная отправка того же комментария: возвращаем уже созданный без повторного уведомления
+		var dup *storage.DuplicateCommentError
+		if errors.As(err, &dup) {
+			log.Printf("Комментарий уже создан, возвращается существующий: %s", dup.Existing.ID)
+			r.refundQuota(ctx, userID, quota.ActionComment)
+			return toComment(dup.Existing), nil
+		}
 		log.Printf("Ошибка при создании комментария: %v", err)
-		return nil, fmt.Errorf("failed to create comment: %v", err)
+		r.refundQuota(ctx, userID, quota.ActionComment)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to create comment: %v", err)
 	}
 	log.Printf("Комментарий успешно создан: %s", comment.ID)
-
-	// Отправка уведомления подписчикам
-	r.SubscriptionHandler.mu.Lock()
-	channels, exists := r.SubscriptionHandler.commentChannels[postID]
-	if exists {
-		log.Printf("Отправка уведомления для postID=%s, количество каналов: %d", postID, len(channels))
-		newChannels := make([]chan *Comment, 0, len(channels))
-		for i, ch := range channels {
-			select {
-			case ch <- comment:
-				log.Printf("Уведомление отправлено в канал %d для postID=%s", i, postID)
-				newChannels = append(newChannels, ch)
-			default:
-				log.Printf("Канал %d занят для postID=%s, удаление канала", i, postID)
-			}
-		}
-		r.SubscriptionHandler.commentChannels[postID] = newChannels
-		if len(newChannels) == 0 {
-			log.Printf("Все каналы удалены для postID=%s, удаление записи", postID)
-			delete(r.SubscriptionHandler.commentChannels, postID)
+	r.recordTenantUsage(ctx, models.TargetComment, int64(len(content)))
+	if verdict.Held() {
+		if err := r.hold(ctx, models.TargetComment, comment.ID, verdict); err != nil {
+			return nil, err
 		}
+	}
+	if r.Spam != nil {
+		r.Spam.Enqueue(spamSubmission(ctx, internalComment))
+	}
+	if r.Toxicity != nil {
+		r.Toxicity.Enqueue(internalComment)
+	}
+
+	// Отправка уведомления подписчикам; скрытый комментарий получает только сам автор
+	if internalComment.Hidden {
+		r.SubscriptionHandler.publishHidden(postID, comment)
 	} else {
-		log.Printf("Нет подписчиков для postID=%s", postID)
+		r.SubscriptionHandler.publish(postID, comment)
+		if r.Push != nil {
+			r.Push.CommentCreated(post, internalComment)
+		}
 	}
-	r.SubscriptionHandler.mu.Unlock()
 	return comment, nil
 }
 
-// CommentAdded реализует подписку commentAdded
-func (s *subscriptionHandler) CommentAdded(ctx context.Context, postID string) (<-chan *Comment, error) {
-	log.Printf("Запуск подписки commentAdded для postID=%s", postID)
-	ch := make(chan *Comment, 1)
-	s.mu.Lock()
-	s.commentChannels[postID] = append(s.commentChannels[postID], ch)
-	log.Printf("Канал добавлен для postID=%s, всего каналов: %d", postID, len(s.commentChannels[postID]))
-	s.mu.Unlock()
-
-	go func() {
-		<-ctx.Done()
-		log.Printf("Контекст подписки для postID=%s завершён", postID)
-		s.mu.Lock()
-		channels := s.commentChannels[postID]
-		for i, c := range channels {
-			if c == ch {
-				s.commentChannels[postID] = append(channels[:i], channels[i+1:]...)
-				log.Printf("Канал удалён для postID=%s, осталось каналов: %d", postID, len(s.commentChannels[postID]))
-				break
-			}
-		}
-		if len(s.commentChannels[postID]) == 0 {
-			log.Printf("Все каналы удалены для postID=%s, удаление записи", postID)
-			delete(s.commentChannels, postID)
-		}
-		s.mu.Unlock()
-		log.Printf("Закрытие канала для postID=%s", postID)
-		close(ch)
-	}()
-	return ch, nil
+// checkTenantQuota отклоняет новую запись kind размером size, если сообщество запроса исчерпало квоту хранилища
+func (r *mutationResolver) checkTenantQuota(ctx context.Context, kind string, size int64) error {
+	if r.TenantQuotas == nil {
+		return nil
+	}
+	if err := r.TenantQuotas.Check(ctx, tenantFromContext(ctx), kind, size); err != nil {
+		return quotaError(err)
+	}
+	return nil
+}
+
+// recordTenantUsage учитывает созданную запись в использовании сообщества. Запись уже сохранена,
+// поэтому ошибка учёта не ломает ответ
+func (r *mutationResolver) recordTenantUsage(ctx context.Context, kind string, size int64) {
+	if r.TenantQuotas == nil {
+		return
+	}
+	if err := r.TenantQuotas.Record(ctx, tenantFromContext(ctx), kind, size); err != nil {
+		log.Printf("Ошибка при учёте использования сообщества %s: %v", tenantFromContext(ctx), err)
+	}
+}
+
+// consumeQuota учитывает действие в квоте пользователя, если квоты включены
+func (r *mutationResolver) consumeQuota(ctx context.Context, userID string, action quota.Action) error {
+	if r.Quotas == nil {
+		return nil
+	}
+	role, _ := ctx.Value("role").(string)
+	if err := r.Quotas.Consume(ctx, userID, role, action); err != nil {
+		log.Printf("Действие %s пользователя %s отклонено по квоте: %v", action, userID, err)
+		return quotaError(err)
+	}
+	return nil
+}
+
+// refundQuota возвращает в квоту действие, которое не состоялось. Ответ от этого не зависит,
+// поэтому ошибка только записывается в журнал
+func (r *mutationResolver) refundQuota(ctx context.Context, userID string, action quota.Action) {
+	if r.Quotas == nil {
+		return
+	}
+	role, _ := ctx.Value("role").(string)
+	if err := r.Quotas.Refund(ctx, userID, role, action); err != nil {
+		log.Printf("Ошибка при возврате действия %s в квоту пользователя %s: %v", action, userID, err)
+	}
 }
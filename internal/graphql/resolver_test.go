@@ -6,10 +6,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/ids"
 	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/moderation"
+	"github.com/ButyrinIA/system/internal/quota"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
 	"github.com/graph-gophers/dataloader/v7"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // мок для интерфейса storage.Storage
@@ -17,11 +24,167 @@ type mockStorage struct {
 	mock.Mock
 }
 
-func (m *mockStorage) ListPosts(ctx context.Context, limit int, cursor *string) (*models.PaginatedPosts, error) {
-	args := m.Called(ctx, limit, cursor)
+func (m *mockStorage) ListPosts(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (*models.PaginatedPosts, error) {
+	args := m.Called(ctx, limit, cursor, filter)
 	return args.Get(0).(*models.PaginatedPosts), args.Error(1)
 }
 
+func (m *mockStorage) GetPostBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	args := m.Called(ctx, slug)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Post), args.Error(1)
+}
+
+func (m *mockStorage) ShortIDs(ctx context.Context, kind string, ids []string) (map[string]int64, error) {
+	args := m.Called(ctx, kind, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+
+func (m *mockStorage) ResolveShortID(ctx context.Context, seq int64) (string, string, error) {
+	args := m.Called(ctx, seq)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *mockStorage) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	args := m.Called(ctx, collection)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Collection), args.Error(1)
+}
+
+func (m *mockStorage) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	args := m.Called(ctx, collection)
+	return args.Error(0)
+}
+
+func (m *mockStorage) DeleteCollection(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListPostCollections(ctx context.Context, postID string) ([]models.Collection, error) {
+	args := m.Called(ctx, postID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Collection), args.Error(1)
+}
+
+func (m *mockStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) SetToxicityScore(ctx context.Context, score models.ToxicityScore, hold *models.HeldItem) error {
+	args := m.Called(ctx, score, hold)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetToxicityScores(ctx context.Context, commentIDs []string) (map[string]models.ToxicityScore, error) {
+	args := m.Called(ctx, commentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]models.ToxicityScore), args.Error(1)
+}
+
+func (m *mockStorage) SetPostRequireApproval(ctx context.Context, postID string, required bool) error {
+	args := m.Called(ctx, postID, required)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetPostExcerpt(ctx context.Context, postID, excerpt string) error {
+	args := m.Called(ctx, postID, excerpt)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetApprovalStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	args := m.Called(ctx, commentID, status, hidden)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListPendingComments(ctx context.Context, postID string, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, postID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) CreateComments(ctx context.Context, comments []*models.Comment) []error {
+	args := m.Called(ctx, comments)
+	return args.Get(0).([]error)
+}
+
+func (m *mockStorage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	args := m.Called(ctx, postID, title, slugBase)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Post), args.Error(1)
+}
+
+func (m *mockStorage) RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	args := m.Called(ctx, postID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Post), args.Error(1)
+}
+
+func (m *mockStorage) SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]storage.SimilarPost, error) {
+	args := m.Called(ctx, title, viewerID, threshold, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]storage.SimilarPost), args.Error(1)
+}
+
+func (m *mockStorage) SavePostReferences(ctx context.Context, postID string, targetIDs []string) error {
+	args := m.Called(ctx, postID, targetIDs)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetPostCategory(ctx context.Context, postID string, categoryID *string) error {
+	args := m.Called(ctx, postID, categoryID)
+	return args.Error(0)
+}
+
+func (m *mockStorage) CreateCategory(ctx context.Context, category *models.Category) error {
+	args := m.Called(ctx, category)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetCategory(ctx context.Context, id string) (*models.Category, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Category), args.Error(1)
+}
+
+func (m *mockStorage) ListCategories(ctx context.Context) ([]models.Category, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
 func (m *mockStorage) GetPost(ctx context.Context, id string) (*models.Post, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*models.Post), args.Error(1)
@@ -37,11 +200,330 @@ func (m *mockStorage) CreateComment(ctx context.Context, comment *models.Comment
 	return args.Error(0)
 }
 
-func (m *mockStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string) (*models.PaginatedComments, error) {
-	args := m.Called(ctx, postID, parentID, limit, cursor)
+func (m *mockStorage) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string, order models.SortOrder) (*models.PaginatedComments, error) {
+	args := m.Called(ctx, postID, parentID, limit, cursor, order)
 	return args.Get(0).(*models.PaginatedComments), args.Error(1)
 }
 
+func (m *mockStorage) CountCommentsBefore(ctx context.Context, commentID string, order models.SortOrder) (int, error) {
+	args := m.Called(ctx, commentID, order)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockStorage) SaveLinkPreview(ctx context.Context, preview *models.LinkPreview) error {
+	args := m.Called(ctx, preview)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetLinkPreviews(ctx context.Context, postID string) ([]models.LinkPreview, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]models.LinkPreview), args.Error(1)
+}
+
+func (m *mockStorage) ToggleReaction(ctx context.Context, reaction *models.Reaction) (bool, error) {
+	args := m.Called(ctx, reaction)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) GetReactionCounts(ctx context.Context, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	args := m.Called(ctx, targetIDs)
+	return args.Get(0).(map[string][]models.ReactionCount), args.Error(1)
+}
+
+func (m *mockStorage) VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, error) {
+	args := m.Called(ctx, vote)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) MarkThreadRead(ctx context.Context, userID, postID string, readAt time.Time) error {
+	args := m.Called(ctx, userID, postID, readAt)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetUnreadCommentCounts(ctx context.Context, userID string, postIDs []string) (map[string]int, error) {
+	args := m.Called(ctx, userID, postIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *mockStorage) IncrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) (int, error) {
+	args := m.Called(ctx, userID, action, windowStart)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockStorage) DecrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) error {
+	args := m.Called(ctx, userID, action, windowStart)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetPreferences(ctx context.Context, userID string) (*models.Preferences, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Preferences), args.Error(1)
+}
+
+func (m *mockStorage) SavePreferences(ctx context.Context, prefs *models.Preferences) error {
+	args := m.Called(ctx, prefs)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetTenantSettings(ctx context.Context, tenantID string) (*models.TenantSettings, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TenantSettings), args.Error(1)
+}
+
+func (m *mockStorage) SaveTenantSettings(ctx context.Context, settings *models.TenantSettings) error {
+	args := m.Called(ctx, settings)
+	return args.Error(0)
+}
+
+func (m *mockStorage) AddTenantUsage(ctx context.Context, tenantID, kind string, bytes int64) error {
+	args := m.Called(ctx, tenantID, kind, bytes)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetTenantUsage(ctx context.Context, tenantID string) (*models.TenantUsage, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TenantUsage), args.Error(1)
+}
+
+func (m *mockStorage) SetFollow(ctx context.Context, followerID, userID string, follow bool) error {
+	args := m.Called(ctx, followerID, userID, follow)
+	return args.Error(0)
+}
+
+func (m *mockStorage) IsFollowing(ctx context.Context, followerID, userID string) (bool, error) {
+	args := m.Called(ctx, followerID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) CountDescendants(ctx context.Context, commentIDs []string) (map[string]int, error) {
+	args := m.Called(ctx, commentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *mockStorage) Stats(ctx context.Context, slowQueries int) (*models.StorageStats, error) {
+	args := m.Called(ctx, slowQueries)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StorageStats), args.Error(1)
+}
+
+func (m *mockStorage) RegisterDeviceToken(ctx context.Context, token *models.DeviceToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UnregisterDeviceToken(ctx context.Context, userID, token string) error {
+	args := m.Called(ctx, userID, token)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListDeviceTokens(ctx context.Context, userID string) ([]models.DeviceToken, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DeviceToken), args.Error(1)
+}
+
+func (m *mockStorage) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	args := m.Called(ctx, search)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.SavedSearch), args.Error(1)
+}
+
+func (m *mockStorage) DeleteSavedSearch(ctx context.Context, userID, id string) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListAlertSavedSearches(ctx context.Context) ([]models.SavedSearch, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.SavedSearch), args.Error(1)
+}
+
+func (m *mockStorage) MarkSavedSearchChecked(ctx context.Context, id string, checkedAt time.Time) error {
+	args := m.Called(ctx, id, checkedAt)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SubscribeToPost(ctx context.Context, userID, postID string, at time.Time) error {
+	args := m.Called(ctx, userID, postID, at)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UnsubscribeFromPost(ctx context.Context, userID, postID string) error {
+	args := m.Called(ctx, userID, postID)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListDigestSubscribers(ctx context.Context) ([]models.DigestSubscriber, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DigestSubscriber), args.Error(1)
+}
+
+func (m *mockStorage) GetDigestComments(ctx context.Context, userID string, since, until time.Time, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, userID, since, until, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) MarkDigestSent(ctx context.Context, userID string, until time.Time) error {
+	args := m.Called(ctx, userID, until)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetShadowBan(ctx context.Context, userID string, banned bool) error {
+	args := m.Called(ctx, userID, banned)
+	return args.Error(0)
+}
+
+func (m *mockStorage) IsShadowBanned(ctx context.Context, userID string) (bool, error) {
+	args := m.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) ListModerationRules(ctx context.Context) ([]models.ModerationRule, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ModerationRule), args.Error(1)
+}
+
+func (m *mockStorage) CreateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UpdateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *mockStorage) DeleteModerationRule(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListAllowedOperations(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockStorage) AddAllowedOperation(ctx context.Context, query string) error {
+	args := m.Called(ctx, query)
+	return args.Error(0)
+}
+
+func (m *mockStorage) HoldContent(ctx context.Context, item *models.HeldItem) error {
+	args := m.Called(ctx, item)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListHeldContent(ctx context.Context, limit int) ([]models.HeldItem, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.HeldItem), args.Error(1)
+}
+
+func (m *mockStorage) ResolveHeldContent(ctx context.Context, id string, approve bool) error {
+	args := m.Called(ctx, id, approve)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetSpamStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	args := m.Called(ctx, commentID, status, hidden)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListCommentsBySpamStatus(ctx context.Context, status string, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, status, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) IteratePosts(ctx context.Context, fn func(post *models.Post) error) error {
+	args := m.Called(ctx, fn)
+	return args.Error(0)
+}
+
+func (m *mockStorage) IterateComments(ctx context.Context, postID string, fn func(comment *models.Comment) error) error {
+	args := m.Called(ctx, postID, fn)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListContents(ctx context.Context, kind, afterID string, limit int) ([]storage.ContentRecord, error) {
+	args := m.Called(ctx, kind, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]storage.ContentRecord), args.Error(1)
+}
+
+func (m *mockStorage) ReplaceContent(ctx context.Context, kind, id, previous, content string) (bool, error) {
+	args := m.Called(ctx, kind, id, previous, content)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	args := m.Called(ctx, kind, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockStorage) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
 func (m *mockStorage) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -64,12 +546,12 @@ func TestPosts(t *testing.T) {
 		TotalCount: 1,
 		NextCursor: nil,
 	}
-	storage.On("ListPosts", mock.Anything, 10, (*string)(nil)).Return(posts, nil)
+	storage.On("ListPosts", mock.Anything, 10, (*string)(nil), mock.Anything).Return(posts, nil)
 
 	resolver := NewResolver(storage, nil)
 	query := resolver.Query()
 
-	result, err := query.Posts(context.Background(), 10, nil)
+	result, err := query.Posts(context.Background(), 10, nil, nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, 1, result.TotalCount)
@@ -82,12 +564,12 @@ func TestPosts(t *testing.T) {
 
 func TestPosts_Error(t *testing.T) {
 	storage := &mockStorage{}
-	storage.On("ListPosts", mock.Anything, 10, (*string)(nil)).Return((*models.PaginatedPosts)(nil), errors.New("ошибка хранилища"))
+	storage.On("ListPosts", mock.Anything, 10, (*string)(nil), mock.Anything).Return((*models.PaginatedPosts)(nil), errors.New("ошибка хранилища"))
 
 	resolver := NewResolver(storage, nil)
 	query := resolver.Query()
 
-	result, err := query.Posts(context.Background(), 10, nil)
+	result, err := query.Posts(context.Background(), 10, nil, nil, nil, nil, nil, nil)
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.Equal(t, "failed to list posts: ошибка хранилища", err.Error())
@@ -135,16 +617,17 @@ func TestPost_Error(t *testing.T) {
 
 func TestComments(t *testing.T) {
 	storage := &mockStorage{}
+	storage.On("GetTenantSettings", mock.Anything, models.DefaultTenant).Return(models.DefaultTenantSettings(models.DefaultTenant), nil).Maybe()
 	createdAt := time.Now()
 	commentLoader := dataloader.NewBatchedLoader(
-		func(ctx context.Context, keys []string) []*dataloader.Result[*models.PaginatedComments] {
+		func(ctx context.Context, keys []CommentsKey) []*dataloader.Result[*models.PaginatedComments] {
 			results := make([]*dataloader.Result[*models.PaginatedComments], len(keys))
 			for i, key := range keys {
 				comments := &models.PaginatedComments{
 					Comments: []models.Comment{
 						{
 							ID:        "comment1",
-							PostID:    key,
+							PostID:    key.PostID,
 							AuthorID:  "user1",
 							Content:   "Тестовый комментарий",
 							CreatedAt: createdAt,
@@ -163,7 +646,7 @@ func TestComments(t *testing.T) {
 	postResolver := resolver.Post()
 
 	post := &Post{ID: "post1"}
-	result, err := postResolver.Comments(ctx, post, 10, nil)
+	result, err := postResolver.Comments(ctx, post, 10, nil, nil, nil, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, 1, result.TotalCount)
@@ -172,12 +655,42 @@ func TestComments(t *testing.T) {
 	assert.Equal(t, createdAt.Format(time.RFC3339), result.Comments[0].CreatedAt)
 }
 
+func TestComments_LimitAndCursor(t *testing.T) {
+	storage := &mockStorage{}
+	storage.On("GetTenantSettings", mock.Anything, models.DefaultTenant).Return(models.DefaultTenantSettings(models.DefaultTenant), nil).Maybe()
+	cursor := "2024-01-01T00:00:00Z"
+	storage.On("GetComments", mock.Anything, "post1", (*string)(nil), 3, &cursor, models.SortDesc).Return(&models.PaginatedComments{
+		Comments:   []models.Comment{{ID: "comment4", PostID: "post1"}},
+		TotalCount: 5,
+	}, nil)
+	storage.On("GetComments", mock.Anything, "post2", (*string)(nil), 25, (*string)(nil), models.SortAsc).Return(&models.PaginatedComments{
+		Comments:   []models.Comment{},
+		TotalCount: 0,
+	}, nil)
+
+	commentLoader := NewCommentLoader(storage)
+	ctx := context.WithValue(context.Background(), "commentLoader", commentLoader)
+	resolver := NewResolver(storage, commentLoader)
+
+	// Клиент получает именно запрошенную страницу, а не первые 10 комментариев
+	result, err := resolver.Post().Comments(ctx, &Post{ID: "post1"}, 3, &cursor, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, result.Comments, 1)
+	assert.Equal(t, "comment4", result.Comments[0].ID)
+
+	asc := SortOrderAsc
+	result, err = resolver.Post().Comments(ctx, &Post{ID: "post2"}, 25, nil, &asc, nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Comments)
+	storage.AssertExpectations(t)
+}
+
 func TestComments_NoLoader(t *testing.T) {
 	storage := &mockStorage{}
 	resolver := NewResolver(storage, nil)
 	postResolver := resolver.Post()
 
-	result, err := postResolver.Comments(context.Background(), &Post{ID: "post1"}, 10, nil)
+	result, err := postResolver.Comments(context.Background(), &Post{ID: "post1"}, 10, nil, nil, nil, nil)
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.Equal(t, "commentLoader not found in context", err.Error())
@@ -185,6 +698,7 @@ func TestComments_NoLoader(t *testing.T) {
 
 func TestReplies(t *testing.T) {
 	storage := &mockStorage{}
+	storage.On("GetTenantSettings", mock.Anything, models.DefaultTenant).Return(models.DefaultTenantSettings(models.DefaultTenant), nil).Maybe()
 	createdAt := time.Now()
 	comments := &models.PaginatedComments{
 		Comments: []models.Comment{
@@ -200,13 +714,13 @@ func TestReplies(t *testing.T) {
 		TotalCount: 1,
 		NextCursor: nil,
 	}
-	storage.On("GetComments", mock.Anything, "post1", stringPtr("comment1"), 10, (*string)(nil)).Return(comments, nil)
+	storage.On("GetComments", mock.Anything, "post1", stringPtr("comment1"), 10, (*string)(nil), models.SortDesc).Return(comments, nil)
 
 	resolver := NewResolver(storage, nil)
 	commentResolver := resolver.Comment()
 
 	comment := &Comment{ID: "comment1", PostID: "post1"}
-	result, err := commentResolver.Replies(context.Background(), comment, 10, nil)
+	result, err := commentResolver.Replies(context.Background(), comment, 10, nil, nil, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, 1, result.TotalCount)
@@ -218,16 +732,19 @@ func TestReplies(t *testing.T) {
 
 func TestReplies_Error(t *testing.T) {
 	storage := &mockStorage{}
-	storage.On("GetComments", mock.Anything, "post1", stringPtr("comment1"), 10, (*string)(nil)).Return((*models.PaginatedComments)(nil), errors.New("ошибка хранилища"))
+	storage.On("GetTenantSettings", mock.Anything, models.DefaultTenant).Return(models.DefaultTenantSettings(models.DefaultTenant), nil).Maybe()
+	storage.On("GetComments", mock.Anything, "post1", stringPtr("comment1"), 10, (*string)(nil), models.SortDesc).Return((*models.PaginatedComments)(nil), errors.New("ошибка хранилища"))
 
 	resolver := NewResolver(storage, nil)
 	commentResolver := resolver.Comment()
 
+	// Ошибка загрузки ответов не прерывает запрос: поле получает пустую страницу
 	comment := &Comment{ID: "comment1", PostID: "post1"}
-	result, err := commentResolver.Replies(context.Background(), comment, 10, nil)
-	assert.Error(t, err)
-	assert.Nil(t, result)
-	assert.Equal(t, "failed to load comment replies: ошибка хранилища", err.Error())
+	result, err := commentResolver.Replies(context.Background(), comment, 10, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Empty(t, result.Comments)
+	assert.Equal(t, 0, result.TotalCount)
 	storage.AssertExpectations(t)
 }
 
@@ -236,12 +753,14 @@ func TestCreatePost(t *testing.T) {
 	storage.On("CreatePost", mock.Anything, mock.AnythingOfType("*models.Post")).Return(nil)
 
 	resolver := NewResolver(storage, nil)
+	resolver.IDs = ids.NewSequence()
 	mutation := resolver.Mutation()
 	ctx := context.WithValue(context.Background(), "userID", "user1")
 
-	result, err := mutation.CreatePost(ctx, "Тестовый пост", "Содержимое", true)
+	result, err := mutation.CreatePost(ctx, "Тестовый пост", "Содержимое", true, nil, nil, nil, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
+	assert.Equal(t, ids.Seq(1), result.ID)
 	assert.Equal(t, "Тестовый пост", result.Title)
 	assert.Equal(t, "user1", result.AuthorID)
 	storage.AssertExpectations(t)
@@ -253,7 +772,7 @@ func TestCreatePost_ValidationError(t *testing.T) {
 	mutation := resolver.Mutation()
 
 	// Слишком длинный заголовок
-	result, err := mutation.CreatePost(context.Background(), string(make([]byte, 201)), "Содержимое", true)
+	result, err := mutation.CreatePost(context.Background(), string(make([]byte, 201)), "Содержимое", true, nil, nil, nil, nil)
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.Equal(t, "title exceeds 200 characters", err.Error())
@@ -266,13 +785,14 @@ func TestCreateComment(t *testing.T) {
 		AllowComments: true,
 	}
 	storage.On("GetPost", mock.Anything, "post1").Return(post, nil)
+	storage.On("IsShadowBanned", mock.Anything, "user1").Return(false, nil)
 	storage.On("CreateComment", mock.Anything, mock.AnythingOfType("*models.Comment")).Return(nil)
 
 	resolver := NewResolver(storage, nil)
 	mutation := resolver.Mutation()
 	ctx := context.WithValue(context.Background(), "userID", "user1")
 
-	result, err := mutation.CreateComment(ctx, "post1", nil, "Тестовый комментарий")
+	result, err := mutation.CreateComment(ctx, "post1", nil, "Тестовый комментарий", nil, nil, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, "post1", result.PostID)
@@ -291,13 +811,200 @@ func TestCreateComment_CommentsDisabled(t *testing.T) {
 	resolver := NewResolver(storage, nil)
 	mutation := resolver.Mutation()
 
-	result, err := mutation.CreateComment(context.Background(), "post1", nil, "Тестовый комментарий")
+	result, err := mutation.CreateComment(context.Background(), "post1", nil, "Тестовый комментарий", nil, nil, nil)
 	assert.Error(t, err)
 	assert.Nil(t, result)
 	assert.Equal(t, "comments are disabled for this post", err.Error())
 	storage.AssertExpectations(t)
 }
 
+func TestCreateComment_Duplicate(t *testing.T) {
+	store := &mockStorage{}
+	store.On("GetPost", mock.Anything, "post1").Return(&models.Post{ID: "post1", AllowComments: true}, nil)
+	store.On("IsShadowBanned", mock.Anything, "user1").Return(false, nil)
+	existing := &models.Comment{ID: "comment1", PostID: "post1", AuthorID: "user1", Content: "Тестовый комментарий", CreatedAt: time.Now()}
+	store.On("CreateComment", mock.Anything, mock.AnythingOfType("*models.Comment")).Return(&storage.DuplicateCommentError{Existing: existing})
+
+	resolver := NewResolver(store, nil)
+	ch, err := resolver.Subscription().CommentAdded(context.Background(), "post1", nil, nil)
+	assert.NoError(t, err)
+
+	result, err := resolver.Mutation().CreateComment(context.Background(), "post1", nil, "Тестовый комментарий", nil, nil, nil)
+	assert.NoError(t, err, "Повтор должен возвращать существующий комментарий, а не ошибку")
+	assert.Equal(t, "comment1", result.ID)
+	select {
+	case <-ch:
+		t.Fatal("Повторный комментарий не должен рассылаться подписчикам")
+	default:
+	}
+	store.AssertExpectations(t)
+}
+
+func TestCreateComment_DuplicateRefundsQuota(t *testing.T) {
+	store := memory.New()
+	store.SetDedupeWindow(time.Minute)
+	resolver := NewResolver(store, nil)
+	resolver.Quotas = quota.New(store, quota.Options{
+		DefaultRole: "user",
+		Roles:       map[string]quota.Limits{"user": {CommentsPerMinute: 2}},
+	})
+	ctx := userContext("user1", "")
+	post, err := resolver.Mutation().CreatePost(ctx, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	first, err := resolver.Mutation().CreateComment(ctx, post.ID, nil, "Комментарий", nil, nil, nil)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		again, err := resolver.Mutation().CreateComment(ctx, post.ID, nil, "Комментарий", nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, again.ID)
+	}
+	_, err = resolver.Mutation().CreateComment(ctx, post.ID, nil, "Другой комментарий", nil, nil, nil)
+	assert.NoError(t, err, "Повторы не расходуют квоту")
+	_, err = resolver.Mutation().CreateComment(ctx, post.ID, nil, "Третий комментарий", nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeQuotaExceeded, gqlerrors.Code(err))
+}
+
+// failingWrites отклоняет создание постов и комментариев, пока fail установлен
+type failingWrites struct {
+	storage.Storage
+	fail bool
+}
+
+func (s *failingWrites) CreatePost(ctx context.Context, post *models.Post) error {
+	if s.fail {
+		return errors.New("storage unavailable")
+	}
+	return s.Storage.CreatePost(ctx, post)
+}
+
+func (s *failingWrites) CreateComment(ctx context.Context, comment *models.Comment) error {
+	if s.fail {
+		return errors.New("storage unavailable")
+	}
+	return s.Storage.CreateComment(ctx, comment)
+}
+
+func TestCreate_RefundsQuotaWhenNotSaved(t *testing.T) {
+	store := &failingWrites{Storage: memory.New()}
+	resolver := NewResolver(store, nil)
+	resolver.Moderation = moderation.New(store, moderation.Options{})
+	resolver.Quotas = quota.New(store, quota.Options{
+		DefaultRole: "user",
+		Roles:       map[string]quota.Limits{"user": {PostsPerDay: 2, CommentsPerMinute: 1}},
+	})
+	mutation := resolver.Mutation()
+	_, err := mutation.CreateModerationRule(userContext("mod1", "moderator"), ModerationRuleInput{Kind: ModerationRuleKindKeyword, Pattern: "казино", Action: ModerationActionBlock})
+	require.NoError(t, err)
+	ctx := userContext("user1", "")
+
+	_, err = mutation.CreatePost(ctx, "Казино", "Содержимое", true, nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeContentBlocked, gqlerrors.Code(err))
+	store.fail = true
+	_, err = mutation.CreatePost(ctx, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeInternal, gqlerrors.Code(err))
+	store.fail = false
+	post, err := mutation.CreatePost(ctx, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err, "Отклонённые и несохранённые посты не расходуют квоту")
+
+	_, err = mutation.CreateComment(ctx, post.ID, nil, "Казино", nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeContentBlocked, gqlerrors.Code(err))
+	store.fail = true
+	_, err = mutation.CreateComment(ctx, post.ID, nil, "Комментарий", nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeInternal, gqlerrors.Code(err))
+	store.fail = false
+	_, err = mutation.CreateComment(ctx, post.ID, nil, "Комментарий", nil, nil, nil)
+	assert.NoError(t, err, "Отклонённые и несохранённые комментарии не расходуют квоту")
+	_, err = mutation.CreateComment(ctx, post.ID, nil, "Ещё комментарий", nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeQuotaExceeded, gqlerrors.Code(err))
+}
+
+func TestCreateComment_QuotaExceeded(t *testing.T) {
+	store := &mockStorage{}
+	store.On("GetPost", mock.Anything, "post1").Return(&models.Post{ID: "post1", AllowComments: true}, nil)
+	store.On("IncrementQuotaUsage", mock.Anything, "user1", "comment", mock.AnythingOfType("time.Time")).Return(2, nil)
+
+	resolver := NewResolver(store, nil)
+	resolver.Quotas = quota.New(store, quota.Options{
+		DefaultRole: "user",
+		Roles:       map[string]quota.Limits{"user": {CommentsPerMinute: 1}},
+	})
+
+	result, err := resolver.Mutation().CreateComment(context.Background(), "post1", nil, "Тестовый комментарий", nil, nil, nil)
+	assert.Nil(t, result)
+	assert.Equal(t, gqlerrors.CodeQuotaExceeded, gqlerrors.Code(err))
+	var gqlErr *gqlerrors.Error
+	assert.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, 1, gqlErr.Extensions["limit"])
+	assert.NotEmpty(t, gqlErr.Extensions["resetAt"])
+	store.AssertNotCalled(t, "CreateComment", mock.Anything, mock.Anything)
+	store.AssertExpectations(t)
+}
+
+func TestCreatePost_QuotaByRole(t *testing.T) {
+	store := &mockStorage{}
+	store.On("CreatePost", mock.Anything, mock.AnythingOfType("*models.Post")).Return(nil)
+
+	resolver := NewResolver(store, nil)
+	resolver.Quotas = quota.New(store, quota.Options{
+		DefaultRole: "user",
+		Roles:       map[string]quota.Limits{"user": {PostsPerDay: 1}, "moderator": {}},
+	})
+
+	// Модератор без лимитов не расходует квоту
+	ctx := context.WithValue(context.WithValue(context.Background(), "userID", "mod1"), "role", "moderator")
+	_, err := resolver.Mutation().CreatePost(ctx, "Заголовок", "Содержимое", true, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	store.AssertNotCalled(t, "IncrementQuotaUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateComment_ShadowBanned(t *testing.T) {
+	store := &mockStorage{}
+	store.On("GetPost", mock.Anything, "post1").Return(&models.Post{ID: "post1", AllowComments: true}, nil)
+	store.On("IsShadowBanned", mock.Anything, "banned").Return(true, nil)
+	store.On("CreateComment", mock.Anything, mock.MatchedBy(func(c *models.Comment) bool {
+		return c.Hidden && c.AuthorID == "banned"
+	})).Return(nil)
+
+	resolver := NewResolver(store, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	other, err := resolver.Subscription().CommentAdded(context.WithValue(ctx, "userID", "user1"), "post1", nil, nil)
+	assert.NoError(t, err)
+
+	// Автор получает комментарий как обычно и не узнаёт о бане
+	result, err := resolver.Mutation().CreateComment(context.WithValue(ctx, "userID", "banned"), "post1", nil, "Тестовый комментарий", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "banned", result.AuthorID)
+	select {
+	case <-other:
+		t.Fatal("Комментарий под теневым баном не должен рассылаться другим подписчикам")
+	case <-time.After(50 * time.Millisecond):
+	}
+	store.AssertExpectations(t)
+}
+
+func TestShadowBanUser(t *testing.T) {
+	store := &mockStorage{}
+	store.On("SetShadowBan", mock.Anything, "user2", true).Return(nil)
+	store.On("SetShadowBan", mock.Anything, "user2", false).Return(nil)
+	mutation := NewResolver(store, nil).Mutation()
+
+	_, err := mutation.ShadowBanUser(context.WithValue(context.Background(), "userID", "user1"), "user2", nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err), "Бан доступен только модераторам")
+	store.AssertNotCalled(t, "SetShadowBan", mock.Anything, mock.Anything, mock.Anything)
+
+	ctx := context.WithValue(context.Background(), "role", "moderator")
+	banned, err := mutation.ShadowBanUser(ctx, "user2", nil)
+	assert.NoError(t, err)
+	assert.True(t, banned)
+	lift := false
+	banned, err = mutation.ShadowBanUser(ctx, "user2", &lift)
+	assert.NoError(t, err)
+	assert.False(t, banned)
+	store.AssertExpectations(t)
+}
+
 func TestCommentAdded(t *testing.T) {
 	resolver := NewResolver(nil, nil)
 	subscription := resolver.Subscription()
@@ -305,22 +1012,12 @@ func TestCommentAdded(t *testing.T) {
 	defer cancel()
 
 	postID := "post1"
-	ch, err := subscription.CommentAdded(ctx, postID)
+	ch, err := subscription.CommentAdded(ctx, postID, nil, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, ch)
 
 	comment := &Comment{ID: "comment1", PostID: postID, Content: "Тестовый комментарий"}
-	resolver.SubscriptionHandler.mu.Lock()
-	resolver.SubscriptionHandler.commentChannels[postID] = append(resolver.SubscriptionHandler.commentChannels[postID])
-	resolver.SubscriptionHandler.mu.Unlock()
-
-	go func() {
-		resolver.SubscriptionHandler.mu.Lock()
-		for _, c := range resolver.SubscriptionHandler.commentChannels[postID] {
-			c <- comment
-		}
-		resolver.SubscriptionHandler.mu.Unlock()
-	}()
+	resolver.SubscriptionHandler.publish(postID, comment)
 
 	select {
 	case received := <-ch:
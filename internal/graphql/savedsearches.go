@@ -0,0 +1,148 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+const (
+	// maxSavedSearches - наибольшее число сохранённых поисков одного пользователя
+	maxSavedSearches = 20
+	// maxSavedSearchNameLength - максимальная длина названия сохранённого поиска в символах
+	maxSavedSearchNameLength = 100
+)
+
+// SavedSearches реализует запрос savedSearches
+func (r *queryResolver) SavedSearches(ctx context.Context) ([]*SavedSearch, error) {
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	searches, err := r.Storage.ListSavedSearches(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка при получении сохранённых поисков пользователя %s: %v", userID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to list saved searches: %v", err)
+	}
+	result := make([]*SavedSearch, len(searches))
+	for i := range searches {
+		result[i] = toSavedSearch(&searches[i])
+	}
+	return result, nil
+}
+
+// SaveSearch реализует мутацию saveSearch
+func (r *mutationResolver) SaveSearch(ctx context.Context, name string, categoryID *string, includeSubcategories *bool, filterInput *PostFilterInput, alert *bool) (*SavedSearch, error) {
+	log.Printf("Запуск мутации saveSearch: name=%s, categoryID=%v, filter=%+v, alert=%v", name, categoryID, filterInput, alert)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: сохранение поиска без авторизации")
+		return nil, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "saved search name is empty")
+	}
+	if utf8.RuneCountInString(name) > maxSavedSearchNameLength {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "saved search name exceeds %d characters", maxSavedSearchNameLength)
+	}
+	filter, err := toPostFilter(filterInput)
+	if err != nil {
+		return nil, err
+	}
+	// Уведомления сообщают о новых постах, поэтому фиксированный интервал времени в поиске бессмыслен
+	if filter.CreatedAfter != nil || filter.CreatedBefore != nil {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "saved searches cannot have a time range")
+	}
+	if categoryID != nil {
+		if _, err := r.Storage.GetCategory(ctx, *categoryID); err != nil {
+			log.Printf("Ошибка при получении категории с ID=%s: %v", *categoryID, err)
+			return nil, categoryError("failed to save search", err)
+		}
+	}
+	existing, err := r.Storage.ListSavedSearches(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка при получении сохранённых поисков пользователя %s: %v", userID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to save search: %v", err)
+	}
+	if len(existing) >= maxSavedSearches {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "at most %d saved searches are allowed", maxSavedSearches)
+	}
+	now := r.Clock.Now()
+	search := &models.SavedSearch{
+		ID:     r.IDs.New(),
+		UserID: userID,
+		Name:   name,
+		Filter: models.SearchFilter{
+			Text:                 filter.Text,
+			IncludeSubcategories: includeSubcategories == nil || *includeSubcategories,
+			AuthorID:             filter.AuthorID,
+			Tag:                  filter.Tag,
+			HasComments:          filter.HasComments,
+			AllowComments:        filter.AllowComments,
+			Language:             filter.Language,
+		},
+		Alert:     alert != nil && *alert,
+		CreatedAt: now,
+		CheckedAt: now,
+	}
+	if categoryID != nil {
+		search.Filter.CategoryID = *categoryID
+	}
+	if err := r.Storage.CreateSavedSearch(ctx, search); err != nil {
+		log.Printf("Ошибка при сохранении поиска: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to save search: %v", err)
+	}
+	log.Printf("Поиск %s сохранён пользователем %s", search.ID, userID)
+	return toSavedSearch(search), nil
+}
+
+// DeleteSavedSearch реализует мутацию deleteSavedSearch
+func (r *mutationResolver) DeleteSavedSearch(ctx context.Context, id string) (bool, error) {
+	log.Printf("Запуск мутации deleteSavedSearch: id=%s", id)
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return false, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	if err := r.Storage.DeleteSavedSearch(ctx, userID, id); err != nil {
+		log.Printf("Ошибка при удалении поиска %s: %v", id, err)
+		if errors.Is(err, storage.ErrSavedSearchNotFound) {
+			return false, gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to delete saved search: %v", err)
+		}
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to delete saved search: %v", err)
+	}
+	return true, nil
+}
+
+// toSavedSearch конвертирует сохранённый поиск в GraphQL-тип; пустые условия возвращаются как null
+func toSavedSearch(search *models.SavedSearch) *SavedSearch {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+	return &SavedSearch{
+		ID:                   search.ID,
+		Name:                 search.Name,
+		CategoryID:           optional(search.Filter.CategoryID),
+		IncludeSubcategories: search.Filter.IncludeSubcategories,
+		Filter: &SavedSearchFilter{
+			Text:          optional(search.Filter.Text),
+			AuthorID:      optional(search.Filter.AuthorID),
+			Tag:           optional(search.Filter.Tag),
+			HasComments:   search.Filter.HasComments,
+			AllowComments: search.Filter.AllowComments,
+			Language:      optional(search.Filter.Language),
+		},
+		Alert:     search.Alert,
+		CreatedAt: search.CreatedAt.Format(time.RFC3339),
+	}
+}
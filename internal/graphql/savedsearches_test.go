@@ -0,0 +1,89 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedSearches(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	user := userContext("user1", "")
+	other := userContext("user2", "")
+	mutation := resolver.Mutation()
+	query := resolver.Query()
+
+	_, err := mutation.SaveSearch(context.Background(), "Ракеты", nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = query.SavedSearches(context.Background())
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = mutation.SaveSearch(user, "  ", nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	after := "2024-01-01T00:00:00Z"
+	_, err = mutation.SaveSearch(user, "Ракеты", nil, nil, &PostFilterInput{CreatedAfter: &after}, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err), "Интервал времени не сохраняется")
+	missing := "missing"
+	_, err = mutation.SaveSearch(user, "Ракеты", &missing, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	text, tag := "ракет", "space"
+	alert := true
+	saved, err := mutation.SaveSearch(user, " Ракеты ", nil, nil, &PostFilterInput{Text: &text, Tag: &tag}, &alert)
+	require.NoError(t, err)
+	assert.Equal(t, "Ракеты", saved.Name)
+	assert.True(t, saved.Alert)
+	assert.True(t, saved.IncludeSubcategories)
+	assert.Nil(t, saved.CategoryID)
+	assert.Equal(t, &text, saved.Filter.Text)
+	assert.Equal(t, &tag, saved.Filter.Tag)
+	assert.Nil(t, saved.Filter.AuthorID)
+
+	searches, err := query.SavedSearches(user)
+	require.NoError(t, err)
+	require.Len(t, searches, 1)
+	assert.Equal(t, saved, searches[0])
+	searches, err = query.SavedSearches(other)
+	require.NoError(t, err)
+	assert.Empty(t, searches, "Чужие поиски не видны")
+
+	_, err = mutation.DeleteSavedSearch(other, saved.ID)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), "Чужой поиск удалить нельзя")
+	ok, err := mutation.DeleteSavedSearch(user, saved.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	searches, err = query.SavedSearches(user)
+	require.NoError(t, err)
+	assert.Empty(t, searches)
+
+	for i := 0; i < maxSavedSearches; i++ {
+		_, err = mutation.SaveSearch(other, fmt.Sprintf("Поиск %d", i), nil, nil, nil, nil)
+		require.NoError(t, err)
+	}
+	_, err = mutation.SaveSearch(other, "Лишний", nil, nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
+
+func TestPosts_TextFilter(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+	launch, err := mutation.CreatePost(user, "Запуск ракеты", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = mutation.CreatePost(user, "Погода", "Без осадков", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	inContent, err := mutation.CreatePost(user, "Новости", "Ракета стартовала", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	text := "РАКЕТ"
+	page, err := resolver.Query().Posts(user, 10, nil, nil, nil, nil, &PostFilterInput{Text: &text}, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 2)
+	assert.Equal(t, inContent.ID, page.Posts[0].ID)
+	assert.Equal(t, launch.ID, page.Posts[1].ID)
+}
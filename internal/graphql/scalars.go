@@ -0,0 +1,31 @@
+package graphql
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/ids"
+)
+
+// MarshalUUID записывает идентификатор сущности. Ответ не проверяется: идентификаторы
+// приходят из хранилища
+func MarshalUUID(id string) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, strconv.Quote(id))
+	})
+}
+
+// UnmarshalUUID разбирает аргумент типа ID. Идентификатор, который не мог выдать ни один генератор ids,
+// отклоняется с BAD_USER_INPUT до обращения к хранилищу
+func UnmarshalUUID(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "id must be a string, got %T", v)
+	}
+	if !ids.Valid(s) {
+		return "", gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid id %q: expected UUID or snowflake id", s)
+	}
+	return s, nil
+}
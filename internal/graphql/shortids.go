@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/ids"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// ShortID реализует поле shortId в Post
+func (r *postResolver) ShortID(ctx context.Context, obj *Post) (string, error) {
+	return r.loadShortID(ctx, models.TargetPost, obj.ID)
+}
+
+// ShortID реализует поле shortId в Comment
+func (r *commentResolver) ShortID(ctx context.Context, obj *Comment) (string, error) {
+	return r.loadShortID(ctx, models.TargetComment, obj.ID)
+}
+
+// loadShortID загружает короткий идентификатор через DataLoader из контекста.
+// Для сущности без номера возвращается ошибка NOT_FOUND, а не пустой идентификатор
+func (r *Resolver) loadShortID(ctx context.Context, kind, id string) (string, error) {
+	var shortID string
+	var err error
+	if loader, ok := ctx.Value("shortIDLoader").(*ShortIDLoader); ok {
+		shortID, err = loader.Load(ctx, ShortIDKey{Kind: kind, ID: id})()
+	} else {
+		log.Println("ShortIDLoader не найден в контексте, загрузка напрямую из хранилища")
+		var seqs map[string]int64
+		seqs, err = r.Storage.ShortIDs(ctx, kind, []string{id})
+		if seq, ok := seqs[id]; ok {
+			shortID = ids.EncodeShort(seq)
+		} else if err == nil {
+			err = storage.ErrShortIDNotFound
+		}
+	}
+	if errors.Is(err, storage.ErrShortIDNotFound) {
+		log.Printf("Короткий идентификатор %s %s не выдавался", kind, id)
+		return "", gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to get short id: %v", err)
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении короткого идентификатора %s %s: %v", kind, id, err)
+		return "", gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get short id: %v", err)
+	}
+	return shortID, nil
+}
+
+// PostByShortID реализует запрос postByShortId
+func (r *queryResolver) PostByShortID(ctx context.Context, shortID string) (*Post, error) {
+	log.Printf("Запрос postByShortId: %s", shortID)
+	id, err := r.resolveShortID(ctx, shortID, models.TargetPost)
+	if err != nil {
+		return nil, err
+	}
+	return r.Post(ctx, id)
+}
+
+// CommentByShortID реализует запрос commentByShortId
+func (r *queryResolver) CommentByShortID(ctx context.Context, shortID string, limit int, order *SortOrder) (*CommentPermalink, error) {
+	log.Printf("Запрос commentByShortId: %s", shortID)
+	id, err := r.resolveShortID(ctx, shortID, models.TargetComment)
+	if err != nil {
+		return nil, err
+	}
+	return r.CommentPermalink(ctx, id, limit, order)
+}
+
+// resolveShortID возвращает ID сущности вида kind по короткому идентификатору. Некорректный
+// идентификатор и идентификатор сущности другого вида не найдены, как и невыданный
+func (r *queryResolver) resolveShortID(ctx context.Context, shortID, kind string) (string, error) {
+	seq, err := ids.DecodeShort(shortID)
+	if err != nil {
+		return "", gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to resolve short id: %v", err)
+	}
+	targetKind, id, err := r.Storage.ResolveShortID(ctx, seq)
+	if err == nil && targetKind != kind {
+		err = storage.ErrShortIDNotFound
+	}
+	if errors.Is(err, storage.ErrShortIDNotFound) {
+		return "", gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to resolve short id: %v", err)
+	}
+	if err != nil {
+		log.Printf("Ошибка при разрешении короткого идентификатора %s: %v", shortID, err)
+		return "", gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to resolve short id: %v", err)
+	}
+	return id, nil
+}
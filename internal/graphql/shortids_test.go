@@ -0,0 +1,82 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortIDs(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user1", "")
+	other := userContext("user2", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	comment, err := mutation.CreateComment(author, post.ID, nil, "Комментарий", nil, nil, nil)
+	require.NoError(t, err)
+	private := PostVisibilityPrivate
+	note, err := mutation.CreatePost(author, "Заметка", "Содержимое", true, nil, nil, nil, &private)
+	require.NoError(t, err)
+
+	postShort, err := resolver.Post().ShortID(author, post)
+	require.NoError(t, err)
+	assert.Equal(t, "1", postShort)
+	commentShort, err := resolver.Comment().ShortID(author, comment)
+	require.NoError(t, err)
+	assert.Equal(t, "2", commentShort)
+	again, err := resolver.Post().ShortID(other, post)
+	require.NoError(t, err)
+	assert.Equal(t, postShort, again)
+
+	found, err := resolver.Query().PostByShortID(other, postShort)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, found.ID)
+	permalink, err := resolver.Query().CommentByShortID(other, commentShort, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, comment.ID, permalink.Comment.ID)
+	assert.Equal(t, post.ID, permalink.Post.ID)
+
+	noteShort, err := resolver.Post().ShortID(author, note)
+	require.NoError(t, err)
+	_, err = resolver.Query().PostByShortID(other, noteShort)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), "Короткий идентификатор не открывает доступ к посту")
+	_, err = resolver.Query().PostByShortID(author, noteShort)
+	assert.NoError(t, err)
+
+	for _, shortID := range []string{commentShort, "zz", "0", "a-b"} {
+		_, err = resolver.Query().PostByShortID(other, shortID)
+		assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), shortID)
+	}
+	_, err = resolver.Query().CommentByShortID(other, postShort, 10, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), "Идентификатор поста не находит комментарий")
+}
+
+func TestShortIDLoader_MissingSequence(t *testing.T) {
+	store := &mockStorage{}
+	store.On("ShortIDs", mock.Anything, models.TargetPost, mock.Anything).Return(map[string]int64{"post1": 5}, nil)
+	loader := NewShortIDLoader(store)
+	ctx := context.Background()
+
+	found := loader.Load(ctx, ShortIDKey{Kind: models.TargetPost, ID: "post1"})
+	missing := loader.Load(ctx, ShortIDKey{Kind: models.TargetPost, ID: "post2"})
+	shortID, err := found()
+	require.NoError(t, err)
+	assert.Equal(t, "5", shortID)
+	_, err = missing()
+	assert.ErrorIs(t, err, storage.ErrShortIDNotFound, "Без номера возвращается ошибка, а не пустой идентификатор")
+
+	resolver := NewResolver(store, nil)
+	_, err = resolver.Post().ShortID(context.WithValue(ctx, "shortIDLoader", loader), &Post{ID: "post2"})
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = resolver.Post().ShortID(ctx, &Post{ID: "post2"})
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), "Без DataLoader отсутствие номера обрабатывается так же")
+}
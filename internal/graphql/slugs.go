@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/slug"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// PostBySlug реализует запрос postBySlug
+func (r *queryResolver) PostBySlug(ctx context.Context, postSlug string) (*Post, error) {
+	log.Printf("Запрос postBySlug со slug=%s", postSlug)
+	post, err := r.Storage.GetPostBySlug(ctx, postSlug)
+	viewerID, _ := ctx.Value("userID").(string)
+	if err == nil {
+		err = storage.CheckPostVisible(ctx, r.Storage, post, viewerID)
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении поста со slug=%s: %v", postSlug, err)
+		return nil, categoryError("failed to get post", err)
+	}
+	if post.Slug != postSlug {
+		log.Printf("Slug %s поста %s устарел, актуальный: %s", postSlug, post.ID, post.Slug)
+	}
+	return toPost(post), nil
+}
+
+// UpdatePostTitle реализует мутацию updatePostTitle
+func (r *mutationResolver) UpdatePostTitle(ctx context.Context, postID string, title string) (*Post, error) {
+	log.Printf("Запуск мутации updatePostTitle: postID=%s, title=%s", postID, title)
+	if err := r.ContentLimits.CheckTitle(tenantFromContext(ctx), title); err != nil {
+		return nil, contentSizeError(err)
+	}
+	if _, err := r.editablePost(ctx, postID, "failed to update post title"); err != nil {
+		return nil, err
+	}
+	// Скрыть уже опубликованный пост до проверки нельзя, поэтому правка, подпадающая под HOLD, отклоняется.
+	// Теги правил TAG ставятся только при создании поста
+	verdict, err := r.moderate(ctx, title)
+	if err != nil {
+		return nil, err
+	}
+	if verdict.Held() {
+		log.Printf("Новый заголовок поста %s требует проверки правилом %s", postID, verdict.RuleID)
+		return nil, gqlerrors.New(gqlerrors.CodeContentBlocked, "title edit requires moderator review")
+	}
+	post, err := r.Storage.UpdatePostTitle(ctx, postID, title, slug.Make(title))
+	if err != nil {
+		log.Printf("Ошибка при изменении заголовка поста %s: %v", postID, err)
+		return nil, categoryError("failed to update post title", err)
+	}
+	r.invalidateRelated(postID)
+	log.Printf("Заголовок поста %s изменён, slug=%s", postID, post.Slug)
+	return toPost(post), nil
+}
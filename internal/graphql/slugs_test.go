@@ -0,0 +1,48 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostSlugs(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	author := userContext("user2", "")
+	other := userContext("user1", "")
+	mod := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+	query := resolver.Query()
+
+	first, err := mutation.CreatePost(author, "Привет, мир!", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "privet-mir", first.Slug)
+	second, err := mutation.CreatePost(author, "Привет мир", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "privet-mir-2", second.Slug, "Совпадающий slug получает суффикс")
+
+	got, err := query.PostBySlug(other, "privet-mir-2")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, got.ID)
+	_, err = query.PostBySlug(other, "missing")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	_, err = mutation.UpdatePostTitle(other, second.ID, "Чужой заголовок")
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+
+	updated, err := mutation.UpdatePostTitle(author, second.ID, "Привет, мир.")
+	require.NoError(t, err)
+	assert.Equal(t, "Привет, мир.", updated.Title)
+	assert.Equal(t, "privet-mir-2", updated.Slug, "Правка пунктуации не меняет адрес")
+
+	updated, err = mutation.UpdatePostTitle(mod, second.ID, "Новости недели")
+	require.NoError(t, err)
+	assert.Equal(t, "novosti-nedeli", updated.Slug)
+	got, err = query.PostBySlug(other, "privet-mir-2")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, got.ID, "Прежний slug ведёт на пост")
+	assert.Equal(t, "novosti-nedeli", got.Slug, "Ответ содержит актуальный slug для перенаправления")
+}
@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+)
+
+// snapshotPrefix отличает токен снимка от курсора
+const snapshotPrefix = "snapshot|"
+
+// encodeSnapshot кодирует границу снимка ленты at
+func encodeSnapshot(at time.Time) string {
+	raw := snapshotPrefix + strconv.FormatInt(at.UnixNano(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeSnapshot разбирает токен снимка
+func decodeSnapshot(token string) (time.Time, error) {
+	invalid := gqlerrors.New(gqlerrors.CodeBadUserInput, "invalid snapshot token")
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, invalid
+	}
+	nanos, ok := strings.CutPrefix(string(raw), snapshotPrefix)
+	if !ok {
+		return time.Time{}, invalid
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}, invalid
+	}
+	return time.Unix(0, n).UTC(), nil
+}
+
+// postsSnapshot возвращает границу снимка для запроса posts и токен, который вернётся клиенту.
+// Без токена снимок начинается сейчас: первая страница и так не видит постов, созданных после запроса,
+// поэтому граница в условия отбора не добавляется
+func (r *Resolver) postsSnapshot(snapshot *string) (*time.Time, string, error) {
+	if snapshot == nil {
+		return nil, encodeSnapshot(r.Clock.Now()), nil
+	}
+	at, err := decodeSnapshot(*snapshot)
+	if err != nil {
+		return nil, "", err
+	}
+	return &at, *snapshot, nil
+}
@@ -0,0 +1,116 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/spam"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// SpamStatus реализует поле spamStatus в Comment; статус виден только модераторам
+func (r *commentResolver) SpamStatus(ctx context.Context, obj *Comment) (*SpamStatus, error) {
+	if role, _ := ctx.Value("role").(string); role != roleModerator {
+		return nil, nil
+	}
+	comment, err := r.Storage.GetComment(ctx, obj.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении статуса проверки комментария %s: %v", obj.ID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get spam status: %v", err)
+	}
+	if comment.SpamStatus == "" {
+		return nil, nil
+	}
+	status := SpamStatus(comment.SpamStatus)
+	return &status, nil
+}
+
+// SpamComments реализует запрос spamComments
+func (r *queryResolver) SpamComments(ctx context.Context, status *SpamStatus, limit int) ([]*Comment, error) {
+	filter := SpamStatusSpam
+	if status != nil {
+		filter = *status
+	}
+	log.Printf("Запрос spamComments: status=%s, limit=%d", filter, limit)
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	comments, err := r.Storage.ListCommentsBySpamStatus(ctx, string(filter), limit)
+	if err != nil {
+		log.Printf("Ошибка при получении комментариев со статусом %s: %v", filter, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to list spam comments: %v", err)
+	}
+	result := make([]*Comment, len(comments))
+	for i := range comments {
+		result[i] = toComment(&comments[i])
+	}
+	return result, nil
+}
+
+// MarkSpam реализует мутацию markSpam
+func (r *mutationResolver) MarkSpam(ctx context.Context, commentID string, isSpam bool) (*Comment, error) {
+	log.Printf("Запуск мутации markSpam: commentID=%s, spam=%t", commentID, isSpam)
+	if err := requireModerator(ctx); err != nil {
+		return nil, err
+	}
+	comment, err := r.Storage.GetComment(ctx, commentID)
+	if err != nil {
+		log.Printf("Ошибка при получении комментария %s: %v", commentID, err)
+		return nil, commentError("failed to mark spam", err)
+	}
+	status, hidden := models.SpamStatusSpam, true
+	if !isSpam {
+		status = models.SpamStatusHam
+		// Комментарий пользователя под теневым баном остаётся видимым только автору
+		hidden, err = r.Storage.IsShadowBanned(ctx, comment.AuthorID)
+		if err != nil {
+			log.Printf("Ошибка при проверке теневого бана пользователя %s: %v", comment.AuthorID, err)
+			return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to mark spam: %v", err)
+		}
+	}
+	if err := r.Storage.SetSpamStatus(ctx, commentID, status, hidden); err != nil {
+		log.Printf("Ошибка при сохранении статуса проверки комментария %s: %v", commentID, err)
+		return nil, commentError("failed to mark spam", err)
+	}
+	if r.Spam != nil {
+		// Ошибка обучения не отменяет решение модератора
+		if err := r.Spam.Report(ctx, spam.Submission{Comment: comment}, isSpam); err != nil {
+			log.Printf("Ошибка передачи решения по комментарию %s сервису проверки: %v", commentID, err)
+		}
+	}
+	updated := *comment
+	updated.SpamStatus, updated.Hidden = status, hidden
+	return toComment(&updated), nil
+}
+
+// screenSpam проверяет новый комментарий на спам, если проверка включена, и скрывает спам
+func (r *mutationResolver) screenSpam(ctx context.Context, comment *models.Comment) {
+	if r.Spam == nil {
+		return
+	}
+	comment.SpamStatus = r.Spam.Screen(ctx, spamSubmission(ctx, comment))
+	if comment.SpamStatus == models.SpamStatusSpam {
+		comment.Hidden = true
+	}
+}
+
+// spamSubmission дополняет комментарий IP и User-Agent клиента из контекста запроса
+func spamSubmission(ctx context.Context, comment *models.Comment) spam.Submission {
+	ip, _ := ctx.Value("clientIP").(string)
+	userAgent, _ := ctx.Value("userAgent").(string)
+	return spam.Submission{Comment: comment, IP: ip, UserAgent: userAgent}
+}
+
+// commentError возвращает NOT_FOUND для отсутствующего комментария, FORBIDDEN для архивного, иначе INTERNAL
+func commentError(message string, err error) error {
+	if errors.Is(err, storage.ErrCommentNotFound) {
+		return gqlerrors.Errorf(gqlerrors.CodeNotFound, "%s: %v", message, err)
+	}
+	if errors.Is(err, storage.ErrCommentArchived) {
+		return gqlerrors.Errorf(gqlerrors.CodeForbidden, "%s: %v", message, err)
+	}
+	return gqlerrors.Errorf(gqlerrors.CodeInternal, "%s: %v", message, err)
+}
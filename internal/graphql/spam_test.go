@@ -0,0 +1,97 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/spam"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSpamResolver(t *testing.T) (*Resolver, *spam.Bayes) {
+	t.Helper()
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	bayes := spam.NewBayes(0)
+	bayes.Train("Купите дешёвые часы со скидкой", true)
+	bayes.Train("Спасибо за интересную статью", false)
+	resolver.Spam = spam.New(bayes, store, spam.Options{})
+	return resolver, bayes
+}
+
+func TestCreateComment_Spam(t *testing.T) {
+	resolver, _ := newSpamResolver(t)
+	author := userContext("user2", "")
+	other := userContext("user1", "")
+	mod := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	spamComment, err := mutation.CreateComment(author, post.ID, nil, "Купите дешёвые часы со скидкой!", nil, nil, nil)
+	require.NoError(t, err)
+	hamComment, err := mutation.CreateComment(author, post.ID, nil, "Спасибо, статья интересная", nil, nil, nil)
+	require.NoError(t, err)
+
+	replies, err := resolver.Storage.GetComments(viewerContext(other), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	require.Len(t, replies.Comments, 1, "Спам скрыт от других пользователей")
+	assert.Equal(t, hamComment.ID, replies.Comments[0].ID)
+
+	status, err := resolver.Comment().SpamStatus(other, spamComment)
+	require.NoError(t, err)
+	assert.Nil(t, status, "Статус проверки виден только модераторам")
+	status, err = resolver.Comment().SpamStatus(mod, spamComment)
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, SpamStatusSpam, *status)
+
+	listed, err := resolver.Query().SpamComments(mod, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, spamComment.ID, listed[0].ID)
+	_, err = resolver.Query().SpamComments(other, nil, 10)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+}
+
+func TestMarkSpam(t *testing.T) {
+	resolver, bayes := newSpamResolver(t)
+	author := userContext("user2", "")
+	other := userContext("user1", "")
+	mod := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	comment, err := mutation.CreateComment(author, post.ID, nil, "Выгодные кредиты без проверки", nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = mutation.MarkSpam(other, comment.ID, true)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	_, err = mutation.MarkSpam(mod, "missing", true)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+
+	before := bayes.Score("кредиты без проверки")
+	_, err = mutation.MarkSpam(mod, comment.ID, true)
+	require.NoError(t, err)
+	assert.Greater(t, bayes.Score("кредиты без проверки"), before, "Решение модератора обучает классификатор")
+	replies, err := resolver.Storage.GetComments(viewerContext(other), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	assert.Empty(t, replies.Comments)
+
+	_, err = mutation.MarkSpam(mod, comment.ID, false)
+	require.NoError(t, err)
+	replies, err = resolver.Storage.GetComments(viewerContext(other), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	assert.Len(t, replies.Comments, 1, "Комментарий, признанный не спамом, виден всем")
+
+	_, err = mutation.ShadowBanUser(mod, "user2", nil)
+	require.NoError(t, err)
+	_, err = mutation.MarkSpam(mod, comment.ID, false)
+	require.NoError(t, err)
+	replies, err = resolver.Storage.GetComments(viewerContext(context.Background()), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	assert.Empty(t, replies.Comments, "Комментарий пользователя под теневым баном остаётся скрытым")
+}
@@ -0,0 +1,60 @@
+package graphql
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// maxSlowQueries - наибольшее число медленных запросов в ответе storageStats
+const maxSlowQueries = 100
+
+// StorageStats реализует запрос storageStats
+func (r *queryResolver) StorageStats(ctx context.Context, slowQueries *int) (*StorageStats, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	limit := 0
+	if slowQueries != nil {
+		limit = *slowQueries
+	}
+	if limit < 0 || limit > maxSlowQueries {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "slowQueries must be between 0 and %d", maxSlowQueries)
+	}
+	stats, err := r.Storage.Stats(ctx, limit)
+	if err != nil {
+		log.Printf("Ошибка при получении статистики хранилища: %v", err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get storage stats: %v", err)
+	}
+	return toStorageStats(stats), nil
+}
+
+func toStorageStats(stats *models.StorageStats) *StorageStats {
+	result := &StorageStats{
+		Backend:              stats.Backend,
+		Tables:               make([]*TableStats, 0, len(stats.Tables)),
+		SlowQueriesAvailable: stats.SlowQueriesAvailable,
+		SlowQueries:          make([]*QueryStats, 0, len(stats.SlowQueries)),
+	}
+	for _, table := range stats.Tables {
+		result.Tables = append(result.Tables, &TableStats{
+			Name:       table.Name,
+			Rows:       int(table.Rows),
+			DataBytes:  int(table.DataBytes),
+			IndexBytes: int(table.IndexBytes),
+		})
+	}
+	for _, query := range stats.SlowQueries {
+		result.SlowQueries = append(result.SlowQueries, &QueryStats{
+			Query:       query.Query,
+			Calls:       int(query.Calls),
+			Rows:        int(query.Rows),
+			TotalTimeMs: float64(query.TotalTime) / float64(time.Millisecond),
+			MeanTimeMs:  float64(query.MeanTime) / float64(time.Millisecond),
+		})
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageStats(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	user := userContext("user1", "")
+	admin := userContext("admin1", roleAdmin)
+
+	post, err := resolver.Mutation().CreatePost(user, "Пост", "Текст", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().CreateComment(user, post.ID, nil, "Первый", nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Query().StorageStats(user, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	tooMany := maxSlowQueries + 1
+	_, err = resolver.Query().StorageStats(admin, &tooMany)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	stats, err := resolver.Query().StorageStats(admin, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "memory", stats.Backend)
+	assert.False(t, stats.SlowQueriesAvailable)
+	assert.Empty(t, stats.SlowQueries)
+	tables := make(map[string]*TableStats, len(stats.Tables))
+	for _, table := range stats.Tables {
+		tables[table.Name] = table
+	}
+	require.Contains(t, tables, "posts")
+	assert.Equal(t, &TableStats{Name: "posts", Rows: 1, DataBytes: len("Пост") + len("Текст")}, tables["posts"])
+	assert.Equal(t, &TableStats{Name: "comments", Rows: 1, DataBytes: len("Первый")}, tables["comments"])
+}
@@ -0,0 +1,531 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/cluster"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storm"
+)
+
+const (
+	// subscriptionShards - количество шардов подписок; подписки поста всегда попадают в один шард
+	subscriptionShards = 32
+	// fanoutQueueSize - размер очереди каждого обработчика рассылки
+	fanoutQueueSize = 1024
+	// replayBufferSize - сколько последних комментариев поста хранится для повторной отправки
+	replayBufferSize = 100
+	// replayRetention - время, после которого история поста без новых комментариев удаляется
+	replayRetention = 10 * time.Minute
+)
+
+// subscriber - канал одного подписчика commentAdded
+type subscriber struct {
+	id     uint64
+	postID string
+	// viewerID - пользователь подписки; ему доставляются его собственные скрытые комментарии
+	viewerID string
+	// tenant - сообщество подписки, политика анонимного доступа которого проверяется при каждом событии
+	tenant string
+	ch     chan *Comment
+	mu     sync.RWMutex
+	closed bool
+}
+
+// receives сообщает, доставляется ли подписчику комментарий; скрытый комментарий получает только автор
+func (s *subscriber) receives(comment *Comment, hidden bool) bool {
+	return !hidden || s.viewerID != "" && s.viewerID == comment.AuthorID
+}
+
+// send неблокирующе отправляет комментарий; false означает, что буфер подписчика переполнен
+func (s *subscriber) send(comment *Comment) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return true
+	}
+	select {
+	case s.ch <- comment:
+		return true
+	default:
+		return false
+	}
+}
+
+// close закрывает канал подписчика; отправка после закрытия игнорируется
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// replayEvent - комментарий из истории поста и время его публикации
+type replayEvent struct {
+	comment     *Comment
+	hidden      bool
+	publishedAt time.Time
+}
+
+// replayBuffer - кольцевой буфер последних комментариев поста
+type replayBuffer struct {
+	events []replayEvent
+	next   int
+	full   bool
+}
+
+func (b *replayBuffer) add(event replayEvent) {
+	if len(b.events) < replayBufferSize {
+		b.events = append(b.events, event)
+		return
+	}
+	b.events[b.next] = event
+	b.next = (b.next + 1) % replayBufferSize
+	b.full = true
+}
+
+// ordered возвращает события от старых к новым
+func (b *replayBuffer) ordered() []replayEvent {
+	if !b.full {
+		return b.events
+	}
+	return append(append([]replayEvent{}, b.events[b.next:]...), b.events[:b.next]...)
+}
+
+// last возвращает время последнего события
+func (b *replayBuffer) last() time.Time {
+	events := b.ordered()
+	return events[len(events)-1].publishedAt
+}
+
+// since выбирает события для повторной отправки подписчику sub. Если известен id последнего полученного
+// комментария, отправляется всё после него; иначе - всё позже sinceTime, а без него - вся история.
+func (b *replayBuffer) since(sub *subscriber, eventID *string, sinceTime *time.Time) []*Comment {
+	events := b.ordered()
+	start := -1
+	if eventID != nil {
+		for i, event := range events {
+			if event.comment.ID == *eventID {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if start < 0 {
+		start = 0
+		if sinceTime != nil {
+			for start < len(events) && !events[start].publishedAt.After(*sinceTime) {
+				start++
+			}
+		}
+	}
+	result := make([]*Comment, 0, len(events)-start)
+	for _, event := range events[start:] {
+		if sub.receives(event.comment, event.hidden) {
+			result = append(result, event.comment)
+		}
+	}
+	return result
+}
+
+// subscriptionShard хранит подписчиков и историю части постов под собственной блокировкой
+type subscriptionShard struct {
+	mu          sync.RWMutex
+	subscribers map[string][]*subscriber
+	history     map[string]*replayBuffer
+	typists     map[string][]*typingSubscriber
+	// digests - события постов в шторме, ожидающие очередной сводки
+	digests map[string][]replayEvent
+}
+
+// fanoutJob - доставка комментариев группе подписчиков одного обработчика; подписчик получает
+// комментарии по порядку, но только те, что ему доставляются
+type fanoutJob struct {
+	events      []replayEvent
+	subscribers []*subscriber
+}
+
+// subscriptionHandler реализует SubscriptionResolver.
+// Подписки разбиты на шарды по postID, чтобы популярный пост не блокировал остальные,
+// а доставка выполняется пулом обработчиков вне блокировок шардов.
+// Каждый подписчик закреплён за одним обработчиком, поэтому порядок комментариев для него сохраняется.
+type subscriptionHandler struct {
+	// store проверяет пост при начале подписки и перед доставкой каждого события; nil отключает проверки
+	store  storage.Storage
+	shards [subscriptionShards]*subscriptionShard
+	queues []chan fanoutJob
+	nextID atomic.Uint64
+
+	// typingMu защищает lastTyping - время последнего принятого сигнала набора по паре пользователь/пост
+	typingMu   sync.Mutex
+	lastTyping map[string]time.Time
+
+	// storm включает сводки для постов, события которых идут чаще порога; nil - события доставляются сразу.
+	// digestMax - сколько последних комментариев поста попадает в одну сводку
+	storm     *storm.Detector
+	digestMax int
+
+	// cluster пересылает события соседним узлам; nil - подписчики есть только на этом узле
+	cluster *cluster.Node
+}
+
+// newSubscriptionHandler создаёт новый subscriptionHandler и запускает пул рассылки
+func newSubscriptionHandler(store storage.Storage) *subscriptionHandler {
+	workers := runtime.NumCPU()
+	log.Printf("Создание нового subscriptionHandler: шардов=%d, обработчиков рассылки=%d", subscriptionShards, workers)
+	h := &subscriptionHandler{store: store, queues: make([]chan fanoutJob, workers), lastTyping: make(map[string]time.Time)}
+	for i := range h.shards {
+		h.shards[i] = &subscriptionShard{
+			subscribers: make(map[string][]*subscriber),
+			history:     make(map[string]*replayBuffer),
+			typists:     make(map[string][]*typingSubscriber),
+			digests:     make(map[string][]replayEvent),
+		}
+	}
+	go h.pruneHistory()
+	for i := range h.queues {
+		h.queues[i] = make(chan fanoutJob, fanoutQueueSize)
+		go h.fanoutWorker(h.queues[i])
+	}
+	return h
+}
+
+// EnableDigests включает сводки во время шторма: события поста, по которому detector распознал шторм,
+// копятся и рассылаются раз в interval одной пачкой из не более maxComments последних комментариев.
+// Вызывается до начала обслуживания подписок
+func (h *subscriptionHandler) EnableDigests(detector *storm.Detector, interval time.Duration, maxComments int) {
+	log.Printf("Включены сводки подписок во время шторма: интервал %v, до %d комментариев", interval, maxComments)
+	h.storm = detector
+	h.digestMax = maxComments
+	go h.flushDigests(interval)
+}
+
+// EnableForwarding включает пересылку событий соседним узлам кластера, чтобы их получали подписчики,
+// подключённые к другим узлам. Вызывается до начала обслуживания подписок
+func (h *subscriptionHandler) EnableForwarding(node *cluster.Node) {
+	log.Printf("Включена пересылка событий подписок узлам кластера с узла %s", node.ID())
+	h.cluster = node
+}
+
+// ReceiveForwarded публикует подписчикам этого узла событие, пересланное соседним узлом
+func (h *subscriptionHandler) ReceiveForwarded(event cluster.Event) error {
+	var comment Comment
+	if err := json.Unmarshal(event.Comment, &comment); err != nil {
+		return fmt.Errorf("invalid forwarded comment: %w", err)
+	}
+	if comment.PostID != event.PostID {
+		return fmt.Errorf("forwarded comment belongs to post %s, not %s", comment.PostID, event.PostID)
+	}
+	log.Printf("Событие узла %s для postID=%s, commentID=%s", event.Origin, event.PostID, comment.ID)
+	h.publishLocal(event.PostID, &comment, event.Hidden)
+	return nil
+}
+
+// shard возвращает шард, в котором хранятся подписки поста
+func (h *subscriptionHandler) shard(postID string) *subscriptionShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(postID))
+	return h.shards[hash.Sum32()%subscriptionShards]
+}
+
+// checkPost возвращает NOT_FOUND, если поста нет или он скрыт от пользователя подписки
+func (h *subscriptionHandler) checkPost(ctx context.Context, postID string) error {
+	if h.store == nil {
+		return nil
+	}
+	viewerID, _ := ctx.Value("userID").(string)
+	_, err := storage.ViewPost(ctx, h.store, postID, viewerID)
+	if err != nil {
+		log.Printf("Подписка на пост %s отклонена: %v", postID, err)
+		return categoryError("failed to subscribe", err)
+	}
+	return nil
+}
+
+// eventAccess - состояние поста, подписки зрителей на его автора и политики сообществ подписчиков
+// на момент публикации события. nil разрешает доставку всем подписчикам
+type eventAccess struct {
+	post      *models.Post
+	following map[string]bool
+	policies  map[string]string
+}
+
+// allows сообщает, получает ли событие пользователь viewerID из сообщества tenant
+func (a *eventAccess) allows(viewerID, tenant string) bool {
+	if a == nil {
+		return true
+	}
+	if a.post == nil || !storage.PostVisibleToFollower(a.post, viewerID, a.following[viewerID]) {
+		return false
+	}
+	return viewerID != "" || a.policies[tenant] != models.AnonymousDeny
+}
+
+// checkAccess читает пост, подписки зрителей viewers на автора поста FOLLOWERS и политики анонимного
+// доступа сообществ tenants: после начала подписки пост мог быть удалён, задержан или закрыт, зритель -
+// отписаться от автора, а сообщество - запретить анонимный доступ. Если пост не удалось прочитать,
+// событие не доставляется никому; в истории поста оно остаётся. Зритель, подписку которого не удалось
+// проверить, событие не получает
+func (h *subscriptionHandler) checkAccess(postID string, viewers, tenants map[string]bool) *eventAccess {
+	if h.store == nil {
+		return nil
+	}
+	ctx := context.Background()
+	access := &eventAccess{following: make(map[string]bool), policies: make(map[string]string, len(tenants))}
+	post, err := h.store.GetPost(ctx, postID)
+	if err != nil {
+		log.Printf("Ошибка при проверке доступа к событиям поста %s, событие не доставляется: %v", postID, err)
+		return access
+	}
+	access.post = post
+	if post.Visibility == models.VisibilityFollowers {
+		for viewerID := range viewers {
+			following, err := h.store.IsFollowing(ctx, viewerID, post.AuthorID)
+			if err != nil {
+				log.Printf("Ошибка при проверке подписки %s на автора поста %s: %v", viewerID, postID, err)
+			}
+			access.following[viewerID] = following
+		}
+	}
+	for tenant := range tenants {
+		access.policies[tenant] = tenantSettings(context.WithValue(ctx, "tenant", tenant), h.store).AnonymousPolicy
+	}
+	return access
+}
+
+// CommentAdded реализует подписку commentAdded.
+// При переподключении клиент передаёт sinceEventId или sinceTimestamp и сначала получает
+// пропущенные комментарии из истории поста, затем новые. Повторы возможны, клиенту следует
+// отбрасывать уже полученные id.
+func (h *subscriptionHandler) CommentAdded(ctx context.Context, postID string, sinceEventID *string, sinceTimestamp *string) (<-chan *Comment, error) {
+	log.Printf("Запуск подписки commentAdded для postID=%s, sinceEventID=%v, sinceTimestamp=%v", postID, sinceEventID, sinceTimestamp)
+	var sinceTime *time.Time
+	if sinceTimestamp != nil {
+		parsed, err := time.Parse(time.RFC3339, *sinceTimestamp)
+		if err != nil {
+			log.Printf("Некорректный sinceTimestamp %s: %v", *sinceTimestamp, err)
+			return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid sinceTimestamp: %v", err)
+		}
+		sinceTime = &parsed
+	}
+	if err := h.checkPost(ctx, postID); err != nil {
+		return nil, err
+	}
+	replay := sinceEventID != nil || sinceTime != nil
+	viewerID, _ := ctx.Value("userID").(string)
+
+	shard := h.shard(postID)
+	shard.mu.Lock()
+	// История и регистрация берутся под одной блокировкой, поэтому между ними нет пропусков
+	sub := &subscriber{id: h.nextID.Add(1), postID: postID, viewerID: viewerID, tenant: tenantFromContext(ctx)}
+	var missed []*Comment
+	if buffer, ok := shard.history[postID]; ok && replay {
+		missed = buffer.since(sub, sinceEventID, sinceTime)
+	}
+	// Буфер вмещает сводку целиком, иначе подписчика отключила бы первая же сводка
+	sub.ch = make(chan *Comment, len(missed)+max(1, h.digestMax))
+	for _, comment := range missed {
+		sub.ch <- comment
+	}
+	shard.subscribers[postID] = append(shard.subscribers[postID], sub)
+	metrics.SubscriptionsActive.WithLabelValues(subscriptionCommentAdded).Inc()
+	log.Printf("Канал добавлен для postID=%s, всего каналов: %d, повторно отправлено: %d", postID, len(shard.subscribers[postID]), len(missed))
+	shard.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		log.Printf("Контекст подписки для postID=%s завершён", postID)
+		h.unsubscribe(sub)
+		log.Printf("Закрытие канала для postID=%s", postID)
+		sub.close()
+	}()
+	return sub.ch, nil
+}
+
+// unsubscribe удаляет подписчика из шарда; повторный вызов ничего не делает
+func (h *subscriptionHandler) unsubscribe(sub *subscriber) {
+	shard := h.shard(sub.postID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	subscribers := shard.subscribers[sub.postID]
+	for i, s := range subscribers {
+		if s == sub {
+			shard.subscribers[sub.postID] = append(subscribers[:i:i], subscribers[i+1:]...)
+			metrics.SubscriptionsActive.WithLabelValues(subscriptionCommentAdded).Dec()
+			log.Printf("Канал удалён для postID=%s, осталось каналов: %d", sub.postID, len(shard.subscribers[sub.postID]))
+			break
+		}
+	}
+	if len(shard.subscribers[sub.postID]) == 0 {
+		delete(shard.subscribers, sub.postID)
+	}
+}
+
+// pruneHistory периодически удаляет историю постов, в которых давно не было комментариев,
+// и устаревшие отметки ограничения сигналов набора
+func (h *subscriptionHandler) pruneHistory() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.pruneTyping(time.Now())
+		cutoff := time.Now().Add(-replayRetention)
+		for _, shard := range h.shards {
+			shard.mu.Lock()
+			for postID, buffer := range shard.history {
+				if buffer.last().Before(cutoff) {
+					delete(shard.history, postID)
+				}
+			}
+			shard.mu.Unlock()
+		}
+	}
+}
+
+// publish сохраняет комментарий в истории поста и ставит его доставку комментария подписчикам поста в очереди обработчиков
+func (h *subscriptionHandler) publish(postID string, comment *Comment) {
+	h.publishEvent(postID, comment, false)
+}
+
+// publishHidden публикует скрытый комментарий: его получают только подписки автора
+func (h *subscriptionHandler) publishHidden(postID string, comment *Comment) {
+	h.publishEvent(postID, comment, true)
+}
+
+// publishEvent публикует событие подписчикам этого узла и пересылает его соседним узлам кластера
+func (h *subscriptionHandler) publishEvent(postID string, comment *Comment, hidden bool) {
+	h.publishLocal(postID, comment, hidden)
+	if h.cluster == nil {
+		return
+	}
+	data, err := json.Marshal(comment)
+	if err != nil {
+		log.Printf("Ошибка сериализации комментария %s для пересылки узлам кластера: %v", comment.ID, err)
+		return
+	}
+	h.cluster.Forward(cluster.Event{PostID: postID, Hidden: hidden, Comment: data})
+}
+
+// publishLocal сохраняет событие в истории поста и доставляет его подписчикам этого узла
+func (h *subscriptionHandler) publishLocal(postID string, comment *Comment, hidden bool) {
+	event := replayEvent{comment: comment, hidden: hidden, publishedAt: time.Now()}
+	shard := h.shard(postID)
+	shard.mu.Lock()
+	buffer, ok := shard.history[postID]
+	if !ok {
+		buffer = &replayBuffer{}
+		shard.history[postID] = buffer
+	}
+	buffer.add(event)
+	// Пока сводка не разослана, новые события ждут её даже после конца шторма, чтобы не нарушить порядок
+	if h.storm != nil && (h.storm.Observe(postID) || len(shard.digests[postID]) > 0) {
+		h.deferEvent(shard, postID, event)
+		shard.mu.Unlock()
+		return
+	}
+	subscribers := append([]*subscriber(nil), shard.subscribers[postID]...)
+	shard.mu.Unlock()
+	h.deliver(postID, []replayEvent{event}, subscribers)
+}
+
+// deferEvent откладывает событие до сводки; из сводки вытесняются самые старые события сверх digestMax.
+// Вызывается под блокировкой шарда
+func (h *subscriptionHandler) deferEvent(shard *subscriptionShard, postID string, event replayEvent) {
+	pending := append(shard.digests[postID], event)
+	if skipped := len(pending) - h.digestMax; skipped > 0 {
+		metrics.SubscriptionDigestSkipped.Add(float64(skipped))
+		pending = append(pending[:0:0], pending[skipped:]...)
+	}
+	shard.digests[postID] = pending
+}
+
+// flushDigests раз в interval рассылает накопленные сводки и забывает затихшие посты детектора
+func (h *subscriptionHandler) flushDigests(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.storm.Sweep()
+		for _, shard := range h.shards {
+			shard.mu.Lock()
+			digests := shard.digests
+			shard.digests = make(map[string][]replayEvent)
+			subscribers := make(map[string][]*subscriber, len(digests))
+			for postID := range digests {
+				subscribers[postID] = append([]*subscriber(nil), shard.subscribers[postID]...)
+			}
+			shard.mu.Unlock()
+			for postID, events := range digests {
+				metrics.SubscriptionDigests.Inc()
+				log.Printf("Сводка для postID=%s: комментариев %d", postID, len(events))
+				h.deliver(postID, events, subscribers[postID])
+			}
+		}
+	}
+}
+
+// deliver проверяет доступ к посту один раз на все события и ставит их доставку в очереди обработчиков
+func (h *subscriptionHandler) deliver(postID string, events []replayEvent, subscribers []*subscriber) {
+	if len(subscribers) == 0 {
+		log.Printf("Нет подписчиков для postID=%s", postID)
+		return
+	}
+
+	// Доступ проверяется вне блокировки шарда: для этого нужны запросы к хранилищу
+	viewers, tenants := make(map[string]bool), make(map[string]bool)
+	for _, sub := range subscribers {
+		if sub.viewerID == "" {
+			tenants[sub.tenant] = true
+		} else {
+			viewers[sub.viewerID] = true
+		}
+	}
+	access := h.checkAccess(postID, viewers, tenants)
+	groups := make([][]*subscriber, len(h.queues))
+	for _, sub := range subscribers {
+		if !access.allows(sub.viewerID, sub.tenant) {
+			continue
+		}
+		w := sub.id % uint64(len(h.queues))
+		groups[w] = append(groups[w], sub)
+	}
+
+	log.Printf("Отправка уведомления для postID=%s, количество каналов: %d", postID, len(subscribers))
+	for w, group := range groups {
+		if len(group) > 0 {
+			h.queues[w] <- fanoutJob{events: events, subscribers: group}
+		}
+	}
+}
+
+// fanoutWorker доставляет комментарии; подписчик с переполненным буфером отключается от рассылки
+func (h *subscriptionHandler) fanoutWorker(queue <-chan fanoutJob) {
+	// Метка отделяет рассылку подписок от работы резолверов в профилях CPU и горутин
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("component", "subscription_fanout")))
+	for job := range queue {
+		for _, sub := range job.subscribers {
+			for _, event := range job.events {
+				if !sub.receives(event.comment, event.hidden) {
+					continue
+				}
+				if !sub.send(event.comment) {
+					log.Printf("Канал занят для postID=%s, удаление канала", sub.postID)
+					h.unsubscribe(sub)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/cluster"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/ButyrinIA/system/internal/storm"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionFanout(t *testing.T) {
+	t.Run("Order preserved per subscriber", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch, _ := h.CommentAdded(ctx, "post1", nil, nil)
+
+		for i := 0; i < 20; i++ {
+			h.publish("post1", &Comment{ID: strconv.Itoa(i)})
+			select {
+			case c := <-ch:
+				assert.Equal(t, strconv.Itoa(i), c.ID)
+			case <-time.After(time.Second):
+				t.Fatal("Таймаут ожидания комментария")
+			}
+		}
+	})
+
+	t.Run("Other posts are not notified", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch, _ := h.CommentAdded(ctx, "post2", nil, nil)
+
+		h.publish("post1", &Comment{ID: "1"})
+		select {
+		case <-ch:
+			t.Fatal("Комментарий другого поста не должен доставляться")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("Slow subscriber removed", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, _ = h.CommentAdded(ctx, "post1", nil, nil)
+
+		// Буфер подписчика на один элемент: второй комментарий не помещается
+		h.publish("post1", &Comment{ID: "1"})
+		h.publish("post1", &Comment{ID: "2"})
+		assert.Eventually(t, func() bool {
+			shard := h.shard("post1")
+			shard.mu.RLock()
+			defer shard.mu.RUnlock()
+			return len(shard.subscribers["post1"]) == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Unsubscribe on cancel", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, _ := h.CommentAdded(ctx, "post1", nil, nil)
+		cancel()
+
+		select {
+		case _, open := <-ch:
+			assert.False(t, open, "Канал должен быть закрыт")
+		case <-time.After(time.Second):
+			t.Fatal("Таймаут ожидания закрытия канала")
+		}
+		h.publish("post1", &Comment{ID: "1"})
+	})
+	t.Run("Replay after lastEventID", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for i := 1; i <= 3; i++ {
+			h.publish("post1", &Comment{ID: strconv.Itoa(i)})
+		}
+
+		last := "1"
+		ch, err := h.CommentAdded(ctx, "post1", &last, nil)
+		assert.NoError(t, err)
+		h.publish("post1", &Comment{ID: "4"})
+
+		var ids []string
+		for len(ids) < 3 {
+			select {
+			case c := <-ch:
+				ids = append(ids, c.ID)
+			case <-time.After(time.Second):
+				t.Fatal("Таймаут ожидания комментария")
+			}
+		}
+		assert.Equal(t, []string{"2", "3", "4"}, ids, "Пропущенные комментарии должны прийти до новых")
+	})
+
+	t.Run("Replay since timestamp", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		h.publish("post1", &Comment{ID: "old"})
+		since := time.Now().Add(time.Hour).Format(time.RFC3339)
+		ch, err := h.CommentAdded(ctx, "post1", nil, &since)
+		assert.NoError(t, err)
+		select {
+		case <-ch:
+			t.Fatal("Комментарии до sinceTimestamp не должны отправляться")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		since = time.Now().Add(-time.Hour).Format(time.RFC3339)
+		ch, err = h.CommentAdded(ctx, "post1", nil, &since)
+		assert.NoError(t, err)
+		assert.Equal(t, "old", (<-ch).ID)
+
+		bad := "вчера"
+		_, err = h.CommentAdded(ctx, "post1", nil, &bad)
+		assert.Error(t, err)
+	})
+
+	t.Run("Hidden comments delivered only to author", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		author, _ := h.CommentAdded(context.WithValue(ctx, "userID", "banned"), "post1", nil, nil)
+		other, _ := h.CommentAdded(context.WithValue(ctx, "userID", "user1"), "post1", nil, nil)
+
+		h.publishHidden("post1", &Comment{ID: "hidden", AuthorID: "banned"})
+		h.publish("post1", &Comment{ID: "visible", AuthorID: "user1"})
+		assert.Equal(t, "hidden", (<-author).ID)
+		assert.Equal(t, "visible", (<-author).ID)
+		assert.Equal(t, "visible", (<-other).ID, "Скрытый комментарий не должен доставляться другим подписчикам")
+
+		// Повтор истории тоже учитывает видимость
+		since := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		replayed, _ := h.CommentAdded(ctx, "post1", nil, &since)
+		assert.Equal(t, "visible", (<-replayed).ID)
+	})
+
+	t.Run("Replay buffer is bounded", func(t *testing.T) {
+		buffer := &replayBuffer{}
+		for i := 0; i < replayBufferSize+10; i++ {
+			buffer.add(replayEvent{comment: &Comment{ID: strconv.Itoa(i)}, publishedAt: time.Now()})
+		}
+		events := buffer.since(&subscriber{}, nil, nil)
+		assert.Len(t, events, replayBufferSize)
+		assert.Equal(t, "10", events[0].ID)
+		assert.Equal(t, strconv.Itoa(replayBufferSize+9), events[len(events)-1].ID)
+	})
+
+	t.Run("Storm digests", func(t *testing.T) {
+		h := newSubscriptionHandler(nil)
+		// Часы стоят, поэтому шторм не заканчивается до конца теста
+		clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+		h.EnableDigests(storm.New(storm.Options{Scope: "test", Threshold: 2, Clock: clk}), 20*time.Millisecond, 3)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch, _ := h.CommentAdded(ctx, "post1", nil, nil)
+		skipped := testutil.ToFloat64(metrics.SubscriptionDigestSkipped)
+
+		h.publish("post1", &Comment{ID: "1"})
+		assert.Equal(t, "1", (<-ch).ID, "До порога события доставляются сразу")
+		for i := 2; i <= 6; i++ {
+			h.publish("post1", &Comment{ID: strconv.Itoa(i)})
+		}
+		// В сводку попадают три последних комментария, более старые пропускаются
+		for _, want := range []string{"4", "5", "6"} {
+			select {
+			case c := <-ch:
+				assert.Equal(t, want, c.ID)
+			case <-time.After(time.Second):
+				t.Fatal("Таймаут ожидания сводки")
+			}
+		}
+		assert.Equal(t, skipped+2, testutil.ToFloat64(metrics.SubscriptionDigestSkipped))
+	})
+
+	t.Run("Events forwarded to another node", func(t *testing.T) {
+		local, remote := newSubscriptionHandler(nil), newSubscriptionHandler(nil)
+		var remoteNode *cluster.Node
+		peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			event, err := remoteNode.Receive(r)
+			if err == nil {
+				err = remote.ReceiveForwarded(event)
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer peer.Close()
+		localNode, err := cluster.New(cluster.Options{NodeID: "a", Secret: "secret", Peers: []cluster.Peer{{ID: "b", URL: peer.URL}}})
+		require.NoError(t, err)
+		defer localNode.Stop()
+		remoteNode, err = cluster.New(cluster.Options{NodeID: "b", Secret: "secret", Peers: []cluster.Peer{{ID: "a", URL: "http://127.0.0.1:1"}}})
+		require.NoError(t, err)
+		defer remoteNode.Stop()
+		local.EnableForwarding(localNode)
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "userID", "author"))
+		defer cancel()
+		ch, _ := remote.CommentAdded(ctx, "post1", nil, nil)
+		local.publish("post1", &Comment{ID: "1", PostID: "post1", Content: "Привет", Format: ContentFormatPlain})
+		local.publishHidden("post1", &Comment{ID: "2", PostID: "post1", AuthorID: "author", Format: ContentFormatPlain})
+		for _, want := range []string{"1", "2"} {
+			select {
+			case c := <-ch:
+				assert.Equal(t, want, c.ID, "Подписчик другого узла получает события по порядку, включая свои скрытые")
+			case <-time.After(time.Second):
+				t.Fatal("Таймаут ожидания пересланного события")
+			}
+		}
+		id := "1"
+		assert.Len(t, remote.shard("post1").history["post1"].since(&subscriber{viewerID: "author"}, &id, nil), 1, "Пересланное событие попадает в историю узла")
+	})
+}
+
+// BenchmarkFanout10kSubscribers измеряет доставку одного комментария 10k подписчикам популярного поста
+func BenchmarkFanout10kSubscribers(b *testing.B) {
+	const subscribers = 10000
+	h := newSubscriptionHandler(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < subscribers; i++ {
+		ch, _ := h.CommentAdded(ctx, "popular", nil, nil)
+		go func() {
+			for range ch {
+				wg.Done()
+			}
+		}()
+	}
+
+	comment := &Comment{ID: "1", PostID: "popular"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(subscribers)
+		h.publish("popular", comment)
+		wg.Wait()
+	}
+	b.ReportMetric(float64(subscribers*b.N)/b.Elapsed().Seconds(), "deliveries/s")
+}
+
+// BenchmarkFanoutManyPosts измеряет публикацию в параллельные посты из разных шардов
+func BenchmarkFanoutManyPosts(b *testing.B) {
+	const posts = 1000
+	h := newSubscriptionHandler(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < posts*10; i++ {
+		ch, _ := h.CommentAdded(ctx, "post"+strconv.Itoa(i%posts), nil, nil)
+		go func() {
+			for range ch {
+			}
+		}()
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			postID := "post" + strconv.Itoa(i%posts)
+			h.publish(postID, &Comment{ID: strconv.Itoa(i), PostID: postID})
+			i++
+		}
+	})
+}
+
+func TestSubscriptionAccess(t *testing.T) {
+	store := memory.New()
+	h := newSubscriptionHandler(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	post := &models.Post{ID: "post1", Title: "Пост", AuthorID: "author", AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(ctx, post))
+	held := &models.Post{ID: "held", Title: "Задержан", AuthorID: "author", AllowComments: true, CreatedAt: time.Now(), Hidden: true}
+	require.NoError(t, store.CreatePost(ctx, held))
+
+	_, err := h.CommentAdded(ctx, "missing", nil, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = h.UserTyping(ctx, "missing")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = h.CommentAdded(context.WithValue(ctx, "userID", "user1"), "held", nil, nil)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err), "Задержанный пост доступен только автору")
+	_, err = h.CommentAdded(context.WithValue(ctx, "userID", "author"), "held", nil, nil)
+	assert.NoError(t, err)
+
+	forum := context.WithValue(ctx, "tenant", "forum")
+	anonymous, err := h.CommentAdded(forum, "post1", nil, nil)
+	require.NoError(t, err)
+	user, err := h.CommentAdded(context.WithValue(forum, "userID", "user1"), "post1", nil, nil)
+	require.NoError(t, err)
+	h.publish("post1", &Comment{ID: "1"})
+	assert.Equal(t, "1", (<-anonymous).ID)
+	assert.Equal(t, "1", (<-user).ID)
+
+	// Политика сообщества проверяется при каждом событии, а не только при подписке
+	require.NoError(t, store.SaveTenantSettings(ctx, &models.TenantSettings{TenantID: "forum", AnonymousPolicy: models.AnonymousDeny}))
+	h.publish("post1", &Comment{ID: "2"})
+	assert.Equal(t, "2", (<-user).ID)
+	select {
+	case <-anonymous:
+		t.Fatal("Анонимный подписчик не должен получать события после запрета")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
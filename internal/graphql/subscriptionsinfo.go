@@ -0,0 +1,142 @@
+package graphql
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+)
+
+// maxSubscriptionsInfo - наибольшее число записей в каждом списке ответа subscriptionsInfo
+const maxSubscriptionsInfo = 100
+
+// Виды подписок в метриках
+const (
+	subscriptionCommentAdded = "comment_added"
+	subscriptionUserTyping   = "user_typing"
+)
+
+// WebsocketConnection - открытое WebSocket-соединение
+type WebsocketConnection struct {
+	// UserID - пользователь соединения; пустой для анонимного соединения
+	UserID      string
+	Tenant      string
+	ConnectedAt time.Time
+}
+
+// ConnectionRegistry - открытые WebSocket-соединения экземпляра сервера
+type ConnectionRegistry interface {
+	// Connections возвращает открытые соединения
+	Connections() []WebsocketConnection
+	// CloseUser закрывает все соединения пользователя и возвращает их число
+	CloseUser(userID string) int
+}
+
+// subscriptionCounts - количество активных подписок экземпляра сервера
+type subscriptionCounts struct {
+	total    int
+	byPost   map[string]int
+	byTenant map[string]int
+	byUser   map[string]int
+}
+
+// stats подсчитывает активные подписки commentAdded и userTyping по постам, сообществам и пользователям
+func (h *subscriptionHandler) stats() subscriptionCounts {
+	counts := subscriptionCounts{byPost: map[string]int{}, byTenant: map[string]int{}, byUser: map[string]int{}}
+	add := func(postID, tenant, viewerID string) {
+		counts.total++
+		counts.byPost[postID]++
+		counts.byTenant[tenant]++
+		counts.byUser[viewerID]++
+	}
+	for _, shard := range h.shards {
+		shard.mu.RLock()
+		for postID, subscribers := range shard.subscribers {
+			for _, sub := range subscribers {
+				add(postID, sub.tenant, sub.viewerID)
+			}
+		}
+		for postID, subscribers := range shard.typists {
+			for _, sub := range subscribers {
+				add(postID, sub.tenant, sub.viewerID)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return counts
+}
+
+// SubscriptionsInfo реализует запрос subscriptionsInfo
+func (r *queryResolver) SubscriptionsInfo(ctx context.Context, limit *int) (*SubscriptionsInfo, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	n := 20
+	if limit != nil {
+		n = *limit
+	}
+	if n < 0 || n > maxSubscriptionsInfo {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "limit must be between 0 and %d", maxSubscriptionsInfo)
+	}
+	counts := r.SubscriptionHandler.stats()
+	info := &SubscriptionsInfo{
+		Subscriptions:     counts.total,
+		ByPost:            topSubscriptionCounts(counts.byPost, n),
+		ByTenant:          topSubscriptionCounts(counts.byTenant, n),
+		ByUser:            topSubscriptionCounts(counts.byUser, n),
+		OldestConnections: []*SubscriptionConnection{},
+	}
+	if r.Connections == nil {
+		return info, nil
+	}
+	connections := r.Connections.Connections()
+	info.Connections = len(connections)
+	slices.SortFunc(connections, func(a, b WebsocketConnection) int {
+		return a.ConnectedAt.Compare(b.ConnectedAt)
+	})
+	now := r.Clock.Now()
+	for _, conn := range connections[:min(n, len(connections))] {
+		result := &SubscriptionConnection{
+			Tenant:      conn.Tenant,
+			ConnectedAt: conn.ConnectedAt.Format(time.RFC3339),
+			AgeSeconds:  int(now.Sub(conn.ConnectedAt) / time.Second),
+		}
+		if conn.UserID != "" {
+			result.UserID = &conn.UserID
+		}
+		info.OldestConnections = append(info.OldestConnections, result)
+	}
+	return info, nil
+}
+
+// topSubscriptionCounts возвращает до limit записей по убыванию количества, при равенстве - по ключу
+func topSubscriptionCounts(counts map[string]int, limit int) []*SubscriptionCount {
+	result := make([]*SubscriptionCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, &SubscriptionCount{Key: key, Count: count})
+	}
+	slices.SortFunc(result, func(a, b *SubscriptionCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	return result[:min(limit, len(result))]
+}
+
+// CloseUserSubscriptions реализует мутацию closeUserSubscriptions
+func (r *mutationResolver) CloseUserSubscriptions(ctx context.Context, userID string) (int, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return 0, err
+	}
+	if userID == "" {
+		return 0, gqlerrors.New(gqlerrors.CodeBadUserInput, "userId is required")
+	}
+	if r.Connections == nil {
+		return 0, nil
+	}
+	adminID, _ := ctx.Value("userID").(string)
+	closed := r.Connections.CloseUser(userID)
+	log.Printf("Администратор %s закрыл WebSocket-соединения пользователя %s: %d", adminID, userID, closed)
+	return closed, nil
+}
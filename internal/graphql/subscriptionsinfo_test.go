@@ -0,0 +1,93 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConnections - ConnectionRegistry с заранее заданными соединениями
+type fakeConnections struct {
+	conns  []WebsocketConnection
+	closed []string
+}
+
+func (f *fakeConnections) Connections() []WebsocketConnection {
+	return append([]WebsocketConnection(nil), f.conns...)
+}
+
+func (f *fakeConnections) CloseUser(userID string) int {
+	f.closed = append(f.closed, userID)
+	closed := 0
+	for _, conn := range f.conns {
+		if conn.UserID == userID {
+			closed++
+		}
+	}
+	return closed
+}
+
+func TestSubscriptionsInfo(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	resolver := NewResolver(nil, nil)
+	resolver.Clock = clock.NewFake(now)
+	connections := &fakeConnections{conns: []WebsocketConnection{
+		{UserID: "user1", Tenant: "acme", ConnectedAt: now.Add(-time.Minute)},
+		{UserID: "", Tenant: "acme", ConnectedAt: now.Add(-time.Hour)},
+		{UserID: "user2", Tenant: "other", ConnectedAt: now.Add(-time.Second)},
+	}}
+	resolver.Connections = connections
+	user := userContext("user1", "")
+	admin := userContext("admin1", roleAdmin)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscribe := func(userID, tenant, postID string) {
+		subCtx := context.WithValue(context.WithValue(ctx, "userID", userID), "tenant", tenant)
+		_, err := resolver.SubscriptionHandler.CommentAdded(subCtx, postID, nil, nil)
+		require.NoError(t, err)
+	}
+	subscribe("user1", "acme", "post1")
+	subscribe("user2", "other", "post1")
+	subscribe("", "acme", "post2")
+	_, err := resolver.SubscriptionHandler.UserTyping(context.WithValue(context.WithValue(ctx, "userID", "user1"), "tenant", "acme"), "post1")
+	require.NoError(t, err)
+
+	_, err = resolver.Query().SubscriptionsInfo(user, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	tooMany := maxSubscriptionsInfo + 1
+	_, err = resolver.Query().SubscriptionsInfo(admin, &tooMany)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	limit := 2
+	info, err := resolver.Query().SubscriptionsInfo(admin, &limit)
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.Connections)
+	assert.Equal(t, 4, info.Subscriptions)
+	assert.Equal(t, []*SubscriptionCount{{Key: "post1", Count: 3}, {Key: "post2", Count: 1}}, info.ByPost)
+	assert.Equal(t, []*SubscriptionCount{{Key: "acme", Count: 3}, {Key: "other", Count: 1}}, info.ByTenant)
+	assert.Equal(t, []*SubscriptionCount{{Key: "user1", Count: 2}, {Key: "", Count: 1}}, info.ByUser)
+	require.Len(t, info.OldestConnections, 2)
+	assert.Nil(t, info.OldestConnections[0].UserID, "Анонимное соединение без пользователя")
+	assert.Equal(t, 3600, info.OldestConnections[0].AgeSeconds)
+	assert.Equal(t, "user1", *info.OldestConnections[1].UserID)
+	assert.Equal(t, now.Add(-time.Minute).Format(time.RFC3339), info.OldestConnections[1].ConnectedAt)
+
+	// Подписки удаляются из учёта после завершения
+	cancel()
+	assert.Eventually(t, func() bool {
+		info, err := resolver.Query().SubscriptionsInfo(admin, nil)
+		return err == nil && info.Subscriptions == 0
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = resolver.Mutation().CloseUserSubscriptions(user, "user2")
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	closed, err := resolver.Mutation().CloseUserSubscriptions(admin, "user2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, closed)
+	assert.Equal(t, []string{"user2"}, connections.closed)
+}
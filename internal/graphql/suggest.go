@@ -0,0 +1,40 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+)
+
+// Suggest реализует запрос suggest
+func (r *queryResolver) Suggest(ctx context.Context, prefix string, kind *SuggestionKind, limit *int) ([]*Suggestion, error) {
+	k, n := SuggestionKindTitle, 5
+	if kind != nil {
+		k = *kind
+	}
+	if limit != nil {
+		n = *limit
+	}
+	log.Printf("Запрос подсказок: prefix=%q, kind=%s, limit=%d", prefix, k, n)
+	if n < 1 {
+		return nil, gqlerrors.New(gqlerrors.CodeBadUserInput, "limit must be positive")
+	}
+	if r.Suggestions == nil {
+		return nil, gqlerrors.New(gqlerrors.CodeInternal, "suggestions are not configured")
+	}
+	suggestions, err := r.Suggestions.Suggest(ctx, prefix, string(k), n)
+	if err != nil {
+		log.Printf("Ошибка при получении подсказок для %q: %v", prefix, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to load suggestions: %v", err)
+	}
+	result := make([]*Suggestion, len(suggestions))
+	for i, s := range suggestions {
+		result[i] = &Suggestion{Text: s.Text, Kind: SuggestionKind(s.Kind), Count: s.Count}
+		if s.PostID != "" {
+			postID := s.PostID
+			result[i].PostID = &postID
+		}
+	}
+	return result, nil
+}
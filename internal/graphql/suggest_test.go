@@ -0,0 +1,38 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/ButyrinIA/system/internal/suggest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggest(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	author := userContext("user2", "")
+	post, err := resolver.Mutation().CreatePost(author, "Запуск ракеты", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Query().Suggest(author, "зап", nil, nil)
+	assert.Equal(t, gqlerrors.CodeInternal, gqlerrors.Code(err), "Без сервиса подсказок запрос завершается ошибкой")
+
+	resolver.Suggestions = suggest.New(store, suggest.Options{})
+	suggestions, err := resolver.Query().Suggest(author, "ракет", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, &Suggestion{Text: "Запуск ракеты", Kind: SuggestionKindTitle, Count: 1, PostID: &post.ID}, suggestions[0])
+
+	kind := SuggestionKindAuthor
+	suggestions, err = resolver.Query().Suggest(author, "user", &kind, nil)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, &Suggestion{Text: "user2", Kind: SuggestionKindAuthor, Count: 1}, suggestions[0])
+
+	limit := 0
+	_, err = resolver.Query().Suggest(author, "зап", nil, &limit)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
@@ -0,0 +1,239 @@
+package graphql
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/quota"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const (
+	// maxNestingLimit - наибольшее значение maxNesting в настройках сообщества
+	maxNestingLimit = 100
+	// maxBrandingLength - наибольшая длина строк оформления в символах
+	maxBrandingLength = 200
+)
+
+// primaryColorPattern - цвет оформления в виде #rrggbb
+var primaryColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// tenantFromContext возвращает сообщество запроса, а без него - models.DefaultTenant
+func tenantFromContext(ctx context.Context) string {
+	if tenantID, _ := ctx.Value("tenant").(string); tenantID != "" {
+		return tenantID
+	}
+	return models.DefaultTenant
+}
+
+// tenantSettings возвращает настройки сообщества запроса. Ошибка чтения не ломает ответ:
+// используются настройки по умолчанию
+func tenantSettings(ctx context.Context, store storage.Storage) *models.TenantSettings {
+	tenantID := tenantFromContext(ctx)
+	settings, err := store.GetTenantSettings(ctx, tenantID)
+	if err != nil {
+		log.Printf("Ошибка при получении настроек сообщества %s, используются настройки по умолчанию: %v", tenantID, err)
+		return models.DefaultTenantSettings(tenantID)
+	}
+	return settings
+}
+
+// TenantSettings реализует запрос tenantSettings
+func (r *queryResolver) TenantSettings(ctx context.Context) (*TenantSettings, error) {
+	tenantID := tenantFromContext(ctx)
+	settings, err := r.Storage.GetTenantSettings(ctx, tenantID)
+	if err != nil {
+		log.Printf("Ошибка при получении настроек сообщества %s: %v", tenantID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get tenant settings: %v", err)
+	}
+	return toTenantSettings(settings), nil
+}
+
+// TenantUsage реализует запрос tenantUsage
+func (r *queryResolver) TenantUsage(ctx context.Context, tenantID *string) (*TenantUsage, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	tenant := tenantFromContext(ctx)
+	if tenantID != nil {
+		tenant = *tenantID
+	}
+	usage, err := r.Storage.GetTenantUsage(ctx, tenant)
+	if err != nil {
+		log.Printf("Ошибка при получении использования сообщества %s: %v", tenant, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get tenant usage: %v", err)
+	}
+	var limits quota.TenantLimits
+	if r.TenantQuotas != nil {
+		limits = r.TenantQuotas.Limits(tenant)
+	}
+	return &TenantUsage{
+		TenantID:    tenant,
+		Posts:       int(usage.Posts),
+		Comments:    int(usage.Comments),
+		Bytes:       int(usage.Bytes),
+		MaxPosts:    int(limits.Posts),
+		MaxComments: int(limits.Comments),
+		MaxBytes:    int(limits.Bytes),
+	}, nil
+}
+
+// UpdateTenantSettings реализует мутацию updateTenantSettings
+func (r *mutationResolver) UpdateTenantSettings(ctx context.Context, input TenantSettingsInput) (*TenantSettings, error) {
+	tenantID := tenantFromContext(ctx)
+	log.Printf("Запуск мутации updateTenantSettings для сообщества %s: %+v", tenantID, input)
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := validateTenantSettings(input); err != nil {
+		return nil, err
+	}
+	settings, err := r.Storage.GetTenantSettings(ctx, tenantID)
+	if err != nil {
+		log.Printf("Ошибка при получении настроек сообщества %s: %v", tenantID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get tenant settings: %v", err)
+	}
+	if input.DefaultCommentSort != nil {
+		settings.DefaultCommentSort = models.SortOrder(*input.DefaultCommentSort)
+	}
+	if input.MaxNesting != nil {
+		settings.MaxNesting = *input.MaxNesting
+	}
+	if input.AnonymousPolicy != nil {
+		settings.AnonymousPolicy = string(*input.AnonymousPolicy)
+	}
+	if input.SiteName != nil {
+		settings.SiteName = strings.TrimSpace(*input.SiteName)
+	}
+	if input.Tagline != nil {
+		settings.Tagline = strings.TrimSpace(*input.Tagline)
+	}
+	if input.LogoURL != nil {
+		settings.LogoURL = strings.TrimSpace(*input.LogoURL)
+	}
+	if input.PrimaryColor != nil {
+		settings.PrimaryColor = strings.ToLower(*input.PrimaryColor)
+	}
+	settings.UpdatedAt = r.Clock.Now()
+	if err := r.Storage.SaveTenantSettings(ctx, settings); err != nil {
+		log.Printf("Ошибка при сохранении настроек сообщества %s: %v", tenantID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to save tenant settings: %v", err)
+	}
+	log.Printf("Настройки сообщества %s обновлены", tenantID)
+	return toTenantSettings(settings), nil
+}
+
+// validateTenantSettings проверяет заданные поля настроек сообщества
+func validateTenantSettings(input TenantSettingsInput) error {
+	if input.DefaultCommentSort != nil && !input.DefaultCommentSort.IsValid() {
+		return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid comment sort %s", *input.DefaultCommentSort)
+	}
+	if input.AnonymousPolicy != nil && !input.AnonymousPolicy.IsValid() {
+		return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid anonymous policy %s", *input.AnonymousPolicy)
+	}
+	if input.MaxNesting != nil && (*input.MaxNesting < 0 || *input.MaxNesting > maxNestingLimit) {
+		return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "maxNesting must be between 0 and %d", maxNestingLimit)
+	}
+	for name, value := range map[string]*string{"siteName": input.SiteName, "tagline": input.Tagline, "logoUrl": input.LogoURL} {
+		if value != nil && utf8.RuneCountInString(*value) > maxBrandingLength {
+			return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "%s exceeds %d characters", name, maxBrandingLength)
+		}
+	}
+	if input.LogoURL != nil {
+		if logo := strings.TrimSpace(*input.LogoURL); logo != "" {
+			parsed, err := url.Parse(logo)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid logo URL %q", logo)
+			}
+		}
+	}
+	if input.PrimaryColor != nil && *input.PrimaryColor != "" && !primaryColorPattern.MatchString(*input.PrimaryColor) {
+		return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid primary color %q", *input.PrimaryColor)
+	}
+	return nil
+}
+
+func toTenantSettings(settings *models.TenantSettings) *TenantSettings {
+	return &TenantSettings{
+		TenantID:           settings.TenantID,
+		DefaultCommentSort: SortOrder(settings.DefaultCommentSort),
+		MaxNesting:         settings.MaxNesting,
+		AnonymousPolicy:    AnonymousPolicy(settings.AnonymousPolicy),
+		SiteName:           settings.SiteName,
+		Tagline:            settings.Tagline,
+		LogoURL:            settings.LogoURL,
+		PrimaryColor:       settings.PrimaryColor,
+	}
+}
+
+// checkNesting отклоняет ответ на parentID, если он окажется глубже maxNesting из настроек сообщества
+func (r *mutationResolver) checkNesting(ctx context.Context, parentID string) error {
+	maxNesting := tenantSettings(ctx, r.Storage).MaxNesting
+	if maxNesting <= 0 {
+		return nil
+	}
+	// Глубина ответа - число его предков плюс один; предков достаточно пройти до maxNesting
+	depth := 1
+	for id := &parentID; id != nil; depth++ {
+		if depth >= maxNesting {
+			log.Printf("Ошибка: ответ на комментарий %s глубже %d уровней", parentID, maxNesting)
+			return gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "comments cannot be nested deeper than %d levels", maxNesting)
+		}
+		parent, err := r.Storage.GetComment(ctx, *id)
+		if err != nil {
+			log.Printf("Ошибка при получении комментария с ID=%s: %v", *id, err)
+			return commentError("failed to create comment", err)
+		}
+		id = parent.ParentID
+	}
+	return nil
+}
+
+// AnonymousAccess применяет политику anonymousPolicy сообщества к запросам без авторизации.
+// Подключается к GraphQL-серверу после аутентификации
+type AnonymousAccess struct {
+	Storage storage.Storage
+}
+
+var _ interface {
+	graphql.RootFieldInterceptor
+	graphql.HandlerExtension
+} = AnonymousAccess{}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (a AnonymousAccess) ExtensionName() string {
+	return "AnonymousAccess"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (a AnonymousAccess) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptRootField реализует graphql.RootFieldInterceptor: READ_ONLY отклоняет мутации,
+// DENY - все корневые поля, кроме tenantSettings и интроспекции
+func (a AnonymousAccess) InterceptRootField(ctx context.Context, next graphql.RootResolver) graphql.Marshaler {
+	if userID, _ := ctx.Value("userID").(string); userID != "" {
+		return next(ctx)
+	}
+	operation := graphql.GetOperationContext(ctx).Operation.Operation
+	name := graphql.GetRootFieldContext(ctx).Field.Name
+	if operation == ast.Query && (name == "tenantSettings" || strings.HasPrefix(name, "__")) {
+		return next(ctx)
+	}
+	policy := tenantSettings(ctx, a.Storage).AnonymousPolicy
+	if policy == models.AnonymousDeny || (policy == models.AnonymousReadOnly && operation == ast.Mutation) {
+		log.Printf("Поле %s отклонено политикой %s сообщества %s", name, policy, tenantFromContext(ctx))
+		graphql.AddError(ctx, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required"))
+		return graphql.Null
+	}
+	return next(ctx)
+}
@@ -0,0 +1,173 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/quota"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantContext - контекст запроса пользователя к сообществу tenantID
+func tenantContext(tenantID, userID, role string) context.Context {
+	return context.WithValue(userContext(userID, role), "tenant", tenantID)
+}
+
+func TestTenantSettings(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	admin := tenantContext("forum", "admin1", roleAdmin)
+
+	settings, err := resolver.Query().TenantSettings(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &TenantSettings{TenantID: models.DefaultTenant, DefaultCommentSort: SortOrderDesc, AnonymousPolicy: AnonymousPolicyAllow}, settings)
+
+	nesting := 2
+	_, err = resolver.Mutation().UpdateTenantSettings(tenantContext("forum", "user1", ""), TenantSettingsInput{MaxNesting: &nesting})
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	for _, input := range []TenantSettingsInput{
+		{MaxNesting: func() *int { n := -1; return &n }()},
+		{LogoURL: func() *string { s := "javascript:alert(1)"; return &s }()},
+		{PrimaryColor: func() *string { s := "red"; return &s }()},
+	} {
+		_, err = resolver.Mutation().UpdateTenantSettings(admin, input)
+		assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err), "%+v", input)
+	}
+
+	best := SortOrderBest
+	name := " Форум "
+	color := "#3366CC"
+	_, err = resolver.Mutation().UpdateTenantSettings(admin, TenantSettingsInput{DefaultCommentSort: &best, SiteName: &name, PrimaryColor: &color})
+	require.NoError(t, err)
+	settings, err = resolver.Mutation().UpdateTenantSettings(admin, TenantSettingsInput{MaxNesting: &nesting})
+	require.NoError(t, err)
+	assert.Equal(t, &TenantSettings{TenantID: "forum", DefaultCommentSort: SortOrderBest, MaxNesting: 2, AnonymousPolicy: AnonymousPolicyAllow, SiteName: "Форум", PrimaryColor: "#3366cc"}, settings, "Незаданные поля не меняются")
+
+	settings, err = resolver.Query().TenantSettings(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SortOrderDesc, settings.DefaultCommentSort, "Настройки хранятся отдельно для каждого сообщества")
+}
+
+func TestTenantSettings_CommentSortAndNesting(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	admin := tenantContext("forum", "admin1", roleAdmin)
+	best := SortOrderBest
+	nesting := 2
+	_, err := resolver.Mutation().UpdateTenantSettings(admin, TenantSettingsInput{DefaultCommentSort: &best, MaxNesting: &nesting})
+	require.NoError(t, err)
+
+	anonymous := tenantContext("forum", "", "")
+	user := tenantContext("forum", "user1", "")
+	assert.Equal(t, models.SortBest, resolver.commentOrder(anonymous, nil), "Без авторизации - порядок сообщества")
+	assert.Equal(t, models.SortBest, resolver.commentOrder(user, nil), "Пользователь без своих настроек получает порядок сообщества")
+	prefs, err := resolver.User().Preferences(user, &User{ID: "user1"})
+	require.NoError(t, err)
+	assert.Equal(t, SortOrderBest, prefs.DefaultCommentSort)
+	assert.Equal(t, models.SortDesc, resolver.commentOrder(userContext("user1", ""), nil), "В другом сообществе свой порядок")
+
+	asc := SortOrderAsc
+	_, err = resolver.Mutation().UpdatePreferences(user, PreferencesInput{DefaultCommentSort: &asc})
+	require.NoError(t, err)
+	assert.Equal(t, models.SortAsc, resolver.commentOrder(user, nil), "Выбор пользователя важнее настроек сообщества")
+
+	post, err := resolver.Mutation().CreatePost(user, "Пост", "Текст", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	root, err := resolver.Mutation().CreateComment(user, post.ID, nil, "Корень", nil, nil, nil)
+	require.NoError(t, err)
+	reply, err := resolver.Mutation().CreateComment(user, post.ID, &root.ID, "Ответ", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().CreateComment(user, post.ID, &reply.ID, "Слишком глубоко", nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+	_, err = resolver.Mutation().CreateComment(userContext("user1", ""), post.ID, &reply.ID, "В сообществе без ограничения", nil, nil, nil)
+	assert.NoError(t, err)
+}
+
+func TestAnonymousAccess(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	policy := func(tenantID string, value AnonymousPolicy) {
+		_, err := resolver.Mutation().UpdateTenantSettings(tenantContext(tenantID, "admin1", roleAdmin), TenantSettingsInput{AnonymousPolicy: &value})
+		require.NoError(t, err)
+	}
+	policy("readonly", AnonymousPolicyReadOnly)
+	policy("closed", AnonymousPolicyDeny)
+
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: resolver, Directives: Directives()}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(gqlerrors.Presenter)
+	srv.Use(AnonymousAccess{Storage: store})
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		headers := graphql.GetOperationContext(ctx).Headers
+		ctx = context.WithValue(ctx, "tenant", headers.Get("X-Tenant-ID"))
+		if userID := headers.Get("X-User"); userID != "" {
+			ctx = context.WithValue(ctx, "userID", userID)
+		}
+		return next(ctx)
+	})
+	c := client.New(srv)
+	execute := func(tenantID, userID, query string) error {
+		var resp map[string]any
+		return c.Post(query, &resp, client.AddHeader("X-Tenant-ID", tenantID), client.AddHeader("X-User", userID))
+	}
+	const (
+		read   = `{ posts(limit: 1) { totalCount } }`
+		write  = `mutation { createPost(title: "Пост", content: "Текст", allowComments: true) { id } }`
+		public = `{ tenantSettings { siteName } }`
+	)
+
+	assert.NoError(t, execute("", "", read))
+	assert.NoError(t, execute("", "", write), "По умолчанию анонимные пользователи могут публиковать")
+	assert.NoError(t, execute("readonly", "", read))
+	assert.ErrorContains(t, execute("readonly", "", write), gqlerrors.CodeUnauthenticated)
+	assert.NoError(t, execute("readonly", "user1", write))
+	assert.ErrorContains(t, execute("closed", "", read), gqlerrors.CodeUnauthenticated)
+	assert.NoError(t, execute("closed", "", public), "Оформление доступно и закрытому сообществу")
+	assert.NoError(t, execute("closed", "user1", read))
+}
+
+func TestTenantUsage(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	resolver.TenantQuotas = quota.NewTenant(store, quota.TenantOptions{
+		Default: quota.TenantLimits{Comments: 1},
+		Tenants: map[string]quota.TenantLimits{"large": {}},
+	})
+	user := tenantContext("forum", "user1", "")
+	admin := tenantContext("forum", "admin1", roleAdmin)
+
+	post, err := resolver.Mutation().CreatePost(user, "Пост", "Текст", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().CreateComment(user, post.ID, nil, "Первый", nil, nil, nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().CreateComment(user, post.ID, nil, "Второй", nil, nil, nil)
+	assert.Equal(t, gqlerrors.CodeQuotaExceeded, gqlerrors.Code(err))
+	_, err = resolver.Mutation().CreateComment(tenantContext("large", "user1", ""), post.ID, nil, "Второй", nil, nil, nil)
+	assert.NoError(t, err, "Квота ведётся отдельно для каждого сообщества")
+
+	_, err = resolver.Query().TenantUsage(user, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	usage, err := resolver.Query().TenantUsage(admin, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &TenantUsage{TenantID: "forum", Posts: 1, Comments: 1, Bytes: contentSize("Пост", "Текст", "Первый"), MaxComments: 1}, usage)
+	large := "large"
+	usage, err = resolver.Query().TenantUsage(admin, &large)
+	require.NoError(t, err)
+	assert.Equal(t, 1, usage.Comments)
+	assert.Zero(t, usage.MaxComments)
+}
+
+// contentSize возвращает суммарный размер строк в байтах
+func contentSize(values ...string) int {
+	size := 0
+	for _, value := range values {
+		size += len(value)
+	}
+	return size
+}
@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// ToxicityScore реализует поле toxicityScore в Comment; оценка видна только модераторам
+func (r *commentResolver) ToxicityScore(ctx context.Context, obj *Comment) (*float64, error) {
+	if role, _ := ctx.Value("role").(string); role != roleModerator {
+		return nil, nil
+	}
+	scores, err := r.Storage.GetToxicityScores(ctx, []string{obj.ID})
+	if err != nil {
+		log.Printf("Ошибка при получении оценки токсичности комментария %s: %v", obj.ID, err)
+		return nil, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to get toxicity score: %v", err)
+	}
+	score, ok := scores[obj.ID]
+	if !ok {
+		return nil, nil
+	}
+	return &score.Score, nil
+}
+
+// RescoreComments реализует мутацию rescoreComments
+func (r *mutationResolver) RescoreComments(ctx context.Context, postID string) (int, error) {
+	log.Printf("Запуск мутации rescoreComments: postID=%s", postID)
+	if err := requireModerator(ctx); err != nil {
+		return 0, err
+	}
+	if r.Toxicity == nil {
+		return 0, gqlerrors.New(gqlerrors.CodeInternal, "toxicity scoring is not configured")
+	}
+	if _, err := r.Storage.GetPost(ctx, postID); err != nil {
+		log.Printf("Ошибка при получении поста %s: %v", postID, err)
+		if errors.Is(err, storage.ErrPostNotFound) {
+			return 0, gqlerrors.Errorf(gqlerrors.CodeNotFound, "failed to rescore comments: %v", err)
+		}
+		return 0, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to rescore comments: %v", err)
+	}
+	queued, err := r.Toxicity.Rescore(ctx, postID)
+	if err != nil {
+		log.Printf("Ошибка постановки комментариев поста %s на оценку токсичности: %v", postID, err)
+		return queued, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to rescore comments: %v", err)
+	}
+	return queued, nil
+}
+
+// fillToxicityScores заполняет toxicityScore задержанных комментариев одним запросом к хранилищу.
+// Ошибка не мешает показать очередь проверки без оценок
+func (r *queryResolver) fillToxicityScores(ctx context.Context, items []*HeldContent) {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Comment != nil {
+			ids = append(ids, item.Comment.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	scores, err := r.Storage.GetToxicityScores(ctx, ids)
+	if err != nil {
+		log.Printf("Ошибка при получении оценок токсичности очереди проверки: %v", err)
+		return
+	}
+	for _, item := range items {
+		if item.Comment == nil {
+			continue
+		}
+		if score, ok := scores[item.Comment.ID]; ok {
+			item.ToxicityScore = &score.Score
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package graphql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/ButyrinIA/system/internal/toxicity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keywordScorer оценивает токсичным текст со словом «дурак»
+type keywordScorer struct{}
+
+func (keywordScorer) Score(ctx context.Context, text, language string) (float64, error) {
+	if strings.Contains(text, "дурак") {
+		return 0.97, nil
+	}
+	return 0.02, nil
+}
+
+func TestToxicity(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	service := toxicity.New(keywordScorer{}, store, toxicity.Options{Clock: resolver.Clock, IDs: resolver.IDs})
+	resolver.Toxicity = service
+	author := userContext("author", "")
+	mod := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	calm, err := mutation.CreateComment(author, post.ID, nil, "Хорошая статья", nil, nil, nil)
+	require.NoError(t, err)
+	toxic, err := mutation.CreateComment(author, post.ID, nil, "Автор дурак", nil, nil, nil)
+	require.NoError(t, err)
+	// Close дожидается оценки поставленных в очередь комментариев
+	service.Close()
+
+	held, err := resolver.Query().HeldContent(mod, 10)
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	assert.Equal(t, models.ToxicityRuleID, held[0].RuleID)
+	require.NotNil(t, held[0].Comment)
+	assert.Equal(t, toxic.ID, held[0].Comment.ID)
+	require.NotNil(t, held[0].ToxicityScore)
+	assert.InDelta(t, 0.97, *held[0].ToxicityScore, 1e-9)
+
+	score, err := resolver.Comment().ToxicityScore(mod, calm)
+	require.NoError(t, err)
+	require.NotNil(t, score)
+	assert.InDelta(t, 0.02, *score, 1e-9)
+	score, err = resolver.Comment().ToxicityScore(author, calm)
+	require.NoError(t, err)
+	assert.Nil(t, score, "Оценка видна только модераторам")
+
+	visible, err := store.GetComments(viewerContext(userContext("reader", "")), post.ID, nil, 10, nil, "")
+	require.NoError(t, err)
+	require.Len(t, visible.Comments, 1, "Токсичный комментарий скрыт до решения модератора")
+	assert.Equal(t, calm.ID, visible.Comments[0].ID)
+
+	resolver.Toxicity = toxicity.New(keywordScorer{}, store, toxicity.Options{})
+	defer resolver.Toxicity.Close()
+	queued, err := mutation.RescoreComments(mod, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+	_, err = mutation.RescoreComments(author, post.ID)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+	_, err = mutation.RescoreComments(mod, "00000000-0000-0000-0000-000000000000")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+}
@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/language"
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// ContentTranslated реализует поле contentTranslated в Post
+func (r *postResolver) ContentTranslated(ctx context.Context, obj *Post, lang *string) (string, error) {
+	return r.translateContent(ctx, obj.Content, obj.Language, lang)
+}
+
+// ContentTranslated реализует поле contentTranslated в Comment
+func (r *commentResolver) ContentTranslated(ctx context.Context, obj *Comment, lang *string) (string, error) {
+	return r.translateContent(ctx, obj.Content, obj.Language, lang)
+}
+
+// translateContent переводит content с языка source на lang, а без lang - на язык из настроек текущего пользователя
+func (r *Resolver) translateContent(ctx context.Context, content string, source *string, lang *string) (string, error) {
+	target, err := r.targetLanguage(ctx, lang)
+	if err != nil {
+		return "", err
+	}
+	var from string
+	if source != nil {
+		from = *source
+	}
+	if from == target {
+		return content, nil
+	}
+	if r.Translations == nil {
+		return "", gqlerrors.New(gqlerrors.CodeInternal, "translation is not configured")
+	}
+	translated, err := r.Translations.Translate(ctx, content, from, target)
+	if err != nil {
+		log.Printf("Ошибка перевода содержимого с %q на %s: %v", from, target, err)
+		return "", gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to translate content: %v", err)
+	}
+	return translated, nil
+}
+
+// targetLanguage возвращает язык перевода из аргумента, а без него - язык интерфейса текущего пользователя.
+// Ошибка чтения настроек не ломает ответ: используется язык по умолчанию
+func (r *Resolver) targetLanguage(ctx context.Context, lang *string) (string, error) {
+	if lang != nil {
+		target, ok := language.Normalize(*lang)
+		if !ok {
+			return "", gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid language %q", *lang)
+		}
+		return target, nil
+	}
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		return models.DefaultPreferences("").Locale, nil
+	}
+	prefs, err := r.Storage.GetPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка при получении настроек пользователя %s, используется язык по умолчанию: %v", userID, err)
+		return models.DefaultPreferences(userID).Locale, nil
+	}
+	return prefs.Locale, nil
+}
+
+// contentLanguage возвращает язык нового поста или комментария: указанный автором или определённый по text
+func contentLanguage(lang *string, text string) (string, error) {
+	if lang == nil {
+		return language.Detect(text), nil
+	}
+	code, ok := language.Normalize(*lang)
+	if !ok {
+		return "", gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "invalid language %q", *lang)
+	}
+	return code, nil
+}
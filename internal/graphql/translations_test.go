@@ -0,0 +1,79 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/ButyrinIA/system/internal/translate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixTranslator помечает текст языком перевода
+type prefixTranslator struct {
+	calls int
+}
+
+func (p *prefixTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	p.calls++
+	return "[" + source + "->" + target + "] " + text, nil
+}
+
+func TestContentLanguageAndTranslation(t *testing.T) {
+	store := memory.New()
+	resolver := NewResolver(store, nil)
+	user := userContext("user1", "")
+	mutation := resolver.Mutation()
+
+	detected, err := mutation.CreatePost(user, "Release notes", "This is the list of changes in the new release", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, detected.Language)
+	assert.Equal(t, "en", *detected.Language, "Язык определяется по тексту")
+	provided := "pt-BR"
+	explicit, err := mutation.CreatePost(user, "Notas", "Texto", true, nil, nil, &provided, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pt", *explicit.Language, "Указанный язык приводится к основному подтегу")
+	unknown, err := mutation.CreatePost(user, "Пост", "Коротко", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, unknown.Language)
+	invalid := "english"
+	_, err = mutation.CreatePost(user, "Пост", "Содержимое", true, nil, nil, &invalid, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	comment, err := mutation.CreateComment(user, detected.ID, nil, "Спасибо, очень подробный список изменений", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ru", *comment.Language)
+
+	english := "EN"
+	page, err := resolver.Query().Posts(user, 10, nil, nil, nil, nil, &PostFilterInput{Language: &english}, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 1)
+	assert.Equal(t, detected.ID, page.Posts[0].ID)
+	_, err = resolver.Query().Posts(user, 10, nil, nil, nil, nil, &PostFilterInput{Language: &invalid}, nil)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+
+	_, err = resolver.Post().ContentTranslated(user, detected, nil)
+	assert.Equal(t, gqlerrors.CodeInternal, gqlerrors.Code(err), "Без сервиса перевода поле недоступно")
+	original, err := resolver.Comment().ContentTranslated(user, comment, nil)
+	require.NoError(t, err)
+	assert.Equal(t, comment.Content, original, "Содержимое на языке пользователя не переводится")
+
+	translator := &prefixTranslator{}
+	resolver.Translations = translate.New(translator, translate.Options{})
+	translated, err := resolver.Post().ContentTranslated(user, detected, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[en->ru] "+detected.Content, translated, "Без lang - язык из настроек пользователя")
+	require.NoError(t, store.SavePreferences(context.Background(), &models.Preferences{UserID: "user1", Locale: "en"}))
+	translated, err = resolver.Comment().ContentTranslated(user, comment, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[ru->en] "+comment.Content, translated)
+	german := "de"
+	_, err = resolver.Post().ContentTranslated(user, detected, &german)
+	require.NoError(t, err)
+	_, err = resolver.Post().ContentTranslated(user, detected, &german)
+	require.NoError(t, err)
+	assert.Equal(t, 3, translator.calls, "Повторный перевод берётся из кеша")
+}
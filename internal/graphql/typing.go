@@ -0,0 +1,182 @@
+package graphql
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+const (
+	// typingSignalInterval - минимальный интервал между сигналами набора одного пользователя в одном посте
+	typingSignalInterval = 3 * time.Second
+	// typingBufferSize - размер буфера подписчика userTyping; при переполнении сигналы отбрасываются
+	typingBufferSize = 16
+)
+
+// typingSubscriber - канал одного подписчика userTyping
+type typingSubscriber struct {
+	id       uint64
+	postID   string
+	viewerID string
+	tenant   string
+	ch       chan *TypingEvent
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// send неблокирующе отправляет событие. Сигналы набора эфемерны, поэтому при заполненном буфере
+// событие теряется, а подписчик, в отличие от commentAdded, не отключается
+func (s *typingSubscriber) send(event *TypingEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- event:
+	default:
+		log.Printf("Буфер userTyping для postID=%s заполнен, сигнал отброшен", s.postID)
+	}
+}
+
+// close закрывает канал подписчика; отправка после закрытия игнорируется
+func (s *typingSubscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// UserTyping реализует подписку userTyping. Собственные сигналы подписчику не доставляются
+func (h *subscriptionHandler) UserTyping(ctx context.Context, postID string) (<-chan *TypingEvent, error) {
+	log.Printf("Запуск подписки userTyping для postID=%s", postID)
+	if err := h.checkPost(ctx, postID); err != nil {
+		return nil, err
+	}
+	viewerID, _ := ctx.Value("userID").(string)
+	sub := &typingSubscriber{
+		id:       h.nextID.Add(1),
+		postID:   postID,
+		viewerID: viewerID,
+		tenant:   tenantFromContext(ctx),
+		ch:       make(chan *TypingEvent, typingBufferSize),
+	}
+	shard := h.shard(postID)
+	shard.mu.Lock()
+	shard.typists[postID] = append(shard.typists[postID], sub)
+	metrics.SubscriptionsActive.WithLabelValues(subscriptionUserTyping).Inc()
+	shard.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		log.Printf("Контекст подписки userTyping для postID=%s завершён", postID)
+		h.unsubscribeTyping(sub)
+		sub.close()
+	}()
+	return sub.ch, nil
+}
+
+// unsubscribeTyping удаляет подписчика userTyping из шарда
+func (h *subscriptionHandler) unsubscribeTyping(sub *typingSubscriber) {
+	shard := h.shard(sub.postID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	subscribers := shard.typists[sub.postID]
+	for i, s := range subscribers {
+		if s == sub {
+			shard.typists[sub.postID] = append(subscribers[:i:i], subscribers[i+1:]...)
+			metrics.SubscriptionsActive.WithLabelValues(subscriptionUserTyping).Dec()
+			break
+		}
+	}
+	if len(shard.typists[sub.postID]) == 0 {
+		delete(shard.typists, sub.postID)
+	}
+}
+
+// allowTyping сообщает, принимается ли сигнал набора: не чаще одного за typingSignalInterval на пользователя и пост
+func (h *subscriptionHandler) allowTyping(userID, postID string, now time.Time) bool {
+	key := userID + "/" + postID
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+	if last, ok := h.lastTyping[key]; ok && now.Sub(last) < typingSignalInterval {
+		return false
+	}
+	h.lastTyping[key] = now
+	return true
+}
+
+// pruneTyping удаляет отметки, которые уже не ограничивают сигналы
+func (h *subscriptionHandler) pruneTyping(now time.Time) {
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+	for key, last := range h.lastTyping {
+		if now.Sub(last) >= typingSignalInterval {
+			delete(h.lastTyping, key)
+		}
+	}
+}
+
+// publishTyping рассылает сигнал набора подписчикам поста, кроме самого автора сигнала
+func (h *subscriptionHandler) publishTyping(event *TypingEvent) {
+	shard := h.shard(event.PostID)
+	shard.mu.RLock()
+	subscribers := append([]*typingSubscriber(nil), shard.typists[event.PostID]...)
+	shard.mu.RUnlock()
+	if len(subscribers) == 0 {
+		return
+	}
+	viewers, tenants := make(map[string]bool), make(map[string]bool)
+	for _, sub := range subscribers {
+		if sub.viewerID == "" {
+			tenants[sub.tenant] = true
+		} else {
+			viewers[sub.viewerID] = true
+		}
+	}
+	access := h.checkAccess(event.PostID, viewers, tenants)
+	for _, sub := range subscribers {
+		if sub.viewerID != event.UserID && access.allows(sub.viewerID, sub.tenant) {
+			sub.send(event)
+		}
+	}
+}
+
+// SignalTyping реализует мутацию signalTyping
+func (r *mutationResolver) SignalTyping(ctx context.Context, postID string) (bool, error) {
+	userID, _ := ctx.Value("userID").(string)
+	if userID == "" {
+		log.Println("Ошибка: сигнал набора без авторизации")
+		return false, gqlerrors.New(gqlerrors.CodeUnauthenticated, "authentication required")
+	}
+	now := r.Clock.Now()
+	// Ограничение проверяется до обращения к хранилищу: клиенты шлют сигнал на каждое нажатие клавиши
+	if !r.SubscriptionHandler.allowTyping(userID, postID, now) {
+		return false, nil
+	}
+	post, err := storage.ViewPost(ctx, r.Storage, postID, userID)
+	if err != nil {
+		log.Printf("Ошибка при получении поста %s для сигнала набора: %v", postID, err)
+		return false, categoryError("failed to signal typing", err)
+	}
+	if !post.AllowComments {
+		return false, gqlerrors.New(gqlerrors.CodeForbidden, "comments are disabled for this post")
+	}
+	shadowBanned, err := r.Storage.IsShadowBanned(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка при проверке теневого бана пользователя %s: %v", userID, err)
+		return false, gqlerrors.Errorf(gqlerrors.CodeInternal, "failed to signal typing: %v", err)
+	}
+	// Комментарии пользователя под теневым баном никто не видит, поэтому не видно и его набора
+	if !shadowBanned {
+		r.SubscriptionHandler.publishTyping(&TypingEvent{PostID: postID, UserID: userID, At: now.Format(time.RFC3339)})
+	}
+	return true, nil
+}
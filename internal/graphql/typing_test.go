@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalTyping(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	resolver.Clock = fake
+	author := userContext("user2", "")
+	writer := userContext("user1", "")
+	mod := userContext("mod1", "moderator")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	closed, err := mutation.CreatePost(author, "Без комментариев", "Содержимое", false, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(author)
+	defer cancel()
+	events, err := resolver.Subscription().UserTyping(ctx, post.ID)
+	require.NoError(t, err)
+	expectNone := func(message string) {
+		select {
+		case event := <-events:
+			t.Fatalf("%s: %+v", message, event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	_, err = mutation.SignalTyping(context.Background(), post.ID)
+	assert.Equal(t, gqlerrors.CodeUnauthenticated, gqlerrors.Code(err))
+	_, err = mutation.SignalTyping(writer, "missing")
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = mutation.SignalTyping(writer, closed.ID)
+	assert.Equal(t, gqlerrors.CodeForbidden, gqlerrors.Code(err))
+
+	ok, err := mutation.SignalTyping(writer, post.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	select {
+	case event := <-events:
+		assert.Equal(t, post.ID, event.PostID)
+		assert.Equal(t, "user1", event.UserID)
+	case <-time.After(time.Second):
+		t.Fatal("Таймаут ожидания сигнала набора")
+	}
+
+	ok, err = mutation.SignalTyping(writer, post.ID)
+	require.NoError(t, err)
+	assert.False(t, ok, "Повторный сигнал в пределах интервала отбрасывается")
+	expectNone("Отброшенный сигнал не рассылается")
+
+	ok, err = mutation.SignalTyping(author, post.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	expectNone("Собственный сигнал подписчику не доставляется")
+
+	_, err = mutation.ShadowBanUser(mod, "user3", nil)
+	require.NoError(t, err)
+	ok, err = mutation.SignalTyping(userContext("user3", ""), post.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	expectNone("Набор пользователя под теневым баном не виден")
+
+	fake.Advance(typingSignalInterval - time.Millisecond)
+	ok, err = mutation.SignalTyping(writer, post.ID)
+	require.NoError(t, err)
+	assert.False(t, ok, "Интервал ещё не прошёл")
+
+	fake.Advance(time.Millisecond)
+	ok, err = mutation.SignalTyping(writer, post.ID)
+	require.NoError(t, err)
+	assert.True(t, ok, "После интервала сигнал снова принимается")
+
+	resolver.SubscriptionHandler.pruneTyping(fake.Now().Add(typingSignalInterval))
+	assert.Empty(t, resolver.SubscriptionHandler.lastTyping, "Отметки старше интервала удаляются")
+}
@@ -0,0 +1,48 @@
+package graphql
+
+import (
+	"context"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// voteValues - значение голоса в хранилище для каждого варианта VoteValue
+var voteValues = map[VoteValue]int{
+	VoteValueUp:   1,
+	VoteValueDown: -1,
+	VoteValueNone: 0,
+}
+
+// VoteComment реализует мутацию voteComment
+func (r *mutationResolver) VoteComment(ctx context.Context, commentID string, vote VoteValue) (*Comment, error) {
+	log.Printf("Запуск мутации voteComment: commentID=%s, vote=%s", commentID, vote)
+	value, ok := voteValues[vote]
+	if !ok {
+		return nil, gqlerrors.Errorf(gqlerrors.CodeBadUserInput, "unknown vote %s", vote)
+	}
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		log.Println("userID не найден в контексте, используется user1")
+		userID = "user1"
+	}
+	// За скрытый комментарий или комментарий невидимого поста может голосовать только тот, кому он виден
+	if _, err := storage.ViewComment(ctx, r.Storage, commentID, userID); err != nil {
+		log.Printf("Ошибка при получении комментария с ID=%s: %v", commentID, err)
+		return nil, commentError("failed to vote", err)
+	}
+	updated, err := r.Storage.VoteComment(ctx, &models.Vote{
+		CommentID: commentID,
+		UserID:    userID,
+		Value:     value,
+		CreatedAt: r.Clock.Now(),
+	})
+	if err != nil {
+		log.Printf("Ошибка при сохранении голоса за комментарий %s: %v", commentID, err)
+		return nil, commentError("failed to vote", err)
+	}
+	log.Printf("Голос за комментарий %s: +%d/-%d", commentID, updated.Upvotes, updated.Downvotes)
+	return toComment(updated), nil
+}
@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoteComment(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	author := userContext("user2", "")
+	voter := userContext("user1", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	comment, err := mutation.CreateComment(author, post.ID, nil, "Комментарий", nil, nil, nil)
+	require.NoError(t, err)
+
+	voted, err := mutation.VoteComment(voter, comment.ID, VoteValueUp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, voted.Upvotes)
+	assert.Equal(t, 1, voted.Score)
+
+	voted, err = mutation.VoteComment(voter, comment.ID, VoteValueDown)
+	require.NoError(t, err)
+	assert.Equal(t, 0, voted.Upvotes, "Повторный голос заменяет предыдущий")
+	assert.Equal(t, 1, voted.Downvotes)
+	assert.Equal(t, -1, voted.Score)
+
+	voted, err = mutation.VoteComment(voter, comment.ID, VoteValueNone)
+	require.NoError(t, err)
+	assert.Equal(t, 0, voted.Downvotes)
+	assert.Equal(t, 0, voted.Score)
+
+	_, err = mutation.VoteComment(voter, "missing", VoteValueUp)
+	assert.Equal(t, gqlerrors.CodeNotFound, gqlerrors.Code(err))
+	_, err = mutation.VoteComment(voter, comment.ID, VoteValue("SIDEWAYS"))
+	assert.Equal(t, gqlerrors.CodeBadUserInput, gqlerrors.Code(err))
+}
+
+func TestVoteComment_BestOrder(t *testing.T) {
+	resolver := NewResolver(memory.New(), nil)
+	author := userContext("user2", "")
+	mutation := resolver.Mutation()
+	post, err := mutation.CreatePost(author, "Пост", "Содержимое", true, nil, nil, nil, nil)
+	require.NoError(t, err)
+	parent, err := mutation.CreateComment(author, post.ID, nil, "Родитель", nil, nil, nil)
+	require.NoError(t, err)
+	popular, err := mutation.CreateComment(author, post.ID, &parent.ID, "Популярный", nil, nil, nil)
+	require.NoError(t, err)
+	disliked, err := mutation.CreateComment(author, post.ID, &parent.ID, "Неудачный", nil, nil, nil)
+	require.NoError(t, err)
+	unvoted, err := mutation.CreateComment(author, post.ID, &parent.ID, "Без голосов", nil, nil, nil)
+	require.NoError(t, err)
+
+	for _, user := range []string{"user3", "user4", "user5"} {
+		_, err = mutation.VoteComment(userContext(user, ""), popular.ID, VoteValueUp)
+		require.NoError(t, err)
+		_, err = mutation.VoteComment(userContext(user, ""), disliked.ID, VoteValueDown)
+		require.NoError(t, err)
+	}
+
+	best := SortOrderBest
+	replies, err := resolver.Comment().Replies(author, parent, 2, nil, &best, nil)
+	require.NoError(t, err)
+	require.Len(t, replies.Comments, 2)
+	assert.Equal(t, popular.ID, replies.Comments[0].ID)
+	assert.Equal(t, unvoted.ID, replies.Comments[1].ID, "При равной оценке первым идёт более новый комментарий")
+	require.NotNil(t, replies.NextCursor)
+
+	replies, err = resolver.Comment().Replies(author, parent, 2, replies.NextCursor, &best, nil)
+	require.NoError(t, err)
+	require.Len(t, replies.Comments, 1)
+	assert.Equal(t, disliked.ID, replies.Comments[0].ID)
+}
@@ -0,0 +1,295 @@
+// Package ids создаёт и проверяет идентификаторы сущностей. Идентификаторы создаёт Generator,
+// который передаётся компонентам при сборке сервера: в production - упорядоченные по времени,
+// в тестах - предсказуемые
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Поддерживаемые форматы идентификаторов
+const (
+	// FormatUUID - случайный UUID версии 4
+	FormatUUID = "uuid"
+	// FormatUUIDv7 - UUID версии 7: 48 бит времени в миллисекундах и случайная часть
+	FormatUUIDv7 = "uuidv7"
+	// FormatULID - ULID: 48 бит времени в миллисекундах и 80 случайных бит. Записывается как UUID,
+	// поэтому проходит ту же проверку и хранится в тех же столбцах, а строковый порядок
+	// идентификаторов совпадает с порядком создания
+	FormatULID = "ulid"
+	// FormatSnowflake - 63-битное число в десятичной записи: время от SnowflakeEpoch, номер узла
+	// и счётчик в пределах миллисекунды. Узлы с разными номерами не выдают совпадающих
+	// идентификаторов; по времени создания они упорядочены как числа, но не как строки
+	FormatSnowflake = "snowflake"
+)
+
+// SnowflakeEpoch - начало отсчёта времени в идентификаторах snowflake
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// MaxSnowflakeNode - наибольший номер узла snowflake (10 бит)
+const MaxSnowflakeNode = 1<<10 - 1
+
+// snowflakeSequenceBits - разрядность счётчика snowflake в пределах миллисекунды
+const snowflakeSequenceBits = 12
+
+// Generator создаёт идентификаторы новых сущностей
+type Generator interface {
+	// New возвращает идентификатор, не совпадающий с выданными ранее
+	New() string
+}
+
+// Options - параметры генераторов
+type Options struct {
+	// Rand - источник случайных байтов, по умолчанию crypto/rand
+	Rand io.Reader
+	// Now - источник времени для упорядоченных форматов, по умолчанию time.Now
+	Now func() time.Time
+	// Node - номер узла для snowflake от 0 до MaxSnowflakeNode
+	Node int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Rand == nil {
+		o.Rand = rand.Reader
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	return o
+}
+
+// New возвращает генератор формата format
+func New(format string, opts Options) (Generator, error) {
+	opts = opts.withDefaults()
+	switch format {
+	case FormatUUID:
+		return &uuidV4{rand: opts.Rand}, nil
+	case FormatUUIDv7:
+		return &uuidV7{rand: opts.Rand, now: opts.Now}, nil
+	case FormatULID:
+		return &ulid{rand: opts.Rand, now: opts.Now}, nil
+	case FormatSnowflake:
+		if opts.Node < 0 || opts.Node > MaxSnowflakeNode {
+			return nil, fmt.Errorf("snowflake node must be between 0 and %d, got %d", MaxSnowflakeNode, opts.Node)
+		}
+		return &snowflake{node: int64(opts.Node), now: opts.Now}, nil
+	default:
+		return nil, fmt.Errorf("unknown id format %q", format)
+	}
+}
+
+// Default возвращает генератор случайных UUID версии 4
+func Default() Generator {
+	return &uuidV4{rand: rand.Reader}
+}
+
+// mustRead заполняет b из источника случайности. Без случайных байтов нельзя выдать
+// уникальный идентификатор, поэтому ошибка чтения - паника, как в uuid.New
+func mustRead(r io.Reader, b []byte) {
+	if _, err := io.ReadFull(r, b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+}
+
+// uuidV4 создаёт случайные UUID версии 4
+type uuidV4 struct {
+	mu   sync.Mutex
+	rand io.Reader
+}
+
+func (g *uuidV4) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id, err := uuid.NewRandomFromReader(g.rand)
+	if err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return id.String()
+}
+
+// uuidV7 создаёт UUID версии 7 по RFC 9562. Время берётся из now, а не из часов пакета uuid,
+// поэтому идентификаторы в пределах миллисекунды упорядочены только случайно
+type uuidV7 struct {
+	mu   sync.Mutex
+	rand io.Reader
+	now  func() time.Time
+}
+
+func (g *uuidV7) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var b [16]byte
+	putMillis(&b, uint64(g.now().UnixMilli()))
+	mustRead(g.rand, b[6:])
+	b[6] = b[6]&0x0f | 0x70 // версия 7
+	b[8] = b[8]&0x3f | 0x80 // вариант RFC 9562
+	return format36(b)
+}
+
+// ulid создаёт ULID. Идентификаторы, созданные в одну миллисекунду или при отступивших назад
+// часах, получают случайную часть на единицу больше предыдущей, поэтому строго возрастают
+type ulid struct {
+	mu       sync.Mutex
+	rand     io.Reader
+	now      func() time.Time
+	lastMs   uint64
+	lastRand [10]byte
+}
+
+func (g *ulid) New() string {
+	ms := uint64(g.now().UnixMilli())
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ms <= g.lastMs {
+		ms = g.lastMs
+		increment(&g.lastRand)
+	} else {
+		mustRead(g.rand, g.lastRand[:])
+		// Старший бит свободен, чтобы инкремент не переполнился за миллисекунду
+		g.lastRand[0] &= 0x7f
+		g.lastMs = ms
+	}
+	var b [16]byte
+	putMillis(&b, ms)
+	copy(b[6:], g.lastRand[:])
+	return format36(b)
+}
+
+// snowflake создаёт идентификаторы snowflake. При исчерпании счётчика или отступивших назад
+// часах время идентификатора продолжает последнее выданное, а не ждёт часов
+type snowflake struct {
+	mu     sync.Mutex
+	node   int64
+	now    func() time.Time
+	lastMs int64
+	seq    int64
+}
+
+func (g *snowflake) New() string {
+	ms := g.now().Sub(SnowflakeEpoch).Milliseconds()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ms <= g.lastMs {
+		g.seq = (g.seq + 1) & (1<<snowflakeSequenceBits - 1)
+		if g.seq == 0 {
+			g.lastMs++
+		}
+	} else {
+		g.lastMs = ms
+		g.seq = 0
+	}
+	id := g.lastMs<<(10+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.seq
+	return strconv.FormatInt(id, 10)
+}
+
+// Sequence выдаёт UUID с номерами 1, 2, 3... Предназначен для тестов, где идентификаторы
+// должны совпадать между запусками
+type Sequence struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+// NewSequence создаёт последовательность, начинающуюся с 1
+func NewSequence() *Sequence {
+	return &Sequence{}
+}
+
+// New реализует Generator
+func (s *Sequence) New() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return Seq(s.n)
+}
+
+// Seq возвращает n-й идентификатор Sequence
+func Seq(n uint64) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[8:], n)
+	b[6] = 0x40 // версия 4
+	b[8] |= 0x80
+	return format36(b)
+}
+
+// putMillis записывает 48 бит времени в начало b
+func putMillis(b *[16]byte, ms uint64) {
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+}
+
+// increment прибавляет единицу к числу big-endian
+func increment(b *[10]byte) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return
+		}
+	}
+}
+
+// format36 записывает 16 байт в каноническом виде UUID
+func format36(b [16]byte) string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// Valid сообщает, может ли s быть идентификатором, выданным одним из генераторов: UUID в
+// каноническом виде или положительное десятичное число snowflake. Фигурные скобки, префикс
+// urn:uuid:, верхний регистр, запись без дефисов и ведущие нули не принимаются, чтобы
+// у одной сущности был один идентификатор
+func Valid(s string) bool {
+	if len(s) == 36 {
+		return validUUID(s)
+	}
+	return validSnowflake(s)
+}
+
+func validUUID(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func validSnowflake(s string) bool {
+	if s == "" || s[0] < '1' || s[0] > '9' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
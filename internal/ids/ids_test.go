@@ -0,0 +1,174 @@
+package ids
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock возвращает заданное время; advance сдвигает его
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{uuid.New().String(), true},
+		{"01890a5d-ac96-774b-bcce-b302099a8057", true},
+		{"1234567890123", true},
+		{"9223372036854775807", true},
+		{"", false},
+		{"post1", false},
+		{"0123", false},
+		{"9223372036854775808", false},
+		{"-5", false},
+		{"01890A5D-AC96-774B-BCCE-B302099A8057", false},
+		{"{01890a5d-ac96-774b-bcce-b302099a8057}", false},
+		{"urn:uuid:01890a5d-ac96-774b-bcce-b302099a8057", false},
+		{"01890a5dac96774bbccebb302099a8057", false},
+		{"01890a5d-ac96-774b-bcce-b302099a805g", false},
+		{"01890a5d-ac96-774b-bcceb-302099a8057", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Valid(tt.id), tt.id)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("uuidv1", Options{})
+	assert.Error(t, err)
+	_, err = New(FormatSnowflake, Options{Node: MaxSnowflakeNode + 1})
+	assert.Error(t, err)
+
+	for _, format := range []string{FormatUUID, FormatUUIDv7, FormatULID, FormatSnowflake} {
+		generator, err := New(format, Options{})
+		require.NoError(t, err, format)
+		seen := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			id := generator.New()
+			require.True(t, Valid(id), "%s: %s", format, id)
+			require.False(t, seen[id], "%s: повтор %s", format, id)
+			seen[id] = true
+		}
+	}
+}
+
+func TestUUID_RandomSource(t *testing.T) {
+	random := bytes.Repeat([]byte{0xab}, 64)
+	first, err := New(FormatUUID, Options{Rand: bytes.NewReader(random)})
+	require.NoError(t, err)
+	second, err := New(FormatUUID, Options{Rand: bytes.NewReader(random)})
+	require.NoError(t, err)
+
+	id := first.New()
+	assert.Equal(t, id, second.New(), "Одинаковый источник случайности даёт одинаковые идентификаторы")
+	assert.Equal(t, uuid.Version(4), uuid.MustParse(id).Version())
+}
+
+func TestUUIDv7(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	generator, err := New(FormatUUIDv7, Options{Now: clock.Now})
+	require.NoError(t, err)
+
+	first := uuid.MustParse(generator.New())
+	assert.Equal(t, uuid.Version(7), first.Version())
+	assert.Equal(t, uuid.RFC4122, first.Variant())
+	sec, nsec := first.Time().UnixTime()
+	assert.Equal(t, clock.now.UnixMilli(), sec*1000+nsec/int64(time.Millisecond))
+
+	clock.advance(time.Millisecond)
+	assert.Greater(t, generator.New(), first.String())
+}
+
+func TestULID(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	generator, err := New(FormatULID, Options{Now: clock.Now})
+	require.NoError(t, err)
+
+	first := generator.New()
+	parsed := uuid.MustParse(first)
+	ms := int64(parsed[0])<<40 | int64(parsed[1])<<32 | int64(parsed[2])<<24 | int64(parsed[3])<<16 | int64(parsed[4])<<8 | int64(parsed[5])
+	assert.Equal(t, clock.now.UnixMilli(), ms, "Первые 48 бит - время создания")
+
+	t.Run("ordered by creation", func(t *testing.T) {
+		generated := []string{first}
+		for i := 0; i < 100; i++ {
+			// Часть идентификаторов создаётся в одну миллисекунду
+			if i%10 == 0 {
+				clock.advance(time.Millisecond)
+			}
+			generated = append(generated, generator.New())
+		}
+		assert.True(t, sort.StringsAreSorted(generated))
+	})
+
+	t.Run("clock going back keeps order", func(t *testing.T) {
+		later := generator.New()
+		clock.advance(-time.Hour)
+		assert.Greater(t, generator.New(), later)
+	})
+}
+
+func TestSnowflake(t *testing.T) {
+	clock := &fakeClock{now: SnowflakeEpoch.Add(time.Hour)}
+	generator, err := New(FormatSnowflake, Options{Now: clock.Now, Node: 5})
+	require.NoError(t, err)
+
+	parse := func(id string) int64 {
+		n, err := strconv.ParseInt(id, 10, 64)
+		require.NoError(t, err)
+		return n
+	}
+	first := parse(generator.New())
+	assert.Equal(t, time.Hour.Milliseconds(), first>>22, "Старшие биты - время от SnowflakeEpoch")
+	assert.Equal(t, int64(5), first>>12&MaxSnowflakeNode)
+
+	prev := first
+	// Больше идентификаторов, чем помещается в счётчик одной миллисекунды
+	for i := 0; i < 5000; i++ {
+		next := parse(generator.New())
+		require.Greater(t, next, prev)
+		prev = next
+	}
+
+	other, err := New(FormatSnowflake, Options{Now: clock.Now, Node: 6})
+	require.NoError(t, err)
+	assert.NotEqual(t, first, parse(other.New()), "Узлы не выдают одинаковых идентификаторов")
+}
+
+func TestSequence(t *testing.T) {
+	seq := NewSequence()
+	assert.Equal(t, "00000000-0000-4000-8000-000000000001", seq.New())
+	assert.Equal(t, Seq(2), seq.New())
+	assert.True(t, Valid(Seq(3)))
+}
+
+func TestShort(t *testing.T) {
+	assert.Equal(t, "1", EncodeShort(1))
+	assert.Equal(t, "z", EncodeShort(61))
+	assert.Equal(t, "10", EncodeShort(62))
+	assert.Equal(t, "", EncodeShort(0))
+	for _, n := range []int64{1, 61, 62, 3843, 3844, 1 << 40, math.MaxInt64} {
+		decoded, err := DecodeShort(EncodeShort(n))
+		require.NoError(t, err)
+		assert.Equal(t, n, decoded)
+	}
+	for _, s := range []string{"", "0", "01", "a-b", "Zz!", "zzzzzzzzzzz", "123456789012"} {
+		_, err := DecodeShort(s)
+		assert.ErrorIs(t, err, ErrInvalidShort, s)
+	}
+}
@@ -0,0 +1,45 @@
+package ids
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// shortAlphabet - символы коротких идентификаторов: цифры и латинские буквы, безопасные в URL
+const shortAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrInvalidShort возвращается для строки, которая не является коротким идентификатором
+var ErrInvalidShort = errors.New("invalid short id")
+
+// EncodeShort записывает порядковый номер короткой ссылки в base62. Номера выдаёт хранилище,
+// поэтому идентификатор из нескольких символов остаётся уникальным, в отличие от усечённого хеша
+func EncodeShort(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	var b [11]byte
+	i := len(b)
+	for n > 0 {
+		i--
+		b[i] = shortAlphabet[n%62]
+		n /= 62
+	}
+	return string(b[i:])
+}
+
+// DecodeShort возвращает порядковый номер, записанный EncodeShort; с учётом регистра символов
+func DecodeShort(s string) (int64, error) {
+	if s == "" || len(s) > 11 || s[0] == '0' {
+		return 0, ErrInvalidShort
+	}
+	var n int64
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(shortAlphabet, s[i])
+		if digit < 0 || n > (math.MaxInt64-int64(digit))/62 {
+			return 0, ErrInvalidShort
+		}
+		n = n*62 + int64(digit)
+	}
+	return n, nil
+}
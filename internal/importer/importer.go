@@ -0,0 +1,243 @@
+// Package importer переносит обсуждения из выгрузок Disqus и WordPress в посты и комментарии.
+// Идентификаторы создаются из идентификаторов исходной системы, поэтому повторный импорт той же
+// или более новой выгрузки добавляет только новые комментарии
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/slug"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Поддерживаемые форматы выгрузок
+const (
+	FormatDisqus    = "disqus"
+	FormatWordPress = "wordpress"
+)
+
+// namespace - пространство имён UUID версии 5 для идентификаторов импортированных сущностей
+var namespace = uuid.MustParse("5b0f2f8e-9a4c-4c1e-8d0b-3f6a2e7c9d41")
+
+// Thread - обсуждение из выгрузки: запись блога или страница с комментариями
+type Thread struct {
+	SourceID  string
+	Title     string
+	Link      string
+	Content   string
+	Author    string
+	CreatedAt time.Time
+	// Closed - новые комментарии в исходной системе запрещены
+	Closed   bool
+	Comments []Comment
+}
+
+// Comment - комментарий из выгрузки
+type Comment struct {
+	SourceID string
+	// ParentSourceID - идентификатор родительского комментария; пустой у корневых
+	ParentSourceID string
+	Author         string
+	Content        string
+	CreatedAt      time.Time
+	// Hidden - комментарий удалён, не одобрен или помечен как спам в исходной системе
+	Hidden bool
+	Spam   bool
+}
+
+// Report - итог импорта; при пробном запуске - что было бы создано
+type Report struct {
+	Posts    int
+	Comments int
+	// ExistingPosts и ExistingComments - уже импортированные ранее и пропущенные записи
+	ExistingPosts    int
+	ExistingComments int
+	// Orphans - комментарии, родителя которых нет в выгрузке; импортируются корневыми
+	Orphans int
+}
+
+// Options - параметры импорта
+type Options struct {
+	// DryRun - только посчитать, что будет создано, не меняя хранилище
+	DryRun bool
+}
+
+// Importer сохраняет обсуждения выгрузки в хранилище
+type Importer struct {
+	store storage.Storage
+	opts  Options
+}
+
+// New создаёт Importer
+func New(store storage.Storage, opts Options) *Importer {
+	log.Printf("Создание Importer: пробный запуск %t", opts.DryRun)
+	return &Importer{store: store, opts: opts}
+}
+
+// Parse разбирает выгрузку в формате format
+func Parse(format string, r io.Reader) ([]Thread, error) {
+	switch format {
+	case FormatDisqus:
+		return ParseDisqus(r)
+	case FormatWordPress:
+		return ParseWordPress(r)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// Import сохраняет обсуждения выгрузки source как посты с комментариями, сохраняя иерархию
+// и время создания. Авторы получают ID вида source:имя. Записи, импортированные ранее, пропускаются
+func (im *Importer) Import(ctx context.Context, source string, threads []Thread) (*Report, error) {
+	report := &Report{}
+	for _, thread := range threads {
+		if err := im.importThread(ctx, source, thread, report); err != nil {
+			return report, err
+		}
+	}
+	log.Printf("Импорт %s завершён: постов %d, комментариев %d, уже импортировано постов %d и комментариев %d, без родителя %d",
+		source, report.Posts, report.Comments, report.ExistingPosts, report.ExistingComments, report.Orphans)
+	return report, nil
+}
+
+func (im *Importer) importThread(ctx context.Context, source string, thread Thread, report *Report) error {
+	postID := entityID(source, "thread", thread.SourceID)
+	_, err := im.store.GetPost(ctx, postID)
+	switch {
+	case err == nil:
+		report.ExistingPosts++
+	case !errors.Is(err, storage.ErrPostNotFound):
+		return fmt.Errorf("failed to check post %s: %v", thread.SourceID, err)
+	default:
+		content := thread.Content
+		if content == "" {
+			content = thread.Link
+		}
+		title := thread.Title
+		if title == "" {
+			title = thread.Link
+		}
+		post := &models.Post{
+			ID:            postID,
+			Title:         title,
+			Content:       content,
+			Format:        models.FormatPlain,
+			AuthorID:      authorID(source, thread.Author),
+			AllowComments: !thread.Closed,
+			CreatedAt:     thread.CreatedAt,
+			Slug:          slug.Make(title),
+		}
+		if !im.opts.DryRun {
+			if err := im.store.CreatePost(ctx, post); err != nil {
+				return fmt.Errorf("failed to import post %s: %v", thread.SourceID, err)
+			}
+		}
+		report.Posts++
+	}
+
+	comments, orphans := ordered(thread.Comments)
+	report.Orphans += orphans
+	for _, c := range comments {
+		commentID := entityID(source, "comment", c.SourceID)
+		_, err := im.store.GetComment(ctx, commentID)
+		if err == nil {
+			report.ExistingComments++
+			continue
+		}
+		if !errors.Is(err, storage.ErrCommentNotFound) {
+			return fmt.Errorf("failed to check comment %s: %v", c.SourceID, err)
+		}
+		comment := &models.Comment{
+			ID:        commentID,
+			PostID:    postID,
+			AuthorID:  authorID(source, c.Author),
+			Content:   c.Content,
+			Format:    models.FormatPlain,
+			CreatedAt: c.CreatedAt,
+			Hidden:    c.Hidden,
+		}
+		if c.Spam {
+			comment.SpamStatus = models.SpamStatusSpam
+		}
+		if c.ParentSourceID != "" {
+			parentID := entityID(source, "comment", c.ParentSourceID)
+			comment.ParentID = &parentID
+		}
+		if !im.opts.DryRun {
+			if err := im.store.CreateComment(ctx, comment); err != nil {
+				return fmt.Errorf("failed to import comment %s: %v", c.SourceID, err)
+			}
+		}
+		report.Comments++
+	}
+	return nil
+}
+
+// ordered возвращает комментарии так, что родитель идёт раньше ответов. Комментарии, родителя
+// которых нет в выгрузке или которые ссылаются друг на друга по кругу, становятся корневыми;
+// их количество возвращается вторым значением
+func ordered(comments []Comment) ([]Comment, int) {
+	known := make(map[string]bool, len(comments))
+	for _, c := range comments {
+		known[c.SourceID] = true
+	}
+	children := make(map[string][]Comment, len(comments))
+	var roots []Comment
+	orphans := 0
+	for _, c := range comments {
+		switch {
+		case c.ParentSourceID == "":
+			roots = append(roots, c)
+		case !known[c.ParentSourceID]:
+			c.ParentSourceID = ""
+			roots = append(roots, c)
+			orphans++
+		default:
+			children[c.ParentSourceID] = append(children[c.ParentSourceID], c)
+		}
+	}
+
+	out := make([]Comment, 0, len(comments))
+	visited := make(map[string]bool, len(comments))
+	walk := func(queue []Comment) {
+		for len(queue) > 0 {
+			c := queue[0]
+			queue = queue[1:]
+			if visited[c.SourceID] {
+				continue
+			}
+			visited[c.SourceID] = true
+			out = append(out, c)
+			queue = append(queue, children[c.SourceID]...)
+		}
+	}
+	walk(roots)
+	for _, c := range comments {
+		if !visited[c.SourceID] {
+			c.ParentSourceID = ""
+			orphans++
+			walk([]Comment{c})
+		}
+	}
+	return out, orphans
+}
+
+// entityID возвращает постоянный ID импортированной сущности kind с идентификатором sourceID в выгрузке source
+func entityID(source, kind, sourceID string) string {
+	return uuid.NewSHA1(namespace, []byte(source+":"+kind+":"+sourceID)).String()
+}
+
+// authorID возвращает ID автора из выгрузки source
+func authorID(source, name string) string {
+	if name == "" {
+		name = "anonymous"
+	}
+	return source + ":" + name
+}
@@ -0,0 +1,63 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	threads, err := Parse(FormatWordPress, strings.NewReader(wordPressSample))
+	require.NoError(t, err)
+
+	report, err := New(store, Options{DryRun: true}).Import(ctx, FormatWordPress, threads)
+	require.NoError(t, err)
+	assert.Equal(t, &Report{Posts: 1, Comments: 2}, report)
+	_, err = store.GetPost(ctx, entityID(FormatWordPress, "thread", "10"))
+	assert.Error(t, err, "Пробный запуск не меняет хранилище")
+
+	report, err = New(store, Options{}).Import(ctx, FormatWordPress, threads)
+	require.NoError(t, err)
+	assert.Equal(t, &Report{Posts: 1, Comments: 2}, report)
+	post, err := store.GetPost(ctx, entityID(FormatWordPress, "thread", "10"))
+	require.NoError(t, err)
+	assert.Equal(t, "wordpress:admin", post.AuthorID)
+	assert.True(t, post.CreatedAt.Equal(time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)))
+	reply, err := store.GetComment(ctx, entityID(FormatWordPress, "comment", "2"))
+	require.NoError(t, err)
+	require.NotNil(t, reply.ParentID)
+	assert.Equal(t, entityID(FormatWordPress, "comment", "1"), *reply.ParentID)
+	assert.True(t, reply.Hidden)
+
+	threads[0].Comments = append(threads[0].Comments, Comment{SourceID: "4", ParentSourceID: "2", Author: "Мария", Content: "Новый", CreatedAt: time.Now()})
+	report, err = New(store, Options{}).Import(ctx, FormatWordPress, threads)
+	require.NoError(t, err)
+	assert.Equal(t, &Report{Comments: 1, ExistingPosts: 1, ExistingComments: 2}, report, "Повторный импорт добавляет только новые комментарии")
+}
+
+func TestOrdered(t *testing.T) {
+	comments := []Comment{
+		{SourceID: "reply", ParentSourceID: "root"},
+		{SourceID: "root"},
+		{SourceID: "orphan", ParentSourceID: "missing"},
+		{SourceID: "a", ParentSourceID: "b"},
+		{SourceID: "b", ParentSourceID: "a"},
+	}
+	out, orphans := ordered(comments)
+	assert.Equal(t, 2, orphans)
+	ids := make([]string, len(out))
+	for i, c := range out {
+		ids[i] = c.SourceID
+	}
+	assert.Equal(t, []string{"root", "orphan", "reply", "a", "b"}, ids)
+	assert.Empty(t, out[1].ParentSourceID)
+	assert.Empty(t, out[3].ParentSourceID, "Цикл разрывается на первом комментарии")
+	assert.Equal(t, "a", out[4].ParentSourceID)
+}
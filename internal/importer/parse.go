@@ -0,0 +1,267 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// wordPressTime - формат времени в WXR
+const wordPressTime = "2006-01-02 15:04:05"
+
+type disqusExport struct {
+	Threads []disqusThread `xml:"thread"`
+	Posts   []disqusPost   `xml:"post"`
+}
+
+type disqusAuthor struct {
+	Name     string `xml:"name"`
+	Username string `xml:"username"`
+}
+
+type disqusRef struct {
+	ID string `xml:"http://disqus.com/disqus-internals id,attr"`
+}
+
+type disqusThread struct {
+	ID        string       `xml:"http://disqus.com/disqus-internals id,attr"`
+	Link      string       `xml:"link"`
+	Title     string       `xml:"title"`
+	Message   string       `xml:"message"`
+	CreatedAt string       `xml:"createdAt"`
+	IsClosed  bool         `xml:"isClosed"`
+	IsDeleted bool         `xml:"isDeleted"`
+	Author    disqusAuthor `xml:"author"`
+}
+
+type disqusPost struct {
+	ID        string       `xml:"http://disqus.com/disqus-internals id,attr"`
+	Message   string       `xml:"message"`
+	CreatedAt string       `xml:"createdAt"`
+	IsDeleted bool         `xml:"isDeleted"`
+	IsSpam    bool         `xml:"isSpam"`
+	Author    disqusAuthor `xml:"author"`
+	Thread    disqusRef    `xml:"thread"`
+	Parent    *disqusRef   `xml:"parent"`
+}
+
+// ParseDisqus разбирает XML-выгрузку Disqus. Удалённые обсуждения пропускаются, удалённые
+// и спам-комментарии сохраняются скрытыми, чтобы ответы на них не потеряли место в дереве
+func ParseDisqus(r io.Reader) ([]Thread, error) {
+	var export disqusExport
+	if err := xml.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("failed to parse Disqus export: %v", err)
+	}
+	threads := make([]Thread, 0, len(export.Threads))
+	index := make(map[string]int, len(export.Threads))
+	for _, t := range export.Threads {
+		if t.IsDeleted {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, strings.TrimSpace(t.CreatedAt))
+		if err != nil {
+			return nil, fmt.Errorf("invalid createdAt of Disqus thread %s: %v", t.ID, err)
+		}
+		index[t.ID] = len(threads)
+		threads = append(threads, Thread{
+			SourceID:  t.ID,
+			Title:     strings.TrimSpace(t.Title),
+			Link:      strings.TrimSpace(t.Link),
+			Content:   htmlToText(t.Message),
+			Author:    disqusAuthorName(t.Author),
+			CreatedAt: createdAt,
+			Closed:    t.IsClosed,
+		})
+	}
+	skipped := 0
+	for _, p := range export.Posts {
+		i, ok := index[p.Thread.ID]
+		if !ok {
+			skipped++
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, strings.TrimSpace(p.CreatedAt))
+		if err != nil {
+			return nil, fmt.Errorf("invalid createdAt of Disqus post %s: %v", p.ID, err)
+		}
+		comment := Comment{
+			SourceID:  p.ID,
+			Author:    disqusAuthorName(p.Author),
+			Content:   htmlToText(p.Message),
+			CreatedAt: createdAt,
+			Hidden:    p.IsDeleted || p.IsSpam,
+			Spam:      p.IsSpam,
+		}
+		if p.Parent != nil {
+			comment.ParentSourceID = p.Parent.ID
+		}
+		threads[i].Comments = append(threads[i].Comments, comment)
+	}
+	log.Printf("Выгрузка Disqus разобрана: обсуждений %d, комментариев к удалённым или неизвестным обсуждениям пропущено %d", len(threads), skipped)
+	return threads, nil
+}
+
+func disqusAuthorName(author disqusAuthor) string {
+	if author.Username != "" {
+		return author.Username
+	}
+	return author.Name
+}
+
+type wordPressExport struct {
+	Items []wordPressItem `xml:"channel>item"`
+}
+
+type wordPressItem struct {
+	Title         string             `xml:"title"`
+	Link          string             `xml:"link"`
+	Creator       string             `xml:"creator"`
+	Content       string             `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID        string             `xml:"post_id"`
+	PostDate      string             `xml:"post_date"`
+	PostDateGMT   string             `xml:"post_date_gmt"`
+	CommentStatus string             `xml:"comment_status"`
+	Status        string             `xml:"status"`
+	PostType      string             `xml:"post_type"`
+	Comments      []wordPressComment `xml:"comment"`
+}
+
+type wordPressComment struct {
+	ID       string `xml:"comment_id"`
+	Author   string `xml:"comment_author"`
+	Date     string `xml:"comment_date"`
+	DateGMT  string `xml:"comment_date_gmt"`
+	Content  string `xml:"comment_content"`
+	Approved string `xml:"comment_approved"`
+	Type     string `xml:"comment_type"`
+	Parent   string `xml:"comment_parent"`
+}
+
+// ParseWordPress разбирает выгрузку WordPress (WXR). Импортируются опубликованные записи и страницы;
+// pingback и trackback пропускаются, неодобренные, удалённые и спам-комментарии сохраняются скрытыми
+func ParseWordPress(r io.Reader) ([]Thread, error) {
+	var export wordPressExport
+	if err := xml.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("failed to parse WordPress export: %v", err)
+	}
+	var threads []Thread
+	for _, item := range export.Items {
+		if item.Status != "publish" || (item.PostType != "post" && item.PostType != "page") {
+			continue
+		}
+		createdAt, err := wordPressDate(item.PostDateGMT, item.PostDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date of WordPress post %s: %v", item.PostID, err)
+		}
+		thread := Thread{
+			SourceID:  item.PostID,
+			Title:     strings.TrimSpace(item.Title),
+			Link:      strings.TrimSpace(item.Link),
+			Content:   htmlToText(item.Content),
+			Author:    item.Creator,
+			CreatedAt: createdAt,
+			Closed:    item.CommentStatus == "closed",
+		}
+		for _, c := range item.Comments {
+			if c.Type != "" && c.Type != "comment" {
+				continue
+			}
+			createdAt, err := wordPressDate(c.DateGMT, c.Date)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date of WordPress comment %s: %v", c.ID, err)
+			}
+			comment := Comment{
+				SourceID:  c.ID,
+				Author:    c.Author,
+				Content:   htmlToText(c.Content),
+				CreatedAt: createdAt,
+				Hidden:    c.Approved != "1",
+				Spam:      c.Approved == "spam",
+			}
+			if c.Parent != "" && c.Parent != "0" {
+				comment.ParentSourceID = c.Parent
+			}
+			thread.Comments = append(thread.Comments, comment)
+		}
+		threads = append(threads, thread)
+	}
+	log.Printf("Выгрузка WordPress разобрана: записей %d", len(threads))
+	return threads, nil
+}
+
+// wordPressDate разбирает время в UTC, а если оно не заполнено - местное время сайта, считая его UTC
+func wordPressDate(gmt, local string) (time.Time, error) {
+	value := strings.TrimSpace(gmt)
+	if value == "" || strings.HasPrefix(value, "0000") {
+		value = strings.TrimSpace(local)
+	}
+	return time.Parse(wordPressTime, value)
+}
+
+// htmlToText превращает HTML выгрузки в обычный текст: теги удаляются, абзацы и переносы строк
+// сохраняются, ссылки заменяются текстом с адресом в скобках, если текст с ним не совпадает
+func htmlToText(source string) string {
+	var b strings.Builder
+	var href string
+	var linkStart int
+	z := html.NewTokenizer(strings.NewReader(source))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(collapseBlankLines(b.String()))
+		case html.TextToken:
+			b.Write(z.Text())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			switch token.Data {
+			case "br":
+				b.WriteString("\n")
+			case "p", "div", "blockquote", "li":
+				b.WriteString("\n\n")
+			case "a":
+				href, linkStart = "", b.Len()
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						href = attr.Val
+					}
+				}
+			}
+		case html.EndTagToken:
+			token := z.Token()
+			switch token.Data {
+			case "p", "div", "blockquote", "li":
+				b.WriteString("\n\n")
+			case "a":
+				if href != "" && href != strings.TrimSpace(b.String()[linkStart:]) {
+					b.WriteString(" (" + href + ")")
+				}
+				href = ""
+			}
+		}
+	}
+}
+
+// collapseBlankLines оставляет не больше одной пустой строки подряд и убирает пробелы по краям строк
+func collapseBlankLines(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const disqusSample = `<?xml version="1.0" encoding="utf-8"?>
+<disqus xmlns="http://disqus.com" xmlns:dsq="http://disqus.com/disqus-internals">
+  <thread dsq:id="t1">
+    <id>page-1</id>
+    <link>https://blog.example.com/first</link>
+    <title>Первая запись</title>
+    <message><![CDATA[<p>Текст записи</p>]]></message>
+    <createdAt>2015-03-01T10:00:00Z</createdAt>
+    <author><name>Автор</name><username>author</username></author>
+    <isClosed>true</isClosed>
+    <isDeleted>false</isDeleted>
+  </thread>
+  <thread dsq:id="t2">
+    <link>https://blog.example.com/deleted</link>
+    <title>Удалённая</title>
+    <createdAt>2015-03-02T10:00:00Z</createdAt>
+    <isDeleted>true</isDeleted>
+  </thread>
+  <post dsq:id="p1">
+    <message><![CDATA[<p>Привет</p><p>См. <a href="https://example.com/doc">документацию</a><br>и <a href="https://example.com">https://example.com</a></p>]]></message>
+    <createdAt>2015-03-01T11:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name>Иван</name><username>ivan</username></author>
+    <thread dsq:id="t1"/>
+  </post>
+  <post dsq:id="p2">
+    <message><![CDATA[Ответ &amp; спам]]></message>
+    <createdAt>2015-03-01T12:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>true</isSpam>
+    <author><name>Гость</name></author>
+    <thread dsq:id="t1"/>
+    <parent dsq:id="p1"/>
+  </post>
+  <post dsq:id="p3">
+    <message>К удалённой</message>
+    <createdAt>2015-03-02T12:00:00Z</createdAt>
+    <thread dsq:id="t2"/>
+  </post>
+</disqus>`
+
+const wordPressSample = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+  xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+  <item>
+    <title>Запись</title>
+    <link>https://wp.example.com/post</link>
+    <dc:creator><![CDATA[admin]]></dc:creator>
+    <content:encoded><![CDATA[Текст <b>записи</b>]]></content:encoded>
+    <excerpt:encoded><![CDATA[Анонс]]></excerpt:encoded>
+    <wp:post_id>10</wp:post_id>
+    <wp:post_date>2020-05-01 15:00:00</wp:post_date>
+    <wp:post_date_gmt>2020-05-01 12:00:00</wp:post_date_gmt>
+    <wp:comment_status>open</wp:comment_status>
+    <wp:status>publish</wp:status>
+    <wp:post_type>post</wp:post_type>
+    <wp:comment>
+      <wp:comment_id>1</wp:comment_id>
+      <wp:comment_author><![CDATA[Мария]]></wp:comment_author>
+      <wp:comment_date>2020-05-02 15:00:00</wp:comment_date>
+      <wp:comment_date_gmt>2020-05-02 12:00:00</wp:comment_date_gmt>
+      <wp:comment_content><![CDATA[Отличная запись]]></wp:comment_content>
+      <wp:comment_approved>1</wp:comment_approved>
+      <wp:comment_type>comment</wp:comment_type>
+      <wp:comment_parent>0</wp:comment_parent>
+    </wp:comment>
+    <wp:comment>
+      <wp:comment_id>2</wp:comment_id>
+      <wp:comment_author><![CDATA[Олег]]></wp:comment_author>
+      <wp:comment_date>2020-05-02 16:00:00</wp:comment_date>
+      <wp:comment_date_gmt>0000-00-00 00:00:00</wp:comment_date_gmt>
+      <wp:comment_content><![CDATA[Согласен]]></wp:comment_content>
+      <wp:comment_approved>0</wp:comment_approved>
+      <wp:comment_type></wp:comment_type>
+      <wp:comment_parent>1</wp:comment_parent>
+    </wp:comment>
+    <wp:comment>
+      <wp:comment_id>3</wp:comment_id>
+      <wp:comment_date_gmt>2020-05-03 12:00:00</wp:comment_date_gmt>
+      <wp:comment_approved>1</wp:comment_approved>
+      <wp:comment_type>pingback</wp:comment_type>
+    </wp:comment>
+  </item>
+  <item>
+    <title>Черновик</title>
+    <wp:post_id>11</wp:post_id>
+    <wp:status>draft</wp:status>
+    <wp:post_type>post</wp:post_type>
+  </item>
+</channel>
+</rss>`
+
+func TestParseDisqus(t *testing.T) {
+	threads, err := ParseDisqus(strings.NewReader(disqusSample))
+	require.NoError(t, err)
+	require.Len(t, threads, 1, "Удалённые обсуждения пропускаются")
+	thread := threads[0]
+	assert.Equal(t, "t1", thread.SourceID)
+	assert.Equal(t, "Первая запись", thread.Title)
+	assert.Equal(t, "Текст записи", thread.Content)
+	assert.Equal(t, "author", thread.Author)
+	assert.True(t, thread.Closed)
+	assert.Equal(t, time.Date(2015, 3, 1, 10, 0, 0, 0, time.UTC), thread.CreatedAt)
+	assert.Equal(t, []Comment{
+		{
+			SourceID:  "p1",
+			Author:    "ivan",
+			Content:   "Привет\n\nСм. документацию (https://example.com/doc)\nи https://example.com",
+			CreatedAt: time.Date(2015, 3, 1, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			SourceID:       "p2",
+			ParentSourceID: "p1",
+			Author:         "Гость",
+			Content:        "Ответ & спам",
+			CreatedAt:      time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC),
+			Hidden:         true,
+			Spam:           true,
+		},
+	}, thread.Comments)
+}
+
+func TestParseWordPress(t *testing.T) {
+	threads, err := ParseWordPress(strings.NewReader(wordPressSample))
+	require.NoError(t, err)
+	require.Len(t, threads, 1, "Черновики не импортируются")
+	thread := threads[0]
+	assert.Equal(t, "10", thread.SourceID)
+	assert.Equal(t, "Текст записи", thread.Content, "Анонс не заменяет текст записи")
+	assert.Equal(t, "admin", thread.Author)
+	assert.False(t, thread.Closed)
+	assert.Equal(t, time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC), thread.CreatedAt)
+	require.Len(t, thread.Comments, 2, "pingback пропускается")
+	assert.Equal(t, Comment{SourceID: "1", Author: "Мария", Content: "Отличная запись", CreatedAt: time.Date(2020, 5, 2, 12, 0, 0, 0, time.UTC)}, thread.Comments[0])
+	assert.Equal(t, "1", thread.Comments[1].ParentSourceID)
+	assert.True(t, thread.Comments[1].Hidden, "Неодобренный комментарий скрыт")
+	assert.Equal(t, time.Date(2020, 5, 2, 16, 0, 0, 0, time.UTC), thread.Comments[1].CreatedAt, "Без времени UTC используется местное")
+}
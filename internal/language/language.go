@@ -0,0 +1,127 @@
+// Package language определяет язык текста постов и комментариев и приводит коды языков к виду ISO 639-1
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minLetters - меньше букв недостаточно, чтобы уверенно определить язык
+const minLetters = 12
+
+// Normalize приводит код языка к основному подтегу в нижнем регистре: "pt-BR" и "PT_br" дают "pt".
+// Возвращает false, если код не похож на код языка из 2-3 латинских букв
+func Normalize(code string) (string, bool) {
+	base, _, _ := strings.Cut(strings.TrimSpace(code), "-")
+	base, _, _ = strings.Cut(base, "_")
+	base = strings.ToLower(base)
+	if len(base) < 2 || len(base) > 3 {
+		return "", false
+	}
+	for _, r := range base {
+		if r < 'a' || r > 'z' {
+			return "", false
+		}
+	}
+	return base, true
+}
+
+// stopwords - частые служебные слова языков на латинице, по которым они различаются
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "with", "for", "this", "was", "you", "not"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "ich", "mit", "auf", "den", "sie", "zu", "auch"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "des", "je", "que", "pas", "pour", "dans", "ce", "avec"},
+	"es": {"el", "la", "los", "las", "y", "es", "un", "una", "que", "de", "no", "por", "para", "con", "del"},
+	"it": {"il", "lo", "gli", "e", "è", "un", "una", "che", "di", "non", "per", "con", "sono", "della", "anche"},
+	"pt": {"o", "os", "as", "e", "é", "um", "uma", "que", "de", "não", "para", "com", "do", "da", "em"},
+}
+
+// Detect определяет язык текста по письменности, а для латиницы и кириллицы - по характерным буквам
+// и служебным словам. Возвращает пустую строку, если текста мало или язык не удалось определить
+func Detect(text string) string {
+	var letters, latin, cyrillic, ukrainian, greek, arabic, hebrew, kana, hangul, han int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian++
+			}
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Hebrew, r):
+			hebrew++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		}
+	}
+	// Иероглифы информативнее букв: десятка знаков хватает, чтобы определить язык
+	switch {
+	case kana > 0 && kana+han > letters/2:
+		return "ja"
+	case hangul > letters/2 && hangul > 0:
+		return "ko"
+	case han > letters/2 && han >= minLetters/2:
+		return "zh"
+	}
+	if letters < minLetters {
+		return ""
+	}
+	switch {
+	case cyrillic > letters/2:
+		if ukrainian > 0 {
+			return "uk"
+		}
+		return "ru"
+	case greek > letters/2:
+		return "el"
+	case arabic > letters/2:
+		return "ar"
+	case hebrew > letters/2:
+		return "he"
+	case latin > letters/2:
+		return detectLatin(text)
+	}
+	return ""
+}
+
+// detectLatin выбирает язык на латинице с наибольшим числом служебных слов; при равенстве язык не определяется
+func detectLatin(text string) string {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for lang, words := range stopwords {
+			for _, w := range words {
+				if w == word {
+					counts[lang]++
+				}
+			}
+		}
+	}
+	best, bestCount, tie := "", 0, false
+	for lang, count := range counts {
+		switch {
+		case count > bestCount:
+			best, bestCount, tie = lang, count, false
+		case count == bestCount:
+			tie = true
+		}
+	}
+	if tie || bestCount < 2 {
+		return ""
+	}
+	return best
+}
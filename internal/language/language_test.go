@@ -0,0 +1,43 @@
+package language
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Сегодня обсуждаем новую версию сервиса и её ограничения", "ru"},
+		{"Сьогодні ми обговорюємо нову версію сервісу", "uk"},
+		{"This is the first post about the new release of the service", "en"},
+		{"Das ist nicht der erste Beitrag und auch nicht der letzte", "de"},
+		{"Je pense que le service est prêt pour la production", "fr"},
+		{"El servicio está listo para la producción y no tiene errores", "es"},
+		{"Σήμερα συζητάμε τη νέα έκδοση της υπηρεσίας", "el"},
+		{"今日は新しいバージョンについて話します", "ja"},
+		{"오늘은 새로운 버전에 대해 이야기합니다", "ko"},
+		{"今天我们讨论服务的新版本", "zh"},
+		{"Привет", ""},
+		{"Lorem ipsum dolor sit amet consectetur", ""},
+		{"12345 !!! ---", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Detect(tt.text), tt.text)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for input, want := range map[string]string{"ru": "ru", "EN": "en", "pt-BR": "pt", "zh_Hans": "zh", " de ": "de", "fil": "fil"} {
+		got, ok := Normalize(input)
+		assert.True(t, ok, input)
+		assert.Equal(t, want, got, input)
+	}
+	for _, input := range []string{"", "r", "russian", "р1", "12"} {
+		_, ok := Normalize(input)
+		assert.False(t, ok, input)
+	}
+}
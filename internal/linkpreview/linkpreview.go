@@ -0,0 +1,282 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"golang.org/x/net/html"
+)
+
+// maxBodySize ограничивает объём читаемой страницы
+const maxBodySize = 1 << 20
+
+// maxURLsPerPost ограничивает количество ссылок, обрабатываемых для одного поста
+const maxURLsPerPost = 5
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'()]+`)
+
+// Options задаёт параметры извлечения превью
+type Options struct {
+	Timeout      time.Duration
+	Workers      int
+	QueueSize    int
+	AllowDomains []string
+	DenyDomains  []string
+	// Clock - источник времени загрузки превью, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+// Service в фоне загружает Open Graph метаданные для ссылок из постов
+type Service struct {
+	storage storage.Storage
+	client  *http.Client
+	opts    Options
+	jobs    chan *models.Post
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// New создаёт сервис и запускает фоновые обработчики
+func New(store storage.Storage, opts Options) *Service {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+	log.Printf("Создание LinkPreview Service: workers=%d, timeout=%v", opts.Workers, opts.Timeout)
+	s := &Service{
+		storage: store,
+		client:  newClient(opts.Timeout),
+		opts:    opts,
+		jobs:    make(chan *models.Post, opts.QueueSize),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// newClient создаёт HTTP-клиент, который не ходит на внутренние адреса
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+				return fmt.Errorf("address %s is not allowed", address)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+// Enqueue ставит пост в очередь на извлечение превью; при переполнении очереди пост пропускается
+func (s *Service) Enqueue(post *models.Post) {
+	select {
+	case s.jobs <- post:
+		log.Printf("Пост %s поставлен в очередь LinkPreview", post.ID)
+	default:
+		log.Printf("Очередь LinkPreview переполнена, пост %s пропущен", post.ID)
+	}
+}
+
+// Close останавливает обработчики после завершения текущих задач
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.jobs)
+		s.wg.Wait()
+		log.Println("LinkPreview Service остановлен")
+	})
+}
+
+func (s *Service) worker() {
+	defer s.wg.Done()
+	for post := range s.jobs {
+		s.process(post)
+	}
+}
+
+// process загружает превью для всех разрешённых ссылок поста
+func (s *Service) process(post *models.Post) {
+	for _, link := range s.ExtractURLs(post.Content) {
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+		preview, err := s.Fetch(ctx, link)
+		cancel()
+		if err != nil {
+			log.Printf("Ошибка извлечения превью %s для поста %s: %v", link, post.ID, err)
+			continue
+		}
+		preview.PostID = post.ID
+		if err := s.storage.SaveLinkPreview(context.Background(), preview); err != nil {
+			log.Printf("Ошибка сохранения превью %s для поста %s: %v", link, post.ID, err)
+		}
+	}
+}
+
+// ExtractURLs возвращает уникальные разрешённые ссылки из текста
+func (s *Service) ExtractURLs(text string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, raw := range urlPattern.FindAllString(text, -1) {
+		raw = strings.TrimRight(raw, ".,;:!?")
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		parsed, err := url.Parse(raw)
+		if err != nil || !s.domainAllowed(parsed.Hostname()) {
+			continue
+		}
+		urls = append(urls, raw)
+		if len(urls) == maxURLsPerPost {
+			break
+		}
+	}
+	return urls
+}
+
+// domainAllowed проверяет домен по спискам запрета и разрешения (с учётом поддоменов)
+func (s *Service) domainAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, d := range s.opts.DenyDomains {
+		if matchDomain(host, d) {
+			return false
+		}
+	}
+	if len(s.opts.AllowDomains) == 0 {
+		return true
+	}
+	for _, d := range s.opts.AllowDomains {
+		if matchDomain(host, d) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchDomain(host string, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// Fetch загружает страницу и извлекает Open Graph метаданные
+func (s *Service) Fetch(ctx context.Context, link string) (*models.LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("User-Agent", "system-linkpreview/1.0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", link, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d for %s", resp.StatusCode, link)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "text/html") {
+		return nil, fmt.Errorf("unsupported content type %s for %s", ct, link)
+	}
+	preview, err := Parse(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	preview.URL = link
+	preview.FetchedAt = s.opts.Clock.Now()
+	return preview, nil
+}
+
+// Parse извлекает og:title, og:description, og:image и og:site_name из HTML;
+// при отсутствии og:title используется <title>
+func Parse(r io.Reader) (*models.LinkPreview, error) {
+	preview := &models.LinkPreview{}
+	var pageTitle string
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				if preview.Title == "" {
+					preview.Title = strings.TrimSpace(pageTitle)
+				}
+				return preview, nil
+			}
+			return nil, fmt.Errorf("failed to parse html: %v", tokenizer.Err())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				inTitle = true
+			case "meta":
+				applyMeta(preview, token.Attr)
+			case "body":
+				// Метаданные находятся в <head>, тело страницы не разбираем
+				if preview.Title == "" {
+					preview.Title = strings.TrimSpace(pageTitle)
+				}
+				return preview, nil
+			}
+		case html.TextToken:
+			if inTitle {
+				pageTitle += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().Data == "title" {
+				inTitle = false
+			}
+		}
+	}
+}
+
+func applyMeta(preview *models.LinkPreview, attrs []html.Attribute) {
+	var property, content string
+	for _, attr := range attrs {
+		switch attr.Key {
+		case "property", "name":
+			property = attr.Val
+		case "content":
+			content = strings.TrimSpace(attr.Val)
+		}
+	}
+	switch property {
+	case "og:title":
+		preview.Title = content
+	case "og:description":
+		preview.Description = content
+	case "og:image":
+		preview.ImageURL = content
+	case "og:site_name":
+		preview.SiteName = content
+	}
+}
@@ -0,0 +1,89 @@
+package linkpreview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPage = `<html><head>
+<title>Заголовок страницы</title>
+<meta property="og:title" content="OG заголовок">
+<meta property="og:description" content="Описание">
+<meta property="og:image" content="https://example.com/image.png">
+<meta property="og:site_name" content="Example">
+</head><body><meta property="og:title" content="не из head"></body></html>`
+
+func TestParse(t *testing.T) {
+	preview, err := Parse(strings.NewReader(testPage))
+	assert.NoError(t, err)
+	assert.Equal(t, "OG заголовок", preview.Title)
+	assert.Equal(t, "Описание", preview.Description)
+	assert.Equal(t, "https://example.com/image.png", preview.ImageURL)
+	assert.Equal(t, "Example", preview.SiteName)
+
+	// Без og:title используется <title>
+	preview, err = Parse(strings.NewReader("<html><head><title> Просто заголовок </title></head></html>"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Просто заголовок", preview.Title)
+}
+
+func TestExtractURLs(t *testing.T) {
+	s := &Service{opts: Options{DenyDomains: []string{"bad.com"}}}
+	text := "Смотри https://example.com/a, https://sub.bad.com/x и снова https://example.com/a. Ещё http://go.dev"
+	assert.Equal(t, []string{"https://example.com/a", "http://go.dev"}, s.ExtractURLs(text))
+
+	s = &Service{opts: Options{AllowDomains: []string{"go.dev"}}}
+	assert.Equal(t, []string{"http://go.dev"}, s.ExtractURLs(text))
+}
+
+func TestService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, testPage)
+	}))
+	defer server.Close()
+
+	store := memory.New()
+	ctx := context.Background()
+	post := &models.Post{
+		ID:        "post1",
+		Title:     "Пост со ссылкой",
+		Content:   "Читайте " + server.URL + "/article",
+		AuthorID:  "user1",
+		CreatedAt: time.Now(),
+	}
+	assert.NoError(t, store.CreatePost(ctx, post))
+
+	service := New(store, Options{Timeout: time.Second, Workers: 1})
+	// Тестовый сервер слушает loopback, поэтому используем его клиент без проверки адресов
+	service.client = server.Client()
+	service.Enqueue(post)
+	service.Close()
+
+	previews, err := store.GetLinkPreviews(ctx, post.ID)
+	assert.NoError(t, err)
+	assert.Len(t, previews, 1)
+	assert.Equal(t, server.URL+"/article", previews[0].URL)
+	assert.Equal(t, "OG заголовок", previews[0].Title)
+}
+
+func TestFetch_PrivateAddressRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testPage)
+	}))
+	defer server.Close()
+
+	service := New(memory.New(), Options{Timeout: time.Second})
+	defer service.Close()
+	_, err := service.Fetch(context.Background(), server.URL)
+	assert.Error(t, err, "Запросы к loopback-адресам должны отклоняться")
+}
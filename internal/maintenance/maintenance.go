@@ -0,0 +1,106 @@
+// Package maintenance реализует режим обслуживания: на время миграций и переключений базы мутации
+// отклоняются с кодом MAINTENANCE, а запросы и подписки продолжают выполняться
+package maintenance
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ToggleField - мутация, которая выполняется и в режиме обслуживания, чтобы его можно было выключить
+const ToggleField = "setMaintenanceMode"
+
+// Mode - переключатель режима обслуживания; подключается к GraphQL-серверу как расширение
+type Mode struct {
+	enabled atomic.Bool
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = &Mode{}
+
+// New создаёт переключатель в заданном состоянии
+func New(enabled bool) *Mode {
+	m := &Mode{}
+	m.Set(enabled)
+	return m
+}
+
+// Enabled сообщает, включён ли режим обслуживания
+func (m *Mode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set включает или выключает режим обслуживания
+func (m *Mode) Set(enabled bool) {
+	m.changed(m.enabled.Swap(enabled), enabled)
+}
+
+// Toggle переключает режим обслуживания и возвращает новое состояние
+func (m *Mode) Toggle() bool {
+	for {
+		enabled := m.Enabled()
+		if m.enabled.CompareAndSwap(enabled, !enabled) {
+			m.changed(enabled, !enabled)
+			return !enabled
+		}
+	}
+}
+
+// changed пишет смену режима в лог и метрику
+func (m *Mode) changed(previous, enabled bool) {
+	if previous != enabled {
+		log.Printf("Режим обслуживания: %t", enabled)
+	}
+	value := 0.0
+	if enabled {
+		value = 1
+	}
+	metrics.MaintenanceMode.Set(value)
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (m *Mode) ExtensionName() string {
+	return "MaintenanceMode"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (m *Mode) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationContext отклоняет мутации в режиме обслуживания. Пропускается только операция,
+// все поля которой - ToggleField, иначе режим нельзя было бы выключить через API
+func (m *Mode) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if !m.Enabled() || rc.Operation == nil || rc.Operation.Operation != ast.Mutation {
+		return nil
+	}
+	if onlyToggle(rc.Operation.SelectionSet) {
+		return nil
+	}
+	log.Printf("Мутация %s отклонена: включён режим обслуживания", rc.OperationName)
+	err := gqlerror.Errorf("server is in maintenance mode, mutations are temporarily disabled")
+	errcode.Set(err, gqlerrors.CodeMaintenance)
+	return err
+}
+
+// onlyToggle сообщает, что операция состоит только из ToggleField и __typename. Фрагменты не разбираются
+// и считаются обычными мутациями
+func onlyToggle(selections ast.SelectionSet) bool {
+	for _, selection := range selections {
+		field, ok := selection.(*ast.Field)
+		if !ok || (field.Name != ToggleField && field.Name != "__typename") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// operation разбирает документ с одной операцией
+func operation(t *testing.T, query string) *graphql.OperationContext {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	require.NoError(t, err)
+	return &graphql.OperationContext{Operation: doc.Operations[0]}
+}
+
+func TestMode(t *testing.T) {
+	ctx := context.Background()
+	mutation := operation(t, `mutation { createCategory(name: "Наука") { id } }`)
+	query := operation(t, `query { posts(limit: 10) { totalCount } }`)
+	subscription := operation(t, `subscription { commentAdded(postId: "1") { id } }`)
+	toggle := operation(t, `mutation { setMaintenanceMode(enabled: false) __typename }`)
+	mixed := operation(t, `mutation { setMaintenanceMode(enabled: false) createCategory(name: "Наука") { id } }`)
+	fragment := operation(t, `mutation { ... on Mutation { createCategory(name: "Наука") { id } } }`)
+
+	mode := New(false)
+	assert.Nil(t, mode.MutateOperationContext(ctx, mutation), "Вне режима обслуживания мутации выполняются")
+
+	assert.True(t, mode.Toggle())
+	assert.True(t, mode.Enabled())
+	err := mode.MutateOperationContext(ctx, mutation)
+	require.NotNil(t, err)
+	assert.Equal(t, gqlerrors.CodeMaintenance, err.Extensions["code"])
+	assert.NotNil(t, mode.MutateOperationContext(ctx, mixed), "Вместе с выключением режима другие мутации не выполняются")
+	assert.NotNil(t, mode.MutateOperationContext(ctx, fragment))
+	assert.Nil(t, mode.MutateOperationContext(ctx, query))
+	assert.Nil(t, mode.MutateOperationContext(ctx, subscription))
+	assert.Nil(t, mode.MutateOperationContext(ctx, toggle), "Режим можно выключить через API")
+
+	mode.Set(false)
+	assert.False(t, mode.Enabled())
+	assert.Nil(t, mode.MutateOperationContext(ctx, mutation))
+}
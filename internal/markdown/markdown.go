@@ -0,0 +1,83 @@
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/models"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// Renderer преобразует содержимое постов и комментариев в безопасный HTML
+// и кеширует результат по хешу исходного текста
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	cache  *lru.Cache[string, string]
+}
+
+// New создаёт Renderer с кешем на cacheSize записей
+func New(cacheSize int) *Renderer {
+	log.Printf("Создание Markdown Renderer, размер кеша: %d", cacheSize)
+	if cacheSize <= 0 {
+		cacheSize = 1000
+	}
+	cache, _ := lru.New[string, string](cacheSize)
+	return &Renderer{
+		// goldmark по умолчанию не пропускает сырой HTML
+		md:     goldmark.New(),
+		policy: newPolicy(),
+		cache:  cache,
+	}
+}
+
+// newPolicy разрешает только базовое форматирование текста, ссылки и код
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "em", "strong", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowStandardURLs()
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render возвращает HTML для содержимого в заданном формате
+func (r *Renderer) Render(format string, content string) (string, error) {
+	key := cacheKey(format, content)
+	if cached, ok := r.cache.Get(key); ok {
+		return cached, nil
+	}
+
+	var rendered string
+	switch format {
+	case models.FormatMarkdown:
+		var buf bytes.Buffer
+		if err := r.md.Convert([]byte(content), &buf); err != nil {
+			log.Printf("Ошибка рендеринга Markdown: %v", err)
+			return "", fmt.Errorf("failed to render markdown: %v", err)
+		}
+		rendered = r.policy.Sanitize(buf.String())
+	case models.FormatPlain, "":
+		rendered = strings.ReplaceAll(html.EscapeString(content), "\n", "<br>")
+	default:
+		return "", fmt.Errorf("unknown content format: %s", format)
+	}
+
+	r.cache.Add(key, rendered)
+	return rendered, nil
+}
+
+func cacheKey(format string, content string) string {
+	sum := sha256.Sum256([]byte(format + "\x00" + content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,63 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	renderer := New(10)
+
+	t.Run("Markdown", func(t *testing.T) {
+		html, err := renderer.Render(models.FormatMarkdown, "**жирный** и [ссылка](https://example.com)")
+		assert.NoError(t, err)
+		assert.Contains(t, html, "<strong>жирный</strong>")
+		assert.Contains(t, html, `href="https://example.com"`)
+		assert.Contains(t, html, "nofollow")
+	})
+
+	t.Run("Scripts are removed", func(t *testing.T) {
+		html, err := renderer.Render(models.FormatMarkdown, "текст <script>alert(1)</script> [x](javascript:alert(1))")
+		assert.NoError(t, err)
+		assert.NotContains(t, html, "<script")
+		assert.NotContains(t, html, "javascript:")
+	})
+
+	t.Run("Images are not allowed", func(t *testing.T) {
+		html, err := renderer.Render(models.FormatMarkdown, "![img](https://example.com/a.png)")
+		assert.NoError(t, err)
+		assert.NotContains(t, html, "<img")
+	})
+
+	t.Run("Plain text is escaped", func(t *testing.T) {
+		html, err := renderer.Render(models.FormatPlain, "<b>не жирный</b>\nвторая строка")
+		assert.NoError(t, err)
+		assert.Equal(t, "&lt;b&gt;не жирный&lt;/b&gt;<br>вторая строка", html)
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := renderer.Render("HTML", "<p>текст</p>")
+		assert.Error(t, err)
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		cached := New(10)
+		first, err := cached.Render(models.FormatMarkdown, "# заголовок")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, cached.cache.Len())
+		second, err := cached.Render(models.FormatMarkdown, "# заголовок")
+		assert.NoError(t, err)
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, cached.cache.Len())
+	})
+}
+
+func TestPlainText(t *testing.T) {
+	content := "# Заголовок\n\n**Жирный** текст со [ссылкой](https://example.com) и `кодом`.\n\n" +
+		"```go\nfmt.Println()\n```\n\n- пункт\n- <b>второй</b>\n\n![img](https://example.com/a.png) <https://example.org>"
+	assert.Equal(t, "Заголовок\nЖирный текст со ссылкой и кодом.\nпункт\nвторой\nhttps://example.org",
+		PlainText(models.FormatMarkdown, content))
+	assert.Equal(t, "**не разметка**", PlainText(models.FormatPlain, "**не разметка**"))
+}
@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// PlainText возвращает текст содержимого без разметки, например для анонсов. У Markdown остаются
+// текст абзацев, заголовков, списков, цитат и ссылок; блоки кода, HTML и изображения отбрасываются,
+// а блоки разделяются одним переводом строки. Содержимое в других форматах возвращается как есть
+func PlainText(format, content string) string {
+	if format != models.FormatMarkdown {
+		return content
+	}
+	source := []byte(content)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(source))
+	var b strings.Builder
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock, *ast.RawHTML, *ast.Image:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if entering {
+				b.Write(n.Segment.Value(source))
+				if n.SoftLineBreak() || n.HardLineBreak() {
+					b.WriteByte(' ')
+				}
+			}
+		case *ast.String:
+			if entering {
+				b.Write(n.Value)
+			}
+		case *ast.AutoLink:
+			if entering {
+				b.Write(n.Label(source))
+			}
+		}
+		if !entering && n.Type() == ast.TypeBlock && n.Kind() != ast.KindDocument {
+			b.WriteByte('\n')
+		}
+		return ast.WalkContinue, nil
+	})
+	// Вложенные блоки вроде абзаца в пункте списка дают пустые строки, они убираются
+	lines := strings.Split(b.String(), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
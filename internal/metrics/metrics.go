@@ -0,0 +1,312 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DBQueryTimeouts считает запросы к базе, прерванные по таймауту
+var DBQueryTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "db_query_timeouts_total",
+	Help: "Количество запросов к базе данных, прерванных по таймауту",
+}, []string{"operation"})
+
+// CacheRequests считает обращения к кешам с результатом hit, miss или bypass
+var CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_requests_total",
+	Help: "Количество обращений к кешу по результату",
+}, []string{"cache", "result"})
+
+// QuotaRejections считает действия, отклонённые из-за исчерпанной квоты пользователя
+var QuotaRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "quota_rejections_total",
+	Help: "Количество действий, отклонённых по квоте",
+}, []string{"action", "role"})
+
+// TenantQuotaRejections считает посты и комментарии, отклонённые из-за исчерпанной квоты хранилища сообщества
+var TenantQuotaRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "tenant_quota_rejections_total",
+	Help: "Количество записей, отклонённых по квоте хранилища сообщества",
+}, []string{"resource"})
+
+// ModerationVerdicts считает срабатывания правил автомодерации по итоговому действию
+var ModerationVerdicts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "moderation_verdicts_total",
+	Help: "Количество постов и комментариев, к которым применено действие автомодерации",
+}, []string{"action"})
+
+// SpamChecks считает проверки комментариев на спам по результату: HAM, SPAM или error
+var SpamChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "spam_checks_total",
+	Help: "Количество проверок комментариев на спам",
+}, []string{"result"})
+
+// ToxicityChecks считает оценки токсичности комментариев по результату: ok, held, error или dropped
+var ToxicityChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "toxicity_checks_total",
+	Help: "Количество оценок токсичности комментариев",
+}, []string{"result"})
+
+// PostSummaries считает краткие содержания постов от внешнего сервиса по результату: ok, empty, error или dropped
+var PostSummaries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "post_summaries_total",
+	Help: "Количество запросов кратких содержаний постов",
+}, []string{"result"})
+
+// DuplicateChecks считает поиски постов с похожим заголовком по результату: unique, similar, blocked или error.
+// blocked учитывается вместе с similar, когда createPost отклоняет пост
+var DuplicateChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "post_duplicate_checks_total",
+	Help: "Количество поисков постов с похожим заголовком",
+}, []string{"result"})
+
+// ToxicityScores - распределение полученных оценок токсичности
+var ToxicityScores = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "toxicity_score",
+	Help:    "Оценки токсичности комментариев",
+	Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+})
+
+// StormActive - число ключей, по которым сейчас идёт шторм: comment_writes для вставок комментариев,
+// subscription_events для событий подписок по постам
+var StormActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "storm_active",
+	Help: "Количество ключей в режиме шторма",
+}, []string{"scope"})
+
+// CoalescedBatchSize - размер пачек комментариев, вставленных одной записью во время шторма
+var CoalescedBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "comment_coalesced_batch_size",
+	Help:    "Размер пачек комментариев, объединённых во время шторма",
+	Buckets: prometheus.ExponentialBuckets(1, 2, 8),
+})
+
+// SubscriptionDigests считает сводки событий подписок, разосланные во время шторма
+var SubscriptionDigests = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "subscription_digests_total",
+	Help: "Количество сводок комментариев, разосланных подписчикам во время шторма",
+})
+
+// SubscriptionDigestSkipped считает комментарии, не попавшие в сводки из-за ограничения их размера
+var SubscriptionDigestSkipped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "subscription_digest_skipped_total",
+	Help: "Количество комментариев, пропущенных в сводках подписок во время шторма",
+})
+
+// WriteBehindWrites считает вставки комментариев через буфер отложенной записи: buffered - через очередь,
+// sync - сразу, потому что очередь заполнена или журнал недоступен
+var WriteBehindWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "writebehind_writes_total",
+	Help: "Количество вставок комментариев через буфер отложенной записи",
+}, []string{"mode"})
+
+// WriteBehindFlushed считает комментарии из очереди по результату записи: ok, dropped или retry
+var WriteBehindFlushed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "writebehind_flushed_total",
+	Help: "Количество комментариев, записанных из очереди отложенной записи",
+}, []string{"result"})
+
+// WriteBehindLatency - время от приёма комментария до его сохранения в хранилище по способу записи
+var WriteBehindLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "writebehind_latency_seconds",
+	Help:    "Время от приёма комментария до сохранения в хранилище",
+	Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+}, []string{"mode"})
+
+// WriteBehindQueueDepth - число комментариев, ожидающих записи
+var WriteBehindQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "writebehind_queue_depth",
+	Help: "Количество комментариев в очереди отложенной записи",
+})
+
+// PartialFields считает поля, затронутые режимом лучшего усилия у срока запроса: estimated или skipped
+var PartialFields = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "graphql_partial_fields_total",
+	Help: "Количество полей с приблизительным или пропущенным значением из-за срока запроса",
+}, []string{"kind"})
+
+// ClusterForwarded считает события подписок, пересланные соседним узлам, по результату: sent, error или dropped
+var ClusterForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cluster_events_forwarded_total",
+	Help: "Количество событий подписок, пересланных соседним узлам",
+}, []string{"result"})
+
+// ClusterReceived считает события подписок, принятые от соседних узлов
+var ClusterReceived = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "cluster_events_received_total",
+	Help: "Количество событий подписок, принятых от соседних узлов",
+})
+
+// DigestEmails считает сводки новых комментариев по результату отправки: sent или error
+var DigestEmails = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "digest_emails_total",
+	Help: "Количество сводок новых комментариев, отправленных подписчикам постов",
+}, []string{"result"})
+
+// SearchAlertEmails считает письма о новых постах по сохранённым поискам по результату отправки: sent или error
+var SearchAlertEmails = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "search_alert_emails_total",
+	Help: "Количество писем о новых постах по сохранённым поискам",
+}, []string{"result"})
+
+// PushDeliveries считает push-уведомления по результату: sent, error или invalid_token
+var PushDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "push_deliveries_total",
+	Help: "Количество push-уведомлений, отправленных на устройства пользователей",
+}, []string{"result"})
+
+// FeatureFlagEvaluations считает вычисления флагов в запросах по результату: on или off
+var FeatureFlagEvaluations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "feature_flag_evaluations_total",
+	Help: "Количество запросов, в которых вычислен флаг, по его значению",
+}, []string{"flag", "result"})
+
+// MaintenanceMode равна 1, пока включён режим обслуживания и мутации отклоняются
+var MaintenanceMode = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "maintenance_mode",
+	Help: "Включён ли режим обслуживания: 1 - да, 0 - нет",
+})
+
+// FaultsInjected считает сбои, внедрённые для проверки устойчивости, по цели (storage, http) и виду: error или latency
+var FaultsInjected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "faults_injected_total",
+	Help: "Количество внедрённых ошибок и задержек",
+}, []string{"target", "kind"})
+
+// CostBudgetRejections считает операции, отклонённые из-за исчерпанного бюджета стоимости
+var CostBudgetRejections = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "graphql_cost_budget_rejections_total",
+	Help: "Количество операций, отклонённых из-за исчерпанного бюджета стоимости",
+})
+
+// Translations считает обращения к сервису машинного перевода по результату: translated или error
+var Translations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "translations_total",
+	Help: "Количество переводов содержимого сервисом машинного перевода",
+}, []string{"result"})
+
+// PurgedContent считает посты и комментарии, обезличенные по запросам на удаление данных пользователя, по виду: POST или COMMENT
+var PurgedContent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "purged_content_total",
+	Help: "Количество обезличенных постов и комментариев",
+}, []string{"kind"})
+
+// ArchivedComments считает комментарии, перенесённые в архивную таблицу
+var ArchivedComments = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "archived_comments_total",
+	Help: "Количество комментариев, перенесённых в архив",
+})
+
+// WebsocketClosed считает WebSocket-соединения, закрытые сервером, по причине: shutdown, token_expired или admin
+var WebsocketClosed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "websocket_closed_total",
+	Help: "Количество WebSocket-соединений, закрытых сервером",
+}, []string{"reason"})
+
+// WebsocketConnections - количество открытых инициализированных WebSocket-соединений
+var WebsocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "websocket_connections",
+	Help: "Количество открытых WebSocket-соединений",
+})
+
+// WebsocketConnectionDuration - длительность WebSocket-соединений, замеряемая при их закрытии
+var WebsocketConnectionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "websocket_connection_duration_seconds",
+	Help:    "Длительность WebSocket-соединений",
+	Buckets: []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 24 * 3600},
+})
+
+// SubscriptionsActive - количество активных подписок по виду: comment_added или user_typing
+var SubscriptionsActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "subscriptions_active",
+	Help: "Количество активных GraphQL-подписок",
+}, []string{"kind"})
+
+// SLIOperations считает завершённые GraphQL-операции по классу: query или mutation
+var SLIOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sli_operations_total",
+	Help: "Количество GraphQL-операций, учтённых в SLI",
+}, []string{"class"})
+
+// SLIAvailableOperations считает операции класса, завершённые без ошибок сервера
+var SLIAvailableOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sli_operations_available_total",
+	Help: "Количество GraphQL-операций без ошибок сервера",
+}, []string{"class"})
+
+// SLIFastOperations считает операции класса, выполненные быстрее порога задержки класса
+var SLIFastOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sli_operations_fast_total",
+	Help: "Количество GraphQL-операций, выполненных быстрее порога задержки",
+}, []string{"class"})
+
+// SLOObjective - цель класса операций по SLI availability или latency
+var SLOObjective = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slo_objective",
+	Help: "Целевая доля хороших операций",
+}, []string{"class", "sli"})
+
+// SLOErrorBudget - бюджет ошибок класса операций по SLI: допустимая доля плохих операций, 1 - slo_objective
+var SLOErrorBudget = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slo_error_budget",
+	Help: "Допустимая доля плохих операций",
+}, []string{"class", "sli"})
+
+// SLOLatencyThreshold - порог задержки класса операций для SLI latency
+var SLOLatencyThreshold = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slo_latency_threshold_seconds",
+	Help: "Порог задержки, быстрее которого операция считается хорошей",
+}, []string{"class"})
+
+// GCBallastBytes - размер балласта кучи, см. пакет gctuning
+var GCBallastBytes = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "gc_ballast_bytes",
+	Help: "Размер балласта кучи в байтах",
+})
+
+// RequestGCPause - оценка суммарной длительности пауз GC, пришедшихся на HTTP-запрос
+var RequestGCPause = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "http_request_gc_pause_seconds",
+	Help:    "Суммарная длительность пауз сборщика мусора во время запроса",
+	Buckets: []float64{0, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1},
+})
+
+// SearchIndexed считает посты, переданные поисковому движку, по источнику: incremental или reindex и результату: indexed или error
+var SearchIndexed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "search_indexed_posts_total",
+	Help: "Количество постов, переданных в поисковый индекс",
+}, []string{"source", "result"})
+
+// SearchIndexDropped считает изменения постов, не попавшие в очередь индексации из-за её переполнения
+var SearchIndexDropped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "search_index_dropped_total",
+	Help: "Количество изменений постов, пропущенных переполненной очередью индексации",
+})
+
+// SearchQueries считает поиски постов по тексту по результату: engine или fallback, когда движок недоступен
+// и поиск выполнен условиями хранилища
+var SearchQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "search_queries_total",
+	Help: "Количество поисков постов по тексту",
+}, []string{"engine", "result"})
+
+// RankingAssignments считает назначения стратегий ранжирования комментариев по стратегии и источнику:
+// request, experiment или tenant
+var RankingAssignments = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ranking_assignments_total",
+	Help: "Количество назначений стратегий ранжирования комментариев",
+}, []string{"strategy", "source"})
+
+// RankingFallbacks считает случаи, когда стратегия ранжирования была недоступна и использовалась best
+var RankingFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ranking_fallbacks_total",
+	Help: "Количество замен недоступной стратегии ранжирования на best",
+}, []string{"strategy"})
+
+// Handler возвращает HTTP-обработчик для /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
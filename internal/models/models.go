@@ -1,14 +1,105 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Форматы содержимого постов и комментариев
+const (
+	FormatPlain    = "PLAIN"
+	FormatMarkdown = "MARKDOWN"
+)
+
+// SortOrder - порядок сортировки: по времени создания или по оценке голосов
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "ASC"
+	SortDesc SortOrder = "DESC"
+	// SortBest - сначала комментарии с наибольшей оценкой WilsonScore, при равенстве - новые
+	SortBest SortOrder = "BEST"
+)
 
 type Post struct {
 	ID            string    `json:"id"`
 	Title         string    `json:"title"`
 	Content       string    `json:"content"`
+	Format        string    `json:"format"`
 	AuthorID      string    `json:"authorId"`
 	AllowComments bool      `json:"allowComments"`
 	CreatedAt     time.Time `json:"createdAt"`
+	// Hidden - пост на проверке модератором, виден только автору
+	Hidden bool     `json:"hidden"`
+	Tags   []string `json:"tags"`
+	// CategoryID - категория поста; nil, если пост не отнесён к категории
+	CategoryID *string `json:"categoryId"`
+	// Slug - текущий адрес поста для URL; прежние slug после смены заголовка продолжают вести на пост
+	Slug string `json:"slug"`
+	// Language - код языка ISO 639-1, указанный автором или определённый по тексту; пустая строка, если язык неизвестен
+	Language string `json:"language"`
+	// Visibility - кому виден пост кроме автора; пустая строка означает VisibilityPublic
+	Visibility string `json:"visibility"`
+	// RequireApproval - премодерация: новые комментарии скрыты со статусом ApprovalPending до решения модератора
+	RequireApproval bool `json:"requireApproval"`
+	// Excerpt - анонс для списков постов: начало текста без разметки или краткое содержание от внешнего сервиса.
+	// Пустой у постов, созданных до появления анонсов
+	Excerpt   string `json:"excerpt"`
+	createdAt rfc3339
+}
+
+// PrecomputeTimes заранее форматирует время поста для ответов. Вызывается до того, как пост станет
+// доступен другим горутинам, например при сохранении в хранилище в памяти или в кеш
+func (p *Post) PrecomputeTimes() {
+	p.createdAt = newRFC3339(p.CreatedAt)
+}
+
+// CreatedAtRFC3339 возвращает CreatedAt в RFC3339; заранее отформатированное время используется, пока CreatedAt не менялось
+func (p *Post) CreatedAtRFC3339() string {
+	return p.createdAt.format(p.CreatedAt)
+}
+
+// rfc3339 - время, отформатированное в RFC3339 заранее
+type rfc3339 struct {
+	at   time.Time
+	text string
+}
+
+func newRFC3339(t time.Time) rfc3339 {
+	return rfc3339{at: t, text: t.Format(time.RFC3339)}
+}
+
+// format возвращает t в RFC3339 без повторного форматирования, если t совпадает с отформатированным временем
+func (r rfc3339) format(t time.Time) string {
+	if r.text != "" && r.at == t {
+		return r.text
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Видимость поста
+const (
+	// VisibilityPublic - пост виден всем и попадает в списки постов
+	VisibilityPublic = "PUBLIC"
+	// VisibilityUnlisted - пост виден всем, у кого есть ссылка, но в списки постов не попадает
+	VisibilityUnlisted = "UNLISTED"
+	// VisibilityFollowers - пост виден только подписчикам автора
+	VisibilityFollowers = "FOLLOWERS"
+	// VisibilityPrivate - личная заметка, видна только автору
+	VisibilityPrivate = "PRIVATE"
+)
+
+// Category - узел дерева категорий постов
+type Category struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	ParentID *string `json:"parentId"`
+	// Path - ID категорий от корня до этой включительно через "/"; заполняется хранилищем
+	// и позволяет выбрать всё поддерево одним условием по префиксу
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 type Comment struct {
@@ -17,13 +108,269 @@ type Comment struct {
 	ParentID  *string   `json:"parentId"`
 	AuthorID  string    `json:"authorId"`
 	Content   string    `json:"content"`
+	Format    string    `json:"format"`
 	CreatedAt time.Time `json:"createdAt"`
+	// Hidden - комментарий пользователя под теневым баном, на проверке модератором или спам, виден только автору
+	Hidden bool     `json:"hidden"`
+	Tags   []string `json:"tags"`
+	// SpamStatus - результат проверки на спам; пустая строка, если проверка не включена
+	SpamStatus string `json:"spamStatus"`
+	Upvotes    int    `json:"upvotes"`
+	Downvotes  int    `json:"downvotes"`
+	// Language - код языка ISO 639-1, указанный автором или определённый по тексту; пустая строка, если язык неизвестен
+	Language string `json:"language"`
+	// QuotedCommentID - цитируемый комментарий того же поста; в отличие от ParentID не задаёт место в ветке
+	QuotedCommentID *string `json:"quotedCommentId"`
+	// ApprovalStatus - решение по комментарию к посту с премодерацией; пустая строка для постов без неё
+	ApprovalStatus string `json:"approvalStatus"`
+	// ContentHash - хэш открытого содержимого для дедупликации, если хранилище получает Content
+	// зашифрованным; пустая строка означает, что хэш считается по Content
+	ContentHash string `json:"contentHash,omitempty"`
+	createdAt   rfc3339
+}
+
+// PrecomputeTimes заранее форматирует время комментария для ответов, см. Post.PrecomputeTimes
+func (c *Comment) PrecomputeTimes() {
+	c.createdAt = newRFC3339(c.CreatedAt)
+}
+
+// CreatedAtRFC3339 возвращает CreatedAt в RFC3339, см. Post.CreatedAtRFC3339
+func (c *Comment) CreatedAtRFC3339() string {
+	return c.createdAt.format(c.CreatedAt)
+}
+
+// Vote - голос пользователя за комментарий: 1 - за, -1 - против, 0 снимает голос
+type Vote struct {
+	CommentID string    `json:"commentId"`
+	UserID    string    `json:"userId"`
+	Value     int       `json:"value"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Preferences - настройки пользователя: письма об ответах и упоминаниях, порядок комментариев по умолчанию и язык
+type Preferences struct {
+	UserID         string `json:"userId"`
+	EmailOnReply   bool   `json:"emailOnReply"`
+	EmailOnMention bool   `json:"emailOnMention"`
+	// DefaultCommentSort - порядок комментариев и ответов, если клиент не указал его в запросе
+	DefaultCommentSort SortOrder `json:"defaultCommentSort"`
+	Locale             string    `json:"locale"`
+	// PushOnReply и PushOnMention - push-уведомления на устройства пользователя об ответах и упоминаниях
+	PushOnReply   bool `json:"pushOnReply"`
+	PushOnMention bool `json:"pushOnMention"`
+	// Email - адрес для писем; без него письма пользователю не отправляются
+	Email string `json:"email"`
+	// DigestFrequency - как часто отправлять сводку новых комментариев к постам, на которые подписан пользователь
+	DigestFrequency string    `json:"digestFrequency"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// Частота сводки новых комментариев
+const (
+	DigestNever  = "NEVER"
+	DigestDaily  = "DAILY"
+	DigestWeekly = "WEEKLY"
+)
+
+// Платформы push-уведомлений
+const (
+	PlatformFCM     = "FCM"
+	PlatformAPNs    = "APNS"
+	PlatformWebPush = "WEBPUSH"
+)
+
+// DeviceToken - устройство пользователя, на которое доставляются push-уведомления.
+// Для WEBPUSH токеном служит JSON подписки браузера
+type DeviceToken struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"userId"`
+	Platform  string    `json:"platform"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DigestSubscriber - пользователь, подписанный хотя бы на один пост, и время его последней сводки
+type DigestSubscriber struct {
+	UserID string `json:"userId"`
+	// LastSentAt - граница комментариев, вошедших в последнюю сводку; нулевое, если сводок ещё не было
+	LastSentAt time.Time `json:"lastSentAt"`
+}
+
+// SavedSearch - сохранённый пользователем поиск постов. Если включён Alert, пользователь получает
+// письмо о новых постах, подходящих под Filter
+type SavedSearch struct {
+	ID        string       `json:"id"`
+	UserID    string       `json:"userId"`
+	Name      string       `json:"name"`
+	Filter    SearchFilter `json:"filter"`
+	Alert     bool         `json:"alert"`
+	CreatedAt time.Time    `json:"createdAt"`
+	// CheckedAt - время создания самого нового поста, о котором уже сообщено; новые посты ищутся после него
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Collection - серия постов автора в заданном им порядке, например части одного большого текста
+type Collection struct {
+	ID          string `json:"id"`
+	AuthorID    string `json:"authorId"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// PostIDs - посты серии в порядке чтения
+	PostIDs   []string  `json:"postIds"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SearchFilter - условия сохранённого поиска; пустые поля не ограничивают выборку
+type SearchFilter struct {
+	Text                 string `json:"text,omitempty"`
+	CategoryID           string `json:"categoryId,omitempty"`
+	IncludeSubcategories bool   `json:"includeSubcategories,omitempty"`
+	AuthorID             string `json:"authorId,omitempty"`
+	Tag                  string `json:"tag,omitempty"`
+	HasComments          *bool  `json:"hasComments,omitempty"`
+	AllowComments        *bool  `json:"allowComments,omitempty"`
+	Language             string `json:"language,omitempty"`
+}
+
+// События, о которых пользователю отправляются письма
+const (
+	NotifyReply   = "REPLY"
+	NotifyMention = "MENTION"
+)
+
+// DefaultPreferences возвращает настройки пользователя, который их ещё не менял
+func DefaultPreferences(userID string) *Preferences {
+	return &Preferences{
+		UserID:             userID,
+		EmailOnReply:       true,
+		EmailOnMention:     true,
+		PushOnReply:        true,
+		PushOnMention:      true,
+		DefaultCommentSort: SortDesc,
+		Locale:             "ru",
+		DigestFrequency:    DigestDaily,
+	}
+}
+
+// DefaultTenant - сообщество запросов без заголовка сообщества
+const DefaultTenant = "default"
+
+// Политика для запросов без авторизации
+const (
+	AnonymousAllow    = "ALLOW"
+	AnonymousReadOnly = "READ_ONLY"
+	AnonymousDeny     = "DENY"
+)
+
+// TenantSettings - настройки сообщества: одна установка обслуживает несколько сообществ с разными настройками
+type TenantSettings struct {
+	TenantID string `json:"tenantId"`
+	// DefaultCommentSort - порядок комментариев для пользователей, которые не выбрали свой
+	DefaultCommentSort SortOrder `json:"defaultCommentSort"`
+	// MaxNesting - наибольшая глубина комментариев, 1 - только корневые; 0 - без ограничения
+	MaxNesting      int    `json:"maxNesting"`
+	AnonymousPolicy string `json:"anonymousPolicy"`
+	// Строки оформления, которые клиенты показывают вместо своих
+	SiteName     string    `json:"siteName"`
+	Tagline      string    `json:"tagline"`
+	LogoURL      string    `json:"logoUrl"`
+	PrimaryColor string    `json:"primaryColor"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// TenantUsage - объём содержимого, созданного в сообществе: число постов и комментариев
+// и суммарный размер их заголовков и текстов в байтах
+type TenantUsage struct {
+	TenantID string `json:"tenantId"`
+	Posts    int64  `json:"posts"`
+	Comments int64  `json:"comments"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// StorageStats - состояние хранилища для администраторов: размеры таблиц и самые медленные запросы
+type StorageStats struct {
+	// Backend - тип хранилища: memory или postgres
+	Backend string       `json:"backend"`
+	Tables  []TableStats `json:"tables"`
+	// SlowQueriesAvailable - хранилище ведёт статистику запросов; для PostgreSQL нужно расширение pg_stat_statements
+	SlowQueriesAvailable bool         `json:"slowQueriesAvailable"`
+	SlowQueries          []QueryStats `json:"slowQueries"`
+}
+
+// TableStats - количество строк и занимаемое место таблицы
+type TableStats struct {
+	Name string `json:"name"`
+	// Rows - количество строк; в PostgreSQL это оценка по статистике, а не точный подсчёт
+	Rows       int64 `json:"rows"`
+	DataBytes  int64 `json:"dataBytes"`
+	IndexBytes int64 `json:"indexBytes"`
+}
+
+// QueryStats - накопленная статистика выполнения запроса
+type QueryStats struct {
+	// Query - нормализованный текст запроса с параметрами вместо значений
+	Query     string        `json:"query"`
+	Calls     int64         `json:"calls"`
+	Rows      int64         `json:"rows"`
+	TotalTime time.Duration `json:"totalTime"`
+	MeanTime  time.Duration `json:"meanTime"`
+}
+
+// DefaultTenantSettings возвращает настройки сообщества, которое их ещё не меняло
+func DefaultTenantSettings(tenantID string) *TenantSettings {
+	return &TenantSettings{
+		TenantID:           tenantID,
+		DefaultCommentSort: SortDesc,
+		AnonymousPolicy:    AnonymousAllow,
+	}
 }
 
+// AllowsEmail сообщает, согласен ли пользователь получать письма о событии; доставка уведомлений
+// должна проверять это перед отправкой
+func (p *Preferences) AllowsEmail(event string) bool {
+	switch event {
+	case NotifyReply:
+		return p.EmailOnReply
+	case NotifyMention:
+		return p.EmailOnMention
+	}
+	return false
+}
+
+// AllowsPush сообщает, согласен ли пользователь получать push-уведомления о событии
+func (p *Preferences) AllowsPush(event string) bool {
+	switch event {
+	case NotifyReply:
+		return p.PushOnReply
+	case NotifyMention:
+		return p.PushOnMention
+	}
+	return false
+}
+
+// Статусы проверки комментария на спам
+const (
+	// SpamStatusPending - проверка ещё не выполнена или сервис проверки был недоступен
+	SpamStatusPending = "PENDING"
+	SpamStatusHam     = "HAM"
+	SpamStatusSpam    = "SPAM"
+)
+
+// Статусы премодерации комментария
+const (
+	// ApprovalPending - комментарий ждёт решения модератора и виден только автору
+	ApprovalPending  = "PENDING"
+	ApprovalApproved = "APPROVED"
+	// ApprovalRejected - комментарий отклонён и остаётся виден только автору
+	ApprovalRejected = "REJECTED"
+)
+
 type PaginatedComments struct {
 	Comments   []Comment `json:"comments"`
 	TotalCount int       `json:"totalCount"`
 	NextCursor *string   `json:"nextCursor"`
+	// Estimated - TotalCount взят из счётчика без досчёта скрытых комментариев зрителя, см. storage.WithEstimatedCounts
+	Estimated bool `json:"estimated"`
 }
 
 type PaginatedPosts struct {
@@ -31,3 +378,101 @@ type PaginatedPosts struct {
 	TotalCount int     `json:"totalCount"`
 	NextCursor *string `json:"nextCursor"`
 }
+
+type LinkPreview struct {
+	PostID      string    `json:"postId"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	ImageURL    string    `json:"imageUrl"`
+	SiteName    string    `json:"siteName"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+}
+
+type Reaction struct {
+	TargetID  string    `json:"targetId"`
+	UserID    string    `json:"userId"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// Типы правил автомодерации
+const (
+	RuleKindKeyword = "KEYWORD"
+	RuleKindRegex   = "REGEX"
+)
+
+// Действия правил автомодерации
+const (
+	RuleActionBlock = "BLOCK"
+	RuleActionHold  = "HOLD"
+	RuleActionTag   = "TAG"
+)
+
+// ModerationRule - правило автомодерации: слово, фраза или регулярное выражение и действие при совпадении
+type ModerationRule struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Tag       string    `json:"tag"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Типы содержимого в очереди проверки
+const (
+	TargetPost    = "POST"
+	TargetComment = "COMMENT"
+)
+
+// DeletedUserID - автор постов и комментариев, обезличенных по запросу пользователя на удаление его данных
+const DeletedUserID = "deleted"
+
+// AnonymousAuthorPrefix - префикс ID анонимного автора; за ним следует хэш идентификатора из его cookie
+const AnonymousAuthorPrefix = "anon:"
+
+// AnonymousAuthorID возвращает ID автора для хэша анонимного идентификатора
+func AnonymousAuthorID(identity string) string {
+	return AnonymousAuthorPrefix + identity
+}
+
+// IsAnonymousAuthor сообщает, что authorID принадлежит анонимному автору
+func IsAnonymousAuthor(authorID string) bool {
+	return strings.HasPrefix(authorID, AnonymousAuthorPrefix)
+}
+
+// DisplayAuthorID возвращает автора для показа в обсуждении поста postID. Анонимный автор получает
+// псевдоним вида anon-7f3a2c: постоянный в пределах поста и разный в разных постах, чтобы его
+// комментарии к разным постам нельзя было связать. Остальные ID возвращаются как есть
+func DisplayAuthorID(postID, authorID string) string {
+	if !IsAnonymousAuthor(authorID) {
+		return authorID
+	}
+	sum := sha256.Sum256([]byte(postID + "|" + authorID))
+	return "anon-" + hex.EncodeToString(sum[:3])
+}
+
+// HeldItem - пост или комментарий, задержанный правилом до решения модератора
+type HeldItem struct {
+	ID         string    `json:"id"`
+	TargetType string    `json:"targetType"`
+	TargetID   string    `json:"targetId"`
+	RuleID     string    `json:"ruleId"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ToxicityRuleID - RuleID записи очереди проверки для комментария, задержанного по оценке токсичности
+const ToxicityRuleID = "toxicity"
+
+// ToxicityScore - оценка токсичности комментария от 0 до 1, полученная при последней проверке
+type ToxicityScore struct {
+	CommentID string    `json:"commentId"`
+	Score     float64   `json:"score"`
+	ScoredAt  time.Time `json:"scoredAt"`
+}
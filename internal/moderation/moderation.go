@@ -0,0 +1,204 @@
+// Package moderation проверяет посты и комментарии правилами автомодерации.
+// Правила хранятся в базе и редактируются модераторами; сервис держит их скомпилированную копию
+// и периодически перечитывает, чтобы изменения с других экземпляров тоже применялись
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// RefreshInterval - как долго используются загруженные правила до повторного чтения из хранилища
+	RefreshInterval time.Duration
+	// Clock - источник времени для срока жизни загруженных правил, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = 30 * time.Second
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// severity - строгость действий: из нескольких сработавших правил применяется самое строгое
+var severity = map[string]int{
+	models.RuleActionTag:   1,
+	models.RuleActionHold:  2,
+	models.RuleActionBlock: 3,
+}
+
+// Verdict - результат проверки содержимого
+type Verdict struct {
+	// Action - самое строгое действие сработавших правил или пустая строка
+	Action string
+	// RuleID - правило, определившее Action
+	RuleID string
+	// Tags - теги всех сработавших правил TAG без повторов
+	Tags []string
+}
+
+// Blocked сообщает, что содержимое нужно отклонить
+func (v Verdict) Blocked() bool {
+	return v.Action == models.RuleActionBlock
+}
+
+// Held сообщает, что содержимое нужно скрыть до решения модератора
+func (v Verdict) Held() bool {
+	return v.Action == models.RuleActionHold
+}
+
+// compiledRule - правило вместе с регулярным выражением для проверки
+type compiledRule struct {
+	rule models.ModerationRule
+	re   *regexp.Regexp
+}
+
+// Service проверяет содержимое правилами из хранилища
+type Service struct {
+	store    storage.Storage
+	opts     Options
+	mu       sync.Mutex
+	rules    []compiledRule
+	loadedAt time.Time
+	loaded   bool
+	clock    clock.Clock
+}
+
+// New создаёт сервис автомодерации поверх хранилища правил
+func New(store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Moderation Service: интервал обновления правил=%v", opts.RefreshInterval)
+	return &Service{store: store, opts: opts, clock: opts.Clock}
+}
+
+// Validate проверяет тип, действие и шаблон правила
+func Validate(rule *models.ModerationRule) error {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return fmt.Errorf("pattern must not be empty")
+	}
+	if _, ok := severity[rule.Action]; !ok {
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.Action == models.RuleActionTag && strings.TrimSpace(rule.Tag) == "" {
+		return fmt.Errorf("tag is required for TAG rules")
+	}
+	_, err := compile(rule.Kind, rule.Pattern)
+	return err
+}
+
+// compile превращает шаблон правила в регулярное выражение. Слова и фразы ищутся без учёта
+// регистра целиком, а не как часть другого слова; пробелы во фразе совпадают с любыми пробелами
+func compile(kind, pattern string) (*regexp.Regexp, error) {
+	switch kind {
+	case models.RuleKindKeyword:
+		words := strings.Fields(pattern)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		return regexp.Compile(`(?i)(?:^|[^\p{L}\p{N}_])` + strings.Join(words, `\s+`) + `(?:$|[^\p{L}\p{N}_])`)
+	case models.RuleKindRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		return re, nil
+	}
+	return nil, fmt.Errorf("unknown rule kind %q", kind)
+}
+
+// Invalidate сбрасывает загруженные правила; вызывается после их изменения
+func (s *Service) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+}
+
+// Evaluate проверяет тексты (например, заголовок и содержимое поста) всеми правилами
+func (s *Service) Evaluate(ctx context.Context, texts ...string) (Verdict, error) {
+	rules, err := s.currentRules(ctx)
+	if err != nil {
+		return Verdict{}, err
+	}
+	var verdict Verdict
+	for _, r := range rules {
+		if !matchAny(r.re, texts) {
+			continue
+		}
+		log.Printf("Сработало правило автомодерации %s: %s", r.rule.ID, r.rule.Action)
+		if r.rule.Action == models.RuleActionTag && !contains(verdict.Tags, r.rule.Tag) {
+			verdict.Tags = append(verdict.Tags, r.rule.Tag)
+		}
+		if severity[r.rule.Action] > severity[verdict.Action] {
+			verdict.Action = r.rule.Action
+			verdict.RuleID = r.rule.ID
+		}
+	}
+	if verdict.Action != "" {
+		metrics.ModerationVerdicts.WithLabelValues(verdict.Action).Inc()
+	}
+	return verdict, nil
+}
+
+// currentRules возвращает правила, перечитывая их из хранилища по истечении RefreshInterval.
+// Если хранилище недоступно, используются ранее загруженные правила
+func (s *Service) currentRules(ctx context.Context) ([]compiledRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded && s.clock.Now().Sub(s.loadedAt) < s.opts.RefreshInterval {
+		return s.rules, nil
+	}
+	stored, err := s.store.ListModerationRules(ctx)
+	if err != nil {
+		if s.loaded {
+			log.Printf("Не удалось обновить правила автомодерации, используются загруженные ранее: %v", err)
+			return s.rules, nil
+		}
+		return nil, fmt.Errorf("failed to load moderation rules: %v", err)
+	}
+	rules := make([]compiledRule, 0, len(stored))
+	for _, rule := range stored {
+		re, err := compile(rule.Kind, rule.Pattern)
+		if err != nil {
+			// Правила проверяются при сохранении, сюда попадают только испорченные вручную записи
+			log.Printf("Правило автомодерации %s пропущено: %v", rule.ID, err)
+			continue
+		}
+		rules = append(rules, compiledRule{rule: rule, re: re})
+	}
+	s.rules, s.loadedAt, s.loaded = rules, s.clock.Now(), true
+	return rules, nil
+}
+
+func matchAny(re *regexp.Regexp, texts []string) bool {
+	for _, text := range texts {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addRule(t *testing.T, store *memory.MemoryStorage, id, kind, pattern, action, tag string) {
+	t.Helper()
+	rule := &models.ModerationRule{ID: id, Kind: kind, Pattern: pattern, Action: action, Tag: tag, CreatedAt: time.Now()}
+	require.NoError(t, Validate(rule))
+	require.NoError(t, store.CreateModerationRule(context.Background(), rule))
+}
+
+func TestEvaluate(t *testing.T) {
+	store := memory.New()
+	addRule(t, store, "tag", models.RuleKindKeyword, "спойлер", models.RuleActionTag, "spoiler")
+	addRule(t, store, "hold", models.RuleKindKeyword, "купить  дёшево", models.RuleActionHold, "")
+	addRule(t, store, "block", models.RuleKindRegex, `https?://spam\.example`, models.RuleActionBlock, "")
+	s := New(store, Options{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		texts  []string
+		action string
+		ruleID string
+		tags   []string
+	}{
+		{name: "Без совпадений", texts: []string{"Обычный комментарий"}},
+		{name: "Слово целиком без учёта регистра", texts: []string{"Осторожно, СПОЙЛЕР!"}, action: models.RuleActionTag, ruleID: "tag", tags: []string{"spoiler"}},
+		{name: "Часть другого слова не совпадает", texts: []string{"спойлеры"}},
+		{name: "Фраза с любыми пробелами", texts: []string{"Где купить\nдёшево?"}, action: models.RuleActionHold, ruleID: "hold"},
+		{name: "Самое строгое действие и все теги", texts: []string{"Спойлер", "http://spam.example/купить дёшево"}, action: models.RuleActionBlock, ruleID: "block", tags: []string{"spoiler"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := s.Evaluate(ctx, tt.texts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.action, verdict.Action)
+			assert.Equal(t, tt.ruleID, verdict.RuleID)
+			assert.Equal(t, tt.tags, verdict.Tags)
+		})
+	}
+}
+
+func TestEvaluate_RefreshAndInvalidate(t *testing.T) {
+	store := memory.New()
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	s := New(store, Options{RefreshInterval: time.Minute, Clock: fake})
+	ctx := context.Background()
+
+	verdict, err := s.Evaluate(ctx, "реклама")
+	require.NoError(t, err)
+	assert.Empty(t, verdict.Action)
+
+	addRule(t, store, "r1", models.RuleKindKeyword, "реклама", models.RuleActionBlock, "")
+	verdict, _ = s.Evaluate(ctx, "реклама")
+	assert.Empty(t, verdict.Action, "До истечения интервала используются загруженные правила")
+
+	fake.Advance(time.Minute)
+	verdict, _ = s.Evaluate(ctx, "реклама")
+	assert.True(t, verdict.Blocked(), "Правила перечитываются по истечении интервала")
+
+	require.NoError(t, store.DeleteModerationRule(ctx, "r1"))
+	s.Invalidate()
+	verdict, _ = s.Evaluate(ctx, "реклама")
+	assert.Empty(t, verdict.Action, "Invalidate перечитывает правила сразу")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.ModerationRule
+		err  string
+	}{
+		{name: "Пустой шаблон", rule: models.ModerationRule{Kind: models.RuleKindKeyword, Pattern: " ", Action: models.RuleActionBlock}, err: "pattern must not be empty"},
+		{name: "Неизвестное действие", rule: models.ModerationRule{Kind: models.RuleKindKeyword, Pattern: "a", Action: "DELETE"}, err: `unknown action "DELETE"`},
+		{name: "TAG без тега", rule: models.ModerationRule{Kind: models.RuleKindKeyword, Pattern: "a", Action: models.RuleActionTag}, err: "tag is required for TAG rules"},
+		{name: "Некорректное выражение", rule: models.ModerationRule{Kind: models.RuleKindRegex, Pattern: "(", Action: models.RuleActionHold}, err: "invalid regex"},
+		{name: "Неизвестный тип", rule: models.ModerationRule{Kind: "GLOB", Pattern: "a", Action: models.RuleActionHold}, err: `unknown rule kind "GLOB"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.rule)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Package partial включает для запросов режим «лучшего усилия»: у каждого запроса есть бюджет времени,
+// и когда до его конца остаётся меньше запаса, резолверы переходят на дешёвые пути - счётчики становятся
+// приблизительными, а глубокие агрегаты возвращают null с ошибкой PARTIAL вместо того, чтобы сорвать
+// весь запрос по таймауту. Поля, затронутые режимом, перечисляются в extensions.partial ответа
+package partial
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// ExtensionKey - ключ отчёта в extensions ответа
+const ExtensionKey = "partial"
+
+// Report - отчёт о неполном ответе: пути полей с приблизительными счётчиками и пропущенных полей
+type Report struct {
+	Estimated []string `json:"estimated"`
+	Skipped   []string `json:"skipped"`
+}
+
+// Options задаёт расширение; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Budget - бюджет времени запроса; срок контекста запроса, если он раньше, важнее
+	Budget time.Duration
+	// Reserve - за сколько до конца бюджета резолверы переходят на дешёвые пути
+	Reserve time.Duration
+	Clock   clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Budget <= 0 {
+		o.Budget = 2 * time.Second
+	}
+	if o.Reserve <= 0 {
+		o.Reserve = o.Budget / 4
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// Extension отслеживает бюджет времени запросов. Мутации и подписки выполняются полностью
+type Extension struct {
+	opts Options
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.ResponseInterceptor
+} = &Extension{}
+
+// New создаёт расширение для handler.Server.Use
+func New(opts Options) *Extension {
+	opts = opts.withDefaults()
+	log.Printf("Создание расширения неполных ответов: бюджет %v, запас %v", opts.Budget, opts.Reserve)
+	return &Extension{opts: opts}
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (e *Extension) ExtensionName() string {
+	return "Partial"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (e *Extension) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+type stateKey struct{}
+
+// state - срок запроса и поля, затронутые режимом; поля разрешаются параллельно
+type state struct {
+	clock     clock.Clock
+	switchAt  time.Time
+	mu        sync.Mutex
+	estimated []string
+	skipped   []string
+}
+
+// InterceptOperation назначает запросу срок, после которого включается режим лучшего усилия
+func (e *Extension) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil || oc.Operation.Operation != ast.Query {
+		return next(ctx)
+	}
+	deadline := e.opts.Clock.Now().Add(e.opts.Budget)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return next(context.WithValue(ctx, stateKey{}, &state{clock: e.opts.Clock, switchAt: deadline.Add(-e.opts.Reserve)}))
+}
+
+// InterceptResponse добавляет отчёт в extensions, если ответ неполный
+func (e *Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok || resp == nil {
+		return resp
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.estimated) == 0 && len(s.skipped) == 0 {
+		return resp
+	}
+	report := Report{Estimated: append([]string{}, s.estimated...), Skipped: append([]string{}, s.skipped...)}
+	sort.Strings(report.Estimated)
+	sort.Strings(report.Skipped)
+	log.Printf("Неполный ответ: приблизительных счётчиков %d, пропущено полей %d", len(report.Estimated), len(report.Skipped))
+	if resp.Extensions == nil {
+		resp.Extensions = map[string]any{}
+	}
+	resp.Extensions[ExtensionKey] = report
+	return resp
+}
+
+// Near сообщает, что срок запроса близко и резолверу следует выбрать дешёвый путь.
+// Вне запроса с расширением всегда false
+func Near(ctx context.Context) bool {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	return ok && !s.clock.Now().Before(s.switchAt)
+}
+
+// Estimated отмечает текущее поле как вернувшее приблизительный счётчик
+func Estimated(ctx context.Context) {
+	if s, path := current(ctx); s != nil {
+		metrics.PartialFields.WithLabelValues("estimated").Inc()
+		s.mu.Lock()
+		s.estimated = append(s.estimated, path)
+		s.mu.Unlock()
+	}
+}
+
+// Skipped отмечает текущее поле как пропущенное; резолвер возвращает null и ошибку с кодом PARTIAL
+func Skipped(ctx context.Context) {
+	if s, path := current(ctx); s != nil {
+		metrics.PartialFields.WithLabelValues("skipped").Inc()
+		s.mu.Lock()
+		s.skipped = append(s.skipped, path)
+		s.mu.Unlock()
+	}
+}
+
+// current возвращает состояние запроса и путь текущего поля
+func current(ctx context.Context) (*state, string) {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	fc := graphql.GetFieldContext(ctx)
+	if !ok || fc == nil {
+		return nil, ""
+	}
+	return s, fc.Path().String()
+}
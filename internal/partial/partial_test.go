@@ -0,0 +1,112 @@
+package partial_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	mygraphql "github.com/ButyrinIA/system/internal/graphql"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/partial"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtension(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	now := time.Now()
+	postID := "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55"
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: postID, Title: "Пост", AuthorID: "user1", AllowComments: true, CreatedAt: now}))
+	require.NoError(t, store.CreateComment(ctx, &models.Comment{ID: "c1", PostID: postID, AuthorID: "user2", Content: "Видимый", CreatedAt: now}))
+	require.NoError(t, store.CreateComment(ctx, &models.Comment{ID: "c2", PostID: postID, AuthorID: "user1", Content: "Скрытый", Hidden: true, CreatedAt: now.Add(time.Second)}))
+
+	clk := clock.NewFake(now)
+	newServer := func(opts partial.Options) *handler.Server {
+		srv := handler.New(mygraphql.NewExecutableSchema(mygraphql.Config{
+			Resolvers:  mygraphql.NewResolver(store, nil),
+			Directives: mygraphql.Directives(),
+		}))
+		srv.AddTransport(transport.POST{})
+		srv.SetErrorPresenter(gqlerrors.Presenter)
+		opts.Clock = clk
+		srv.Use(partial.New(opts))
+		srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+			ctx = context.WithValue(ctx, "userID", "user1")
+			ctx = context.WithValue(ctx, "commentLoader", mygraphql.NewCommentLoader(store))
+			return next(ctx)
+		})
+		return srv
+	}
+	type response struct {
+		Data struct {
+			Post struct {
+				Comments struct {
+					TotalCount int `json:"totalCount"`
+					Comments   []struct {
+						DescendantCount *int  `json:"descendantCount"`
+						HasMoreReplies  *bool `json:"hasMoreReplies"`
+					} `json:"comments"`
+				} `json:"comments"`
+			} `json:"post"`
+		} `json:"data"`
+		Errors []struct {
+			Path       []any          `json:"path"`
+			Extensions map[string]any `json:"extensions"`
+		} `json:"errors"`
+		Extensions struct {
+			Partial *partial.Report `json:"partial"`
+		} `json:"extensions"`
+	}
+	execute := func(srv *handler.Server) response {
+		t.Helper()
+		query := `{ post(id: "` + postID + `") { comments(limit: 10) { totalCount comments { descendantCount hasMoreReplies } } } }`
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		var resp response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp), rr.Body.String())
+		return resp
+	}
+
+	t.Run("Full answer within budget", func(t *testing.T) {
+		resp := execute(newServer(partial.Options{Budget: time.Hour, Reserve: time.Second}))
+		assert.Empty(t, resp.Errors)
+		assert.Nil(t, resp.Extensions.Partial)
+		assert.Equal(t, 2, resp.Data.Post.Comments.TotalCount, "Точный счётчик учитывает скрытый комментарий автора")
+		require.Len(t, resp.Data.Post.Comments.Comments, 2)
+		require.NotNil(t, resp.Data.Post.Comments.Comments[0].DescendantCount)
+	})
+
+	t.Run("Best effort near deadline", func(t *testing.T) {
+		// Запас равен бюджету: режим лучшего усилия включается с начала запроса
+		resp := execute(newServer(partial.Options{Budget: time.Second, Reserve: time.Second}))
+		assert.Equal(t, 1, resp.Data.Post.Comments.TotalCount, "Приблизительный счётчик без скрытых комментариев зрителя")
+		require.Len(t, resp.Data.Post.Comments.Comments, 2, "Сама страница загружается полностью")
+		for _, comment := range resp.Data.Post.Comments.Comments {
+			assert.Nil(t, comment.DescendantCount)
+			assert.Nil(t, comment.HasMoreReplies)
+		}
+		require.Len(t, resp.Errors, 4)
+		for _, e := range resp.Errors {
+			assert.Equal(t, "PARTIAL", e.Extensions["code"])
+		}
+		require.NotNil(t, resp.Extensions.Partial)
+		assert.Equal(t, []string{"post.comments"}, resp.Extensions.Partial.Estimated)
+		assert.Len(t, resp.Extensions.Partial.Skipped, 4)
+		assert.Contains(t, resp.Extensions.Partial.Skipped, "post.comments.comments[0].descendantCount")
+	})
+}
@@ -0,0 +1,197 @@
+// Package purge выполняет запросы на удаление данных пользователя: его посты и комментарии обезличиваются
+// в фоне пачками по BatchSize с паузой BatchInterval между ними, чтобы удаление большого объёма
+// не нагружало базу. Ход работы доступен по ID задачи. Задачи хранятся в памяти процесса: прерванную
+// перезапуском сервера задачу нужно запустить заново, уже обезличенные записи при этом не затрагиваются
+package purge
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/ids"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Status - состояние задачи
+type Status string
+
+const (
+	StatusRunning   Status = "RUNNING"
+	StatusCompleted Status = "COMPLETED"
+	// StatusFailed - задача остановлена ошибкой хранилища; обработанные записи остаются обезличенными
+	StatusFailed Status = "FAILED"
+)
+
+// Job - задача обезличивания постов и комментариев пользователя
+type Job struct {
+	ID     string
+	UserID string
+	Status Status
+	// Posts и Comments - сколько записей обезличено
+	Posts     int
+	Comments  int
+	StartedAt time.Time
+	// FinishedAt - время завершения; nil, пока задача выполняется
+	FinishedAt *time.Time
+	// Error - причина остановки задачи со статусом StatusFailed
+	Error string
+}
+
+// Options задаёт скорость обработки; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	BatchSize int
+	// BatchInterval - пауза между пачками
+	BatchInterval time.Duration
+	// Retention - как долго хранится завершённая задача
+	Retention time.Duration
+	IDs       ids.Generator
+	Clock     clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.BatchInterval <= 0 {
+		o.BatchInterval = time.Second
+	}
+	if o.Retention <= 0 {
+		o.Retention = 7 * 24 * time.Hour
+	}
+	if o.IDs == nil {
+		o.IDs = ids.Default()
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// Service запускает задачи обезличивания и хранит их состояние
+type Service struct {
+	store storage.Storage
+	opts  Options
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	// running - ID выполняемой задачи пользователя
+	running map[string]string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New создаёт сервис обезличивания поверх хранилища
+func New(store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Purge Service: размер пачки=%d, пауза=%v", opts.BatchSize, opts.BatchInterval)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		store:   store,
+		opts:    opts,
+		jobs:    make(map[string]*Job),
+		running: make(map[string]string),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start запускает обезличивание содержимого userID и возвращает задачу. Если задача для пользователя
+// уже выполняется, возвращается она, а новая не запускается
+func (s *Service) Start(userID string) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.running[userID]; ok {
+		return *s.jobs[id]
+	}
+	s.prune()
+	job := &Job{ID: s.opts.IDs.New(), UserID: userID, Status: StatusRunning, StartedAt: s.opts.Clock.Now()}
+	s.jobs[job.ID] = job
+	s.running[userID] = job.ID
+	log.Printf("Запуск обезличивания содержимого пользователя %s: задача %s", userID, job.ID)
+	s.wg.Add(1)
+	go s.run(job.ID, userID)
+	return *job
+}
+
+// Get возвращает копию задачи или false, если задачи нет или она удалена по истечении Retention
+func (s *Service) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Close останавливает выполняемые задачи и дожидается их завершения
+func (s *Service) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// prune удаляет задачи, завершённые раньше Retention; вызывается под mu
+func (s *Service) prune() {
+	now := s.opts.Clock.Now()
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > s.opts.Retention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// run обезличивает сначала комментарии, затем посты пользователя
+func (s *Service) run(jobID, userID string) {
+	defer s.wg.Done()
+	var err error
+	for _, kind := range []string{models.TargetComment, models.TargetPost} {
+		if err = s.purge(jobID, kind, userID); err != nil {
+			break
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[jobID]
+	finished := s.opts.Clock.Now()
+	job.FinishedAt = &finished
+	job.Status = StatusCompleted
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		log.Printf("Обезличивание содержимого пользователя %s остановлено: %v", userID, err)
+	} else {
+		log.Printf("Обезличивание содержимого пользователя %s завершено: постов=%d, комментариев=%d", userID, job.Posts, job.Comments)
+	}
+	delete(s.running, userID)
+}
+
+// purge обезличивает записи kind пачками, пока они не закончатся
+func (s *Service) purge(jobID, kind, userID string) error {
+	for {
+		anonymized, err := s.store.AnonymizeUserContent(s.ctx, kind, userID, s.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		metrics.PurgedContent.WithLabelValues(kind).Add(float64(len(anonymized)))
+		s.mu.Lock()
+		if kind == models.TargetPost {
+			s.jobs[jobID].Posts += len(anonymized)
+		} else {
+			s.jobs[jobID].Comments += len(anonymized)
+		}
+		s.mu.Unlock()
+		if len(anonymized) < s.opts.BatchSize {
+			return nil
+		}
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-time.After(s.opts.BatchInterval):
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore отклоняет обезличивание постов
+type failingStore struct {
+	storage.Storage
+}
+
+func (f failingStore) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	if kind == models.TargetPost {
+		return nil, errors.New("database unavailable")
+	}
+	return f.Storage.AnonymizeUserContent(ctx, kind, userID, limit)
+}
+
+// seed создаёт посты и комментарии пользователя user1 и один чужой пост
+func seed(t *testing.T, store storage.Storage, posts, comments int) {
+	ctx := context.Background()
+	now := time.Now()
+	for i := 0; i < posts; i++ {
+		post := &models.Post{ID: fmt.Sprintf("p%d", i), Title: "Пост", Content: "Текст", AuthorID: "user1", AllowComments: true, CreatedAt: now}
+		require.NoError(t, store.CreatePost(ctx, post))
+	}
+	other := &models.Post{ID: "other", Title: "Чужой пост", AuthorID: "user2", AllowComments: true, CreatedAt: now}
+	require.NoError(t, store.CreatePost(ctx, other))
+	for i := 0; i < comments; i++ {
+		comment := &models.Comment{ID: fmt.Sprintf("c%d", i), PostID: other.ID, AuthorID: "user1", Content: fmt.Sprintf("Комментарий %d", i), CreatedAt: now}
+		require.NoError(t, store.CreateComment(ctx, comment))
+	}
+}
+
+// wait дожидается завершения задачи
+func wait(t *testing.T, service *Service, id string) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		var ok bool
+		job, ok = service.Get(id)
+		return ok && job.Status != StatusRunning
+	}, 5*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestService(t *testing.T) {
+	store := memory.New()
+	seed(t, store, 3, 5)
+	service := New(store, Options{BatchSize: 2, BatchInterval: time.Millisecond})
+	defer service.Close()
+
+	job := service.Start("user1")
+	assert.Equal(t, "user1", job.UserID)
+	job = wait(t, service, job.ID)
+	assert.Equal(t, StatusCompleted, job.Status)
+	assert.Equal(t, 3, job.Posts)
+	assert.Equal(t, 5, job.Comments)
+	require.NotNil(t, job.FinishedAt)
+	assert.Empty(t, job.Error)
+
+	ctx := context.Background()
+	post, err := store.GetPost(ctx, "p0")
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletedUserID, post.AuthorID)
+	comment, err := store.GetComment(ctx, "c4")
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletedUserID, comment.AuthorID)
+	other, err := store.GetPost(ctx, "other")
+	require.NoError(t, err)
+	assert.Equal(t, "user2", other.AuthorID)
+
+	again := wait(t, service, service.Start("user1").ID)
+	assert.NotEqual(t, job.ID, again.ID, "Завершённая задача не мешает запустить новую")
+	assert.Zero(t, again.Posts+again.Comments)
+
+	_, ok := service.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestService_RunningJobIsReused(t *testing.T) {
+	store := memory.New()
+	seed(t, store, 0, 5)
+	service := New(store, Options{BatchSize: 1, BatchInterval: time.Hour})
+	first := service.Start("user1")
+	second := service.Start("user1")
+	assert.Equal(t, first.ID, second.ID, "Для пользователя выполняется одна задача")
+
+	service.Close()
+	job, ok := service.Get(first.ID)
+	require.True(t, ok)
+	assert.Equal(t, StatusFailed, job.Status, "Остановленная задача считается прерванной")
+	assert.Equal(t, 1, job.Comments)
+}
+
+func TestService_Failure(t *testing.T) {
+	store := memory.New()
+	seed(t, store, 1, 2)
+	service := New(failingStore{store}, Options{BatchSize: 10, BatchInterval: time.Millisecond})
+	defer service.Close()
+
+	job := wait(t, service, service.Start("user1").ID)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, 2, job.Comments, "Обработанные записи учитываются")
+	assert.Contains(t, job.Error, "database unavailable")
+}
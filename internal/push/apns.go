@@ -0,0 +1,157 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// apnsTokenLifetime - срок использования токена провайдера; Apple принимает токены не старше часа
+// и отклоняет слишком частое их обновление
+const apnsTokenLifetime = 50 * time.Minute
+
+// APNsOptions задаёт доставку через Apple Push Notification service с аутентификацией по токену
+type APNsOptions struct {
+	// KeyFile - ключ .p8 из Apple Developer
+	KeyFile string
+	KeyID   string
+	TeamID  string
+	// Topic - bundle ID приложения
+	Topic      string
+	Production bool
+	// Endpoint заменяет адрес APNs, используется в тестах
+	Endpoint string
+	Timeout  time.Duration
+}
+
+func (o APNsOptions) withDefaults() APNsOptions {
+	if o.Endpoint == "" {
+		o.Endpoint = "https://api.sandbox.push.apple.com"
+		if o.Production {
+			o.Endpoint = "https://api.push.apple.com"
+		}
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	return o
+}
+
+// APNsProvider отправляет уведомления на устройства Apple
+type APNsProvider struct {
+	opts   APNsOptions
+	key    *ecdsa.PrivateKey
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNs создаёт провайдер APNs по ключу .p8
+func NewAPNs(opts APNsOptions) (*APNsProvider, error) {
+	opts = opts.withDefaults()
+	if opts.KeyID == "" || opts.TeamID == "" || opts.Topic == "" {
+		return nil, errors.New("APNs requires key ID, team ID and topic")
+	}
+	data, err := os.ReadFile(opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read APNs key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to parse APNs key: no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse APNs key: %v", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("failed to parse APNs key: key is not ECDSA")
+	}
+	// HTTP/2 обязателен для APNs; стандартный транспорт согласует его через TLS ALPN
+	return &APNsProvider{opts: opts, key: key, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// Send реализует Provider
+func (p *APNsProvider) Send(ctx context.Context, msg Message) error {
+	token, err := p.providerToken()
+	if err != nil {
+		return err
+	}
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode APNs payload: %v", err)
+	}
+	endpoint := p.opts.Endpoint + "/3/device/" + url.PathEscape(msg.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create APNs request: %v", err)
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("apns-topic", p.opts.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send APNs notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var result struct {
+		Reason string `json:"reason"`
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	_ = json.Unmarshal(respBody, &result)
+	if resp.StatusCode == http.StatusGone || result.Reason == "BadDeviceToken" || result.Reason == "Unregistered" {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("APNs returned status %d: %s", resp.StatusCode, result.Reason)
+}
+
+// providerToken возвращает JWT провайдера, подписывая новый раз в apnsTokenLifetime
+func (p *APNsProvider) providerToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.token != "" && now.Sub(p.issuedAt) < apnsTokenLifetime {
+		return p.token, nil
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.opts.TeamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = p.opts.KeyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign APNs token: %v", err)
+	}
+	p.token = signed
+	p.issuedAt = now
+	return signed, nil
+}
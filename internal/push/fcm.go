@@ -0,0 +1,191 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fcmScope - OAuth-область, необходимая для отправки сообщений FCM
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCMOptions задаёт доставку через Firebase Cloud Messaging HTTP v1
+type FCMOptions struct {
+	// CredentialsFile - JSON-ключ сервисного аккаунта Google
+	CredentialsFile string
+	// Endpoint заменяет адрес FCM, используется в тестах
+	Endpoint string
+	Timeout  time.Duration
+}
+
+func (o FCMOptions) withDefaults() FCMOptions {
+	if o.Endpoint == "" {
+		o.Endpoint = "https://fcm.googleapis.com"
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	return o
+}
+
+// serviceAccount - используемые поля JSON-ключа сервисного аккаунта
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMProvider отправляет уведомления на Android-устройства и в браузеры через FCM
+type FCMProvider struct {
+	opts    FCMOptions
+	account serviceAccount
+	key     *rsa.PrivateKey
+	client  *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCM создаёт провайдер FCM по ключу сервисного аккаунта
+func NewFCM(opts FCMOptions) (*FCMProvider, error) {
+	opts = opts.withDefaults()
+	data, err := os.ReadFile(opts.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FCM credentials: %v", err)
+	}
+	var account serviceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse FCM credentials: %v", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, errors.New("FCM credentials must contain project_id, client_email and token_uri")
+	}
+	key, err := parseRSAKey(account.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FCM private key: %v", err)
+	}
+	return &FCMProvider{opts: opts, account: account, key: key, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// Send реализует Provider
+func (p *FCMProvider) Send(ctx context.Context, msg Message) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        msg.Token,
+			"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+			"data":         msg.Data,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode FCM message: %v", err)
+	}
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", p.opts.Endpoint, url.PathEscape(p.account.ProjectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create FCM request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send FCM message: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || strings.Contains(string(respBody), "UNREGISTERED"):
+		return ErrInvalidToken
+	default:
+		return fmt.Errorf("FCM returned status %d: %s", resp.StatusCode, respBody)
+	}
+}
+
+// token возвращает OAuth-токен доступа, обменивая подписанный JWT сервисного аккаунта за минуту до истечения прежнего
+func (p *FCMProvider) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.accessToken != "" && now.Before(p.expiresAt.Add(-time.Minute)) {
+		return p.accessToken, nil
+	}
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   p.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign FCM assertion: %v", err)
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create FCM token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain FCM access token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("FCM token endpoint returned status %d: %s", resp.StatusCode, body)
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode FCM access token: %v", err)
+	}
+	p.accessToken = result.AccessToken
+	p.expiresAt = now.Add(time.Duration(result.ExpiresIn) * time.Second)
+	return p.accessToken, nil
+}
+
+// parseRSAKey разбирает RSA-ключ в PEM (PKCS#8 или PKCS#1)
+func parseRSAKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not RSA")
+	}
+	return key, nil
+}
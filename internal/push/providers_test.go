@@ -0,0 +1,219 @@
+package push
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile сохраняет данные во временный файл и возвращает путь
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestFCMProvider_Send(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	var tokenRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests.Add(1)
+			require.NoError(t, r.ParseForm())
+			claims := jwt.MapClaims{}
+			_, err := jwt.ParseWithClaims(r.PostForm.Get("assertion"), claims, func(*jwt.Token) (any, error) { return &key.PublicKey, nil })
+			assert.NoError(t, err)
+			assert.Equal(t, fcmScope, claims["scope"])
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access", "expires_in": 3600})
+		case "/v1/projects/demo/messages:send":
+			assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+			var body struct {
+				Message struct {
+					Token        string            `json:"token"`
+					Notification map[string]string `json:"notification"`
+				} `json:"message"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if body.Message.Token == "stale" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+				return
+			}
+			assert.Equal(t, "Заголовок", body.Message.Notification["title"])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	credentials, err := json.Marshal(map[string]string{
+		"project_id":   "demo",
+		"client_email": "push@demo.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"token_uri":    server.URL + "/token",
+	})
+	require.NoError(t, err)
+
+	provider, err := NewFCM(FCMOptions{CredentialsFile: writeFile(t, "fcm.json", credentials), Endpoint: server.URL})
+	require.NoError(t, err)
+	require.NoError(t, provider.Send(context.Background(), Message{Token: "device", Title: "Заголовок"}))
+	assert.ErrorIs(t, provider.Send(context.Background(), Message{Token: "stale", Title: "Заголовок"}), ErrInvalidToken)
+	assert.Equal(t, int32(1), tokenRequests.Load(), "Токен доступа кэшируется")
+}
+
+func TestAPNsProvider_Send(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := jwt.Parse(strings.TrimPrefix(r.Header.Get("Authorization"), "bearer "), func(*jwt.Token) (any, error) { return &key.PublicKey, nil })
+		assert.NoError(t, err)
+		assert.Equal(t, "KEY123", token.Header["kid"])
+		assert.Equal(t, "com.example.app", r.Header.Get("apns-topic"))
+		switch r.URL.Path {
+		case "/3/device/device":
+			var payload map[string]any
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, "post1", payload["postId"])
+		case "/3/device/stale":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"reason":"InvalidProviderToken"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewAPNs(APNsOptions{
+		KeyFile:  writeFile(t, "apns.p8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		KeyID:    "KEY123",
+		TeamID:   "TEAM",
+		Topic:    "com.example.app",
+		Endpoint: server.URL,
+	})
+	require.NoError(t, err)
+	msg := Message{Token: "device", Title: "Заголовок", Data: map[string]string{"postId": "post1"}}
+	require.NoError(t, provider.Send(context.Background(), msg))
+	msg.Token = "stale"
+	assert.ErrorIs(t, provider.Send(context.Background(), msg), ErrInvalidToken)
+	msg.Token = "other"
+	err = provider.Send(context.Background(), msg)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidToken)
+	assert.Contains(t, err.Error(), "InvalidProviderToken")
+}
+
+func TestWebPushProvider_Send(t *testing.T) {
+	vapid, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	authSecret := make([]byte, 16)
+	_, err = rand.Read(authSecret)
+	require.NoError(t, err)
+
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "86400", r.Header.Get("TTL"))
+		var jwtToken string
+		for _, part := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "vapid "), ", ") {
+			if strings.HasPrefix(part, "t=") {
+				jwtToken = strings.TrimPrefix(part, "t=")
+			}
+		}
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(jwtToken, claims, func(*jwt.Token) (any, error) {
+			raw := vapid.PublicKey().Bytes()
+			return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(raw[1:33]), Y: new(big.Int).SetBytes(raw[33:])}, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "http://"+r.Host, claims["aud"])
+		assert.Equal(t, "mailto:push@example.com", claims["sub"])
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	provider, err := NewWebPush(WebPushOptions{
+		VAPIDPrivateKey: base64.RawURLEncoding.EncodeToString(vapid.Bytes()),
+		Subject:         "mailto:push@example.com",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(vapid.PublicKey().Bytes()), provider.PublicKey())
+	subscription := func(endpoint string) string {
+		data, err := json.Marshal(map[string]any{
+			"endpoint": endpoint,
+			"keys": map[string]string{
+				"p256dh": base64.RawURLEncoding.EncodeToString(browser.PublicKey().Bytes()),
+				"auth":   base64.RawURLEncoding.EncodeToString(authSecret),
+			},
+		})
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	msg := Message{Token: subscription(server.URL + "/push"), Title: "Заголовок", Body: "Текст", Data: map[string]string{"postId": "post1"}}
+	require.NoError(t, provider.Send(context.Background(), msg))
+
+	// Расшифровка на стороне браузера по RFC 8291
+	require.Greater(t, len(received), 21)
+	salt := received[:16]
+	assert.Equal(t, uint32(webPushRecordSize), binary.BigEndian.Uint32(received[16:20]))
+	idLen := int(received[20])
+	asPublicBytes := received[21 : 21+idLen]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	require.NoError(t, err)
+	shared, err := browser.ECDH(asPublic)
+	require.NoError(t, err)
+	keyInfo := append([]byte("WebPush: info\x00"), browser.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm := hkdf(authSecret, shared, keyInfo, 32)
+	block, err := aes.NewCipher(hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plaintext, err := gcm.Open(nil, hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12), received[21+idLen:], nil)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x02), plaintext[len(plaintext)-1])
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(plaintext[:len(plaintext)-1], &payload))
+	assert.Equal(t, "Заголовок", payload["title"])
+	assert.Equal(t, "Текст", payload["body"])
+
+	msg.Token = subscription(server.URL + "/gone")
+	assert.ErrorIs(t, provider.Send(context.Background(), msg), ErrInvalidToken)
+	msg.Token = "not a subscription"
+	assert.ErrorIs(t, provider.Send(context.Background(), msg), ErrInvalidToken)
+}
@@ -0,0 +1,241 @@
+// Package push доставляет push-уведомления об ответах и упоминаниях на устройства пользователей
+// через FCM, APNs и Web Push. Получатели определяются и уведомления отправляются в фоне,
+// чтобы создание комментария не ждало внешних сервисов
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// ErrInvalidToken возвращается провайдером, если устройство больше не принимает уведомления;
+// такой токен удаляется
+var ErrInvalidToken = errors.New("device token is no longer valid")
+
+// maxExcerptLength - длина фрагмента комментария в уведомлении в символах
+const maxExcerptLength = 100
+
+// mentionPattern выделяет упоминания вида @userID
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// Message - уведомление для одного устройства
+type Message struct {
+	Token string
+	Title string
+	Body  string
+	// Data - данные для перехода к комментарию в клиенте
+	Data map[string]string
+}
+
+// Provider доставляет уведомления на устройства одной платформы
+type Provider interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogProvider пишет уведомления в стандартный лог вместо отправки
+type LogProvider struct{}
+
+// NewLogProvider создаёт провайдер, пишущий уведомления в лог
+func NewLogProvider() *LogProvider {
+	return &LogProvider{}
+}
+
+// Send реализует Provider
+func (p *LogProvider) Send(ctx context.Context, msg Message) error {
+	log.Printf("Push-уведомление: %s - %s %v", msg.Title, msg.Body, msg.Data)
+	return nil
+}
+
+// Options задаёт фоновую доставку; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	Timeout   time.Duration
+	Workers   int
+	QueueSize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.Workers <= 0 {
+		o.Workers = 2
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	return o
+}
+
+// job - новый комментарий, о котором нужно уведомить участников обсуждения
+type job struct {
+	post    *models.Post
+	comment *models.Comment
+}
+
+// Service рассылает уведомления о новых комментариях
+type Service struct {
+	store     storage.Storage
+	providers map[string]Provider
+	opts      Options
+	jobs      chan job
+	wg        sync.WaitGroup
+	once      sync.Once
+}
+
+// New создаёт сервис и запускает фоновые обработчики. providers сопоставляет платформу устройства
+// с провайдером; устройства платформ без провайдера пропускаются
+func New(store storage.Storage, providers map[string]Provider, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Push Service: платформ %d, workers=%d", len(providers), opts.Workers)
+	s := &Service{store: store, providers: providers, opts: opts, jobs: make(chan job, opts.QueueSize)}
+	for i := 0; i < opts.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// CommentCreated ставит в очередь уведомления о новом видимом комментарии: автору родительского
+// комментария или поста об ответе и упомянутым пользователям. При переполнении очереди уведомления теряются
+func (s *Service) CommentCreated(post *models.Post, comment *models.Comment) {
+	select {
+	case s.jobs <- job{post: post, comment: comment}:
+	default:
+		log.Printf("Очередь push-уведомлений переполнена, комментарий %s пропущен", comment.ID)
+	}
+}
+
+// Close останавливает обработчики после доставки уже поставленных в очередь уведомлений
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.jobs)
+		s.wg.Wait()
+		log.Println("Push Service остановлен")
+	})
+}
+
+func (s *Service) worker() {
+	defer s.wg.Done()
+	for j := range s.jobs {
+		s.process(j)
+	}
+}
+
+// process определяет получателей комментария; упомянутый в ответе автор родителя получает одно уведомление об ответе
+func (s *Service) process(j job) {
+	ctx := context.Background()
+	recipients := map[string]bool{j.comment.AuthorID: true}
+	replyTo := j.post.AuthorID
+	if j.comment.ParentID != nil {
+		parent, err := s.store.GetComment(ctx, *j.comment.ParentID)
+		if err != nil {
+			log.Printf("Ошибка получения родительского комментария %s: %v", *j.comment.ParentID, err)
+			replyTo = ""
+		} else {
+			replyTo = parent.AuthorID
+		}
+	}
+	if replyTo != "" && !recipients[replyTo] {
+		recipients[replyTo] = true
+		s.notify(ctx, replyTo, models.NotifyReply, j.comment)
+	}
+	for _, userID := range Mentions(j.comment.Content) {
+		if !recipients[userID] {
+			recipients[userID] = true
+			s.notify(ctx, userID, models.NotifyMention, j.comment)
+		}
+	}
+}
+
+// notify отправляет уведомление на все устройства пользователя, если он не отключил такие уведомления
+func (s *Service) notify(ctx context.Context, userID, event string, comment *models.Comment) {
+	prefs, err := s.store.GetPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка получения настроек пользователя %s: %v", userID, err)
+		return
+	}
+	if !prefs.AllowsPush(event) {
+		return
+	}
+	tokens, err := s.store.ListDeviceTokens(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка получения устройств пользователя %s: %v", userID, err)
+		return
+	}
+	for _, token := range tokens {
+		provider, ok := s.providers[token.Platform]
+		if !ok {
+			continue
+		}
+		msg := Message{
+			Token: token.Token,
+			Title: title(prefs.Locale, event, models.DisplayAuthorID(comment.PostID, comment.AuthorID)),
+			Body:  excerpt(comment.Content),
+			Data:  map[string]string{"postId": comment.PostID, "commentId": comment.ID},
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+		err := provider.Send(sendCtx, msg)
+		cancel()
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			log.Printf("Устройство %s пользователя %s больше не принимает уведомления, токен удалён", token.Platform, userID)
+			metrics.PushDeliveries.WithLabelValues("invalid_token").Inc()
+			if err := s.store.UnregisterDeviceToken(ctx, userID, token.Token); err != nil {
+				log.Printf("Ошибка удаления устройства пользователя %s: %v", userID, err)
+			}
+		case err != nil:
+			log.Printf("Ошибка отправки push-уведомления на %s пользователя %s: %v", token.Platform, userID, err)
+			metrics.PushDeliveries.WithLabelValues("error").Inc()
+		default:
+			metrics.PushDeliveries.WithLabelValues("sent").Inc()
+		}
+	}
+}
+
+// Mentions возвращает ID упомянутых в тексте пользователей в порядке первого упоминания
+func Mentions(content string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		userID := strings.TrimRight(match[1], ".-")
+		if userID != "" && !seen[userID] {
+			seen[userID] = true
+			result = append(result, userID)
+		}
+	}
+	return result
+}
+
+// title возвращает заголовок уведомления на языке получателя
+func title(locale, event, authorID string) string {
+	if locale == "en" {
+		if event == models.NotifyMention {
+			return fmt.Sprintf("%s mentioned you", authorID)
+		}
+		return fmt.Sprintf("%s replied to you", authorID)
+	}
+	if event == models.NotifyMention {
+		return fmt.Sprintf("%s упомянул(а) вас", authorID)
+	}
+	return fmt.Sprintf("%s ответил(а) вам", authorID)
+}
+
+// excerpt обрезает текст комментария до maxExcerptLength символов
+func excerpt(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(content) <= maxExcerptLength {
+		return content
+	}
+	return string([]rune(content)[:maxExcerptLength]) + "…"
+}
@@ -0,0 +1,105 @@
+package push
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProvider запоминает отправленные уведомления и отклоняет токены из invalid
+type recordingProvider struct {
+	mu      sync.Mutex
+	sent    []Message
+	invalid map[string]bool
+}
+
+func (p *recordingProvider) Send(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.invalid[msg.Token] {
+		return ErrInvalidToken
+	}
+	p.sent = append(p.sent, msg)
+	return nil
+}
+
+func TestService_CommentCreated(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	now := time.Now()
+	post := &models.Post{ID: "post1", Title: "Пост", AuthorID: "author", AllowComments: true, CreatedAt: now}
+	require.NoError(t, store.CreatePost(ctx, post))
+	root := &models.Comment{ID: "c1", PostID: post.ID, AuthorID: "replier", Content: "Корневой", CreatedAt: now}
+	require.NoError(t, store.CreateComment(ctx, root))
+	for _, token := range []models.DeviceToken{
+		{Token: "author-phone", UserID: "author", Platform: models.PlatformFCM, CreatedAt: now},
+		{Token: "replier-phone", UserID: "replier", Platform: models.PlatformAPNs, CreatedAt: now},
+		{Token: "replier-stale", UserID: "replier", Platform: models.PlatformAPNs, CreatedAt: now},
+		{Token: "muted-phone", UserID: "muted", Platform: models.PlatformFCM, CreatedAt: now},
+		{Token: "fan-browser", UserID: "fan", Platform: models.PlatformWebPush, CreatedAt: now},
+	} {
+		require.NoError(t, store.RegisterDeviceToken(ctx, &token))
+	}
+	muted := models.DefaultPreferences("muted")
+	muted.PushOnMention = false
+	require.NoError(t, store.SavePreferences(ctx, muted))
+	english := models.DefaultPreferences("replier")
+	english.Locale = "en"
+	require.NoError(t, store.SavePreferences(ctx, english))
+
+	fcm := &recordingProvider{}
+	apns := &recordingProvider{invalid: map[string]bool{"replier-stale": true}}
+	service := New(store, map[string]Provider{models.PlatformFCM: fcm, models.PlatformAPNs: apns}, Options{})
+
+	parentID := root.ID
+	reply := &models.Comment{ID: "c2", PostID: post.ID, ParentID: &parentID, AuthorID: "author",
+		Content: "Согласен, @replier и @muted. Спросим @fan и @author", CreatedAt: now}
+	service.CommentCreated(post, reply)
+	service.CommentCreated(post, &models.Comment{ID: "c3", PostID: post.ID, AuthorID: "fan", Content: "Отличный пост", CreatedAt: now})
+	service.Close()
+
+	require.Len(t, apns.sent, 1, "Упомянутый в ответе автор родителя получает одно уведомление")
+	assert.Equal(t, "replier-phone", apns.sent[0].Token)
+	assert.Equal(t, "author replied to you", apns.sent[0].Title)
+	assert.Equal(t, map[string]string{"postId": "post1", "commentId": "c2"}, apns.sent[0].Data)
+	require.Len(t, fcm.sent, 1, "Отключивший упоминания и сам комментатор уведомлений не получают")
+	assert.Equal(t, "author-phone", fcm.sent[0].Token)
+	assert.Equal(t, "fan ответил(а) вам", fcm.sent[0].Title)
+	assert.Equal(t, "Отличный пост", fcm.sent[0].Body)
+
+	tokens, err := store.ListDeviceTokens(ctx, "replier")
+	require.NoError(t, err)
+	require.Len(t, tokens, 1, "Недействительный токен удаляется")
+	assert.Equal(t, "replier-phone", tokens[0].Token)
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"Несколько упоминаний", "@alice, @bob и снова @alice", []string{"alice", "bob"}},
+		{"Точка в конце предложения", "Спасибо, @user1.", []string{"user1"}},
+		{"Адрес почты не упоминание", "Пишите на mail@example.com", nil},
+		{"Без упоминаний", "Обычный текст", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Mentions(tt.content))
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.Equal(t, "Текст в одну строку", excerpt("Текст\nв  одну\tстроку"))
+	long := excerpt(strings.Repeat("а", 150))
+	assert.Equal(t, maxExcerptLength+1, len([]rune(long)))
+}
@@ -0,0 +1,217 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// webPushRecordSize - размер записи aes128gcm; уведомление всегда помещается в одну запись
+const webPushRecordSize = 4096
+
+// WebPushOptions задаёт доставку в браузеры по протоколу Web Push с ключами VAPID
+type WebPushOptions struct {
+	// VAPIDPrivateKey - закрытый ключ P-256 в base64url без дополнения (32 байта)
+	VAPIDPrivateKey string
+	// Subject - контакт отправителя для push-сервиса: mailto: или https: URL
+	Subject string
+	// TTL - сколько push-сервис хранит уведомление для недоступного браузера
+	TTL     time.Duration
+	Timeout time.Duration
+}
+
+func (o WebPushOptions) withDefaults() WebPushOptions {
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	return o
+}
+
+// webPushSubscription - PushSubscription браузера в JSON; используется как токен устройства WEBPUSH
+type webPushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// WebPushProvider шифрует уведомления по RFC 8291 и отправляет их на push-сервисы браузеров
+type WebPushProvider struct {
+	opts      WebPushOptions
+	key       *ecdsa.PrivateKey
+	publicKey []byte
+	client    *http.Client
+}
+
+// NewWebPush создаёт провайдер Web Push по закрытому ключу VAPID
+func NewWebPush(opts WebPushOptions) (*WebPushProvider, error) {
+	opts = opts.withDefaults()
+	if opts.Subject == "" {
+		return nil, errors.New("web push requires a VAPID subject")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(opts.VAPIDPrivateKey, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode VAPID private key: %v", err)
+	}
+	private, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse VAPID private key: %v", err)
+	}
+	public := private.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}
+	return &WebPushProvider{opts: opts, key: key, publicKey: public, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// PublicKey возвращает открытый ключ VAPID в base64url; его передают в pushManager.subscribe браузера
+func (p *WebPushProvider) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(p.publicKey)
+}
+
+// Send реализует Provider; msg.Token - JSON подписки браузера
+func (p *WebPushProvider) Send(ctx context.Context, msg Message) error {
+	var sub webPushSubscription
+	if err := json.Unmarshal([]byte(msg.Token), &sub); err != nil || sub.Endpoint == "" {
+		return ErrInvalidToken
+	}
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return ErrInvalidToken
+	}
+	payload, err := json.Marshal(map[string]any{"title": msg.Title, "body": msg.Body, "data": msg.Data})
+	if err != nil {
+		return fmt.Errorf("failed to encode web push payload: %v", err)
+	}
+	body, err := encryptWebPush(sub, payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	vapid, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": p.opts.Subject,
+	}).SignedString(p.key)
+	if err != nil {
+		return fmt.Errorf("failed to sign VAPID token: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create web push request: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", vapid, p.PublicKey()))
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(p.opts.TTL.Seconds())))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send web push: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrInvalidToken
+	default:
+		return fmt.Errorf("web push service returned status %d: %s", resp.StatusCode, respBody)
+	}
+}
+
+// encryptWebPush шифрует payload для подписки по RFC 8291 (кодирование aes128gcm из RFC 8188)
+func encryptWebPush(sub webPushSubscription, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(sub.Keys.P256dh, "="))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(sub.Keys.Auth, "="))
+	if err != nil || len(authSecret) == 0 {
+		return nil, ErrInvalidToken
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate web push key: %v", err)
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive web push secret: %v", err)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate web push salt: %v", err)
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicBytes...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm := hkdf(authSecret, sharedSecret, keyInfo, 32)
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create web push cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create web push cipher: %v", err)
+	}
+	// Разделитель 0x02 отмечает последнюю запись
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	if len(plaintext)+gcm.Overhead() > webPushRecordSize {
+		return nil, errors.New("web push payload is too large")
+	}
+
+	header := make([]byte, 0, 21+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// hkdf - HKDF-SHA-256 (RFC 5869) для length не больше размера хэша, которого достаточно для Web Push
+func hkdf(salt, secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{0x01})
+	return expand.Sum(nil)[:length]
+}
@@ -0,0 +1,97 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// ExtensionKey - ключ extensions ответа с состоянием квот, которые учитывала операция
+const ExtensionKey = "quotas"
+
+// Usage - состояние квоты действия после операции: клиент может замедлиться до того, как упрётся в лимит
+type Usage struct {
+	Action Action `json:"action"`
+	Limit  int    `json:"limit"`
+	// Remaining - сколько действий осталось до конца окна; 0, если квота исчерпана
+	Remaining int `json:"remaining"`
+	// ResetAt - время в RFC3339, когда начнётся новое окно квоты
+	ResetAt string `json:"resetAt"`
+}
+
+// feedbackKey - ключ контекста операции с feedback
+type feedbackKey struct{}
+
+// feedback собирает состояние квот, которые учитывались при выполнении операции
+type feedback struct {
+	mu     sync.Mutex
+	usages []Usage
+}
+
+// record запоминает состояние квоты; повторное действие в той же операции заменяет прежнее
+func (f *feedback) record(usage Usage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.usages {
+		if f.usages[i].Action == usage.Action {
+			f.usages[i] = usage
+			return
+		}
+	}
+	f.usages = append(f.usages, usage)
+}
+
+func (f *feedback) get() []Usage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Usage(nil), f.usages...)
+}
+
+// recordUsage передаёт состояние квоты в ответ операции, если подключено расширение Feedback
+func recordUsage(ctx context.Context, action Action, limit, used int, resetAt time.Time) {
+	f, ok := ctx.Value(feedbackKey{}).(*feedback)
+	if !ok {
+		return
+	}
+	f.record(Usage{Action: action, Limit: limit, Remaining: max(limit-used, 0), ResetAt: resetAt.Format(time.RFC3339)})
+}
+
+// Feedback - расширение GraphQL-сервера, которое добавляет в extensions ответа состояние квот,
+// учтённых операцией, - и при успехе, и при отказе QUOTA_EXCEEDED
+type Feedback struct{}
+
+var _ interface {
+	graphql.OperationInterceptor
+	graphql.HandlerExtension
+} = Feedback{}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (Feedback) ExtensionName() string {
+	return "QuotaFeedback"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (Feedback) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation реализует graphql.OperationInterceptor
+func (Feedback) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	f := &feedback{}
+	handler := next(context.WithValue(ctx, feedbackKey{}, f))
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		if resp == nil {
+			return resp
+		}
+		if usages := f.get(); len(usages) > 0 {
+			if resp.Extensions == nil {
+				resp.Extensions = map[string]any{}
+			}
+			resp.Extensions[ExtensionKey] = usages
+		}
+		return resp
+	}
+}
@@ -0,0 +1,43 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runOperation выполняет операцию через Feedback; exec вызывается с контекстом операции
+func runOperation(exec func(ctx context.Context)) *graphql.Response {
+	handler := Feedback{}.InterceptOperation(context.Background(), func(ctx context.Context) graphql.ResponseHandler {
+		exec(ctx)
+		return graphql.OneShot(&graphql.Response{Data: []byte(`{}`)})
+	})
+	return handler(context.Background())
+}
+
+func TestFeedback(t *testing.T) {
+	s := newTestService(clock.NewFake(time.Date(2026, 3, 10, 12, 0, 30, 0, time.UTC)))
+
+	resp := runOperation(func(ctx context.Context) {})
+	assert.NotContains(t, resp.Extensions, ExtensionKey, "Операция без квот не добавляет extensions")
+
+	resp = runOperation(func(ctx context.Context) {
+		require.NoError(t, s.Consume(ctx, "user1", "", ActionPost))
+	})
+	assert.Equal(t, []Usage{{Action: ActionPost, Limit: 2, Remaining: 1, ResetAt: "2026-03-11T00:00:00Z"}}, resp.Extensions[ExtensionKey])
+
+	resp = runOperation(func(ctx context.Context) {
+		require.NoError(t, s.Consume(ctx, "user1", "", ActionPost))
+		assert.Error(t, s.Consume(ctx, "user1", "", ActionPost))
+		require.NoError(t, s.Consume(ctx, "user1", "user", ActionComment))
+	})
+	assert.Equal(t, []Usage{
+		{Action: ActionPost, Limit: 2, Remaining: 0, ResetAt: "2026-03-11T00:00:00Z"},
+		{Action: ActionComment, Limit: 1, Remaining: 0, ResetAt: "2026-03-10T12:01:00Z"},
+	}, resp.Extensions[ExtensionKey])
+}
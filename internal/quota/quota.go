@@ -0,0 +1,132 @@
+// Package quota ограничивает число постов и комментариев пользователя за период.
+// В отличие от технических rate limit, квоты - продуктовое ограничение против спама
+// и зависят от роли пользователя
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Action - действие, на которое действует квота
+type Action string
+
+const (
+	ActionPost    Action = "post"
+	ActionComment Action = "comment"
+)
+
+// Limits - лимиты роли; 0 означает отсутствие ограничения
+type Limits struct {
+	PostsPerDay       int
+	CommentsPerMinute int
+}
+
+// Options задаёт лимиты по ролям и роль пользователей, для которых она не указана
+type Options struct {
+	Roles       map[string]Limits
+	DefaultRole string
+	// Clock - источник времени для окон квот, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// ExceededError возвращается, если пользователь исчерпал квоту до ResetAt
+type ExceededError struct {
+	Action  Action
+	Limit   int
+	ResetAt time.Time
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s quota of %d exceeded, resets at %s", e.Action, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// Service проверяет и учитывает квоты пользователей в хранилище
+type Service struct {
+	store storage.Storage
+	opts  Options
+	clock clock.Clock
+}
+
+// New создаёт сервис квот поверх хранилища счётчиков
+func New(store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Quota Service: ролей=%d, роль по умолчанию=%s", len(opts.Roles), opts.DefaultRole)
+	return &Service{store: store, opts: opts, clock: opts.Clock}
+}
+
+// Consume учитывает действие пользователя и возвращает *ExceededError, если квота исчерпана.
+// Пустая роль заменяется ролью по умолчанию; роль без настроенных лимитов не ограничивается.
+// Состояние квоты после действия передаётся в ответ операции через Feedback
+func (s *Service) Consume(ctx context.Context, userID, role string, action Action) error {
+	if role == "" {
+		role = s.opts.DefaultRole
+	}
+	limits, ok := s.opts.Roles[role]
+	if !ok {
+		return nil
+	}
+	limit, windowStart, resetAt := s.window(limits, action)
+	if limit <= 0 {
+		return nil
+	}
+	used, err := s.store.IncrementQuotaUsage(ctx, userID, string(action), windowStart)
+	if err != nil {
+		return fmt.Errorf("failed to check quota: %v", err)
+	}
+	recordUsage(ctx, action, limit, used, resetAt)
+	if used > limit {
+		log.Printf("Квота %s исчерпана пользователем %s (роль %s): %d из %d", action, userID, role, used, limit)
+		metrics.QuotaRejections.WithLabelValues(string(action), role).Inc()
+		return &ExceededError{Action: action, Limit: limit, ResetAt: resetAt}
+	}
+	return nil
+}
+
+// Refund возвращает действие, учтённое Consume, если оно не состоялось, например повторно отправленный
+// комментарий. Действие возвращается только в текущее окно: если окно сменилось, счётчик уже начат заново
+func (s *Service) Refund(ctx context.Context, userID, role string, action Action) error {
+	if role == "" {
+		role = s.opts.DefaultRole
+	}
+	limits, ok := s.opts.Roles[role]
+	if !ok {
+		return nil
+	}
+	limit, windowStart, _ := s.window(limits, action)
+	if limit <= 0 {
+		return nil
+	}
+	if err := s.store.DecrementQuotaUsage(ctx, userID, string(action), windowStart); err != nil {
+		return fmt.Errorf("failed to refund quota: %v", err)
+	}
+	log.Printf("Действие %s возвращено в квоту пользователя %s", action, userID)
+	return nil
+}
+
+// window возвращает лимит действия и границы текущего окна: сутки UTC для постов, минута для комментариев
+func (s *Service) window(limits Limits, action Action) (int, time.Time, time.Time) {
+	now := s.clock.Now().UTC()
+	switch action {
+	case ActionPost:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return limits.PostsPerDay, start, start.AddDate(0, 0, 1)
+	case ActionComment:
+		start := now.Truncate(time.Minute)
+		return limits.CommentsPerMinute, start, start.Add(time.Minute)
+	}
+	return 0, now, now
+}
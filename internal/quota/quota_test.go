@@ -0,0 +1,82 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(fake *clock.Fake) *Service {
+	return New(memory.New(), Options{
+		DefaultRole: "user",
+		Roles: map[string]Limits{
+			"user":      {PostsPerDay: 2, CommentsPerMinute: 1},
+			"moderator": {},
+		},
+		Clock: fake,
+	})
+}
+
+func TestConsume_PostsPerDayWithDailyRollover(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 3, 10, 23, 59, 0, 0, time.UTC))
+	s := newTestService(fake)
+	ctx := context.Background()
+
+	require.NoError(t, s.Consume(ctx, "user1", "", ActionPost))
+	require.NoError(t, s.Consume(ctx, "user1", "", ActionPost))
+
+	err := s.Consume(ctx, "user1", "", ActionPost)
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, ActionPost, exceeded.Action)
+	assert.Equal(t, 2, exceeded.Limit)
+	assert.Equal(t, time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC), exceeded.ResetAt)
+
+	assert.NoError(t, s.Consume(ctx, "user2", "", ActionPost), "Квота ведётся отдельно для каждого пользователя")
+
+	fake.Advance(2 * time.Minute)
+	assert.NoError(t, s.Consume(ctx, "user1", "", ActionPost), "После полуночи квота начинается заново")
+}
+
+func TestConsume_CommentsPerMinute(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 30, 0, time.UTC))
+	s := newTestService(fake)
+	ctx := context.Background()
+
+	require.NoError(t, s.Consume(ctx, "user1", "user", ActionComment))
+	err := s.Consume(ctx, "user1", "user", ActionComment)
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, time.Date(2026, 3, 10, 12, 1, 0, 0, time.UTC), exceeded.ResetAt)
+
+	fake.Advance(30 * time.Second)
+	assert.NoError(t, s.Consume(ctx, "user1", "user", ActionComment))
+}
+
+func TestConsume_UnlimitedRoles(t *testing.T) {
+	s := newTestService(clock.NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)))
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, s.Consume(ctx, "mod1", "moderator", ActionComment), "Нулевой лимит не ограничивает")
+		assert.NoError(t, s.Consume(ctx, "bot", "unknown", ActionComment), "Роль без лимитов не ограничивается")
+	}
+}
+
+func TestRefund(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 30, 0, time.UTC))
+	s := newTestService(fake)
+	ctx := context.Background()
+
+	require.NoError(t, s.Consume(ctx, "user1", "", ActionComment))
+	require.NoError(t, s.Refund(ctx, "user1", "", ActionComment))
+	require.NoError(t, s.Consume(ctx, "user1", "", ActionComment), "Возвращённое действие снова доступно")
+	assert.Error(t, s.Consume(ctx, "user1", "", ActionComment))
+
+	assert.NoError(t, s.Refund(ctx, "mod1", "moderator", ActionComment), "Без лимита возвращать нечего")
+}
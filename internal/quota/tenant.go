@@ -0,0 +1,109 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Ресурсы хранилища, на которые действует квота сообщества
+const (
+	ResourcePosts    = "posts"
+	ResourceComments = "comments"
+	ResourceBytes    = "bytes"
+)
+
+// TenantLimits - лимиты хранилища сообщества; 0 означает отсутствие ограничения
+type TenantLimits struct {
+	Posts    int64
+	Comments int64
+	// Bytes - суммарный размер заголовков и текстов постов и комментариев
+	Bytes int64
+}
+
+// TenantOptions задаёт лимиты по умолчанию и отдельные лимиты сообществ
+type TenantOptions struct {
+	Default TenantLimits
+	Tenants map[string]TenantLimits
+}
+
+// StorageExceededError возвращается, если сообщество достигло лимита хранилища
+type StorageExceededError struct {
+	TenantID string
+	Resource string
+	Limit    int64
+}
+
+func (e *StorageExceededError) Error() string {
+	return fmt.Sprintf("tenant %s %s quota of %d exceeded", e.TenantID, e.Resource, e.Limit)
+}
+
+// TenantService ограничивает объём содержимого сообщества, чтобы одно сообщество не заняло всю базу.
+// Квоты мягкие: проверка и учёт выполняются раздельно, поэтому одновременные запросы могут
+// немного превысить лимит
+type TenantService struct {
+	store storage.Storage
+	opts  TenantOptions
+}
+
+// NewTenant создаёт сервис квот сообществ поверх хранилища счётчиков
+func NewTenant(store storage.Storage, opts TenantOptions) *TenantService {
+	log.Printf("Создание Tenant Quota Service: постов=%d, комментариев=%d, байт=%d, отдельных лимитов=%d",
+		opts.Default.Posts, opts.Default.Comments, opts.Default.Bytes, len(opts.Tenants))
+	return &TenantService{store: store, opts: opts}
+}
+
+// Limits возвращает лимиты сообщества
+func (s *TenantService) Limits(tenantID string) TenantLimits {
+	if limits, ok := s.opts.Tenants[tenantID]; ok {
+		return limits
+	}
+	return s.opts.Default
+}
+
+// Usage возвращает использование хранилища сообществом
+func (s *TenantService) Usage(ctx context.Context, tenantID string) (*models.TenantUsage, error) {
+	usage, err := s.store.GetTenantUsage(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant usage: %v", err)
+	}
+	return usage, nil
+}
+
+// Check возвращает *StorageExceededError, если новая запись kind размером bytes превысит лимит сообщества
+func (s *TenantService) Check(ctx context.Context, tenantID, kind string, bytes int64) error {
+	limits := s.Limits(tenantID)
+	if limits == (TenantLimits{}) {
+		return nil
+	}
+	usage, err := s.Usage(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	resource, limit := "", int64(0)
+	switch {
+	case kind == models.TargetPost && limits.Posts > 0 && usage.Posts >= limits.Posts:
+		resource, limit = ResourcePosts, limits.Posts
+	case kind == models.TargetComment && limits.Comments > 0 && usage.Comments >= limits.Comments:
+		resource, limit = ResourceComments, limits.Comments
+	case limits.Bytes > 0 && usage.Bytes+bytes > limits.Bytes:
+		resource, limit = ResourceBytes, limits.Bytes
+	default:
+		return nil
+	}
+	log.Printf("Квота %s сообщества %s исчерпана: лимит %d", resource, tenantID, limit)
+	metrics.TenantQuotaRejections.WithLabelValues(resource).Inc()
+	return &StorageExceededError{TenantID: tenantID, Resource: resource, Limit: limit}
+}
+
+// Record учитывает созданную запись kind размером bytes
+func (s *TenantService) Record(ctx context.Context, tenantID, kind string, bytes int64) error {
+	if err := s.store.AddTenantUsage(ctx, tenantID, kind, bytes); err != nil {
+		return fmt.Errorf("failed to record tenant usage: %v", err)
+	}
+	return nil
+}
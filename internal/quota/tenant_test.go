@@ -0,0 +1,39 @@
+package quota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantService(t *testing.T) {
+	s := NewTenant(memory.New(), TenantOptions{
+		Default: TenantLimits{Posts: 1, Bytes: 100},
+		Tenants: map[string]TenantLimits{"unlimited": {}},
+	})
+	ctx := context.Background()
+
+	require.NoError(t, s.Check(ctx, "forum", models.TargetPost, 60))
+	require.NoError(t, s.Record(ctx, "forum", models.TargetPost, 60))
+	err := s.Check(ctx, "forum", models.TargetPost, 10)
+	var exceeded *StorageExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, &StorageExceededError{TenantID: "forum", Resource: ResourcePosts, Limit: 1}, exceeded)
+
+	require.NoError(t, s.Check(ctx, "forum", models.TargetComment, 40), "Комментарии без лимита числа")
+	require.NoError(t, s.Record(ctx, "forum", models.TargetComment, 40))
+	require.ErrorAs(t, s.Check(ctx, "forum", models.TargetComment, 1), &exceeded)
+	assert.Equal(t, ResourceBytes, exceeded.Resource)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Check(ctx, "unlimited", models.TargetPost, 1000), "Отдельные лимиты сообщества заменяют лимиты по умолчанию")
+		require.NoError(t, s.Record(ctx, "unlimited", models.TargetPost, 1000))
+	}
+	usage, err := s.Usage(ctx, "unlimited")
+	require.NoError(t, err)
+	assert.Equal(t, &models.TenantUsage{TenantID: "unlimited", Posts: 3, Bytes: 3000}, usage)
+}
@@ -0,0 +1,87 @@
+package ranking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// maxMLResponse ограничивает объём читаемого ответа модели
+const maxMLResponse = 4 << 20
+
+// MLScorer - стратегия MLScore: комментарии оцениваются внешним сервисом. Запрос POST
+// {"comments": [{"id", "content", "upvotes", "downvotes", "createdAt"}]}, ответ {"scores": {"<id>": 0.7}};
+// комментарии без оценки в ответе получают 0
+type MLScorer struct {
+	url    string
+	client *http.Client
+}
+
+var _ Strategy = &MLScorer{}
+
+// NewMLScorer создаёт клиент модели по адресу url; запрос прерывается через timeout
+func NewMLScorer(url string, timeout time.Duration) *MLScorer {
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	return &MLScorer{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Name реализует Strategy
+func (m *MLScorer) Name() string {
+	return MLScore
+}
+
+// mlComment - комментарий в запросе к модели
+type mlComment struct {
+	ID        string    `json:"id"`
+	Content   string    `json:"content"`
+	Upvotes   int       `json:"upvotes"`
+	Downvotes int       `json:"downvotes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Score реализует Strategy
+func (m *MLScorer) Score(ctx context.Context, comments []models.Comment) ([]float64, error) {
+	request := struct {
+		Comments []mlComment `json:"comments"`
+	}{Comments: make([]mlComment, len(comments))}
+	for i, c := range comments {
+		request.Comments[i] = mlComment{ID: c.ID, Content: c.Content, Upvotes: c.Upvotes, Downvotes: c.Downvotes, CreatedAt: c.CreatedAt}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scoring request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build scoring request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("scoring request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("scoring service returned %d: %s", resp.StatusCode, data)
+	}
+	var result struct {
+		Scores map[string]float64 `json:"scores"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMLResponse)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode scores: %v", err)
+	}
+	scores := make([]float64, len(comments))
+	for i, c := range comments {
+		scores[i] = result.Scores[c.ID]
+	}
+	return scores, nil
+}
@@ -0,0 +1,241 @@
+// Package ranking упорядочивает комментарии поста стратегиями ранжирования для экспериментов.
+// Хронологический порядок и BEST хранилище выдаёт само; остальные стратегии переупорядочивают в памяти
+// до MaxCandidates последних комментариев. Стратегия выбирается аргументом запроса, флагами экспериментов
+// или настройкой сообщества, и каждое назначение записывается в лог и метрики для анализа
+package ranking
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Стратегии ранжирования
+const (
+	// Chronological - сначала новые комментарии
+	Chronological = "chronological"
+	// Best - сначала комментарии с наибольшей оценкой storage.WilsonScore
+	Best = "best"
+	// Controversial - сначала комментарии с большим числом голосов, поровну разделённых на «за» и «против»
+	Controversial = "controversial"
+	// MLScore - оценка внешней модели, см. MLScorer
+	MLScore = "ml-score"
+)
+
+// Источники назначения стратегии
+const (
+	SourceRequest    = "request"
+	SourceExperiment = "experiment"
+	SourceTenant     = "tenant"
+)
+
+// Strategy оценивает комментарии одного уровня ветки; комментарии с большей оценкой показываются раньше
+type Strategy interface {
+	// Name - название стратегии для логов, метрик и курсоров
+	Name() string
+	// Score возвращает оценки комментариев в том же порядке
+	Score(ctx context.Context, comments []models.Comment) ([]float64, error)
+}
+
+// StorageOrder возвращает порядок хранилища, совпадающий со стратегией name, или false, если
+// стратегия переупорядочивает комментарии в памяти
+func StorageOrder(name string) (models.SortOrder, bool) {
+	switch name {
+	case Chronological:
+		return models.SortDesc, true
+	case Best:
+		return models.SortBest, true
+	}
+	return "", false
+}
+
+// scoreFunc - стратегия, оценивающая каждый комментарий независимо
+type scoreFunc struct {
+	name  string
+	score func(*models.Comment) float64
+}
+
+// Name реализует Strategy
+func (s scoreFunc) Name() string {
+	return s.name
+}
+
+// Score реализует Strategy
+func (s scoreFunc) Score(ctx context.Context, comments []models.Comment) ([]float64, error) {
+	scores := make([]float64, len(comments))
+	for i := range comments {
+		scores[i] = s.score(&comments[i])
+	}
+	return scores, nil
+}
+
+var (
+	chronological = scoreFunc{name: Chronological, score: func(c *models.Comment) float64 {
+		return float64(c.CreatedAt.UnixNano())
+	}}
+	best = scoreFunc{name: Best, score: func(c *models.Comment) float64 {
+		return storage.WilsonScore(c.Upvotes, c.Downvotes)
+	}}
+	controversial = scoreFunc{name: Controversial, score: func(c *models.Comment) float64 {
+		return Controversy(c.Upvotes, c.Downvotes)
+	}}
+)
+
+// Controversy - оценка спорности: число голосов в степени отношения меньшей части голосов к большей.
+// Комментарий без голосов «за» или «против» получает 0
+func Controversy(upvotes, downvotes int) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	balance := float64(min(upvotes, downvotes)) / float64(max(upvotes, downvotes))
+	return math.Pow(float64(upvotes+downvotes), balance)
+}
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// MaxCandidates - сколько последних комментариев поста переупорядочивается стратегиями,
+	// которых нет в хранилище; более старые комментарии в таком порядке не показываются
+	MaxCandidates int
+	// Tenants - стратегия по умолчанию для сообществ; запросы с order её не используют
+	Tenants map[string]string
+	// MLScore - внешняя модель; nil отключает стратегию MLScore
+	MLScore Strategy
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxCandidates <= 0 {
+		o.MaxCandidates = 500
+	}
+	return o
+}
+
+// Assignment - стратегия, назначенная запросу, и источник назначения
+type Assignment struct {
+	Strategy string
+	Source   string
+}
+
+// Service выбирает стратегию для запроса и упорядочивает комментарии
+type Service struct {
+	opts       Options
+	strategies map[string]Strategy
+}
+
+// New создаёт сервис ранжирования
+func New(opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Ranking Service: кандидатов не больше %d, стратегий сообществ %d, внешняя модель: %t",
+		opts.MaxCandidates, len(opts.Tenants), opts.MLScore != nil)
+	strategies := map[string]Strategy{
+		Chronological: chronological,
+		Best:          best,
+		Controversial: controversial,
+	}
+	if opts.MLScore != nil {
+		strategies[MLScore] = opts.MLScore
+	}
+	return &Service{opts: opts, strategies: strategies}
+}
+
+// MaxCandidates - сколько последних комментариев переупорядочивается в памяти
+func (s *Service) MaxCandidates() int {
+	return s.opts.MaxCandidates
+}
+
+// Assign выбирает стратегию запроса: requested из аргумента запроса, затем эксперименты по флагам
+// flags.RankingMLScore и flags.RankingControversial, затем стратегию сообщества. false означает,
+// что запрос использует обычный порядок комментариев. Назначение записывается в лог и метрики
+func (s *Service) Assign(ctx context.Context, requested string) (Assignment, bool) {
+	var assignment Assignment
+	tenant, _ := ctx.Value("tenant").(string)
+	if tenant == "" {
+		tenant = models.DefaultTenant
+	}
+	switch {
+	case requested != "":
+		assignment = Assignment{Strategy: requested, Source: SourceRequest}
+	case s.opts.MLScore != nil && flags.Enabled(ctx, flags.RankingMLScore):
+		assignment = Assignment{Strategy: MLScore, Source: SourceExperiment}
+	case flags.Enabled(ctx, flags.RankingControversial):
+		assignment = Assignment{Strategy: Controversial, Source: SourceExperiment}
+	case s.opts.Tenants[tenant] != "":
+		assignment = Assignment{Strategy: s.opts.Tenants[tenant], Source: SourceTenant}
+	default:
+		return Assignment{}, false
+	}
+	userID, _ := ctx.Value("userID").(string)
+	log.Printf("Назначение ранжирования комментариев: пользователь=%q, сообщество=%s, стратегия=%s, источник=%s",
+		userID, tenant, assignment.Strategy, assignment.Source)
+	metrics.RankingAssignments.WithLabelValues(assignment.Strategy, assignment.Source).Inc()
+	return assignment, true
+}
+
+// Rank возвращает комментарии, упорядоченные стратегией name по убыванию оценки; при равенстве
+// сохраняется исходный порядок. Если стратегия недоступна или не смогла оценить комментарии,
+// используется Best
+func (s *Service) Rank(ctx context.Context, name string, comments []models.Comment) []models.Comment {
+	strategy, ok := s.strategies[name]
+	if !ok {
+		log.Printf("Стратегия ранжирования %s недоступна, используется %s", name, Best)
+		metrics.RankingFallbacks.WithLabelValues(name).Inc()
+		strategy = best
+	}
+	scores, err := strategy.Score(ctx, comments)
+	if err != nil || len(scores) != len(comments) {
+		log.Printf("Стратегия ранжирования %s не оценила %d комментариев, используется %s: %v", name, len(comments), Best, err)
+		metrics.RankingFallbacks.WithLabelValues(name).Inc()
+		scores, _ = best.Score(ctx, comments)
+	}
+	order := make([]int, len(comments))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(scores[b], scores[a])
+	})
+	ranked := make([]models.Comment, len(comments))
+	for i, index := range order {
+		ranked[i] = comments[index]
+	}
+	return ranked
+}
+
+// cursorMarker отличает курсор ранжированной ленты от курсоров хранилища
+const cursorMarker = "ranking"
+
+// EncodeCursor кодирует позицию после первых offset комментариев, упорядоченных стратегией strategy
+func EncodeCursor(strategy string, offset int) string {
+	raw := cursorMarker + "|" + strategy + "|" + strconv.Itoa(offset)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor возвращает смещение курсора и проверяет, что он выдан для стратегии strategy.
+// Ошибки совпадают с ошибками курсоров хранилища
+func DecodeCursor(cursor, strategy string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, storage.ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || parts[0] != cursorMarker {
+		return 0, storage.ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(parts[2])
+	if err != nil || offset < 1 {
+		return 0, storage.ErrInvalidCursor
+	}
+	if parts[1] != strategy {
+		return 0, storage.ErrCursorOrderMismatch
+	}
+	return offset, nil
+}
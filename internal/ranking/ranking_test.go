@@ -0,0 +1,115 @@
+package ranking
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(comments []models.Comment) []string {
+	result := make([]string, len(comments))
+	for i, c := range comments {
+		result[i] = c.ID
+	}
+	return result
+}
+
+func TestControversy(t *testing.T) {
+	assert.Zero(t, Controversy(10, 0), "Без голосов «против» комментарий не спорный")
+	assert.Zero(t, Controversy(0, 0))
+	assert.Equal(t, 20.0, Controversy(10, 10))
+	assert.Greater(t, Controversy(10, 10), Controversy(15, 5), "При равном числе голосов спорнее равный раздел")
+	assert.Greater(t, Controversy(50, 50), Controversy(10, 10), "При равном разделе спорнее больше голосов")
+}
+
+func TestRank(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	comments := []models.Comment{
+		{ID: "new", CreatedAt: base.Add(3 * time.Hour)},
+		{ID: "loved", Upvotes: 20, CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "split", Upvotes: 10, Downvotes: 9, CreatedAt: base.Add(time.Hour)},
+		{ID: "old", Upvotes: 1, Downvotes: 1, CreatedAt: base},
+	}
+	service := New(Options{})
+	ctx := context.Background()
+	assert.Equal(t, []string{"split", "old", "new", "loved"}, ids(service.Rank(ctx, Controversial, comments)))
+	assert.Equal(t, []string{"new", "loved", "split", "old"}, ids(service.Rank(ctx, Chronological, comments)))
+	assert.Equal(t, []string{"loved", "split", "old", "new"}, ids(service.Rank(ctx, Best, comments)))
+	assert.Equal(t, ids(service.Rank(ctx, Best, comments)), ids(service.Rank(ctx, MLScore, comments)), "Без модели используется best")
+	assert.Equal(t, []string{"new", "loved", "split", "old"}, ids(comments), "Исходный срез не меняется")
+}
+
+func TestMLScorer(t *testing.T) {
+	var received struct {
+		Comments []mlComment `json:"comments"`
+	}
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "model unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte(`{"scores":{"b":0.9,"c":0.5}}`))
+	}))
+	defer server.Close()
+
+	comments := []models.Comment{{ID: "a", Content: "Первый", Upvotes: 5}, {ID: "b", Content: "Второй"}, {ID: "c", Content: "Третий"}}
+	service := New(Options{MLScore: NewMLScorer(server.URL, time.Second)})
+	ctx := context.Background()
+	assert.Equal(t, []string{"b", "c", "a"}, ids(service.Rank(ctx, MLScore, comments)), "Комментарий без оценки получает 0")
+	require.Len(t, received.Comments, 3)
+	assert.Equal(t, mlComment{ID: "a", Content: "Первый", Upvotes: 5}, received.Comments[0])
+
+	fail = true
+	assert.Equal(t, []string{"a", "b", "c"}, ids(service.Rank(ctx, MLScore, comments)), "При ошибке модели используется best")
+}
+
+func TestAssign(t *testing.T) {
+	flagService := flags.New(flags.Options{Static: map[string]flags.Flag{
+		flags.RankingControversial: {Users: []string{"user1"}},
+		flags.RankingMLScore:       {Users: []string{"user2"}},
+	}})
+	service := New(Options{Tenants: map[string]string{"forum": Best}})
+	request := func(userID, tenant string) context.Context {
+		ctx := context.WithValue(context.Background(), "userID", userID)
+		ctx = context.WithValue(ctx, "tenant", tenant)
+		return flags.NewContext(ctx, flagService.ForTenant(userID, tenant))
+	}
+
+	assignment, ok := service.Assign(request("user1", "forum"), Chronological)
+	assert.True(t, ok)
+	assert.Equal(t, Assignment{Strategy: Chronological, Source: SourceRequest}, assignment)
+	assignment, _ = service.Assign(request("user1", "forum"), "")
+	assert.Equal(t, Assignment{Strategy: Controversial, Source: SourceExperiment}, assignment, "Эксперимент важнее настройки сообщества")
+	assignment, _ = service.Assign(request("user2", "forum"), "")
+	assert.Equal(t, Assignment{Strategy: Best, Source: SourceTenant}, assignment, "Без модели эксперимент ml-score не назначается")
+	_, ok = service.Assign(request("user3", "other"), "")
+	assert.False(t, ok)
+
+	withModel := New(Options{MLScore: NewMLScorer("http://localhost", time.Second)})
+	assignment, _ = withModel.Assign(request("user2", "other"), "")
+	assert.Equal(t, Assignment{Strategy: MLScore, Source: SourceExperiment}, assignment)
+}
+
+func TestCursor(t *testing.T) {
+	offset, err := DecodeCursor(EncodeCursor(Controversial, 20), Controversial)
+	require.NoError(t, err)
+	assert.Equal(t, 20, offset)
+
+	_, err = DecodeCursor(EncodeCursor(Controversial, 20), MLScore)
+	assert.ErrorIs(t, err, storage.ErrCursorOrderMismatch)
+	_, err = DecodeCursor(storage.EncodeOffsetCursor(models.SortDesc, 20), Controversial)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor, "Курсор хранилища не подходит ранжированной ленте")
+	_, err = DecodeCursor("!", Controversial)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
@@ -0,0 +1,92 @@
+// Package references находит в содержимом поста ссылки на другие посты: адреса вида .../posts/<id или slug>,
+// явные ссылки post:<id> и ID в формате UUID. Найденные посты сохраняются как связи для Post.references
+// и Post.referencedBy
+package references
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// MaxPerPost ограничивает количество ссылок, сохраняемых для одного поста
+const MaxPerPost = 20
+
+var (
+	urlPattern  = regexp.MustCompile(`https?://[^\s<>"'()]+`)
+	idPattern   = regexp.MustCompile(`\bpost:([A-Za-z0-9_-]+)`)
+	uuidPattern = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+)
+
+// Extract возвращает ключи постов, упомянутых в content, без повторов и в порядке появления.
+// Ключ - ID или slug поста; существование постов не проверяется
+func Extract(content string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	// Адреса разбираются первыми, и UUID внутри них не считается отдельной ссылкой
+	content = urlPattern.ReplaceAllStringFunc(content, func(raw string) string {
+		add(postKey(raw))
+		return " "
+	})
+	for _, match := range idPattern.FindAllStringSubmatch(content, -1) {
+		add(match[1])
+	}
+	for _, id := range uuidPattern.FindAllString(content, -1) {
+		add(strings.ToLower(id))
+	}
+	return keys
+}
+
+// postKey возвращает сегмент пути после /posts/ или пустую строку, если адрес не ведёт на пост
+func postKey(raw string) string {
+	u, err := url.Parse(strings.TrimRight(raw, ".,;:!?"))
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "posts" {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
+
+// Resolve находит посты, на которые ссылается content поста postID, и возвращает их ID, не больше MaxPerPost.
+// Ключ ищется сначала как ID, затем как slug; несуществующие посты и ссылка поста на себя пропускаются
+func Resolve(ctx context.Context, store storage.Storage, postID, content string) ([]string, error) {
+	var ids []string
+	seen := map[string]bool{postID: true}
+	for _, key := range Extract(content) {
+		if len(ids) == MaxPerPost {
+			break
+		}
+		post, err := store.GetPost(ctx, key)
+		if errors.Is(err, storage.ErrPostNotFound) {
+			post, err = store.GetPostBySlug(ctx, key)
+		}
+		if errors.Is(err, storage.ErrPostNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[post.ID] {
+			seen[post.ID] = true
+			ids = append(ids, post.ID)
+		}
+	}
+	log.Printf("Ссылки поста %s на другие посты: %v", postID, ids)
+	return ids, nil
+}
@@ -0,0 +1,45 @@
+package references
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"Без ссылок", "Просто текст со ссылкой https://example.com/about", nil},
+		{"Адрес поста", "Смотрите https://blog.example.com/posts/kak-nachat. И ещё раз https://blog.example.com/posts/kak-nachat", []string{"kak-nachat"}},
+		{"Адрес с UUID", "https://example.com/posts/0F8FAD5B-D9CB-469F-A165-70867728950E?ref=feed", []string{"0F8FAD5B-D9CB-469F-A165-70867728950E"}},
+		{"Явная ссылка", "Продолжение в post:01HZX3 и post:42", []string{"01HZX3", "42"}},
+		{"UUID в тексте", "Ответ на 0f8fad5b-d9cb-469f-a165-70867728950e", []string{"0f8fad5b-d9cb-469f-a165-70867728950e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Extract(tt.content))
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	for _, post := range []*models.Post{
+		{ID: "p1", Title: "Первый", Slug: "pervyi"},
+		{ID: "p2", Title: "Второй", Slug: "vtoroi"},
+	} {
+		require.NoError(t, store.CreatePost(ctx, post))
+	}
+
+	ids, err := Resolve(ctx, store, "p1", "См. post:p2, https://example.com/posts/vtoroi, post:p1 и post:missing")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p2"}, ids, "Ссылка на себя, повторы и несуществующие посты пропускаются")
+}
@@ -0,0 +1,152 @@
+// Package related подбирает похожие посты для блока «вам может понравиться».
+// Подбор требует сравнения поста со всеми кандидатами, поэтому список для каждого поста
+// кешируется и пересчитывается по истечении RefreshInterval при очередном запросе
+package related
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// cacheName - метка кеша похожих постов в метриках
+const cacheName = "related"
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// RefreshInterval - как долго используется рассчитанный список до пересчёта
+	RefreshInterval time.Duration
+	// Size - число постов, для которых хранятся списки
+	Size int
+	// MaxLimit - длина хранимого списка; большие limit в запросах ограничиваются им
+	MaxLimit int
+	// Clock - источник времени для срока жизни списков, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = 10 * time.Minute
+	}
+	if o.Size <= 0 {
+		o.Size = 1000
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 20
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// entry - рассчитанный список похожих постов
+type entry struct {
+	posts    []*models.Post
+	loadedAt time.Time
+}
+
+// Service возвращает похожие посты с кешированием по ID поста
+type Service struct {
+	store   storage.Storage
+	opts    Options
+	entries *lru.Cache[string, entry]
+	clock   clock.Clock
+}
+
+// New создаёт сервис похожих постов поверх хранилища
+func New(store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Related Service: интервал пересчёта=%v, размер кеша=%d", opts.RefreshInterval, opts.Size)
+	entries, _ := lru.New[string, entry](opts.Size)
+	return &Service{store: store, opts: opts, entries: entries, clock: opts.Clock}
+}
+
+// Get возвращает до limit постов, похожих на postID. Если пересчитать устаревший список не удалось,
+// используется прежний. С флагом flags.RelatedRecency свежие посты поднимаются выше
+func (s *Service) Get(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	if limit > s.opts.MaxLimit {
+		limit = s.opts.MaxLimit
+	}
+	posts, err := s.get(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if flags.Enabled(ctx, flags.RelatedRecency) {
+		posts = s.rankByRecency(ctx, postID, posts)
+	}
+	return head(posts, limit), nil
+}
+
+// get возвращает список похожих постов длины MaxLimit из кеша или пересчитывает его
+func (s *Service) get(ctx context.Context, postID string) ([]*models.Post, error) {
+	cached, ok := s.entries.Get(postID)
+	if ok && s.clock.Now().Sub(cached.loadedAt) < s.opts.RefreshInterval {
+		metrics.CacheRequests.WithLabelValues(cacheName, "hit").Inc()
+		return cached.posts, nil
+	}
+	metrics.CacheRequests.WithLabelValues(cacheName, "miss").Inc()
+	posts, err := s.store.RelatedPosts(ctx, postID, s.opts.MaxLimit)
+	if err != nil {
+		if ok && !errors.Is(err, storage.ErrPostNotFound) {
+			log.Printf("Не удалось пересчитать похожие посты для %s, используется прежний список: %v", postID, err)
+			return cached.posts, nil
+		}
+		return nil, err
+	}
+	s.entries.Add(postID, entry{posts: posts, loadedAt: s.clock.Now()})
+	return posts, nil
+}
+
+// RecencyHalfLife - возраст, за который оценка похожего поста уменьшается вдвое при ранжировании
+// с флагом flags.RelatedRecency
+const RecencyHalfLife = 30 * 24 * time.Hour
+
+// rankByRecency упорядочивает список по RelatedScore, уменьшенной вдвое за каждые RecencyHalfLife возраста поста.
+// Кешированный список не меняется; если исходный пост прочитать не удалось, порядок остаётся прежним
+func (s *Service) rankByRecency(ctx context.Context, postID string, posts []*models.Post) []*models.Post {
+	post, err := s.store.GetPost(ctx, postID)
+	if err != nil {
+		log.Printf("Не удалось прочитать пост %s для ранжирования по свежести: %v", postID, err)
+		return posts
+	}
+	now := s.clock.Now()
+	scores := make(map[string]float64, len(posts))
+	for _, candidate := range posts {
+		score, _ := storage.RelatedScore(post, candidate)
+		age := now.Sub(candidate.CreatedAt)
+		if age < 0 {
+			age = 0
+		}
+		scores[candidate.ID] = score * math.Exp2(-float64(age)/float64(RecencyHalfLife))
+	}
+	ranked := slices.Clone(posts)
+	slices.SortStableFunc(ranked, func(a, b *models.Post) int {
+		return cmp.Compare(scores[b.ID], scores[a.ID])
+	})
+	return ranked
+}
+
+// Invalidate сбрасывает список поста; вызывается после изменения его заголовка, тегов или категории
+func (s *Service) Invalidate(postID string) {
+	s.entries.Remove(postID)
+}
+
+// head возвращает первые limit постов
+func head(posts []*models.Post, limit int) []*models.Post {
+	if len(posts) > limit {
+		return posts[:limit]
+	}
+	return posts
+}
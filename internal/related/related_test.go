@@ -0,0 +1,114 @@
+package related
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore возвращает ошибку из RelatedPosts, когда fail установлен
+type failingStore struct {
+	storage.Storage
+	fail bool
+}
+
+func (s *failingStore) RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	if s.fail {
+		return nil, errors.New("база недоступна")
+	}
+	return s.Storage.RelatedPosts(ctx, postID, limit)
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	store := &failingStore{Storage: memory.New()}
+	base := time.Now()
+	create := func(id, title string) {
+		require.NoError(t, store.CreatePost(ctx, &models.Post{ID: id, Title: title, Tags: []string{"космос"}, CreatedAt: base}))
+	}
+	create("p1", "Запуск ракеты")
+	create("p2", "Посадка на Луну")
+
+	fake := clock.NewFake(base)
+	service := New(store, Options{RefreshInterval: time.Minute, MaxLimit: 2, Clock: fake})
+
+	posts, err := service.Get(ctx, "p1", 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "p2", posts[0].ID)
+
+	create("p3", "Запуск ракеты перенесён")
+	posts, err = service.Get(ctx, "p1", 10)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1, "До пересчёта используется кешированный список")
+
+	fake.Advance(2 * time.Minute)
+	store.fail = true
+	posts, err = service.Get(ctx, "p1", 10)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1, "При ошибке пересчёта используется прежний список")
+
+	store.fail = false
+	posts, err = service.Get(ctx, "p1", 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 2, "Устаревший список пересчитывается")
+	assert.Equal(t, "p3", posts[0].ID, "Похожий заголовок поднимает пост выше")
+
+	posts, err = service.Get(ctx, "p1", 1)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+
+	_, err = service.Get(ctx, "missing", 5)
+	assert.ErrorIs(t, err, storage.ErrPostNotFound)
+}
+
+func TestService_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "p1", Title: "Запуск ракеты", CreatedAt: time.Now()}))
+	service := New(store, Options{})
+
+	posts, err := service.Get(ctx, "p1", 5)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "p2", Title: "Запуск ракеты отложен", CreatedAt: time.Now()}))
+	service.Invalidate("p1")
+	posts, err = service.Get(ctx, "p1", 5)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+}
+
+func TestService_RecencyFlag(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	base := time.Now()
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "p1", Title: "Запуск ракеты", CreatedAt: base}))
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "old", Title: "Запуск ракеты перенесён", CreatedAt: base.Add(-365 * 24 * time.Hour)}))
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "new", Title: "Запуск", CreatedAt: base}))
+	service := New(store, Options{Clock: clock.NewFake(base)})
+
+	posts, err := service.Get(ctx, "p1", 5)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "old", posts[0].ID, "Без флага порядок определяет только сходство")
+
+	flagService := flags.New(flags.Options{Static: map[string]flags.Flag{flags.RelatedRecency: {Percentage: 100}}})
+	posts, err = service.Get(flags.NewContext(ctx, flagService.For("user1")), "p1", 5)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "new", posts[0].ID, "С флагом свежий пост поднимается выше")
+
+	posts, err = service.Get(ctx, "p1", 5)
+	require.NoError(t, err)
+	assert.Equal(t, "old", posts[0].ID, "Кешированный список не меняется")
+}
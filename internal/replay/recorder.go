@@ -0,0 +1,169 @@
+// Package replay записывает мутации рабочего сервера и воспроизводит их на другом хранилище,
+// сравнивая ответы: так проверяется перенос данных на новое хранилище до переключения на него
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Redacted заменяет значения переменных, которые нельзя сохранять в записи
+const Redacted = "[REDACTED]"
+
+// sensitiveNames - части имён переменных со значениями, которые не попадают в запись
+var sensitiveNames = []string{"password", "token", "secret", "apikey"}
+
+// Record - одна записанная мутация: кто и когда её выполнил, запрос и полученный ответ
+type Record struct {
+	Time          time.Time      `json:"time"`
+	UserID        string         `json:"userId,omitempty"`
+	Role          string         `json:"role,omitempty"`
+	Tenant        string         `json:"tenant,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	// Data - поле data ответа
+	Data json.RawMessage `json:"data"`
+	// Errors - коды ошибок ответа по порядку, у ошибки без кода - её текст
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Recorder записывает выполненные мутации в файл JSON Lines, по записи на строку.
+// Подключается к GraphQL-серверу как расширение. Значения переменных с паролями, токенами
+// и ключами заменяются на Redacted; значения, записанные прямо в тексте запроса, сохраняются как есть
+type Recorder struct {
+	mu  sync.Mutex
+	out io.WriteCloser
+	enc *json.Encoder
+}
+
+var _ interface {
+	graphql.ResponseInterceptor
+	graphql.HandlerExtension
+} = &Recorder{}
+
+// NewRecorder открывает файл path для дозаписи мутаций
+func NewRecorder(path string) (*Recorder, error) {
+	log.Printf("Создание Recorder: файл %s", path)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %v", err)
+	}
+	return newRecorder(file), nil
+}
+
+func newRecorder(out io.WriteCloser) *Recorder {
+	return &Recorder{out: out, enc: json.NewEncoder(out)}
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (r *Recorder) ExtensionName() string {
+	return "MutationRecorder"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (r *Recorder) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse реализует graphql.ResponseInterceptor: записывает мутацию вместе с ответом
+func (r *Recorder) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	oc := graphql.GetOperationContext(ctx)
+	if resp == nil || oc.Operation == nil || oc.Operation.Operation != ast.Mutation {
+		return resp
+	}
+	rec := Record{
+		Time:          oc.Stats.OperationStart.UTC(),
+		OperationName: oc.OperationName,
+		Query:         oc.RawQuery,
+		Variables:     sanitize(oc.Variables),
+		Data:          resp.Data,
+		Errors:        errorCodes(resp.Errors),
+	}
+	rec.UserID, _ = ctx.Value("userID").(string)
+	rec.Role, _ = ctx.Value("role").(string)
+	rec.Tenant, _ = ctx.Value("tenant").(string)
+	if err := r.write(rec); err != nil {
+		log.Printf("Ошибка при записи мутации %s: %v", oc.OperationName, err)
+	}
+	return resp
+}
+
+func (r *Recorder) write(rec Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(rec)
+}
+
+// Close закрывает файл записи
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.out.Close()
+}
+
+// sanitize возвращает копию переменных, в которой значения с чувствительными именами заменены на Redacted
+func sanitize(variables map[string]any) map[string]any {
+	if variables == nil {
+		return nil
+	}
+	clean := make(map[string]any, len(variables))
+	for name, value := range variables {
+		if sensitive(name) {
+			clean[name] = Redacted
+			continue
+		}
+		clean[name] = sanitizeValue(value)
+	}
+	return clean
+}
+
+func sanitizeValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return sanitize(v)
+	case []any:
+		clean := make([]any, len(v))
+		for i, item := range v {
+			clean[i] = sanitizeValue(item)
+		}
+		return clean
+	default:
+		return value
+	}
+}
+
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveNames {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCodes возвращает коды ошибок ответа
+func errorCodes(errs gqlerror.List) []string {
+	var codes []string
+	for _, err := range errs {
+		code, _ := err.Extensions["code"].(string)
+		if code == "" {
+			code = err.Message
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
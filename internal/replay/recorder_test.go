@@ -0,0 +1,23 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	variables := map[string]any{
+		"token":   "device-token",
+		"content": "Текст",
+		"input":   map[string]any{"apiKey": "key", "siteName": "Форум"},
+		"list":    []any{map[string]any{"password": "secret"}},
+	}
+	assert.Equal(t, map[string]any{
+		"token":   Redacted,
+		"content": "Текст",
+		"input":   map[string]any{"apiKey": Redacted, "siteName": "Форум"},
+		"list":    []any{map[string]any{"password": Redacted}},
+	}, sanitize(variables))
+	assert.Equal(t, "device-token", variables["token"], "Исходные переменные не меняются")
+}
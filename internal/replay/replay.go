@@ -0,0 +1,267 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+)
+
+// maxRecordSize - наибольший размер строки записи
+const maxRecordSize = 16 << 20
+
+// Options - параметры воспроизведения
+type Options struct {
+	// Clock переводится на время записи перед каждой мутацией, чтобы ограничения по времени
+	// срабатывали так же, как при записи; nil оставляет часы как есть
+	Clock *clock.Fake
+}
+
+// Diff - расхождение ответа воспроизведённой мутации с записанным
+type Diff struct {
+	// Index - номер мутации в записи, начиная с 1
+	Index         int
+	OperationName string
+	// Path - путь к первому различию, например data.createPost.title
+	Path     string
+	Recorded any
+	Replayed any
+}
+
+func (d Diff) String() string {
+	recorded, _ := json.Marshal(d.Recorded)
+	replayed, _ := json.Marshal(d.Replayed)
+	return fmt.Sprintf("#%d %s: %s: recorded %s, replayed %s", d.Index, d.OperationName, d.Path, recorded, replayed)
+}
+
+// Result - итог воспроизведения
+type Result struct {
+	Total int
+	Diffs []Diff
+}
+
+// Replay выполняет записанные мутации из in по порядку через GraphQL-обработчик handler
+// от имени записанных пользователей и сравнивает ответы с записанными.
+// Идентификаторы новых сущностей при воспроизведении другие: соответствие записанных и новых
+// ID запоминается по полям id и *Id ответов и подставляется в переменные и текст следующих
+// мутаций. Время в ответах сравнивается с точностью до секунды
+func Replay(ctx context.Context, handler http.Handler, in io.Reader, opts Options) (*Result, error) {
+	result := &Result{}
+	ids := idMap{}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return result, fmt.Errorf("failed to parse record %d: %v", result.Total+1, err)
+		}
+		result.Total++
+		if opts.Clock != nil {
+			opts.Clock.Set(rec.Time)
+		}
+		replayed, err := execute(ctx, handler, rec, ids)
+		if err != nil {
+			return result, fmt.Errorf("failed to replay record %d: %v", result.Total, err)
+		}
+
+		var recorded any
+		if len(rec.Data) > 0 {
+			if err := json.Unmarshal(rec.Data, &recorded); err != nil {
+				return result, fmt.Errorf("failed to parse data of record %d: %v", result.Total, err)
+			}
+		}
+		ids.learn(recorded, replayed.data)
+		if path, want, got, ok := ids.compare("data", recorded, replayed.data); !ok {
+			result.Diffs = append(result.Diffs, Diff{Index: result.Total, OperationName: rec.OperationName, Path: path, Recorded: want, Replayed: got})
+		} else if !reflect.DeepEqual(rec.Errors, replayed.errors) {
+			result.Diffs = append(result.Diffs, Diff{Index: result.Total, OperationName: rec.OperationName, Path: "errors", Recorded: rec.Errors, Replayed: replayed.errors})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("failed to read records: %v", err)
+	}
+	log.Printf("Воспроизведено мутаций: %d, расхождений: %d", result.Total, len(result.Diffs))
+	return result, nil
+}
+
+// response - ответ воспроизведённой мутации
+type response struct {
+	data   any
+	errors []string
+}
+
+// execute выполняет мутацию записи через handler, подставляя новые ID вместо записанных
+func execute(ctx context.Context, handler http.Handler, rec Record, ids idMap) (*response, error) {
+	query := rec.Query
+	for recorded, replayed := range ids {
+		query = strings.ReplaceAll(query, `"`+recorded+`"`, `"`+replayed+`"`)
+	}
+	body, err := json.Marshal(map[string]any{
+		"query":         query,
+		"operationName": rec.OperationName,
+		"variables":     ids.replace(rec.Variables),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, "tenant", rec.Tenant)
+	if rec.UserID != "" {
+		ctx = context.WithValue(ctx, "userID", rec.UserID)
+		ctx = context.WithValue(ctx, "role", rec.Role)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var raw struct {
+		Data   any `json:"data"`
+		Errors []struct {
+			Message    string         `json:"message"`
+			Extensions map[string]any `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+		return nil, fmt.Errorf("unexpected response with status %d: %v", w.Code, err)
+	}
+	resp := &response{data: raw.Data}
+	for _, e := range raw.Errors {
+		code, _ := e.Extensions["code"].(string)
+		if code == "" {
+			code = e.Message
+		}
+		resp.errors = append(resp.errors, code)
+	}
+	return resp, nil
+}
+
+// idMap - соответствие записанных ID сущностей и ID, созданных при воспроизведении
+type idMap map[string]string
+
+// idField сообщает, содержит ли поле ответа ID сущности
+func idField(name string) bool {
+	return name == "id" || strings.HasSuffix(name, "Id")
+}
+
+// learn запоминает новые ID из полей ответа, которые отличаются от записанных
+func (m idMap) learn(recorded, replayed any) {
+	switch want := recorded.(type) {
+	case map[string]any:
+		got, ok := replayed.(map[string]any)
+		if !ok {
+			return
+		}
+		for name, value := range want {
+			from, isString := value.(string)
+			to, _ := got[name].(string)
+			if idField(name) && isString && to != "" && from != to {
+				if _, known := m[from]; !known {
+					m[from] = to
+				}
+				continue
+			}
+			m.learn(value, got[name])
+		}
+	case []any:
+		got, ok := replayed.([]any)
+		if !ok {
+			return
+		}
+		for i := 0; i < len(want) && i < len(got); i++ {
+			m.learn(want[i], got[i])
+		}
+	}
+}
+
+// replace возвращает копию значения, в которой записанные ID заменены новыми
+func (m idMap) replace(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for name, item := range v {
+			out[name] = m.replace(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = m.replace(item)
+		}
+		return out
+	case string:
+		if replayed, ok := m[v]; ok {
+			return replayed
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// compare сравнивает записанное значение с воспроизведённым с учётом новых ID и возвращает
+// путь к первому различию и различающиеся значения
+func (m idMap) compare(path string, recorded, replayed any) (string, any, any, bool) {
+	switch want := recorded.(type) {
+	case map[string]any:
+		got, ok := replayed.(map[string]any)
+		if !ok || len(got) != len(want) {
+			return path, recorded, replayed, false
+		}
+		for name, value := range want {
+			if diffPath, a, b, ok := m.compare(path+"."+name, value, got[name]); !ok {
+				return diffPath, a, b, false
+			}
+		}
+		return "", nil, nil, true
+	case []any:
+		got, ok := replayed.([]any)
+		if !ok || len(got) != len(want) {
+			return path, recorded, replayed, false
+		}
+		for i := range want {
+			if diffPath, a, b, ok := m.compare(fmt.Sprintf("%s[%d]", path, i), want[i], got[i]); !ok {
+				return diffPath, a, b, false
+			}
+		}
+		return "", nil, nil, true
+	case string:
+		got, ok := replayed.(string)
+		if ok && (got == want || m[want] == got || sameTime(want, got)) {
+			return "", nil, nil, true
+		}
+		return path, recorded, replayed, false
+	default:
+		if reflect.DeepEqual(recorded, replayed) {
+			return "", nil, nil, true
+		}
+		return path, recorded, replayed, false
+	}
+}
+
+// sameTime сообщает, что обе строки - время RFC 3339, отличающееся не больше чем на секунду
+func sameTime(a, b string) bool {
+	ta, err := time.Parse(time.RFC3339, a)
+	if err != nil {
+		return false
+	}
+	tb, err := time.Parse(time.RFC3339, b)
+	if err != nil {
+		return false
+	}
+	d := ta.Sub(tb)
+	return d >= -time.Second && d <= time.Second
+}
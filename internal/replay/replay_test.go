@@ -0,0 +1,104 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	mygraphql "github.com/ButyrinIA/system/internal/graphql"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buffer - io.WriteCloser поверх bytes.Buffer
+type buffer struct {
+	bytes.Buffer
+}
+
+func (b *buffer) Close() error {
+	return nil
+}
+
+// newHandler собирает GraphQL-обработчик над новым хранилищем в памяти; пользователь берётся из X-User
+func newHandler(clk clock.Clock, recorder *Recorder) http.Handler {
+	resolver := mygraphql.NewResolver(memory.New(), nil)
+	resolver.Clock = clk
+	srv := handler.New(mygraphql.NewExecutableSchema(mygraphql.Config{Resolvers: resolver, Directives: mygraphql.Directives()}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(gqlerrors.Presenter)
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		if userID := graphql.GetOperationContext(ctx).Headers.Get("X-User"); userID != "" {
+			ctx = context.WithValue(ctx, "userID", userID)
+		}
+		return next(ctx)
+	})
+	if recorder != nil {
+		srv.Use(recorder)
+	}
+	return srv
+}
+
+// post выполняет операцию от имени userID и возвращает data ответа
+func post(t *testing.T, h http.Handler, userID, query string, variables map[string]any) map[string]any {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", userID)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var resp struct {
+		Data map[string]any `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func TestReplay(t *testing.T) {
+	recorded := &buffer{}
+	source := newHandler(clock.Real(), newRecorder(recorded))
+	created := post(t, source, "user1", `mutation { createPost(title: "Пост", content: "Текст", allowComments: true) { id title createdAt } }`, nil)
+	postID := created["createPost"].(map[string]any)["id"].(string)
+	comment := post(t, source, "user1", `mutation($post: ID!) { createComment(postId: $post, content: "Корень") { id postId } }`, map[string]any{"post": postID})
+	commentID := comment["createComment"].(map[string]any)["id"].(string)
+	post(t, source, "user2", `mutation { createComment(postId: "`+postID+`", parentId: "`+commentID+`", content: "Ответ") { id parentId authorId } }`, nil)
+	post(t, source, "", `mutation { createComment(postId: "missing", content: "Ошибка") { id } }`, nil)
+	post(t, source, "user1", `{ post(id: "`+postID+`") { id } }`, nil)
+
+	lines := strings.Split(strings.TrimSpace(recorded.String()), "\n")
+	require.Len(t, lines, 4, "Записываются только мутации")
+	var first Record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "user1", first.UserID)
+	assert.False(t, first.Time.IsZero())
+
+	clk := clock.NewFake(time.Time{})
+	result, err := Replay(context.Background(), newHandler(clk, nil), strings.NewReader(recorded.String()), Options{Clock: clk})
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Total)
+	assert.Empty(t, result.Diffs, "Новые ID подставляются в переменные и текст запросов")
+	var last Record
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &last))
+	assert.Equal(t, []string{gqlerrors.CodeBadUserInput}, last.Errors)
+	assert.True(t, last.Time.Equal(clk.Now()), "Часы переводятся на время записи")
+
+	tampered := strings.Replace(recorded.String(), `"title":"Пост"`, `"title":"Другой"`, 1)
+	result, err = Replay(context.Background(), newHandler(clock.Real(), nil), strings.NewReader(tampered), Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Diffs, 1)
+	assert.Equal(t, Diff{Index: 1, Path: "data.createPost.title", Recorded: "Другой", Replayed: "Пост"}, result.Diffs[0])
+
+	_, err = Replay(context.Background(), newHandler(clock.Real(), nil), strings.NewReader("{broken\n"), Options{})
+	assert.Error(t, err)
+}
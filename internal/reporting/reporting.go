@@ -0,0 +1,92 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/google/uuid"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Event описывает перехваченную ошибку для отправки во внешнюю систему
+type Event struct {
+	ID    string
+	Err   error
+	Stack []byte
+	// PCs - стек вызовов в точке перехвата паники, см. Callers
+	PCs       []uintptr
+	Operation string
+	Path      string
+	UserID    string
+	Time      time.Time
+}
+
+// Callers возвращает стек вызовов для Event.PCs. Вызванный в отложенной функции, перехватившей панику,
+// он включает и функцию, в которой паника произошла: её кадры ещё на стеке горутины
+func Callers() []uintptr {
+	pcs := make([]uintptr, 64)
+	return pcs[:runtime.Callers(2, pcs)]
+}
+
+// Reporter отправляет события об ошибках во внешнюю систему
+type Reporter interface {
+	Report(ctx context.Context, event *Event)
+	// Flush дожидается отправки накопленных событий не дольше timeout и сообщает, успел ли
+	Flush(timeout time.Duration) bool
+}
+
+// LogReporter пишет события в стандартный лог
+type LogReporter struct{}
+
+// NewLogReporter создаёт репортер, пишущий в лог
+func NewLogReporter() *LogReporter {
+	return &LogReporter{}
+}
+
+// Report реализует Reporter
+func (r *LogReporter) Report(ctx context.Context, event *Event) {
+	log.Printf("Ошибка %s в операции %s (путь %s): %v\n%s", event.ID, event.Operation, event.Path, event.Err, event.Stack)
+}
+
+// Flush реализует Reporter: лог пишется сразу, ждать нечего
+func (r *LogReporter) Flush(timeout time.Duration) bool {
+	return true
+}
+
+// RecoverFunc возвращает обработчик паник резолверов: паника превращается в ошибку INTERNAL
+// с уникальным errorId, а событие со стеком уходит в репортер
+func RecoverFunc(reporter Reporter) graphql.RecoverFunc {
+	return func(ctx context.Context, p interface{}) error {
+		event := &Event{
+			ID:    uuid.New().String(),
+			Err:   fmt.Errorf("panic: %v", p),
+			Stack: debug.Stack(),
+			PCs:   Callers(),
+			Time:  time.Now(),
+		}
+		if graphql.HasOperationContext(ctx) {
+			event.Operation = graphql.GetOperationContext(ctx).OperationName
+		}
+		if fc := graphql.GetFieldContext(ctx); fc != nil {
+			event.Path = fc.Path().String()
+		}
+		if userID, ok := ctx.Value("userID").(string); ok {
+			event.UserID = userID
+		}
+		reporter.Report(ctx, event)
+
+		return &gqlerror.Error{
+			Message: "internal server error",
+			Extensions: map[string]interface{}{
+				"code":    gqlerrors.CodeInternal,
+				"errorId": event.ID,
+			},
+		}
+	}
+}
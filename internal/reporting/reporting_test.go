@@ -0,0 +1,74 @@
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+type recordingReporter struct {
+	events []*Event
+}
+
+func (r *recordingReporter) Report(ctx context.Context, event *Event) {
+	r.events = append(r.events, event)
+}
+
+func (r *recordingReporter) Flush(timeout time.Duration) bool {
+	return true
+}
+
+func TestRecoverFunc(t *testing.T) {
+	reporter := &recordingReporter{}
+	recoverFunc := RecoverFunc(reporter)
+	ctx := context.WithValue(context.Background(), "userID", "user1")
+
+	err := recoverFunc(ctx, "что-то сломалось")
+
+	gqlErr, ok := err.(*gqlerror.Error)
+	assert.True(t, ok, "Ожидалась ошибка gqlerror")
+	assert.Equal(t, "internal server error", gqlErr.Message)
+	assert.Equal(t, gqlerrors.CodeInternal, gqlErr.Extensions["code"])
+
+	assert.Len(t, reporter.events, 1)
+	event := reporter.events[0]
+	assert.Equal(t, event.ID, gqlErr.Extensions["errorId"])
+	assert.Equal(t, "panic: что-то сломалось", event.Err.Error())
+	assert.Equal(t, "user1", event.UserID)
+	assert.NotEmpty(t, event.Stack)
+}
+
+func TestRecoverFunc_UniqueIDs(t *testing.T) {
+	reporter := &recordingReporter{}
+	recoverFunc := RecoverFunc(reporter)
+
+	first := recoverFunc(context.Background(), "первая").(*gqlerror.Error)
+	second := recoverFunc(context.Background(), "вторая").(*gqlerror.Error)
+	assert.NotEqual(t, first.Extensions["errorId"], second.Extensions["errorId"])
+}
+
+func panicking() {
+	panic("сбой")
+}
+
+func TestStacktrace_FromRecoveryPoint(t *testing.T) {
+	var pcs []uintptr
+	func() {
+		defer func() {
+			recover()
+			pcs = Callers()
+		}()
+		panicking()
+	}()
+
+	trace := stacktrace(pcs)
+	if assert.NotNil(t, trace) && assert.NotEmpty(t, trace.Frames) {
+		last := trace.Frames[len(trace.Frames)-1]
+		assert.Equal(t, "panicking", last.Function)
+	}
+	assert.Nil(t, stacktrace(nil))
+}
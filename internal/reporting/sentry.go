@@ -0,0 +1,85 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// SentryReporter отправляет события в Sentry
+type SentryReporter struct {
+	client *sentry.Client
+}
+
+// NewSentry создаёт репортер Sentry по DSN
+func NewSentry(dsn string, environment string) (*SentryReporter, error) {
+	log.Printf("Инициализация Sentry, окружение: %s", environment)
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         dsn,
+		Environment: environment,
+	})
+	if err != nil {
+		log.Printf("Ошибка инициализации Sentry: %v", err)
+		return nil, fmt.Errorf("failed to init sentry: %v", err)
+	}
+	return &SentryReporter{client: client}, nil
+}
+
+// Report реализует Reporter
+func (r *SentryReporter) Report(ctx context.Context, event *Event) {
+	sentryEvent := sentry.NewEvent()
+	sentryEvent.EventID = sentry.EventID(strings.ReplaceAll(event.ID, "-", ""))
+	sentryEvent.Level = sentry.LevelError
+	sentryEvent.Message = event.Err.Error()
+	sentryEvent.Timestamp = event.Time
+	sentryEvent.Transaction = event.Operation
+	sentryEvent.Exception = []sentry.Exception{{
+		Type:       "panic",
+		Value:      event.Err.Error(),
+		Stacktrace: stacktrace(event.PCs),
+	}}
+	sentryEvent.Tags = map[string]string{"error_id": event.ID}
+	sentryEvent.Extra = map[string]interface{}{
+		"path":  event.Path,
+		"stack": string(event.Stack),
+	}
+	if event.UserID != "" {
+		sentryEvent.User = sentry.User{ID: event.UserID}
+	}
+	r.client.CaptureEvent(sentryEvent, nil, nil)
+}
+
+// stacktrace строит стек Sentry из стека точки перехвата паники, от внешних вызовов к месту паники.
+// Кадры обработчика над runtime.gopanic и кадры runtime пропускаются
+func stacktrace(pcs []uintptr) *sentry.Stacktrace {
+	if len(pcs) == 0 {
+		return nil
+	}
+	var frames []sentry.Frame
+	callers := runtime.CallersFrames(pcs)
+	for {
+		frame, more := callers.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			frames = frames[:0]
+		case !strings.HasPrefix(frame.Function, "runtime."):
+			frames = append(frames, sentry.NewFrame(frame))
+		}
+		if !more {
+			break
+		}
+	}
+	slices.Reverse(frames)
+	return &sentry.Stacktrace{Frames: frames}
+}
+
+// Flush дожидается отправки накопленных событий
+func (r *SentryReporter) Flush(timeout time.Duration) bool {
+	return r.client.Flush(timeout)
+}
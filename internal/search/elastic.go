@@ -0,0 +1,173 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxElasticResponse ограничивает объём читаемого ответа Elasticsearch
+const maxElasticResponse = 16 << 20
+
+// ElasticOptions задаёт кластер Elasticsearch или OpenSearch и индекс постов
+type ElasticOptions struct {
+	// Engine - EngineElasticsearch или EngineOpenSearch; API, которым пользуется клиент, у них общий
+	Engine   string
+	URL      string
+	Index    string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+func (o ElasticOptions) withDefaults() ElasticOptions {
+	if o.Engine == "" {
+		o.Engine = EngineElasticsearch
+	}
+	if o.Index == "" {
+		o.Index = "posts"
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	return o
+}
+
+// Elastic - движок на Elasticsearch или OpenSearch: документы записываются методом _bulk,
+// поиск выполняется запросом multi_match по заголовку, содержимому и тегам
+type Elastic struct {
+	opts   ElasticOptions
+	client *http.Client
+}
+
+var _ Engine = &Elastic{}
+
+// NewElastic создаёт клиент Elasticsearch или OpenSearch
+func NewElastic(opts ElasticOptions) *Elastic {
+	opts = opts.withDefaults()
+	return &Elastic{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// Name реализует Engine
+func (e *Elastic) Name() string {
+	return e.opts.Engine
+}
+
+// elasticBulkResponse - ответ _bulk; при errors причина указана у каждой неудачной операции
+type elasticBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// Index реализует Engine
+func (e *Elastic) Index(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, doc := range docs {
+		action := map[string]any{"index": map[string]string{"_index": e.opts.Index, "_id": doc.ID}}
+		if err := enc.Encode(action); err != nil {
+			return fmt.Errorf("failed to encode bulk action: %v", err)
+		}
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("failed to encode document %s: %v", doc.ID, err)
+		}
+	}
+	var result elasticBulkResponse
+	if err := e.call(ctx, "/_bulk", "application/x-ndjson", &body, &result); err != nil {
+		return err
+	}
+	if !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for _, op := range item {
+			if op.Error != nil {
+				return fmt.Errorf("failed to index document %s: %s: %s", op.ID, op.Error.Type, op.Error.Reason)
+			}
+		}
+	}
+	return fmt.Errorf("failed to index documents")
+}
+
+// elasticSearchResponse - ответ _search
+type elasticSearchResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID string `json:"_id"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// Search реализует Engine. Все слова text должны встретиться в документе
+func (e *Elastic) Search(ctx context.Context, text string, limit int) ([]string, error) {
+	query := map[string]any{
+		"size":    limit,
+		"_source": false,
+		"query": map[string]any{
+			"multi_match": map[string]any{
+				"query":    text,
+				"fields":   []string{"title^2", "content", "tags"},
+				"operator": "and",
+			},
+		},
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode search request: %v", err)
+	}
+	var result elasticSearchResponse
+	if err := e.call(ctx, "/"+url.PathEscape(e.opts.Index)+"/_search", "application/json", bytes.NewReader(body), &result); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(result.Hits.Hits))
+	for i, hit := range result.Hits.Hits {
+		ids[i] = hit.ID
+	}
+	return ids, nil
+}
+
+// call отправляет body методом POST по пути path и разбирает ответ в result
+func (e *Elastic) call(ctx context.Context, path, contentType string, body io.Reader, result any) error {
+	endpoint := strings.TrimSuffix(e.opts.URL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to build %s request: %v", e.opts.Engine, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "system-search/1.0")
+	if e.opts.Username != "" {
+		req.SetBasicAuth(e.opts.Username, e.opts.Password)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %v", e.opts.Engine, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxElasticResponse))
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %v", e.opts.Engine, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s: %s", resp.StatusCode, e.opts.Engine, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", e.opts.Engine, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElastic(t *testing.T) {
+	var bulk []map[string]any
+	var query map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "elastic", user)
+		assert.Equal(t, "secret", pass)
+		switch r.URL.Path {
+		case "/_bulk":
+			assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var line map[string]any
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+				bulk = append(bulk, line)
+			}
+			if len(bulk) > 2 {
+				w.Write([]byte(`{"errors":true,"items":[{"index":{"_id":"1","status":201}},{"index":{"_id":"2","status":400,"error":{"type":"mapper_parsing_exception","reason":"bad tags"}}}]}`))
+				return
+			}
+			w.Write([]byte(`{"errors":false,"items":[{"index":{"_id":"1","status":201}}]}`))
+		case "/posts-v2/_search":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&query))
+			w.Write([]byte(`{"hits":{"hits":[{"_id":"2"},{"_id":"1"}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"no such index"}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	engine, err := NewEngine(EngineOpenSearch, ElasticOptions{URL: srv.URL + "/", Index: "posts-v2", Username: "elastic", Password: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, EngineOpenSearch, engine.Name())
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, engine.Index(ctx, []Document{{ID: "1", Title: "Ракета", Content: "Запуск", Tags: []string{"космос"}, CreatedAt: created}}))
+	require.Len(t, bulk, 2)
+	assert.Equal(t, map[string]any{"index": map[string]any{"_index": "posts-v2", "_id": "1"}}, bulk[0])
+	assert.Equal(t, "Ракета", bulk[1]["title"])
+	assert.Equal(t, "2024-01-02T03:04:05Z", bulk[1]["createdAt"])
+	assert.NotContains(t, bulk[1], "ID", "ID передаётся только в действии")
+
+	bulk = nil
+	err = engine.Index(ctx, []Document{{ID: "1"}, {ID: "2"}})
+	assert.ErrorContains(t, err, "failed to index document 2: mapper_parsing_exception: bad tags")
+
+	ids, err := engine.Search(ctx, "запуск ракеты", 50)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "1"}, ids)
+	assert.EqualValues(t, 50, query["size"])
+	match := query["query"].(map[string]any)["multi_match"].(map[string]any)
+	assert.Equal(t, "запуск ракеты", match["query"])
+	assert.Equal(t, "and", match["operator"])
+
+	missing, err := NewEngine(EngineElasticsearch, ElasticOptions{URL: srv.URL, Index: "other", Username: "elastic", Password: "secret"})
+	require.NoError(t, err)
+	_, err = missing.Search(ctx, "запуск", 10)
+	assert.ErrorContains(t, err, "unexpected status 404 from elasticsearch")
+
+	_, err = NewEngine("bleve", ElasticOptions{})
+	assert.Error(t, err)
+}
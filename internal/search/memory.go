@@ -0,0 +1,122 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// titleWeight - во сколько раз слово заголовка весит больше слова содержимого
+const titleWeight = 2
+
+// MemoryIndex - встроенный движок: обратный индекс слов в памяти процесса. Подходит для одного экземпляра
+// сервера и тестов; после перезапуска индекс заполняется заново командой переиндексации или по мере изменения постов
+type MemoryIndex struct {
+	mu sync.RWMutex
+	// postings - вес слова в каждом документе, где оно встречается
+	postings map[string]map[string]int
+	// terms - слова документа, чтобы удалить их при замене документа
+	terms map[string][]string
+}
+
+var _ Engine = &MemoryIndex{}
+
+// NewMemoryIndex создаёт пустой MemoryIndex
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{postings: map[string]map[string]int{}, terms: map[string][]string{}}
+}
+
+// Name реализует Engine
+func (m *MemoryIndex) Name() string {
+	return EngineMemory
+}
+
+// Index реализует Engine
+func (m *MemoryIndex) Index(ctx context.Context, docs []Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, doc := range docs {
+		m.remove(doc.ID)
+		weights := map[string]int{}
+		for _, term := range tokenize(doc.Title) {
+			weights[term] += titleWeight
+		}
+		for _, term := range tokenize(doc.Content) {
+			weights[term]++
+		}
+		for _, tag := range doc.Tags {
+			for _, term := range tokenize(tag) {
+				weights[term]++
+			}
+		}
+		terms := make([]string, 0, len(weights))
+		for term, weight := range weights {
+			if m.postings[term] == nil {
+				m.postings[term] = map[string]int{}
+			}
+			m.postings[term][doc.ID] = weight
+			terms = append(terms, term)
+		}
+		m.terms[doc.ID] = terms
+	}
+	return nil
+}
+
+// remove удаляет документ id из индекса; вызывается под блокировкой
+func (m *MemoryIndex) remove(id string) {
+	for _, term := range m.terms[id] {
+		delete(m.postings[term], id)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	delete(m.terms, id)
+}
+
+// Search реализует Engine: находит документы со всеми словами text, релевантность - сумма весов слов
+func (m *MemoryIndex) Search(ctx context.Context, text string, limit int) ([]string, error) {
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return []string{}, nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	scores := map[string]int{}
+	for id, weight := range m.postings[terms[0]] {
+		scores[id] = weight
+	}
+	for _, term := range terms[1:] {
+		docs := m.postings[term]
+		for id := range scores {
+			weight, ok := docs[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += weight
+		}
+	}
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// tokenize разбивает текст на слова из букв и цифр в нижнем регистре
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
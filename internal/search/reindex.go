@@ -0,0 +1,51 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Reindex заново передаёт движку все посты хранилища пачками по batchSize, читая их потоком IteratePosts.
+// Документы заменяются на месте, поэтому поиск во время переиндексации продолжает работать.
+// Возвращает количество проиндексированных постов
+func Reindex(ctx context.Context, store storage.Storage, engine Engine, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	log.Printf("Переиндексация постов в %s пачками по %d", engine.Name(), batchSize)
+	indexed := 0
+	batch := make([]Document, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := engine.Index(ctx, batch); err != nil {
+			metrics.SearchIndexed.WithLabelValues("reindex", "error").Add(float64(len(batch)))
+			return fmt.Errorf("failed to index posts: %v", err)
+		}
+		metrics.SearchIndexed.WithLabelValues("reindex", "indexed").Add(float64(len(batch)))
+		indexed += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	err := store.IteratePosts(ctx, func(post *models.Post) error {
+		batch = append(batch, DocumentFor(post))
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return indexed, err
+	}
+	log.Printf("Переиндексация завершена: постов %d", indexed)
+	return indexed, nil
+}
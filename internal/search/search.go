@@ -0,0 +1,72 @@
+// Package search переносит поиск постов по тексту из хранилища во внешний поисковый движок.
+// Движок хранит только документы для поиска и возвращает ID подходящих постов; посты, их порядок
+// и права на просмотр по-прежнему берутся из хранилища. Индекс обновляется в фоне при изменении
+// постов через Storage и целиком - командой переиндексации Reindex.
+//
+// Движка на Bleve нет: его модуль не входит в зависимости сервиса, а индекс внутри процесса
+// обеспечивает встроенный движок memory
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// Поддерживаемые движки
+const (
+	EngineMemory        = "memory"
+	EngineElasticsearch = "elasticsearch"
+	EngineOpenSearch    = "opensearch"
+)
+
+// Document - пост в том виде, в каком он хранится в поисковом индексе
+type Document struct {
+	ID        string    `json:"-"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	AuthorID  string    `json:"authorId"`
+	Language  string    `json:"language"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DocumentFor возвращает документ индекса для поста
+func DocumentFor(post *models.Post) Document {
+	return Document{
+		ID:        post.ID,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      post.Tags,
+		AuthorID:  post.AuthorID,
+		Language:  post.Language,
+		CreatedAt: post.CreatedAt,
+	}
+}
+
+// Engine - поисковый движок. Индекс содержит и скрытые посты: права на просмотр проверяет хранилище
+type Engine interface {
+	// Name - название движка для логов и метрик
+	Name() string
+	// Index добавляет документы в индекс, заменяя документы с теми же ID
+	Index(ctx context.Context, docs []Document) error
+	// Search возвращает до limit ID постов, подходящих под text, по убыванию релевантности
+	Search(ctx context.Context, text string, limit int) ([]string, error)
+}
+
+// NewEngine создаёт движок по названию; для пустого названия возвращает nil. Параметры кластера
+// используются только движками Elasticsearch и OpenSearch
+func NewEngine(name string, opts ElasticOptions) (Engine, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case EngineMemory:
+		return NewMemoryIndex(), nil
+	case EngineElasticsearch, EngineOpenSearch:
+		opts.Engine = name
+		return NewElastic(opts), nil
+	}
+	return nil, fmt.Errorf("unknown search engine %q", name)
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryIndex(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	require.NoError(t, index.Index(ctx, []Document{
+		{ID: "1", Title: "Запуск ракеты", Content: "Подробности о запуске"},
+		{ID: "2", Title: "Новости", Content: "Ракета стартовала, запуск прошёл успешно", Tags: []string{"космос"}},
+		{ID: "3", Title: "Погода", Content: "Запуск отложен"},
+	}))
+
+	ids, err := index.Search(ctx, "ЗАПУСК", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, ids, "Слово заголовка весит больше, равные веса упорядочены по ID")
+	ids, err = index.Search(ctx, "запуск космос", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, ids, "Документ должен содержать все слова запроса")
+	ids, err = index.Search(ctx, "запуск", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids)
+	ids, err = index.Search(ctx, "  ,. ", 10)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	require.NoError(t, index.Index(ctx, []Document{{ID: "1", Title: "Посадка"}}))
+	ids, err = index.Search(ctx, "запуск", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "3"}, ids, "Новая версия документа заменяет прежнюю")
+	ids, err = index.Search(ctx, "посадка", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids)
+}
+
+// failingEngine - движок, который всегда возвращает ошибку
+type failingEngine struct{ *MemoryIndex }
+
+func (failingEngine) Search(ctx context.Context, text string, limit int) ([]string, error) {
+	return nil, errors.New("engine unavailable")
+}
+
+func newPost(id, title, content string, createdAt time.Time) *models.Post {
+	return &models.Post{ID: id, Title: title, Content: content, AuthorID: "user1", AllowComments: true, CreatedAt: createdAt}
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	inner := memory.New()
+	rocket := newPost("p1", "Ракета", "Запуск ракеты перенесён", base)
+	require.NoError(t, inner.CreatePost(ctx, rocket))
+	index := NewMemoryIndex()
+	store := New(inner, index, Options{})
+
+	titles := func(filter storage.PostFilter) []string {
+		page, err := store.ListPosts(ctx, 10, nil, filter)
+		require.NoError(t, err)
+		result := []string{}
+		for _, p := range page.Posts {
+			result = append(result, p.Title)
+		}
+		return result
+	}
+	assert.Equal(t, []string{}, titles(storage.PostFilter{Text: "ракета"}), "Посты, созданные до обёртки, ищутся после переиндексации")
+	indexed, err := Reindex(ctx, inner, index, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, []string{"Ракета"}, titles(storage.PostFilter{Text: "ракеты"}))
+
+	require.NoError(t, store.CreatePost(ctx, newPost("p2", "Погода", "Ракеты не летают в грозу", base.Add(time.Minute))))
+	require.NoError(t, store.CreatePost(ctx, newPost("p3", "Новости", "Ничего нового", base.Add(2*time.Minute))))
+	_, err = store.UpdatePostTitle(ctx, "p3", "Ракеты в новостях", "")
+	require.NoError(t, err)
+	store.Stop()
+
+	assert.Equal(t, []string{"Ракеты в новостях", "Погода", "Ракета"}, titles(storage.PostFilter{Text: "ракеты"}), "Порядок задаёт хранилище, а не релевантность")
+	assert.Equal(t, []string{"Погода"}, titles(storage.PostFilter{Text: "ракеты", IDs: []string{"p2", "p4"}}))
+	assert.Equal(t, []string{"Ракеты в новостях", "Погода", "Ракета"}, titles(storage.PostFilter{}), "Без текста движок не используется")
+
+	fallback := New(inner, failingEngine{index}, Options{})
+	defer fallback.Stop()
+	page, err := fallback.ListPosts(ctx, 10, nil, storage.PostFilter{Text: "грозу"})
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 1, "При ошибке движка поиск выполняет хранилище")
+	assert.Equal(t, "p2", page.Posts[0].ID)
+}
+
+func TestStorageQueueOverflow(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	store := New(memory.New(), index, Options{QueueSize: 1, BatchSize: 1})
+	for i, id := range []string{"p1", "p2", "p3"} {
+		require.NoError(t, store.CreatePost(ctx, newPost(id, "Заметка", "Текст", time.Now().Add(time.Duration(i)*time.Second))))
+	}
+	store.Stop()
+	ids, err := index.Search(ctx, "заметка", 10)
+	require.NoError(t, err)
+	assert.NotEmpty(t, ids, "Пост из очереди проиндексирован до остановки")
+	assert.LessOrEqual(t, len(ids), 3)
+}
@@ -0,0 +1,207 @@
+package search
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Options задаёт поиск через движок; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// MaxResults - сколько самых релевантных постов движок возвращает на один поиск;
+	// менее релевантные посты в результат не попадают
+	MaxResults int
+	// QueueSize - сколько изменённых постов может ждать фоновой индексации
+	QueueSize int
+	// BatchSize - сколько постов из очереди передаётся движку за раз
+	BatchSize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxResults <= 0 {
+		o.MaxResults = 1000
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	return o
+}
+
+// Storage - обёртка над хранилищем, которая ищет посты по тексту в движке и поддерживает его индекс.
+// ListPosts с PostFilter.Text запрашивает у движка ID подходящих постов и читает их из хранилища
+// с остальными условиями фильтра: права на просмотр, порядок и пагинация остаются прежними.
+// Если движок недоступен, поиск выполняется условиями хранилища.
+// Созданные и изменённые посты ставятся в очередь и индексируются в фоне; при переполнении очереди
+// изменение пропускается, и индекс восстанавливается переиндексацией
+type Storage struct {
+	storage.Storage
+	engine Engine
+	opts   Options
+	queue  chan string
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+var _ storage.Storage = &Storage{}
+
+// New оборачивает хранилище поиском через engine и запускает фоновую индексацию
+func New(store storage.Storage, engine Engine, opts Options) *Storage {
+	opts = opts.withDefaults()
+	log.Printf("Создание поиска через %s: до %d результатов, очередь %d", engine.Name(), opts.MaxResults, opts.QueueSize)
+	s := &Storage{
+		Storage: store,
+		engine:  engine,
+		opts:    opts,
+		queue:   make(chan string, opts.QueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.indexer()
+	return s
+}
+
+// ListPosts ищет посты по тексту в движке
+func (s *Storage) ListPosts(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (*models.PaginatedPosts, error) {
+	if filter.Text == "" {
+		return s.Storage.ListPosts(ctx, limit, cursor, filter)
+	}
+	ids, err := s.engine.Search(ctx, filter.Text, s.opts.MaxResults)
+	if err != nil {
+		log.Printf("Поиск через %s не удался, используется поиск хранилища: %v", s.engine.Name(), err)
+		metrics.SearchQueries.WithLabelValues(s.engine.Name(), "fallback").Inc()
+		return s.Storage.ListPosts(ctx, limit, cursor, filter)
+	}
+	metrics.SearchQueries.WithLabelValues(s.engine.Name(), "engine").Inc()
+	if filter.IDs != nil {
+		ids = slices.DeleteFunc(ids, func(id string) bool { return !slices.Contains(filter.IDs, id) })
+	}
+	filter.Text = ""
+	filter.IDs = ids
+	return s.Storage.ListPosts(ctx, limit, cursor, filter)
+}
+
+// CreatePost ставит новый пост в очередь индексации
+func (s *Storage) CreatePost(ctx context.Context, post *models.Post) error {
+	if err := s.Storage.CreatePost(ctx, post); err != nil {
+		return err
+	}
+	s.enqueue(post.ID)
+	return nil
+}
+
+// UpdatePostTitle ставит пост с новым заголовком в очередь индексации
+func (s *Storage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	post, err := s.Storage.UpdatePostTitle(ctx, postID, title, slugBase)
+	if err != nil {
+		return nil, err
+	}
+	s.enqueue(post.ID)
+	return post, nil
+}
+
+// ReplaceContent ставит пост с заменённым содержимым в очередь индексации
+func (s *Storage) ReplaceContent(ctx context.Context, kind, id, previous, content string) (bool, error) {
+	replaced, err := s.Storage.ReplaceContent(ctx, kind, id, previous, content)
+	if err == nil && replaced && kind == models.TargetPost {
+		s.enqueue(id)
+	}
+	return replaced, err
+}
+
+// AnonymizeUserContent ставит обезличенные посты в очередь индексации, чтобы их прежний текст не находился
+func (s *Storage) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	ids, err := s.Storage.AnonymizeUserContent(ctx, kind, userID, limit)
+	if kind == models.TargetPost {
+		for _, id := range ids {
+			s.enqueue(id)
+		}
+	}
+	return ids, err
+}
+
+// enqueue ставит пост в очередь индексации; при переполнении изменение пропускается
+func (s *Storage) enqueue(postID string) {
+	select {
+	case s.queue <- postID:
+	default:
+		log.Printf("Очередь индексации переполнена, пост %s пропущен", postID)
+		metrics.SearchIndexDropped.Inc()
+	}
+}
+
+// Stop останавливает фоновую индексацию после обработки уже поставленных в очередь постов
+func (s *Storage) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		log.Println("Фоновая индексация остановлена")
+	})
+}
+
+// Close останавливает фоновую индексацию и закрывает обёрнутое хранилище
+func (s *Storage) Close() error {
+	s.Stop()
+	return s.Storage.Close()
+}
+
+// indexer индексирует посты из очереди пачками до BatchSize
+func (s *Storage) indexer() {
+	defer close(s.done)
+	for {
+		select {
+		case id := <-s.queue:
+			s.indexBatch(s.collect(id))
+		case <-s.stop:
+			for len(s.queue) > 0 {
+				s.indexBatch(s.collect(<-s.queue))
+			}
+			return
+		}
+	}
+}
+
+// collect дополняет пачку постами, уже ждущими в очереди; повторы одного поста индексируются один раз
+func (s *Storage) collect(first string) []string {
+	ids := []string{first}
+	for len(ids) < s.opts.BatchSize {
+		select {
+		case id := <-s.queue:
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		default:
+			return ids
+		}
+	}
+	return ids
+}
+
+// indexBatch читает посты из хранилища и передаёт их движку
+func (s *Storage) indexBatch(ids []string) {
+	ctx := context.Background()
+	docs := make([]Document, 0, len(ids))
+	for _, id := range ids {
+		post, err := s.Storage.GetPost(ctx, id)
+		if err != nil {
+			log.Printf("Не удалось прочитать пост %s для индексации: %v", id, err)
+			metrics.SearchIndexed.WithLabelValues("incremental", "error").Inc()
+			continue
+		}
+		docs = append(docs, DocumentFor(post))
+	}
+	if err := s.engine.Index(ctx, docs); err != nil {
+		log.Printf("Не удалось проиндексировать посты в %s: %v", s.engine.Name(), err)
+		metrics.SearchIndexed.WithLabelValues("incremental", "error").Add(float64(len(docs)))
+		return
+	}
+	metrics.SearchIndexed.WithLabelValues("incremental", "indexed").Add(float64(len(docs)))
+}
@@ -0,0 +1,181 @@
+// Package searchalert периодически проверяет сохранённые поиски с включёнными уведомлениями
+// и сообщает пользователям письмом о новых постах, подходящих под поиск
+package searchalert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/email"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Options задаёт параметры проверки; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Interval - как часто проверяются сохранённые поиски
+	Interval time.Duration
+	// MaxPosts - наибольшее число постов в одном письме; об остальных сообщается только их количество
+	MaxPosts int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Interval <= 0 {
+		o.Interval = 15 * time.Minute
+	}
+	if o.MaxPosts <= 0 {
+		o.MaxPosts = 20
+	}
+	return o
+}
+
+// Service проверяет сохранённые поиски по расписанию
+type Service struct {
+	store  storage.Storage
+	sender email.Sender
+	opts   Options
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// New создаёт сервис уведомлений; проверки начинаются после Start
+func New(store storage.Storage, sender email.Sender, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Search Alert Service: interval=%v, maxPosts=%d", opts.Interval, opts.MaxPosts)
+	return &Service{
+		store:  store,
+		sender: sender,
+		opts:   opts,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start запускает фоновую проверку поисков каждые Interval до вызова Close
+func (s *Service) Start() {
+	go s.run()
+}
+
+// Close останавливает фоновую проверку и дожидается завершения текущего прохода
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		log.Println("Search Alert Service остановлен")
+	})
+}
+
+func (s *Service) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if _, err := s.CheckDue(context.Background()); err != nil {
+				log.Printf("Фоновая проверка сохранённых поисков не удалась: %v", err)
+			}
+		}
+	}
+}
+
+// CheckDue ищет новые посты по всем поискам с уведомлениями и возвращает число отправленных писем.
+// Поиск, письмо по которому не удалось отправить, повторяется при следующем проходе
+func (s *Service) CheckDue(ctx context.Context) (int, error) {
+	searches, err := s.store.ListAlertSavedSearches(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list saved searches: %v", err)
+	}
+	sent := 0
+	for _, search := range searches {
+		ok, err := s.check(ctx, search)
+		if err != nil {
+			log.Printf("Ошибка проверки сохранённого поиска %s пользователя %s: %v", search.ID, search.UserID, err)
+			metrics.SearchAlertEmails.WithLabelValues("error").Inc()
+			continue
+		}
+		if ok {
+			sent++
+			metrics.SearchAlertEmails.WithLabelValues("sent").Inc()
+		}
+	}
+	log.Printf("Проход проверки сохранённых поисков завершён: поисков %d, отправлено %d", len(searches), sent)
+	return sent, nil
+}
+
+// check отправляет письмо о постах, появившихся после CheckedAt поиска, и сообщает, было ли оно отправлено.
+// Ищутся только посты, видимые всем; собственные посты пользователя в письмо не попадают, но сдвигают CheckedAt
+func (s *Service) check(ctx context.Context, search models.SavedSearch) (bool, error) {
+	filter := storage.SearchPostFilter(search.Filter)
+	filter.CreatedAfter = &search.CheckedAt
+	page, err := s.store.ListPosts(ctx, s.opts.MaxPosts, nil, filter)
+	if err != nil {
+		return false, err
+	}
+	if len(page.Posts) == 0 {
+		return false, nil
+	}
+	posts := make([]*models.Post, 0, len(page.Posts))
+	for _, post := range page.Posts {
+		if post.AuthorID != search.UserID {
+			posts = append(posts, post)
+		}
+	}
+	sent := false
+	if len(posts) > 0 {
+		prefs, err := s.store.GetPreferences(ctx, search.UserID)
+		if err != nil {
+			return false, err
+		}
+		// Без адреса уведомление теряется: иначе после его появления пришло бы письмо обо всех накопившихся постах
+		if prefs.Email != "" {
+			msg := email.Message{
+				To:      prefs.Email,
+				Subject: subject(prefs.Locale, search.Name),
+				Body:    body(prefs.Locale, posts, page.TotalCount-len(page.Posts)),
+			}
+			if err := s.sender.Send(ctx, msg); err != nil {
+				return false, err
+			}
+			sent = true
+			log.Printf("Уведомление о %d новых постах по поиску %s отправлено пользователю %s", len(posts), search.ID, search.UserID)
+		}
+	}
+	// Посты приходят от новых к старым, поэтому первый - самый новый
+	return sent, s.store.MarkSavedSearchChecked(ctx, search.ID, page.Posts[0].CreatedAt)
+}
+
+// subject возвращает тему письма на языке пользователя
+func subject(locale, name string) string {
+	if locale == "en" {
+		return fmt.Sprintf("New posts for your saved search \"%s\"", name)
+	}
+	return fmt.Sprintf("Новые посты по сохранённому поиску «%s»", name)
+}
+
+// body перечисляет заголовки новых постов с анонсами; more - сколько подходящих постов не вошло в письмо
+func body(locale string, posts []*models.Post, more int) string {
+	var b strings.Builder
+	for _, post := range posts {
+		b.WriteString(post.Title + "\n")
+		if post.Excerpt != "" {
+			b.WriteString("  " + post.Excerpt + "\n")
+		}
+	}
+	if more > 0 {
+		if locale == "en" {
+			fmt.Fprintf(&b, "\nAnd %d more.\n", more)
+		} else {
+			fmt.Fprintf(&b, "\nИ ещё %d.\n", more)
+		}
+	}
+	return b.String()
+}
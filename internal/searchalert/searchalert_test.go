@@ -0,0 +1,109 @@
+package searchalert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/email"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSender запоминает отправленные письма и может имитировать сбой
+type recordingSender struct {
+	sent []email.Message
+	err  error
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg email.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestCheckDue(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	start := time.Now()
+	addPost := func(id, title, author string, at time.Time) {
+		t.Helper()
+		require.NoError(t, store.CreatePost(ctx, &models.Post{ID: id, Title: title, Content: "Содержимое", Excerpt: "Анонс " + id, AuthorID: author, AllowComments: true, CreatedAt: at}))
+	}
+	addPost("old", "Старая ракета", "author", start.Add(-time.Hour))
+	require.NoError(t, store.SavePreferences(ctx, &models.Preferences{UserID: "reader", Email: "reader@example.com", Locale: "ru"}))
+	require.NoError(t, store.CreateSavedSearch(ctx, &models.SavedSearch{
+		ID: "rockets", UserID: "reader", Name: "Ракеты", Filter: models.SearchFilter{Text: "РАКЕТ"},
+		Alert: true, CreatedAt: start, CheckedAt: start,
+	}))
+	require.NoError(t, store.CreateSavedSearch(ctx, &models.SavedSearch{
+		ID: "quiet", UserID: "reader", Name: "Без уведомлений", Filter: models.SearchFilter{Text: "ракет"},
+		CreatedAt: start, CheckedAt: start,
+	}))
+	require.NoError(t, store.CreateSavedSearch(ctx, &models.SavedSearch{
+		ID: "noemail", UserID: "nomail", Name: "Ракеты", Filter: models.SearchFilter{Text: "ракет"},
+		Alert: true, CreatedAt: start, CheckedAt: start,
+	}))
+	addPost("launch", "Запуск ракеты", "author", start.Add(time.Minute))
+	addPost("own", "Моя ракета", "reader", start.Add(2*time.Minute))
+	addPost("other", "Погода", "author", start.Add(3*time.Minute))
+
+	sender := &recordingSender{}
+	service := New(store, sender, Options{})
+	sent, err := service.CheckDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent, "Поиск без уведомлений и пользователь без адреса писем не получают")
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "reader@example.com", sender.sent[0].To)
+	assert.Contains(t, sender.sent[0].Subject, "Ракеты")
+	assert.Contains(t, sender.sent[0].Body, "Запуск ракеты\n  Анонс launch\n", "Под заголовком - анонс поста")
+	assert.NotContains(t, sender.sent[0].Body, "Моя ракета", "Собственные посты не попадают в письмо")
+	assert.NotContains(t, sender.sent[0].Body, "Старая ракета", "Посты до сохранения поиска не попадают в письмо")
+
+	searches, err := store.ListAlertSavedSearches(ctx)
+	require.NoError(t, err)
+	for _, search := range searches {
+		assert.Equal(t, start.Add(2*time.Minute), search.CheckedAt, "Проверка сдвигается до самого нового подходящего поста")
+	}
+
+	sent, err = service.CheckDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent, "О постах сообщается один раз")
+
+	addPost("next", "Ещё ракета", "author", start.Add(4*time.Minute))
+	sender.err = errors.New("smtp unavailable")
+	sent, err = service.CheckDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	sender.err = nil
+	sent, err = service.CheckDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent, "Неотправленное письмо повторяется")
+	assert.Contains(t, sender.sent[1].Body, "Ещё ракета")
+}
+
+func TestCheckDue_MaxPosts(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	start := time.Now()
+	require.NoError(t, store.SavePreferences(ctx, &models.Preferences{UserID: "reader", Email: "reader@example.com", Locale: "en"}))
+	require.NoError(t, store.CreateSavedSearch(ctx, &models.SavedSearch{
+		ID: "all", UserID: "reader", Name: "All", Alert: true, CreatedAt: start, CheckedAt: start,
+	}))
+	for i, title := range []string{"First", "Second", "Third"} {
+		require.NoError(t, store.CreatePost(ctx, &models.Post{ID: title, Title: title, AuthorID: "author", CreatedAt: start.Add(time.Duration(i+1) * time.Minute)}))
+	}
+
+	sender := &recordingSender{}
+	_, err := New(store, sender, Options{MaxPosts: 2}).CheckDue(ctx)
+	require.NoError(t, err)
+	require.Len(t, sender.sent, 1)
+	assert.Contains(t, sender.sent[0].Body, "Third")
+	assert.NotContains(t, sender.sent[0].Body, "First")
+	assert.Contains(t, sender.sent[0].Body, "And 1 more.")
+}
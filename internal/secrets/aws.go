@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AWSOptions задаёт чтение секретов из AWS Secrets Manager. Учётные данные берутся из
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY и AWS_SESSION_TOKEN
+type AWSOptions struct {
+	// Region - регион Secrets Manager; если не задан, используется AWS_REGION
+	Region string
+	// Endpoint заменяет адрес Secrets Manager, используется в тестах и для VPC endpoint
+	Endpoint string
+	Timeout  time.Duration
+	// Now возвращает время подписи запроса; по умолчанию time.Now
+	Now func() time.Time
+}
+
+func (o AWSOptions) withDefaults() AWSOptions {
+	if o.Region == "" {
+		o.Region = os.Getenv("AWS_REGION")
+	}
+	if o.Endpoint == "" && o.Region != "" {
+		o.Endpoint = "https://secretsmanager." + o.Region + ".amazonaws.com"
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	return o
+}
+
+// AWSProvider читает секреты Secrets Manager методом GetSecretValue. Path ссылки - имя или ARN секрета,
+// Field - ключ JSON-объекта в строке секрета; без него возвращается вся строка
+type AWSProvider struct {
+	opts   AWSOptions
+	client *http.Client
+}
+
+// NewAWS создаёт источник секретов AWS Secrets Manager
+func NewAWS(opts AWSOptions) (*AWSProvider, error) {
+	opts = opts.withDefaults()
+	if opts.Region == "" {
+		return nil, fmt.Errorf("aws region is required: configure it or set AWS_REGION")
+	}
+	return &AWSProvider{opts: opts, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// Fetch реализует Provider
+func (p *AWSProvider) Fetch(ctx context.Context, ref Ref) (string, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("aws credentials are not set: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create aws request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signV4(req, body, accessKey, secretKey, p.opts.Region, "secretsmanager", p.opts.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("aws request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type string `json:"__type"`
+		}
+		_ = json.Unmarshal(respBody, &apiErr)
+		if strings.HasSuffix(apiErr.Type, "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: aws secret %s", ErrNotFound, ref.Path)
+		}
+		return "", fmt.Errorf("aws returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var result struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to decode aws response: %v", err)
+	}
+	if result.SecretString == nil {
+		return "", fmt.Errorf("aws secret %s has no string value", ref.Path)
+	}
+	return jsonField(ref, *result.SecretString)
+}
+
+// signV4 подписывает запрос AWS Signature Version 4 по host и всем заголовкам запроса
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signed := []string{"host"}
+	for name := range req.Header {
+		signed = append(signed, strings.ToLower(name))
+	}
+	// Заголовки в канонической форме перечисляются по алфавиту
+	slices.Sort(signed)
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery возвращает параметры запроса, отсортированные по имени, в кодировке SigV4
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProvider(t *testing.T) {
+	var gotToken, gotNamespace string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken, gotNamespace = r.Header.Get("X-Vault-Token"), r.Header.Get("X-Vault-Namespace")
+		switch r.URL.Path {
+		case "/v1/kv/data/system":
+			w.Write([]byte(`{"data":{"data":{"jwt_secret":"s3cret"},"metadata":{"version":3}},"lease_duration":0}`))
+		case "/v1/secret/system":
+			w.Write([]byte(`{"data":{"jwt_secret":"v1-secret"},"lease_duration":2764800}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("hvs.token\n"), 0o600))
+	provider, err := NewVault(VaultOptions{Addr: server.URL + "/", TokenFile: tokenFile, Namespace: "team"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	value, err := provider.Fetch(ctx, Ref{Scheme: SchemeVault, Path: "kv/data/system", Field: "jwt_secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value, "Значения KV v2 вложены в data.data")
+	assert.Equal(t, "hvs.token", gotToken)
+	assert.Equal(t, "team", gotNamespace)
+
+	value, err = provider.Fetch(ctx, Ref{Scheme: SchemeVault, Path: "secret/system", Field: "jwt_secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1-secret", value)
+
+	_, err = provider.Fetch(ctx, Ref{Scheme: SchemeVault, Path: "kv/data/system", Field: "missing"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = provider.Fetch(ctx, Ref{Scheme: SchemeVault, Path: "kv/data/other", Field: "jwt_secret"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = provider.Fetch(ctx, Ref{Scheme: SchemeVault, Path: "kv/data/system"})
+	assert.ErrorContains(t, err, "must name a field")
+
+	t.Setenv("VAULT_TOKEN", "env-token")
+	fromEnv, err := NewVault(VaultOptions{Addr: server.URL})
+	require.NoError(t, err)
+	_, err = fromEnv.Fetch(ctx, Ref{Scheme: SchemeVault, Path: "kv/data/system", Field: "jwt_secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "env-token", gotToken)
+
+	_, err = NewVault(VaultOptions{})
+	assert.Error(t, err)
+}
+
+func TestAWSProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	var gotTarget, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTarget, gotAuth = r.Header.Get("X-Amz-Target"), r.Header.Get("Authorization")
+		var req struct{ SecretId string }
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &req))
+		switch req.SecretId {
+		case "prod/system":
+			json.NewEncoder(w).Encode(map[string]string{"Name": "prod/system", "SecretString": `{"dsn":"postgres://db/posts"}`})
+		case "prod/plain":
+			json.NewEncoder(w).Encode(map[string]string{"Name": "prod/plain", "SecretString": "plain-secret"})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewAWS(AWSOptions{Region: "eu-central-1", Endpoint: server.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	value, err := provider.Fetch(ctx, Ref{Scheme: SchemeAWS, Path: "prod/system", Field: "dsn"})
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://db/posts", value)
+	assert.Equal(t, "secretsmanager.GetSecretValue", gotTarget)
+	assert.True(t, strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), gotAuth)
+	assert.Contains(t, gotAuth, "/eu-central-1/secretsmanager/aws4_request")
+
+	value, err = provider.Fetch(ctx, Ref{Scheme: SchemeAWS, Path: "prod/plain"})
+	require.NoError(t, err)
+	assert.Equal(t, "plain-secret", value)
+
+	_, err = provider.Fetch(ctx, Ref{Scheme: SchemeAWS, Path: "prod/missing"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	t.Setenv("AWS_REGION", "")
+	_, err = NewAWS(AWSOptions{})
+	assert.Error(t, err)
+}
+
+// Пример get-vanilla из набора тестов AWS Signature Version 4
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
@@ -0,0 +1,294 @@
+// Package secrets подставляет в конфигурацию секреты из внешних источников. Значение вида
+// <схема>:<путь>[#<поле>] считается ссылкой на секрет, например env:JWT_SECRET, file:/run/secrets/dsn,
+// vault:kv/data/system#jwt_secret или aws:prod/system#dsn. Остальные значения используются как есть
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Схемы ссылок на секреты
+const (
+	SchemeEnv   = "env"
+	SchemeFile  = "file"
+	SchemeVault = "vault"
+	SchemeAWS   = "aws"
+)
+
+// ErrNotFound возвращается, если источник не содержит запрошенного секрета
+var ErrNotFound = errors.New("secret not found")
+
+// Ref - разобранная ссылка на секрет
+type Ref struct {
+	Scheme string
+	Path   string
+	// Field - поле внутри секрета с несколькими значениями; пустое, если нужен секрет целиком
+	Field string
+}
+
+// String возвращает ссылку в исходном виде
+func (r Ref) String() string {
+	if r.Field == "" {
+		return r.Scheme + ":" + r.Path
+	}
+	return r.Scheme + ":" + r.Path + "#" + r.Field
+}
+
+// ParseRef разбирает ссылку на секрет. Возвращает false для обычного значения, в том числе для URL
+// вроде postgres://..., схема которого не относится к источникам секретов
+func ParseRef(value string) (Ref, bool) {
+	scheme, rest, ok := strings.Cut(value, ":")
+	if !ok {
+		return Ref{}, false
+	}
+	switch scheme {
+	case SchemeEnv, SchemeFile, SchemeVault, SchemeAWS:
+	default:
+		return Ref{}, false
+	}
+	path, field, _ := strings.Cut(rest, "#")
+	if path == "" {
+		return Ref{}, false
+	}
+	return Ref{Scheme: scheme, Path: path, Field: field}, true
+}
+
+// IsRef сообщает, является ли value ссылкой на секрет
+func IsRef(value string) bool {
+	_, ok := ParseRef(value)
+	return ok
+}
+
+// Provider читает секреты одного источника
+type Provider interface {
+	Fetch(ctx context.Context, ref Ref) (string, error)
+}
+
+// jsonField возвращает секрет целиком или, если задано поле, строковое поле JSON-объекта секрета
+func jsonField(ref Ref, secret string) (string, error) {
+	if ref.Field == "" {
+		return secret, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, field %q cannot be selected", ref.Field)
+	}
+	return stringField(fields, ref.Field)
+}
+
+// stringField возвращает строковое поле секрета
+func stringField(fields map[string]any, name string) (string, error) {
+	value, ok := fields[name]
+	if !ok {
+		return "", fmt.Errorf("%w: no field %q", ErrNotFound, name)
+	}
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %q is not a string", name)
+	}
+	return text, nil
+}
+
+// EnvProvider читает секрет из переменной окружения Path; поле выбирается из JSON-объекта в переменной
+type EnvProvider struct{}
+
+// Fetch реализует Provider
+func (EnvProvider) Fetch(ctx context.Context, ref Ref) (string, error) {
+	value, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, ref.Path)
+	}
+	return jsonField(ref, value)
+}
+
+// FileProvider читает секрет из файла Path, например смонтированного секрета Docker или Kubernetes.
+// Завершающий перевод строки отбрасывается; поле выбирается из JSON-объекта в файле
+type FileProvider struct{}
+
+// Fetch реализует Provider
+func (FileProvider) Fetch(ctx context.Context, ref Ref) (string, error) {
+	data, err := os.ReadFile(ref.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: file %s does not exist", ErrNotFound, ref.Path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %v", err)
+	}
+	return jsonField(ref, strings.TrimRight(string(data), "\r\n"))
+}
+
+// Options задаёт параметры Resolver; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// RefreshInterval - как часто Start перечитывает закешированные секреты
+	RefreshInterval time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = 5 * time.Minute
+	}
+	return o
+}
+
+// Resolver подставляет секреты по ссылкам и кеширует их; после Start закешированные секреты
+// периодически перечитываются, а подписчики OnChange узнают о новых значениях
+type Resolver struct {
+	providers map[string]Provider
+	opts      Options
+
+	mu        sync.Mutex
+	cache     map[string]string
+	listeners map[string][]func(string)
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewResolver создаёт Resolver с источниками по схемам ссылок; env и file доступны всегда
+func NewResolver(providers map[string]Provider, opts Options) *Resolver {
+	all := map[string]Provider{SchemeEnv: EnvProvider{}, SchemeFile: FileProvider{}}
+	for scheme, provider := range providers {
+		all[scheme] = provider
+	}
+	return &Resolver{
+		providers: all,
+		opts:      opts.withDefaults(),
+		cache:     make(map[string]string),
+		listeners: make(map[string][]func(string)),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Resolve возвращает секрет, на который ссылается value, или само value, если это не ссылка.
+// Прочитанный секрет кешируется и обновляется только фоновым перечитыванием
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	ref, ok := ParseRef(value)
+	if !ok {
+		return value, nil
+	}
+	r.mu.Lock()
+	secret, cached := r.cache[value]
+	r.mu.Unlock()
+	if cached {
+		return secret, nil
+	}
+	secret, err := r.fetch(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.cache[value] = secret
+	r.mu.Unlock()
+	return secret, nil
+}
+
+// ResolveFields заменяет ссылки в полях конфигурации, заданных как имя поля и указатель на значение,
+// прочитанными секретами. Возвращает исходные ссылки по именам полей, чтобы подписаться на их обновление
+func (r *Resolver) ResolveFields(ctx context.Context, fields map[string]*string) (map[string]string, error) {
+	refs := make(map[string]string)
+	for name, field := range fields {
+		if !IsRef(*field) {
+			continue
+		}
+		secret, err := r.Resolve(ctx, *field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		refs[name] = *field
+		*field = secret
+	}
+	return refs, nil
+}
+
+// OnChange вызывает fn с новым значением секрета ref после каждого его изменения при перечитывании
+func (r *Resolver) OnChange(ref string, fn func(string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners[ref] = append(r.listeners[ref], fn)
+}
+
+// Refresh перечитывает все закешированные секреты. Секрет, который не удалось прочитать,
+// сохраняет прежнее значение; возвращается первая из ошибок
+func (r *Resolver) Refresh(ctx context.Context) error {
+	r.mu.Lock()
+	values := make([]string, 0, len(r.cache))
+	for value := range r.cache {
+		values = append(values, value)
+	}
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, value := range values {
+		ref, _ := ParseRef(value)
+		secret, err := r.fetch(ctx, ref)
+		if err != nil {
+			log.Printf("Не удалось перечитать секрет %s, используется прежнее значение: %v", value, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		r.mu.Lock()
+		changed := r.cache[value] != secret
+		r.cache[value] = secret
+		listeners := append([]func(string){}, r.listeners[value]...)
+		r.mu.Unlock()
+		if changed {
+			log.Printf("Секрет %s изменился", value)
+			for _, fn := range listeners {
+				fn(secret)
+			}
+		}
+	}
+	return firstErr
+}
+
+// Start запускает перечитывание секретов каждые RefreshInterval до вызова Close
+func (r *Resolver) Start() {
+	go r.run()
+}
+
+// Close останавливает перечитывание и дожидается завершения текущего прохода
+func (r *Resolver) Close() {
+	r.once.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+}
+
+func (r *Resolver) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			_ = r.Refresh(context.Background())
+		}
+	}
+}
+
+// fetch читает секрет из источника его схемы
+func (r *Resolver) fetch(ctx context.Context, ref Ref) (string, error) {
+	provider, ok := r.providers[ref.Scheme]
+	if !ok {
+		return "", fmt.Errorf("secret source %s is not configured for %s", ref.Scheme, ref)
+	}
+	secret, err := provider.Fetch(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch secret %s: %w", ref, err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRef(t *testing.T) {
+	ref, ok := ParseRef("vault:kv/data/system#jwt_secret")
+	require.True(t, ok)
+	assert.Equal(t, Ref{Scheme: SchemeVault, Path: "kv/data/system", Field: "jwt_secret"}, ref)
+	assert.Equal(t, "vault:kv/data/system#jwt_secret", ref.String())
+
+	ref, ok = ParseRef("file:/run/secrets/dsn")
+	require.True(t, ok)
+	assert.Equal(t, Ref{Scheme: SchemeFile, Path: "/run/secrets/dsn"}, ref)
+
+	for _, value := range []string{"", "secret", "postgres://user:password@db:5432/posts", "env:", "https://example.com#frag"} {
+		assert.False(t, IsRef(value), value)
+	}
+}
+
+// countingProvider возвращает value и считает обращения
+type countingProvider struct {
+	value string
+	err   error
+	calls int
+}
+
+func (p *countingProvider) Fetch(ctx context.Context, ref Ref) (string, error) {
+	p.calls++
+	return p.value, p.err
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Plain values pass through", func(t *testing.T) {
+		resolver := NewResolver(nil, Options{})
+		value, err := resolver.Resolve(ctx, "postgres://user:password@db:5432/posts")
+		require.NoError(t, err)
+		assert.Equal(t, "postgres://user:password@db:5432/posts", value)
+	})
+
+	t.Run("Env and file", func(t *testing.T) {
+		t.Setenv("SYSTEM_TEST_SECRET", "from-env")
+		t.Setenv("SYSTEM_TEST_JSON", `{"jwt":"from-json","port":5432}`)
+		path := filepath.Join(t.TempDir(), "dsn")
+		require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+		resolver := NewResolver(nil, Options{})
+
+		for value, want := range map[string]string{
+			"env:SYSTEM_TEST_SECRET":   "from-env",
+			"env:SYSTEM_TEST_JSON#jwt": "from-json",
+			"file:" + path:             "from-file",
+		} {
+			got, err := resolver.Resolve(ctx, value)
+			require.NoError(t, err, value)
+			assert.Equal(t, want, got, value)
+		}
+
+		_, err := resolver.Resolve(ctx, "env:SYSTEM_TEST_MISSING")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = resolver.Resolve(ctx, "env:SYSTEM_TEST_JSON#port")
+		assert.ErrorContains(t, err, "not a string")
+		_, err = resolver.Resolve(ctx, "file:"+filepath.Join(t.TempDir(), "missing"))
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Unconfigured source", func(t *testing.T) {
+		_, err := NewResolver(nil, Options{}).Resolve(ctx, "vault:kv/data/system#jwt")
+		assert.ErrorContains(t, err, "not configured")
+	})
+
+	t.Run("Cached until refresh", func(t *testing.T) {
+		provider := &countingProvider{value: "v1"}
+		resolver := NewResolver(map[string]Provider{SchemeVault: provider}, Options{})
+		var changes []string
+		resolver.OnChange("vault:kv/data/system#jwt", func(value string) { changes = append(changes, value) })
+
+		for range 3 {
+			value, err := resolver.Resolve(ctx, "vault:kv/data/system#jwt")
+			require.NoError(t, err)
+			assert.Equal(t, "v1", value)
+		}
+		assert.Equal(t, 1, provider.calls)
+
+		require.NoError(t, resolver.Refresh(ctx))
+		assert.Empty(t, changes, "Неизменившийся секрет не вызывает подписчиков")
+
+		provider.value = "v2"
+		require.NoError(t, resolver.Refresh(ctx))
+		assert.Equal(t, []string{"v2"}, changes)
+		value, err := resolver.Resolve(ctx, "vault:kv/data/system#jwt")
+		require.NoError(t, err)
+		assert.Equal(t, "v2", value)
+
+		provider.err = errors.New("vault is sealed")
+		assert.Error(t, resolver.Refresh(ctx))
+		value, err = resolver.Resolve(ctx, "vault:kv/data/system#jwt")
+		require.NoError(t, err)
+		assert.Equal(t, "v2", value, "При ошибке перечитывания остаётся прежнее значение")
+	})
+
+	t.Run("ResolveFields", func(t *testing.T) {
+		t.Setenv("SYSTEM_TEST_SECRET", "from-env")
+		resolver := NewResolver(nil, Options{})
+		jwt, dsn := "env:SYSTEM_TEST_SECRET", "postgres://db/posts"
+		refs, err := resolver.ResolveFields(ctx, map[string]*string{"auth.jwtSecret": &jwt, "postgres.dsn": &dsn})
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", jwt)
+		assert.Equal(t, "postgres://db/posts", dsn)
+		assert.Equal(t, map[string]string{"auth.jwtSecret": "env:SYSTEM_TEST_SECRET"}, refs)
+
+		missing := "env:SYSTEM_TEST_MISSING"
+		_, err = resolver.ResolveFields(ctx, map[string]*string{"email.password": &missing})
+		assert.ErrorContains(t, err, "email.password")
+	})
+}
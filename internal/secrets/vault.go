@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultOptions задаёт чтение секретов из HashiCorp Vault
+type VaultOptions struct {
+	// Addr - адрес Vault, например https://vault.example.com:8200
+	Addr string
+	// TokenFile - файл с токеном Vault, например из Vault Agent; читается при каждом запросе, чтобы
+	// подхватывать продлённый токен. Если не задан, используется переменная VAULT_TOKEN
+	TokenFile string
+	// Namespace - пространство имён Vault Enterprise; пустое для открытой версии
+	Namespace string
+	Timeout   time.Duration
+}
+
+func (o VaultOptions) withDefaults() VaultOptions {
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	o.Addr = strings.TrimRight(o.Addr, "/")
+	return o
+}
+
+// VaultProvider читает секреты через HTTP API Vault. Path ссылки - путь API без /v1/,
+// например kv/data/system для движка KV второй версии; Field - ключ внутри секрета
+type VaultProvider struct {
+	opts   VaultOptions
+	client *http.Client
+}
+
+// NewVault создаёт источник секретов Vault
+func NewVault(opts VaultOptions) (*VaultProvider, error) {
+	opts = opts.withDefaults()
+	if opts.Addr == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	return &VaultProvider{opts: opts, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// Fetch реализует Provider
+func (p *VaultProvider) Fetch(ctx context.Context, ref Ref) (string, error) {
+	if ref.Field == "" {
+		return "", fmt.Errorf("vault reference %s must name a field after #", ref)
+	}
+	token, err := p.token()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.Addr+"/v1/"+strings.TrimLeft(ref.Path, "/"), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create vault request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if p.opts.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.opts.Namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: vault path %s", ErrNotFound, ref.Path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %v", err)
+	}
+	// KV второй версии вкладывает значения в data.data рядом с data.metadata
+	fields := result.Data
+	if nested, ok := fields["data"].(map[string]any); ok {
+		if _, versioned := fields["metadata"]; versioned {
+			fields = nested
+		}
+	}
+	return stringField(fields, ref.Field)
+}
+
+// token возвращает токен Vault из файла или переменной окружения
+func (p *VaultProvider) token() (string, error) {
+	if p.opts.TokenFile == "" {
+		token := os.Getenv("VAULT_TOKEN")
+		if token == "" {
+			return "", fmt.Errorf("vault token is not set: configure a token file or VAULT_TOKEN")
+		}
+		return token, nil
+	}
+	data, err := os.ReadFile(p.opts.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault token: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
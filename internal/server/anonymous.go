@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// anonymousCookiePattern - допустимое значение cookie анонимного идентификатора
+var anonymousCookiePattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// withAnonymousID передаёт в контекст запроса без авторизации хэш анонимного идентификатора из cookie.
+// Cookie выдаётся только запросам, которые могут быть мутациями (не GET), чтобы кэшируемые ответы
+// на GET-запросы не содержали Set-Cookie. Сам идентификатор нигде не сохраняется: HMAC с ключом
+// secret не позволяет восстановить его и связать с cookie без ключа
+func withAnonymousID(secret []byte, cookieName string, maxAge time.Duration, next http.Handler) http.Handler {
+	log.Printf("Анонимные комментарии включены: cookie %s, срок %v", cookieName, maxAge)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var value string
+		if cookie, err := r.Cookie(cookieName); err == nil && anonymousCookiePattern.MatchString(cookie.Value) {
+			value = cookie.Value
+		} else if r.Method != http.MethodGet {
+			value = newAnonymousCookie()
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookieName,
+				Value:    value,
+				Path:     "/",
+				MaxAge:   int(maxAge.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(value))
+		identity := hex.EncodeToString(mac.Sum(nil)[:16])
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "anonymousID", identity)))
+	})
+}
+
+// newAnonymousCookie возвращает случайный анонимный идентификатор
+func newAnonymousCookie() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
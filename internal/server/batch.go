@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	mygraphql "github.com/ButyrinIA/system/internal/graphql"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Loaders - DataLoader-ы, которые операции получают в контексте
+type Loaders struct {
+	Comment  *mygraphql.CommentLoader
+	Reaction *mygraphql.ReactionLoader
+	Unread   *mygraphql.UnreadLoader
+	// Descendant - число ответов на любой глубине
+	Descendant *mygraphql.DescendantLoader
+	ShortID    *mygraphql.ShortIDLoader
+	// CommentByID - цитируемые комментарии
+	CommentByID *mygraphql.CommentByIDLoader
+}
+
+// NewLoaders создаёт DataLoader-ы поверх хранилища
+func NewLoaders(store storage.Storage) *Loaders {
+	return &Loaders{
+		Comment:     mygraphql.NewCommentLoader(store),
+		Reaction:    mygraphql.NewReactionLoader(store),
+		Unread:      mygraphql.NewUnreadLoader(store),
+		Descendant:  mygraphql.NewDescendantLoader(store),
+		ShortID:     mygraphql.NewShortIDLoader(store),
+		CommentByID: mygraphql.NewCommentByIDLoader(store),
+	}
+}
+
+// context передаёт DataLoader-ы резолверам
+func (l *Loaders) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, "commentLoader", l.Comment)
+	ctx = context.WithValue(ctx, "reactionLoader", l.Reaction)
+	ctx = context.WithValue(ctx, "descendantLoader", l.Descendant)
+	ctx = context.WithValue(ctx, "shortIDLoader", l.ShortID)
+	ctx = context.WithValue(ctx, "commentByIDLoader", l.CommentByID)
+	return context.WithValue(ctx, "unreadLoader", l.Unread)
+}
+
+// loadersKey - ключ контекста HTTP-запроса с DataLoader-ами пакета
+type loadersKey struct{}
+
+// loadersFor возвращает DataLoader-ы пакетного запроса, если операция из него, иначе shared
+func loadersFor(ctx context.Context, shared *Loaders) *Loaders {
+	if batch, ok := ctx.Value(loadersKey{}).(*Loaders); ok {
+		return batch
+	}
+	return shared
+}
+
+// withBatching выполняет пакетные запросы: POST с JSON-массивом операций. Операции выполняются параллельно
+// как отдельные запросы, ответ - массив их ответов в том же порядке; ошибка одной операции не влияет на остальные.
+// Операции пакета используют общие DataLoader-ы из newLoaders, поэтому их загрузки группируются вместе.
+// Пакеты больше maxSize отклоняются; при maxSize 0 запрос передаётся дальше как есть
+func withBatching(maxSize int, newLoaders func() *Loaders, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxSize <= 0 || r.Method != http.MethodPost || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeBatchError(w, http.StatusBadRequest, gqlerrors.CodeBadUserInput, "failed to read request body: %v", err)
+			return
+		}
+		if trimmed := bytes.TrimSpace(body); len(trimmed) == 0 || trimmed[0] != '[' {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+			return
+		}
+		var operations []json.RawMessage
+		if err := json.Unmarshal(body, &operations); err != nil {
+			writeBatchError(w, http.StatusBadRequest, gqlerrors.CodeBadUserInput, "batch must be a JSON array of operations: %v", err)
+			return
+		}
+		if len(operations) == 0 || len(operations) > maxSize {
+			writeBatchError(w, http.StatusBadRequest, gqlerrors.CodeBadUserInput, "batch must contain from 1 to %d operations, got %d", maxSize, len(operations))
+			return
+		}
+		log.Printf("Пакетный запрос: %d операций", len(operations))
+
+		ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders())
+		responses := make([]json.RawMessage, len(operations))
+		var wg sync.WaitGroup
+		for i, operation := range operations {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				responses[i] = executeBatched(ctx, r, operation, next)
+			}()
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(responses)
+	})
+}
+
+// executeBatched выполняет одну операцию пакета и возвращает её ответ GraphQL. Ответ, который не является
+// JSON, например текст ошибки HTTP, заменяется ответом с ошибкой
+func executeBatched(ctx context.Context, r *http.Request, operation json.RawMessage, next http.Handler) json.RawMessage {
+	sub := r.Clone(ctx)
+	sub.Body = io.NopCloser(bytes.NewReader(operation))
+	sub.ContentLength = int64(len(operation))
+	rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+	next.ServeHTTP(rec, sub)
+	if json.Valid(rec.body.Bytes()) {
+		return rec.body.Bytes()
+	}
+	log.Printf("Операция пакета завершилась статусом %d без ответа GraphQL: %s", rec.status, bytes.TrimSpace(rec.body.Bytes()))
+	resp, _ := json.Marshal(batchError(gqlerrors.CodeInternal, "operation failed with status %d", rec.status))
+	return resp
+}
+
+// batchError возвращает ответ GraphQL с одной ошибкой с кодом code
+func batchError(code, format string, args ...any) *graphql.Response {
+	err := gqlerror.Errorf(format, args...)
+	errcode.Set(err, code)
+	return &graphql.Response{Errors: gqlerror.List{err}}
+}
+
+// writeBatchError отвечает на весь пакетный запрос ошибкой
+func writeBatchError(w http.ResponseWriter, status int, code, format string, args ...any) {
+	log.Printf("Пакетный запрос отклонён: %s", fmt.Sprintf(format, args...))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(batchError(code, format, args...))
+}
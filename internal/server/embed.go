@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/export"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// oEmbedResponse - описание виджета по спецификации oEmbed 1.0, тип rich
+type oEmbedResponse struct {
+	Version     string `json:"version"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	AuthorName  string `json:"author_name"`
+	ProviderURL string `json:"provider_url"`
+	HTML        string `json:"html"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	CacheAge    int    `json:"cache_age,omitempty"`
+}
+
+// embedURL возвращает внешний адрес виджета поста postID
+func (s *Server) embedURL(postID string) string {
+	return strings.TrimSuffix(s.cfg.Embed.BaseURL, "/") + "/embed/" + url.PathEscape(postID)
+}
+
+// oEmbedURL возвращает адрес описания виджета поста postID
+func (s *Server) oEmbedURL(postID string) string {
+	return strings.TrimSuffix(s.cfg.Embed.BaseURL, "/") + "/oembed?format=json&url=" + url.QueryEscape(s.embedURL(postID))
+}
+
+// handleEmbed отдаёт виджет с деревом комментариев поста для встраивания на внешние сайты через iframe.
+// Виджет только показывает обсуждение анонимному посетителю и ведёт на страницу входа
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	postID := r.PathValue("id")
+	doc, err := s.exporter.Build(r.Context(), postID, "")
+	if errors.Is(err, storage.ErrPostNotFound) {
+		writeError(w, r, http.StatusNotFound, gqlerrors.CodeNotFound, "post not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при сборке виджета поста %s: %v", postID, err)
+		writeError(w, r, http.StatusInternalServerError, gqlerrors.CodeInternal, "failed to render comments")
+		return
+	}
+	var buf bytes.Buffer
+	format := export.Embed{LoginURL: s.cfg.Embed.LoginURL, OEmbedURL: s.oEmbedURL(postID)}
+	if err := format.Write(&buf, doc); err != nil {
+		log.Printf("Ошибка при выводе виджета поста %s: %v", postID, err)
+		writeError(w, r, http.StatusInternalServerError, gqlerrors.CodeInternal, "failed to render comments")
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	// Виджет встраивается на любые сайты, поэтому запрет фреймов снимается явно
+	w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="alternate"; type="application/json+oembed"`, format.OEmbedURL))
+	if maxAge := int(s.cfg.Server.CacheMaxAge.Seconds()); maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	}
+	w.Write(buf.Bytes())
+}
+
+// handleOEmbed отвечает на запрос обнаружения oEmbed для адреса виджета: возвращает iframe
+// не больше запрошенных maxwidth и maxheight. Поддерживается только формат json
+func (s *Server) handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		writeError(w, r, http.StatusNotImplemented, codeNotImplemented, "only json format is supported")
+		return
+	}
+	prefix := strings.TrimSuffix(s.cfg.Embed.BaseURL, "/") + "/embed/"
+	escapedID, ok := strings.CutPrefix(query.Get("url"), prefix)
+	postID, err := url.PathUnescape(escapedID)
+	if !ok || err != nil || postID == "" || strings.Contains(postID, "/") {
+		writeError(w, r, http.StatusNotFound, gqlerrors.CodeNotFound, "url is not an embeddable post")
+		return
+	}
+	post, err := storage.ViewPost(r.Context(), s.storage, postID, "")
+	if errors.Is(err, storage.ErrPostNotFound) {
+		writeError(w, r, http.StatusNotFound, gqlerrors.CodeNotFound, "post not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при ответе oEmbed для поста %s: %v", postID, err)
+		writeError(w, r, http.StatusInternalServerError, gqlerrors.CodeInternal, "failed to describe embed")
+		return
+	}
+
+	width := limitDimension(s.cfg.Embed.Width, query.Get("maxwidth"))
+	height := limitDimension(s.cfg.Embed.Height, query.Get("maxheight"))
+	resp := oEmbedResponse{
+		Version:     "1.0",
+		Type:        "rich",
+		Title:       post.Title,
+		AuthorName:  post.AuthorID,
+		ProviderURL: s.cfg.Embed.BaseURL,
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" loading="lazy" title="%s"></iframe>`,
+			html.EscapeString(s.embedURL(postID)), width, height, html.EscapeString(post.Title)),
+		Width:    width,
+		Height:   height,
+		CacheAge: int(s.cfg.Server.CacheMaxAge.Seconds()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// limitDimension уменьшает размер value до ограничения limit из запроса, если оно задано и меньше
+func limitDimension(value int, limit string) int {
+	if n, err := strconv.Atoi(limit); err == nil && n > 0 && n < value {
+		return n
+	}
+	return value
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/cachecontrol"
+	"github.com/ButyrinIA/system/internal/costbudget"
+)
+
+// withETag добавляет ETag к ответам на GET-запросы GraphQL и отвечает 304 Not Modified, если клиент
+// прислал совпадающий If-None-Match. Cache-Control строится по политике из extensions.cacheControl,
+// срок ограничен maxAge; ответы с ошибками и без политики не кэшируются.
+// ETag слабый: сжатие меняет байты ответа, но не его содержимое. Учёт бюджета стоимости
+// из extensions.costBudget меняется с каждым запросом и в ETag не входит
+func withETag(maxAge time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		h := w.Header()
+		for key, values := range rec.header {
+			h[key] = values
+		}
+		if rec.status != http.StatusOK {
+			w.WriteHeader(rec.status)
+			_, _ = w.Write(rec.body.Bytes())
+			return
+		}
+		sum := sha256.Sum256(withoutCostBudget(rec.body.Bytes()))
+		etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+		h.Set("ETag", etag)
+		h.Add("Vary", "Authorization")
+		h.Set("Cache-Control", cacheControlHeader(rec.body.Bytes(), maxAge))
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(rec.body.Bytes())
+	})
+}
+
+// withoutCostBudget возвращает ответ GraphQL без extensions.costBudget; ответ без учёта бюджета
+// или не разобранный как JSON возвращается как есть
+func withoutCostBudget(body []byte) []byte {
+	if !bytes.Contains(body, []byte(costbudget.ExtensionKey)) {
+		return body
+	}
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return body
+	}
+	var extensions map[string]json.RawMessage
+	if err := json.Unmarshal(resp["extensions"], &extensions); err != nil {
+		return body
+	}
+	if _, ok := extensions[costbudget.ExtensionKey]; !ok {
+		return body
+	}
+	delete(extensions, costbudget.ExtensionKey)
+	stripped, err := json.Marshal(extensions)
+	if err != nil {
+		return body
+	}
+	resp["extensions"] = stripped
+	if stripped, err = json.Marshal(resp); err != nil {
+		return body
+	}
+	return stripped
+}
+
+// etagMatches сравнивает If-None-Match с ETag по слабому сравнению из RFC 9110
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControlHeader возвращает Cache-Control для ответа GraphQL с политикой кэширования не дольше maxAge
+func cacheControlHeader(body []byte, maxAge time.Duration) string {
+	var resp struct {
+		Errors     json.RawMessage `json:"errors"`
+		Extensions struct {
+			CacheControl *cachecontrol.Policy `json:"cacheControl"`
+		} `json:"extensions"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "no-cache"
+	}
+	policy := resp.Extensions.CacheControl
+	if (len(resp.Errors) > 0 && string(resp.Errors) != "null") || policy == nil {
+		return "no-cache"
+	}
+	seconds := min(policy.MaxAge, int(maxAge.Seconds()))
+	if seconds <= 0 {
+		return "no-cache"
+	}
+	if policy.Scope == cachecontrol.ScopePrivate {
+		return fmt.Sprintf("private, max-age=%d", seconds)
+	}
+	return fmt.Sprintf("public, max-age=%d", seconds)
+}
+
+// responseRecorder копит ответ целиком, чтобы посчитать ETag до отправки
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status = status
+		r.wrote = true
+	}
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wrote = true
+	return r.body.Write(p)
+}
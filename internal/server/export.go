@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ButyrinIA/system/internal/auth"
+	"github.com/ButyrinIA/system/internal/export"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// handleExport отдаёт пост со всем деревом комментариев самостоятельной HTML-страницей для архива.
+// Содержимое выбирается от имени пользователя из заголовка Authorization, без него - как для анонимного посетителя;
+// токен проверяет auth.Middleware
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	postID := r.PathValue("id")
+	viewerID := auth.UserID(r.Context())
+	doc, err := s.exporter.Build(r.Context(), postID, viewerID)
+	if errors.Is(err, storage.ErrPostNotFound) {
+		writeError(w, r, http.StatusNotFound, gqlerrors.CodeNotFound, "post not found")
+		return
+	}
+	if err != nil {
+		s.logger.Printf("Ошибка при выгрузке поста %s: %v", postID, err)
+		writeError(w, r, http.StatusInternalServerError, gqlerrors.CodeInternal, "failed to export post")
+		return
+	}
+	// Страница собирается целиком, чтобы ошибка не оставила клиенту обрезанный файл
+	var buf bytes.Buffer
+	format := export.HTML{}
+	if err := format.Write(&buf, doc); err != nil {
+		s.logger.Printf("Ошибка при выводе поста %s: %v", postID, err)
+		writeError(w, r, http.StatusInternalServerError, gqlerrors.CodeInternal, "failed to export post")
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="post-%s.%s"`, postID, format.Extension()))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/reporting"
+	"github.com/google/uuid"
+)
+
+// Коды ошибок HTTP-эндпоинтов вне GraphQL в дополнение к кодам gqlerrors
+const (
+	codeUnavailable    = "UNAVAILABLE"
+	codeNotImplemented = "NOT_IMPLEMENTED"
+)
+
+// RequestIDHeader - заголовок с ID запроса: принимается от клиента или прокси и возвращается в ответе
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern - допустимый ID запроса из заголовка; другие значения заменяются новым ID
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// requestIDKey - ключ контекста, под которым хранится ID запроса
+type requestIDKey struct{}
+
+// requestIDFromContext возвращает ID запроса или пустую строку
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// errorBody - описание ошибки в ответе HTTP-эндпоинта вне GraphQL
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RequestID - ID запроса для поиска в логах, совпадает с заголовком X-Request-ID ответа
+	RequestID string `json:"requestId"`
+}
+
+// errorResponse - общий вид ответа с ошибкой HTTP-эндпоинтов вне GraphQL
+type errorResponse struct {
+	// Status - состояние сервера в ответах проб /healthz и /readyz
+	Status string    `json:"status,omitempty"`
+	Error  errorBody `json:"error"`
+}
+
+// writeError отвечает статусом status и ошибкой с кодом code в формате errorResponse
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, format string, args ...any) {
+	writeErrorResponse(w, r, status, errorResponse{Error: errorBody{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+// writeErrorResponse дополняет resp ID запроса и отвечает им со статусом status
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, resp errorResponse) {
+	resp.Error.RequestID = requestIDFromContext(r.Context())
+	log.Printf("Запрос %s %s [%s] завершён ошибкой %d %s: %s", r.Method, r.URL.Path, resp.Error.RequestID, status, resp.Error.Code, resp.Error.Message)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// rejectToken отвечает на запрос с недействительным заголовком Authorization, см. auth.Middleware
+func rejectToken(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, http.StatusUnauthorized, gqlerrors.CodeUnauthenticated, "invalid token")
+}
+
+// withRequestID передаёт в контекст запроса ID из заголовка X-Request-ID или новый, если его нет
+// или он недопустим, и возвращает ID в заголовке ответа
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// withRecovery превращает панику обработчика в ответ INTERNAL, а событие со стеком отправляет в reporter.
+// Паники резолверов GraphQL перехватывает сам gqlgen; сюда попадают паники остальных эндпоинтов.
+// Если ответ уже начат, к нему ничего не добавляется
+func withRecovery(reporter reporting.Reporter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			event := &reporting.Event{
+				ID:        uuid.NewString(),
+				Err:       fmt.Errorf("panic: %v", p),
+				Stack:     debug.Stack(),
+				PCs:       reporting.Callers(),
+				Operation: r.Method + " " + r.URL.Path,
+				Time:      time.Now(),
+			}
+			event.UserID, _ = r.Context().Value("userID").(string)
+			reporter.Report(r.Context(), event)
+			if !rec.wroteHeader {
+				writeError(rec, r, http.StatusInternalServerError, gqlerrors.CodeInternal, "internal server error")
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// statusRecorder запоминает, начат ли ответ
+type statusRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader реализует http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	r.wroteHeader = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write реализует http.ResponseWriter
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap открывает исходный ResponseWriter для http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
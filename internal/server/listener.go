@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Переменные окружения передачи слушающего сокета, совместимые с активацией сокетов systemd:
+// LISTEN_FDS - количество переданных дескрипторов, начиная с listenFDsStart,
+// LISTEN_PID - процесс, которому они предназначены; пустой - любой, как при передаче от прежнего процесса
+const (
+	EnvListenFDs   = "LISTEN_FDS"
+	EnvListenPID   = "LISTEN_PID"
+	listenFDsStart = 3
+)
+
+// Listen открывает порт сервера. Если процесс получил слушающий сокет от прежнего процесса или от systemd,
+// используется он: соединения, ожидающие в очереди сокета, не теряются при перезапуске
+func (s *Server) Listen() (net.Listener, error) {
+	ln, err := inheritedListener()
+	if err != nil {
+		return nil, err
+	}
+	if ln != nil {
+		log.Printf("Используется унаследованный слушающий сокет %s", ln.Addr())
+	} else {
+		lc := net.ListenConfig{}
+		if s.cfg.Server.ReusePort {
+			lc.Control = reusePort
+		}
+		if ln, err = lc.Listen(context.Background(), "tcp", s.http.Addr); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %v", s.http.Addr, err)
+		}
+	}
+	s.listener.Store(&ln)
+	return ln, nil
+}
+
+// ListenerFile возвращает копию дескриптора слушающего сокета для передачи новому процессу
+func (s *Server) ListenerFile() (*os.File, error) {
+	ln := s.listener.Load()
+	if ln == nil {
+		return nil, errors.New("server is not listening")
+	}
+	tcp, ok := (*ln).(*net.TCPListener)
+	if !ok {
+		return nil, fmt.Errorf("listener %T cannot be passed to another process", *ln)
+	}
+	return tcp.File()
+}
+
+// inheritedListener возвращает слушающий сокет, переданный через LISTEN_FDS, или nil, если сокета нет.
+// Переменные удаляются из окружения, чтобы их не унаследовали дочерние процессы
+func inheritedListener() (net.Listener, error) {
+	fds := os.Getenv(EnvListenFDs)
+	if fds == "" {
+		return nil, nil
+	}
+	pid := os.Getenv(EnvListenPID)
+	os.Unsetenv(EnvListenFDs)
+	os.Unsetenv(EnvListenPID)
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	if n, err := strconv.Atoi(fds); err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid %s %q", EnvListenFDs, fds)
+	}
+	file := os.NewFile(listenFDsStart, "listener")
+	defer file.Close()
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use inherited listener: %v", err)
+	}
+	return ln, nil
+}
+
+// reusePort включает SO_REUSEPORT, чтобы несколько процессов могли слушать один порт
+func reusePort(network, address string, conn syscall.RawConn) error {
+	var sockErr error
+	err := conn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/auth"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Option настраивает сервер, создаваемый New; без опций компоненты собираются из конфигурации
+type Option func(*options)
+
+type options struct {
+	authenticator auth.Authenticator
+	loaders       func(storage.Storage) *Loaders
+	transports    []graphql.Transport
+	middleware    []func(http.Handler) http.Handler
+	logger        *log.Logger
+	clock         clock.Clock
+}
+
+func (o options) withDefaults() options {
+	if o.loaders == nil {
+		o.loaders = NewLoaders
+	}
+	if o.transports == nil {
+		o.transports = []graphql.Transport{
+			transport.Options{},
+			transport.GET{},
+			transport.MultipartMixed{},
+			transport.POST{},
+			transport.MultipartForm{},
+		}
+	}
+	if o.logger == nil {
+		o.logger = log.Default()
+	}
+	if o.clock == nil {
+		o.clock = clock.Real()
+	}
+	return o
+}
+
+// WithAuthenticator заменяет проверку JWT ключом из auth.jwtSecret конфигурации. Ею проверяются токены GraphQL-запросов,
+// подписок и выгрузки постов; SetJWTSecret после этого меняет только ключ выдачи токенов на /token
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithLoaders задаёт создание DataLoader-ов: один набор на сервер и свой на каждый пакетный запрос
+func WithLoaders(newLoaders func(storage.Storage) *Loaders) Option {
+	return func(o *options) {
+		o.loaders = newLoaders
+	}
+}
+
+// WithTransports заменяет HTTP-транспорты GraphQL-эндпоинта /query. WebSocket-транспорт с аутентификацией
+// подписок добавляется всегда: через него сервер закрывает соединения при остановке
+func WithTransports(transports ...graphql.Transport) Option {
+	return func(o *options) {
+		o.transports = transports
+	}
+}
+
+// WithMiddleware оборачивает все маршруты сервера; первый middleware получает запрос первым,
+// уже с ID запроса в контексте
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithLogger задаёт журнал сообщений сервера о сборке, запуске, остановке и аутентификации;
+// по умолчанию используется стандартный журнал
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// withClock передаёт резолверам и сервисам время от clk, см. Replay
+func withClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+)
+
+// profilingHandler отдаёт профили pprof под /debug/pprof/ для непрерывного профилирования
+// (Pyroscope, Parca). Профили раскрывают внутреннее устройство сервера, поэтому доступны только
+// с токеном в заголовке Authorization: Bearer
+func profilingHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeError(w, r, http.StatusUnauthorized, gqlerrors.CodeUnauthenticated, "profiling token required")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// profileLabels - расширение GraphQL-сервера, которое помечает выполнение операции метками pprof
+// graphql_operation и graphql_operation_type: по ним профили резолверов разбиваются по операциям.
+// Метки наследуют горутины, запущенные во время выполнения
+type profileLabels struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+} = profileLabels{}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (profileLabels) ExtensionName() string {
+	return "ProfileLabels"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (profileLabels) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation реализует graphql.OperationInterceptor. Резолверы выполняются и при подготовке
+// операции, и при получении ответа, поэтому метки ставятся на оба этапа
+func (profileLabels) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil {
+		return next(ctx)
+	}
+	name := oc.OperationName
+	if name == "" {
+		name = "anonymous"
+	}
+	labels := runtimepprof.Labels("graphql_operation", name, "graphql_operation_type", string(oc.Operation.Operation))
+	var handler graphql.ResponseHandler
+	runtimepprof.Do(ctx, labels, func(ctx context.Context) {
+		handler = next(ctx)
+	})
+	return func(ctx context.Context) *graphql.Response {
+		var resp *graphql.Response
+		runtimepprof.Do(ctx, labels, func(ctx context.Context) {
+			resp = handler(ctx)
+		})
+		return resp
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/cachecontrol"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/costbudget"
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	mygraphql "github.com/ButyrinIA/system/internal/graphql"
+	"github.com/ButyrinIA/system/internal/reporting"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// newPublicHandler собирает GraphQL-сервер эндпоинта /public/query поверх той же схемы и резолверов, что и /query.
+// Принимаются только GET и POST без WebSocket и загрузки файлов, интроспекция выключена. Заголовок Authorization
+// не проверяется, поэтому все операции анонимные, а их стоимость учитывается отдельным бюджетом по IP
+func newPublicHandler(cfg *config.Config, es graphql.ExecutableSchema, store storage.Storage, shared *Loaders, flagService *flags.Service, reporter reporting.Reporter, clk clock.Clock) *handler.Server {
+	public := cfg.PublicAPI
+	log.Printf("Создание публичного API: запросы %v, кэширование от %v до %v, бюджет %d за %v",
+		public.Queries, public.CacheMinAge, public.CacheMaxAge, public.Capacity, public.Period)
+	srv := handler.New(es)
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](100)})
+	srv.SetErrorPresenter(gqlerrors.Presenter)
+	srv.SetRecoverFunc(reporting.RecoverFunc(reporter))
+
+	srv.Use(cachecontrol.New(cachecontrol.Options{MinMaxAge: int(public.CacheMinAge / time.Second)}))
+	srv.Use(newPublicGuard(public.Queries))
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		tenant, _ := ctx.Value("tenant").(string)
+		ctx = flags.NewContext(ctx, flagService.ForTenant("", tenant))
+		return next(shared.context(ctx))
+	})
+	srv.Use(mygraphql.AnonymousAccess{Storage: store})
+	srv.Use(costbudget.New(costbudget.Options{
+		Limit: costbudget.Limit{Capacity: public.Capacity, Period: public.Period},
+		Clock: clk,
+	}))
+	srv.Use(mygraphql.Arena{})
+	return srv
+}
+
+// publicGuard пропускает на публичный эндпоинт только запросы, все корневые поля которых разрешены
+type publicGuard struct {
+	queries []string
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &publicGuard{}
+
+func newPublicGuard(queries []string) *publicGuard {
+	return &publicGuard{queries: queries}
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (g *publicGuard) ExtensionName() string {
+	return "PublicGuard"
+}
+
+// Validate реализует graphql.HandlerExtension: поля, которых нет в Query, не разрешаются
+func (g *publicGuard) Validate(schema graphql.ExecutableSchema) error {
+	query := schema.Schema().Query
+	g.queries = slices.DeleteFunc(slices.Clone(g.queries), func(name string) bool {
+		if query.Fields.ForName(name) == nil {
+			log.Printf("Поле %s не найдено в Query и не разрешено в публичном API", name)
+			return true
+		}
+		return false
+	})
+	return nil
+}
+
+// MutateOperationContext отклоняет мутации, подписки и запросы с неразрешёнными корневыми полями.
+// Поля собираются с учётом фрагментов и директив @skip и @include; __typename разрешено всегда
+func (g *publicGuard) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	if oc.Operation == nil {
+		return nil
+	}
+	if oc.Operation.Operation != ast.Query {
+		log.Printf("Операция %s отклонена публичным API: %s", oc.OperationName, oc.Operation.Operation)
+		return publicForbidden("only queries are allowed on the public API")
+	}
+	for _, field := range graphql.CollectFields(oc, oc.Operation.SelectionSet, []string{"Query"}) {
+		if field.Name != "__typename" && !slices.Contains(g.queries, field.Name) {
+			log.Printf("Операция %s отклонена публичным API: поле %s не разрешено", oc.OperationName, field.Name)
+			return publicForbidden("field " + field.Name + " is not available on the public API")
+		}
+	}
+	return nil
+}
+
+func publicForbidden(message string) *gqlerror.Error {
+	err := gqlerror.Errorf("%s", message)
+	errcode.Set(err, gqlerrors.CodeForbidden)
+	return err
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicAPI(t *testing.T) {
+	store := memory.New()
+	require.NoError(t, store.CreatePost(context.Background(), &models.Post{ID: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55", Title: "Пост", Content: "Текст", AuthorID: "user1", AllowComments: true, CreatedAt: time.Now()}))
+	newHandler := func(capacity int) http.Handler {
+		cfg := config.Default()
+		cfg.PublicAPI.Enabled = true
+		cfg.PublicAPI.Capacity = capacity
+		return New(cfg, store).Handler()
+	}
+	handler := newHandler(1000)
+	type response struct {
+		Data   map[string]any `json:"data"`
+		Errors []struct {
+			Extensions map[string]any `json:"extensions"`
+		} `json:"errors"`
+	}
+	code := func(resp response) string {
+		if len(resp.Errors) == 0 {
+			return ""
+		}
+		code, _ := resp.Errors[0].Extensions["code"].(string)
+		return code
+	}
+	get := func(h http.Handler, query string, headers map[string]string) (*httptest.ResponseRecorder, response) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/public/query?"+url.Values{"query": {query}}.Encode(), nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var resp response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp), rr.Body.String())
+		return rr, resp
+	}
+
+	// Срок кэширования поднимается до cacheMinAge, заголовок Authorization не проверяется
+	rr, resp := get(handler, `{ posts(limit: 10) { posts { title comments(limit: 5) { totalCount } } } }`, map[string]string{"Authorization": "Bearer invalid"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	_, resp = get(handler, `{ post(id: "0f8c6d6e-3c41-4f0a-9a53-7d1f3b2e4c55") { title } __typename }`, nil)
+	assert.Empty(t, resp.Errors)
+
+	_, resp = get(handler, `{ me { id } }`, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, code(resp))
+	_, resp = get(handler, `query { ...F } fragment F on Query { categories { name } }`, nil)
+	assert.Equal(t, gqlerrors.CodeForbidden, code(resp))
+	_, resp = get(handler, `{ __schema { types { name } } }`, nil)
+	assert.NotEmpty(t, resp.Errors, "Интроспекция выключена")
+
+	body, err := json.Marshal(map[string]string{"query": `mutation { createPost(title: "Спам", content: "Спам", allowComments: true) { id } }`})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/public/query", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	resp = response{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, gqlerrors.CodeForbidden, code(resp))
+
+	// Бюджет публичного API меньше и не зависит от бюджета /query
+	_, resp = get(newHandler(1), `{ posts(limit: 10) { posts { title } } }`, nil)
+	assert.Equal(t, gqlerrors.CodeBudgetExhausted, code(resp))
+}
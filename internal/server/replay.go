@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/replay"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Replay воспроизводит мутации, записанные сервером с включённым recording, на хранилище store
+// и сравнивает ответы с записанными. Сервер собирается с конфигурацией cfg, но без рассылок,
+// push-уведомлений, внедрения сбоев и повторной записи; часы резолверов переводятся на время
+// каждой записанной мутации
+func Replay(ctx context.Context, cfg *config.Config, store storage.Storage, in io.Reader) (*replay.Result, error) {
+	replayCfg := *cfg
+	replayCfg.Server.Maintenance = false
+	replayCfg.Digest.Enabled = false
+	replayCfg.SearchAlerts.Enabled = false
+	replayCfg.Push.Enabled = false
+	replayCfg.Faults.Enabled = false
+	replayCfg.Recording.Enabled = false
+	clk := clock.NewFake(time.Now())
+	srv := New(&replayCfg, store, withClock(clk))
+	return replay.Replay(ctx, srv.handler, in, replay.Options{Clock: clk})
+}
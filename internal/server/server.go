@@ -6,187 +6,872 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"strings"
+	"regexp"
+	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
-	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/ButyrinIA/system/internal/allowlist"
+	"github.com/ButyrinIA/system/internal/auth"
+	"github.com/ButyrinIA/system/internal/cachecontrol"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/cluster"
 	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/costbudget"
+	"github.com/ButyrinIA/system/internal/digest"
+	"github.com/ButyrinIA/system/internal/duplicates"
+	"github.com/ButyrinIA/system/internal/email"
+	"github.com/ButyrinIA/system/internal/excerpt"
+	"github.com/ButyrinIA/system/internal/export"
+	"github.com/ButyrinIA/system/internal/faults"
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/gctuning"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
 	mygraphql "github.com/ButyrinIA/system/internal/graphql"
+	"github.com/ButyrinIA/system/internal/ids"
+	"github.com/ButyrinIA/system/internal/linkpreview"
+	"github.com/ButyrinIA/system/internal/maintenance"
+	"github.com/ButyrinIA/system/internal/metrics"
 	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/moderation"
+	"github.com/ButyrinIA/system/internal/partial"
+	"github.com/ButyrinIA/system/internal/purge"
+	"github.com/ButyrinIA/system/internal/push"
+	"github.com/ButyrinIA/system/internal/quota"
+	"github.com/ButyrinIA/system/internal/ranking"
+	"github.com/ButyrinIA/system/internal/related"
+	"github.com/ButyrinIA/system/internal/replay"
+	"github.com/ButyrinIA/system/internal/reporting"
+	"github.com/ButyrinIA/system/internal/search"
+	"github.com/ButyrinIA/system/internal/searchalert"
+	"github.com/ButyrinIA/system/internal/slo"
+	"github.com/ButyrinIA/system/internal/spam"
 	"github.com/ButyrinIA/system/internal/storage"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/gorilla/websocket"
-	"github.com/graph-gophers/dataloader/v7"
-	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/ButyrinIA/system/internal/storage/cache"
+	"github.com/ButyrinIA/system/internal/storage/coalesce"
+	"github.com/ButyrinIA/system/internal/storage/faulty"
+	"github.com/ButyrinIA/system/internal/storm"
+	"github.com/ButyrinIA/system/internal/suggest"
+	"github.com/ButyrinIA/system/internal/textsize"
+	"github.com/ButyrinIA/system/internal/toxicity"
+	"github.com/ButyrinIA/system/internal/translate"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 // Server представляет HTTP-сервер для обработки GraphQL-запросов
 type Server struct {
-	cfg     *config.Config
-	storage storage.Storage
-	handler *handler.Server
-}
-
-// New создаёт новый сервер с заданной конфигурацией и хранилищем
-func New(cfg *config.Config, storage storage.Storage) *Server {
-	log.Printf("Создание нового сервера с портом: %s", cfg.Server.Port)
-
-	// Инициализация DataLoader для пакетной загрузки комментариев
-	commentLoader := dataloader.NewBatchedLoader(
-		func(ctx context.Context, keys []string) []*dataloader.Result[*models.PaginatedComments] {
-			results := make([]*dataloader.Result[*models.PaginatedComments], len(keys))
-			for i, postID := range keys {
-				comments, err := storage.GetComments(ctx, postID, nil, 10, nil)
-				if err != nil {
-					log.Printf("Ошибка загрузки комментариев для postID=%s: %v", postID, err)
-					results[i] = &dataloader.Result[*models.PaginatedComments]{Error: err}
-				} else {
-					log.Printf("Получено комментариев для postID=%s: %d", postID, len(comments.Comments))
-					results[i] = &dataloader.Result[*models.PaginatedComments]{Data: comments}
+	cfg       *config.Config
+	storage   storage.Storage
+	handler   *handler.Server
+	jwtSecret *auth.Key
+	// auth проверяет токены запросов; по умолчанию - JWT, подписанные jwtSecret
+	auth auth.Authenticator
+	// loaders создаёт DataLoader-ы пакетных запросов
+	loaders func(storage.Storage) *Loaders
+	// middleware оборачивает все маршруты, см. WithMiddleware
+	middleware []func(http.Handler) http.Handler
+	logger     *log.Logger
+	// maintenance - режим обслуживания, в котором мутации отклоняются
+	maintenance *maintenance.Mode
+	// faults - внедрение сбоев в HTTP-запросы; nil, если отключено
+	faults *faults.Injector
+	// public - GraphQL-сервер эндпоинта /public/query; nil, если публичный API выключен
+	public *handler.Server
+	// websockets - открытые WebSocket-соединения подписок
+	websockets *wsConnections
+	// exporter собирает выгрузки постов с комментариями для /export/posts/{id}
+	exporter *export.Service
+	// recorder - запись мутаций для воспроизведения; nil, если запись выключена
+	recorder *replay.Recorder
+	// reporter получает паники резолверов и HTTP-эндпоинтов
+	reporter reporting.Reporter
+	// draining - сервер останавливается: /readyz отвечает 503, запросы ещё обслуживаются
+	draining atomic.Bool
+	// gcTuner учитывает паузы GC в запросах; nil, если не задан
+	gcTuner *gctuning.Tuner
+	// search - поиск через движок с фоновой индексацией; nil, если движок не настроен
+	search *search.Storage
+	// excerpts - анонсы постов; при остановке дожидается обработки очереди кратких содержаний
+	excerpts *excerpt.Service
+	// cluster - этот узел в кластере; nil, если события подписок не пересылаются между узлами.
+	// receiveEvent публикует подписчикам события соседей
+	cluster      *cluster.Node
+	receiveEvent func(cluster.Event) error
+	// listener - слушающий сокет, открытый Listen
+	listener atomic.Pointer[net.Listener]
+	http     *http.Server
+}
+
+// New создаёт новый сервер с заданной конфигурацией и хранилищем. Опции заменяют компоненты, которые
+// иначе собираются из конфигурации: аутентификацию, DataLoader-ы, транспорты, middleware и журнал
+func New(cfg *config.Config, storage storage.Storage, opts ...Option) *Server {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o = o.withDefaults()
+	clk, logger := o.clock, o.logger
+	logger.Printf("Создание нового сервера с портом: %s", cfg.Server.Port)
+	jwtSecret := auth.NewKey([]byte(cfg.Auth.JWTSecret))
+	if cfg.Auth.JWTSecret == "" {
+		logger.Println("Ключ подписи JWT не задан, используется ключ для разработки")
+		jwtSecret.Set([]byte(config.DevJWTSecret))
+	}
+	authenticator := o.authenticator
+	if authenticator == nil {
+		authenticator = auth.JWT(jwtSecret)
+	}
+
+	// Внедрение сбоев для проверки устойчивости; кеш постов стоит над ним, как над настоящим хранилищем
+	var httpFaults *faults.Injector
+	if cfg.Faults.Enabled {
+		if cfg.Environment == config.EnvProduction {
+			logger.Println("Внедрение сбоев не включается в production")
+		} else {
+			storage = faulty.New(storage, faults.New("storage", cfg.Faults.Storage))
+			httpFaults = faults.New("http", cfg.Faults.HTTP)
+		}
+	}
+
+	// Общий короткоживущий кеш постов для горячих чтений по ID
+	if cfg.Cache.PostTTL > 0 {
+		storage = cache.New(storage, cache.Options{TTL: cfg.Cache.PostTTL, Size: cfg.Cache.PostSize})
+	}
+
+	// Во время шторма комментарии вставляются пачками
+	if cfg.Storm.Enabled {
+		storage = coalesce.New(storage, coalesce.Options{
+			Threshold: cfg.Storm.WriteThreshold,
+			Window:    cfg.Storm.BatchWindow,
+			MaxBatch:  cfg.Storm.MaxBatch,
+			Clock:     clk,
+		})
+	}
+
+	// Поиск постов по тексту во внешнем движке с фоновой индексацией изменённых постов
+	var searchStore *search.Storage
+	engine, err := NewSearchEngine(cfg)
+	if err != nil {
+		logger.Printf("Поиск через движок не включён: %v", err)
+	} else if engine != nil {
+		searchStore = search.New(storage, engine, search.Options{
+			MaxResults: cfg.Search.MaxResults,
+			QueueSize:  cfg.Search.QueueSize,
+			BatchSize:  cfg.Search.BatchSize,
+		})
+		storage = searchStore
+		if engine.Name() == search.EngineMemory {
+			// Встроенный индекс после запуска пуст: заполняем его из хранилища в фоне
+			store := storage
+			go func() {
+				if _, err := search.Reindex(context.Background(), store, engine, cfg.Search.BatchSize); err != nil {
+					logger.Printf("Не удалось заполнить встроенный поисковый индекс: %v", err)
 				}
-			}
-			return results
-		},
-		dataloader.WithCache[string, *models.PaginatedComments](&dataloader.NoCache[string, *models.PaginatedComments]{}),
-	)
+			}()
+		}
+	}
+
+	// DataLoader-ы комментариев, счётчиков реакций и непрочитанных комментариев; у пакетных запросов свои, см. withBatching
+	shared := o.loaders(storage)
+
+	// Флаги постепенного включения возможностей, вычисляются для каждой операции
+	flagService := newFlagService(cfg)
+	flagService.Start()
 
 	// Создание GraphQL-сервера с резолвером
-	resolver := mygraphql.NewResolver(storage, commentLoader)
+	resolver := mygraphql.NewResolver(storage, shared.Comment)
+	resolver.Clock = clk
+	if cfg.Storm.Enabled {
+		detector := storm.New(storm.Options{Scope: "subscription_events", Threshold: cfg.Storm.EventThreshold, Clock: clk})
+		resolver.SubscriptionHandler.EnableDigests(detector, cfg.Storm.DigestInterval, cfg.Storm.DigestMaxComments)
+	}
+	// События подписок пересылаются соседним узлам, чтобы комментарий дошёл до подписчиков на любом узле
+	var node *cluster.Node
+	if cfg.Cluster.Enabled {
+		node, err = cluster.New(cluster.Options{
+			NodeID:    cfg.Cluster.NodeID,
+			Peers:     cfg.Cluster.Peers,
+			Secret:    cfg.Cluster.Secret,
+			Sticky:    cfg.Cluster.Mode == config.ClusterModeSticky,
+			Timeout:   cfg.Cluster.Timeout,
+			QueueSize: cfg.Cluster.QueueSize,
+		})
+		if err != nil {
+			logger.Printf("Пересылка событий между узлами не включена: %v", err)
+		} else {
+			resolver.SubscriptionHandler.EnableForwarding(node)
+		}
+	}
+	resolver.IDs = newIDGenerator(cfg, clk)
+	mode := maintenance.New(cfg.Server.Maintenance)
+	resolver.Maintenance = mode
+	if cfg.LinkPreview.Enabled {
+		resolver.LinkPreviews = linkpreview.New(storage, linkpreview.Options{
+			Timeout:      cfg.LinkPreview.Timeout,
+			Workers:      cfg.LinkPreview.Workers,
+			AllowDomains: cfg.LinkPreview.AllowDomains,
+			DenyDomains:  cfg.LinkPreview.DenyDomains,
+			Clock:        clk,
+		})
+	}
+	if cfg.Quotas.Enabled {
+		roles := make(map[string]quota.Limits, len(cfg.Quotas.Roles))
+		for role, limits := range cfg.Quotas.Roles {
+			roles[role] = quota.Limits{PostsPerDay: limits.PostsPerDay, CommentsPerMinute: limits.CommentsPerMinute}
+		}
+		resolver.Quotas = quota.New(storage, quota.Options{Roles: roles, DefaultRole: cfg.Quotas.DefaultRole, Clock: clk})
+	}
+	tenantQuotas := make(map[string]quota.TenantLimits, len(cfg.Tenants.QuotaOverrides))
+	for tenant, limits := range cfg.Tenants.QuotaOverrides {
+		tenantQuotas[tenant] = quota.TenantLimits(limits)
+	}
+	resolver.TenantQuotas = quota.NewTenant(storage, quota.TenantOptions{Default: quota.TenantLimits(cfg.Tenants.Quota), Tenants: tenantQuotas})
+	// Без раздела content, например в конфигурации тестов, остаются встроенные лимиты резолвера
+	if cfg.Content.Unit != "" {
+		contentLimits := make(map[string]textsize.Limits, len(cfg.Content.LimitOverrides))
+		for tenant, limits := range cfg.Content.LimitOverrides {
+			contentLimits[tenant] = textsize.Limits(limits)
+		}
+		resolver.ContentLimits = textsize.New(textsize.Options{
+			Unit:    textsize.Unit(cfg.Content.Unit),
+			Default: textsize.Limits(cfg.Content.Limits),
+			Tenants: contentLimits,
+		})
+	}
+	var summarizer excerpt.Summarizer
+	if cfg.Content.Summarizer.Enabled {
+		summarizer = excerpt.NewHTTP(excerpt.HTTPOptions{URL: cfg.Content.Summarizer.URL, Timeout: cfg.Content.Summarizer.Timeout})
+	}
+	resolver.Excerpts = excerpt.New(summarizer, storage, excerpt.Options{
+		Length:    cfg.Content.ExcerptLength,
+		Timeout:   cfg.Content.Summarizer.Timeout,
+		Workers:   cfg.Content.Summarizer.Workers,
+		QueueSize: cfg.Content.Summarizer.QueueSize,
+	})
+	resolver.Moderation = moderation.New(storage, moderation.Options{Clock: clk})
+	resolver.Duplicates = duplicates.New(storage, duplicates.Options{
+		Enforcement: duplicates.Enforcement(cfg.Content.Duplicates.Enforcement),
+		Threshold:   cfg.Content.Duplicates.Threshold,
+		MaxLimit:    cfg.Content.Duplicates.MaxLimit,
+	})
+	resolver.Related = related.New(storage, related.Options{Clock: clk})
+	resolver.Suggestions = suggest.New(storage, suggest.Options{Clock: clk})
+	// Стратегии ранжирования комментариев; внешняя модель подключается, если задан её адрес
+	rankingOpts := ranking.Options{MaxCandidates: cfg.Ranking.MaxCandidates, Tenants: cfg.Ranking.Tenants}
+	if cfg.Ranking.MLScoreURL != "" {
+		rankingOpts.MLScore = ranking.NewMLScorer(cfg.Ranking.MLScoreURL, cfg.Ranking.MLScoreTimeout)
+	}
+	resolver.Ranking = ranking.New(rankingOpts)
+	if cfg.Spam.Enabled {
+		resolver.Spam = newSpamService(cfg, storage)
+	}
+	if cfg.Toxicity.Enabled {
+		scorer := toxicity.NewPerspective(toxicity.PerspectiveOptions{
+			APIKey:   cfg.Toxicity.APIKey,
+			Endpoint: cfg.Toxicity.URL,
+			Timeout:  cfg.Toxicity.Timeout,
+		})
+		resolver.Toxicity = toxicity.New(scorer, storage, toxicity.Options{
+			Timeout:       cfg.Toxicity.Timeout,
+			Workers:       cfg.Toxicity.Workers,
+			QueueSize:     cfg.Toxicity.QueueSize,
+			HoldThreshold: cfg.Toxicity.HoldThreshold,
+			Clock:         clk,
+			IDs:           resolver.IDs,
+		})
+	}
+	if cfg.Push.Enabled {
+		resolver.Push = newPushService(cfg, storage)
+	}
+	resolver.Purge = purge.New(storage, purge.Options{
+		BatchSize:     cfg.Purge.BatchSize,
+		BatchInterval: cfg.Purge.BatchInterval,
+		Retention:     cfg.Purge.Retention,
+		IDs:           resolver.IDs,
+		Clock:         clk,
+	})
+	if cfg.Translation.Enabled {
+		translator := translate.NewLibreTranslate(translate.LibreTranslateOptions{
+			Endpoint: cfg.Translation.Endpoint,
+			APIKey:   cfg.Translation.APIKey,
+			Timeout:  cfg.Translation.Timeout,
+		})
+		resolver.Translations = translate.New(translator, translate.Options{
+			CacheSize: cfg.Translation.CacheSize,
+			CacheTTL:  cfg.Translation.CacheTTL,
+		})
+	}
+	// Фоновая рассылка сводок новых комментариев подписчикам постов
+	if cfg.Digest.Enabled {
+		digest.New(storage, newEmailSender(cfg), digest.Options{
+			Interval:    cfg.Digest.Interval,
+			MaxComments: cfg.Digest.MaxComments,
+			Clock:       clk,
+		}).Start()
+	}
+	// Фоновая проверка сохранённых поисков и письма о новых подходящих постах
+	if cfg.SearchAlerts.Enabled {
+		searchalert.New(storage, newEmailSender(cfg), searchalert.Options{
+			Interval: cfg.SearchAlerts.Interval,
+			MaxPosts: cfg.SearchAlerts.MaxPosts,
+		}).Start()
+	}
 	executableSchema := mygraphql.NewExecutableSchema(mygraphql.Config{
-		Resolvers: resolver,
+		Resolvers:  resolver,
+		Directives: mygraphql.Directives(),
 	})
-	srv := handler.NewDefaultServer(executableSchema)
-	log.Println("Сервер GraphQL успешно инициализирован")
-
-	// Конфигурация WebSocket-транспорта
-	srv.AddTransport(&transport.Websocket{
-		Upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				log.Printf("Проверка происхождения WebSocket: %s", r.Header.Get("Origin"))
-				return true
-			},
-		},
-		KeepAlivePingInterval: 30 * time.Second, // Увеличенный таймаут для стабильности
-		InitFunc: func(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
-			log.Printf("Инициализация WebSocket-соединения, payload: %+v", initPayload)
-			authHeader, ok := initPayload["Authorization"].(string)
-			if ok && authHeader != "" {
-				if !strings.HasPrefix(authHeader, "Bearer ") {
-					log.Printf("Неверный формат заголовка авторизации в WebSocket: %s", authHeader)
-					return ctx, nil, gqlerror.Errorf("Неверный формат заголовка авторизации")
-				}
-				token := strings.TrimPrefix(authHeader, "Bearer ")
-				userID, err := validateJWT(token)
-				if err != nil {
-					log.Printf("Недействительный токен в WebSocket: %v", err)
-					return ctx, nil, gqlerror.Errorf("Недействительный токен: %v", err)
-				}
-				log.Printf("Успешная аутентификация WebSocket: %s", userID)
-				ctx = context.WithValue(ctx, "userID", userID)
-				return ctx, nil, nil
-			}
-			log.Println("Заголовок авторизации отсутствует в WebSocket")
-			return ctx, nil, nil
+	srv := newHandler(executableSchema, o.transports)
+	srv.SetErrorPresenter(gqlerrors.Presenter)
+	reporter := newReporter(cfg)
+	srv.SetRecoverFunc(reporting.RecoverFunc(reporter))
+	logger.Println("Сервер GraphQL успешно инициализирован")
+
+	if cfg.Profiling.Enabled {
+		runtime.SetMutexProfileFraction(cfg.Profiling.MutexProfileFraction)
+		runtime.SetBlockProfileRate(cfg.Profiling.BlockProfileRate)
+		srv.Use(profileLabels{})
+	}
+
+	// SLI учитываются первым расширением, чтобы задержка включала работу остальных
+	if cfg.SLO.Enabled {
+		classes := make(map[string]slo.Objective, len(cfg.SLO.Classes))
+		for class, objective := range cfg.SLO.Classes {
+			classes[class] = slo.Objective(objective)
+		}
+		srv.Use(slo.New(slo.Options{Classes: classes, Clock: clk}))
+	}
+
+	// Политика кэширования ответа по подсказкам @cacheControl; ответы авторизованным пользователям приватны
+	srv.Use(cachecontrol.New(cachecontrol.Options{
+		Private: func(ctx context.Context) bool {
+			userID, _ := ctx.Value("userID").(string)
+			return userID != ""
 		},
-	})
+	}))
+
+	// Режим лучшего усилия: у срока запроса счётчики приблизительны, а глубокие агрегаты пропускаются
+	if cfg.Partial.Enabled {
+		srv.Use(partial.New(partial.Options{Budget: cfg.Partial.Budget, Reserve: cfg.Partial.Reserve, Clock: clk}))
+	}
+
+	// Режим allowlist: выполняются только заранее зарегистрированные операции из каталога или хранилища
+	if cfg.Allowlist.Enabled {
+		list := allowlist.New()
+		var err error
+		if cfg.Allowlist.Source == config.AllowlistSourceStorage {
+			err = list.LoadStorage(context.Background(), storage)
+		} else {
+			err = list.LoadDir(cfg.Allowlist.Dir)
+		}
+		if err != nil {
+			logger.Printf("Ошибка загрузки allowlist, все операции будут отклонены: %v", err)
+		}
+		srv.Use(list)
+	}
+
+	// Режим обслуживания: мутации, кроме setMaintenanceMode, отклоняются с кодом MAINTENANCE
+	srv.Use(mode)
+
+	// WebSocket-транспорт с аутентификацией; открытые соединения сервер закрывает при остановке,
+	// по истечении срока токена и по мутации closeUserSubscriptions
+	websockets := newWSConnections()
+	srv.AddTransport(newWebsocketTransport(cfg, authenticator, websockets, logger))
+	resolver.Connections = websockets
+	srv.Use(reconnectExtension{advice: reconnectAdvice{
+		MinBackoffMs: cfg.Subscriptions.ReconnectMinBackoff.Milliseconds(),
+		MaxBackoffMs: cfg.Subscriptions.ReconnectMaxBackoff.Milliseconds(),
+	}})
 
 	// Middleware для аутентификации HTTP-запросов
 	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
 		oc := graphql.GetOperationContext(ctx)
-		log.Printf("Обработка операции: %s", oc.OperationName)
-		authHeader := oc.Headers.Get("Authorization")
-		if authHeader != "" {
-			if !strings.HasPrefix(authHeader, "Bearer ") {
-				log.Printf("Неверный формат заголовка авторизации: %s", authHeader)
-				oc.Error(ctx, gqlerror.Errorf("Неверный формат заголовка авторизации"))
-				return next(ctx)
-			}
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			userID, err := validateJWT(token)
-			if err != nil {
-				log.Printf("Недействительный токен: %v", err)
-				oc.Error(ctx, gqlerror.Errorf("Недействительный токен: %v", err))
-				return next(ctx)
-			}
-			log.Printf("Успешная аутентификация пользователя: %s", userID)
-			ctx = context.WithValue(ctx, "userID", userID)
+		logger.Printf("Обработка операции: %s", oc.OperationName)
+		id, err := auth.FromHeader(authenticator, oc.Headers.Get("Authorization"))
+		if err != nil {
+			// Ответ об ошибке вместо выполнения: операция с чужим токеном не выполняется даже анонимно
+			return graphql.OneShot(batchError(gqlerrors.CodeUnauthenticated, "%v", err))
+		}
+		if id.UserID != "" {
+			logger.Printf("Успешная аутентификация пользователя: %s", id.UserID)
 		} else {
-			log.Println("Заголовок авторизации отсутствует")
+			logger.Println("Заголовок авторизации отсутствует")
 		}
-		// Передача commentLoader в контекст
-		ctx = context.WithValue(ctx, "commentLoader", commentLoader)
-		return next(ctx)
+		ctx = auth.NewContext(ctx, id)
+		userID, _ := ctx.Value("userID").(string)
+		tenant, _ := ctx.Value("tenant").(string)
+		ctx = flags.NewContext(ctx, flagService.ForTenant(userID, tenant))
+		// Передача DataLoader-ов в контекст
+		return next(loadersFor(ctx, shared).context(ctx))
 	})
 
-	return &Server{cfg: cfg, storage: storage, handler: srv}
+	// Политика anonymousPolicy сообщества проверяется на корневых полях, когда пользователь уже известен
+	srv.Use(mygraphql.AnonymousAccess{Storage: storage})
+
+	// Запись мутаций для проверки переноса данных на другое хранилище, см. Replay
+	var recorder *replay.Recorder
+	if cfg.Recording.Enabled {
+		var err error
+		if recorder, err = replay.NewRecorder(cfg.Recording.File); err != nil {
+			logger.Printf("Запись мутаций не включена: %v", err)
+		} else {
+			srv.Use(recorder)
+		}
+	}
+
+	// Состояние квот в ответах позволяет клиентам замедлиться до отказа QUOTA_EXCEEDED
+	if cfg.Quotas.Enabled {
+		srv.Use(quota.Feedback{})
+	}
+
+	// Бюджет стоимости операций подключается после аутентификации, чтобы считать его по пользователю
+	if cfg.CostBudget.Enabled {
+		srv.Use(costbudget.New(costbudget.Options{
+			Limit: costbudget.Limit{Capacity: cfg.CostBudget.Capacity, Period: cfg.CostBudget.Period},
+			Clock: clk,
+		}))
+	}
+
+	// Объекты страниц постов и комментариев берутся из пулов и возвращаются после ответа операции
+	srv.Use(mygraphql.Arena{})
+
+	// Анонимное чтение разрешённых запросов на отдельном эндпоинте
+	var public *handler.Server
+	if cfg.PublicAPI.Enabled {
+		public = newPublicHandler(cfg, executableSchema, storage, shared, flagService, reporter, clk)
+	}
+
+	return &Server{
+		cfg:         cfg,
+		storage:     storage,
+		handler:     srv,
+		public:      public,
+		jwtSecret:   jwtSecret,
+		auth:        authenticator,
+		loaders:     o.loaders,
+		middleware:  o.middleware,
+		logger:      logger,
+		maintenance: mode,
+		faults:      httpFaults,
+		websockets:  websockets,
+		exporter:    export.New(storage, resolver.Renderer, export.Options{Clock: clk}),
+		recorder:    recorder,
+		reporter:    reporter,
+		search:      searchStore,
+		excerpts:    resolver.Excerpts,
+		cluster:     node,
+		http:        &http.Server{Addr: ":" + cfg.Server.Port, ErrorLog: logger},
+
+		receiveEvent: resolver.SubscriptionHandler.ReceiveForwarded,
+	}
 }
 
-// Run запускает сервер
-func (s *Server) Run() error {
-	http.Handle("/", playground.Handler("GraphQL Playground", "/query"))
-	http.Handle("/query", s.handler)
-	http.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
-		log.Println("Запрос на генерацию токена")
-		token, err := generateToken("user1")
-		if err != nil {
-			log.Printf("Ошибка генерации токена: %v", err)
-			http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
-			return
+// newHandler собирает GraphQL-сервер с транспортами transports; по умолчанию это транспорты
+// handler.NewDefaultServer, и MultipartMixed стоит перед POST: транспорты проверяются по порядку, а POST
+// принимает и запросы с Accept: multipart/mixed, которым нужна инкрементальная доставка фрагментов с @defer.
+// WebSocket-транспорт с аутентификацией добавляется в New
+func newHandler(es graphql.ExecutableSchema, transports []graphql.Transport) *handler.Server {
+	srv := handler.New(es)
+	for _, t := range transports {
+		srv.AddTransport(t)
+	}
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](100)})
+	return srv
+}
+
+// newReporter выбирает репортер ошибок: Sentry, если задан DSN, иначе лог
+func newReporter(cfg *config.Config) reporting.Reporter {
+	if cfg.ErrorReporting.SentryDSN == "" {
+		return reporting.NewLogReporter()
+	}
+	environment := cfg.ErrorReporting.Environment
+	if environment == "" {
+		environment = cfg.Environment
+	}
+	reporter, err := reporting.NewSentry(cfg.ErrorReporting.SentryDSN, environment)
+	if err != nil {
+		log.Printf("Sentry недоступен, ошибки будут писаться в лог: %v", err)
+		return reporting.NewLogReporter()
+	}
+	return reporter
+}
+
+// newFlagService создаёт сервис флагов из конфигурации с удалённым источником, если он задан
+func newFlagService(cfg *config.Config) *flags.Service {
+	opts := flags.Options{Static: cfg.Flags.Definitions, Interval: cfg.Flags.RefreshInterval}
+	if cfg.Flags.RemoteURL != "" {
+		opts.Remote = flags.HTTPProvider{URL: cfg.Flags.RemoteURL}
+	}
+	return flags.New(opts)
+}
+
+// newIDGenerator создаёт генератор идентификаторов выбранного в конфигурации формата; при ошибке
+// используются случайные UUID
+func newIDGenerator(cfg *config.Config, clk clock.Clock) ids.Generator {
+	if cfg.IDs.Format == "" {
+		return ids.Default()
+	}
+	generator, err := ids.New(cfg.IDs.Format, ids.Options{Node: cfg.IDs.Node, Now: clk.Now})
+	if err != nil {
+		log.Printf("Не удалось создать генератор идентификаторов, используются случайные UUID: %v", err)
+		return ids.Default()
+	}
+	log.Printf("Формат идентификаторов: %s", cfg.IDs.Format)
+	return generator
+}
+
+// newSpamService создаёт проверку комментариев на спам выбранным в конфигурации сервисом
+func newSpamService(cfg *config.Config, store storage.Storage) *spam.Service {
+	var checker spam.Checker
+	switch cfg.Spam.Provider {
+	case config.SpamAkismet:
+		checker = spam.NewAkismet(spam.AkismetOptions{
+			APIKey:   cfg.Spam.Akismet.APIKey,
+			Site:     cfg.Spam.Akismet.Site,
+			Endpoint: cfg.Spam.Akismet.Endpoint,
+			Timeout:  cfg.Spam.Timeout,
+		})
+	default:
+		bayes := spam.NewBayes(cfg.Spam.Bayes.Threshold)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := bayes.Load(ctx, store, cfg.Spam.Bayes.TrainingLimit); err != nil {
+			log.Printf("Не удалось обучить байесовский классификатор, он начнёт с пустой модели: %v", err)
 		}
-		log.Printf("Токен успешно сгенерирован: %s", token)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"token": token})
+		checker = bayes
+	}
+	return spam.New(checker, store, spam.Options{
+		Async:   cfg.Spam.Async,
+		Timeout: cfg.Spam.Timeout,
+		Workers: cfg.Spam.Workers,
 	})
+}
 
-	log.Printf("Сервер запущен на порту :%s", s.cfg.Server.Port)
-	return http.ListenAndServe(":"+s.cfg.Server.Port, nil)
+// newPushService создаёт провайдеры для настроенных платформ; провайдер, который не удалось создать,
+// пропускается. Без настроенных платформ уведомления пишутся в лог
+func newPushService(cfg *config.Config, store storage.Storage) *push.Service {
+	providers := make(map[string]push.Provider)
+	if cfg.Push.FCM.CredentialsFile != "" {
+		fcm, err := push.NewFCM(push.FCMOptions{CredentialsFile: cfg.Push.FCM.CredentialsFile, Timeout: cfg.Push.Timeout})
+		if err != nil {
+			log.Printf("Push-уведомления FCM отключены: %v", err)
+		} else {
+			providers[models.PlatformFCM] = fcm
+		}
+	}
+	if cfg.Push.APNs.KeyFile != "" {
+		apns, err := push.NewAPNs(push.APNsOptions{
+			KeyFile:    cfg.Push.APNs.KeyFile,
+			KeyID:      cfg.Push.APNs.KeyID,
+			TeamID:     cfg.Push.APNs.TeamID,
+			Topic:      cfg.Push.APNs.Topic,
+			Production: cfg.Push.APNs.Production,
+			Timeout:    cfg.Push.Timeout,
+		})
+		if err != nil {
+			log.Printf("Push-уведомления APNs отключены: %v", err)
+		} else {
+			providers[models.PlatformAPNs] = apns
+		}
+	}
+	if cfg.Push.WebPush.VAPIDPrivateKey != "" {
+		webPush, err := push.NewWebPush(push.WebPushOptions{
+			VAPIDPrivateKey: cfg.Push.WebPush.VAPIDPrivateKey,
+			Subject:         cfg.Push.WebPush.Subject,
+			Timeout:         cfg.Push.Timeout,
+		})
+		if err != nil {
+			log.Printf("Push-уведомления Web Push отключены: %v", err)
+		} else {
+			providers[models.PlatformWebPush] = webPush
+			log.Printf("Открытый ключ VAPID для браузеров: %s", webPush.PublicKey())
+		}
+	}
+	if len(providers) == 0 {
+		logProvider := push.NewLogProvider()
+		for _, platform := range []string{models.PlatformFCM, models.PlatformAPNs, models.PlatformWebPush} {
+			providers[platform] = logProvider
+		}
+	}
+	return push.New(store, providers, push.Options{
+		Timeout:   cfg.Push.Timeout,
+		Workers:   cfg.Push.Workers,
+		QueueSize: cfg.Push.QueueSize,
+	})
 }
 
-func validateJWT(token string) (string, error) {
-	log.Printf("Валидация токена: %s", token)
-	if token == "" {
-		log.Println("Ошибка: пустой токен")
-		return "", errors.New("пустой токен")
+// newEmailSender выбирает отправку писем: через SMTP, если задан сервер, иначе в лог
+func newEmailSender(cfg *config.Config) email.Sender {
+	if cfg.Email.SMTPAddr == "" {
+		return email.NewLogSender()
 	}
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			log.Printf("Ошибка: неожиданный метод подписи: %v", token.Header["alg"])
-			return nil, fmt.Errorf("неожиданный метод подписи: %v", token.Header["alg"])
+	return email.NewSMTP(email.SMTPOptions{
+		Addr:     cfg.Email.SMTPAddr,
+		From:     cfg.Email.From,
+		Username: cfg.Email.Username,
+		Password: cfg.Email.Password,
+	})
+}
+
+// withClientInfo передаёт в контекст запроса IP и User-Agent клиента для проверки комментариев на спам
+func withClientInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		ctx := context.WithValue(r.Context(), "clientIP", ip)
+		ctx = context.WithValue(ctx, "userAgent", r.UserAgent())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// tenantIDPattern - допустимый ID сообщества в заголовке запроса
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// withTenant передаёт в контекст запроса ID сообщества из заголовка header. Ответ зависит от сообщества,
+// поэтому заголовок добавляется в Vary
+func withTenant(header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", header)
+		tenantID := r.Header.Get(header)
+		if tenantID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !tenantIDPattern.MatchString(tenantID) {
+			log.Printf("Недопустимый ID сообщества в заголовке %s: %q", header, tenantID)
+			writeError(w, r, http.StatusBadRequest, gqlerrors.CodeBadUserInput, "invalid tenant id")
+			return
 		}
-		return []byte("your-secret-key"), nil
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "tenant", tenantID)))
 	})
+}
+
+// Handler возвращает HTTP-обработчик со всеми маршрутами сервера
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", playground.Handler("GraphQL Playground", "/query"))
+	var query http.Handler = withClientInfo(costbudget.Headers(withBatching(s.cfg.Server.MaxBatchSize, s.newLoaders, withETag(s.cfg.Server.CacheMaxAge, s.websockets.track(s.handler)))))
+	if anonymous := s.cfg.Anonymous; anonymous.Enabled {
+		query = withAnonymousID([]byte(anonymous.Secret), anonymous.CookieName, anonymous.CookieMaxAge, query)
+	}
+	query = s.gcTuner.Middleware(withTenant(s.cfg.Tenants.Header, query))
+	if s.cluster != nil {
+		// Запросы с X-Post-Id в режиме sticky обслуживает узел-владелец поста
+		query = s.cluster.Route(query)
+	}
+	mux.Handle("/query", query)
+	if s.public != nil {
+		public := withClientInfo(costbudget.Headers(withETag(s.cfg.PublicAPI.CacheMaxAge, s.public)))
+		mux.Handle("/public/query", s.gcTuner.Middleware(withTenant(s.cfg.Tenants.Header, public)))
+	}
+	// Эндпоинты вне GraphQL отвечают на ошибки и паники в формате errorResponse
+	endpoint := func(h http.HandlerFunc) http.Handler {
+		return withRecovery(s.reporter, h)
+	}
+	mux.Handle("/healthz", endpoint(s.handleHealth))
+	mux.Handle("/readyz", endpoint(s.handleReady))
+	mux.Handle("/metrics", metrics.Handler())
+	if s.cfg.Profiling.Enabled {
+		mux.Handle("/debug/pprof/", profilingHandler(s.cfg.Profiling.Token))
+	}
+	mux.Handle("/token", endpoint(s.handleToken))
+	if s.cluster != nil {
+		mux.Handle("POST "+cluster.EventsPath, endpoint(s.handleClusterEvent))
+	}
+	mux.Handle("GET /export/posts/{id}", auth.Middleware(s.auth, rejectToken)(endpoint(s.handleExport)))
+	if s.cfg.Embed.Enabled {
+		mux.Handle("GET /embed/{id}", endpoint(s.handleEmbed))
+		mux.Handle("GET /oembed", endpoint(s.handleOEmbed))
+	}
+	var handler http.Handler = mux
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		handler = s.middleware[i](handler)
+	}
+	if s.faults != nil {
+		handler = s.faults.Middleware(handler)
+	}
+	if s.cfg.Server.Compression {
+		handler = withCompression(s.cfg.Server.CompressionMinSize, handler)
+	}
+	return withRequestID(handler)
+}
+
+// newLoaders создаёт DataLoader-ы пакетного запроса
+func (s *Server) newLoaders() *Loaders {
+	return s.loaders(s.storage)
+}
+
+// SetJWTSecret заменяет ключ подписи JWT; токены, подписанные прежним ключом, перестают приниматься.
+// Пустой ключ игнорируется
+func (s *Server) SetJWTSecret(secret string) {
+	if secret == "" {
+		s.logger.Println("Новый ключ подписи JWT пуст, используется прежний")
+		return
+	}
+	s.jwtSecret.Set([]byte(secret))
+	s.logger.Println("Ключ подписи JWT обновлён")
+}
+
+// SetGCTuner подключает учёт пауз GC в запросах /query; вызывается до Serve
+func (s *Server) SetGCTuner(tuner *gctuning.Tuner) {
+	s.gcTuner = tuner
+}
+
+// ToggleMaintenance переключает режим обслуживания и возвращает новое состояние
+func (s *Server) ToggleMaintenance() bool {
+	return s.maintenance.Toggle()
+}
+
+// Run открывает порт и обслуживает запросы до остановки; после Shutdown возвращает nil
+func (s *Server) Run() error {
+	ln, err := s.Listen()
 	if err != nil {
-		log.Printf("Ошибка парсинга токена: %v", err)
-		return "", err
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve обслуживает запросы из ln до остановки; после Shutdown возвращает nil
+func (s *Server) Serve(ln net.Listener) error {
+	s.logger.Printf("Сервер запущен на %s", ln.Addr())
+	s.http.Handler = s.Handler()
+	if err := s.http.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown останавливает сервер. Сначала в течение server.drainPeriod /readyz отвечает 503, запросы
+// обслуживаются, а WebSocket-соединения закрываются с кодом 1001 равномерно по всему периоду. Затем
+// закрываются оставшиеся соединения, и сервер дожидается завершения HTTP-запросов, пока не истечёт ctx.
+// Клиенты подписок переподключаются к другому экземпляру с задержкой из расширения reconnect
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+	return s.stop(ctx)
+}
+
+// HandOver останавливает сервер, слушающий сокет которого передан новому процессу: так же, как Shutdown,
+// но /readyz продолжает отвечать ok, ведь порт не перестаёт обслуживаться
+func (s *Server) HandOver(ctx context.Context) error {
+	return s.stop(ctx)
+}
+
+func (s *Server) stop(ctx context.Context) error {
+	if period := s.cfg.Server.DrainPeriod; period > 0 {
+		s.logger.Printf("Остановка сервера: вывод из ротации в течение %v", period)
+		closed := s.websockets.drain(ctx, period, closeGoingAway, "server is shutting down")
+		s.logger.Printf("Вывод из ротации завершён: закрыто WebSocket-соединений: %d", closed)
 	}
-	if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok && parsedToken.Valid {
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			log.Println("Ошибка: user_id не найден в токене")
-			return "", errors.New("user_id не найден в токене")
+	closed := s.websockets.closeAll(closeGoingAway, "server is shutting down")
+	s.logger.Printf("Остановка сервера: закрыто WebSocket-соединений: %d", closed)
+	err := s.http.Shutdown(ctx)
+	if s.search != nil {
+		s.search.Stop()
+	}
+	s.excerpts.Close()
+	if s.cluster != nil {
+		s.cluster.Stop()
+	}
+	if s.recorder != nil {
+		if closeErr := s.recorder.Close(); closeErr != nil {
+			s.logger.Printf("Ошибка при закрытии записи мутаций: %v", closeErr)
 		}
-		log.Printf("Токен валиден, userID: %s", userID)
-		return userID, nil
 	}
-	log.Println("Ошибка: недействительный токен")
-	return "", errors.New("недействительный токен")
+	s.flushReporter(ctx)
+	return err
 }
 
-func generateToken(userID string) (string, error) {
-	log.Printf("Генерация токена для userID: %s", userID)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
-	tokenString, err := token.SignedString([]byte("your-secret-key"))
+// reporterFlushTimeout ограничивает отправку накопленных событий об ошибках, если у контекста остановки нет дедлайна
+const reporterFlushTimeout = 2 * time.Second
+
+// flushReporter дожидается отправки событий об ошибках, накопленных репортером, в пределах дедлайна остановки
+func (s *Server) flushReporter(ctx context.Context) {
+	timeout := reporterFlushTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if !s.reporter.Flush(timeout) {
+		s.logger.Printf("Не все события об ошибках отправлены за %v", timeout)
+	}
+}
+
+// handleToken выдаёт тестовый JWT для user1
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.logger.Println("Запрос на генерацию токена")
+	token, err := auth.Issue(s.jwtSecret.Get(), "user1")
 	if err != nil {
-		log.Printf("Ошибка при подписи токена: %v", err)
-		return "", err
+		s.logger.Printf("Ошибка генерации токена: %v", err)
+		writeError(w, r, http.StatusInternalServerError, gqlerrors.CodeInternal, "failed to generate token")
+		return
+	}
+	s.logger.Printf("Токен успешно сгенерирован: %s", token)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// handleClusterEvent принимает событие подписок соседнего узла и публикует его подписчикам этого узла
+func (s *Server) handleClusterEvent(w http.ResponseWriter, r *http.Request) {
+	event, err := s.cluster.Receive(r)
+	if errors.Is(err, cluster.ErrUnauthorized) {
+		writeError(w, r, http.StatusUnauthorized, gqlerrors.CodeUnauthenticated, "cluster secret required")
+		return
+	}
+	if err == nil {
+		err = s.receiveEvent(event)
 	}
-	log.Printf("Токен успешно создан: %s", tokenString)
-	return tokenString, nil
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, gqlerrors.CodeBadUserInput, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHealth отвечает на проверку живости процесса
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReady проверяет готовность принимать запросы: сервер не останавливается и хранилище отвечает
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	w.Header().Set("Content-Type", "application/json")
+	if s.draining.Load() {
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, errorResponse{
+			Status: "draining",
+			Error:  errorBody{Code: codeUnavailable, Message: "server is shutting down"},
+		})
+		return
+	}
+	if err := s.storage.Ping(ctx); err != nil {
+		s.logger.Printf("Хранилище не готово: %v", err)
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, errorResponse{
+			Status: "unavailable",
+			Error:  errorBody{Code: codeUnavailable, Message: fmt.Sprintf("storage is unavailable: %v", err)},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// NewSearchEngine создаёт поисковый движок из раздела search конфигурации; nil, если движок не задан
+func NewSearchEngine(cfg *config.Config) (search.Engine, error) {
+	return search.NewEngine(cfg.Search.Engine, search.ElasticOptions{
+		URL:      cfg.Search.URL,
+		Index:    cfg.Search.Index,
+		Username: cfg.Search.Username,
+		Password: cfg.Search.Password,
+		Timeout:  cfg.Search.Timeout,
+	})
 }
@@ -1,29 +1,200 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/auth"
 	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
 	"github.com/ButyrinIA/system/internal/models"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/ButyrinIA/system/internal/reporting"
+	"github.com/ButyrinIA/system/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockStorage struct {
 	mock.Mock
 }
 
-func (m *mockStorage) ListPosts(ctx context.Context, limit int, cursor *string) (*models.PaginatedPosts, error) {
-	args := m.Called(ctx, limit, cursor)
+func (m *mockStorage) ListPosts(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (*models.PaginatedPosts, error) {
+	args := m.Called(ctx, limit, cursor, filter)
 	return args.Get(0).(*models.PaginatedPosts), args.Error(1)
 }
 
+func (m *mockStorage) GetPostBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	args := m.Called(ctx, slug)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Post), args.Error(1)
+}
+
+func (m *mockStorage) ShortIDs(ctx context.Context, kind string, ids []string) (map[string]int64, error) {
+	args := m.Called(ctx, kind, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+
+func (m *mockStorage) ResolveShortID(ctx context.Context, seq int64) (string, string, error) {
+	args := m.Called(ctx, seq)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *mockStorage) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	args := m.Called(ctx, collection)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Collection), args.Error(1)
+}
+
+func (m *mockStorage) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	args := m.Called(ctx, collection)
+	return args.Error(0)
+}
+
+func (m *mockStorage) DeleteCollection(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListPostCollections(ctx context.Context, postID string) ([]models.Collection, error) {
+	args := m.Called(ctx, postID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Collection), args.Error(1)
+}
+
+func (m *mockStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) SetToxicityScore(ctx context.Context, score models.ToxicityScore, hold *models.HeldItem) error {
+	args := m.Called(ctx, score, hold)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetToxicityScores(ctx context.Context, commentIDs []string) (map[string]models.ToxicityScore, error) {
+	args := m.Called(ctx, commentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]models.ToxicityScore), args.Error(1)
+}
+
+func (m *mockStorage) SetPostRequireApproval(ctx context.Context, postID string, required bool) error {
+	args := m.Called(ctx, postID, required)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetPostExcerpt(ctx context.Context, postID, excerpt string) error {
+	args := m.Called(ctx, postID, excerpt)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetApprovalStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	args := m.Called(ctx, commentID, status, hidden)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListPendingComments(ctx context.Context, postID string, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, postID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) CreateComments(ctx context.Context, comments []*models.Comment) []error {
+	args := m.Called(ctx, comments)
+	return args.Get(0).([]error)
+}
+
+func (m *mockStorage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	args := m.Called(ctx, postID, title, slugBase)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Post), args.Error(1)
+}
+
+func (m *mockStorage) RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	args := m.Called(ctx, postID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Post), args.Error(1)
+}
+
+func (m *mockStorage) SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]storage.SimilarPost, error) {
+	args := m.Called(ctx, title, viewerID, threshold, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]storage.SimilarPost), args.Error(1)
+}
+
+func (m *mockStorage) SavePostReferences(ctx context.Context, postID string, targetIDs []string) error {
+	args := m.Called(ctx, postID, targetIDs)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetPostCategory(ctx context.Context, postID string, categoryID *string) error {
+	args := m.Called(ctx, postID, categoryID)
+	return args.Error(0)
+}
+
+func (m *mockStorage) CreateCategory(ctx context.Context, category *models.Category) error {
+	args := m.Called(ctx, category)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetCategory(ctx context.Context, id string) (*models.Category, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Category), args.Error(1)
+}
+
+func (m *mockStorage) ListCategories(ctx context.Context) ([]models.Category, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
 func (m *mockStorage) GetPost(ctx context.Context, id string) (*models.Post, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*models.Post), args.Error(1)
@@ -39,82 +210,356 @@ func (m *mockStorage) CreateComment(ctx context.Context, comment *models.Comment
 	return args.Error(0)
 }
 
-func (m *mockStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string) (*models.PaginatedComments, error) {
-	args := m.Called(ctx, postID, parentID, limit, cursor)
+func (m *mockStorage) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string, order models.SortOrder) (*models.PaginatedComments, error) {
+	args := m.Called(ctx, postID, parentID, limit, cursor, order)
 	return args.Get(0).(*models.PaginatedComments), args.Error(1)
 }
 
-func (m *mockStorage) Close() error {
-	args := m.Called()
+func (m *mockStorage) CountCommentsBefore(ctx context.Context, commentID string, order models.SortOrder) (int, error) {
+	args := m.Called(ctx, commentID, order)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockStorage) SaveLinkPreview(ctx context.Context, preview *models.LinkPreview) error {
+	args := m.Called(ctx, preview)
 	return args.Error(0)
 }
 
-func TestNewServer(t *testing.T) {
-	cfg := &config.Config{
-		Server: struct {
-			Port string `yaml:"port"`
-		}{Port: "8080"},
+func (m *mockStorage) GetLinkPreviews(ctx context.Context, postID string) ([]models.LinkPreview, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]models.LinkPreview), args.Error(1)
+}
+
+func (m *mockStorage) ToggleReaction(ctx context.Context, reaction *models.Reaction) (bool, error) {
+	args := m.Called(ctx, reaction)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) GetReactionCounts(ctx context.Context, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	args := m.Called(ctx, targetIDs)
+	return args.Get(0).(map[string][]models.ReactionCount), args.Error(1)
+}
+
+func (m *mockStorage) VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, error) {
+	args := m.Called(ctx, vote)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
-	storage := &mockStorage{}
-	server := New(cfg, storage)
+	return args.Get(0).(*models.Comment), args.Error(1)
+}
 
-	assert.NotNil(t, server)
-	assert.Equal(t, cfg, server.cfg)
-	assert.NotNil(t, server.handler)
+func (m *mockStorage) MarkThreadRead(ctx context.Context, userID, postID string, readAt time.Time) error {
+	args := m.Called(ctx, userID, postID, readAt)
+	return args.Error(0)
 }
 
-func TestGenerateToken(t *testing.T) {
-	token, err := generateToken("user1")
-	assert.NoError(t, err)
-	assert.NotEmpty(t, token)
+func (m *mockStorage) GetUnreadCommentCounts(ctx context.Context, userID string, postIDs []string) (map[string]int, error) {
+	args := m.Called(ctx, userID, postIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
 
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-secret-key"), nil
-	})
-	assert.NoError(t, err)
-	assert.True(t, parsedToken.Valid)
+func (m *mockStorage) IncrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) (int, error) {
+	args := m.Called(ctx, userID, action, windowStart)
+	return args.Int(0), args.Error(1)
+}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	assert.True(t, ok)
-	assert.Equal(t, "user1", claims["user_id"])
+func (m *mockStorage) DecrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) error {
+	args := m.Called(ctx, userID, action, windowStart)
+	return args.Error(0)
 }
 
-func TestValidateJWT(t *testing.T) {
-	token, err := generateToken("user1")
-	assert.NoError(t, err)
+func (m *mockStorage) GetPreferences(ctx context.Context, userID string) (*models.Preferences, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Preferences), args.Error(1)
+}
 
-	userID, err := validateJWT(token)
-	assert.NoError(t, err)
-	assert.Equal(t, "user1", userID)
+func (m *mockStorage) SavePreferences(ctx context.Context, prefs *models.Preferences) error {
+	args := m.Called(ctx, prefs)
+	return args.Error(0)
 }
 
-func TestValidateJWT_Invalid(t *testing.T) {
-	_, err := validateJWT("invalid-token")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "пустой токен")
+func (m *mockStorage) GetTenantSettings(ctx context.Context, tenantID string) (*models.TenantSettings, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TenantSettings), args.Error(1)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": "user1",
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
-	wrongKeyToken, _ := token.SignedString([]byte("wrong-key"))
-	_, err = validateJWT(wrongKeyToken)
-	assert.Error(t, err)
+func (m *mockStorage) SaveTenantSettings(ctx context.Context, settings *models.TenantSettings) error {
+	args := m.Called(ctx, settings)
+	return args.Error(0)
 }
 
-func TestTokenHandler(t *testing.T) {
-	cfg := &config.Config{
-		Server: struct {
-			Port string `yaml:"port"`
-		}{Port: "8080"},
+func (m *mockStorage) AddTenantUsage(ctx context.Context, tenantID, kind string, bytes int64) error {
+	args := m.Called(ctx, tenantID, kind, bytes)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetTenantUsage(ctx context.Context, tenantID string) (*models.TenantUsage, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TenantUsage), args.Error(1)
+}
+
+func (m *mockStorage) SetFollow(ctx context.Context, followerID, userID string, follow bool) error {
+	args := m.Called(ctx, followerID, userID, follow)
+	return args.Error(0)
+}
+
+func (m *mockStorage) IsFollowing(ctx context.Context, followerID, userID string) (bool, error) {
+	args := m.Called(ctx, followerID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) CountDescendants(ctx context.Context, commentIDs []string) (map[string]int, error) {
+	args := m.Called(ctx, commentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *mockStorage) Stats(ctx context.Context, slowQueries int) (*models.StorageStats, error) {
+	args := m.Called(ctx, slowQueries)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StorageStats), args.Error(1)
+}
+
+func (m *mockStorage) RegisterDeviceToken(ctx context.Context, token *models.DeviceToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UnregisterDeviceToken(ctx context.Context, userID, token string) error {
+	args := m.Called(ctx, userID, token)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListDeviceTokens(ctx context.Context, userID string) ([]models.DeviceToken, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DeviceToken), args.Error(1)
+}
+
+func (m *mockStorage) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	args := m.Called(ctx, search)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.SavedSearch), args.Error(1)
+}
+
+func (m *mockStorage) DeleteSavedSearch(ctx context.Context, userID, id string) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListAlertSavedSearches(ctx context.Context) ([]models.SavedSearch, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
 	}
+	return args.Get(0).([]models.SavedSearch), args.Error(1)
+}
+
+func (m *mockStorage) MarkSavedSearchChecked(ctx context.Context, id string, checkedAt time.Time) error {
+	args := m.Called(ctx, id, checkedAt)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SubscribeToPost(ctx context.Context, userID, postID string, at time.Time) error {
+	args := m.Called(ctx, userID, postID, at)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UnsubscribeFromPost(ctx context.Context, userID, postID string) error {
+	args := m.Called(ctx, userID, postID)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListDigestSubscribers(ctx context.Context) ([]models.DigestSubscriber, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DigestSubscriber), args.Error(1)
+}
+
+func (m *mockStorage) GetDigestComments(ctx context.Context, userID string, since, until time.Time, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, userID, since, until, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) MarkDigestSent(ctx context.Context, userID string, until time.Time) error {
+	args := m.Called(ctx, userID, until)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetShadowBan(ctx context.Context, userID string, banned bool) error {
+	args := m.Called(ctx, userID, banned)
+	return args.Error(0)
+}
+
+func (m *mockStorage) IsShadowBanned(ctx context.Context, userID string) (bool, error) {
+	args := m.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) ListModerationRules(ctx context.Context) ([]models.ModerationRule, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ModerationRule), args.Error(1)
+}
+
+func (m *mockStorage) CreateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UpdateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	args := m.Called(ctx, rule)
+	return args.Error(0)
+}
+
+func (m *mockStorage) DeleteModerationRule(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListAllowedOperations(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockStorage) AddAllowedOperation(ctx context.Context, query string) error {
+	args := m.Called(ctx, query)
+	return args.Error(0)
+}
+
+func (m *mockStorage) HoldContent(ctx context.Context, item *models.HeldItem) error {
+	args := m.Called(ctx, item)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListHeldContent(ctx context.Context, limit int) ([]models.HeldItem, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.HeldItem), args.Error(1)
+}
+
+func (m *mockStorage) ResolveHeldContent(ctx context.Context, id string, approve bool) error {
+	args := m.Called(ctx, id, approve)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SetSpamStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	args := m.Called(ctx, commentID, status, hidden)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListCommentsBySpamStatus(ctx context.Context, status string, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, status, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *mockStorage) IteratePosts(ctx context.Context, fn func(post *models.Post) error) error {
+	args := m.Called(ctx, fn)
+	return args.Error(0)
+}
+
+func (m *mockStorage) IterateComments(ctx context.Context, postID string, fn func(comment *models.Comment) error) error {
+	args := m.Called(ctx, postID, fn)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ListContents(ctx context.Context, kind, afterID string, limit int) ([]storage.ContentRecord, error) {
+	args := m.Called(ctx, kind, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]storage.ContentRecord), args.Error(1)
+}
+
+func (m *mockStorage) ReplaceContent(ctx context.Context, kind, id, previous, content string) (bool, error) {
+	args := m.Called(ctx, kind, id, previous, content)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	args := m.Called(ctx, kind, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockStorage) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *mockStorage) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func TestNewServer(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+	storage := &mockStorage{}
+	server := New(cfg, storage)
+
+	assert.NotNil(t, server)
+	assert.Equal(t, cfg, server.cfg)
+	assert.NotNil(t, server.handler)
+}
+
+func TestTokenHandler(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
 	storage := &mockStorage{}
 	New(cfg, storage)
 
 	req, _ := http.NewRequest("GET", "/token", nil)
 	rr := httptest.NewRecorder()
 	http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := generateToken("user1")
+		token, err := auth.Issue([]byte(config.DevJWTSecret), "user1")
 		if err != nil {
 			http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
 			return
@@ -129,3 +574,466 @@ func TestTokenHandler(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, response["token"])
 }
+
+func TestSetJWTSecret(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+	cfg.Auth.JWTSecret = "old-secret"
+	srv := New(cfg, &mockStorage{})
+
+	token, err := auth.Issue(srv.jwtSecret.Get(), "user1")
+	assert.NoError(t, err)
+	_, _, err = auth.Validate([]byte("old-secret"), token)
+	assert.NoError(t, err)
+
+	srv.SetJWTSecret("")
+	assert.Equal(t, []byte("old-secret"), srv.jwtSecret.Get(), "Пустой ключ не применяется")
+
+	srv.SetJWTSecret("new-secret")
+	_, _, err = auth.Validate(srv.jwtSecret.Get(), token)
+	assert.Error(t, err, "Токен, подписанный прежним ключом, больше не принимается")
+}
+
+func TestReadyHandler(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+
+	storage := &mockStorage{}
+	storage.On("Ping", mock.Anything).Return(nil).Once()
+	storage.On("Ping", mock.Anything).Return(errors.New("connection refused")).Once()
+	server := New(cfg, storage)
+
+	rr := httptest.NewRecorder()
+	server.handleReady(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	server.handleReady(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var response errorResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, "unavailable", response.Status)
+	assert.Equal(t, codeUnavailable, response.Error.Code)
+	assert.Contains(t, response.Error.Message, "connection refused")
+	storage.AssertExpectations(t)
+}
+
+// panicReporter запоминает события о паниках
+type panicReporter struct {
+	events  []*reporting.Event
+	flushed bool
+}
+
+func (r *panicReporter) Report(ctx context.Context, event *reporting.Event) {
+	r.events = append(r.events, event)
+}
+
+func (r *panicReporter) Flush(timeout time.Duration) bool {
+	r.flushed = true
+	return true
+}
+
+func TestErrorResponses(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+	cfg.Tenants.Header = "X-Tenant-ID"
+	store := &mockStorage{}
+	store.On("GetPost", mock.Anything, "missing").Return((*models.Post)(nil), storage.ErrPostNotFound)
+	handler := New(cfg, store).Handler()
+	decode := func(rr *httptest.ResponseRecorder) errorResponse {
+		t.Helper()
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		var response errorResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		return response
+	}
+
+	req := httptest.NewRequest("GET", "/export/posts/missing", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "req-42", rr.Header().Get(RequestIDHeader), "ID запроса от прокси сохраняется")
+	assert.Equal(t, errorBody{Code: gqlerrors.CodeNotFound, Message: "post not found", RequestID: "req-42"}, decode(rr).Error)
+
+	req = httptest.NewRequest("GET", "/export/posts/missing", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	generated := rr.Header().Get(RequestIDHeader)
+	assert.NotEqual(t, "bad id\n", generated, "Недопустимый ID заменяется новым")
+	assert.Equal(t, generated, decode(rr).Error.RequestID)
+
+	req = httptest.NewRequest("POST", "/query", strings.NewReader(`{"query":"{ __typename }"}`))
+	req.Header.Set("X-Tenant-ID", "Bad Tenant")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, gqlerrors.CodeBadUserInput, decode(rr).Error.Code)
+
+	reporter := &panicReporter{}
+	panicking := withRequestID(withRecovery(reporter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	rr = httptest.NewRecorder()
+	panicking.ServeHTTP(rr, httptest.NewRequest("GET", "/token", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	response := decode(rr)
+	assert.Equal(t, gqlerrors.CodeInternal, response.Error.Code)
+	assert.NotEmpty(t, response.Error.RequestID)
+	require.Len(t, reporter.events, 1)
+	assert.Equal(t, "GET /token", reporter.events[0].Operation)
+}
+
+func TestShutdown_FlushesReporter(t *testing.T) {
+	s := New(config.Default(), &mockStorage{})
+	reporter := &panicReporter{}
+	s.reporter = reporter
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	assert.True(t, reporter.flushed, "При остановке накопленные события об ошибках должны отправляться")
+}
+
+func TestOptions(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+	var logs bytes.Buffer
+	var loaderCalls int
+	handler := New(cfg, &mockStorage{},
+		WithAuthenticator(auth.AuthenticatorFunc(func(token string) (string, string, error) {
+			if token != "service-token" {
+				return "", "", errors.New("unknown token")
+			}
+			return "service", "admin", nil
+		})),
+		WithLoaders(func(store storage.Storage) *Loaders {
+			loaderCalls++
+			return NewLoaders(store)
+		}),
+		WithTransports(transport.POST{}),
+		WithMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Middleware", requestIDFromContext(r.Context()))
+				next.ServeHTTP(w, r)
+			})
+		}),
+		WithLogger(log.New(&logs, "", 0)),
+	).Handler()
+	assert.Equal(t, 1, loaderCalls, "Общие DataLoader-ы создаются фабрикой")
+	assert.Contains(t, logs.String(), "Создание нового сервера")
+
+	query := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/query", strings.NewReader(`{"query":"{ __typename }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := query(http.MethodPost, "service-token")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "errors")
+	assert.Equal(t, rr.Header().Get(RequestIDHeader), rr.Header().Get("X-Middleware"), "Middleware получает запрос с ID")
+
+	rr = query(http.MethodPost, "jwt-token")
+	assert.Contains(t, rr.Body.String(), "unknown token", "Токен проверяется заданной аутентификацией")
+	assert.Contains(t, rr.Body.String(), gqlerrors.CodeUnauthenticated)
+
+	rr = query(http.MethodGet, "service-token")
+	assert.NotEqual(t, http.StatusOK, rr.Code, "GET не входит в заданные транспорты")
+}
+
+func TestListen_ReusePort(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Port = "0"
+	cfg.Server.ReusePort = true
+	first, err := New(cfg, &mockStorage{}).Listen()
+	require.NoError(t, err)
+	defer first.Close()
+
+	port := first.Addr().(*net.TCPAddr).Port
+	cfg.Server.Port = strconv.Itoa(port)
+	srv := New(cfg, &mockStorage{})
+	second, err := srv.Listen()
+	require.NoError(t, err, "С SO_REUSEPORT новый процесс открывает порт, пока прежний его слушает")
+	defer second.Close()
+
+	file, err := srv.ListenerFile()
+	require.NoError(t, err)
+	defer file.Close()
+	inherited, err := net.FileListener(file)
+	require.NoError(t, err)
+	defer inherited.Close()
+	assert.Equal(t, port, inherited.Addr().(*net.TCPAddr).Port)
+}
+
+func TestExportHandler(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+
+	store := &mockStorage{}
+	store.On("GetPost", mock.Anything, "missing").Return((*models.Post)(nil), storage.ErrPostNotFound)
+	store.On("GetPost", mock.Anything, "post1").Return(&models.Post{ID: "post1", Title: "Пост <архив>", Content: "Текст", AuthorID: "user1", CreatedAt: time.Now()}, nil)
+	store.On("IterateComments", mock.Anything, "post1", mock.Anything).Return(nil)
+	handler := New(cfg, store).Handler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export/posts/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export/posts/post1", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename="post-post1.html"`, rr.Header().Get("Content-Disposition"))
+	assert.Contains(t, rr.Body.String(), "<h1>Пост &lt;архив&gt;</h1>")
+
+	req := httptest.NewRequest("GET", "/export/posts/post1", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestEmbedHandlers(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+	cfg.Embed.Enabled = true
+	cfg.Embed.BaseURL = "https://comments.example.com/"
+	cfg.Embed.LoginURL = "https://example.com/login"
+	cfg.Embed.Width = 600
+	cfg.Embed.Height = 400
+
+	store := &mockStorage{}
+	store.On("GetPost", mock.Anything, "missing").Return((*models.Post)(nil), storage.ErrPostNotFound)
+	store.On("GetPost", mock.Anything, "held").Return(&models.Post{ID: "held", Title: "Черновик", AuthorID: "user1", Hidden: true}, nil)
+	store.On("GetPost", mock.Anything, "post1").Return(&models.Post{ID: "post1", Title: `Пост "один"`, Content: "Текст", AuthorID: "user1", CreatedAt: time.Now()}, nil)
+	store.On("IterateComments", mock.Anything, "post1", mock.Anything).Return(nil)
+	handler := New(cfg, store).Handler()
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	rr := get("/embed/post1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "frame-ancestors *", rr.Header().Get("Content-Security-Policy"))
+	assert.Contains(t, rr.Header().Get("Link"), "https://comments.example.com/oembed?format=json&url=https%3A%2F%2Fcomments.example.com%2Fembed%2Fpost1")
+	assert.Contains(t, rr.Body.String(), `href="https://example.com/login"`)
+	assert.Equal(t, http.StatusNotFound, get("/embed/missing").Code)
+
+	rr = get("/oembed?url=" + url.QueryEscape("https://comments.example.com/embed/post1") + "&maxwidth=320")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "rich", resp["type"])
+	assert.Equal(t, float64(320), resp["width"])
+	assert.Equal(t, float64(400), resp["height"])
+	assert.Equal(t, `<iframe src="https://comments.example.com/embed/post1" width="320" height="400" frameborder="0" loading="lazy" title="Пост &#34;один&#34;"></iframe>`, resp["html"])
+
+	assert.Equal(t, http.StatusNotFound, get("/oembed?url="+url.QueryEscape("https://comments.example.com/embed/held")).Code)
+	assert.Equal(t, http.StatusNotFound, get("/oembed?url="+url.QueryEscape("https://other.example.com/embed/post1")).Code)
+	assert.Equal(t, http.StatusNotImplemented, get("/oembed?format=xml&url="+url.QueryEscape("https://comments.example.com/embed/post1")).Code)
+}
+
+func TestWithCompression(t *testing.T) {
+	large := strings.Repeat(`{"data":"значение"}`, 100)
+	handler := withCompression(1024, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		io.WriteString(w, r.URL.Query().Get("size"))
+		if r.URL.Query().Get("size") == "large" {
+			io.WriteString(w, large)
+		}
+	}))
+	get := func(target, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/?size=large&type=application/json", "br, gzip")
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+	reader, err := gzip.NewReader(rr.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "large"+large, string(body))
+
+	rr = get("/?size=small&type=application/json", "gzip")
+	assert.Empty(t, rr.Header().Get("Content-Encoding"), "Короткие ответы не сжимаются")
+	assert.Equal(t, "small", rr.Body.String())
+
+	rr = get("/?size=large&type=image/png", "gzip")
+	assert.Empty(t, rr.Header().Get("Content-Encoding"), "Несжимаемые типы передаются как есть")
+
+	rr = get("/?size=large&type=application/json", "gzip;q=0, identity")
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+}
+
+func TestWithETag(t *testing.T) {
+	handler := withETag(time.Minute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, r.URL.Query().Get("body"))
+	}))
+	get := func(body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/query?"+url.Values{"body": {body}}.Encode(), nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	public := `{"data":{"posts":[]},"extensions":{"cacheControl":{"maxAge":30,"scope":"PUBLIC"}}}`
+	rr := get(public, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, public, rr.Body.String())
+	etag := rr.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+	assert.Equal(t, "public, max-age=30", rr.Header().Get("Cache-Control"))
+
+	rr = get(public, map[string]string{"If-None-Match": `"other", ` + strings.TrimPrefix(etag, "W/")})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	withBudget := `{"data":{"posts":[]},"extensions":{"cacheControl":{"maxAge":30,"scope":"PUBLIC"},"costBudget":{"cost":3,"remaining":97,"limit":100}}}`
+	assert.Equal(t, get(withBudget, nil).Header().Get("ETag"), get(strings.Replace(withBudget, "97", "94", 1), nil).Header().Get("ETag"),
+		"Остаток бюджета стоимости не меняет ETag")
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"Срок ограничен сверху", `{"data":{},"extensions":{"cacheControl":{"maxAge":3600,"scope":"PUBLIC"}}}`, "public, max-age=60"},
+		{"Приватные данные", `{"data":{},"extensions":{"cacheControl":{"maxAge":30,"scope":"PRIVATE"}}}`, "private, max-age=30"},
+		{"Нулевой срок", `{"data":{},"extensions":{"cacheControl":{"maxAge":0,"scope":"PUBLIC"}}}`, "no-cache"},
+		{"Без политики", `{"data":{}}`, "no-cache"},
+		{"Ошибки", `{"errors":[{"message":"boom"}],"data":null,"extensions":{"cacheControl":{"maxAge":30,"scope":"PUBLIC"}}}`, "no-cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, get(tt.body, nil).Header().Get("Cache-Control"))
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Empty(t, rr.Header().Get("ETag"), "POST-запросы не получают ETag")
+}
+
+func TestWithBatching(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[*Loaders]bool)
+	newLoaders := func() *Loaders { return NewLoaders(&mockStorage{}) }
+	handler := withBatching(3, newLoaders, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[loadersFor(r.Context(), nil)] = true
+		mu.Unlock()
+		var params struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&params)
+		if params.Query == "broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":{"query":"`+params.Query+`"}}`)
+	}))
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`[{"query":"a"},{"query":"broken"},{"query":"c"}]`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var responses []map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &responses))
+	require.Len(t, responses, 3)
+	assert.Equal(t, map[string]any{"query": "a"}, responses[0]["data"])
+	assert.Contains(t, responses[1], "errors", "Ошибка одной операции не влияет на остальные")
+	assert.Equal(t, map[string]any{"query": "c"}, responses[2]["data"])
+	assert.Len(t, seen, 1, "Операции пакета используют общие DataLoader-ы")
+	assert.NotContains(t, seen, (*Loaders)(nil))
+
+	rr = post(`{"query":"single"}`)
+	assert.Equal(t, `{"data":{"query":"single"}}`, rr.Body.String(), "Одиночная операция передаётся как есть")
+
+	for _, body := range []string{`[]`, `[{},{},{},{}]`, `[1,`} {
+		rr = post(body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		assert.Contains(t, rr.Body.String(), gqlerrors.CodeBadUserInput)
+	}
+}
+
+func TestWithAnonymousID(t *testing.T) {
+	var identities []string
+	handler := withAnonymousID([]byte("0123456789abcdef"), "anon_id", time.Hour, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := r.Context().Value("anonymousID").(string)
+		identities = append(identities, identity)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/query", nil))
+	assert.Empty(t, w.Result().Cookies(), "GET-запрос без cookie не получает её")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/query", nil))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "anon_id", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Empty(t, w.Result().Cookies(), "Существующая cookie не заменяется")
+
+	req = httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set("Authorization", "Bearer token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Len(t, identities, 4)
+	assert.Empty(t, identities[0])
+	assert.Len(t, identities[1], 32)
+	assert.Equal(t, identities[1], identities[2], "Идентификатор постоянен для cookie")
+	assert.NotEqual(t, cookies[0].Value, identities[1], "В контекст попадает только хэш")
+	assert.Empty(t, identities[3], "Авторизованный запрос не анонимный")
+}
+
+func TestProfilingHandler(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+	cfg.Profiling.Enabled = true
+	cfg.Profiling.Token = "0123456789abcdef"
+	handler := New(cfg, &mockStorage{}).Handler()
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("wrong").Code)
+	assert.Equal(t, http.StatusOK, get(cfg.Profiling.Token).Code)
+
+	cfg.Profiling.Enabled = false
+	rr := httptest.NewRecorder()
+	New(cfg, &mockStorage{}).Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil))
+	assert.NotContains(t, rr.Header().Get("Content-Type"), "text/plain", "Без profiling.enabled профили недоступны")
+}
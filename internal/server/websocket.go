@@ -0,0 +1,315 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/ButyrinIA/system/internal/auth"
+	"github.com/ButyrinIA/system/internal/config"
+	mygraphql "github.com/ButyrinIA/system/internal/graphql"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Коды, с которыми сервер сам закрывает WebSocket-соединение
+const (
+	// closeGoingAway - сервер останавливается; переподключаться стоит с задержкой из расширения reconnect
+	closeGoingAway = websocket.CloseGoingAway
+	// closeTokenExpired - истёк срок токена соединения; переподключаться нужно с новым токеном.
+	// Код совпадает с 4401 Unauthorized протокола graphql-transport-ws
+	closeTokenExpired = 4401
+	// closeByAdmin - соединение закрыто мутацией closeUserSubscriptions; код совпадает с 4403 Forbidden
+	closeByAdmin = 4403
+)
+
+// closeLabels - причины закрытия соединения сервером в метриках
+var closeLabels = map[int]string{
+	closeGoingAway:    "shutdown",
+	closeTokenExpired: "token_expired",
+	closeByAdmin:      "admin",
+}
+
+// reconnectExtensionKey - ключ расширения ответа подписки с рекомендацией по переподключению
+const reconnectExtensionKey = "reconnect"
+
+// reconnectAdvice - рекомендуемая задержка перед переподключением после разрыва соединения. Клиент выбирает
+// случайную задержку не меньше MinBackoffMs и удваивает её после каждой неудачной попытки до MaxBackoffMs,
+// чтобы после перезапуска сервера клиенты не переподключались одновременно
+type reconnectAdvice struct {
+	MinBackoffMs int64 `json:"minBackoffMs"`
+	MaxBackoffMs int64 `json:"maxBackoffMs"`
+}
+
+// reconnectExtension добавляет reconnectAdvice в каждый ответ подписки
+type reconnectExtension struct {
+	advice reconnectAdvice
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = reconnectExtension{}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (reconnectExtension) ExtensionName() string {
+	return "ReconnectAdvice"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (reconnectExtension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse реализует graphql.ResponseInterceptor
+func (e reconnectExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	if resp == nil || !graphql.HasOperationContext(ctx) {
+		return resp
+	}
+	if op := graphql.GetOperationContext(ctx).Operation; op == nil || op.Operation != ast.Subscription {
+		return resp
+	}
+	if resp.Extensions == nil {
+		resp.Extensions = map[string]any{}
+	}
+	resp.Extensions[reconnectExtensionKey] = e.advice
+	return resp
+}
+
+// newWebsocketTransport создаёт WebSocket-транспорт с аутентификацией по токену из connection_init через authenticator.
+// Соединение с токеном закрывается с кодом closeTokenExpired, когда срок токена истекает
+func newWebsocketTransport(cfg *config.Config, authenticator auth.Authenticator, connections *wsConnections, logger *log.Logger) *transport.Websocket {
+	return &transport.Websocket{
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				logger.Printf("Проверка происхождения WebSocket: %s", r.Header.Get("Origin"))
+				return true
+			},
+		},
+		KeepAlivePingInterval: cfg.Subscriptions.KeepAliveInterval,
+		PingPongInterval:      cfg.Subscriptions.PingInterval,
+		InitTimeout:           cfg.Subscriptions.InitTimeout,
+		InitFunc: func(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+			logger.Printf("Инициализация WebSocket-соединения, payload: %+v", initPayload)
+			id, err := auth.FromInitPayload(authenticator, initPayload)
+			if err != nil {
+				return ctx, nil, gqlerror.Errorf("%v", err)
+			}
+			if id.UserID != "" {
+				logger.Printf("Успешная аутентификация WebSocket: %s", id.UserID)
+				return connections.register(auth.NewContext(ctx, id), id.ExpiresAt), nil, nil
+			}
+			logger.Println("Заголовок авторизации отсутствует в WebSocket")
+			return connections.register(ctx, time.Time{}), nil, nil
+		},
+	}
+}
+
+// wsConnections отслеживает открытые WebSocket-соединения, чтобы сервер мог закрыть их с кодом причины
+type wsConnections struct {
+	mu    sync.Mutex
+	conns map[*wsConn]struct{}
+}
+
+var _ mygraphql.ConnectionRegistry = &wsConnections{}
+
+func newWSConnections() *wsConnections {
+	return &wsConnections{conns: make(map[*wsConn]struct{})}
+}
+
+// wsConnKey - ключ контекста запроса на upgrade, под которым хранится его wsConn
+type wsConnKey struct{}
+
+// wsConn - сетевое соединение, перехваченное при upgrade, и отмена контекста, по которой gqlgen
+// завершает подписки соединения и закрывает его
+type wsConn struct {
+	mu      sync.Mutex
+	netConn net.Conn
+	cancel  context.CancelFunc
+	closed  bool
+	// info - пользователь, сообщество и время инициализации соединения
+	info mygraphql.WebsocketConnection
+}
+
+// track передаёт в контекст запроса на upgrade wsConn, в который запоминается перехваченное соединение
+func (c *wsConnections) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		conn := &wsConn{}
+		ctx := context.WithValue(r.Context(), wsConnKey{}, conn)
+		next.ServeHTTP(&hijackRecorder{ResponseWriter: w, conn: conn}, r.WithContext(ctx))
+	})
+}
+
+// register учитывает инициализированное соединение и возвращает контекст, отмена которого его закрывает.
+// Если expiresAt задан, в этот момент соединение закрывается с кодом closeTokenExpired
+func (c *wsConnections) register(ctx context.Context, expiresAt time.Time) context.Context {
+	conn, ok := ctx.Value(wsConnKey{}).(*wsConn)
+	if !ok {
+		return ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	userID, _ := ctx.Value("userID").(string)
+	tenant, _ := ctx.Value("tenant").(string)
+	if tenant == "" {
+		tenant = models.DefaultTenant
+	}
+	conn.mu.Lock()
+	conn.cancel = cancel
+	conn.info = mygraphql.WebsocketConnection{UserID: userID, Tenant: tenant, ConnectedAt: time.Now()}
+	conn.mu.Unlock()
+	c.mu.Lock()
+	c.conns[conn] = struct{}{}
+	c.mu.Unlock()
+	metrics.WebsocketConnections.Inc()
+
+	var expiry *time.Timer
+	if !expiresAt.IsZero() {
+		expiry = time.AfterFunc(time.Until(expiresAt), func() {
+			log.Println("Срок токена WebSocket-соединения истёк, соединение закрывается")
+			conn.close(closeTokenExpired, "token expired")
+		})
+	}
+	// Контекст запроса отменяется, когда gqlgen завершает обработку соединения
+	go func() {
+		<-ctx.Done()
+		if expiry != nil {
+			expiry.Stop()
+		}
+		c.mu.Lock()
+		delete(c.conns, conn)
+		c.mu.Unlock()
+		metrics.WebsocketConnections.Dec()
+		metrics.WebsocketConnectionDuration.Observe(time.Since(conn.info.ConnectedAt).Seconds())
+	}()
+	return ctx
+}
+
+// Connections реализует graphql.ConnectionRegistry
+func (c *wsConnections) Connections() []mygraphql.WebsocketConnection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]mygraphql.WebsocketConnection, 0, len(c.conns))
+	for conn := range c.conns {
+		result = append(result, conn.info)
+	}
+	return result
+}
+
+// CloseUser реализует graphql.ConnectionRegistry: соединения пользователя закрываются с кодом closeByAdmin
+func (c *wsConnections) CloseUser(userID string) int {
+	c.mu.Lock()
+	var conns []*wsConn
+	for conn := range c.conns {
+		if conn.info.UserID == userID {
+			conns = append(conns, conn)
+		}
+	}
+	c.mu.Unlock()
+	for _, conn := range conns {
+		conn.close(closeByAdmin, "closed by administrator")
+	}
+	return len(conns)
+}
+
+// closeAll закрывает все открытые соединения с кодом code и причиной reason
+func (c *wsConnections) closeAll(code int, reason string) int {
+	c.mu.Lock()
+	conns := make([]*wsConn, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	c.mu.Unlock()
+	for _, conn := range conns {
+		conn.close(code, reason)
+	}
+	return len(conns)
+}
+
+// drain в течение period закрывает открытые соединения по одному через равные промежутки, чтобы клиенты
+// переподключались к другим экземплярам постепенно, и возвращает число закрытых соединений.
+// Возвращается через period или раньше, если ctx отменён
+func (c *wsConnections) drain(ctx context.Context, period time.Duration, code int, reason string) int {
+	c.mu.Lock()
+	conns := make([]*wsConn, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	c.mu.Unlock()
+	deadline := time.NewTimer(period)
+	defer deadline.Stop()
+	interval := period / time.Duration(len(conns)+1)
+	closed := 0
+	for _, conn := range conns {
+		select {
+		case <-ctx.Done():
+			return closed
+		case <-deadline.C:
+			return closed
+		case <-time.After(interval):
+		}
+		conn.close(code, reason)
+		closed++
+	}
+	select {
+	case <-ctx.Done():
+	case <-deadline.C:
+	}
+	return closed
+}
+
+// close отправляет клиенту кадр закрытия с кодом code и отменяет контекст соединения. gqlgen закрывает
+// соединение по отмене контекста всегда с кодом 1000, поэтому кадр с нужным кодом записывается в сетевое
+// соединение напрямую: он уходит одной записью и не перемешивается с кадрами gorilla/websocket
+func (c *wsConn) close(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.netConn == nil || c.cancel == nil {
+		return
+	}
+	c.closed = true
+	// Кадр закрытия от сервера не маскируется; длина причины ограничена 123 байтами
+	payload := websocket.FormatCloseMessage(code, reason)
+	frame := append([]byte{0x80 | websocket.CloseMessage, byte(len(payload))}, payload...)
+	c.netConn.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err := c.netConn.Write(frame); err != nil {
+		log.Printf("Ошибка отправки кадра закрытия WebSocket: %v", err)
+	}
+	metrics.WebsocketClosed.WithLabelValues(closeLabels[code]).Inc()
+	c.cancel()
+}
+
+// hijackRecorder запоминает в wsConn соединение, перехваченное gorilla/websocket при upgrade
+type hijackRecorder struct {
+	http.ResponseWriter
+	conn *wsConn
+}
+
+// Hijack реализует http.Hijacker
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := h.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err == nil {
+		h.conn.mu.Lock()
+		h.conn.netConn = netConn
+		h.conn.mu.Unlock()
+	}
+	return netConn, rw, err
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/config"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// dialSubscriptions подключается к /query по протоколу graphql-transport-ws и дожидается connection_ack
+func dialSubscriptions(t *testing.T, url string, payload map[string]any) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/query", nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "connection_init", "payload": payload}))
+	var ack map[string]any
+	require.NoError(t, conn.ReadJSON(&ack))
+	require.Equal(t, "connection_ack", ack["type"])
+	return conn
+}
+
+// closeCode читает сообщения до закрытия соединения и возвращает код закрытия
+func closeCode(t *testing.T, conn *websocket.Conn) int {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		return closeErr.Code
+	}
+}
+
+func TestWebsocket_ClosedOnShutdown(t *testing.T) {
+	cfg := config.Default()
+	s := New(cfg, &mockStorage{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	conn := dialSubscriptions(t, ts.URL, nil)
+	defer conn.Close()
+	require.NoError(t, s.Shutdown(context.Background()))
+	assert.Equal(t, websocket.CloseGoingAway, closeCode(t, conn))
+}
+
+func TestWebsocket_DrainedOnShutdown(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.DrainPeriod = 300 * time.Millisecond
+	s := New(cfg, &mockStorage{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	first := dialSubscriptions(t, ts.URL, nil)
+	defer first.Close()
+	second := dialSubscriptions(t, ts.URL, nil)
+	defer second.Close()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(context.Background()) }()
+
+	assert.Eventually(t, s.draining.Load, time.Second, 10*time.Millisecond)
+	resp, err := http.Get(ts.URL + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "Во время вывода из ротации сервер не готов, но отвечает")
+
+	assert.Equal(t, websocket.CloseGoingAway, closeCode(t, first))
+	assert.Equal(t, websocket.CloseGoingAway, closeCode(t, second))
+	require.NoError(t, <-done)
+	assert.GreaterOrEqual(t, time.Since(start), cfg.Server.DrainPeriod)
+}
+
+func TestWebsocket_ClosedOnTokenExpiry(t *testing.T) {
+	cfg := config.Default()
+	s := New(cfg, &mockStorage{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "user1",
+		"exp":     time.Now().Add(time.Second).Unix(),
+	}).SignedString([]byte(config.DevJWTSecret))
+	require.NoError(t, err)
+	conn := dialSubscriptions(t, ts.URL, map[string]any{"Authorization": "Bearer " + token})
+	defer conn.Close()
+	assert.Equal(t, closeTokenExpired, closeCode(t, conn))
+}
+
+func TestReconnectExtension(t *testing.T) {
+	advice := reconnectAdvice{MinBackoffMs: 1000, MaxBackoffMs: 30000}
+	ext := reconnectExtension{advice: advice}
+	respond := func(ctx context.Context) *graphql.Response {
+		return &graphql.Response{}
+	}
+	operation := func(op ast.Operation) context.Context {
+		return graphql.WithOperationContext(context.Background(), &graphql.OperationContext{
+			Operation: &ast.OperationDefinition{Operation: op},
+		})
+	}
+
+	resp := ext.InterceptResponse(operation(ast.Subscription), respond)
+	assert.Equal(t, advice, resp.Extensions[reconnectExtensionKey])
+	resp = ext.InterceptResponse(operation(ast.Query), respond)
+	assert.NotContains(t, resp.Extensions, reconnectExtensionKey, "Рекомендация добавляется только в ответы подписок")
+}
+
+func TestWebsocket_ClosedByAdmin(t *testing.T) {
+	cfg := config.Default()
+	s := New(cfg, &mockStorage{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	token := func(userID string) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": userID,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(config.DevJWTSecret))
+		require.NoError(t, err)
+		return "Bearer " + signed
+	}
+	target := dialSubscriptions(t, ts.URL, map[string]any{"Authorization": token("user1")})
+	defer target.Close()
+	other := dialSubscriptions(t, ts.URL, map[string]any{"Authorization": token("user2")})
+	defer other.Close()
+
+	connections := s.websockets.Connections()
+	require.Len(t, connections, 2)
+	assert.Equal(t, models.DefaultTenant, connections[0].Tenant)
+	assert.Equal(t, 1, s.websockets.CloseUser("user1"))
+	assert.Equal(t, closeByAdmin, closeCode(t, target))
+	assert.Eventually(t, func() bool { return len(s.websockets.Connections()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "user2", s.websockets.Connections()[0].UserID)
+}
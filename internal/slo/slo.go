@@ -0,0 +1,131 @@
+// Package slo публикует в /metrics показатели уровня обслуживания GraphQL-операций (SLI) и бюджеты ошибок,
+// чтобы алерты по скорости расхода бюджета настраивались без правил записи. Операции делятся на классы
+// query и mutation; подписки не учитываются. Для каждого класса считаются все операции, операции без
+// ошибок сервера (availability) и операции быстрее порога (latency). Скорость расхода бюджета за окно:
+//
+//	(1 - rate(sli_operations_available_total[1h]) / rate(sli_operations_total[1h]))
+//	  / on(class) slo_error_budget{sli="availability"}
+//
+// Для latency вместо sli_operations_available_total берётся sli_operations_fast_total
+package slo
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Имена SLI в метках slo_objective и slo_error_budget
+const (
+	SLIAvailability = "availability"
+	SLILatency      = "latency"
+)
+
+// Objective - цели класса операций
+type Objective struct {
+	// Availability - доля операций без ошибок сервера
+	Availability float64
+	// Latency - доля операций, выполненных быстрее LatencyThreshold
+	Latency          float64
+	LatencyThreshold time.Duration
+}
+
+// Options задаёт цели по классам операций; класс без цели не учитывается
+type Options struct {
+	Classes map[string]Objective
+	// Clock - источник времени завершения операции, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// Tracker - расширение GraphQL-сервера, которое учитывает операции в SLI. Подключается первым,
+// чтобы задержка включала все остальные расширения
+type Tracker struct {
+	opts Options
+}
+
+var _ interface {
+	graphql.OperationInterceptor
+	graphql.HandlerExtension
+} = &Tracker{}
+
+// New создаёт Tracker и публикует цели и бюджеты ошибок классов
+func New(opts Options) *Tracker {
+	opts = opts.withDefaults()
+	log.Printf("Создание SLO Tracker: классов операций %d", len(opts.Classes))
+	for class, objective := range opts.Classes {
+		metrics.SLOObjective.WithLabelValues(class, SLIAvailability).Set(objective.Availability)
+		metrics.SLOObjective.WithLabelValues(class, SLILatency).Set(objective.Latency)
+		metrics.SLOErrorBudget.WithLabelValues(class, SLIAvailability).Set(1 - objective.Availability)
+		metrics.SLOErrorBudget.WithLabelValues(class, SLILatency).Set(1 - objective.Latency)
+		metrics.SLOLatencyThreshold.WithLabelValues(class).Set(objective.LatencyThreshold.Seconds())
+	}
+	return &Tracker{opts: opts}
+}
+
+// ExtensionName реализует graphql.HandlerExtension
+func (t *Tracker) ExtensionName() string {
+	return "SLOTracker"
+}
+
+// Validate реализует graphql.HandlerExtension
+func (t *Tracker) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation реализует graphql.OperationInterceptor: операция учитывается после ответа,
+// задержка отсчитывается от начала её разбора
+func (t *Tracker) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil {
+		return next(ctx)
+	}
+	class := string(oc.Operation.Operation)
+	objective, ok := t.opts.Classes[class]
+	if !ok || oc.Operation.Operation == ast.Subscription {
+		return next(ctx)
+	}
+	handler := next(ctx)
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		observe(class, objective, resp, t.opts.Clock.Now().Sub(oc.Stats.OperationStart))
+		return resp
+	}
+}
+
+// observe учитывает операцию класса class с ответом resp, выполненную за elapsed
+func observe(class string, objective Objective, resp *graphql.Response, elapsed time.Duration) {
+	metrics.SLIOperations.WithLabelValues(class).Inc()
+	if resp == nil || !serverFailure(resp.Errors) {
+		metrics.SLIAvailableOperations.WithLabelValues(class).Inc()
+	}
+	if elapsed < objective.LatencyThreshold {
+		metrics.SLIFastOperations.WithLabelValues(class).Inc()
+	}
+}
+
+// serverFailure сообщает, есть ли среди ошибок ответа ошибки сервера: с кодом INTERNAL или без кода,
+// например после паники в резолвере. Ошибки клиента - неверный ввод, отказ в доступе, исчерпанные
+// квоты и режим обслуживания - бюджет ошибок не расходуют
+func serverFailure(errs gqlerror.List) bool {
+	for _, err := range errs {
+		code, _ := err.Extensions["code"].(string)
+		if code == "" || code == gqlerrors.CodeInternal {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package slo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/gqlerrors"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// run выполняет операцию вида operation через Tracker; операция длится elapsed и завершается ошибками errs
+func run(tracker *Tracker, clk *clock.Fake, operation ast.Operation, elapsed time.Duration, errs gqlerror.List) {
+	oc := &graphql.OperationContext{Operation: &ast.OperationDefinition{Operation: operation}}
+	oc.Stats.OperationStart = clk.Now()
+	ctx := graphql.WithOperationContext(context.Background(), oc)
+	handler := tracker.InterceptOperation(ctx, func(ctx context.Context) graphql.ResponseHandler {
+		clk.Advance(elapsed)
+		return graphql.OneShot(&graphql.Response{Data: []byte(`{}`), Errors: errs})
+	})
+	handler(ctx)
+}
+
+func TestTracker(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	tracker := New(Options{
+		Classes: map[string]Objective{"mutation": {Availability: 0.999, Latency: 0.99, LatencyThreshold: time.Second}},
+		Clock:   clk,
+	})
+	assert.InDelta(t, 0.001, testutil.ToFloat64(metrics.SLOErrorBudget.WithLabelValues("mutation", SLIAvailability)), 1e-9)
+	assert.InDelta(t, 0.01, testutil.ToFloat64(metrics.SLOErrorBudget.WithLabelValues("mutation", SLILatency)), 1e-9)
+
+	counters := func() [3]float64 {
+		return [3]float64{
+			testutil.ToFloat64(metrics.SLIOperations.WithLabelValues("mutation")),
+			testutil.ToFloat64(metrics.SLIAvailableOperations.WithLabelValues("mutation")),
+			testutil.ToFloat64(metrics.SLIFastOperations.WithLabelValues("mutation")),
+		}
+	}
+	before := counters()
+	run(tracker, clk, ast.Mutation, 100*time.Millisecond, nil)
+	forbidden := gqlerror.Errorf("forbidden")
+	forbidden.Extensions = map[string]any{"code": gqlerrors.CodeForbidden}
+	run(tracker, clk, ast.Mutation, 2*time.Second, gqlerror.List{forbidden})
+	run(tracker, clk, ast.Mutation, 100*time.Millisecond, gqlerror.List{gqlerror.Errorf("internal system error")})
+	run(tracker, clk, ast.Query, time.Minute, nil)
+	after := counters()
+
+	assert.Equal(t, 3.0, after[0]-before[0], "Запросы без цели не учитываются")
+	assert.Equal(t, 2.0, after[1]-before[1], "Ошибка клиента не расходует бюджет, ошибка без кода - расходует")
+	assert.Equal(t, 2.0, after[2]-before[2])
+}
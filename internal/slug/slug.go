@@ -0,0 +1,57 @@
+// Package slug строит из заголовков постов адреса для URL: латиница в нижнем регистре,
+// цифры и дефисы. Кириллица транслитерируется, остальные символы отбрасываются
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxLength - максимальная длина slug без суффикса, добавляемого при совпадении
+const MaxLength = 80
+
+// fallback используется, если в заголовке нет ни одного допустимого символа
+const fallback = "post"
+
+// translit - транслитерация строчных букв русского алфавита
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// Make возвращает slug заголовка: подряд идущие разделители заменяются одним дефисом,
+// длина ограничивается MaxLength по границе слова
+func Make(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case translit[r] != "":
+			b.WriteString(translit[r])
+			dash = false
+		case r == 'ъ' || r == 'ь' || r == '\'' || r == '’':
+			// Знаки и апострофы не разделяют слово
+		case !dash && (unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)):
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.Trim(b.String(), "-")
+	if len(s) > MaxLength {
+		s = s[:MaxLength]
+		if i := strings.LastIndexByte(s, '-'); i > 0 {
+			s = s[:i]
+		}
+		s = strings.Trim(s, "-")
+	}
+	if s == "" {
+		return fallback
+	}
+	return s
+}
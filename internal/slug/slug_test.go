@@ -0,0 +1,33 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"Объявление о съезде", "obyavlenie-o-sezde"},
+		{"Щука и ёж: итоги 2024 года", "shchuka-i-ezh-itogi-2024-goda"},
+		{"  --Go  1.23 -- релиз--  ", "go-1-23-reliz"},
+		{"Don't panic", "dont-panic"},
+		{"日本語", "post"},
+		{"!!!", "post"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Make(tt.title), tt.title)
+	}
+}
+
+func TestMake_Length(t *testing.T) {
+	got := Make(strings.Repeat("слово ", 30))
+	assert.LessOrEqual(t, len(got), MaxLength)
+	assert.False(t, strings.HasSuffix(got, "-"))
+	assert.True(t, strings.HasSuffix(got, "slovo"), "Обрезка идёт по границе слова")
+}
@@ -0,0 +1,113 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAkismetEndpoint - базовый адрес REST API Akismet
+const DefaultAkismetEndpoint = "https://rest.akismet.com/1.1"
+
+// maxAkismetResponse ограничивает объём читаемого ответа Akismet
+const maxAkismetResponse = 64 << 10
+
+// AkismetOptions задаёт ключ API и сайт, от имени которого проверяются комментарии
+type AkismetOptions struct {
+	APIKey string
+	// Site - адрес сайта, под которым зарегистрирован ключ (параметр blog)
+	Site     string
+	Endpoint string
+	Timeout  time.Duration
+}
+
+func (o AkismetOptions) withDefaults() AkismetOptions {
+	if o.Endpoint == "" {
+		o.Endpoint = DefaultAkismetEndpoint
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	return o
+}
+
+// Akismet проверяет комментарии через comment-check и сообщает решения модераторов
+// через submit-spam и submit-ham
+type Akismet struct {
+	opts   AkismetOptions
+	client *http.Client
+}
+
+// NewAkismet создаёт клиент Akismet
+func NewAkismet(opts AkismetOptions) *Akismet {
+	opts = opts.withDefaults()
+	return &Akismet{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// Check вызывает comment-check: Akismet отвечает "true" для спама и "false" для обычного комментария
+func (a *Akismet) Check(ctx context.Context, sub Submission) (bool, error) {
+	body, header, err := a.call(ctx, "comment-check", sub)
+	if err != nil {
+		return false, err
+	}
+	switch body {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected akismet response %q: %s", body, header.Get("X-akismet-debug-help"))
+}
+
+// Report вызывает submit-spam или submit-ham. Akismet требует IP автора, который не хранится
+// вместе с комментарием, поэтому решения по старым комментариям могут быть отклонены
+func (a *Akismet) Report(ctx context.Context, sub Submission, spam bool) error {
+	method := "submit-ham"
+	if spam {
+		method = "submit-spam"
+	}
+	_, _, err := a.call(ctx, method, sub)
+	return err
+}
+
+// call отправляет комментарий методу API и возвращает тело ответа без пробелов по краям
+func (a *Akismet) call(ctx context.Context, method string, sub Submission) (string, http.Header, error) {
+	commentType := "comment"
+	if sub.Comment.ParentID != nil {
+		commentType = "reply"
+	}
+	form := url.Values{
+		"api_key":          {a.opts.APIKey},
+		"blog":             {a.opts.Site},
+		"user_ip":          {sub.IP},
+		"user_agent":       {sub.UserAgent},
+		"comment_type":     {commentType},
+		"comment_author":   {sub.Comment.AuthorID},
+		"comment_content":  {sub.Comment.Content},
+		"comment_date_gmt": {sub.Comment.CreatedAt.UTC().Format(time.RFC3339)},
+	}
+	endpoint := strings.TrimSuffix(a.opts.Endpoint, "/") + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to build akismet request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "system-spam/1.0")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to call akismet %s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAkismetResponse))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read akismet response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("unexpected status %d from akismet %s", resp.StatusCode, method)
+	}
+	return strings.TrimSpace(string(data)), resp.Header, nil
+}
@@ -0,0 +1,132 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// DefaultBayesThreshold - вероятность спама, начиная с которой комментарий считается спамом
+const DefaultBayesThreshold = 0.9
+
+// Bayes - локальный наивный байесовский классификатор по словам комментария.
+// Модель хранится в памяти и дообучается на решениях модераторов; Load восстанавливает её
+// по уже проверенным комментариям после перезапуска
+type Bayes struct {
+	threshold float64
+	mu        sync.RWMutex
+	// words - сколько раз слово встретилось в спаме и в обычных комментариях
+	words map[string]*[2]int
+	// tokens и docs - общее число слов и комментариев каждого класса
+	tokens [2]int
+	docs   [2]int
+}
+
+// Индексы классов в счётчиках модели
+const (
+	classHam  = 0
+	classSpam = 1
+)
+
+// NewBayes создаёт необученный классификатор; threshold вне (0, 1) заменяется DefaultBayesThreshold
+func NewBayes(threshold float64) *Bayes {
+	if threshold <= 0 || threshold >= 1 {
+		threshold = DefaultBayesThreshold
+	}
+	return &Bayes{threshold: threshold, words: make(map[string]*[2]int)}
+}
+
+// Train добавляет текст в модель как спам или обычный комментарий
+func (b *Bayes) Train(text string, spam bool) {
+	class := classHam
+	if spam {
+		class = classSpam
+	}
+	tokens := tokenize(text)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, token := range tokens {
+		counts, ok := b.words[token]
+		if !ok {
+			counts = &[2]int{}
+			b.words[token] = counts
+		}
+		counts[class]++
+	}
+	b.tokens[class] += len(tokens)
+	b.docs[class]++
+}
+
+// Score возвращает вероятность того, что текст - спам. Пока в модели нет примеров
+// обоих классов, возвращается 0.5
+func (b *Bayes) Score(text string) float64 {
+	tokens := tokenize(text)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.docs[classHam] == 0 || b.docs[classSpam] == 0 {
+		return 0.5
+	}
+	// Логарифмы вероятностей с лапласовским сглаживанием, чтобы незнакомые слова не обнуляли оценку
+	vocabulary := float64(len(b.words))
+	total := float64(b.docs[classHam] + b.docs[classSpam])
+	var logProb [2]float64
+	for class := range logProb {
+		logProb[class] = math.Log(float64(b.docs[class]) / total)
+		for _, token := range tokens {
+			count := 0
+			if counts, ok := b.words[token]; ok {
+				count = counts[class]
+			}
+			logProb[class] += math.Log((float64(count) + 1) / (float64(b.tokens[class]) + vocabulary))
+		}
+	}
+	return 1 / (1 + math.Exp(logProb[classHam]-logProb[classSpam]))
+}
+
+// Check сравнивает вероятность спама с порогом
+func (b *Bayes) Check(ctx context.Context, sub Submission) (bool, error) {
+	return b.Score(sub.Comment.Content) >= b.threshold, nil
+}
+
+// Report обучает модель на решении модератора
+func (b *Bayes) Report(ctx context.Context, sub Submission, spam bool) error {
+	b.Train(sub.Comment.Content, spam)
+	return nil
+}
+
+// Load обучает модель на последних limit комментариях со статусами SPAM и HAM из хранилища
+func (b *Bayes) Load(ctx context.Context, store storage.Storage, limit int) error {
+	for _, status := range []string{models.SpamStatusSpam, models.SpamStatusHam} {
+		comments, err := store.ListCommentsBySpamStatus(ctx, status, limit)
+		if err != nil {
+			return fmt.Errorf("failed to load %s comments: %v", status, err)
+		}
+		for _, comment := range comments {
+			b.Train(comment.Content, status == models.SpamStatusSpam)
+		}
+		log.Printf("Байесовский классификатор обучен на %d комментариях со статусом %s", len(comments), status)
+	}
+	return nil
+}
+
+// tokenize разбивает текст на слова в нижнем регистре; слова короче двух символов пропускаются
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := fields[:0]
+	for _, field := range fields {
+		if utf8.RuneCountInString(field) >= 2 {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,159 @@
+// Package spam оценивает комментарии на спам внешним сервисом (Akismet) или локальным
+// наивным байесовским классификатором. Комментарий проверяется при создании сразу или в фоне;
+// признанный спамом комментарий скрывается от всех, кроме автора, до решения модератора
+package spam
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Submission - комментарий вместе с данными запроса, по которым сервисы оценивают спам
+type Submission struct {
+	Comment   *models.Comment
+	IP        string
+	UserAgent string
+}
+
+// Checker оценивает комментарии и учитывает решения модераторов
+type Checker interface {
+	// Check сообщает, является ли комментарий спамом
+	Check(ctx context.Context, sub Submission) (bool, error)
+	// Report передаёт решение модератора: spam=true для пропущенного спама, false для ложного срабатывания
+	Report(ctx context.Context, sub Submission, spam bool) error
+}
+
+// Options задаёт режим проверки; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Async - проверять в фоне: комментарий публикуется сразу со статусом PENDING
+	// и скрывается, если окажется спамом
+	Async     bool
+	Timeout   time.Duration
+	Workers   int
+	QueueSize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.Workers <= 0 {
+		o.Workers = 2
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	return o
+}
+
+// Service проверяет новые комментарии и сохраняет результат фоновой проверки
+type Service struct {
+	checker Checker
+	store   storage.Storage
+	opts    Options
+	jobs    chan Submission
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// New создаёт сервис; в асинхронном режиме запускает фоновые обработчики
+func New(checker Checker, store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Spam Service: async=%t, workers=%d, timeout=%v", opts.Async, opts.Workers, opts.Timeout)
+	s := &Service{checker: checker, store: store, opts: opts}
+	if opts.Async {
+		s.jobs = make(chan Submission, opts.QueueSize)
+		for i := 0; i < opts.Workers; i++ {
+			s.wg.Add(1)
+			go s.worker()
+		}
+	}
+	return s
+}
+
+// Screen возвращает статус нового комментария до его сохранения. В синхронном режиме
+// комментарий проверяется сразу; если сервис проверки недоступен, комментарий публикуется
+// со статусом PENDING, чтобы сбой проверки не мешал обсуждению
+func (s *Service) Screen(ctx context.Context, sub Submission) string {
+	if s.opts.Async {
+		return models.SpamStatusPending
+	}
+	return s.check(ctx, sub)
+}
+
+// Enqueue ставит сохранённый комментарий на фоновую проверку; в синхронном режиме ничего не делает.
+// При переполнении очереди комментарий остаётся со статусом PENDING
+func (s *Service) Enqueue(sub Submission) {
+	if !s.opts.Async {
+		return
+	}
+	select {
+	case s.jobs <- sub:
+		log.Printf("Комментарий %s поставлен в очередь проверки на спам", sub.Comment.ID)
+	default:
+		log.Printf("Очередь проверки на спам переполнена, комментарий %s остаётся PENDING", sub.Comment.ID)
+	}
+}
+
+// Report передаёт решение модератора сервису проверки
+func (s *Service) Report(ctx context.Context, sub Submission, spam bool) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	return s.checker.Report(ctx, sub, spam)
+}
+
+// Close останавливает обработчики после завершения текущих проверок
+func (s *Service) Close() {
+	s.once.Do(func() {
+		if s.jobs != nil {
+			close(s.jobs)
+			s.wg.Wait()
+		}
+		log.Println("Spam Service остановлен")
+	})
+}
+
+func (s *Service) worker() {
+	defer s.wg.Done()
+	for sub := range s.jobs {
+		s.process(sub)
+	}
+}
+
+// process проверяет комментарий и сохраняет результат. Комментарий, скрытый по другой причине
+// (теневой бан, очередь проверки), остаётся скрытым и после статуса HAM
+func (s *Service) process(sub Submission) {
+	status := s.check(context.Background(), sub)
+	if status == models.SpamStatusPending {
+		return
+	}
+	hidden := sub.Comment.Hidden || status == models.SpamStatusSpam
+	if err := s.store.SetSpamStatus(context.Background(), sub.Comment.ID, status, hidden); err != nil {
+		log.Printf("Ошибка сохранения результата проверки комментария %s: %v", sub.Comment.ID, err)
+	}
+}
+
+// check вызывает Checker с таймаутом и переводит ответ в статус комментария
+func (s *Service) check(ctx context.Context, sub Submission) string {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	isSpam, err := s.checker.Check(ctx, sub)
+	if err != nil {
+		log.Printf("Ошибка проверки комментария %s на спам: %v", sub.Comment.ID, err)
+		metrics.SpamChecks.WithLabelValues("error").Inc()
+		return models.SpamStatusPending
+	}
+	status := models.SpamStatusHam
+	if isSpam {
+		status = models.SpamStatusSpam
+		log.Printf("Комментарий %s признан спамом", sub.Comment.ID)
+	}
+	metrics.SpamChecks.WithLabelValues(status).Inc()
+	return status
+}
@@ -0,0 +1,172 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubChecker возвращает заданный ответ и запоминает решения модераторов
+type stubChecker struct {
+	spam    bool
+	err     error
+	reports []bool
+}
+
+func (c *stubChecker) Check(ctx context.Context, sub Submission) (bool, error) {
+	return c.spam, c.err
+}
+
+func (c *stubChecker) Report(ctx context.Context, sub Submission, spam bool) error {
+	c.reports = append(c.reports, spam)
+	return nil
+}
+
+func TestAkismet(t *testing.T) {
+	var form map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = map[string]string{"path": r.URL.Path}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		switch r.PostForm.Get("comment_content") {
+		case "spam":
+			w.Write([]byte("true"))
+		case "invalid":
+			w.Header().Set("X-akismet-debug-help", "Empty api_key")
+			w.Write([]byte("invalid"))
+		default:
+			w.Write([]byte("false"))
+		}
+	}))
+	defer srv.Close()
+	akismet := NewAkismet(AkismetOptions{APIKey: "key", Site: "https://example.com", Endpoint: srv.URL})
+	ctx := context.Background()
+	parentID := "c0"
+	sub := Submission{
+		Comment:   &models.Comment{ID: "c1", ParentID: &parentID, AuthorID: "user1", Content: "spam", CreatedAt: time.Now()},
+		IP:        "203.0.113.7",
+		UserAgent: "test",
+	}
+
+	isSpam, err := akismet.Check(ctx, sub)
+	require.NoError(t, err)
+	assert.True(t, isSpam)
+	assert.Equal(t, "/comment-check", form["path"])
+	assert.Equal(t, "key", form["api_key"])
+	assert.Equal(t, "https://example.com", form["blog"])
+	assert.Equal(t, "203.0.113.7", form["user_ip"])
+	assert.Equal(t, "reply", form["comment_type"])
+	assert.Equal(t, "user1", form["comment_author"])
+
+	sub.Comment.Content = "Обычный комментарий"
+	isSpam, err = akismet.Check(ctx, sub)
+	require.NoError(t, err)
+	assert.False(t, isSpam)
+
+	sub.Comment.Content = "invalid"
+	_, err = akismet.Check(ctx, sub)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Empty api_key")
+
+	require.NoError(t, akismet.Report(ctx, sub, true))
+	assert.Equal(t, "/submit-spam", form["path"])
+	require.NoError(t, akismet.Report(ctx, sub, false))
+	assert.Equal(t, "/submit-ham", form["path"])
+}
+
+func TestBayes(t *testing.T) {
+	bayes := NewBayes(0)
+	assert.Equal(t, 0.5, bayes.Score("Купите дешёвые часы"), "Без примеров обоих классов оценка нейтральна")
+
+	for _, text := range []string{
+		"Купите дешёвые часы со скидкой",
+		"Скидка на часы, купите сейчас",
+		"Дешёвые кредиты без проверки, звоните",
+	} {
+		bayes.Train(text, true)
+	}
+	for _, text := range []string{
+		"Спасибо за статью, очень подробно",
+		"Не согласен с выводом во втором разделе",
+		"Интересная статья, жду продолжения",
+	} {
+		bayes.Train(text, false)
+	}
+
+	isSpam, err := bayes.Check(context.Background(), Submission{Comment: &models.Comment{Content: "Купите часы со скидкой!"}})
+	require.NoError(t, err)
+	assert.True(t, isSpam)
+	isSpam, err = bayes.Check(context.Background(), Submission{Comment: &models.Comment{Content: "Спасибо, интересная статья"}})
+	require.NoError(t, err)
+	assert.False(t, isSpam)
+}
+
+func TestBayes_Load(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	post := &models.Post{ID: "p1", Title: "Пост", AuthorID: "user1", AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(ctx, post))
+	for i, c := range []struct {
+		content string
+		status  string
+	}{
+		{"Купите дешёвые часы", models.SpamStatusSpam},
+		{"Спасибо за статью", models.SpamStatusHam},
+	} {
+		require.NoError(t, store.CreateComment(ctx, &models.Comment{
+			ID: string(rune('a' + i)), PostID: post.ID, AuthorID: "user1", Content: c.content, CreatedAt: time.Now(), SpamStatus: c.status,
+		}))
+	}
+
+	bayes := NewBayes(0)
+	require.NoError(t, bayes.Load(ctx, store, 100))
+	assert.Greater(t, bayes.Score("дешёвые часы"), 0.5)
+	assert.Less(t, bayes.Score("спасибо за статью"), 0.5)
+}
+
+func TestService_Screen(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	sub := Submission{Comment: &models.Comment{ID: "c1", Content: "текст"}}
+
+	s := New(&stubChecker{spam: true}, store, Options{})
+	assert.Equal(t, models.SpamStatusSpam, s.Screen(ctx, sub))
+
+	s = New(&stubChecker{err: errors.New("unavailable")}, store, Options{})
+	assert.Equal(t, models.SpamStatusPending, s.Screen(ctx, sub), "Сбой проверки не блокирует комментарий")
+
+	s = New(&stubChecker{spam: true}, store, Options{Async: true})
+	defer s.Close()
+	assert.Equal(t, models.SpamStatusPending, s.Screen(ctx, sub), "В асинхронном режиме проверка откладывается")
+}
+
+func TestService_Async(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "p1", Title: "Пост", AuthorID: "user1", AllowComments: true, CreatedAt: time.Now()}))
+	comment := &models.Comment{ID: "c1", PostID: "p1", AuthorID: "user2", Content: "Купите часы", CreatedAt: time.Now(), SpamStatus: models.SpamStatusPending}
+	require.NoError(t, store.CreateComment(ctx, comment))
+
+	checker := &stubChecker{spam: true}
+	s := New(checker, store, Options{Async: true})
+	s.Enqueue(Submission{Comment: comment})
+	s.Close()
+
+	got, err := store.GetComment(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, models.SpamStatusSpam, got.SpamStatus)
+	assert.True(t, got.Hidden, "Спам скрывается после фоновой проверки")
+
+	require.NoError(t, s.Report(ctx, Submission{Comment: got}, false))
+	assert.Equal(t, []bool{false}, checker.reports)
+}
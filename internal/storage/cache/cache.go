@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// cacheName - метка кеша постов в метриках
+const cacheName = "post"
+
+// Options задаёт параметры кеша; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	TTL  time.Duration
+	Size int
+}
+
+func (o Options) withDefaults() Options {
+	if o.TTL <= 0 {
+		o.TTL = 5 * time.Second
+	}
+	if o.Size <= 0 {
+		o.Size = 1000
+	}
+	return o
+}
+
+// Storage - обёртка над хранилищем с общим для всех запросов кешем постов по ID.
+// Кеш живёт недолго и сбрасывается при изменении поста через эту обёртку.
+type Storage struct {
+	storage.Storage
+	posts *expirable.LRU[string, models.Post]
+}
+
+var _ storage.Storage = &Storage{}
+
+// New оборачивает хранилище кешем постов
+func New(store storage.Storage, opts Options) *Storage {
+	opts = opts.withDefaults()
+	log.Printf("Создание кеша постов: TTL=%v, размер=%d", opts.TTL, opts.Size)
+	return &Storage{
+		Storage: store,
+		posts:   expirable.NewLRU[string, models.Post](opts.Size, nil, opts.TTL),
+	}
+}
+
+// GetPost возвращает пост из кеша или загружает его из хранилища. Запросы с выключенным флагом
+// flags.PostCache читают хранилище напрямую
+func (s *Storage) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	if !flags.Enabled(ctx, flags.PostCache) {
+		metrics.CacheRequests.WithLabelValues(cacheName, "bypass").Inc()
+		return s.Storage.GetPost(ctx, id)
+	}
+	if post, ok := s.posts.Get(id); ok {
+		metrics.CacheRequests.WithLabelValues(cacheName, "hit").Inc()
+		return &post, nil
+	}
+	metrics.CacheRequests.WithLabelValues(cacheName, "miss").Inc()
+	post, err := s.Storage.GetPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// В кеше хранится копия, чтобы изменения вызывающего кода не попадали в другие запросы;
+	// время форматируется один раз на запись кеша
+	cached := *post
+	cached.PrecomputeTimes()
+	s.posts.Add(id, cached)
+	return post, nil
+}
+
+// CreatePost создаёт пост и сбрасывает возможную запись в кеше
+func (s *Storage) CreatePost(ctx context.Context, post *models.Post) error {
+	err := s.Storage.CreatePost(ctx, post)
+	s.Invalidate(post.ID)
+	return err
+}
+
+// SetPostCategory меняет категорию поста и сбрасывает его запись в кеше
+func (s *Storage) SetPostCategory(ctx context.Context, postID string, categoryID *string) error {
+	err := s.Storage.SetPostCategory(ctx, postID, categoryID)
+	s.Invalidate(postID)
+	return err
+}
+
+// SetPostRequireApproval меняет премодерацию поста и сбрасывает его запись в кеше
+func (s *Storage) SetPostRequireApproval(ctx context.Context, postID string, required bool) error {
+	err := s.Storage.SetPostRequireApproval(ctx, postID, required)
+	s.Invalidate(postID)
+	return err
+}
+
+// SetPostExcerpt меняет анонс поста и сбрасывает его запись в кеше
+func (s *Storage) SetPostExcerpt(ctx context.Context, postID, excerpt string) error {
+	err := s.Storage.SetPostExcerpt(ctx, postID, excerpt)
+	s.Invalidate(postID)
+	return err
+}
+
+// UpdatePostTitle меняет заголовок поста и сбрасывает его запись в кеше
+func (s *Storage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	post, err := s.Storage.UpdatePostTitle(ctx, postID, title, slugBase)
+	s.Invalidate(postID)
+	return post, err
+}
+
+// AnonymizeUserContent обезличивает посты или комментарии пользователя и сбрасывает записи обезличенных постов в кеше
+func (s *Storage) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	ids, err := s.Storage.AnonymizeUserContent(ctx, kind, userID, limit)
+	if kind == models.TargetPost {
+		for _, id := range ids {
+			s.Invalidate(id)
+		}
+	}
+	return ids, err
+}
+
+// Invalidate удаляет пост из кеша
+func (s *Storage) Invalidate(id string) {
+	s.posts.Remove(id)
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/flags"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Hit after miss", func(t *testing.T) {
+		store := New(memory.New(), Options{TTL: time.Minute})
+		post := &models.Post{ID: "1", Title: "Пост", CreatedAt: time.Now()}
+		assert.NoError(t, store.CreatePost(ctx, post))
+
+		hits := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(cacheName, "hit"))
+		misses := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(cacheName, "miss"))
+
+		_, err := store.GetPost(ctx, "1")
+		assert.NoError(t, err)
+		got, err := store.GetPost(ctx, "1")
+		assert.NoError(t, err)
+		assert.Equal(t, "Пост", got.Title)
+
+		assert.Equal(t, misses+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(cacheName, "miss")))
+		assert.Equal(t, hits+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(cacheName, "hit")))
+	})
+
+	t.Run("Cached copy is isolated", func(t *testing.T) {
+		store := New(memory.New(), Options{TTL: time.Minute})
+		assert.NoError(t, store.CreatePost(ctx, &models.Post{ID: "1", Title: "Пост", CreatedAt: time.Now()}))
+
+		got, _ := store.GetPost(ctx, "1")
+		got.Title = "Изменён"
+		got, _ = store.GetPost(ctx, "1")
+		assert.Equal(t, "Пост", got.Title)
+	})
+
+	t.Run("Invalidate and expiry", func(t *testing.T) {
+		store := New(memory.New(), Options{TTL: 20 * time.Millisecond})
+		assert.NoError(t, store.CreatePost(ctx, &models.Post{ID: "1", CreatedAt: time.Now()}))
+		_, _ = store.GetPost(ctx, "1")
+		_, ok := store.posts.Get("1")
+		assert.True(t, ok)
+
+		store.Invalidate("1")
+		_, ok = store.posts.Get("1")
+		assert.False(t, ok)
+
+		_, _ = store.GetPost(ctx, "1")
+		time.Sleep(50 * time.Millisecond)
+		_, ok = store.posts.Get("1")
+		assert.False(t, ok, "Запись должна истечь по TTL")
+	})
+
+	t.Run("SetPostCategory invalidates", func(t *testing.T) {
+		store := New(memory.New(), Options{TTL: time.Minute})
+		assert.NoError(t, store.CreateCategory(ctx, &models.Category{ID: "c1", Name: "Наука", CreatedAt: time.Now()}))
+		assert.NoError(t, store.CreatePost(ctx, &models.Post{ID: "1", CreatedAt: time.Now()}))
+		_, _ = store.GetPost(ctx, "1")
+
+		categoryID := "c1"
+		assert.NoError(t, store.SetPostCategory(ctx, "1", &categoryID))
+		got, err := store.GetPost(ctx, "1")
+		assert.NoError(t, err)
+		assert.Equal(t, &categoryID, got.CategoryID)
+	})
+
+	t.Run("Not found is not cached", func(t *testing.T) {
+		store := New(memory.New(), Options{})
+		_, err := store.GetPost(ctx, "missing")
+		assert.Error(t, err)
+		assert.Equal(t, 0, store.posts.Len())
+	})
+
+	t.Run("Disabled flag bypasses cache", func(t *testing.T) {
+		store := New(memory.New(), Options{TTL: time.Minute})
+		assert.NoError(t, store.CreatePost(ctx, &models.Post{ID: "1", CreatedAt: time.Now()}))
+		service := flags.New(flags.Options{Static: map[string]flags.Flag{flags.PostCache: {}}})
+		flagCtx := flags.NewContext(ctx, service.For("user1"))
+
+		bypasses := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(cacheName, "bypass"))
+		_, err := store.GetPost(flagCtx, "1")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, store.posts.Len())
+		assert.Equal(t, bypasses+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues(cacheName, "bypass")))
+	})
+}
@@ -0,0 +1,19 @@
+package storage
+
+import "strings"
+
+// categoryPathSeparator разделяет ID категорий в Category.Path
+const categoryPathSeparator = "/"
+
+// CategoryPath возвращает путь категории id, вложенной в категорию с путём parentPath; для корневой категории parentPath пустой
+func CategoryPath(parentPath, id string) string {
+	if parentPath == "" {
+		return id
+	}
+	return parentPath + categoryPathSeparator + id
+}
+
+// InCategoryTree сообщает, лежит ли категория с путём path в поддереве категории с путём rootPath, включая её саму
+func InCategoryTree(rootPath, path string) bool {
+	return path == rootPath || strings.HasPrefix(path, rootPath+categoryPathSeparator)
+}
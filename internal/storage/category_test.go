@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryPath(t *testing.T) {
+	assert.Equal(t, "a", CategoryPath("", "a"))
+	assert.Equal(t, "a/b", CategoryPath("a", "b"))
+
+	assert.True(t, InCategoryTree("a", "a"))
+	assert.True(t, InCategoryTree("a", "a/b/c"))
+	assert.False(t, InCategoryTree("a", "ab"), "Префикс ID соседней категории не входит в поддерево")
+	assert.False(t, InCategoryTree("a/b", "a"))
+}
@@ -0,0 +1,156 @@
+// Package coalesce объединяет вставки комментариев в пачки во время шторма: пока частота вставок
+// ниже порога, комментарии пишутся как обычно, а выше порога ждут попутчиков не дольше окна
+// и сохраняются одной записью в хранилище через CreateComments
+package coalesce
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storm"
+)
+
+// stormKey - ключ вставок комментариев в детекторе: частота считается по всему узлу
+const stormKey = "comments"
+
+// Options задаёт параметры объединения; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Threshold - число вставок в секунду, начиная с которого они объединяются в пачки
+	Threshold int
+	// Window - сколько вставка ждёт попутчиков; MaxBatch - размер пачки, при котором она пишется сразу
+	Window   time.Duration
+	MaxBatch int
+	// Clock - часы детектора шторма и окна ожидания, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Threshold <= 0 {
+		o.Threshold = 200
+	}
+	if o.Window <= 0 {
+		o.Window = 20 * time.Millisecond
+	}
+	if o.MaxBatch <= 0 {
+		o.MaxBatch = 50
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// request - вставка, ожидающая записи пачки
+type request struct {
+	comment *models.Comment
+	done    chan error
+}
+
+// Storage - обёртка над хранилищем, объединяющая вставки комментариев во время шторма.
+// Вызывающий CreateComment получает результат своей вставки, как без обёртки
+type Storage struct {
+	storage.Storage
+	opts     Options
+	detector *storm.Detector
+
+	mu      sync.Mutex
+	pending []*request
+	timer   clock.Timer
+}
+
+var _ storage.Storage = &Storage{}
+
+// New оборачивает хранилище объединением вставок комментариев
+func New(store storage.Storage, opts Options) *Storage {
+	opts = opts.withDefaults()
+	log.Printf("Создание объединения вставок комментариев: порог %d/с, окно %v, пачка до %d", opts.Threshold, opts.Window, opts.MaxBatch)
+	return &Storage{
+		Storage:  store,
+		opts:     opts,
+		detector: storm.New(storm.Options{Scope: "comment_writes", Threshold: opts.Threshold, Window: time.Second, Clock: opts.Clock}),
+	}
+}
+
+// CreateComment вставляет комментарий сразу или, во время шторма, в составе пачки.
+// Запись пачки не отменяется контекстом отдельного запроса: комментарии попутчиков должны сохраниться.
+// При отмене контекста вызывающий перестаёт ждать: комментарий, ещё ждущий окна, убирается из пачки,
+// а уже записываемый сохраняется вместе с ней
+func (s *Storage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	if !s.detector.Observe(stormKey) {
+		return s.Storage.CreateComment(ctx, comment)
+	}
+	req := &request{comment: comment, done: make(chan error, 1)}
+	s.mu.Lock()
+	s.pending = append(s.pending, req)
+	if len(s.pending) >= s.opts.MaxBatch {
+		batch := s.take()
+		s.mu.Unlock()
+		s.write(batch)
+	} else {
+		if s.timer == nil {
+			s.timer = s.opts.Clock.AfterFunc(s.opts.Window, s.flush)
+		}
+		s.mu.Unlock()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		s.cancel(req)
+		return ctx.Err()
+	}
+}
+
+// cancel убирает вставку из ожидающих окна; взятая в запись пачка не меняется
+func (s *Storage) cancel(req *request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, pending := range s.pending {
+		if pending == req {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// flush записывает накопленную пачку по истечении окна
+func (s *Storage) flush() {
+	s.mu.Lock()
+	batch := s.take()
+	s.mu.Unlock()
+	s.write(batch)
+}
+
+// take забирает накопленные вставки и останавливает таймер окна; вызывается под s.mu
+func (s *Storage) take() []*request {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	batch := s.pending
+	s.pending = nil
+	return batch
+}
+
+// write сохраняет пачку и передаёт каждому ожидающему результат его вставки
+func (s *Storage) write(batch []*request) {
+	if len(batch) == 0 {
+		return
+	}
+	comments := make([]*models.Comment, len(batch))
+	for i, req := range batch {
+		comments[i] = req.comment
+	}
+	metrics.CoalescedBatchSize.Observe(float64(len(batch)))
+	errs := s.Storage.CreateComments(context.Background(), comments)
+	log.Printf("Записана пачка из %d комментариев", len(batch))
+	for i, req := range batch {
+		req.done <- errs[i]
+	}
+}
@@ -0,0 +1,118 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorage считает одиночные вставки и комментарии, вставленные пачками
+type countingStorage struct {
+	storage.Storage
+	mu      sync.Mutex
+	single  int
+	batched int
+}
+
+func (s *countingStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	s.mu.Lock()
+	s.single++
+	s.mu.Unlock()
+	return s.Storage.CreateComment(ctx, comment)
+}
+
+func (s *countingStorage) CreateComments(ctx context.Context, comments []*models.Comment) []error {
+	s.mu.Lock()
+	s.batched += len(comments)
+	s.mu.Unlock()
+	return s.Storage.CreateComments(ctx, comments)
+}
+
+func TestCoalesce(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingStorage{Storage: memory.New()}
+	// Часы стоят, поэтому шторм не заканчивается до конца теста
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := New(inner, Options{Threshold: 3, Window: 20 * time.Millisecond, MaxBatch: 4, Clock: clk})
+	post := &models.Post{ID: uuid.New().String(), Title: "Пост", AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(ctx, post))
+	newComment := func(postID string) *models.Comment {
+		return &models.Comment{ID: uuid.New().String(), PostID: postID, AuthorID: "author", Content: uuid.New().String(), CreatedAt: time.Now()}
+	}
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, store.CreateComment(ctx, newComment(post.ID)))
+	}
+	assert.Equal(t, 2, inner.single, "До порога комментарии вставляются сразу")
+
+	comments := []*models.Comment{newComment(post.ID), newComment(post.ID), newComment(uuid.New().String()), newComment(post.ID), newComment(post.ID)}
+	errs := make([]error, len(comments))
+	var wg sync.WaitGroup
+	for i, comment := range comments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = store.CreateComment(ctx, comment)
+		}()
+	}
+	// Пачка из MaxBatch пишется сразу, а остаток ждёт окна: часы сдвигаются, когда пришли все вставки
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		waiting := len(store.pending)
+		store.mu.Unlock()
+		inner.mu.Lock()
+		defer inner.mu.Unlock()
+		return inner.batched+waiting == len(comments)
+	}, time.Second, time.Millisecond)
+	clk.Advance(20 * time.Millisecond)
+	wg.Wait()
+
+	assert.Equal(t, 2, inner.single)
+	assert.Equal(t, len(comments), inner.batched, "Во время шторма вставки объединяются в пачки")
+	for i, err := range errs {
+		if i == 2 {
+			assert.ErrorIs(t, err, storage.ErrPostNotFound, "Каждый получает результат своей вставки")
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	page, err := store.GetComments(ctx, post.ID, nil, 10, nil, models.SortAsc)
+	require.NoError(t, err)
+	assert.Len(t, page.Comments, 6)
+}
+
+func TestCoalesce_Canceled(t *testing.T) {
+	inner := &countingStorage{Storage: memory.New()}
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := New(inner, Options{Threshold: 1, Window: 20 * time.Millisecond, MaxBatch: 10, Clock: clk})
+	post := &models.Post{ID: uuid.New().String(), Title: "Пост", AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(context.Background(), post))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- store.CreateComment(ctx, &models.Comment{ID: uuid.New().String(), PostID: post.ID, AuthorID: "author", Content: "Текст", CreatedAt: time.Now()})
+	}()
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.pending) == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled, "Вызывающий не ждёт окна после отмены контекста")
+
+	clk.Advance(20 * time.Millisecond)
+	page, err := store.GetComments(context.Background(), post.ID, nil, 10, nil, models.SortAsc)
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments, "Отменённая вставка убирается из пачки")
+	assert.Zero(t, inner.batched)
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// ErrInvalidCursor возвращается для курсора, который не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrCursorOrderMismatch возвращается, если курсор выдан для другого порядка сортировки
+var ErrCursorOrderMismatch = errors.New("cursor was issued for a different sort order")
+
+// ErrOffsetTooLarge возвращается для постраничного курсора со смещением больше MaxOffset
+var ErrOffsetTooLarge = errors.New("page offset is too large")
+
+// MaxOffset - наибольшее смещение постраничного курсора. Для OFFSET база читает и отбрасывает
+// все предыдущие строки, поэтому глубокие страницы доступны только переходом по nextCursor
+const MaxOffset = 10000
+
+// offsetMarker отличает постраничный курсор от курсора по ключу
+const offsetMarker = "offset"
+
+// Cursor - позиция в ленте комментариев: направление сортировки и ключ последнего элемента
+// либо, для постраничного курсора, число пропускаемых элементов
+type Cursor struct {
+	Order models.SortOrder
+	// Offset - число пропускаемых элементов; больше нуля только у постраничного курсора, ключ у него пустой
+	Offset int
+	// Score - оценка последнего элемента; используется только для порядка BEST
+	Score     float64
+	CreatedAt time.Time
+	ID        string
+}
+
+// EncodeCursor кодирует позицию после элемента (createdAt, id) для порядка order
+func EncodeCursor(order models.SortOrder, createdAt time.Time, id string) string {
+	raw := string(order) + "|" + strconv.FormatInt(createdAt.UnixNano(), 10) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// EncodeBestCursor кодирует позицию после элемента (score, createdAt, id) для порядка BEST
+func EncodeBestCursor(score float64, createdAt time.Time, id string) string {
+	return EncodeCursor(models.SortBest, createdAt, strconv.FormatFloat(score, 'g', -1, 64)+"|"+id)
+}
+
+// EncodeOffsetCursor кодирует позицию после первых offset элементов для порядка order.
+// Используется для перехода к произвольной странице, последовательный обход идёт по nextCursor
+func EncodeOffsetCursor(order models.SortOrder, offset int) string {
+	raw := string(order) + "|" + offsetMarker + "|" + strconv.Itoa(offset)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor разбирает курсор и проверяет, что он выдан для порядка order
+func DecodeCursor(cursor string, order models.SortOrder) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	if parts[1] == offsetMarker {
+		return decodeOffsetCursor(models.SortOrder(parts[0]), parts[2], order)
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{
+		Order:     models.SortOrder(parts[0]),
+		CreatedAt: time.Unix(0, nanos).UTC(),
+		ID:        parts[2],
+	}
+	switch c.Order {
+	case models.SortAsc, models.SortDesc:
+	case models.SortBest:
+		score, id, ok := strings.Cut(c.ID, "|")
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		if c.Score, err = strconv.ParseFloat(score, 64); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		c.ID = id
+	default:
+		return nil, ErrInvalidCursor
+	}
+	if c.Order != order {
+		return nil, ErrCursorOrderMismatch
+	}
+	return c, nil
+}
+
+func decodeOffsetCursor(cursorOrder models.SortOrder, offset string, order models.SortOrder) (*Cursor, error) {
+	n, err := strconv.Atoi(offset)
+	if err != nil || n < 1 {
+		return nil, ErrInvalidCursor
+	}
+	switch cursorOrder {
+	case models.SortAsc, models.SortDesc, models.SortBest:
+	default:
+		return nil, ErrInvalidCursor
+	}
+	if n > MaxOffset {
+		return nil, ErrOffsetTooLarge
+	}
+	if cursorOrder != order {
+		return nil, ErrCursorOrderMismatch
+	}
+	return &Cursor{Order: cursorOrder, Offset: n}, nil
+}
+
+// After сообщает, находится ли элемент (createdAt, id) после курсора в его порядке сортировки
+func (c *Cursor) After(createdAt time.Time, id string) bool {
+	if c.Order == models.SortAsc {
+		return createdAt.After(c.CreatedAt) || (createdAt.Equal(c.CreatedAt) && id > c.ID)
+	}
+	return createdAt.Before(c.CreatedAt) || (createdAt.Equal(c.CreatedAt) && id < c.ID)
+}
+
+// AfterComment сообщает, находится ли комментарий после курсора; для BEST сравнивается
+// оценка WilsonScore, затем время создания и ID по убыванию
+func (c *Cursor) AfterComment(comment *models.Comment) bool {
+	if c.Order != models.SortBest {
+		return c.After(comment.CreatedAt, comment.ID)
+	}
+	score := WilsonScore(comment.Upvotes, comment.Downvotes)
+	if score != c.Score {
+		return score < c.Score
+	}
+	return c.After(comment.CreatedAt, comment.ID)
+}
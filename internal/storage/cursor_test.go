@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC)
+	encoded := EncodeCursor(models.SortAsc, createdAt, "comment1")
+
+	decoded, err := DecodeCursor(encoded, models.SortAsc)
+	assert.NoError(t, err)
+	assert.Equal(t, models.SortAsc, decoded.Order)
+	assert.True(t, createdAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, "comment1", decoded.ID)
+
+	assert.True(t, decoded.After(createdAt.Add(time.Second), "comment0"))
+	assert.True(t, decoded.After(createdAt, "comment2"), "При равном времени сравнивается ID")
+	assert.False(t, decoded.After(createdAt, "comment1"))
+
+	_, err = DecodeCursor(encoded, models.SortDesc)
+	assert.ErrorIs(t, err, ErrCursorOrderMismatch)
+
+	_, err = DecodeCursor("2024-05-01 12:00:00 +0000 UTC", models.SortDesc)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestOffsetCursor(t *testing.T) {
+	decoded, err := DecodeCursor(EncodeOffsetCursor(models.SortBest, 40), models.SortBest)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, decoded.Offset)
+	assert.Empty(t, decoded.ID)
+
+	_, err = DecodeCursor(EncodeOffsetCursor(models.SortAsc, 40), models.SortDesc)
+	assert.ErrorIs(t, err, ErrCursorOrderMismatch)
+	_, err = DecodeCursor(EncodeOffsetCursor(models.SortDesc, 0), models.SortDesc)
+	assert.ErrorIs(t, err, ErrInvalidCursor, "Нулевое смещение не кодируется курсором")
+	_, err = DecodeCursor(EncodeOffsetCursor(models.SortDesc, MaxOffset+1), models.SortDesc)
+	assert.ErrorIs(t, err, ErrOffsetTooLarge)
+}
+
+func TestBestCursor(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	score := WilsonScore(3, 1)
+	decoded, err := DecodeCursor(EncodeBestCursor(score, createdAt, "comment1"), models.SortBest)
+	assert.NoError(t, err)
+	assert.Equal(t, score, decoded.Score, "Оценка восстанавливается без потери точности")
+	assert.Equal(t, "comment1", decoded.ID)
+
+	assert.True(t, decoded.AfterComment(&models.Comment{ID: "comment9", Upvotes: 1, CreatedAt: createdAt}), "Меньшая оценка идёт после курсора")
+	assert.False(t, decoded.AfterComment(&models.Comment{ID: "comment0", Upvotes: 30, CreatedAt: createdAt}))
+	assert.True(t, decoded.AfterComment(&models.Comment{ID: "comment0", Upvotes: 3, Downvotes: 1, CreatedAt: createdAt}), "При равной оценке сравниваются время и ID")
+
+	_, err = DecodeCursor(EncodeCursor(models.SortBest, createdAt, "comment1"), models.SortBest)
+	assert.ErrorIs(t, err, ErrInvalidCursor, "Курсор BEST без оценки некорректен")
+}
+
+func TestWilsonScore(t *testing.T) {
+	assert.Equal(t, 0.0, WilsonScore(0, 0))
+	assert.Greater(t, WilsonScore(10, 0), WilsonScore(1, 0), "Больше голосов - выше уверенность")
+	assert.Greater(t, WilsonScore(90, 10), WilsonScore(5, 0))
+	assert.Less(t, WilsonScore(1, 9), WilsonScore(1, 1))
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// DuplicateCommentError возвращается из CreateComment, если такой же комментарий
+// (автор, пост, родитель, содержимое) уже был создан в пределах окна дедупликации
+type DuplicateCommentError struct {
+	Existing *models.Comment
+}
+
+func (e *DuplicateCommentError) Error() string {
+	return "duplicate comment: " + e.Existing.ID
+}
+
+// ContentHash возвращает sha256 содержимого комментария в hex
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// CommentHash возвращает хэш содержимого комментария для дедупликации: ContentHash, если он задан,
+// иначе ContentHash(Content)
+func CommentHash(comment *models.Comment) string {
+	if comment.ContentHash != "" {
+		return comment.ContentHash
+	}
+	return ContentHash(comment.Content)
+}
+
+// IsDuplicate сообщает, является ли candidate повтором existing в пределах окна window
+func IsDuplicate(existing, candidate *models.Comment, window time.Duration) bool {
+	if window <= 0 || existing.AuthorID != candidate.AuthorID || existing.PostID != candidate.PostID {
+		return false
+	}
+	if (existing.ParentID == nil) != (candidate.ParentID == nil) {
+		return false
+	}
+	if existing.ParentID != nil && *existing.ParentID != *candidate.ParentID {
+		return false
+	}
+	if CommentHash(existing) != CommentHash(candidate) {
+		return false
+	}
+	age := candidate.CreatedAt.Sub(existing.CreatedAt)
+	return age >= 0 && age <= window
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	parent := "c1"
+	otherParent := "c2"
+	base := time.Now()
+	existing := &models.Comment{ID: "1", PostID: "p", AuthorID: "u", ParentID: &parent, Content: "привет", CreatedAt: base}
+	candidate := func(mutate func(c *models.Comment)) *models.Comment {
+		c := &models.Comment{PostID: "p", AuthorID: "u", ParentID: &parent, Content: "привет", CreatedAt: base.Add(2 * time.Second)}
+		mutate(c)
+		return c
+	}
+
+	assert.True(t, IsDuplicate(existing, candidate(func(c *models.Comment) {}), 5*time.Second))
+	assert.False(t, IsDuplicate(existing, candidate(func(c *models.Comment) {}), 0), "Нулевое окно отключает дедупликацию")
+	assert.False(t, IsDuplicate(existing, candidate(func(c *models.Comment) { c.CreatedAt = base.Add(10 * time.Second) }), 5*time.Second))
+	assert.False(t, IsDuplicate(existing, candidate(func(c *models.Comment) { c.Content = "пока" }), 5*time.Second))
+	assert.False(t, IsDuplicate(existing, candidate(func(c *models.Comment) { c.AuthorID = "u2" }), 5*time.Second))
+	assert.False(t, IsDuplicate(existing, candidate(func(c *models.Comment) { c.ParentID = &otherParent }), 5*time.Second))
+	assert.False(t, IsDuplicate(existing, candidate(func(c *models.Comment) { c.ParentID = nil }), 5*time.Second))
+}
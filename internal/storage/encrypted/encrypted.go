@@ -0,0 +1,381 @@
+// Package encrypted - обёртка над хранилищем, которая хранит содержимое постов и комментариев
+// в зашифрованном виде. Анонсы постов шифруются так же, как содержимое, но Reencrypt их не перешифровывает:
+// анонс, который не удалось расшифровать, читается пустым. Заголовки, теги и остальные поля не шифруются.
+//
+// Хранилище видит только шифротекст, поэтому поиск ListPosts по тексту находит посты лишь по заголовку.
+// Для дедупликации комментариев хранилище получает хэш открытого текста в Comment.ContentHash
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/encryption"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Storage шифрует содержимое при записи и расшифровывает при чтении.
+// Значения, записанные до включения шифрования, читаются как есть, пока их не перешифрует Reencrypt
+type Storage struct {
+	storage.Storage
+	envelope *encryption.Envelope
+}
+
+var _ storage.Storage = &Storage{}
+
+// New оборачивает хранилище шифрованием содержимого
+func New(store storage.Storage, envelope *encryption.Envelope) *Storage {
+	return &Storage{Storage: store, envelope: envelope}
+}
+
+// CreatePost сохраняет пост с зашифрованным содержимым; post сохраняет открытый текст
+func (s *Storage) CreatePost(ctx context.Context, post *models.Post) error {
+	content, err := s.envelope.Encrypt(post.Content)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt post content: %v", err)
+	}
+	excerpt, err := s.encryptExcerpt(post.Excerpt)
+	if err != nil {
+		return err
+	}
+	// Хранилище получает копию: memory сохраняет сам указатель, а вызывающему нужен открытый текст
+	stored := *post
+	stored.Content = content
+	stored.Excerpt = excerpt
+	err = s.Storage.CreatePost(ctx, &stored)
+	plaintext, plainExcerpt := post.Content, post.Excerpt
+	*post = stored
+	post.Content = plaintext
+	post.Excerpt = plainExcerpt
+	return err
+}
+
+// SetPostExcerpt сохраняет зашифрованный анонс поста
+func (s *Storage) SetPostExcerpt(ctx context.Context, postID, excerpt string) error {
+	encrypted, err := s.encryptExcerpt(excerpt)
+	if err != nil {
+		return err
+	}
+	return s.Storage.SetPostExcerpt(ctx, postID, encrypted)
+}
+
+// encryptExcerpt шифрует анонс; пустой анонс остаётся пустым, чтобы его можно было отличить от заполненного
+func (s *Storage) encryptExcerpt(excerpt string) (string, error) {
+	if excerpt == "" {
+		return "", nil
+	}
+	encrypted, err := s.envelope.Encrypt(excerpt)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt post excerpt: %v", err)
+	}
+	return encrypted, nil
+}
+
+// CreateComment сохраняет комментарий с зашифрованным содержимым; comment сохраняет открытый текст
+func (s *Storage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	content, err := s.envelope.Encrypt(comment.Content)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt comment content: %v", err)
+	}
+	stored := *comment
+	stored.Content = content
+	stored.ContentHash = storage.CommentHash(comment)
+	err = s.Storage.CreateComment(ctx, &stored)
+	plaintext := comment.Content
+	*comment = stored
+	comment.Content = plaintext
+	return s.decryptDuplicate(err)
+}
+
+// CreateComments сохраняет пачку комментариев с зашифрованным содержимым; комментарии сохраняют открытый текст
+func (s *Storage) CreateComments(ctx context.Context, comments []*models.Comment) []error {
+	errs := make([]error, len(comments))
+	stored := make([]*models.Comment, 0, len(comments))
+	positions := make([]int, 0, len(comments))
+	for i, comment := range comments {
+		content, err := s.envelope.Encrypt(comment.Content)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to encrypt comment content: %v", err)
+			continue
+		}
+		copied := *comment
+		copied.Content = content
+		copied.ContentHash = storage.CommentHash(comment)
+		stored = append(stored, &copied)
+		positions = append(positions, i)
+	}
+	if len(stored) == 0 {
+		return errs
+	}
+	results := s.Storage.CreateComments(ctx, stored)
+	for j, i := range positions {
+		plaintext := comments[i].Content
+		*comments[i] = *stored[j]
+		comments[i].Content = plaintext
+		errs[i] = s.decryptDuplicate(results[j])
+	}
+	return errs
+}
+
+// decryptDuplicate расшифровывает существующий комментарий в ошибке повтора; другие ошибки возвращаются как есть
+func (s *Storage) decryptDuplicate(err error) error {
+	var duplicate *storage.DuplicateCommentError
+	if errors.As(err, &duplicate) {
+		existing, decryptErr := s.comment(duplicate.Existing)
+		if decryptErr != nil {
+			return decryptErr
+		}
+		return &storage.DuplicateCommentError{Existing: existing}
+	}
+	return err
+}
+
+func (s *Storage) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	return s.decryptPost(s.Storage.GetPost(ctx, id))
+}
+
+func (s *Storage) GetPostBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	return s.decryptPost(s.Storage.GetPostBySlug(ctx, slug))
+}
+
+func (s *Storage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	return s.decryptPost(s.Storage.UpdatePostTitle(ctx, postID, title, slugBase))
+}
+
+func (s *Storage) ListPosts(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (*models.PaginatedPosts, error) {
+	page, err := s.Storage.ListPosts(ctx, limit, cursor, filter)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := *page
+	if decrypted.Posts, err = s.posts(page.Posts); err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+func (s *Storage) RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	posts, err := s.Storage.RelatedPosts(ctx, postID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.posts(posts)
+}
+
+func (s *Storage) SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]storage.SimilarPost, error) {
+	similar, err := s.Storage.SimilarPosts(ctx, title, viewerID, threshold, limit)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]storage.SimilarPost, len(similar))
+	for i, p := range similar {
+		post, err := s.post(p.Post)
+		if err != nil {
+			return nil, err
+		}
+		decrypted[i] = storage.SimilarPost{Post: post, Similarity: p.Similarity}
+	}
+	return decrypted, nil
+}
+
+func (s *Storage) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	return s.decryptComment(s.Storage.GetComment(ctx, id))
+}
+
+func (s *Storage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error) {
+	comments, err := s.Storage.GetCommentsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make(map[string]*models.Comment, len(comments))
+	for id, comment := range comments {
+		if decrypted[id], err = s.comment(comment); err != nil {
+			return nil, err
+		}
+	}
+	return decrypted, nil
+}
+
+func (s *Storage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string, order models.SortOrder) (*models.PaginatedComments, error) {
+	page, err := s.Storage.GetComments(ctx, postID, parentID, limit, cursor, order)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.comments(page.Comments)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := *page
+	decrypted.Comments = comments
+	return &decrypted, nil
+}
+
+func (s *Storage) VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, error) {
+	return s.decryptComment(s.Storage.VoteComment(ctx, vote))
+}
+
+func (s *Storage) GetDigestComments(ctx context.Context, userID string, since, until time.Time, limit int) ([]models.Comment, error) {
+	comments, err := s.Storage.GetDigestComments(ctx, userID, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.comments(comments)
+}
+
+func (s *Storage) ListCommentsBySpamStatus(ctx context.Context, status string, limit int) ([]models.Comment, error) {
+	comments, err := s.Storage.ListCommentsBySpamStatus(ctx, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.comments(comments)
+}
+
+func (s *Storage) ListPendingComments(ctx context.Context, postID string, limit int) ([]models.Comment, error) {
+	comments, err := s.Storage.ListPendingComments(ctx, postID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.comments(comments)
+}
+
+func (s *Storage) IteratePosts(ctx context.Context, fn func(post *models.Post) error) error {
+	return s.Storage.IteratePosts(ctx, func(post *models.Post) error {
+		decrypted, err := s.post(post)
+		if err != nil {
+			return err
+		}
+		return fn(decrypted)
+	})
+}
+
+func (s *Storage) IterateComments(ctx context.Context, postID string, fn func(comment *models.Comment) error) error {
+	return s.Storage.IterateComments(ctx, postID, func(comment *models.Comment) error {
+		decrypted, err := s.comment(comment)
+		if err != nil {
+			return err
+		}
+		return fn(decrypted)
+	})
+}
+
+// decryptPost расшифровывает результат чтения поста из обёрнутого хранилища
+func (s *Storage) decryptPost(post *models.Post, err error) (*models.Post, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s.post(post)
+}
+
+// decryptComment расшифровывает результат чтения комментария из обёрнутого хранилища
+func (s *Storage) decryptComment(comment *models.Comment, err error) (*models.Comment, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s.comment(comment)
+}
+
+// post возвращает копию поста с открытым содержимым; memory отдаёт свои записи, менять их нельзя
+func (s *Storage) post(post *models.Post) (*models.Post, error) {
+	content, err := s.envelope.Decrypt(post.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt post %s: %w", post.ID, err)
+	}
+	decrypted := *post
+	decrypted.Content = content
+	// Анонс можно построить заново из содержимого, поэтому ошибка его расшифровки не ломает чтение поста
+	if decrypted.Excerpt, err = s.envelope.Decrypt(post.Excerpt); err != nil {
+		log.Printf("Анонс поста %s не расшифрован: %v", post.ID, err)
+		decrypted.Excerpt = ""
+	}
+	return &decrypted, nil
+}
+
+// posts возвращает новый срез копий постов с открытым содержимым
+func (s *Storage) posts(posts []*models.Post) ([]*models.Post, error) {
+	decrypted := make([]*models.Post, len(posts))
+	for i, post := range posts {
+		var err error
+		if decrypted[i], err = s.post(post); err != nil {
+			return nil, err
+		}
+	}
+	return decrypted, nil
+}
+
+// comment возвращает копию комментария с открытым содержимым
+func (s *Storage) comment(comment *models.Comment) (*models.Comment, error) {
+	content, err := s.envelope.Decrypt(comment.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt comment %s: %w", comment.ID, err)
+	}
+	decrypted := *comment
+	decrypted.Content = content
+	return &decrypted, nil
+}
+
+// comments возвращает новый срез комментариев с открытым содержимым
+func (s *Storage) comments(comments []models.Comment) ([]models.Comment, error) {
+	decrypted := make([]models.Comment, len(comments))
+	for i := range comments {
+		comment, err := s.comment(&comments[i])
+		if err != nil {
+			return nil, err
+		}
+		decrypted[i] = *comment
+	}
+	return decrypted, nil
+}
+
+// ReencryptResult - итог перешифрования
+type ReencryptResult struct {
+	// Rotated - сколько значений зашифровано основным ключом
+	Rotated int
+	// Skipped - сколько значений пропущено: изменились во время работы или не расшифровываются
+	Skipped int
+}
+
+// Reencrypt переводит содержимое всех постов и комментариев на основной ключ: шифрует значения,
+// записанные до включения шифрования, и заново оборачивает ключи данных значений под прежними ключами.
+// Обходит записи пачками по batchSize; значение, которое не удалось расшифровать, пропускается с записью в лог.
+// После успешного завершения прежние ключи можно удалить из набора
+func (s *Storage) Reencrypt(ctx context.Context, batchSize int) (ReencryptResult, error) {
+	var result ReencryptResult
+	for _, kind := range []string{models.TargetPost, models.TargetComment} {
+		afterID := ""
+		for {
+			records, err := s.Storage.ListContents(ctx, kind, afterID, batchSize)
+			if err != nil {
+				return result, err
+			}
+			for _, record := range records {
+				if !s.envelope.NeedsRotation(record.Content) {
+					continue
+				}
+				content, err := s.envelope.Rotate(record.Content)
+				if err != nil {
+					log.Printf("Не удалось перешифровать %s %s: %v", kind, record.ID, err)
+					result.Skipped++
+					continue
+				}
+				replaced, err := s.Storage.ReplaceContent(ctx, kind, record.ID, record.Content, content)
+				if err != nil {
+					return result, err
+				}
+				if !replaced {
+					result.Skipped++
+					continue
+				}
+				result.Rotated++
+			}
+			if len(records) < batchSize {
+				break
+			}
+			afterID = records[len(records)-1].ID
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,155 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/encryption"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEnvelope создаёт Envelope с ключами ids; ключ определяется своим ID, поэтому одинаков во всех наборах
+func newEnvelope(t *testing.T, primary string, ids ...string) *encryption.Envelope {
+	keyring := &encryption.Keyring{Primary: primary, Keys: map[string][]byte{}}
+	for _, id := range ids {
+		keyring.Keys[id] = bytes.Repeat([]byte(id[len(id)-1:]), 32)
+	}
+	envelope, err := encryption.New(keyring)
+	require.NoError(t, err)
+	return envelope
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	raw := memory.New()
+	store := New(raw, newEnvelope(t, "k1", "k1"))
+
+	post := &models.Post{ID: "p1", Title: "Пост", Content: "Секретный пост", Excerpt: "Секретный", AuthorID: "user1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(ctx, post))
+	assert.Equal(t, "Секретный пост", post.Content, "Вызывающий получает открытый текст")
+	assert.Equal(t, "Секретный", post.Excerpt)
+	assert.Equal(t, "p1", post.Slug)
+
+	stored, err := raw.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.True(t, encryption.IsEncrypted(stored.Content), "В хранилище попадает шифротекст")
+	assert.True(t, encryption.IsEncrypted(stored.Excerpt), "Анонс шифруется вместе с содержимым")
+
+	got, err := store.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "Секретный пост", got.Content)
+	assert.Equal(t, "Секретный", got.Excerpt)
+
+	require.NoError(t, store.SetPostExcerpt(ctx, "p1", "Краткое содержание"))
+	stored, err = raw.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.True(t, encryption.IsEncrypted(stored.Excerpt))
+	got, err = store.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "Краткое содержание", got.Excerpt)
+	stored, err = raw.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.True(t, encryption.IsEncrypted(stored.Content), "Расшифровка не меняет записи хранилища")
+
+	page, err := store.ListPosts(ctx, 10, nil, storage.PostFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Posts, 1)
+	assert.Equal(t, "Секретный пост", page.Posts[0].Content)
+
+	comment := &models.Comment{ID: "c1", PostID: "p1", AuthorID: "user2", Content: "Секретный комментарий", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateComment(ctx, comment))
+	assert.Equal(t, "Секретный комментарий", comment.Content)
+
+	comments, err := store.GetComments(ctx, "p1", nil, 10, nil, models.SortDesc)
+	require.NoError(t, err)
+	require.Len(t, comments.Comments, 1)
+	assert.Equal(t, "Секретный комментарий", comments.Comments[0].Content)
+
+	voted, err := store.VoteComment(ctx, &models.Vote{CommentID: "c1", UserID: "user1", Value: 1, CreatedAt: time.Now()})
+	require.NoError(t, err)
+	assert.Equal(t, "Секретный комментарий", voted.Content)
+}
+
+// duplicateStorage отвечает на любой новый комментарий повтором existing
+type duplicateStorage struct {
+	storage.Storage
+	existing *models.Comment
+}
+
+func (s *duplicateStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	return &storage.DuplicateCommentError{Existing: s.existing}
+}
+
+func TestStorage_DuplicateCommentIsDecrypted(t *testing.T) {
+	envelope := newEnvelope(t, "k1", "k1")
+	content, err := envelope.Encrypt("Привет")
+	require.NoError(t, err)
+	store := New(&duplicateStorage{Storage: memory.New(), existing: &models.Comment{ID: "c1", Content: content}}, envelope)
+
+	err = store.CreateComment(context.Background(), &models.Comment{ID: "c2", Content: "Привет"})
+	var duplicate *storage.DuplicateCommentError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, "c1", duplicate.Existing.ID)
+	assert.Equal(t, "Привет", duplicate.Existing.Content)
+}
+
+func TestStorage_Dedupe(t *testing.T) {
+	raw := memory.New()
+	raw.SetDedupeWindow(time.Minute)
+	store := New(raw, newEnvelope(t, "k1", "k1"))
+	ctx := context.Background()
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "p1", Title: "Пост", Content: "Текст", AuthorID: "user1", CreatedAt: time.Now()}))
+
+	now := time.Now()
+	require.NoError(t, store.CreateComment(ctx, &models.Comment{ID: "c1", PostID: "p1", AuthorID: "user2", Content: "Привет", CreatedAt: now}))
+	err := store.CreateComment(ctx, &models.Comment{ID: "c2", PostID: "p1", AuthorID: "user2", Content: "Привет", CreatedAt: now.Add(time.Second)})
+	var duplicate *storage.DuplicateCommentError
+	require.True(t, errors.As(err, &duplicate), "Шифротексты разные, но повтор распознаётся по хэшу открытого текста")
+	assert.Equal(t, "c1", duplicate.Existing.ID)
+	assert.Equal(t, "Привет", duplicate.Existing.Content)
+
+	errs := store.CreateComments(ctx, []*models.Comment{{ID: "c3", PostID: "p1", AuthorID: "user2", Content: "Привет", CreatedAt: now.Add(2 * time.Second)}})
+	assert.True(t, errors.As(errs[0], &duplicate))
+	assert.NoError(t, store.CreateComment(ctx, &models.Comment{ID: "c4", PostID: "p1", AuthorID: "user2", Content: "Пока", CreatedAt: now.Add(3 * time.Second)}))
+}
+
+func TestStorage_Reencrypt(t *testing.T) {
+	ctx := context.Background()
+	raw := memory.New()
+	require.NoError(t, raw.CreatePost(ctx, &models.Post{ID: "p1", Content: "Открытый пост", CreatedAt: time.Now()}))
+	old := New(raw, newEnvelope(t, "k1", "k1"))
+	for _, id := range []string{"c1", "c2", "c3"} {
+		require.NoError(t, old.CreateComment(ctx, &models.Comment{ID: id, PostID: "p1", AuthorID: "user1", Content: "Комментарий " + id, CreatedAt: time.Now()}))
+	}
+	require.NoError(t, raw.CreateComment(ctx, &models.Comment{ID: "c4", PostID: "p1", AuthorID: "user1", Content: "enc1:k9:мусор", CreatedAt: time.Now()}))
+
+	store := New(raw, newEnvelope(t, "k2", "k1", "k2"))
+	result, err := store.Reencrypt(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, ReencryptResult{Rotated: 4, Skipped: 1}, result, "Нерасшифровываемое значение пропускается")
+
+	records, err := raw.ListContents(ctx, models.TargetComment, "", 10)
+	require.NoError(t, err)
+	for _, record := range records[:3] {
+		assert.Equal(t, "k2", encryption.KeyID(record.Content))
+	}
+
+	result, err = store.Reencrypt(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, ReencryptResult{Skipped: 1}, result, "Повторный запуск ничего не меняет")
+
+	current := New(raw, newEnvelope(t, "k2", "k2"))
+	post, err := current.GetPost(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "Открытый пост", post.Content)
+	comment, err := current.GetComment(ctx, "c2")
+	require.NoError(t, err)
+	assert.Equal(t, "Комментарий c2", comment.Content)
+}
@@ -0,0 +1,685 @@
+// Package faulty - обёртка над хранилищем, которая внедряет ошибки и задержки в каждый вызов,
+// кроме Close, для проверки устойчивости вне production
+package faulty
+
+import (
+	"context"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/faults"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Storage перед обращением к хранилищу вызывает faults.Injector с именем метода в качестве операции
+type Storage struct {
+	storage.Storage
+	faults *faults.Injector
+}
+
+var _ storage.Storage = &Storage{}
+
+// New оборачивает хранилище внедрением сбоев
+func New(store storage.Storage, injector *faults.Injector) *Storage {
+	return &Storage{Storage: store, faults: injector}
+}
+
+// CreatePost реализует storage.Storage
+func (s *Storage) CreatePost(ctx context.Context, post *models.Post) error {
+	if err := s.faults.Inject(ctx, "CreatePost"); err != nil {
+		return err
+	}
+	return s.Storage.CreatePost(ctx, post)
+}
+
+// GetPost реализует storage.Storage
+func (s *Storage) GetPost(ctx context.Context, id string) (*models.Post, error) {
+	if err := s.faults.Inject(ctx, "GetPost"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetPost(ctx, id)
+}
+
+// GetPostBySlug реализует storage.Storage
+func (s *Storage) GetPostBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	if err := s.faults.Inject(ctx, "GetPostBySlug"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetPostBySlug(ctx, slug)
+}
+
+// ShortIDs реализует storage.Storage
+func (s *Storage) ShortIDs(ctx context.Context, kind string, ids []string) (map[string]int64, error) {
+	if err := s.faults.Inject(ctx, "ShortIDs"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ShortIDs(ctx, kind, ids)
+}
+
+// ResolveShortID реализует storage.Storage
+func (s *Storage) ResolveShortID(ctx context.Context, seq int64) (string, string, error) {
+	if err := s.faults.Inject(ctx, "ResolveShortID"); err != nil {
+		return "", "", err
+	}
+	return s.Storage.ResolveShortID(ctx, seq)
+}
+
+// UpdatePostTitle реализует storage.Storage
+func (s *Storage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	if err := s.faults.Inject(ctx, "UpdatePostTitle"); err != nil {
+		return nil, err
+	}
+	return s.Storage.UpdatePostTitle(ctx, postID, title, slugBase)
+}
+
+// ListPosts реализует storage.Storage
+func (s *Storage) ListPosts(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (*models.PaginatedPosts, error) {
+	if err := s.faults.Inject(ctx, "ListPosts"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListPosts(ctx, limit, cursor, filter)
+}
+
+// RelatedPosts реализует storage.Storage
+func (s *Storage) RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	if err := s.faults.Inject(ctx, "RelatedPosts"); err != nil {
+		return nil, err
+	}
+	return s.Storage.RelatedPosts(ctx, postID, limit)
+}
+
+// SimilarPosts реализует storage.Storage
+func (s *Storage) SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]storage.SimilarPost, error) {
+	if err := s.faults.Inject(ctx, "SimilarPosts"); err != nil {
+		return nil, err
+	}
+	return s.Storage.SimilarPosts(ctx, title, viewerID, threshold, limit)
+}
+
+// SavePostReferences реализует storage.Storage
+func (s *Storage) SavePostReferences(ctx context.Context, postID string, targetIDs []string) error {
+	if err := s.faults.Inject(ctx, "SavePostReferences"); err != nil {
+		return err
+	}
+	return s.Storage.SavePostReferences(ctx, postID, targetIDs)
+}
+
+// SetPostRequireApproval реализует storage.Storage
+func (s *Storage) SetPostRequireApproval(ctx context.Context, postID string, required bool) error {
+	if err := s.faults.Inject(ctx, "SetPostRequireApproval"); err != nil {
+		return err
+	}
+	return s.Storage.SetPostRequireApproval(ctx, postID, required)
+}
+
+// SetPostExcerpt реализует storage.Storage
+func (s *Storage) SetPostExcerpt(ctx context.Context, postID, excerpt string) error {
+	if err := s.faults.Inject(ctx, "SetPostExcerpt"); err != nil {
+		return err
+	}
+	return s.Storage.SetPostExcerpt(ctx, postID, excerpt)
+}
+
+// SetPostCategory реализует storage.Storage
+func (s *Storage) SetPostCategory(ctx context.Context, postID string, categoryID *string) error {
+	if err := s.faults.Inject(ctx, "SetPostCategory"); err != nil {
+		return err
+	}
+	return s.Storage.SetPostCategory(ctx, postID, categoryID)
+}
+
+// CreateCategory реализует storage.Storage
+func (s *Storage) CreateCategory(ctx context.Context, category *models.Category) error {
+	if err := s.faults.Inject(ctx, "CreateCategory"); err != nil {
+		return err
+	}
+	return s.Storage.CreateCategory(ctx, category)
+}
+
+// GetCategory реализует storage.Storage
+func (s *Storage) GetCategory(ctx context.Context, id string) (*models.Category, error) {
+	if err := s.faults.Inject(ctx, "GetCategory"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetCategory(ctx, id)
+}
+
+// ListCategories реализует storage.Storage
+func (s *Storage) ListCategories(ctx context.Context) ([]models.Category, error) {
+	if err := s.faults.Inject(ctx, "ListCategories"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListCategories(ctx)
+}
+
+// CreateComment реализует storage.Storage
+func (s *Storage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	if err := s.faults.Inject(ctx, "CreateComment"); err != nil {
+		return err
+	}
+	return s.Storage.CreateComment(ctx, comment)
+}
+
+// CreateComments реализует storage.Storage; внедрённая ошибка возвращается для каждого комментария
+func (s *Storage) CreateComments(ctx context.Context, comments []*models.Comment) []error {
+	if err := s.faults.Inject(ctx, "CreateComments"); err != nil {
+		errs := make([]error, len(comments))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	return s.Storage.CreateComments(ctx, comments)
+}
+
+// GetComment реализует storage.Storage
+func (s *Storage) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	if err := s.faults.Inject(ctx, "GetComment"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetComment(ctx, id)
+}
+
+// GetCommentsByIDs реализует storage.Storage
+func (s *Storage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error) {
+	if err := s.faults.Inject(ctx, "GetCommentsByIDs"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetCommentsByIDs(ctx, ids)
+}
+
+// GetComments реализует storage.Storage
+func (s *Storage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string, order models.SortOrder) (*models.PaginatedComments, error) {
+	if err := s.faults.Inject(ctx, "GetComments"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetComments(ctx, postID, parentID, limit, cursor, order)
+}
+
+// CountCommentsBefore реализует storage.Storage
+func (s *Storage) CountCommentsBefore(ctx context.Context, commentID string, order models.SortOrder) (int, error) {
+	if err := s.faults.Inject(ctx, "CountCommentsBefore"); err != nil {
+		return 0, err
+	}
+	return s.Storage.CountCommentsBefore(ctx, commentID, order)
+}
+
+// SaveLinkPreview реализует storage.Storage
+func (s *Storage) SaveLinkPreview(ctx context.Context, preview *models.LinkPreview) error {
+	if err := s.faults.Inject(ctx, "SaveLinkPreview"); err != nil {
+		return err
+	}
+	return s.Storage.SaveLinkPreview(ctx, preview)
+}
+
+// GetLinkPreviews реализует storage.Storage
+func (s *Storage) GetLinkPreviews(ctx context.Context, postID string) ([]models.LinkPreview, error) {
+	if err := s.faults.Inject(ctx, "GetLinkPreviews"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetLinkPreviews(ctx, postID)
+}
+
+// ToggleReaction реализует storage.Storage
+func (s *Storage) ToggleReaction(ctx context.Context, reaction *models.Reaction) (bool, error) {
+	if err := s.faults.Inject(ctx, "ToggleReaction"); err != nil {
+		return false, err
+	}
+	return s.Storage.ToggleReaction(ctx, reaction)
+}
+
+// GetReactionCounts реализует storage.Storage
+func (s *Storage) GetReactionCounts(ctx context.Context, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	if err := s.faults.Inject(ctx, "GetReactionCounts"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetReactionCounts(ctx, targetIDs)
+}
+
+// VoteComment реализует storage.Storage
+func (s *Storage) VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, error) {
+	if err := s.faults.Inject(ctx, "VoteComment"); err != nil {
+		return nil, err
+	}
+	return s.Storage.VoteComment(ctx, vote)
+}
+
+// MarkThreadRead реализует storage.Storage
+func (s *Storage) MarkThreadRead(ctx context.Context, userID, postID string, readAt time.Time) error {
+	if err := s.faults.Inject(ctx, "MarkThreadRead"); err != nil {
+		return err
+	}
+	return s.Storage.MarkThreadRead(ctx, userID, postID, readAt)
+}
+
+// GetUnreadCommentCounts реализует storage.Storage
+func (s *Storage) GetUnreadCommentCounts(ctx context.Context, userID string, postIDs []string) (map[string]int, error) {
+	if err := s.faults.Inject(ctx, "GetUnreadCommentCounts"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetUnreadCommentCounts(ctx, userID, postIDs)
+}
+
+// IncrementQuotaUsage реализует storage.Storage
+func (s *Storage) IncrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) (int, error) {
+	if err := s.faults.Inject(ctx, "IncrementQuotaUsage"); err != nil {
+		return 0, err
+	}
+	return s.Storage.IncrementQuotaUsage(ctx, userID, action, windowStart)
+}
+
+// DecrementQuotaUsage реализует storage.Storage
+func (s *Storage) DecrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) error {
+	if err := s.faults.Inject(ctx, "DecrementQuotaUsage"); err != nil {
+		return err
+	}
+	return s.Storage.DecrementQuotaUsage(ctx, userID, action, windowStart)
+}
+
+// GetPreferences реализует storage.Storage
+func (s *Storage) GetPreferences(ctx context.Context, userID string) (*models.Preferences, error) {
+	if err := s.faults.Inject(ctx, "GetPreferences"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetPreferences(ctx, userID)
+}
+
+// SavePreferences реализует storage.Storage
+func (s *Storage) SavePreferences(ctx context.Context, prefs *models.Preferences) error {
+	if err := s.faults.Inject(ctx, "SavePreferences"); err != nil {
+		return err
+	}
+	return s.Storage.SavePreferences(ctx, prefs)
+}
+
+// GetTenantSettings реализует storage.Storage
+func (s *Storage) GetTenantSettings(ctx context.Context, tenantID string) (*models.TenantSettings, error) {
+	if err := s.faults.Inject(ctx, "GetTenantSettings"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetTenantSettings(ctx, tenantID)
+}
+
+// SaveTenantSettings реализует storage.Storage
+func (s *Storage) SaveTenantSettings(ctx context.Context, settings *models.TenantSettings) error {
+	if err := s.faults.Inject(ctx, "SaveTenantSettings"); err != nil {
+		return err
+	}
+	return s.Storage.SaveTenantSettings(ctx, settings)
+}
+
+// AddTenantUsage реализует storage.Storage
+func (s *Storage) AddTenantUsage(ctx context.Context, tenantID, kind string, bytes int64) error {
+	if err := s.faults.Inject(ctx, "AddTenantUsage"); err != nil {
+		return err
+	}
+	return s.Storage.AddTenantUsage(ctx, tenantID, kind, bytes)
+}
+
+// GetTenantUsage реализует storage.Storage
+func (s *Storage) GetTenantUsage(ctx context.Context, tenantID string) (*models.TenantUsage, error) {
+	if err := s.faults.Inject(ctx, "GetTenantUsage"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetTenantUsage(ctx, tenantID)
+}
+
+// SetFollow реализует storage.Storage
+func (s *Storage) SetFollow(ctx context.Context, followerID, userID string, follow bool) error {
+	if err := s.faults.Inject(ctx, "SetFollow"); err != nil {
+		return err
+	}
+	return s.Storage.SetFollow(ctx, followerID, userID, follow)
+}
+
+// IsFollowing реализует storage.Storage
+func (s *Storage) IsFollowing(ctx context.Context, followerID, userID string) (bool, error) {
+	if err := s.faults.Inject(ctx, "IsFollowing"); err != nil {
+		return false, err
+	}
+	return s.Storage.IsFollowing(ctx, followerID, userID)
+}
+
+// CountDescendants реализует storage.Storage
+func (s *Storage) CountDescendants(ctx context.Context, commentIDs []string) (map[string]int, error) {
+	if err := s.faults.Inject(ctx, "CountDescendants"); err != nil {
+		return nil, err
+	}
+	return s.Storage.CountDescendants(ctx, commentIDs)
+}
+
+// Stats реализует storage.Storage
+func (s *Storage) Stats(ctx context.Context, slowQueries int) (*models.StorageStats, error) {
+	if err := s.faults.Inject(ctx, "Stats"); err != nil {
+		return nil, err
+	}
+	return s.Storage.Stats(ctx, slowQueries)
+}
+
+// RegisterDeviceToken реализует storage.Storage
+func (s *Storage) RegisterDeviceToken(ctx context.Context, token *models.DeviceToken) error {
+	if err := s.faults.Inject(ctx, "RegisterDeviceToken"); err != nil {
+		return err
+	}
+	return s.Storage.RegisterDeviceToken(ctx, token)
+}
+
+// UnregisterDeviceToken реализует storage.Storage
+func (s *Storage) UnregisterDeviceToken(ctx context.Context, userID, token string) error {
+	if err := s.faults.Inject(ctx, "UnregisterDeviceToken"); err != nil {
+		return err
+	}
+	return s.Storage.UnregisterDeviceToken(ctx, userID, token)
+}
+
+// ListDeviceTokens реализует storage.Storage
+func (s *Storage) ListDeviceTokens(ctx context.Context, userID string) ([]models.DeviceToken, error) {
+	if err := s.faults.Inject(ctx, "ListDeviceTokens"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListDeviceTokens(ctx, userID)
+}
+
+// SubscribeToPost реализует storage.Storage
+func (s *Storage) SubscribeToPost(ctx context.Context, userID, postID string, at time.Time) error {
+	if err := s.faults.Inject(ctx, "SubscribeToPost"); err != nil {
+		return err
+	}
+	return s.Storage.SubscribeToPost(ctx, userID, postID, at)
+}
+
+// UnsubscribeFromPost реализует storage.Storage
+func (s *Storage) UnsubscribeFromPost(ctx context.Context, userID, postID string) error {
+	if err := s.faults.Inject(ctx, "UnsubscribeFromPost"); err != nil {
+		return err
+	}
+	return s.Storage.UnsubscribeFromPost(ctx, userID, postID)
+}
+
+// ListDigestSubscribers реализует storage.Storage
+func (s *Storage) ListDigestSubscribers(ctx context.Context) ([]models.DigestSubscriber, error) {
+	if err := s.faults.Inject(ctx, "ListDigestSubscribers"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListDigestSubscribers(ctx)
+}
+
+// GetDigestComments реализует storage.Storage
+func (s *Storage) GetDigestComments(ctx context.Context, userID string, since, until time.Time, limit int) ([]models.Comment, error) {
+	if err := s.faults.Inject(ctx, "GetDigestComments"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetDigestComments(ctx, userID, since, until, limit)
+}
+
+// MarkDigestSent реализует storage.Storage
+func (s *Storage) MarkDigestSent(ctx context.Context, userID string, until time.Time) error {
+	if err := s.faults.Inject(ctx, "MarkDigestSent"); err != nil {
+		return err
+	}
+	return s.Storage.MarkDigestSent(ctx, userID, until)
+}
+
+// CreateCollection реализует storage.Storage
+func (s *Storage) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	if err := s.faults.Inject(ctx, "CreateCollection"); err != nil {
+		return err
+	}
+	return s.Storage.CreateCollection(ctx, collection)
+}
+
+// GetCollection реализует storage.Storage
+func (s *Storage) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	if err := s.faults.Inject(ctx, "GetCollection"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetCollection(ctx, id)
+}
+
+// UpdateCollection реализует storage.Storage
+func (s *Storage) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	if err := s.faults.Inject(ctx, "UpdateCollection"); err != nil {
+		return err
+	}
+	return s.Storage.UpdateCollection(ctx, collection)
+}
+
+// DeleteCollection реализует storage.Storage
+func (s *Storage) DeleteCollection(ctx context.Context, id string) error {
+	if err := s.faults.Inject(ctx, "DeleteCollection"); err != nil {
+		return err
+	}
+	return s.Storage.DeleteCollection(ctx, id)
+}
+
+// ListPostCollections реализует storage.Storage
+func (s *Storage) ListPostCollections(ctx context.Context, postID string) ([]models.Collection, error) {
+	if err := s.faults.Inject(ctx, "ListPostCollections"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListPostCollections(ctx, postID)
+}
+
+// CreateSavedSearch реализует storage.Storage
+func (s *Storage) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	if err := s.faults.Inject(ctx, "CreateSavedSearch"); err != nil {
+		return err
+	}
+	return s.Storage.CreateSavedSearch(ctx, search)
+}
+
+// ListSavedSearches реализует storage.Storage
+func (s *Storage) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	if err := s.faults.Inject(ctx, "ListSavedSearches"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListSavedSearches(ctx, userID)
+}
+
+// DeleteSavedSearch реализует storage.Storage
+func (s *Storage) DeleteSavedSearch(ctx context.Context, userID, id string) error {
+	if err := s.faults.Inject(ctx, "DeleteSavedSearch"); err != nil {
+		return err
+	}
+	return s.Storage.DeleteSavedSearch(ctx, userID, id)
+}
+
+// ListAlertSavedSearches реализует storage.Storage
+func (s *Storage) ListAlertSavedSearches(ctx context.Context) ([]models.SavedSearch, error) {
+	if err := s.faults.Inject(ctx, "ListAlertSavedSearches"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListAlertSavedSearches(ctx)
+}
+
+// MarkSavedSearchChecked реализует storage.Storage
+func (s *Storage) MarkSavedSearchChecked(ctx context.Context, id string, checkedAt time.Time) error {
+	if err := s.faults.Inject(ctx, "MarkSavedSearchChecked"); err != nil {
+		return err
+	}
+	return s.Storage.MarkSavedSearchChecked(ctx, id, checkedAt)
+}
+
+// SetShadowBan реализует storage.Storage
+func (s *Storage) SetShadowBan(ctx context.Context, userID string, banned bool) error {
+	if err := s.faults.Inject(ctx, "SetShadowBan"); err != nil {
+		return err
+	}
+	return s.Storage.SetShadowBan(ctx, userID, banned)
+}
+
+// IsShadowBanned реализует storage.Storage
+func (s *Storage) IsShadowBanned(ctx context.Context, userID string) (bool, error) {
+	if err := s.faults.Inject(ctx, "IsShadowBanned"); err != nil {
+		return false, err
+	}
+	return s.Storage.IsShadowBanned(ctx, userID)
+}
+
+// ListModerationRules реализует storage.Storage
+func (s *Storage) ListModerationRules(ctx context.Context) ([]models.ModerationRule, error) {
+	if err := s.faults.Inject(ctx, "ListModerationRules"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListModerationRules(ctx)
+}
+
+// CreateModerationRule реализует storage.Storage
+func (s *Storage) CreateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	if err := s.faults.Inject(ctx, "CreateModerationRule"); err != nil {
+		return err
+	}
+	return s.Storage.CreateModerationRule(ctx, rule)
+}
+
+// UpdateModerationRule реализует storage.Storage
+func (s *Storage) UpdateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	if err := s.faults.Inject(ctx, "UpdateModerationRule"); err != nil {
+		return err
+	}
+	return s.Storage.UpdateModerationRule(ctx, rule)
+}
+
+// DeleteModerationRule реализует storage.Storage
+func (s *Storage) DeleteModerationRule(ctx context.Context, id string) error {
+	if err := s.faults.Inject(ctx, "DeleteModerationRule"); err != nil {
+		return err
+	}
+	return s.Storage.DeleteModerationRule(ctx, id)
+}
+
+// ListAllowedOperations реализует storage.Storage
+func (s *Storage) ListAllowedOperations(ctx context.Context) ([]string, error) {
+	if err := s.faults.Inject(ctx, "ListAllowedOperations"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListAllowedOperations(ctx)
+}
+
+// AddAllowedOperation реализует storage.Storage
+func (s *Storage) AddAllowedOperation(ctx context.Context, query string) error {
+	if err := s.faults.Inject(ctx, "AddAllowedOperation"); err != nil {
+		return err
+	}
+	return s.Storage.AddAllowedOperation(ctx, query)
+}
+
+// HoldContent реализует storage.Storage
+func (s *Storage) HoldContent(ctx context.Context, item *models.HeldItem) error {
+	if err := s.faults.Inject(ctx, "HoldContent"); err != nil {
+		return err
+	}
+	return s.Storage.HoldContent(ctx, item)
+}
+
+// ListHeldContent реализует storage.Storage
+func (s *Storage) ListHeldContent(ctx context.Context, limit int) ([]models.HeldItem, error) {
+	if err := s.faults.Inject(ctx, "ListHeldContent"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListHeldContent(ctx, limit)
+}
+
+// ResolveHeldContent реализует storage.Storage
+func (s *Storage) ResolveHeldContent(ctx context.Context, id string, approve bool) error {
+	if err := s.faults.Inject(ctx, "ResolveHeldContent"); err != nil {
+		return err
+	}
+	return s.Storage.ResolveHeldContent(ctx, id, approve)
+}
+
+// SetSpamStatus реализует storage.Storage
+func (s *Storage) SetSpamStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	if err := s.faults.Inject(ctx, "SetSpamStatus"); err != nil {
+		return err
+	}
+	return s.Storage.SetSpamStatus(ctx, commentID, status, hidden)
+}
+
+// SetToxicityScore реализует storage.Storage
+func (s *Storage) SetToxicityScore(ctx context.Context, score models.ToxicityScore, hold *models.HeldItem) error {
+	if err := s.faults.Inject(ctx, "SetToxicityScore"); err != nil {
+		return err
+	}
+	return s.Storage.SetToxicityScore(ctx, score, hold)
+}
+
+// GetToxicityScores реализует storage.Storage
+func (s *Storage) GetToxicityScores(ctx context.Context, commentIDs []string) (map[string]models.ToxicityScore, error) {
+	if err := s.faults.Inject(ctx, "GetToxicityScores"); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetToxicityScores(ctx, commentIDs)
+}
+
+// SetApprovalStatus реализует storage.Storage
+func (s *Storage) SetApprovalStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	if err := s.faults.Inject(ctx, "SetApprovalStatus"); err != nil {
+		return err
+	}
+	return s.Storage.SetApprovalStatus(ctx, commentID, status, hidden)
+}
+
+// ListPendingComments реализует storage.Storage
+func (s *Storage) ListPendingComments(ctx context.Context, postID string, limit int) ([]models.Comment, error) {
+	if err := s.faults.Inject(ctx, "ListPendingComments"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListPendingComments(ctx, postID, limit)
+}
+
+// ListCommentsBySpamStatus реализует storage.Storage
+func (s *Storage) ListCommentsBySpamStatus(ctx context.Context, status string, limit int) ([]models.Comment, error) {
+	if err := s.faults.Inject(ctx, "ListCommentsBySpamStatus"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListCommentsBySpamStatus(ctx, status, limit)
+}
+
+// IteratePosts реализует storage.Storage
+func (s *Storage) IteratePosts(ctx context.Context, fn func(post *models.Post) error) error {
+	if err := s.faults.Inject(ctx, "IteratePosts"); err != nil {
+		return err
+	}
+	return s.Storage.IteratePosts(ctx, fn)
+}
+
+// IterateComments реализует storage.Storage
+func (s *Storage) IterateComments(ctx context.Context, postID string, fn func(comment *models.Comment) error) error {
+	if err := s.faults.Inject(ctx, "IterateComments"); err != nil {
+		return err
+	}
+	return s.Storage.IterateComments(ctx, postID, fn)
+}
+
+// ListContents реализует storage.Storage
+func (s *Storage) ListContents(ctx context.Context, kind, afterID string, limit int) ([]storage.ContentRecord, error) {
+	if err := s.faults.Inject(ctx, "ListContents"); err != nil {
+		return nil, err
+	}
+	return s.Storage.ListContents(ctx, kind, afterID, limit)
+}
+
+// ReplaceContent реализует storage.Storage
+func (s *Storage) ReplaceContent(ctx context.Context, kind, id, previous, content string) (bool, error) {
+	if err := s.faults.Inject(ctx, "ReplaceContent"); err != nil {
+		return false, err
+	}
+	return s.Storage.ReplaceContent(ctx, kind, id, previous, content)
+}
+
+// AnonymizeUserContent реализует storage.Storage
+func (s *Storage) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	if err := s.faults.Inject(ctx, "AnonymizeUserContent"); err != nil {
+		return nil, err
+	}
+	return s.Storage.AnonymizeUserContent(ctx, kind, userID, limit)
+}
+
+// Ping реализует storage.Storage
+func (s *Storage) Ping(ctx context.Context) error {
+	if err := s.faults.Inject(ctx, "Ping"); err != nil {
+		return err
+	}
+	return s.Storage.Ping(ctx)
+}
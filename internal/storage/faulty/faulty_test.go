@@ -0,0 +1,27 @@
+package faulty
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/faults"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	store := New(memory.New(), faults.New("storage", faults.Options{ErrorRate: 1, Operations: []string{"GetPost", "Ping"}}))
+
+	require.NoError(t, store.CreatePost(ctx, &models.Post{ID: "1", Title: "Пост", CreatedAt: time.Now()}))
+	_, err := store.GetPost(ctx, "1")
+	assert.ErrorIs(t, err, faults.ErrInjected)
+	assert.ErrorIs(t, store.Ping(ctx), faults.ErrInjected)
+	post, err := store.GetPostBySlug(ctx, "1")
+	require.NoError(t, err, "Сбои внедряются только в перечисленные операции")
+	assert.Equal(t, "Пост", post.Title)
+	assert.NoError(t, store.Close())
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// PostFilter - условия отбора постов в ListPosts; нулевое значение возвращает все посты.
+// Заданные условия объединяются через И
+type PostFilter struct {
+	// CategoryID - категория постов; пустая строка отключает фильтр
+	CategoryID string
+	// IncludeSubcategories добавляет к постам категории посты всех её подкатегорий
+	IncludeSubcategories bool
+	// CreatedAfter и CreatedBefore ограничивают время создания поста, не включая границы
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// AsOf - граница снимка ленты: посты, созданные позже, не возвращаются и не учитываются в TotalCount.
+	// В отличие от CreatedBefore граница включается
+	AsOf *time.Time
+	// Text - подстрока заголовка или содержимого без учёта регистра; пустая строка отключает фильтр
+	Text string
+	// AuthorID - автор постов; пустая строка отключает фильтр
+	AuthorID string
+	// Tag - тег, который должен быть у поста; пустая строка отключает фильтр
+	Tag string
+	// HasComments отбирает посты с видимыми всем комментариями или без них
+	HasComments *bool
+	// AllowComments отбирает посты с открытыми или закрытыми комментариями
+	AllowComments *bool
+	// Language - код языка постов; пустая строка отключает фильтр
+	Language string
+	// ReferencedBy отбирает посты, на которые ссылается пост с этим ID; пустая строка отключает фильтр
+	ReferencedBy string
+	// References отбирает посты, которые ссылаются на пост с этим ID; пустая строка отключает фильтр
+	References string
+	// IDs ограничивает посты этими ID, например найденными поисковым движком; nil отключает фильтр,
+	// а пустой срез не отбирает ни одного поста
+	IDs []string
+}
+
+// Matches проверяет условия filter, кроме категории и ссылок между постами, для поста с commentCount видимыми всем комментариями
+func (f PostFilter) Matches(post *models.Post, commentCount int) bool {
+	if f.CreatedAfter != nil && !post.CreatedAt.After(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !post.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.AsOf != nil && post.CreatedAt.After(*f.AsOf) {
+		return false
+	}
+	if f.Text != "" && !containsFold(post.Title, f.Text) && !containsFold(post.Content, f.Text) {
+		return false
+	}
+	if f.AuthorID != "" && post.AuthorID != f.AuthorID {
+		return false
+	}
+	if f.Tag != "" && !slices.Contains(post.Tags, f.Tag) {
+		return false
+	}
+	if f.HasComments != nil && *f.HasComments != (commentCount > 0) {
+		return false
+	}
+	if f.AllowComments != nil && *f.AllowComments != post.AllowComments {
+		return false
+	}
+	if f.Language != "" && post.Language != f.Language {
+		return false
+	}
+	if f.IDs != nil && !slices.Contains(f.IDs, post.ID) {
+		return false
+	}
+	return true
+}
+
+// SearchPostFilter возвращает условия ListPosts для сохранённого поиска
+func SearchPostFilter(filter models.SearchFilter) PostFilter {
+	return PostFilter{
+		CategoryID:           filter.CategoryID,
+		IncludeSubcategories: filter.IncludeSubcategories,
+		Text:                 filter.Text,
+		AuthorID:             filter.AuthorID,
+		Tag:                  filter.Tag,
+		HasComments:          filter.HasComments,
+		AllowComments:        filter.AllowComments,
+		Language:             filter.Language,
+	}
+}
+
+// containsFold сообщает, содержит ли s подстроку substr без учёта регистра
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
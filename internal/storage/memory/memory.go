@@ -2,35 +2,151 @@ package memory
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
 )
 
 // MemoryStorage представляет in-memory хранилище
 type MemoryStorage struct {
-	posts    map[string]*models.Post
-	comments map[string][]*models.Comment
-	mu       sync.RWMutex
+	posts     map[string]*models.Post
+	comments  map[string][]*models.Comment
+	previews  map[string][]models.LinkPreview
+	reactions map[string][]models.Reaction
+	quotas    map[quotaKey]quotaUsage
+	// shadowBans - пользователи под теневым баном
+	shadowBans map[string]bool
+	// follows - авторы, на которых подписан пользователь
+	follows map[string]map[string]bool
+	rules   []models.ModerationRule
+	held    []models.HeldItem
+	// allowedOperations - документы операций allowlist в порядке добавления
+	allowedOperations []string
+	// toxicity - последние оценки токсичности комментариев
+	toxicity map[string]models.ToxicityScore
+	// categories - категории в порядке создания
+	categories []models.Category
+	// slugs - текущие и прежние slug постов и ID их постов
+	slugs map[string]string
+	// references - ID постов, на которые ссылается пост
+	references map[string][]string
+	// shortIDs - номера коротких ссылок по виду и ID сущности; shortTargets - сущности по номеру с 1
+	shortIDs     map[shortKey]int64
+	shortTargets []shortKey
+	// votes - голос пользователя за комментарий: 1 или -1
+	votes map[voteKey]int
+	// reads - время последнего прочтения комментариев поста пользователем
+	reads map[readKey]time.Time
+	// preferences - сохранённые настройки пользователей
+	preferences map[string]models.Preferences
+	// tenantSettings - сохранённые настройки сообществ
+	tenantSettings map[string]models.TenantSettings
+	// tenantUsage - использование хранилища сообществами
+	tenantUsage map[string]models.TenantUsage
+	// postSubscriptions - время подписки пользователя на сводки комментариев поста
+	postSubscriptions map[readKey]time.Time
+	// digestsSent - граница последней сводки пользователя
+	digestsSent map[string]time.Time
+	// deviceTokens - устройства для push-уведомлений в порядке регистрации
+	deviceTokens []models.DeviceToken
+	// savedSearches - сохранённые поиски в порядке создания
+	savedSearches []models.SavedSearch
+	// collections - серии постов в порядке создания
+	collections []models.Collection
+	// dedupeWindow - окно, в котором одинаковый комментарий не создаётся повторно
+	dedupeWindow time.Duration
+	mu           sync.RWMutex
+}
+
+// quotaKey - пользователь и действие, для которых ведётся счётчик квоты
+type quotaKey struct {
+	userID string
+	action string
+}
+
+// readKey - пользователь и прочитанный им пост или пост, на который он подписан
+type readKey struct {
+	userID string
+	postID string
+}
+
+// shortKey - вид и ID сущности с короткой ссылкой
+type shortKey struct {
+	kind string
+	id   string
+}
+
+// voteKey - комментарий и проголосовавший пользователь
+type voteKey struct {
+	commentID string
+	userID    string
+}
+
+// quotaUsage - счётчик квоты в текущем окне
+type quotaUsage struct {
+	windowStart time.Time
+	used        int
 }
 
 // New создаёт новое in-memory хранилище
 func New() *MemoryStorage {
 	log.Println("Инициализация нового MemoryStorage")
 	return &MemoryStorage{
-		posts:    make(map[string]*models.Post),
-		comments: make(map[string][]*models.Comment),
+		posts:             make(map[string]*models.Post),
+		comments:          make(map[string][]*models.Comment),
+		previews:          make(map[string][]models.LinkPreview),
+		reactions:         make(map[string][]models.Reaction),
+		quotas:            make(map[quotaKey]quotaUsage),
+		shadowBans:        make(map[string]bool),
+		follows:           make(map[string]map[string]bool),
+		votes:             make(map[voteKey]int),
+		reads:             make(map[readKey]time.Time),
+		preferences:       make(map[string]models.Preferences),
+		tenantSettings:    make(map[string]models.TenantSettings),
+		tenantUsage:       make(map[string]models.TenantUsage),
+		postSubscriptions: make(map[readKey]time.Time),
+		digestsSent:       make(map[string]time.Time),
+		slugs:             make(map[string]string),
+		references:        make(map[string][]string),
+		shortIDs:          make(map[shortKey]int64),
+		toxicity:          make(map[string]models.ToxicityScore),
 	}
 }
 
+// SetDedupeWindow задаёт окно дедупликации одинаковых комментариев; 0 отключает проверку
+func (s *MemoryStorage) SetDedupeWindow(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedupeWindow = window
+}
+
 // CreatePost создаёт новый пост
 func (s *MemoryStorage) CreatePost(ctx context.Context, post *models.Post) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	log.Printf("Вставка поста в Memory: ID=%s, Title=%s, CreatedAt=%v", post.ID, post.Title, post.CreatedAt)
+	if post.CategoryID != nil && s.findCategory(*post.CategoryID) == nil {
+		log.Printf("Категория поста ID=%s не найдена в Memory: %s", post.ID, *post.CategoryID)
+		return storage.ErrCategoryNotFound
+	}
+	base := post.Slug
+	if base == "" {
+		base = post.ID
+	}
+	post.Slug = storage.UniqueSlug(base, s.slugsWithBase(base))
+	if post.Visibility == "" {
+		post.Visibility = models.VisibilityPublic
+	}
+	post.PrecomputeTimes()
+	s.slugs[post.Slug] = post.ID
 	s.posts[post.ID] = post
+	s.assignShortID(models.TargetPost, post.ID)
 	log.Printf("Пост успешно вставлен в Memory: %s", post.ID)
 	return nil
 }
@@ -43,40 +159,141 @@ func (s *MemoryStorage) GetPost(ctx context.Context, id string) (*models.Post, e
 	post, exists := s.posts[id]
 	if !exists {
 		log.Printf("Пост с ID=%s не найден в Memory", id)
-		return nil, errors.New("post not found")
+		return nil, storage.ErrPostNotFound
 	}
 	log.Printf("Пост успешно получен из Memory: ID=%s, Title=%s", post.ID, post.Title)
 	return post, nil
 }
 
-// ListPosts возвращает список постов
-func (s *MemoryStorage) ListPosts(ctx context.Context, limit int, cursor *string) (*models.PaginatedPosts, error) {
+// GetPostBySlug получает пост по текущему или прежнему slug
+func (s *MemoryStorage) GetPostBySlug(ctx context.Context, slug string) (*models.Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	log.Printf("Запрос списка постов из Memory: limit=%d, cursor=%v", limit, cursor)
+	log.Printf("Получение поста по slug=%s из Memory", slug)
+	post, exists := s.posts[s.slugs[slug]]
+	if !exists {
+		return nil, storage.ErrPostNotFound
+	}
+	return post, nil
+}
 
-	var posts []*models.Post
-	for _, post := range s.posts {
-		posts = append(posts, post)
+// ShortIDs возвращает выданные номера коротких ссылок
+func (s *MemoryStorage) ShortIDs(ctx context.Context, kind string, ids []string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		if seq, ok := s.shortIDs[shortKey{kind: kind, id: id}]; ok {
+			result[id] = seq
+		}
 	}
+	return result, nil
+}
 
-	// Сортировка по createdAt (от новых к старым)
-	for i := 0; i < len(posts)-1; i++ {
-		for j := i + 1; j < len(posts); j++ {
-			if posts[i].CreatedAt.Before(posts[j].CreatedAt) {
-				posts[i], posts[j] = posts[j], posts[i]
-			}
+// assignShortID выдаёт сущности следующий номер короткой ссылки; вызывается под s.mu
+func (s *MemoryStorage) assignShortID(kind, id string) {
+	key := shortKey{kind: kind, id: id}
+	if _, ok := s.shortIDs[key]; ok {
+		return
+	}
+	s.shortTargets = append(s.shortTargets, key)
+	s.shortIDs[key] = int64(len(s.shortTargets))
+}
+
+// ResolveShortID возвращает сущность короткой ссылки
+func (s *MemoryStorage) ResolveShortID(ctx context.Context, seq int64) (string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if seq < 1 || seq > int64(len(s.shortTargets)) {
+		return "", "", storage.ErrShortIDNotFound
+	}
+	target := s.shortTargets[seq-1]
+	return target.kind, target.id, nil
+}
+
+// UpdatePostTitle меняет заголовок поста и при необходимости его slug.
+// Запись заменяется копией, как и в unhide
+func (s *MemoryStorage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Изменение заголовка поста %s в Memory: %s", postID, title)
+	post, exists := s.posts[postID]
+	if !exists {
+		return nil, storage.ErrPostNotFound
+	}
+	updated := *post
+	updated.Title = title
+	updated.Slug = storage.RetitleSlug(postID, post.Slug, slugBase, s.slugsWithBase(slugBase))
+	s.slugs[updated.Slug] = postID
+	s.posts[postID] = &updated
+	result := updated
+	return &result, nil
+}
+
+// slugsWithBase возвращает занятые slug с основой base; вызывается под блокировкой
+func (s *MemoryStorage) slugsWithBase(base string) map[string]string {
+	taken := make(map[string]string)
+	for slug, postID := range s.slugs {
+		if storage.SlugHasBase(slug, base) {
+			taken[slug] = postID
+		}
+	}
+	return taken
+}
+
+// ListPosts возвращает список постов, отобранных filter
+func (s *MemoryStorage) ListPosts(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (*models.PaginatedPosts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Запрос списка постов из Memory: limit=%d, cursor=%v, filter=%+v", limit, cursor, filter)
+
+	var after *storage.Cursor
+	if cursor != nil {
+		decoded, err := storage.DecodeCursor(*cursor, models.SortDesc)
+		if err != nil {
+			log.Printf("Ошибка разбора курсора %s: %v", *cursor, err)
+			return nil, err
+		}
+		after = decoded
+	}
+
+	var rootPath string
+	if filter.CategoryID != "" {
+		category := s.findCategory(filter.CategoryID)
+		if category == nil {
+			log.Printf("Категория фильтра с ID=%s не найдена в Memory", filter.CategoryID)
+			return nil, storage.ErrCategoryNotFound
+		}
+		rootPath = category.Path
+	}
+
+	viewerID := storage.Viewer(ctx)
+	posts := make([]*models.Post, 0, len(s.posts))
+	for _, post := range s.posts {
+		if storage.PostListedTo(post, viewerID, s.follows[viewerID][post.AuthorID]) && s.matchesFilter(post, filter, rootPath) {
+			posts = append(posts, post)
 		}
 	}
 
+	// Сортировка по (createdAt, id) от новых к старым
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.After(posts[j].CreatedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+
 	totalCount := len(posts)
 	log.Printf("Общее количество постов в Memory: %d", totalCount)
 
 	startIdx := 0
-	if cursor != nil {
+	if after != nil && after.Offset > 0 {
+		startIdx = min(after.Offset, len(posts))
+	} else if after != nil {
+		startIdx = len(posts)
 		for i, post := range posts {
-			if post.CreatedAt.String() == *cursor {
-				startIdx = i + 1
+			if after.After(post.CreatedAt, post.ID) {
+				startIdx = i
 				break
 			}
 		}
@@ -92,7 +309,8 @@ func (s *MemoryStorage) ListPosts(ctx context.Context, limit int, cursor *string
 	result := posts[startIdx:endIdx]
 	var nextCursor *string
 	if endIdx < len(posts) {
-		cursorVal := posts[endIdx-1].CreatedAt.String()
+		last := posts[endIdx-1]
+		cursorVal := storage.EncodeCursor(models.SortDesc, last.CreatedAt, last.ID)
 		nextCursor = &cursorVal
 		log.Printf("Установлен nextCursor: %s", *nextCursor)
 	}
@@ -104,6 +322,230 @@ func (s *MemoryStorage) ListPosts(ctx context.Context, limit int, cursor *string
 	}, nil
 }
 
+// SavePostReferences реализует storage.Storage
+func (s *MemoryStorage) SavePostReferences(ctx context.Context, postID string, targetIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range append([]string{postID}, targetIDs...) {
+		if _, exists := s.posts[id]; !exists {
+			return storage.ErrPostNotFound
+		}
+	}
+	if len(targetIDs) == 0 {
+		delete(s.references, postID)
+		return nil
+	}
+	s.references[postID] = slices.Clone(targetIDs)
+	return nil
+}
+
+// RelatedPosts возвращает опубликованные посты, похожие на пост postID
+func (s *MemoryStorage) RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Запрос похожих постов для %s из Memory: limit=%d", postID, limit)
+	post, exists := s.posts[postID]
+	if !exists {
+		return nil, storage.ErrPostNotFound
+	}
+	type scored struct {
+		post  *models.Post
+		score float64
+	}
+	candidates := []scored{}
+	for _, candidate := range s.posts {
+		// Похожие посты общие для всех зрителей, поэтому предлагаются только публичные
+		if candidate.ID == postID || candidate.Hidden || candidate.Visibility != models.VisibilityPublic {
+			continue
+		}
+		if score, ok := storage.RelatedScore(post, candidate); ok {
+			candidates = append(candidates, scored{post: candidate, score: score})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.post.CreatedAt.Equal(b.post.CreatedAt) {
+			return a.post.CreatedAt.After(b.post.CreatedAt)
+		}
+		return a.post.ID > b.post.ID
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	result := make([]*models.Post, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.post
+	}
+	return result, nil
+}
+
+// SimilarPosts возвращает посты с похожими заголовками
+func (s *MemoryStorage) SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]storage.SimilarPost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Запрос постов с заголовком, похожим на %q, из Memory: порог %.2f, limit=%d", title, threshold, limit)
+	threshold = max(threshold, storage.RelatedSimilarityThreshold)
+	similar := []storage.SimilarPost{}
+	for _, post := range s.posts {
+		published := !post.Hidden && post.Visibility == models.VisibilityPublic
+		if !published && (viewerID == "" || post.AuthorID != viewerID) {
+			continue
+		}
+		if similarity := storage.TrigramSimilarity(title, post.Title); similarity >= threshold {
+			similar = append(similar, storage.SimilarPost{Post: post, Similarity: similarity})
+		}
+	}
+	sort.Slice(similar, func(i, j int) bool {
+		a, b := similar[i], similar[j]
+		if a.Similarity != b.Similarity {
+			return a.Similarity > b.Similarity
+		}
+		if !a.Post.CreatedAt.Equal(b.Post.CreatedAt) {
+			return a.Post.CreatedAt.After(b.Post.CreatedAt)
+		}
+		return a.Post.ID > b.Post.ID
+	})
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar, nil
+}
+
+// matchesFilter сообщает, подходит ли пост под фильтр; rootPath - путь категории фильтра.
+// Вызывается под блокировкой
+func (s *MemoryStorage) matchesFilter(post *models.Post, filter storage.PostFilter, rootPath string) bool {
+	visibleComments := 0
+	if filter.HasComments != nil {
+		for _, comment := range s.comments[post.ID] {
+			if !comment.Hidden {
+				visibleComments++
+			}
+		}
+	}
+	if !filter.Matches(post, visibleComments) {
+		return false
+	}
+	if filter.ReferencedBy != "" && !slices.Contains(s.references[filter.ReferencedBy], post.ID) {
+		return false
+	}
+	if filter.References != "" && !slices.Contains(s.references[post.ID], filter.References) {
+		return false
+	}
+	if filter.CategoryID == "" {
+		return true
+	}
+	if post.CategoryID == nil {
+		return false
+	}
+	if !filter.IncludeSubcategories {
+		return *post.CategoryID == filter.CategoryID
+	}
+	category := s.findCategory(*post.CategoryID)
+	return category != nil && storage.InCategoryTree(rootPath, category.Path)
+}
+
+// SetPostCategory относит пост к категории или убирает его из категории.
+// Запись заменяется копией, как и в unhide
+func (s *MemoryStorage) SetPostCategory(ctx context.Context, postID string, categoryID *string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Изменение категории поста %s в Memory: %v", postID, categoryID)
+	post, ok := s.posts[postID]
+	if !ok {
+		return storage.ErrPostNotFound
+	}
+	if categoryID != nil && s.findCategory(*categoryID) == nil {
+		return storage.ErrCategoryNotFound
+	}
+	updated := *post
+	updated.CategoryID = categoryID
+	s.posts[postID] = &updated
+	return nil
+}
+
+// SetPostRequireApproval включает или выключает премодерацию комментариев поста.
+// Запись заменяется копией, как и в unhide
+func (s *MemoryStorage) SetPostRequireApproval(ctx context.Context, postID string, required bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Премодерация комментариев поста %s в Memory: %t", postID, required)
+	post, ok := s.posts[postID]
+	if !ok {
+		return storage.ErrPostNotFound
+	}
+	updated := *post
+	updated.RequireApproval = required
+	s.posts[postID] = &updated
+	return nil
+}
+
+// SetPostExcerpt заменяет анонс поста
+func (s *MemoryStorage) SetPostExcerpt(ctx context.Context, postID, excerpt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Замена анонса поста %s в Memory", postID)
+	post, ok := s.posts[postID]
+	if !ok {
+		return storage.ErrPostNotFound
+	}
+	updated := *post
+	updated.Excerpt = excerpt
+	s.posts[postID] = &updated
+	return nil
+}
+
+// CreateCategory сохраняет новую категорию, вычисляя её путь по родителю
+func (s *MemoryStorage) CreateCategory(ctx context.Context, category *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Создание категории в Memory: ID=%s, Name=%s, ParentID=%v", category.ID, category.Name, category.ParentID)
+	var parentPath string
+	if category.ParentID != nil {
+		parent := s.findCategory(*category.ParentID)
+		if parent == nil {
+			return storage.ErrCategoryNotFound
+		}
+		parentPath = parent.Path
+	}
+	category.Path = storage.CategoryPath(parentPath, category.ID)
+	s.categories = append(s.categories, *category)
+	return nil
+}
+
+// GetCategory возвращает категорию по ID
+func (s *MemoryStorage) GetCategory(ctx context.Context, id string) (*models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	category := s.findCategory(id)
+	if category == nil {
+		return nil, storage.ErrCategoryNotFound
+	}
+	result := *category
+	return &result, nil
+}
+
+// ListCategories возвращает все категории в порядке создания
+func (s *MemoryStorage) ListCategories(ctx context.Context) ([]models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]models.Category, len(s.categories))
+	copy(result, s.categories)
+	return result, nil
+}
+
+// findCategory возвращает категорию по ID или nil; вызывается под блокировкой
+func (s *MemoryStorage) findCategory(id string) *models.Category {
+	for i := range s.categories {
+		if s.categories[i].ID == id {
+			return &s.categories[i]
+		}
+	}
+	return nil
+}
+
 // CreateComment создаёт новый комментарий
 func (s *MemoryStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
 	s.mu.Lock()
@@ -111,51 +553,185 @@ func (s *MemoryStorage) CreateComment(ctx context.Context, comment *models.Comme
 	log.Printf("Вставка комментария в Memory: ID=%s, PostID=%s, Content=%s", comment.ID, comment.PostID, comment.Content)
 	if _, exists := s.posts[comment.PostID]; !exists {
 		log.Printf("Ошибка: пост с ID=%s не найден в Memory", comment.PostID)
-		return errors.New("post not found")
+		return storage.ErrPostNotFound
+	}
+	for _, existing := range s.comments[comment.PostID] {
+		if storage.IsDuplicate(existing, comment, s.dedupeWindow) {
+			log.Printf("Повторный комментарий в Memory, возвращается существующий: %s", existing.ID)
+			return &storage.DuplicateCommentError{Existing: existing}
+		}
 	}
+	comment.PrecomputeTimes()
 	s.comments[comment.PostID] = append(s.comments[comment.PostID], comment)
+	s.assignShortID(models.TargetComment, comment.ID)
 	log.Printf("Комментарий успешно вставлен в Memory: %s", comment.ID)
 	return nil
 }
 
+// CreateComments создаёт пачку комментариев
+func (s *MemoryStorage) CreateComments(ctx context.Context, comments []*models.Comment) []error {
+	errs := make([]error, len(comments))
+	for i, comment := range comments {
+		errs[i] = s.CreateComment(ctx, comment)
+	}
+	return errs
+}
+
+// GetComment получает комментарий по ID
+func (s *MemoryStorage) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Получение комментария с ID=%s из Memory", id)
+	for _, comments := range s.comments {
+		for _, comment := range comments {
+			if comment.ID == id {
+				return comment, nil
+			}
+		}
+	}
+	log.Printf("Комментарий с ID=%s не найден в Memory", id)
+	return nil, storage.ErrCommentNotFound
+}
+
+// GetCommentsByIDs получает комментарии по ID
+func (s *MemoryStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	result := make(map[string]*models.Comment, len(ids))
+	for _, comments := range s.comments {
+		for _, comment := range comments {
+			if wanted[comment.ID] {
+				result[comment.ID] = comment
+			}
+		}
+	}
+	return result, nil
+}
+
+// commentBefore сообщает, идёт ли комментарий a перед b в порядке order
+func commentBefore(a, b *models.Comment, order models.SortOrder) bool {
+	if order == models.SortBest {
+		scoreA, scoreB := storage.WilsonScore(a.Upvotes, a.Downvotes), storage.WilsonScore(b.Upvotes, b.Downvotes)
+		if scoreA != scoreB {
+			return scoreA > scoreB
+		}
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		if order == models.SortAsc {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	if order == models.SortAsc {
+		return a.ID < b.ID
+	}
+	return a.ID > b.ID
+}
+
+// CountCommentsBefore реализует storage.Storage
+func (s *MemoryStorage) CountCommentsBefore(ctx context.Context, commentID string, order models.SortOrder) (int, error) {
+	if order == "" {
+		order = models.SortDesc
+	}
+	target, err := s.GetComment(ctx, commentID)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	viewerID := storage.Viewer(ctx)
+	count := 0
+	for _, comment := range s.comments[target.PostID] {
+		if !storage.CommentVisibleTo(comment, viewerID) || !sameParent(comment.ParentID, target.ParentID) {
+			continue
+		}
+		if commentBefore(comment, target, order) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// sameParent сравнивает родителей комментариев; nil - корневой комментарий
+func sameParent(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
 // GetComments получает комментарии для поста
-func (s *MemoryStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string) (*models.PaginatedComments, error) {
-	log.Printf("Запрос комментариев из Memory: postID=%s, parentID=%v, limit=%d, cursor=%v", postID, parentID, limit, cursor)
+func (s *MemoryStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string, order models.SortOrder) (*models.PaginatedComments, error) {
+	log.Printf("Запрос комментариев из Memory: postID=%s, parentID=%v, limit=%d, cursor=%v, order=%s", postID, parentID, limit, cursor, order)
+	if order == "" {
+		order = models.SortDesc
+	}
+	var after *storage.Cursor
+	if cursor != nil {
+		decoded, err := storage.DecodeCursor(*cursor, order)
+		if err != nil {
+			log.Printf("Ошибка разбора курсора %s: %v", *cursor, err)
+			return nil, err
+		}
+		after = decoded
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	// Комментарии поста, который зритель не видит, для него не существуют
+	viewerID := storage.Viewer(ctx)
+	post, visible := s.posts[postID]
+	if visible {
+		visible = storage.PostVisibleToFollower(post, viewerID, s.follows[viewerID][post.AuthorID])
+	}
 	comments, exists := s.comments[postID]
-	if !exists {
+	if !exists || !visible {
 		log.Printf("Комментарии для postID=%s не найдены в Memory", postID)
 		return &models.PaginatedComments{Comments: []models.Comment{}, TotalCount: 0, NextCursor: nil}, nil
 	}
 
-	// Фильтрация по parentID
-	var filtered []models.Comment
+	// Фильтрация по parentID и видимости для зрителя
+	filtered := []models.Comment{}
 	for _, comment := range comments {
+		if !storage.CommentVisibleTo(comment, viewerID) {
+			continue
+		}
 		if parentID == nil && comment.ParentID == nil || (parentID != nil && comment.ParentID != nil && *comment.ParentID == *parentID) {
 			filtered = append(filtered, *comment)
 			log.Printf("Добавлен комментарий: ID=%s, Content=%s", comment.ID, comment.Content)
 		}
 	}
 
-	// Сортировка по createdAt (от новых к старым)
-	for i := 0; i < len(filtered)-1; i++ {
-		for j := i + 1; j < len(filtered); j++ {
-			if filtered[i].CreatedAt.Before(filtered[j].CreatedAt) {
-				filtered[i], filtered[j] = filtered[j], filtered[i]
+	// Сортировка по (createdAt, id) в запрошенном направлении; для BEST сначала по оценке голосов
+	sort.Slice(filtered, func(i, j int) bool {
+		return commentBefore(&filtered[i], &filtered[j], order)
+	})
+
+	totalCount := len(filtered)
+	estimated := storage.EstimatedCounts(ctx)
+	if estimated {
+		// Как счётчик postgres: без скрытых комментариев зрителя
+		for i := range filtered {
+			if filtered[i].Hidden {
+				totalCount--
 			}
 		}
 	}
-
-	totalCount := len(filtered)
 	log.Printf("Общее количество комментариев для postID=%s: %d", postID, totalCount)
 
 	startIdx := 0
-	if cursor != nil {
+	if after != nil && after.Offset > 0 {
+		startIdx = min(after.Offset, len(filtered))
+	} else if after != nil {
+		startIdx = len(filtered)
 		for i, comment := range filtered {
-			if comment.CreatedAt.String() == *cursor {
-				startIdx = i + 1
+			if after.AfterComment(&comment) {
+				startIdx = i
 				break
 			}
 		}
@@ -171,7 +747,11 @@ func (s *MemoryStorage) GetComments(ctx context.Context, postID string, parentID
 	result := filtered[startIdx:endIdx]
 	var nextCursor *string
 	if endIdx < len(filtered) {
-		cursorVal := filtered[endIdx-1].CreatedAt.String()
+		last := filtered[endIdx-1]
+		cursorVal := storage.EncodeCursor(order, last.CreatedAt, last.ID)
+		if order == models.SortBest {
+			cursorVal = storage.EncodeBestCursor(storage.WilsonScore(last.Upvotes, last.Downvotes), last.CreatedAt, last.ID)
+		}
 		nextCursor = &cursorVal
 		log.Printf("Установлен nextCursor: %s", *nextCursor)
 	}
@@ -180,9 +760,1106 @@ func (s *MemoryStorage) GetComments(ctx context.Context, postID string, parentID
 		Comments:   result,
 		TotalCount: totalCount,
 		NextCursor: nextCursor,
+		Estimated:  estimated,
 	}, nil
 }
 
+// SaveLinkPreview сохраняет превью ссылки, заменяя предыдущее для того же URL
+func (s *MemoryStorage) SaveLinkPreview(ctx context.Context, preview *models.LinkPreview) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Сохранение превью в Memory: PostID=%s, URL=%s", preview.PostID, preview.URL)
+	if _, exists := s.posts[preview.PostID]; !exists {
+		log.Printf("Ошибка: пост с ID=%s не найден в Memory", preview.PostID)
+		return storage.ErrPostNotFound
+	}
+	previews := s.previews[preview.PostID]
+	for i := range previews {
+		if previews[i].URL == preview.URL {
+			previews[i] = *preview
+			return nil
+		}
+	}
+	s.previews[preview.PostID] = append(previews, *preview)
+	return nil
+}
+
+// GetLinkPreviews возвращает превью ссылок поста
+func (s *MemoryStorage) GetLinkPreviews(ctx context.Context, postID string) ([]models.LinkPreview, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Запрос превью из Memory: postID=%s", postID)
+	result := make([]models.LinkPreview, len(s.previews[postID]))
+	copy(result, s.previews[postID])
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URL < result[j].URL
+	})
+	return result, nil
+}
+
+// ToggleReaction добавляет реакцию пользователя или снимает её, если она уже была.
+// Возвращает true, если реакция добавлена
+func (s *MemoryStorage) ToggleReaction(ctx context.Context, reaction *models.Reaction) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Переключение реакции в Memory: TargetID=%s, UserID=%s, Emoji=%s", reaction.TargetID, reaction.UserID, reaction.Emoji)
+	reactions := s.reactions[reaction.TargetID]
+	for i, r := range reactions {
+		if r.UserID == reaction.UserID && r.Emoji == reaction.Emoji {
+			s.reactions[reaction.TargetID] = append(reactions[:i], reactions[i+1:]...)
+			return false, nil
+		}
+	}
+	s.reactions[reaction.TargetID] = append(reactions, *reaction)
+	return true, nil
+}
+
+// VoteComment сохраняет голос пользователя и обновляет счётчики комментария на разницу с прошлым голосом.
+// Запись комментария заменяется копией, как и в unhide
+func (s *MemoryStorage) VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Голос пользователя %s за комментарий %s в Memory: %d", vote.UserID, vote.CommentID, vote.Value)
+	for _, comments := range s.comments {
+		for i, comment := range comments {
+			if comment.ID != vote.CommentID {
+				continue
+			}
+			key := voteKey{commentID: vote.CommentID, userID: vote.UserID}
+			updated := *comment
+			switch s.votes[key] {
+			case 1:
+				updated.Upvotes--
+			case -1:
+				updated.Downvotes--
+			}
+			switch vote.Value {
+			case 1:
+				updated.Upvotes++
+			case -1:
+				updated.Downvotes++
+			}
+			if vote.Value == 0 {
+				delete(s.votes, key)
+			} else {
+				s.votes[key] = vote.Value
+			}
+			comments[i] = &updated
+			result := updated
+			return &result, nil
+		}
+	}
+	return nil, storage.ErrCommentNotFound
+}
+
+// MarkThreadRead сохраняет время прочтения комментариев поста, если оно позже сохранённого
+func (s *MemoryStorage) MarkThreadRead(ctx context.Context, userID, postID string, readAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Отметка о прочтении поста %s пользователем %s в Memory: %v", postID, userID, readAt)
+	if _, ok := s.posts[postID]; !ok {
+		return storage.ErrPostNotFound
+	}
+	key := readKey{userID: userID, postID: postID}
+	if readAt.After(s.reads[key]) {
+		s.reads[key] = readAt
+	}
+	return nil
+}
+
+// GetUnreadCommentCounts считает непрочитанные пользователем комментарии постов, включая ответы
+func (s *MemoryStorage) GetUnreadCommentCounts(ctx context.Context, userID string, postIDs []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Запрос непрочитанных комментариев из Memory для %d постов", len(postIDs))
+	result := make(map[string]int, len(postIDs))
+	for _, postID := range postIDs {
+		readAt := s.reads[readKey{userID: userID, postID: postID}]
+		count := 0
+		for _, comment := range s.comments[postID] {
+			if comment.AuthorID != userID && !comment.Hidden && comment.CreatedAt.After(readAt) {
+				count++
+			}
+		}
+		result[postID] = count
+	}
+	return result, nil
+}
+
+// CountDescendants обходит дерево видимых комментариев от каждого из commentIDs
+func (s *MemoryStorage) CountDescendants(ctx context.Context, commentIDs []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Подсчёт ответов из Memory для %d комментариев", len(commentIDs))
+	children := make(map[string][]string)
+	for _, comments := range s.comments {
+		for _, comment := range comments {
+			if comment.ParentID != nil && !comment.Hidden {
+				children[*comment.ParentID] = append(children[*comment.ParentID], comment.ID)
+			}
+		}
+	}
+	result := make(map[string]int, len(commentIDs))
+	for _, id := range commentIDs {
+		count := 0
+		visited := map[string]bool{id: true}
+		queue := children[id]
+		for len(queue) > 0 {
+			child := queue[0]
+			queue = queue[1:]
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			count++
+			queue = append(queue, children[child]...)
+		}
+		result[id] = count
+	}
+	return result, nil
+}
+
+// GetReactionCounts возвращает количество реакций по каждому эмодзи для набора объектов
+func (s *MemoryStorage) GetReactionCounts(ctx context.Context, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	log.Printf("Запрос количества реакций из Memory для %d объектов", len(targetIDs))
+	result := make(map[string][]models.ReactionCount, len(targetIDs))
+	for _, id := range targetIDs {
+		counts := make(map[string]int)
+		for _, r := range s.reactions[id] {
+			counts[r.Emoji]++
+		}
+		grouped := make([]models.ReactionCount, 0, len(counts))
+		for emoji, count := range counts {
+			grouped = append(grouped, models.ReactionCount{Emoji: emoji, Count: count})
+		}
+		sort.Slice(grouped, func(i, j int) bool {
+			if grouped[i].Count != grouped[j].Count {
+				return grouped[i].Count > grouped[j].Count
+			}
+			return grouped[i].Emoji < grouped[j].Emoji
+		})
+		result[id] = grouped
+	}
+	return result, nil
+}
+
+// IncrementQuotaUsage увеличивает счётчик квоты; при смене окна счётчик начинается заново
+func (s *MemoryStorage) IncrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := quotaKey{userID: userID, action: action}
+	usage := s.quotas[key]
+	if !usage.windowStart.Equal(windowStart) {
+		usage = quotaUsage{windowStart: windowStart}
+	}
+	usage.used++
+	s.quotas[key] = usage
+	return usage.used, nil
+}
+
+// DecrementQuotaUsage уменьшает счётчик квоты текущего окна
+func (s *MemoryStorage) DecrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := quotaKey{userID: userID, action: action}
+	if usage, ok := s.quotas[key]; ok && usage.windowStart.Equal(windowStart) && usage.used > 0 {
+		usage.used--
+		s.quotas[key] = usage
+	}
+	return nil
+}
+
+// GetPreferences возвращает копию настроек пользователя или настройки по умолчанию
+func (s *MemoryStorage) GetPreferences(ctx context.Context, userID string) (*models.Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefs, ok := s.preferences[userID]
+	if !ok {
+		return models.DefaultPreferences(userID), nil
+	}
+	return &prefs, nil
+}
+
+// SavePreferences сохраняет копию настроек пользователя
+func (s *MemoryStorage) SavePreferences(ctx context.Context, prefs *models.Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Сохранение настроек пользователя %s в Memory", prefs.UserID)
+	s.preferences[prefs.UserID] = *prefs
+	return nil
+}
+
+// GetTenantSettings возвращает копию настроек сообщества или настройки по умолчанию
+func (s *MemoryStorage) GetTenantSettings(ctx context.Context, tenantID string) (*models.TenantSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	settings, ok := s.tenantSettings[tenantID]
+	if !ok {
+		return models.DefaultTenantSettings(tenantID), nil
+	}
+	return &settings, nil
+}
+
+// SaveTenantSettings сохраняет копию настроек сообщества
+func (s *MemoryStorage) SaveTenantSettings(ctx context.Context, settings *models.TenantSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Сохранение настроек сообщества %s в Memory", settings.TenantID)
+	s.tenantSettings[settings.TenantID] = *settings
+	return nil
+}
+
+// AddTenantUsage увеличивает счётчики сообщества
+func (s *MemoryStorage) AddTenantUsage(ctx context.Context, tenantID, kind string, bytes int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := s.tenantUsage[tenantID]
+	usage.TenantID = tenantID
+	switch kind {
+	case models.TargetPost:
+		usage.Posts++
+	case models.TargetComment:
+		usage.Comments++
+	default:
+		return fmt.Errorf("unknown content kind %q", kind)
+	}
+	usage.Bytes += bytes
+	s.tenantUsage[tenantID] = usage
+	return nil
+}
+
+// GetTenantUsage возвращает копию счётчиков сообщества
+func (s *MemoryStorage) GetTenantUsage(ctx context.Context, tenantID string) (*models.TenantUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	usage := s.tenantUsage[tenantID]
+	usage.TenantID = tenantID
+	return &usage, nil
+}
+
+// RegisterDeviceToken сохраняет устройство, заменяя прежнюю регистрацию того же токена
+func (s *MemoryStorage) RegisterDeviceToken(ctx context.Context, token *models.DeviceToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Регистрация устройства %s пользователя %s в Memory", token.Platform, token.UserID)
+	s.removeDeviceToken(func(t models.DeviceToken) bool { return t.Token == token.Token })
+	s.deviceTokens = append(s.deviceTokens, *token)
+	return nil
+}
+
+// UnregisterDeviceToken удаляет устройство пользователя
+func (s *MemoryStorage) UnregisterDeviceToken(ctx context.Context, userID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Удаление устройства пользователя %s в Memory", userID)
+	s.removeDeviceToken(func(t models.DeviceToken) bool { return t.Token == token && t.UserID == userID })
+	return nil
+}
+
+// ListDeviceTokens возвращает устройства пользователя
+func (s *MemoryStorage) ListDeviceTokens(ctx context.Context, userID string) ([]models.DeviceToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := []models.DeviceToken{}
+	for _, t := range s.deviceTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+// removeDeviceToken удаляет устройства, подходящие под match; вызывается под блокировкой
+func (s *MemoryStorage) removeDeviceToken(match func(models.DeviceToken) bool) {
+	kept := s.deviceTokens[:0]
+	for _, t := range s.deviceTokens {
+		if !match(t) {
+			kept = append(kept, t)
+		}
+	}
+	s.deviceTokens = kept
+}
+
+// CreateSavedSearch сохраняет копию поиска
+func (s *MemoryStorage) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Сохранение поиска %s пользователя %s в Memory", search.ID, search.UserID)
+	s.savedSearches = append(s.savedSearches, *search)
+	return nil
+}
+
+// ListSavedSearches возвращает сохранённые поиски пользователя
+func (s *MemoryStorage) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	searches := []models.SavedSearch{}
+	for _, search := range s.savedSearches {
+		if search.UserID == userID {
+			searches = append(searches, search)
+		}
+	}
+	return searches, nil
+}
+
+// DeleteSavedSearch удаляет поиск пользователя
+func (s *MemoryStorage) DeleteSavedSearch(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Удаление поиска %s пользователя %s в Memory", id, userID)
+	for i, search := range s.savedSearches {
+		if search.ID == id && search.UserID == userID {
+			s.savedSearches = append(s.savedSearches[:i], s.savedSearches[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrSavedSearchNotFound
+}
+
+// CreateCollection сохраняет копию серии
+func (s *MemoryStorage) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Создание серии %s автора %s в Memory", collection.ID, collection.AuthorID)
+	if err := s.checkPostsExist(collection.PostIDs); err != nil {
+		return err
+	}
+	c := *collection
+	c.PostIDs = slices.Clone(collection.PostIDs)
+	s.collections = append(s.collections, c)
+	return nil
+}
+
+// GetCollection возвращает копию серии
+func (s *MemoryStorage) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, c := range s.collections {
+		if c.ID == id {
+			c.PostIDs = slices.Clone(c.PostIDs)
+			return &c, nil
+		}
+	}
+	return nil, storage.ErrCollectionNotFound
+}
+
+// UpdateCollection заменяет серию копией collection
+func (s *MemoryStorage) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Изменение серии %s в Memory: постов %d", collection.ID, len(collection.PostIDs))
+	for i := range s.collections {
+		if s.collections[i].ID != collection.ID {
+			continue
+		}
+		if err := s.checkPostsExist(collection.PostIDs); err != nil {
+			return err
+		}
+		s.collections[i].Title = collection.Title
+		s.collections[i].Description = collection.Description
+		s.collections[i].PostIDs = slices.Clone(collection.PostIDs)
+		s.collections[i].UpdatedAt = collection.UpdatedAt
+		return nil
+	}
+	return storage.ErrCollectionNotFound
+}
+
+// DeleteCollection удаляет серию
+func (s *MemoryStorage) DeleteCollection(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Удаление серии %s в Memory", id)
+	for i, c := range s.collections {
+		if c.ID == id {
+			s.collections = append(s.collections[:i], s.collections[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrCollectionNotFound
+}
+
+// ListPostCollections возвращает копии серий с постом postID
+func (s *MemoryStorage) ListPostCollections(ctx context.Context, postID string) ([]models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collections := []models.Collection{}
+	for _, c := range s.collections {
+		if slices.Contains(c.PostIDs, postID) {
+			c.PostIDs = slices.Clone(c.PostIDs)
+			collections = append(collections, c)
+		}
+	}
+	return collections, nil
+}
+
+// checkPostsExist возвращает ErrPostNotFound, если какого-то поста нет; вызывается под блокировкой
+func (s *MemoryStorage) checkPostsExist(postIDs []string) error {
+	for _, id := range postIDs {
+		if _, ok := s.posts[id]; !ok {
+			return storage.ErrPostNotFound
+		}
+	}
+	return nil
+}
+
+// ListAlertSavedSearches возвращает поиски с включёнными уведомлениями
+func (s *MemoryStorage) ListAlertSavedSearches(ctx context.Context) ([]models.SavedSearch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	searches := []models.SavedSearch{}
+	for _, search := range s.savedSearches {
+		if search.Alert {
+			searches = append(searches, search)
+		}
+	}
+	return searches, nil
+}
+
+// MarkSavedSearchChecked сдвигает CheckedAt поиска вперёд
+func (s *MemoryStorage) MarkSavedSearchChecked(ctx context.Context, id string, checkedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.savedSearches {
+		if s.savedSearches[i].ID == id && checkedAt.After(s.savedSearches[i].CheckedAt) {
+			s.savedSearches[i].CheckedAt = checkedAt
+		}
+	}
+	return nil
+}
+
+// SubscribeToPost сохраняет подписку пользователя на пост, если её ещё нет
+func (s *MemoryStorage) SubscribeToPost(ctx context.Context, userID, postID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Подписка пользователя %s на пост %s в Memory", userID, postID)
+	if _, ok := s.posts[postID]; !ok {
+		return storage.ErrPostNotFound
+	}
+	key := readKey{userID: userID, postID: postID}
+	if _, ok := s.postSubscriptions[key]; !ok {
+		s.postSubscriptions[key] = at
+	}
+	return nil
+}
+
+// UnsubscribeFromPost удаляет подписку пользователя на пост
+func (s *MemoryStorage) UnsubscribeFromPost(ctx context.Context, userID, postID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Отписка пользователя %s от поста %s в Memory", userID, postID)
+	delete(s.postSubscriptions, readKey{userID: userID, postID: postID})
+	return nil
+}
+
+// ListDigestSubscribers возвращает подписанных пользователей в порядке ID
+func (s *MemoryStorage) ListDigestSubscribers(ctx context.Context) ([]models.DigestSubscriber, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	subscribers := []models.DigestSubscriber{}
+	for key := range s.postSubscriptions {
+		if seen[key.userID] {
+			continue
+		}
+		seen[key.userID] = true
+		subscribers = append(subscribers, models.DigestSubscriber{UserID: key.userID, LastSentAt: s.digestsSent[key.userID]})
+	}
+	sort.Slice(subscribers, func(i, j int) bool { return subscribers[i].UserID < subscribers[j].UserID })
+	return subscribers, nil
+}
+
+// GetDigestComments собирает новые комментарии к постам, на которые подписан пользователь
+func (s *MemoryStorage) GetDigestComments(ctx context.Context, userID string, since, until time.Time, limit int) ([]models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := []models.Comment{}
+	for key, subscribedAt := range s.postSubscriptions {
+		if key.userID != userID {
+			continue
+		}
+		for _, comment := range s.comments[key.postID] {
+			if comment.AuthorID == userID || comment.Hidden {
+				continue
+			}
+			if comment.CreatedAt.After(since) && comment.CreatedAt.After(subscribedAt) && !comment.CreatedAt.After(until) {
+				result = append(result, *comment)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PostID != result[j].PostID {
+			return result[i].PostID < result[j].PostID
+		}
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// MarkDigestSent сохраняет границу последней сводки пользователя
+func (s *MemoryStorage) MarkDigestSent(ctx context.Context, userID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.digestsSent[userID] = until
+	return nil
+}
+
+// SetShadowBan включает или снимает теневой бан пользователя
+func (s *MemoryStorage) SetShadowBan(ctx context.Context, userID string, banned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Теневой бан пользователя %s в Memory: %t", userID, banned)
+	if banned {
+		s.shadowBans[userID] = true
+	} else {
+		delete(s.shadowBans, userID)
+	}
+	return nil
+}
+
+// IsShadowBanned сообщает, находится ли пользователь под теневым баном
+func (s *MemoryStorage) IsShadowBanned(ctx context.Context, userID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shadowBans[userID], nil
+}
+
+// SetFollow подписывает пользователя followerID на автора userID или отменяет подписку
+func (s *MemoryStorage) SetFollow(ctx context.Context, followerID, userID string, follow bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Подписка пользователя %s на %s в Memory: %t", followerID, userID, follow)
+	if !follow {
+		delete(s.follows[followerID], userID)
+		return nil
+	}
+	if s.follows[followerID] == nil {
+		s.follows[followerID] = make(map[string]bool)
+	}
+	s.follows[followerID][userID] = true
+	return nil
+}
+
+// IsFollowing сообщает, подписан ли пользователь followerID на автора userID
+func (s *MemoryStorage) IsFollowing(ctx context.Context, followerID, userID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.follows[followerID][userID], nil
+}
+
+// ListModerationRules возвращает правила автомодерации в порядке создания
+func (s *MemoryStorage) ListModerationRules(ctx context.Context) ([]models.ModerationRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]models.ModerationRule, len(s.rules))
+	copy(result, s.rules)
+	return result, nil
+}
+
+// CreateModerationRule сохраняет новое правило автомодерации
+func (s *MemoryStorage) CreateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Создание правила автомодерации в Memory: ID=%s, Kind=%s, Action=%s", rule.ID, rule.Kind, rule.Action)
+	s.rules = append(s.rules, *rule)
+	return nil
+}
+
+// UpdateModerationRule заменяет тип, шаблон, действие и тег правила
+func (s *MemoryStorage) UpdateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Обновление правила автомодерации в Memory: ID=%s", rule.ID)
+	for i := range s.rules {
+		if s.rules[i].ID == rule.ID {
+			s.rules[i].Kind = rule.Kind
+			s.rules[i].Pattern = rule.Pattern
+			s.rules[i].Action = rule.Action
+			s.rules[i].Tag = rule.Tag
+			*rule = s.rules[i]
+			return nil
+		}
+	}
+	return storage.ErrRuleNotFound
+}
+
+// DeleteModerationRule удаляет правило автомодерации
+func (s *MemoryStorage) DeleteModerationRule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Удаление правила автомодерации из Memory: ID=%s", id)
+	for i := range s.rules {
+		if s.rules[i].ID == id {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrRuleNotFound
+}
+
+// ListAllowedOperations возвращает документы разрешённых операций в порядке добавления
+func (s *MemoryStorage) ListAllowedOperations(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]string, len(s.allowedOperations))
+	copy(result, s.allowedOperations)
+	return result, nil
+}
+
+// AddAllowedOperation разрешает операцию, если она ещё не разрешена
+func (s *MemoryStorage) AddAllowedOperation(ctx context.Context, query string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.allowedOperations, query) {
+		return nil
+	}
+	log.Printf("Добавление разрешённой операции в Memory: %s", storage.ContentHash(query))
+	s.allowedOperations = append(s.allowedOperations, query)
+	return nil
+}
+
+// HoldContent ставит содержимое в очередь проверки
+func (s *MemoryStorage) HoldContent(ctx context.Context, item *models.HeldItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Содержимое %s %s задержано правилом %s в Memory", item.TargetType, item.TargetID, item.RuleID)
+	s.held = append(s.held, *item)
+	return nil
+}
+
+// ListHeldContent возвращает до limit записей очереди проверки, начиная со старых
+func (s *MemoryStorage) ListHeldContent(ctx context.Context, limit int) ([]models.HeldItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]models.HeldItem, len(s.held))
+	copy(result, s.held)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// ResolveHeldContent удаляет запись из очереди и при одобрении открывает содержимое всем
+func (s *MemoryStorage) ResolveHeldContent(ctx context.Context, id string, approve bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Решение по задержанному содержимому %s в Memory: approve=%t", id, approve)
+	for i, item := range s.held {
+		if item.ID != id {
+			continue
+		}
+		s.held = append(s.held[:i], s.held[i+1:]...)
+		if approve {
+			s.unhide(item)
+		}
+		return nil
+	}
+	return storage.ErrHeldItemNotFound
+}
+
+// unhide открывает задержанный пост или комментарий; вызывается под блокировкой.
+// Запись заменяется копией, так как ранее выданные указатели читаются без блокировки
+func (s *MemoryStorage) unhide(item models.HeldItem) {
+	switch item.TargetType {
+	case models.TargetPost:
+		if post, ok := s.posts[item.TargetID]; ok {
+			updated := *post
+			updated.Hidden = false
+			s.posts[item.TargetID] = &updated
+		}
+	case models.TargetComment:
+		for _, comments := range s.comments {
+			for i, comment := range comments {
+				if comment.ID == item.TargetID {
+					updated := *comment
+					updated.Hidden = false
+					comments[i] = &updated
+					return
+				}
+			}
+		}
+	}
+}
+
+// SetSpamStatus сохраняет результат проверки комментария на спам и его видимость.
+// Запись заменяется копией, как и в unhide
+func (s *MemoryStorage) SetSpamStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Статус проверки на спам комментария %s в Memory: %s, hidden=%t", commentID, status, hidden)
+	for _, comments := range s.comments {
+		for i, comment := range comments {
+			if comment.ID == commentID {
+				updated := *comment
+				updated.SpamStatus = status
+				updated.Hidden = hidden
+				comments[i] = &updated
+				return nil
+			}
+		}
+	}
+	return storage.ErrCommentNotFound
+}
+
+// SetToxicityScore сохраняет оценку токсичности и при hold скрывает видимый комментарий до решения модератора
+func (s *MemoryStorage) SetToxicityScore(ctx context.Context, score models.ToxicityScore, hold *models.HeldItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Оценка токсичности комментария %s в Memory: %.3f, hold=%t", score.CommentID, score.Score, hold != nil)
+	for _, comments := range s.comments {
+		for i, comment := range comments {
+			if comment.ID != score.CommentID {
+				continue
+			}
+			s.toxicity[score.CommentID] = score
+			if hold != nil && !comment.Hidden {
+				updated := *comment
+				updated.Hidden = true
+				comments[i] = &updated
+				s.held = append(s.held, *hold)
+			}
+			return nil
+		}
+	}
+	return storage.ErrCommentNotFound
+}
+
+// GetToxicityScores возвращает оценки токсичности комментариев по ID
+func (s *MemoryStorage) GetToxicityScores(ctx context.Context, commentIDs []string) (map[string]models.ToxicityScore, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]models.ToxicityScore, len(commentIDs))
+	for _, id := range commentIDs {
+		if score, ok := s.toxicity[id]; ok {
+			result[id] = score
+		}
+	}
+	return result, nil
+}
+
+// SetApprovalStatus сохраняет решение по комментарию с премодерацией и его видимость.
+// Запись заменяется копией, как и в unhide
+func (s *MemoryStorage) SetApprovalStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("Статус премодерации комментария %s в Memory: %s, hidden=%t", commentID, status, hidden)
+	for _, comments := range s.comments {
+		for i, comment := range comments {
+			if comment.ID == commentID {
+				updated := *comment
+				updated.ApprovalStatus = status
+				updated.Hidden = hidden
+				comments[i] = &updated
+				return nil
+			}
+		}
+	}
+	return storage.ErrCommentNotFound
+}
+
+// ListPendingComments возвращает до limit комментариев, ждущих решения модератора, начиная со старых
+func (s *MemoryStorage) ListPendingComments(ctx context.Context, postID string, limit int) ([]models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := []models.Comment{}
+	for id, comments := range s.comments {
+		if postID != "" && id != postID {
+			continue
+		}
+		for _, comment := range comments {
+			if comment.ApprovalStatus == models.ApprovalPending {
+				result = append(result, *comment)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// ListCommentsBySpamStatus возвращает до limit комментариев с указанным статусом, начиная с новых
+func (s *MemoryStorage) ListCommentsBySpamStatus(ctx context.Context, status string, limit int) ([]models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := []models.Comment{}
+	for _, comments := range s.comments {
+		for _, comment := range comments {
+			if comment.SpamStatus == status {
+				result = append(result, *comment)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// IteratePosts передаёт fn копии постов, снятые под блокировкой: fn может менять хранилище во время обхода
+func (s *MemoryStorage) IteratePosts(ctx context.Context, fn func(post *models.Post) error) error {
+	s.mu.RLock()
+	posts := make([]models.Post, 0, len(s.posts))
+	for _, post := range s.posts {
+		posts = append(posts, *post)
+	}
+	s.mu.RUnlock()
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.Before(posts[j].CreatedAt)
+		}
+		return posts[i].ID < posts[j].ID
+	})
+	for i := range posts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&posts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IterateComments передаёт fn копии комментариев, как IteratePosts
+func (s *MemoryStorage) IterateComments(ctx context.Context, postID string, fn func(comment *models.Comment) error) error {
+	s.mu.RLock()
+	var comments []models.Comment
+	for id, postComments := range s.comments {
+		if postID != "" && id != postID {
+			continue
+		}
+		for _, comment := range postComments {
+			comments = append(comments, *comment)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(comments, func(i, j int) bool {
+		return commentBefore(&comments[i], &comments[j], models.SortAsc)
+	})
+	for i := range comments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&comments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListContents возвращает до limit записей содержимого постов или комментариев с ID больше afterID по возрастанию ID
+func (s *MemoryStorage) ListContents(ctx context.Context, kind, afterID string, limit int) ([]storage.ContentRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := []storage.ContentRecord{}
+	switch kind {
+	case models.TargetPost:
+		for _, post := range s.posts {
+			if post.ID > afterID {
+				result = append(result, storage.ContentRecord{ID: post.ID, Content: post.Content})
+			}
+		}
+	case models.TargetComment:
+		for _, comments := range s.comments {
+			for _, comment := range comments {
+				if comment.ID > afterID {
+					result = append(result, storage.ContentRecord{ID: comment.ID, Content: comment.Content})
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown content kind %q", kind)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// ReplaceContent заменяет содержимое, если оно не изменилось. Запись заменяется копией, как и в unhide
+func (s *MemoryStorage) ReplaceContent(ctx context.Context, kind, id, previous, content string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch kind {
+	case models.TargetPost:
+		post, exists := s.posts[id]
+		if !exists || post.Content != previous {
+			return false, nil
+		}
+		updated := *post
+		updated.Content = content
+		s.posts[id] = &updated
+		return true, nil
+	case models.TargetComment:
+		for _, comments := range s.comments {
+			for i, comment := range comments {
+				if comment.ID == id {
+					if comment.Content != previous {
+						return false, nil
+					}
+					updated := *comment
+					updated.Content = content
+					comments[i] = &updated
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown content kind %q", kind)
+}
+
+// AnonymizeUserContent обезличивает записи пользователя; записи заменяются копиями, как и в ReplaceContent
+func (s *MemoryStorage) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	switch kind {
+	case models.TargetPost:
+		for id, post := range s.posts {
+			if post.AuthorID == userID {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		if len(ids) > limit {
+			ids = ids[:limit]
+		}
+		for _, id := range ids {
+			updated := *s.posts[id]
+			updated.AuthorID = models.DeletedUserID
+			updated.Title = ""
+			updated.Content = ""
+			updated.Excerpt = ""
+			updated.Tags = nil
+			updated.Language = ""
+			updated.Slug = id
+			s.posts[id] = &updated
+			for slug, postID := range s.slugs {
+				if postID == id {
+					delete(s.slugs, slug)
+				}
+			}
+			if _, taken := s.slugs[id]; !taken {
+				s.slugs[id] = id
+			}
+			delete(s.references, id)
+			delete(s.previews, id)
+		}
+	case models.TargetComment:
+		type position struct {
+			postID string
+			index  int
+		}
+		found := make(map[string]position)
+		for postID, comments := range s.comments {
+			for i, comment := range comments {
+				if comment.AuthorID == userID {
+					ids = append(ids, comment.ID)
+					found[comment.ID] = position{postID, i}
+				}
+			}
+		}
+		slices.Sort(ids)
+		if len(ids) > limit {
+			ids = ids[:limit]
+		}
+		for _, id := range ids {
+			pos := found[id]
+			updated := *s.comments[pos.postID][pos.index]
+			updated.AuthorID = models.DeletedUserID
+			updated.Content = ""
+			updated.ContentHash = ""
+			updated.Language = ""
+			s.comments[pos.postID][pos.index] = &updated
+		}
+	default:
+		return nil, fmt.Errorf("unknown content kind %q", kind)
+	}
+	log.Printf("Обезличено в Memory записей %s пользователя %s: %d", kind, userID, len(ids))
+	return ids, nil
+}
+
+// Stats возвращает количество записей в таблицах in-memory хранилища под именами таблиц PostgreSQL.
+// Размер данных учитывается только для текстов постов и комментариев; статистики запросов нет
+func (s *MemoryStorage) Stats(ctx context.Context, slowQueries int) (*models.StorageStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var postBytes, commentCount, commentBytes, previewCount, reactionCount, referenceCount int64
+	for _, post := range s.posts {
+		postBytes += int64(len(post.Title) + len(post.Content))
+	}
+	for _, comments := range s.comments {
+		for _, comment := range comments {
+			commentCount++
+			commentBytes += int64(len(comment.Content))
+		}
+	}
+	for _, previews := range s.previews {
+		previewCount += int64(len(previews))
+	}
+	for _, reactions := range s.reactions {
+		reactionCount += int64(len(reactions))
+	}
+	for _, references := range s.references {
+		referenceCount += int64(len(references))
+	}
+	return &models.StorageStats{
+		Backend: "memory",
+		Tables: []models.TableStats{
+			{Name: "posts", Rows: int64(len(s.posts)), DataBytes: postBytes},
+			{Name: "comments", Rows: commentCount, DataBytes: commentBytes},
+			{Name: "link_previews", Rows: previewCount},
+			{Name: "reactions", Rows: reactionCount},
+			{Name: "comment_votes", Rows: int64(len(s.votes))},
+			{Name: "categories", Rows: int64(len(s.categories))},
+			{Name: "post_slugs", Rows: int64(len(s.slugs))},
+			{Name: "post_references", Rows: referenceCount},
+			{Name: "quota_usage", Rows: int64(len(s.quotas))},
+			{Name: "shadow_bans", Rows: int64(len(s.shadowBans))},
+			{Name: "moderation_rules", Rows: int64(len(s.rules))},
+			{Name: "allowed_operations", Rows: int64(len(s.allowedOperations))},
+			{Name: "held_content", Rows: int64(len(s.held))},
+			{Name: "thread_reads", Rows: int64(len(s.reads))},
+			{Name: "user_preferences", Rows: int64(len(s.preferences))},
+			{Name: "tenant_settings", Rows: int64(len(s.tenantSettings))},
+			{Name: "tenant_usage", Rows: int64(len(s.tenantUsage))},
+			{Name: "post_subscriptions", Rows: int64(len(s.postSubscriptions))},
+			{Name: "digest_deliveries", Rows: int64(len(s.digestsSent))},
+			{Name: "device_tokens", Rows: int64(len(s.deviceTokens))},
+			{Name: "saved_searches", Rows: int64(len(s.savedSearches))},
+		},
+	}, nil
+}
+
+// Ping всегда успешен: in-memory хранилище доступно, пока работает процесс
+func (s *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
 // Close очищает in-memory хранилище
 func (s *MemoryStorage) Close() error {
 	s.mu.Lock()
@@ -190,6 +1867,16 @@ func (s *MemoryStorage) Close() error {
 	log.Println("Закрытие MemoryStorage")
 	s.posts = make(map[string]*models.Post)
 	s.comments = make(map[string][]*models.Comment)
+	s.previews = make(map[string][]models.LinkPreview)
+	s.reactions = make(map[string][]models.Reaction)
+	s.quotas = make(map[quotaKey]quotaUsage)
+	s.shadowBans = make(map[string]bool)
+	s.votes = make(map[voteKey]int)
+	s.slugs = make(map[string]string)
+	s.rules = nil
+	s.allowedOperations = nil
+	s.held = nil
+	s.categories = nil
 	log.Println("MemoryStorage успешно очищено")
 	return nil
 }
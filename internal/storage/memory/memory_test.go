@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/storagetest"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -72,7 +74,7 @@ func TestMemoryStorage(t *testing.T) {
 		assert.NoError(t, store.CreatePost(ctx, post2))
 
 		// Тестируем пагинацию
-		result, err := store.ListPosts(ctx, 1, nil)
+		result, err := store.ListPosts(ctx, 1, nil, storage.PostFilter{})
 		assert.NoError(t, err, "Ошибка при получении списка постов")
 		assert.Len(t, result.Posts, 1, "Ожидался один пост")
 		assert.Equal(t, post2.ID, result.Posts[0].ID, "Ожидался более новый пост")
@@ -80,7 +82,7 @@ func TestMemoryStorage(t *testing.T) {
 		assert.NotNil(t, result.NextCursor, "Ожидался ненулевой курсор")
 
 		// Тестируем с курсором
-		result, err = store.ListPosts(ctx, 1, result.NextCursor)
+		result, err = store.ListPosts(ctx, 1, result.NextCursor, storage.PostFilter{})
 		assert.NoError(t, err, "Ошибка при получении постов с курсором")
 		assert.Len(t, result.Posts, 1, "Ожидался один пост")
 		assert.Equal(t, post1.ID, result.Posts[0].ID, "Ожидался более старый пост")
@@ -110,7 +112,7 @@ func TestMemoryStorage(t *testing.T) {
 		err := store.CreateComment(ctx, comment)
 		assert.NoError(t, err, "Ошибка при создании комментария")
 
-		comments, err := store.GetComments(ctx, post.ID, nil, 10, nil)
+		comments, err := store.GetComments(ctx, post.ID, nil, 10, nil, models.SortDesc)
 		assert.NoError(t, err, "Ошибка при получении комментариев")
 		assert.Len(t, comments.Comments, 1, "Ожидался один комментарий")
 		assert.Equal(t, comment.ID, comments.Comments[0].ID, "Полученный комментарий не совпадает")
@@ -149,7 +151,7 @@ func TestMemoryStorage(t *testing.T) {
 		assert.NoError(t, store.CreateComment(ctx, parentComment))
 		assert.NoError(t, store.CreateComment(ctx, reply))
 
-		comments, err := store.GetComments(ctx, post.ID, &parentComment.ID, 10, nil)
+		comments, err := store.GetComments(ctx, post.ID, &parentComment.ID, 10, nil, models.SortDesc)
 		assert.NoError(t, err, "Ошибка при получении ответов")
 		assert.Len(t, comments.Comments, 1, "Ожидался один ответ")
 		assert.Equal(t, reply.ID, comments.Comments[0].ID, "Полученный ответ не совпадает")
@@ -175,4 +177,145 @@ func TestMemoryStorage(t *testing.T) {
 		_, err = store.GetPost(ctx, post.ID)
 		assert.Error(t, err, "Ожидалась ошибка после очистки хранилища")
 	})
+
+	t.Run("SaveLinkPreview and GetLinkPreviews", func(t *testing.T) {
+		store := New()
+		ctx := context.Background()
+
+		post := &models.Post{
+			ID:            uuid.New().String(),
+			Title:         "Тестовый пост",
+			Content:       "https://example.com",
+			AuthorID:      "user1",
+			AllowComments: true,
+			CreatedAt:     time.Now(),
+		}
+		assert.NoError(t, store.CreatePost(ctx, post))
+
+		preview := &models.LinkPreview{PostID: post.ID, URL: "https://example.com", Title: "Старый"}
+		assert.NoError(t, store.SaveLinkPreview(ctx, preview))
+		preview.Title = "Новый"
+		assert.NoError(t, store.SaveLinkPreview(ctx, preview))
+
+		previews, err := store.GetLinkPreviews(ctx, post.ID)
+		assert.NoError(t, err)
+		assert.Len(t, previews, 1, "Превью для одного URL должно заменяться")
+		assert.Equal(t, "Новый", previews[0].Title)
+
+		err = store.SaveLinkPreview(ctx, &models.LinkPreview{PostID: "non-existent-id", URL: "https://example.com"})
+		assert.Error(t, err, "Ожидалась ошибка для несуществующего поста")
+	})
+
+	t.Run("ToggleReaction and GetReactionCounts", func(t *testing.T) {
+		store := New()
+		ctx := context.Background()
+
+		added, err := store.ToggleReaction(ctx, &models.Reaction{TargetID: "post1", UserID: "user1", Emoji: "👍"})
+		assert.NoError(t, err)
+		assert.True(t, added, "Первая реакция должна добавляться")
+		_, err = store.ToggleReaction(ctx, &models.Reaction{TargetID: "post1", UserID: "user2", Emoji: "👍"})
+		assert.NoError(t, err)
+		_, err = store.ToggleReaction(ctx, &models.Reaction{TargetID: "post1", UserID: "user1", Emoji: "🎉"})
+		assert.NoError(t, err)
+
+		counts, err := store.GetReactionCounts(ctx, []string{"post1", "post2"})
+		assert.NoError(t, err)
+		assert.Equal(t, []models.ReactionCount{{Emoji: "👍", Count: 2}, {Emoji: "🎉", Count: 1}}, counts["post1"])
+		assert.Empty(t, counts["post2"])
+
+		// Повторная реакция снимает её
+		added, err = store.ToggleReaction(ctx, &models.Reaction{TargetID: "post1", UserID: "user1", Emoji: "👍"})
+		assert.NoError(t, err)
+		assert.False(t, added, "Повторная реакция должна сниматься")
+		counts, err = store.GetReactionCounts(ctx, []string{"post1"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, counts["post1"][0].Count)
+	})
+
+	t.Run("GetComments order and cursors", func(t *testing.T) {
+		store := New()
+		ctx := context.Background()
+
+		post := &models.Post{
+			ID:            uuid.New().String(),
+			Title:         "Тестовый пост",
+			Content:       "Содержимое",
+			AuthorID:      "user1",
+			AllowComments: true,
+			CreatedAt:     time.Now(),
+		}
+		assert.NoError(t, store.CreatePost(ctx, post))
+
+		base := time.Now()
+		var ids []string
+		for i := 0; i < 3; i++ {
+			comment := &models.Comment{
+				ID:        uuid.New().String(),
+				PostID:    post.ID,
+				AuthorID:  "user1",
+				Content:   "Комментарий",
+				CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			}
+			assert.NoError(t, store.CreateComment(ctx, comment))
+			ids = append(ids, comment.ID)
+		}
+
+		// От старых к новым
+		page, err := store.GetComments(ctx, post.ID, nil, 2, nil, models.SortAsc)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{ids[0], ids[1]}, []string{page.Comments[0].ID, page.Comments[1].ID})
+		assert.NotNil(t, page.NextCursor)
+
+		page, err = store.GetComments(ctx, post.ID, nil, 2, page.NextCursor, models.SortAsc)
+		assert.NoError(t, err)
+		assert.Len(t, page.Comments, 1)
+		assert.Equal(t, ids[2], page.Comments[0].ID)
+		assert.Nil(t, page.NextCursor)
+
+		// От новых к старым
+		page, err = store.GetComments(ctx, post.ID, nil, 2, nil, models.SortDesc)
+		assert.NoError(t, err)
+		assert.Equal(t, ids[2], page.Comments[0].ID)
+
+		// Курсор другого направления отклоняется
+		_, err = store.GetComments(ctx, post.ID, nil, 2, page.NextCursor, models.SortAsc)
+		assert.ErrorIs(t, err, storage.ErrCursorOrderMismatch)
+
+		_, err = store.GetComments(ctx, post.ID, nil, 2, stringPtr("мусор"), models.SortDesc)
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	})
+	t.Run("CreateComment dedupe window", func(t *testing.T) {
+		store := New()
+		ctx := context.Background()
+		store.SetDedupeWindow(5 * time.Second)
+		post := &models.Post{ID: "dedupe", Title: "Пост", AllowComments: true, CreatedAt: time.Now()}
+		assert.NoError(t, store.CreatePost(ctx, post))
+
+		first := &models.Comment{ID: "c1", PostID: post.ID, AuthorID: "user1", Content: "Дубль", CreatedAt: time.Now()}
+		assert.NoError(t, store.CreateComment(ctx, first))
+
+		second := &models.Comment{ID: "c2", PostID: post.ID, AuthorID: "user1", Content: "Дубль", CreatedAt: first.CreatedAt.Add(time.Second)}
+		err := store.CreateComment(ctx, second)
+		var dup *storage.DuplicateCommentError
+		assert.ErrorAs(t, err, &dup)
+		assert.Equal(t, "c1", dup.Existing.ID)
+
+		later := &models.Comment{ID: "c3", PostID: post.ID, AuthorID: "user1", Content: "Дубль", CreatedAt: first.CreatedAt.Add(time.Minute)}
+		assert.NoError(t, store.CreateComment(ctx, later), "Вне окна комментарий создаётся")
+
+		page, err := store.GetComments(ctx, post.ID, nil, 10, nil, models.SortDesc)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, page.TotalCount)
+	})
+}
+
+// TestMemoryStorage_Conformance проверяет MemoryStorage общим набором тестов хранилищ
+func TestMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New()
+	})
+}
+
+func stringPtr(s string) *string {
+	return &s
 }
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// ArchiveComments переносит до limit комментариев, созданных раньше before, из comments в comments_archive,
+// начиная с самых старых. Комментарии из очереди проверки не переносятся, пока модератор не примет решение.
+// Голоса за перенесённые комментарии удаляются, счётчики голосов и post_comment_counts сохраняются.
+// Возвращает количество перенесённых комментариев
+func (s *PostgresStorage) ArchiveComments(ctx context.Context, before time.Time, limit int) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("ArchiveComments", err)
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Триггер счётчиков не уменьшает post_comment_counts при удалении строк этой транзакцией
+	if _, err := tx.Exec(ctx, `SELECT set_config('system.moving_comments', 'on', true)`); err != nil {
+		observeTimeout("ArchiveComments", err)
+		return 0, fmt.Errorf("failed to mark archiving transaction: %v", err)
+	}
+	tag, err := tx.Exec(ctx, `
+		WITH moved AS (
+			DELETE FROM comments
+			WHERE id IN (
+				SELECT id FROM comments c
+				WHERE created_at < $1
+				AND NOT EXISTS (SELECT 1 FROM held_content h WHERE h.target_id = c.id)
+				AND approval_status <> 'PENDING'
+				ORDER BY created_at, id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, post_id, parent_id, author_id, content, content_hash, format, created_at, hidden, tags, spam_status, upvotes, downvotes, best_score, language, quoted_comment_id, approval_status
+		), votes AS (
+			DELETE FROM comment_votes WHERE comment_id IN (SELECT id FROM moved)
+		)
+		INSERT INTO comments_archive (id, post_id, parent_id, author_id, content, content_hash, format, created_at, hidden, tags, spam_status, upvotes, downvotes, best_score, language, quoted_comment_id, approval_status)
+		SELECT id, post_id, parent_id, author_id, content, content_hash, format, created_at, hidden, tags, spam_status, upvotes, downvotes, best_score, language, quoted_comment_id, approval_status
+		FROM moved`, before, limit)
+	if err != nil {
+		observeTimeout("ArchiveComments", err)
+		log.Printf("Ошибка при переносе комментариев в архив: %v", err)
+		return 0, fmt.Errorf("failed to archive comments: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("ArchiveComments", err)
+		return 0, fmt.Errorf("failed to commit archived comments: %v", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// archiveComments переносит в архив все комментарии старше months месяцев пачками по batchSize
+func (s *PostgresStorage) archiveComments(months, batchSize int) (int64, error) {
+	before := s.clock.Now().AddDate(0, -months, 0)
+	var total int64
+	for {
+		select {
+		case <-s.archiveStop:
+			return total, nil
+		default:
+		}
+		moved, err := s.ArchiveComments(context.Background(), before, batchSize)
+		total += moved
+		metrics.ArchivedComments.Add(float64(moved))
+		if err != nil || moved < int64(batchSize) {
+			return total, err
+		}
+	}
+}
+
+// runCommentArchiver периодически переносит старые комментарии в архив до закрытия хранилища
+func (s *PostgresStorage) runCommentArchiver(months int, interval time.Duration, batchSize int) {
+	defer close(s.archiveDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		total, err := s.archiveComments(months, batchSize)
+		if err != nil {
+			log.Printf("Фоновый перенос комментариев в архив не удался: %v", err)
+		} else if total > 0 {
+			log.Printf("В архив перенесено комментариев старше %d мес.: %d", months, total)
+		}
+		select {
+		case <-s.archiveStop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// missingCommentError возвращает ErrCommentArchived, если комментария id нет в рабочей таблице, но он есть в архиве,
+// иначе ErrCommentNotFound
+func (s *PostgresStorage) missingCommentError(ctx context.Context, tx pgx.Tx, id string) error {
+	var archived bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM comments_archive WHERE id=$1)`, id).Scan(&archived); err != nil {
+		observeTimeout("VoteComment", err)
+		return fmt.Errorf("failed to check archived comment: %v", err)
+	}
+	if archived {
+		return storage.ErrCommentArchived
+	}
+	return storage.ErrCommentNotFound
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ReconcileCommentCounts пересчитывает post_comment_counts по видимым комментариям, включая архивные,
+// и исправляет расхождения, накопившиеся, например, после ручных правок данных.
+// Возвращает количество исправленных строк.
+func (s *PostgresStorage) ReconcileCommentCounts(ctx context.Context) (int64, error) {
+	log.Println("Сверка счётчиков комментариев")
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	fixed, err := s.pool.Exec(ctx, `
+		INSERT INTO post_comment_counts (post_id, parent_id, comment_count)
+		SELECT post_id, COALESCE(parent_id, ''), COUNT(*)
+		FROM comments_all
+		WHERE NOT hidden
+		GROUP BY post_id, COALESCE(parent_id, '')
+		ON CONFLICT (post_id, parent_id) DO UPDATE
+		SET comment_count = EXCLUDED.comment_count
+		WHERE post_comment_counts.comment_count <> EXCLUDED.comment_count`)
+	if err != nil {
+		observeTimeout("ReconcileCommentCounts", err)
+		log.Printf("Ошибка при сверке счётчиков комментариев: %v", err)
+		return 0, fmt.Errorf("failed to reconcile comment counts: %v", err)
+	}
+	orphaned, err := s.pool.Exec(ctx, `
+		UPDATE post_comment_counts AS c
+		SET comment_count = 0
+		WHERE comment_count <> 0 AND NOT EXISTS (
+			SELECT 1 FROM comments_all a
+			WHERE a.post_id = c.post_id AND COALESCE(a.parent_id, '') = c.parent_id
+			AND NOT a.hidden
+		)`)
+	if err != nil {
+		observeTimeout("ReconcileCommentCounts", err)
+		log.Printf("Ошибка при обнулении счётчиков комментариев: %v", err)
+		return 0, fmt.Errorf("failed to reset comment counts: %v", err)
+	}
+	total := fixed.RowsAffected() + orphaned.RowsAffected()
+	log.Printf("Сверка счётчиков комментариев завершена, исправлено строк: %d", total)
+	return total, nil
+}
+
+// runCountReconciler периодически сверяет счётчики комментариев до закрытия хранилища
+func (s *PostgresStorage) runCountReconciler(interval time.Duration) {
+	defer close(s.reconcileDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.reconcileStop:
+			return
+		case <-ticker.C:
+			if _, err := s.ReconcileCommentCounts(context.Background()); err != nil {
+				log.Printf("Фоновая сверка счётчиков не удалась: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Проверки согласованности данных, выполняемые Fsck
+const (
+	FsckMissingPost   = "missing-post"
+	FsckMissingParent = "missing-parent"
+	FsckDanglingChain = "dangling-chain"
+	FsckCountDrift    = "count-drift"
+	FsckBrokenCursor  = "broken-cursor"
+)
+
+// fsckExamples - сколько затронутых записей приводится в отчёте по каждой проверке
+const fsckExamples = 10
+
+// FsckIssue - результат одной проверки согласованности
+type FsckIssue struct {
+	Check       string
+	Description string
+	// Count - количество затронутых записей до исправления
+	Count int64
+	// Examples - ID первых затронутых записей, для count-drift - ID постов
+	Examples []string
+	// Repairable сообщает, умеет ли Fsck исправлять проблему
+	Repairable bool
+	// Repaired - количество изменённых при исправлении записей; 0, если исправление не запрашивалось
+	Repaired int64
+}
+
+// FsckReport - результат проверки согласованности данных
+type FsckReport struct {
+	Issues []FsckIssue
+}
+
+// Unresolved возвращает количество записей с проблемами, оставшимися после проверки
+func (r *FsckReport) Unresolved() int64 {
+	var total int64
+	for _, issue := range r.Issues {
+		if issue.Repaired == 0 {
+			total += issue.Count
+		}
+	}
+	return total
+}
+
+// fsckCheck описывает проверку: find возвращает ID затронутых записей и их общее количество
+// в последнем столбце, repair исправляет проблему и возвращает число изменённых записей
+type fsckCheck struct {
+	name        string
+	description string
+	find        string
+	args        []any
+	repair      func(ctx context.Context, tx pgx.Tx) (int64, error)
+}
+
+// reachableComments находит комментарии, от которых цепочка parent_id доходит до корня.
+// Комментарий с отсутствующим родителем считается корнем: его проверяет missing-post или missing-parent
+const reachableComments = `
+	WITH RECURSIVE reachable(id) AS (
+		SELECT c.id FROM comments_all c
+		WHERE c.parent_id IS NULL OR NOT EXISTS (
+			SELECT 1 FROM comments_all p WHERE p.id = c.parent_id AND p.post_id = c.post_id
+		)
+		UNION
+		SELECT c.id FROM comments_all c JOIN reachable r ON c.parent_id = r.id
+	)`
+
+// Fsck проверяет согласованность комментариев после ручных правок базы: комментарии без поста
+// или родителя, цепочки parent_id, не доходящие до корня, расхождения post_comment_counts
+// и ключи, для которых нельзя выдать курсор. С repair исправимые проблемы устраняются
+// в отдельной транзакции для каждой проверки, а после всех исправлений пересчитываются счётчики.
+// Проверки просматривают таблицы целиком, поэтому queryTimeout к ним не применяется
+func (s *PostgresStorage) Fsck(ctx context.Context, repair bool) (*FsckReport, error) {
+	log.Printf("Проверка согласованности данных, исправление: %t", repair)
+	minCursorTime, maxCursorTime := time.Unix(0, math.MinInt64).UTC(), time.Unix(0, math.MaxInt64).UTC()
+	checks := []fsckCheck{
+		{
+			name:        FsckMissingPost,
+			description: "comments referencing a missing post",
+			find: `
+				SELECT c.id, COUNT(*) OVER () FROM comments_all c
+				WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id)
+				ORDER BY c.id LIMIT $1`,
+			repair: func(ctx context.Context, tx pgx.Tx) (int64, error) {
+				return execAll(ctx, tx,
+					`DELETE FROM comment_votes v USING comments c
+					WHERE c.id = v.comment_id AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id)`,
+					`DELETE FROM comments c WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id)`,
+					`DELETE FROM comments_archive c WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id)`,
+				)
+			},
+		},
+		{
+			name:        FsckMissingParent,
+			description: "replies whose parent is missing or belongs to another post",
+			find: `
+				SELECT c.id, COUNT(*) OVER () FROM comments_all c
+				WHERE c.parent_id IS NOT NULL AND NOT EXISTS (
+					SELECT 1 FROM comments_all p WHERE p.id = c.parent_id AND p.post_id = c.post_id
+				)
+				ORDER BY c.id LIMIT $1`,
+			// Ответ без родителя становится корневым комментарием, чтобы не терять его содержимое
+			repair: func(ctx context.Context, tx pgx.Tx) (int64, error) {
+				const detach = `
+					UPDATE %s c SET parent_id = NULL
+					WHERE c.parent_id IS NOT NULL AND NOT EXISTS (
+						SELECT 1 FROM comments_all p WHERE p.id = c.parent_id AND p.post_id = c.post_id
+					)`
+				return execAll(ctx, tx, fmt.Sprintf(detach, "comments"), fmt.Sprintf(detach, "comments_archive"))
+			},
+		},
+		{
+			name:        FsckDanglingChain,
+			description: "comments whose parent_id chain never reaches a root (cycles)",
+			find: reachableComments + `
+				SELECT c.id, COUNT(*) OVER () FROM comments_all c
+				WHERE NOT EXISTS (SELECT 1 FROM reachable r WHERE r.id = c.id)
+				ORDER BY c.id LIMIT $1`,
+			// В цикле хотя бы один комментарий создан не раньше своего родителя; такие комментарии
+			// становятся корневыми, что разрывает каждый цикл и возвращает в дерево цепочки под ним
+			repair: func(ctx context.Context, tx pgx.Tx) (int64, error) {
+				const detach = reachableComments + `
+					UPDATE %s c SET parent_id = NULL
+					FROM comments_all p
+					WHERE p.id = c.parent_id AND p.created_at >= c.created_at
+					AND NOT EXISTS (SELECT 1 FROM reachable r WHERE r.id = c.id)`
+				return execAll(ctx, tx, fmt.Sprintf(detach, "comments"), fmt.Sprintf(detach, "comments_archive"))
+			},
+		},
+		{
+			name:        FsckCountDrift,
+			description: "post_comment_counts rows that differ from visible comments",
+			find: `
+				WITH actual AS (
+					SELECT post_id, COALESCE(parent_id, '') AS parent_id, COUNT(*) AS comment_count
+					FROM comments_all
+					WHERE NOT hidden
+					GROUP BY post_id, COALESCE(parent_id, '')
+				)
+				SELECT COALESCE(a.post_id, c.post_id), COUNT(*) OVER ()
+				FROM actual a
+				FULL JOIN post_comment_counts c ON c.post_id = a.post_id AND c.parent_id = a.parent_id
+				WHERE COALESCE(a.comment_count, 0) <> COALESCE(c.comment_count, 0)
+				ORDER BY 1 LIMIT $1`,
+		},
+		{
+			name:        FsckBrokenCursor,
+			description: "duplicate IDs, NaN scores or timestamps outside the cursor range; fix manually",
+			find: `
+				SELECT id, COUNT(*) OVER () FROM (
+					SELECT id FROM comments_all GROUP BY id HAVING COUNT(*) > 1
+					UNION
+					SELECT id FROM comments_all WHERE created_at NOT BETWEEN $2 AND $3 OR best_score = 'NaN'
+					UNION
+					SELECT id FROM posts WHERE created_at NOT BETWEEN $2 AND $3
+				) broken
+				ORDER BY id LIMIT $1`,
+			args: []any{minCursorTime, maxCursorTime},
+		},
+	}
+
+	report := &FsckReport{}
+	for _, check := range checks {
+		issue, err := s.runFsckCheck(ctx, check, repair)
+		if err != nil {
+			return report, err
+		}
+		if issue.Count == 0 {
+			continue
+		}
+		// Счётчики проверяются после остальных исправлений и учитывают перенесённые ими ответы
+		if check.name == FsckCountDrift {
+			issue.Repairable = true
+			if repair {
+				if issue.Repaired, err = s.ReconcileCommentCounts(ctx); err != nil {
+					return report, err
+				}
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+	log.Printf("Проверка согласованности завершена: проблем %d, не исправлено записей %d", len(report.Issues), report.Unresolved())
+	return report, nil
+}
+
+// runFsckCheck выполняет одну проверку и, если запрошено, исправление
+func (s *PostgresStorage) runFsckCheck(ctx context.Context, check fsckCheck, repair bool) (FsckIssue, error) {
+	issue := FsckIssue{Check: check.name, Description: check.description, Repairable: check.repair != nil}
+	rows, err := s.pool.Query(ctx, check.find, append([]any{fsckExamples}, check.args...)...)
+	if err != nil {
+		log.Printf("Ошибка при проверке %s: %v", check.name, err)
+		return issue, fmt.Errorf("failed to run %s check: %v", check.name, err)
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id, &issue.Count); err != nil {
+			rows.Close()
+			return issue, fmt.Errorf("failed to scan %s check: %v", check.name, err)
+		}
+		issue.Examples = append(issue.Examples, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return issue, fmt.Errorf("failed to run %s check: %v", check.name, err)
+	}
+	if issue.Count == 0 || !repair || check.repair == nil {
+		return issue, nil
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return issue, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	if issue.Repaired, err = check.repair(ctx, tx); err != nil {
+		log.Printf("Ошибка при исправлении %s: %v", check.name, err)
+		return issue, fmt.Errorf("failed to repair %s: %v", check.name, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return issue, fmt.Errorf("failed to commit %s repair: %v", check.name, err)
+	}
+	log.Printf("Проверка %s: исправлено записей %d из %d", check.name, issue.Repaired, issue.Count)
+	return issue, nil
+}
+
+// execAll выполняет запросы в транзакции tx и возвращает суммарное число изменённых строк
+func execAll(ctx context.Context, tx pgx.Tx, queries ...string) (int64, error) {
+	var total int64
+	for _, query := range queries {
+		tag, err := tx.Exec(ctx, query)
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+	}
+	return total, nil
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// iterationCursor - имя серверного курсора обхода; у каждого обхода своё подключение, поэтому имя общее
+const iterationCursor = "iteration"
+
+// IteratePosts читает посты серверным курсором, см. iterate
+func (s *PostgresStorage) IteratePosts(ctx context.Context, fn func(post *models.Post) error) error {
+	return iterate(ctx, s, "IteratePosts", `
+		SELECT id, title, content, format, author_id, allow_comments, created_at, hidden, tags, category_id, slug, language, visibility, require_approval, excerpt
+		FROM posts
+		ORDER BY created_at, id`, nil,
+		func(rows pgx.Rows) (*models.Post, error) {
+			var p models.Post
+			err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.Format, &p.AuthorID, &p.AllowComments, &p.CreatedAt, &p.Hidden, &p.Tags, &p.CategoryID, &p.Slug, &p.Language, &p.Visibility, &p.RequireApproval, &p.Excerpt)
+			return &p, err
+		}, fn)
+}
+
+// IterateComments читает комментарии вместе с архивом серверным курсором, см. iterate
+func (s *PostgresStorage) IterateComments(ctx context.Context, postID string, fn func(comment *models.Comment) error) error {
+	return iterate(ctx, s, "IterateComments", `
+		SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status
+		FROM comments_all
+		WHERE $1::TEXT = '' OR post_id=$1
+		ORDER BY created_at, id`, []any{postID},
+		func(rows pgx.Rows) (*models.Comment, error) {
+			var c models.Comment
+			err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus)
+			return &c, err
+		}, fn)
+}
+
+// iterate открывает query серверным курсором на отдельном подключении и передаёт fn строки, читая их
+// пачками по IterateBatchSize. Курсор живёт в транзакции только для чтения с уровнем REPEATABLE READ, поэтому
+// обход видит один снимок данных, а fn может обращаться к хранилищу: основное подключение курсор не занимает.
+// QueryTimeout ограничивает чтение каждой пачки, а не весь обход
+func iterate[T any](ctx context.Context, s *PostgresStorage, op, query string, args []any, scan func(pgx.Rows) (T, error), fn func(T) error) error {
+	conn, err := connectOne(ctx, s.pool.Config().ConnConfig, s.opts)
+	if err != nil {
+		return fmt.Errorf("failed to connect for %s: %v", op, err)
+	}
+	defer conn.Close(context.Background())
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+	if _, err := tx.Exec(ctx, `DECLARE `+iterationCursor+` NO SCROLL CURSOR FOR `+query, args...); err != nil {
+		log.Printf("Ошибка при открытии курсора %s: %v", op, err)
+		return fmt.Errorf("failed to declare cursor: %v", err)
+	}
+
+	fetch := fmt.Sprintf(`FETCH %d FROM %s`, s.opts.IterateBatchSize, iterationCursor)
+	batch := make([]T, 0, s.opts.IterateBatchSize)
+	total := 0
+	for {
+		if batch, err = fetchBatch(ctx, s, tx, op, fetch, scan, batch[:0]); err != nil {
+			return err
+		}
+		for _, item := range batch {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		total += len(batch)
+		if len(batch) < s.opts.IterateBatchSize {
+			break
+		}
+	}
+	log.Printf("Обход %s завершён: строк %d", op, total)
+	return tx.Commit(ctx)
+}
+
+// fetchBatch читает из курсора следующую пачку строк в batch
+func fetchBatch[T any](ctx context.Context, s *PostgresStorage, tx pgx.Tx, op, fetch string, scan func(pgx.Rows) (T, error), batch []T) ([]T, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := tx.Query(ctx, fetch)
+	if err != nil {
+		observeTimeout(op, err)
+		return nil, fmt.Errorf("failed to fetch rows: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		batch = append(batch, item)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout(op, err)
+		return nil, fmt.Errorf("failed to fetch rows: %v", err)
+	}
+	return batch, nil
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// EnsureCommentPartitions создаёт секции comments с текущего месяца на monthsAhead месяцев вперёд, чтобы новые
+// комментарии не попадали в секцию по умолчанию. Возвращает количество созданных секций
+func (s *PostgresStorage) EnsureCommentPartitions(ctx context.Context, monthsAhead int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	now := s.clock.Now()
+	var created int
+	err := s.pool.QueryRow(ctx, `SELECT create_comment_partitions($1, $2)`, now, now.AddDate(0, monthsAhead, 0)).Scan(&created)
+	if err != nil {
+		observeTimeout("EnsureCommentPartitions", err)
+		log.Printf("Ошибка при создании секций комментариев: %v", err)
+		return 0, fmt.Errorf("failed to create comment partitions: %v", err)
+	}
+	if created > 0 {
+		log.Printf("Создано секций комментариев: %d", created)
+	}
+	return created, nil
+}
+
+// runPartitionMaintainer периодически создаёт секции комментариев на будущие месяцы до закрытия хранилища
+func (s *PostgresStorage) runPartitionMaintainer(monthsAhead int, interval time.Duration) {
+	defer close(s.partitionDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.partitionStop:
+			return
+		case <-ticker.C:
+			if _, err := s.EnsureCommentPartitions(context.Background(), monthsAhead); err != nil {
+				log.Printf("Фоновое создание секций комментариев не удалось: %v", err)
+			}
+		}
+	}
+}
@@ -5,79 +5,492 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ButyrinIA/system/internal/clock"
 	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// foreignKeyViolationCode - SQLSTATE нарушения внешнего ключа
+const foreignKeyViolationCode = "23503"
+
 type PostgresStorage struct {
-	conn *pgx.Conn
+	// pool выдаёт каждому запросу и фоновой задаче своё подключение: pgx.Conn нельзя использовать конкурентно
+	pool *pgxpool.Pool
+	// opts нужны для отдельных подключений, которые держат серверные курсоры IteratePosts и IterateComments
+	opts          Options
+	queryTimeout  time.Duration
+	dedupeWindow  time.Duration
+	clock         clock.Clock
+	reconcileStop chan struct{}
+	reconcileDone chan struct{}
+	archiveStop   chan struct{}
+	archiveDone   chan struct{}
+	// partitionComments - comments секционирована по месяцам created_at
+	partitionComments bool
+	partitionStop     chan struct{}
+	partitionDone     chan struct{}
+}
+
+// Options задаёт параметры подключения к PostgreSQL; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// MaxConns - наибольшее число подключений в пуле
+	MaxConns       int
+	ConnectTimeout time.Duration
+	MaxRetries     int
+	RetryBackoff   time.Duration
+	MaxBackoff     time.Duration
+	SkipMigrations bool
+	// StatementTimeout передаётся серверу как statement_timeout сессии
+	StatementTimeout time.Duration
+	// QueryTimeout ограничивает контекст каждого запроса к хранилищу
+	QueryTimeout time.Duration
+	// SlowQueryThreshold - длительность, начиная с которой запрос помечается в логе как медленный
+	SlowQueryThreshold time.Duration
+	// LogQueryParams включает вывод значений параметров запросов; по умолчанию они скрываются
+	LogQueryParams bool
+	// CountReconcileInterval - период фоновой сверки post_comment_counts; 0 отключает сверку
+	CountReconcileInterval time.Duration
+	// CommentArchiveMonths - возраст в месяцах, после которого комментарии переносятся в архив; 0 отключает перенос
+	CommentArchiveMonths int
+	// CommentArchiveInterval - период фонового переноса комментариев в архив
+	CommentArchiveInterval time.Duration
+	// CommentArchiveBatchSize - сколько комментариев переносится одной транзакцией
+	CommentArchiveBatchSize int
+	// PartitionComments переводит comments на помесячные секции по created_at при миграции
+	// и включает фоновое создание секций на будущие месяцы
+	PartitionComments bool
+	// CommentPartitionsAhead - на сколько месяцев вперёд создаются секции comments
+	CommentPartitionsAhead int
+	// CommentPartitionInterval - период проверки секций comments на будущие месяцы
+	CommentPartitionInterval time.Duration
+	// CommentDedupeWindow - окно, в котором одинаковый комментарий не создаётся повторно; 0 отключает проверку
+	CommentDedupeWindow time.Duration
+	// IterateBatchSize - сколько строк IteratePosts и IterateComments читают из серверного курсора за раз
+	IterateBatchSize int
+	// Clock - источник времени для записей, которые хранилище создаёт само, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxConns <= 0 {
+		o.MaxConns = 10
+	}
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = 5 * time.Second
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = 500 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 10 * time.Second
+	}
+	if o.StatementTimeout < 0 {
+		o.StatementTimeout = 0
+	}
+	if o.QueryTimeout < 0 {
+		o.QueryTimeout = 0
+	}
+	if o.SlowQueryThreshold < 0 {
+		o.SlowQueryThreshold = 0
+	}
+	if o.CountReconcileInterval < 0 {
+		o.CountReconcileInterval = 0
+	}
+	if o.CommentArchiveMonths < 0 {
+		o.CommentArchiveMonths = 0
+	}
+	if o.CommentArchiveInterval <= 0 {
+		o.CommentArchiveInterval = time.Hour
+	}
+	if o.CommentArchiveBatchSize <= 0 {
+		o.CommentArchiveBatchSize = 1000
+	}
+	if o.CommentPartitionsAhead <= 0 {
+		o.CommentPartitionsAhead = 3
+	}
+	if o.CommentPartitionInterval <= 0 {
+		o.CommentPartitionInterval = 24 * time.Hour
+	}
+	if o.IterateBatchSize <= 0 {
+		o.IterateBatchSize = 500
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
 }
 
-func New(dsn string) (*PostgresStorage, error) {
+func New(dsn string, opts Options) (*PostgresStorage, error) {
+	opts = opts.withDefaults()
 	log.Printf("Подключение к PostgreSQL с DSN: %s", dsn)
-	conn, err := pgx.Connect(context.Background(), dsn)
+	pool, err := connect(context.Background(), dsn, opts)
 	if err != nil {
 		log.Printf("Ошибка подключения к PostgreSQL: %v", err)
 		return nil, fmt.Errorf("failed to connect to postgres: %v", err)
 	}
 
-	log.Println("Создание таблиц posts и comments")
-	_, err = conn.Exec(context.Background(), `
-		CREATE TABLE IF NOT EXISTS posts (
-			id TEXT PRIMARY KEY,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			author_id TEXT NOT NULL,
-			allow_comments BOOLEAN NOT NULL,
-			created_at TIMESTAMP NOT NULL
-		);
-		CREATE TABLE IF NOT EXISTS comments (
-			id TEXT PRIMARY KEY,
-			post_id TEXT REFERENCES posts(id),
-			parent_id TEXT,
-			author_id TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL
-		);
-		CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
-		CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
-	`)
-	if err != nil {
-		log.Printf("Ошибка создания таблиц: %v", err)
-		return nil, fmt.Errorf("failed to create tables: %v", err)
-	}
-	log.Println("Таблицы успешно созданы или уже существуют")
-	return &PostgresStorage{conn: conn}, nil
+	store := &PostgresStorage{pool: pool, opts: opts, queryTimeout: opts.QueryTimeout, dedupeWindow: opts.CommentDedupeWindow, clock: opts.Clock, partitionComments: opts.PartitionComments}
+	if opts.SkipMigrations {
+		// Роль без прав на DDL: схема должна быть подготовлена заранее
+		log.Println("Создание таблиц пропущено, проверка схемы")
+		if err := store.CheckSchema(context.Background()); err != nil {
+			pool.Close()
+			return nil, err
+		}
+		return store, nil
+	}
+	if err := store.Migrate(context.Background()); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	if opts.PartitionComments {
+		if _, err := store.EnsureCommentPartitions(context.Background(), opts.CommentPartitionsAhead); err != nil {
+			pool.Close()
+			return nil, err
+		}
+		store.partitionStop = make(chan struct{})
+		store.partitionDone = make(chan struct{})
+		go store.runPartitionMaintainer(opts.CommentPartitionsAhead, opts.CommentPartitionInterval)
+	}
+	// Счётчики могли разойтись, пока сервис был остановлен, или ещё не заполнены после миграции
+	if _, err := store.ReconcileCommentCounts(context.Background()); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	if opts.CountReconcileInterval > 0 {
+		store.reconcileStop = make(chan struct{})
+		store.reconcileDone = make(chan struct{})
+		go store.runCountReconciler(opts.CountReconcileInterval)
+	}
+	if opts.CommentArchiveMonths > 0 {
+		store.archiveStop = make(chan struct{})
+		store.archiveDone = make(chan struct{})
+		go store.runCommentArchiver(opts.CommentArchiveMonths, opts.CommentArchiveInterval, opts.CommentArchiveBatchSize)
+	}
+	return store, nil
+}
+
+// connect создаёт пул подключений к PostgreSQL, повторяя попытки с экспоненциальной задержкой,
+// пока база не станет доступна (например, при старте через docker-compose)
+func connect(ctx context.Context, dsn string, opts Options) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.MaxConns = int32(opts.MaxConns)
+	if opts.StatementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
+	config.ConnConfig.Tracer = newQueryTracer(opts)
+	var pool *pgxpool.Pool
+	err = withRetries(ctx, opts, func(ctx context.Context) error {
+		var err error
+		if pool, err = pgxpool.NewWithConfig(ctx, config); err != nil {
+			return err
+		}
+		// Пул подключается лениво: Ping проверяет, что база уже принимает подключения
+		if err = pool.Ping(ctx); err != nil {
+			pool.Close()
+		}
+		return err
+	})
+	return pool, err
+}
+
+// connectOne открывает отдельное подключение с параметрами пула, повторяя попытки, как connect
+func connectOne(ctx context.Context, config *pgx.ConnConfig, opts Options) (*pgx.Conn, error) {
+	var conn *pgx.Conn
+	err := withRetries(ctx, opts, func(ctx context.Context) error {
+		var err error
+		if conn, err = pgx.ConnectConfig(ctx, config); err != nil {
+			return err
+		}
+		if err = conn.Ping(ctx); err != nil {
+			conn.Close(context.Background())
+		}
+		return err
+	})
+	return conn, err
+}
+
+// withRetries вызывает attempt с ограничением ConnectTimeout, пока он не завершится успешно
+// или не кончатся MaxRetries повторов; задержка между попытками растёт вдвое до MaxBackoff
+func withRetries(ctx context.Context, opts Options, attempt func(ctx context.Context) error) error {
+	backoff := opts.RetryBackoff
+	for i := 0; ; i++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, opts.ConnectTimeout)
+		err := attempt(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if i >= opts.MaxRetries {
+			return err
+		}
+		log.Printf("PostgreSQL недоступен (попытка %d из %d): %v, повтор через %v", i+1, opts.MaxRetries+1, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// Ping проверяет доступность базы данных
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if err := s.pool.Ping(ctx); err != nil {
+		log.Printf("Ошибка проверки соединения с PostgreSQL: %v", err)
+		return fmt.Errorf("failed to ping postgres: %v", err)
+	}
+	return nil
 }
 
 func (s *PostgresStorage) CreatePost(ctx context.Context, post *models.Post) error {
 	log.Printf("Вставка поста: ID=%s, Title=%s, CreatedAt=%s", post.ID, post.Title, post.CreatedAt)
-	_, err := s.conn.Exec(ctx, `
-        INSERT INTO posts (id, title, content, author_id, allow_comments, created_at)
-        VALUES ($1, $2, $3, $4, $5, $6)`,
-		post.ID, post.Title, post.Content, post.AuthorID, post.AllowComments, post.CreatedAt)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	base := post.Slug
+	if base == "" {
+		base = post.ID
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("CreatePost", err)
+		log.Printf("Ошибка начала транзакции для поста ID=%s: %v", post.ID, err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	taken, err := lockSlugs(ctx, tx, base)
+	if err != nil {
+		observeTimeout("CreatePost", err)
+		log.Printf("Ошибка выбора slug для поста ID=%s: %v", post.ID, err)
+		return err
+	}
+	slug := storage.UniqueSlug(base, taken)
+	_, err = tx.Exec(ctx, `
+        INSERT INTO posts (id, title, content, format, author_id, allow_comments, created_at, hidden, tags, category_id, slug, language, visibility, require_approval, excerpt)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		post.ID, post.Title, post.Content, formatOrPlain(post.Format), post.AuthorID, post.AllowComments, post.CreatedAt, post.Hidden, tagsOrEmpty(post.Tags), post.CategoryID, slug, post.Language, visibilityOrPublic(post.Visibility), post.RequireApproval, post.Excerpt)
+	if isForeignKeyViolation(err) {
+		log.Printf("Категория поста ID=%s не найдена: %v", post.ID, post.CategoryID)
+		return storage.ErrCategoryNotFound
+	}
 	if err != nil {
+		observeTimeout("CreatePost", err)
 		log.Printf("Ошибка при вставке поста ID=%s: %v", post.ID, err)
 		return fmt.Errorf("failed to insert post: %v", err)
 	}
-	log.Printf("Пост успешно вставлен: %s", post.ID)
+	if _, err := tx.Exec(ctx, `INSERT INTO post_slugs (slug, post_id, created_at) VALUES ($1, $2, $3)`, slug, post.ID, post.CreatedAt); err != nil {
+		observeTimeout("CreatePost", err)
+		log.Printf("Ошибка при сохранении slug поста ID=%s: %v", post.ID, err)
+		return fmt.Errorf("failed to insert post slug: %v", err)
+	}
+	if err := insertShortID(ctx, tx, models.TargetPost, post.ID); err != nil {
+		observeTimeout("CreatePost", err)
+		log.Printf("Ошибка при выдаче короткой ссылки поста ID=%s: %v", post.ID, err)
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("CreatePost", err)
+		log.Printf("Ошибка фиксации транзакции для поста ID=%s: %v", post.ID, err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	post.Slug = slug
+	log.Printf("Пост успешно вставлен: %s, slug=%s", post.ID, slug)
+	return nil
+}
+
+// lockSlugs блокирует до конца транзакции выбор slug с основой base и возвращает занятые slug с этой основой.
+// Блокировка сериализует одновременное создание постов с одинаковыми заголовками
+func lockSlugs(ctx context.Context, tx pgx.Tx, base string) (map[string]string, error) {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, "slug|"+base); err != nil {
+		return nil, fmt.Errorf("failed to lock slug: %v", err)
+	}
+	rows, err := tx.Query(ctx, `SELECT slug, post_id FROM post_slugs WHERE slug = $1 OR slug LIKE $1 || '-%'`, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query slugs: %v", err)
+	}
+	defer rows.Close()
+	taken := make(map[string]string)
+	for rows.Next() {
+		var slug, postID string
+		if err := rows.Scan(&slug, &postID); err != nil {
+			return nil, fmt.Errorf("failed to scan slug: %v", err)
+		}
+		if storage.SlugHasBase(slug, base) {
+			taken[slug] = postID
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query slugs: %v", err)
+	}
+	return taken, nil
+}
+
+func (s *PostgresStorage) GetPostBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	log.Printf("Получение поста по slug=%s", slug)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var p models.Post
+	err := s.pool.QueryRow(ctx, `
+		SELECT p.id, p.title, p.content, p.format, p.author_id, p.allow_comments, p.created_at, p.hidden, p.tags, p.category_id, p.slug, p.language, p.visibility, p.require_approval, p.excerpt
+		FROM post_slugs s
+		JOIN posts p ON p.id = s.post_id
+		WHERE s.slug=$1`, slug).Scan(&p.ID, &p.Title, &p.Content, &p.Format, &p.AuthorID, &p.AllowComments, &p.CreatedAt, &p.Hidden, &p.Tags, &p.CategoryID, &p.Slug, &p.Language, &p.Visibility, &p.RequireApproval, &p.Excerpt)
+	if err == pgx.ErrNoRows {
+		log.Printf("Пост со slug=%s не найден", slug)
+		return nil, storage.ErrPostNotFound
+	}
+	if err != nil {
+		observeTimeout("GetPostBySlug", err)
+		log.Printf("Ошибка при получении поста по slug=%s: %v", slug, err)
+		return nil, fmt.Errorf("failed to get post by slug: %v", err)
+	}
+	return &p, nil
+}
+
+// ShortIDs читает номера коротких ссылок, выданные при создании постов и комментариев
+func (s *PostgresStorage) ShortIDs(ctx context.Context, kind string, ids []string) (map[string]int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	result := make(map[string]int64, len(ids))
+	rows, err := s.pool.Query(ctx, `SELECT target_id, seq FROM short_ids WHERE kind=$1 AND target_id = ANY($2)`, kind, ids)
+	if err != nil {
+		observeTimeout("ShortIDs", err)
+		log.Printf("Ошибка при чтении коротких ссылок: %v", err)
+		return nil, fmt.Errorf("failed to get short ids: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var seq int64
+		if err := rows.Scan(&id, &seq); err != nil {
+			return nil, fmt.Errorf("failed to scan short id: %v", err)
+		}
+		result[id] = seq
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("ShortIDs", err)
+		return nil, fmt.Errorf("failed to get short ids: %v", err)
+	}
+	return result, nil
+}
+
+// insertShortID выдаёт номер короткой ссылки новой сущности в транзакции её создания
+func insertShortID(ctx context.Context, tx pgx.Tx, kind, id string) error {
+	if _, err := tx.Exec(ctx, `INSERT INTO short_ids (kind, target_id) VALUES ($1, $2) ON CONFLICT (kind, target_id) DO NOTHING`, kind, id); err != nil {
+		return fmt.Errorf("failed to assign short id: %v", err)
+	}
 	return nil
 }
 
+// ResolveShortID возвращает сущность короткой ссылки
+func (s *PostgresStorage) ResolveShortID(ctx context.Context, seq int64) (string, string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var kind, id string
+	err := s.pool.QueryRow(ctx, `SELECT kind, target_id FROM short_ids WHERE seq=$1`, seq).Scan(&kind, &id)
+	if err == pgx.ErrNoRows {
+		return "", "", storage.ErrShortIDNotFound
+	}
+	if err != nil {
+		observeTimeout("ResolveShortID", err)
+		log.Printf("Ошибка при разрешении короткой ссылки %d: %v", seq, err)
+		return "", "", fmt.Errorf("failed to resolve short id: %v", err)
+	}
+	return kind, id, nil
+}
+
+func (s *PostgresStorage) UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error) {
+	log.Printf("Изменение заголовка поста %s: %s", postID, title)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("UpdatePostTitle", err)
+		log.Printf("Ошибка начала транзакции для поста ID=%s: %v", postID, err)
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	taken, err := lockSlugs(ctx, tx, slugBase)
+	if err != nil {
+		observeTimeout("UpdatePostTitle", err)
+		log.Printf("Ошибка выбора slug для поста ID=%s: %v", postID, err)
+		return nil, err
+	}
+	var p models.Post
+	err = tx.QueryRow(ctx, `
+		SELECT id, title, content, format, author_id, allow_comments, created_at, hidden, tags, category_id, slug, language, visibility, require_approval, excerpt
+		FROM posts
+		WHERE id=$1
+		FOR UPDATE`, postID).Scan(&p.ID, &p.Title, &p.Content, &p.Format, &p.AuthorID, &p.AllowComments, &p.CreatedAt, &p.Hidden, &p.Tags, &p.CategoryID, &p.Slug, &p.Language, &p.Visibility, &p.RequireApproval, &p.Excerpt)
+	if err == pgx.ErrNoRows {
+		return nil, storage.ErrPostNotFound
+	}
+	if err != nil {
+		observeTimeout("UpdatePostTitle", err)
+		log.Printf("Ошибка при получении поста ID=%s: %v", postID, err)
+		return nil, fmt.Errorf("failed to get post: %v", err)
+	}
+	p.Title = title
+	slug := storage.RetitleSlug(postID, p.Slug, slugBase, taken)
+	if _, err := tx.Exec(ctx, `UPDATE posts SET title=$2, slug=$3 WHERE id=$1`, postID, title, slug); err != nil {
+		observeTimeout("UpdatePostTitle", err)
+		log.Printf("Ошибка при изменении заголовка поста ID=%s: %v", postID, err)
+		return nil, fmt.Errorf("failed to update post title: %v", err)
+	}
+	if slug != p.Slug {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO post_slugs (slug, post_id, created_at) VALUES ($1, $2, $3)
+			ON CONFLICT (slug) DO NOTHING`, slug, postID, s.clock.Now())
+		if err != nil {
+			observeTimeout("UpdatePostTitle", err)
+			log.Printf("Ошибка при сохранении slug поста ID=%s: %v", postID, err)
+			return nil, fmt.Errorf("failed to insert post slug: %v", err)
+		}
+		p.Slug = slug
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("UpdatePostTitle", err)
+		log.Printf("Ошибка фиксации транзакции для поста ID=%s: %v", postID, err)
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return &p, nil
+}
+
 func (s *PostgresStorage) GetPost(ctx context.Context, id string) (*models.Post, error) {
 	log.Printf("Получение поста с ID=%s", id)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var p models.Post
-	err := s.conn.QueryRow(ctx, `
-		SELECT id, title, content, author_id, allow_comments, created_at
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, title, content, format, author_id, allow_comments, created_at, hidden, tags, category_id, slug, language, visibility, require_approval, excerpt
 		FROM posts
-		WHERE id=$1`, id).Scan(&p.ID, &p.Title, &p.Content, &p.AuthorID, &p.AllowComments, &p.CreatedAt)
+		WHERE id=$1`, id).Scan(&p.ID, &p.Title, &p.Content, &p.Format, &p.AuthorID, &p.AllowComments, &p.CreatedAt, &p.Hidden, &p.Tags, &p.CategoryID, &p.Slug, &p.Language, &p.Visibility, &p.RequireApproval, &p.Excerpt)
 	if err == pgx.ErrNoRows {
 		log.Printf("Пост с ID=%s не найден", id)
-		return nil, errors.New("post not found")
+		return nil, storage.ErrPostNotFound
 	}
 	if err != nil {
+		observeTimeout("GetPost", err)
 		log.Printf("Ошибка при получении поста ID=%s: %v", id, err)
 		return nil, fmt.Errorf("failed to get post: %v", err)
 	}
@@ -85,34 +498,76 @@ func (s *PostgresStorage) GetPost(ctx context.Context, id string) (*models.Post,
 	return &p, nil
 }
 
-func (s *PostgresStorage) ListPosts(ctx context.Context, limit int, cursor *string) (*models.PaginatedPosts, error) {
-	log.Printf("Запрос списка постов: limit=%d, cursor=%v", limit, cursor)
-	// Подсчет общего количества
+func (s *PostgresStorage) ListPosts(ctx context.Context, limit int, cursor *string, filter storage.PostFilter) (*models.PaginatedPosts, error) {
+	log.Printf("Запрос списка постов: limit=%d, cursor=%v, filter=%+v", limit, cursor, filter)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var afterTime *time.Time
+	var afterID *string
+	var offset int
+	if cursor != nil {
+		decoded, err := storage.DecodeCursor(*cursor, models.SortDesc)
+		if err != nil {
+			log.Printf("Ошибка разбора курсора %s: %v", *cursor, err)
+			return nil, err
+		}
+		if decoded.Offset > 0 {
+			offset = decoded.Offset
+		} else {
+			afterTime, afterID = &decoded.CreatedAt, &decoded.ID
+		}
+	}
+	// Посты категории отбираются по пути: с подкатегориями - по префиксу, иначе - по точному совпадению
+	var categoryPath *string
+	if filter.CategoryID != "" {
+		err := s.pool.QueryRow(ctx, `SELECT path FROM categories WHERE id=$1`, filter.CategoryID).Scan(&categoryPath)
+		if err == pgx.ErrNoRows {
+			log.Printf("Категория фильтра с ID=%s не найдена", filter.CategoryID)
+			return nil, storage.ErrCategoryNotFound
+		}
+		if err != nil {
+			observeTimeout("ListPosts", err)
+			log.Printf("Ошибка при получении категории ID=%s: %v", filter.CategoryID, err)
+			return nil, fmt.Errorf("failed to get category: %v", err)
+		}
+	}
+	viewerID := storage.Viewer(ctx)
+	// Подсчет общего количества видимых зрителю постов
 	var totalCount int
-	err := s.conn.QueryRow(ctx, `SELECT COUNT(*) FROM posts`).Scan(&totalCount)
+	conditions, conditionArgs := postFilterConditions(filter, 4)
+	err := s.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM posts
+		WHERE `+postListedCondition(viewerID, "$1")+` AND `+categoryCondition("$2", "$3")+conditions,
+		append([]any{viewerID, categoryPath, filter.IncludeSubcategories}, conditionArgs...)...).Scan(&totalCount)
 	if err != nil {
+		observeTimeout("ListPosts", err)
 		log.Printf("Ошибка при подсчёте постов: %v", err)
 		return nil, fmt.Errorf("failed to count posts: %v", err)
 	}
 	log.Printf("Общее количество постов: %d", totalCount)
 
+	conditions, conditionArgs = postFilterConditions(filter, 8)
 	query := `
-		SELECT id, title, content, author_id, allow_comments, created_at
+		SELECT id, title, content, format, author_id, allow_comments, created_at, hidden, tags, category_id, slug, language, visibility, require_approval, excerpt
 		FROM posts
-		WHERE ($1::TIMESTAMP IS NULL OR created_at < $1)
-		ORDER BY created_at DESC
-		LIMIT $2`
-	rows, err := s.conn.Query(ctx, query, cursor, limit+1)
+		WHERE ($1::TIMESTAMP IS NULL OR (created_at, id) < ($1, $2))
+		AND ` + postListedCondition(viewerID, "$4") + `
+		AND ` + categoryCondition("$5", "$6") + conditions + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $7`
+	args := append([]any{afterTime, afterID, limit + 1, viewerID, categoryPath, filter.IncludeSubcategories, offset}, conditionArgs...)
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
+		observeTimeout("ListPosts", err)
 		log.Printf("Ошибка при запросе постов: %v", err)
 		return nil, fmt.Errorf("failed to query posts: %v", err)
 	}
 	defer rows.Close()
 
-	var posts []*models.Post // Changed from []models.Post to []*models.Post
+	posts := []*models.Post{}
 	for rows.Next() {
 		var p models.Post
-		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.AuthorID, &p.AllowComments, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.Format, &p.AuthorID, &p.AllowComments, &p.CreatedAt, &p.Hidden, &p.Tags, &p.CategoryID, &p.Slug, &p.Language, &p.Visibility, &p.RequireApproval, &p.Excerpt); err != nil {
 			log.Printf("Ошибка при сканировании поста: %v", err)
 			return nil, fmt.Errorf("failed to scan post: %v", err)
 		}
@@ -123,7 +578,7 @@ func (s *PostgresStorage) ListPosts(ctx context.Context, limit int, cursor *stri
 	var nextCursor *string
 	if len(posts) > limit {
 		nextCursor = new(string)
-		*nextCursor = posts[limit-1].CreatedAt.String()
+		*nextCursor = storage.EncodeCursor(models.SortDesc, posts[limit-1].CreatedAt, posts[limit-1].ID)
 		posts = posts[:limit]
 		log.Printf("Установлен nextCursor: %s", *nextCursor)
 	}
@@ -136,95 +591,2226 @@ func (s *PostgresStorage) ListPosts(ctx context.Context, limit int, cursor *stri
 	}, nil
 }
 
-func (s *PostgresStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
-	log.Printf("Вставка комментария: ID=%s, PostID=%s, Content=%s", comment.ID, comment.PostID, comment.Content)
-	_, err := s.conn.Exec(ctx, `
-		INSERT INTO comments (id, post_id, parent_id, author_id, content, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		comment.ID, comment.PostID, comment.ParentID, comment.AuthorID, comment.Content, comment.CreatedAt)
+func (s *PostgresStorage) SavePostReferences(ctx context.Context, postID string, targetIDs []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		log.Printf("Ошибка при вставке комментария ID=%s: %v", comment.ID, err)
-		return fmt.Errorf("failed to insert comment: %v", err)
+		observeTimeout("SavePostReferences", err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM post_references WHERE source_id=$1`, postID); err != nil {
+		observeTimeout("SavePostReferences", err)
+		log.Printf("Ошибка при удалении ссылок поста %s: %v", postID, err)
+		return fmt.Errorf("failed to save post references: %v", err)
+	}
+	if len(targetIDs) > 0 {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO post_references (source_id, target_id)
+			SELECT $1, target_id FROM unnest($2::TEXT[]) AS target_id
+			ON CONFLICT DO NOTHING`, postID, targetIDs)
+		if isForeignKeyViolation(err) {
+			return storage.ErrPostNotFound
+		}
+		if err != nil {
+			observeTimeout("SavePostReferences", err)
+			log.Printf("Ошибка при сохранении ссылок поста %s: %v", postID, err)
+			return fmt.Errorf("failed to save post references: %v", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("SavePostReferences", err)
+		return fmt.Errorf("failed to commit post references: %v", err)
 	}
-	log.Printf("Комментарий успешно вставлен: %s", comment.ID)
 	return nil
 }
 
-func (s *PostgresStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string) (*models.PaginatedComments, error) {
-	log.Printf("Запрос комментариев: postID=%s, parentID=%v, limit=%d, cursor=%v", postID, parentID, limit, cursor)
-	var totalCount int
-	countQuery := `
-        SELECT COUNT(*)
-        FROM comments
-        WHERE post_id=$1 AND parent_id IS NOT DISTINCT FROM $2`
-	err := s.conn.QueryRow(ctx, countQuery, postID, parentID).Scan(&totalCount)
+func (s *PostgresStorage) RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error) {
+	log.Printf("Запрос похожих постов для %s: limit=%d", postID, limit)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var post models.Post
+	err := s.pool.QueryRow(ctx, `SELECT title, tags, category_id FROM posts WHERE id=$1`, postID).Scan(&post.Title, &post.Tags, &post.CategoryID)
+	if err == pgx.ErrNoRows {
+		return nil, storage.ErrPostNotFound
+	}
 	if err != nil {
-		log.Printf("Ошибка при подсчёте комментариев для postID=%s: %v", postID, err)
-		// Возвращаем пустой результат вместо ошибки
-		return &models.PaginatedComments{
-			Comments:   []models.Comment{},
-			TotalCount: 0,
-			NextCursor: nil,
-		}, nil
+		observeTimeout("RelatedPosts", err)
+		log.Printf("Ошибка при получении поста ID=%s: %v", postID, err)
+		return nil, fmt.Errorf("failed to get post: %v", err)
 	}
-	log.Printf("Общее количество комментариев для postID=%s: %d", postID, totalCount)
-
-	query := `
-        SELECT id, post_id, parent_id, author_id, content, created_at
-        FROM comments
-        WHERE post_id=$1 AND parent_id IS NOT DISTINCT FROM $2
-        AND ($3::TIMESTAMP IS NULL OR created_at < $3)
-        ORDER BY created_at DESC
-        LIMIT $4`
-	rows, err := s.conn.Query(ctx, query, postID, parentID, cursor, limit+1)
+	// Кандидаты отбираются по индексам тегов, категории и триграмм заголовка; оценка совпадает с storage.RelatedScore
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, title, content, format, author_id, allow_comments, created_at, hidden, tags, category_id, slug, language, visibility, require_approval, excerpt
+		FROM posts p
+		WHERE id <> $1 AND NOT hidden AND visibility = 'PUBLIC'
+		AND (tags && $3 OR category_id = $4 OR title % $2)
+		ORDER BY (SELECT COUNT(*) FROM unnest(p.tags) AS t(tag) WHERE tag = ANY($3)) * $5::FLOAT8
+			+ CASE WHEN category_id = $4 THEN $6::FLOAT8 ELSE 0 END
+			+ similarity(title, $2) * $7::FLOAT8 DESC,
+			created_at DESC, id DESC
+		LIMIT $8`,
+		postID, post.Title, tagsOrEmpty(post.Tags), post.CategoryID,
+		storage.RelatedTagWeight, storage.RelatedCategoryWeight, storage.RelatedTextWeight, limit)
 	if err != nil {
-		log.Printf("Ошибка при запросе комментариев для postID=%s: %v", postID, err)
-		return &models.PaginatedComments{
-			Comments:   []models.Comment{},
-			TotalCount: totalCount,
-			NextCursor: nil,
-		}, nil
+		observeTimeout("RelatedPosts", err)
+		log.Printf("Ошибка при запросе похожих постов для %s: %v", postID, err)
+		return nil, fmt.Errorf("failed to query related posts: %v", err)
 	}
 	defer rows.Close()
+	posts := []*models.Post{}
+	for rows.Next() {
+		var p models.Post
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.Format, &p.AuthorID, &p.AllowComments, &p.CreatedAt, &p.Hidden, &p.Tags, &p.CategoryID, &p.Slug, &p.Language, &p.Visibility, &p.RequireApproval, &p.Excerpt); err != nil {
+			log.Printf("Ошибка при сканировании поста: %v", err)
+			return nil, fmt.Errorf("failed to scan post: %v", err)
+		}
+		posts = append(posts, &p)
+	}
+	return posts, rows.Err()
+}
 
-	var comments []models.Comment
+func (s *PostgresStorage) SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]storage.SimilarPost, error) {
+	log.Printf("Запрос постов с заголовком, похожим на %q: порог %.2f, limit=%d", title, threshold, limit)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	// Оператор % отбирает кандидатов по индексу триграмм заголовка с порогом pg_trgm.similarity_threshold
+	// по умолчанию, равным storage.RelatedSimilarityThreshold; более высокий порог проверяется отдельно
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, title, content, format, author_id, allow_comments, created_at, hidden, tags, category_id, slug, language, visibility, require_approval, excerpt,
+			similarity(title, $1) AS title_similarity
+		FROM posts
+		WHERE title % $1 AND similarity(title, $1) >= $2
+		AND (NOT hidden AND visibility = 'PUBLIC' OR author_id = $3)
+		ORDER BY title_similarity DESC, created_at DESC, id DESC
+		LIMIT $4`,
+		title, threshold, viewerID, limit)
+	if err != nil {
+		observeTimeout("SimilarPosts", err)
+		log.Printf("Ошибка при запросе постов с похожим заголовком: %v", err)
+		return nil, fmt.Errorf("failed to query similar posts: %v", err)
+	}
+	defer rows.Close()
+	similar := []storage.SimilarPost{}
 	for rows.Next() {
-		var c models.Comment
-		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.CreatedAt); err != nil {
-			log.Printf("Ошибка при сканировании комментария: %v", err)
-			return &models.PaginatedComments{
-				Comments:   []models.Comment{},
-				TotalCount: totalCount,
-				NextCursor: nil,
-			}, nil
+		var p models.Post
+		var similarity float64
+		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.Format, &p.AuthorID, &p.AllowComments, &p.CreatedAt, &p.Hidden, &p.Tags, &p.CategoryID, &p.Slug, &p.Language, &p.Visibility, &p.RequireApproval, &p.Excerpt, &similarity); err != nil {
+			log.Printf("Ошибка при сканировании поста: %v", err)
+			return nil, fmt.Errorf("failed to scan post: %v", err)
 		}
-		comments = append(comments, c)
-		log.Printf("Получен комментарий: ID=%s, Content=%s", c.ID, c.Content)
+		similar = append(similar, storage.SimilarPost{Post: &p, Similarity: similarity})
 	}
+	return similar, rows.Err()
+}
 
-	var nextCursor *string
-	if len(comments) > limit {
-		nextCursor = new(string)
-		*nextCursor = comments[limit-1].CreatedAt.Format(time.RFC3339)
-		comments = comments[:limit]
-		log.Printf("Установлен nextCursor: %s", *nextCursor)
+// categoryCondition возвращает условие отбора постов по пути категории из параметра pathParam;
+// при NULL условие выполняется для всех постов. subParam включает подкатегории
+func categoryCondition(pathParam, subParam string) string {
+	return fmt.Sprintf(`(%[1]s::TEXT IS NULL OR category_id IN (
+			SELECT id FROM categories
+			WHERE path = %[1]s OR (%[2]s AND path LIKE %[1]s || '/%%')))`, pathParam, subParam)
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE; обратная косая черта - экранирующий символ по умолчанию
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// postFilterConditions возвращает условия filter, кроме категории, в виде " AND ..." и их аргументы;
+// номера параметров начинаются с next. В запрос попадают только заданные условия, чтобы планировщик
+// выбирал индексы по автору, времени создания и тегам, а не проверял "$n IS NULL" для каждой строки
+func postFilterConditions(filter storage.PostFilter, next int) (string, []any) {
+	var conditions strings.Builder
+	var args []any
+	add := func(condition string, arg any) {
+		fmt.Fprintf(&conditions, " AND "+condition, next)
+		args = append(args, arg)
+		next++
 	}
-	log.Printf("Возвращено комментариев: %d", len(comments))
+	if filter.CreatedAfter != nil {
+		add("created_at > $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		add("created_at < $%d", *filter.CreatedBefore)
+	}
+	if filter.AsOf != nil {
+		add("created_at <= $%d", *filter.AsOf)
+	}
+	if filter.Text != "" {
+		// ILIKE с шаблоном использует триграммные индексы idx_posts_title_trgm и idx_posts_content_trgm
+		add("(title ILIKE $%[1]d OR content ILIKE $%[1]d)", "%"+likeEscaper.Replace(filter.Text)+"%")
+	}
+	if filter.AuthorID != "" {
+		add("author_id = $%d", filter.AuthorID)
+	}
+	if filter.Tag != "" {
+		// Оператор @> использует GIN-индекс idx_posts_tags, в отличие от = ANY(tags)
+		add("tags @> ARRAY[$%d::TEXT]", filter.Tag)
+	}
+	if filter.HasComments != nil {
+		// Счётчики поддерживаются триггером и учитывают только видимые всем комментарии
+		add(`EXISTS (
+			SELECT 1 FROM post_comment_counts
+			WHERE post_id = posts.id AND comment_count > 0) = $%d`, *filter.HasComments)
+	}
+	if filter.AllowComments != nil {
+		add("allow_comments = $%d", *filter.AllowComments)
+	}
+	if filter.Language != "" {
+		add("language = $%d", filter.Language)
+	}
+	if filter.ReferencedBy != "" {
+		add("id IN (SELECT target_id FROM post_references WHERE source_id = $%d)", filter.ReferencedBy)
+	}
+	if filter.References != "" {
+		// Поиск по target_id использует индекс idx_post_references_target
+		add("id IN (SELECT source_id FROM post_references WHERE target_id = $%d)", filter.References)
+	}
+	if filter.IDs != nil {
+		add("id = ANY($%d)", filter.IDs)
+	}
+	return conditions.String(), args
+}
 
-	return &models.PaginatedComments{
-		Comments:   comments,
-		TotalCount: totalCount,
-		NextCursor: nextCursor,
-	}, nil
+func (s *PostgresStorage) SetPostCategory(ctx context.Context, postID string, categoryID *string) error {
+	log.Printf("Изменение категории поста %s: %v", postID, categoryID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `UPDATE posts SET category_id=$2 WHERE id=$1`, postID, categoryID)
+	if isForeignKeyViolation(err) {
+		return storage.ErrCategoryNotFound
+	}
+	if err != nil {
+		observeTimeout("SetPostCategory", err)
+		log.Printf("Ошибка при изменении категории поста ID=%s: %v", postID, err)
+		return fmt.Errorf("failed to set post category: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrPostNotFound
+	}
+	return nil
 }
 
-func (s *PostgresStorage) Close() error {
-	log.Println("Закрытие соединения с PostgreSQL")
-	err := s.conn.Close(context.Background())
+func (s *PostgresStorage) SetPostRequireApproval(ctx context.Context, postID string, required bool) error {
+	log.Printf("Премодерация комментариев поста %s: %t", postID, required)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `UPDATE posts SET require_approval=$2 WHERE id=$1`, postID, required)
 	if err != nil {
-		log.Printf("Ошибка при закрытии соединения: %v", err)
-		return fmt.Errorf("failed to close connection: %v", err)
+		observeTimeout("SetPostRequireApproval", err)
+		log.Printf("Ошибка при изменении премодерации поста ID=%s: %v", postID, err)
+		return fmt.Errorf("failed to set post approval: %v", err)
 	}
-	log.Println("Соединение с PostgreSQL успешно закрыто")
+	if tag.RowsAffected() == 0 {
+		return storage.ErrPostNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SetPostExcerpt(ctx context.Context, postID, excerpt string) error {
+	log.Printf("Замена анонса поста %s", postID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `UPDATE posts SET excerpt=$2 WHERE id=$1`, postID, excerpt)
+	if err != nil {
+		observeTimeout("SetPostExcerpt", err)
+		log.Printf("Ошибка при замене анонса поста ID=%s: %v", postID, err)
+		return fmt.Errorf("failed to set post excerpt: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrPostNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) CreateCategory(ctx context.Context, category *models.Category) error {
+	log.Printf("Создание категории: ID=%s, Name=%s, ParentID=%v", category.ID, category.Name, category.ParentID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	// Категории не перемещаются, поэтому путь родителя не меняется после чтения
+	var parentPath string
+	if category.ParentID != nil {
+		err := s.pool.QueryRow(ctx, `SELECT path FROM categories WHERE id=$1`, *category.ParentID).Scan(&parentPath)
+		if err == pgx.ErrNoRows {
+			return storage.ErrCategoryNotFound
+		}
+		if err != nil {
+			observeTimeout("CreateCategory", err)
+			log.Printf("Ошибка при получении родительской категории ID=%s: %v", *category.ParentID, err)
+			return fmt.Errorf("failed to get parent category: %v", err)
+		}
+	}
+	path := storage.CategoryPath(parentPath, category.ID)
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO categories (id, name, parent_id, path, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		category.ID, category.Name, category.ParentID, path, category.CreatedAt)
+	if err != nil {
+		observeTimeout("CreateCategory", err)
+		log.Printf("Ошибка при создании категории ID=%s: %v", category.ID, err)
+		return fmt.Errorf("failed to insert category: %v", err)
+	}
+	category.Path = path
+	return nil
+}
+
+func (s *PostgresStorage) GetCategory(ctx context.Context, id string) (*models.Category, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var c models.Category
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, name, parent_id, path, created_at
+		FROM categories
+		WHERE id=$1`, id).Scan(&c.ID, &c.Name, &c.ParentID, &c.Path, &c.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, storage.ErrCategoryNotFound
+	}
+	if err != nil {
+		observeTimeout("GetCategory", err)
+		log.Printf("Ошибка при получении категории ID=%s: %v", id, err)
+		return nil, fmt.Errorf("failed to get category: %v", err)
+	}
+	return &c, nil
+}
+
+func (s *PostgresStorage) ListCategories(ctx context.Context) ([]models.Category, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, parent_id, path, created_at
+		FROM categories
+		ORDER BY created_at, id`)
+	if err != nil {
+		observeTimeout("ListCategories", err)
+		log.Printf("Ошибка при запросе категорий: %v", err)
+		return nil, fmt.Errorf("failed to query categories: %v", err)
+	}
+	defer rows.Close()
+	categories := []models.Category{}
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.ParentID, &c.Path, &c.CreatedAt); err != nil {
+			log.Printf("Ошибка при сканировании категории: %v", err)
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (s *PostgresStorage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	log.Printf("Вставка комментария: ID=%s, PostID=%s, Content=%s", comment.ID, comment.PostID, comment.Content)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("CreateComment", err)
+		log.Printf("Ошибка начала транзакции для комментария ID=%s: %v", comment.ID, err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := s.insertComment(ctx, tx, comment); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("CreateComment", err)
+		log.Printf("Ошибка фиксации комментария ID=%s: %v", comment.ID, err)
+		return fmt.Errorf("failed to commit comment: %v", err)
+	}
+	log.Printf("Комментарий успешно вставлен: %s", comment.ID)
 	return nil
 }
+
+// CreateComments вставляет пачку комментариев одной транзакцией. Каждый комментарий вставляется
+// в своей точке сохранения, чтобы ошибка одного не отменяла остальные
+func (s *PostgresStorage) CreateComments(ctx context.Context, comments []*models.Comment) []error {
+	log.Printf("Вставка пачки из %d комментариев", len(comments))
+	errs := make([]error, len(comments))
+	fail := func(err error) []error {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+		return errs
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("CreateComments", err)
+		log.Printf("Ошибка начала транзакции для пачки комментариев: %v", err)
+		return fail(fmt.Errorf("failed to begin transaction: %v", err))
+	}
+	defer tx.Rollback(ctx)
+	for i, comment := range comments {
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			observeTimeout("CreateComments", err)
+			log.Printf("Ошибка создания точки сохранения для комментария ID=%s: %v", comment.ID, err)
+			return fail(fmt.Errorf("failed to create savepoint: %v", err))
+		}
+		if err := s.insertComment(ctx, savepoint, comment); err != nil {
+			errs[i] = err
+			if err := savepoint.Rollback(ctx); err != nil {
+				observeTimeout("CreateComments", err)
+				log.Printf("Ошибка отката точки сохранения для комментария ID=%s: %v", comment.ID, err)
+				return fail(fmt.Errorf("failed to roll back savepoint: %v", err))
+			}
+			continue
+		}
+		if err := savepoint.Commit(ctx); err != nil {
+			observeTimeout("CreateComments", err)
+			log.Printf("Ошибка освобождения точки сохранения для комментария ID=%s: %v", comment.ID, err)
+			return fail(fmt.Errorf("failed to release savepoint: %v", err))
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("CreateComments", err)
+		log.Printf("Ошибка фиксации пачки комментариев: %v", err)
+		return fail(fmt.Errorf("failed to commit comments: %v", err))
+	}
+	log.Printf("Пачка из %d комментариев вставлена", len(comments))
+	return errs
+}
+
+// insertComment проверяет повтор и вставляет комментарий в транзакции tx
+func (s *PostgresStorage) insertComment(ctx context.Context, tx pgx.Tx, comment *models.Comment) error {
+	hash := storage.CommentHash(comment)
+	if s.dedupeWindow > 0 {
+		// Блокировка по ключу дедупликации сериализует одновременные вставки одинаковых комментариев
+		key := comment.AuthorID + "|" + comment.PostID + "|" + stringOrEmpty(comment.ParentID) + "|" + hash
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, key); err != nil {
+			observeTimeout("CreateComment", err)
+			log.Printf("Ошибка блокировки дедупликации для комментария ID=%s: %v", comment.ID, err)
+			return fmt.Errorf("failed to lock comment dedupe key: %v", err)
+		}
+		var existing models.Comment
+		err := tx.QueryRow(ctx, `
+			SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status
+			FROM comments
+			WHERE author_id=$1 AND post_id=$2 AND parent_id IS NOT DISTINCT FROM $3
+			AND content_hash=$4 AND created_at BETWEEN $5 AND $6
+			ORDER BY created_at DESC
+			LIMIT 1`,
+			comment.AuthorID, comment.PostID, comment.ParentID, hash, comment.CreatedAt.Add(-s.dedupeWindow), comment.CreatedAt,
+		).Scan(&existing.ID, &existing.PostID, &existing.ParentID, &existing.AuthorID, &existing.Content, &existing.Format, &existing.CreatedAt, &existing.Hidden, &existing.Tags, &existing.SpamStatus, &existing.Upvotes, &existing.Downvotes, &existing.Language, &existing.QuotedCommentID, &existing.ApprovalStatus)
+		if err == nil {
+			log.Printf("Повторный комментарий, возвращается существующий: %s", existing.ID)
+			return &storage.DuplicateCommentError{Existing: &existing}
+		}
+		if err != pgx.ErrNoRows {
+			observeTimeout("CreateComment", err)
+			log.Printf("Ошибка поиска повторного комментария: %v", err)
+			return fmt.Errorf("failed to check duplicate comment: %v", err)
+		}
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO comments (id, post_id, parent_id, author_id, content, content_hash, format, created_at, hidden, tags, spam_status, language, quoted_comment_id, approval_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		comment.ID, comment.PostID, comment.ParentID, comment.AuthorID, comment.Content, hash, formatOrPlain(comment.Format), comment.CreatedAt, comment.Hidden, tagsOrEmpty(comment.Tags), comment.SpamStatus, comment.Language, comment.QuotedCommentID, comment.ApprovalStatus)
+	if isForeignKeyViolation(err) {
+		log.Printf("Ошибка: пост с ID=%s не найден", comment.PostID)
+		return storage.ErrPostNotFound
+	}
+	if err != nil {
+		observeTimeout("CreateComment", err)
+		log.Printf("Ошибка при вставке комментария ID=%s: %v", comment.ID, err)
+		return fmt.Errorf("failed to insert comment: %v", err)
+	}
+	if err := insertShortID(ctx, tx, models.TargetComment, comment.ID); err != nil {
+		observeTimeout("CreateComment", err)
+		log.Printf("Ошибка при выдаче короткой ссылки комментария ID=%s: %v", comment.ID, err)
+		return err
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	log.Printf("Получение комментария с ID=%s", id)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var c models.Comment
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status
+		FROM comments_all
+		WHERE id=$1`, id).Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus)
+	if err == pgx.ErrNoRows {
+		log.Printf("Комментарий с ID=%s не найден", id)
+		return nil, storage.ErrCommentNotFound
+	}
+	if err != nil {
+		observeTimeout("GetComment", err)
+		log.Printf("Ошибка при получении комментария ID=%s: %v", id, err)
+		return nil, fmt.Errorf("failed to get comment: %v", err)
+	}
+	return &c, nil
+}
+
+func (s *PostgresStorage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error) {
+	log.Printf("Получение %d комментариев по ID", len(ids))
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status
+		FROM comments_all
+		WHERE id = ANY($1)`, ids)
+	if err != nil {
+		observeTimeout("GetCommentsByIDs", err)
+		log.Printf("Ошибка при получении комментариев по ID: %v", err)
+		return nil, fmt.Errorf("failed to get comments: %v", err)
+	}
+	defer rows.Close()
+	comments := make(map[string]*models.Comment, len(ids))
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %v", err)
+		}
+		comments[c.ID] = &c
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("GetCommentsByIDs", err)
+		return nil, fmt.Errorf("failed to get comments: %v", err)
+	}
+	return comments, nil
+}
+
+// CountCommentsBefore реализует storage.Storage: комментарии перед commentID - те, чей ключ сортировки
+// больше для убывающих порядков и меньше для ASC
+func (s *PostgresStorage) CountCommentsBefore(ctx context.Context, commentID string, order models.SortOrder) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	keys, cmp := "(c.created_at, c.id)", ">"
+	target := "(t.created_at, t.id)"
+	switch order {
+	case models.SortAsc:
+		cmp = "<"
+	case models.SortBest:
+		keys, target = "(c.best_score, c.created_at, c.id)", "(t.best_score, t.created_at, t.id)"
+	}
+	var count int
+	err := s.pool.QueryRow(ctx, `
+		SELECT (
+			SELECT COUNT(*)
+			FROM comments_all c
+			WHERE c.post_id=t.post_id AND c.parent_id IS NOT DISTINCT FROM t.parent_id
+			AND (NOT c.hidden OR c.author_id=$2)
+			AND `+keys+` `+cmp+` `+target+`
+		)
+		FROM comments_all t
+		WHERE t.id=$1`, commentID, storage.Viewer(ctx)).Scan(&count)
+	if err == pgx.ErrNoRows {
+		return 0, storage.ErrCommentNotFound
+	}
+	if err != nil {
+		observeTimeout("CountCommentsBefore", err)
+		log.Printf("Ошибка при подсчёте комментариев перед ID=%s: %v", commentID, err)
+		return 0, fmt.Errorf("failed to count comments: %v", err)
+	}
+	return count, nil
+}
+
+func (s *PostgresStorage) GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string, order models.SortOrder) (*models.PaginatedComments, error) {
+	log.Printf("Запрос комментариев: postID=%s, parentID=%v, limit=%d, cursor=%v, order=%s", postID, parentID, limit, cursor, order)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if order == "" {
+		order = models.SortDesc
+	}
+	var afterTime *time.Time
+	var afterID *string
+	var afterScore float64
+	var offset int
+	if cursor != nil {
+		decoded, err := storage.DecodeCursor(*cursor, order)
+		if err != nil {
+			log.Printf("Ошибка разбора курсора %s: %v", *cursor, err)
+			return nil, err
+		}
+		if decoded.Offset > 0 {
+			offset = decoded.Offset
+		} else {
+			afterTime, afterID, afterScore = &decoded.CreatedAt, &decoded.ID, decoded.Score
+		}
+	}
+	viewerID := storage.Viewer(ctx)
+	// Количество видимых комментариев берётся из post_comment_counts, который поддерживается
+	// триггером на comments и учитывает архив; скрытые комментарии зрителя досчитываются отдельно.
+	// Для поста, который зритель не видит, возвращается -1. Приблизительный счётчик обходится без досчёта
+	var totalCount int
+	hiddenCount := `(
+            SELECT COUNT(*)
+            FROM comments_all
+            WHERE post_id=$1 AND parent_id IS NOT DISTINCT FROM $2 AND hidden AND author_id=$3
+        )`
+	estimated := storage.EstimatedCounts(ctx)
+	if estimated {
+		hiddenCount = "0"
+	}
+	countQuery := `
+        SELECT CASE WHEN EXISTS (SELECT 1 FROM posts WHERE id=$1 AND ` + postVisibleCondition("$3") + `) THEN COALESCE((
+            SELECT comment_count
+            FROM post_comment_counts
+            WHERE post_id=$1 AND parent_id=COALESCE($2::TEXT, '')
+        ), 0) + ` + hiddenCount + ` ELSE -1 END`
+	err := s.pool.QueryRow(ctx, countQuery, postID, parentID, viewerID).Scan(&totalCount)
+	if err != nil {
+		observeTimeout("GetComments", err)
+		log.Printf("Ошибка при подсчёте комментариев для postID=%s: %v", postID, err)
+		// Возвращаем пустой результат вместо ошибки
+		return &models.PaginatedComments{
+			Comments:   []models.Comment{},
+			TotalCount: 0,
+			NextCursor: nil,
+		}, nil
+	}
+	if totalCount < 0 {
+		log.Printf("Пост %s не виден зрителю, комментарии не возвращаются", postID)
+		return &models.PaginatedComments{Comments: []models.Comment{}}, nil
+	}
+	log.Printf("Общее количество комментариев для postID=%s: %d", postID, totalCount)
+
+	// Направление нельзя передать параметром, поэтому оператор сравнения и порядок подставляются в текст запроса
+	cmp, direction := "<", "DESC"
+	if order == models.SortAsc {
+		cmp, direction = ">", "ASC"
+	}
+	keyColumns, keyValues := "(created_at, id)", "($3, $4)"
+	orderBy := "created_at " + direction + ", id " + direction
+	// Отдельное условие на created_at позволяет отбросить секции comments за месяцы за курсором:
+	// сравнение кортежей для отсечения секций не используется
+	bound := "created_at <= COALESCE($3::TIMESTAMP, 'infinity')"
+	if order == models.SortAsc {
+		bound = "created_at >= COALESCE($3::TIMESTAMP, '-infinity')"
+	}
+	args := []any{postID, parentID, afterTime, afterID, limit + 1, viewerID, offset}
+	if order == models.SortBest {
+		// best_score пересчитывается при каждом голосе, поэтому порядок BEST читается по индексу
+		keyColumns, keyValues = "(best_score, created_at, id)", "($8, $3, $4)"
+		orderBy = "best_score DESC, created_at DESC, id DESC"
+		bound = "TRUE"
+		args = append(args, afterScore)
+	}
+	// Старые комментарии лежат в архиве, поэтому страница читается из comments_all: при LIMIT планировщик
+	// сливает упорядоченные сканы обеих таблиц, и архив читается, только когда страница доходит до старых записей
+	query := `
+        SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status, best_score
+        FROM comments_all
+        WHERE post_id=$1 AND parent_id IS NOT DISTINCT FROM $2
+        AND (NOT hidden OR author_id=$6)
+        AND ` + bound + `
+        AND ($3::TIMESTAMP IS NULL OR ` + keyColumns + ` ` + cmp + ` ` + keyValues + `)
+        ORDER BY ` + orderBy + `
+        LIMIT $5 OFFSET $7`
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		observeTimeout("GetComments", err)
+		log.Printf("Ошибка при запросе комментариев для postID=%s: %v", postID, err)
+		return &models.PaginatedComments{
+			Comments:   []models.Comment{},
+			TotalCount: totalCount,
+			NextCursor: nil,
+		}, nil
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	scores := []float64{}
+	for rows.Next() {
+		var c models.Comment
+		var score float64
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus, &score); err != nil {
+			log.Printf("Ошибка при сканировании комментария: %v", err)
+			return &models.PaginatedComments{
+				Comments:   []models.Comment{},
+				TotalCount: totalCount,
+				NextCursor: nil,
+			}, nil
+		}
+		comments = append(comments, c)
+		scores = append(scores, score)
+		log.Printf("Получен комментарий: ID=%s, Content=%s", c.ID, c.Content)
+	}
+
+	var nextCursor *string
+	if len(comments) > limit {
+		last := comments[limit-1]
+		nextCursor = new(string)
+		*nextCursor = storage.EncodeCursor(order, last.CreatedAt, last.ID)
+		if order == models.SortBest {
+			// Курсор хранит оценку из базы, чтобы сравнение в запросе было точным
+			*nextCursor = storage.EncodeBestCursor(scores[limit-1], last.CreatedAt, last.ID)
+		}
+		comments = comments[:limit]
+		log.Printf("Установлен nextCursor: %s", *nextCursor)
+	}
+	log.Printf("Возвращено комментариев: %d", len(comments))
+
+	return &models.PaginatedComments{
+		Comments:   comments,
+		TotalCount: totalCount,
+		NextCursor: nextCursor,
+		Estimated:  estimated,
+	}, nil
+}
+
+func (s *PostgresStorage) SaveLinkPreview(ctx context.Context, preview *models.LinkPreview) error {
+	log.Printf("Сохранение превью: PostID=%s, URL=%s", preview.PostID, preview.URL)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO link_previews (post_id, url, title, description, image_url, site_name, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (post_id, url) DO UPDATE SET
+			title = EXCLUDED.title,
+			description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url,
+			site_name = EXCLUDED.site_name,
+			fetched_at = EXCLUDED.fetched_at`,
+		preview.PostID, preview.URL, preview.Title, preview.Description, preview.ImageURL, preview.SiteName, preview.FetchedAt)
+	if isForeignKeyViolation(err) {
+		log.Printf("Ошибка: пост с ID=%s не найден", preview.PostID)
+		return storage.ErrPostNotFound
+	}
+	if err != nil {
+		observeTimeout("SaveLinkPreview", err)
+		log.Printf("Ошибка при сохранении превью %s: %v", preview.URL, err)
+		return fmt.Errorf("failed to save link preview: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetLinkPreviews(ctx context.Context, postID string) ([]models.LinkPreview, error) {
+	log.Printf("Запрос превью для postID=%s", postID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT post_id, url, title, description, image_url, site_name, fetched_at
+		FROM link_previews
+		WHERE post_id=$1
+		ORDER BY url`, postID)
+	if err != nil {
+		observeTimeout("GetLinkPreviews", err)
+		log.Printf("Ошибка при запросе превью для postID=%s: %v", postID, err)
+		return nil, fmt.Errorf("failed to query link previews: %v", err)
+	}
+	defer rows.Close()
+
+	previews := []models.LinkPreview{}
+	for rows.Next() {
+		var p models.LinkPreview
+		if err := rows.Scan(&p.PostID, &p.URL, &p.Title, &p.Description, &p.ImageURL, &p.SiteName, &p.FetchedAt); err != nil {
+			log.Printf("Ошибка при сканировании превью: %v", err)
+			return nil, fmt.Errorf("failed to scan link preview: %v", err)
+		}
+		previews = append(previews, p)
+	}
+	return previews, rows.Err()
+}
+
+func (s *PostgresStorage) ToggleReaction(ctx context.Context, reaction *models.Reaction) (bool, error) {
+	log.Printf("Переключение реакции: TargetID=%s, UserID=%s, Emoji=%s", reaction.TargetID, reaction.UserID, reaction.Emoji)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `
+		DELETE FROM reactions
+		WHERE target_id=$1 AND user_id=$2 AND emoji=$3`,
+		reaction.TargetID, reaction.UserID, reaction.Emoji)
+	if err != nil {
+		observeTimeout("ToggleReaction", err)
+		log.Printf("Ошибка при удалении реакции: %v", err)
+		return false, fmt.Errorf("failed to delete reaction: %v", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return false, nil
+	}
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO reactions (target_id, user_id, emoji, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`,
+		reaction.TargetID, reaction.UserID, reaction.Emoji, reaction.CreatedAt)
+	if err != nil {
+		observeTimeout("ToggleReaction", err)
+		log.Printf("Ошибка при вставке реакции: %v", err)
+		return false, fmt.Errorf("failed to insert reaction: %v", err)
+	}
+	return true, nil
+}
+
+// CountDescendants считает ответы рекурсивным запросом по comments_all, включая архив. Связь по post_id
+// позволяет использовать индексы ветки обсуждения; UNION отбрасывает повторы и не зацикливается
+// на испорченных цепочках parent_id
+func (s *PostgresStorage) CountDescendants(ctx context.Context, commentIDs []string) (map[string]int, error) {
+	log.Printf("Подсчёт ответов для %d комментариев", len(commentIDs))
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		WITH RECURSIVE tree(root_id, id, post_id) AS (
+			SELECT id, id, post_id FROM comments_all WHERE id = ANY($1)
+			UNION
+			SELECT t.root_id, c.id, c.post_id
+			FROM tree t
+			JOIN comments_all c ON c.post_id = t.post_id AND c.parent_id = t.id
+			WHERE NOT c.hidden
+		)
+		SELECT root_id, COUNT(*) - 1 FROM tree GROUP BY root_id`, commentIDs)
+	if err != nil {
+		observeTimeout("CountDescendants", err)
+		log.Printf("Ошибка при подсчёте ответов: %v", err)
+		return nil, fmt.Errorf("failed to count descendants: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]int, len(commentIDs))
+	for _, id := range commentIDs {
+		result[id] = 0
+	}
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan descendant count: %v", err)
+		}
+		result[id] = count
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStorage) GetReactionCounts(ctx context.Context, targetIDs []string) (map[string][]models.ReactionCount, error) {
+	log.Printf("Запрос количества реакций для %d объектов", len(targetIDs))
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT target_id, emoji, COUNT(*) AS cnt
+		FROM reactions
+		WHERE target_id = ANY($1)
+		GROUP BY target_id, emoji
+		ORDER BY target_id, cnt DESC, emoji`, targetIDs)
+	if err != nil {
+		observeTimeout("GetReactionCounts", err)
+		log.Printf("Ошибка при запросе реакций: %v", err)
+		return nil, fmt.Errorf("failed to query reactions: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]models.ReactionCount, len(targetIDs))
+	for _, id := range targetIDs {
+		result[id] = []models.ReactionCount{}
+	}
+	for rows.Next() {
+		var targetID string
+		var rc models.ReactionCount
+		if err := rows.Scan(&targetID, &rc.Emoji, &rc.Count); err != nil {
+			log.Printf("Ошибка при сканировании реакции: %v", err)
+			return nil, fmt.Errorf("failed to scan reaction: %v", err)
+		}
+		result[targetID] = append(result[targetID], rc)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStorage) MarkThreadRead(ctx context.Context, userID, postID string, readAt time.Time) error {
+	log.Printf("Отметка о прочтении поста %s пользователем %s: %v", postID, userID, readAt)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO thread_reads (user_id, post_id, read_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, post_id) DO UPDATE
+		SET read_at = GREATEST(thread_reads.read_at, EXCLUDED.read_at)`,
+		userID, postID, readAt)
+	if isForeignKeyViolation(err) {
+		return storage.ErrPostNotFound
+	}
+	if err != nil {
+		observeTimeout("MarkThreadRead", err)
+		log.Printf("Ошибка при сохранении отметки о прочтении: %v", err)
+		return fmt.Errorf("failed to mark thread read: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetUnreadCommentCounts(ctx context.Context, userID string, postIDs []string) (map[string]int, error) {
+	log.Printf("Запрос непрочитанных комментариев пользователя %s для %d постов", userID, len(postIDs))
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.post_id, COUNT(*)
+		FROM comments_all c
+		LEFT JOIN thread_reads r ON r.post_id = c.post_id AND r.user_id = $1
+		WHERE c.post_id = ANY($2) AND c.author_id <> $1 AND NOT c.hidden
+			AND (r.read_at IS NULL OR c.created_at > r.read_at)
+		GROUP BY c.post_id`, userID, postIDs)
+	if err != nil {
+		observeTimeout("GetUnreadCommentCounts", err)
+		log.Printf("Ошибка при запросе непрочитанных комментариев: %v", err)
+		return nil, fmt.Errorf("failed to query unread comments: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]int, len(postIDs))
+	for _, id := range postIDs {
+		result[id] = 0
+	}
+	for rows.Next() {
+		var postID string
+		var count int
+		if err := rows.Scan(&postID, &count); err != nil {
+			log.Printf("Ошибка при сканировании числа непрочитанных комментариев: %v", err)
+			return nil, fmt.Errorf("failed to scan unread count: %v", err)
+		}
+		result[postID] = count
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStorage) IncrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("IncrementQuotaUsage", err)
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Счётчики прошедших окон больше не нужны
+	_, err = tx.Exec(ctx, `
+		DELETE FROM quota_usage
+		WHERE user_id=$1 AND action=$2 AND window_start < $3`,
+		userID, action, windowStart)
+	if err != nil {
+		observeTimeout("IncrementQuotaUsage", err)
+		log.Printf("Ошибка при удалении старых счётчиков квоты: %v", err)
+		return 0, fmt.Errorf("failed to roll over quota usage: %v", err)
+	}
+	var used int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO quota_usage (user_id, action, window_start, used)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (user_id, action, window_start) DO UPDATE
+		SET used = quota_usage.used + 1
+		RETURNING used`,
+		userID, action, windowStart).Scan(&used)
+	if err != nil {
+		observeTimeout("IncrementQuotaUsage", err)
+		log.Printf("Ошибка при увеличении счётчика квоты: %v", err)
+		return 0, fmt.Errorf("failed to increment quota usage: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("IncrementQuotaUsage", err)
+		return 0, fmt.Errorf("failed to commit quota usage: %v", err)
+	}
+	return used, nil
+}
+
+func (s *PostgresStorage) DecrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		UPDATE quota_usage SET used = used - 1
+		WHERE user_id=$1 AND action=$2 AND window_start=$3 AND used > 0`,
+		userID, action, windowStart)
+	if err != nil {
+		observeTimeout("DecrementQuotaUsage", err)
+		log.Printf("Ошибка при уменьшении счётчика квоты: %v", err)
+		return fmt.Errorf("failed to decrement quota usage: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SetShadowBan(ctx context.Context, userID string, banned bool) error {
+	log.Printf("Теневой бан пользователя %s: %t", userID, banned)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var err error
+	if banned {
+		_, err = s.pool.Exec(ctx, `
+			INSERT INTO shadow_bans (user_id, created_at)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO NOTHING`, userID, s.clock.Now())
+	} else {
+		_, err = s.pool.Exec(ctx, `DELETE FROM shadow_bans WHERE user_id=$1`, userID)
+	}
+	if err != nil {
+		observeTimeout("SetShadowBan", err)
+		log.Printf("Ошибка при изменении теневого бана пользователя %s: %v", userID, err)
+		return fmt.Errorf("failed to update shadow ban: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetPreferences(ctx context.Context, userID string) (*models.Preferences, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	prefs := models.Preferences{UserID: userID}
+	var sort string
+	err := s.pool.QueryRow(ctx, `
+		SELECT email_on_reply, email_on_mention, push_on_reply, push_on_mention, default_comment_sort, locale, email, digest_frequency, updated_at
+		FROM user_preferences
+		WHERE user_id=$1`, userID).Scan(&prefs.EmailOnReply, &prefs.EmailOnMention, &prefs.PushOnReply, &prefs.PushOnMention, &sort, &prefs.Locale, &prefs.Email, &prefs.DigestFrequency, &prefs.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return models.DefaultPreferences(userID), nil
+	}
+	if err != nil {
+		observeTimeout("GetPreferences", err)
+		log.Printf("Ошибка при получении настроек пользователя %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to get preferences: %v", err)
+	}
+	prefs.DefaultCommentSort = models.SortOrder(sort)
+	return &prefs, nil
+}
+
+func (s *PostgresStorage) SavePreferences(ctx context.Context, prefs *models.Preferences) error {
+	log.Printf("Сохранение настроек пользователя %s", prefs.UserID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO user_preferences (user_id, email_on_reply, email_on_mention, push_on_reply, push_on_mention, default_comment_sort, locale, email, digest_frequency, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email_on_reply = EXCLUDED.email_on_reply,
+			email_on_mention = EXCLUDED.email_on_mention,
+			push_on_reply = EXCLUDED.push_on_reply,
+			push_on_mention = EXCLUDED.push_on_mention,
+			default_comment_sort = EXCLUDED.default_comment_sort,
+			locale = EXCLUDED.locale,
+			email = EXCLUDED.email,
+			digest_frequency = EXCLUDED.digest_frequency,
+			updated_at = EXCLUDED.updated_at`,
+		prefs.UserID, prefs.EmailOnReply, prefs.EmailOnMention, prefs.PushOnReply, prefs.PushOnMention, string(prefs.DefaultCommentSort), prefs.Locale, prefs.Email, prefs.DigestFrequency, prefs.UpdatedAt)
+	if err != nil {
+		observeTimeout("SavePreferences", err)
+		log.Printf("Ошибка при сохранении настроек пользователя %s: %v", prefs.UserID, err)
+		return fmt.Errorf("failed to save preferences: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetTenantSettings(ctx context.Context, tenantID string) (*models.TenantSettings, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	settings := models.TenantSettings{TenantID: tenantID}
+	var sort string
+	err := s.pool.QueryRow(ctx, `
+		SELECT default_comment_sort, max_nesting, anonymous_policy, site_name, tagline, logo_url, primary_color, updated_at
+		FROM tenant_settings
+		WHERE tenant_id=$1`, tenantID).Scan(&sort, &settings.MaxNesting, &settings.AnonymousPolicy, &settings.SiteName, &settings.Tagline, &settings.LogoURL, &settings.PrimaryColor, &settings.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return models.DefaultTenantSettings(tenantID), nil
+	}
+	if err != nil {
+		observeTimeout("GetTenantSettings", err)
+		log.Printf("Ошибка при получении настроек сообщества %s: %v", tenantID, err)
+		return nil, fmt.Errorf("failed to get tenant settings: %v", err)
+	}
+	settings.DefaultCommentSort = models.SortOrder(sort)
+	return &settings, nil
+}
+
+func (s *PostgresStorage) SaveTenantSettings(ctx context.Context, settings *models.TenantSettings) error {
+	log.Printf("Сохранение настроек сообщества %s", settings.TenantID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO tenant_settings (tenant_id, default_comment_sort, max_nesting, anonymous_policy, site_name, tagline, logo_url, primary_color, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (tenant_id) DO UPDATE
+		SET default_comment_sort = EXCLUDED.default_comment_sort,
+			max_nesting = EXCLUDED.max_nesting,
+			anonymous_policy = EXCLUDED.anonymous_policy,
+			site_name = EXCLUDED.site_name,
+			tagline = EXCLUDED.tagline,
+			logo_url = EXCLUDED.logo_url,
+			primary_color = EXCLUDED.primary_color,
+			updated_at = EXCLUDED.updated_at`,
+		settings.TenantID, string(settings.DefaultCommentSort), settings.MaxNesting, settings.AnonymousPolicy, settings.SiteName, settings.Tagline, settings.LogoURL, settings.PrimaryColor, settings.UpdatedAt)
+	if err != nil {
+		observeTimeout("SaveTenantSettings", err)
+		log.Printf("Ошибка при сохранении настроек сообщества %s: %v", settings.TenantID, err)
+		return fmt.Errorf("failed to save tenant settings: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) AddTenantUsage(ctx context.Context, tenantID, kind string, bytes int64) error {
+	if kind != models.TargetPost && kind != models.TargetComment {
+		return fmt.Errorf("unknown content kind %q", kind)
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO tenant_usage (tenant_id, kind, items, bytes)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (tenant_id, kind) DO UPDATE
+		SET items = tenant_usage.items + 1,
+			bytes = tenant_usage.bytes + EXCLUDED.bytes`,
+		tenantID, kind, bytes)
+	if err != nil {
+		observeTimeout("AddTenantUsage", err)
+		log.Printf("Ошибка при учёте использования сообщества %s: %v", tenantID, err)
+		return fmt.Errorf("failed to add tenant usage: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetTenantUsage(ctx context.Context, tenantID string) (*models.TenantUsage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT kind, items, bytes
+		FROM tenant_usage
+		WHERE tenant_id=$1`, tenantID)
+	if err != nil {
+		observeTimeout("GetTenantUsage", err)
+		log.Printf("Ошибка при получении использования сообщества %s: %v", tenantID, err)
+		return nil, fmt.Errorf("failed to get tenant usage: %v", err)
+	}
+	defer rows.Close()
+	usage := &models.TenantUsage{TenantID: tenantID}
+	for rows.Next() {
+		var kind string
+		var items, bytes int64
+		if err := rows.Scan(&kind, &items, &bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant usage: %v", err)
+		}
+		switch kind {
+		case models.TargetPost:
+			usage.Posts = items
+		case models.TargetComment:
+			usage.Comments = items
+		}
+		usage.Bytes += bytes
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("GetTenantUsage", err)
+		return nil, fmt.Errorf("failed to get tenant usage: %v", err)
+	}
+	return usage, nil
+}
+
+func (s *PostgresStorage) RegisterDeviceToken(ctx context.Context, token *models.DeviceToken) error {
+	log.Printf("Регистрация устройства %s пользователя %s", token.Platform, token.UserID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO device_tokens (token, user_id, platform, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, created_at = EXCLUDED.created_at`,
+		token.Token, token.UserID, token.Platform, token.CreatedAt)
+	if err != nil {
+		observeTimeout("RegisterDeviceToken", err)
+		log.Printf("Ошибка при регистрации устройства: %v", err)
+		return fmt.Errorf("failed to register device token: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) UnregisterDeviceToken(ctx context.Context, userID, token string) error {
+	log.Printf("Удаление устройства пользователя %s", userID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if _, err := s.pool.Exec(ctx, `DELETE FROM device_tokens WHERE token=$1 AND user_id=$2`, token, userID); err != nil {
+		observeTimeout("UnregisterDeviceToken", err)
+		log.Printf("Ошибка при удалении устройства: %v", err)
+		return fmt.Errorf("failed to unregister device token: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListDeviceTokens(ctx context.Context, userID string) ([]models.DeviceToken, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT token, user_id, platform, created_at
+		FROM device_tokens
+		WHERE user_id=$1
+		ORDER BY created_at, token`, userID)
+	if err != nil {
+		observeTimeout("ListDeviceTokens", err)
+		log.Printf("Ошибка при запросе устройств пользователя %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to list device tokens: %v", err)
+	}
+	defer rows.Close()
+	tokens := []models.DeviceToken{}
+	for rows.Next() {
+		var t models.DeviceToken
+		if err := rows.Scan(&t.Token, &t.UserID, &t.Platform, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan device token: %v", err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("ListDeviceTokens", err)
+		return nil, fmt.Errorf("failed to list device tokens: %v", err)
+	}
+	return tokens, nil
+}
+
+func (s *PostgresStorage) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	log.Printf("Сохранение поиска %s пользователя %s", search.ID, search.UserID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO saved_searches (id, user_id, name, filter, alert, created_at, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		search.ID, search.UserID, search.Name, search.Filter, search.Alert, search.CreatedAt, search.CheckedAt)
+	if err != nil {
+		observeTimeout("CreateSavedSearch", err)
+		log.Printf("Ошибка при сохранении поиска: %v", err)
+		return fmt.Errorf("failed to create saved search: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	return s.querySavedSearches(ctx, "ListSavedSearches", `WHERE user_id=$1`, userID)
+}
+
+func (s *PostgresStorage) DeleteSavedSearch(ctx context.Context, userID, id string) error {
+	log.Printf("Удаление поиска %s пользователя %s", id, userID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `DELETE FROM saved_searches WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		observeTimeout("DeleteSavedSearch", err)
+		log.Printf("Ошибка при удалении поиска: %v", err)
+		return fmt.Errorf("failed to delete saved search: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) CreateCollection(ctx context.Context, collection *models.Collection) error {
+	log.Printf("Создание серии %s автора %s", collection.ID, collection.AuthorID)
+	return s.inCollectionTx(ctx, "CreateCollection", func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO collections (id, author_id, title, description, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			collection.ID, collection.AuthorID, collection.Title, collection.Description, collection.CreatedAt, collection.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create collection: %v", err)
+		}
+		return saveCollectionPosts(ctx, tx, collection.ID, collection.PostIDs)
+	})
+}
+
+func (s *PostgresStorage) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	collections, err := s.queryCollections(ctx, "GetCollection", `WHERE c.id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(collections) == 0 {
+		return nil, storage.ErrCollectionNotFound
+	}
+	return &collections[0], nil
+}
+
+func (s *PostgresStorage) UpdateCollection(ctx context.Context, collection *models.Collection) error {
+	log.Printf("Изменение серии %s: постов %d", collection.ID, len(collection.PostIDs))
+	return s.inCollectionTx(ctx, "UpdateCollection", func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `UPDATE collections SET title=$2, description=$3, updated_at=$4 WHERE id=$1`,
+			collection.ID, collection.Title, collection.Description, collection.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to update collection: %v", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return storage.ErrCollectionNotFound
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM collection_posts WHERE collection_id=$1`, collection.ID); err != nil {
+			return fmt.Errorf("failed to update collection posts: %v", err)
+		}
+		return saveCollectionPosts(ctx, tx, collection.ID, collection.PostIDs)
+	})
+}
+
+func (s *PostgresStorage) DeleteCollection(ctx context.Context, id string) error {
+	log.Printf("Удаление серии %s", id)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	// Посты серии удаляются каскадно
+	tag, err := s.pool.Exec(ctx, `DELETE FROM collections WHERE id=$1`, id)
+	if err != nil {
+		observeTimeout("DeleteCollection", err)
+		log.Printf("Ошибка при удалении серии: %v", err)
+		return fmt.Errorf("failed to delete collection: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrCollectionNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListPostCollections(ctx context.Context, postID string) ([]models.Collection, error) {
+	return s.queryCollections(ctx, "ListPostCollections",
+		`WHERE c.id IN (SELECT collection_id FROM collection_posts WHERE post_id=$1)`, postID)
+}
+
+// inCollectionTx выполняет изменение серии fn в транзакции
+func (s *PostgresStorage) inCollectionTx(ctx context.Context, op string, fn func(tx pgx.Tx) error) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout(op, err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(tx); err != nil {
+		observeTimeout(op, err)
+		log.Printf("Ошибка при изменении серии (%s): %v", op, err)
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout(op, err)
+		return fmt.Errorf("failed to commit collection: %v", err)
+	}
+	return nil
+}
+
+// saveCollectionPosts записывает посты серии с их позициями
+func saveCollectionPosts(ctx context.Context, tx pgx.Tx, collectionID string, postIDs []string) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO collection_posts (collection_id, post_id, position)
+		SELECT $1, post_id, position FROM unnest($2::TEXT[]) WITH ORDINALITY AS t(post_id, position)`,
+		collectionID, postIDs)
+	if isForeignKeyViolation(err) {
+		return storage.ErrPostNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save collection posts: %v", err)
+	}
+	return nil
+}
+
+// queryCollections возвращает серии, отобранные условием where, в порядке создания
+func (s *PostgresStorage) queryCollections(ctx context.Context, op, where string, args ...any) ([]models.Collection, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.id, c.author_id, c.title, c.description, c.created_at, c.updated_at,
+			COALESCE((SELECT array_agg(p.post_id ORDER BY p.position) FROM collection_posts p WHERE p.collection_id = c.id), '{}')
+		FROM collections c
+		`+where+`
+		ORDER BY c.created_at, c.id`, args...)
+	if err != nil {
+		observeTimeout(op, err)
+		log.Printf("Ошибка при запросе серий: %v", err)
+		return nil, fmt.Errorf("failed to list collections: %v", err)
+	}
+	defer rows.Close()
+	collections := []models.Collection{}
+	for rows.Next() {
+		var c models.Collection
+		if err := rows.Scan(&c.ID, &c.AuthorID, &c.Title, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.PostIDs); err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %v", err)
+		}
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout(op, err)
+		return nil, fmt.Errorf("failed to list collections: %v", err)
+	}
+	return collections, nil
+}
+
+func (s *PostgresStorage) ListAlertSavedSearches(ctx context.Context) ([]models.SavedSearch, error) {
+	return s.querySavedSearches(ctx, "ListAlertSavedSearches", `WHERE alert`)
+}
+
+// querySavedSearches возвращает сохранённые поиски, отобранные условием where, в порядке создания
+func (s *PostgresStorage) querySavedSearches(ctx context.Context, op, where string, args ...any) ([]models.SavedSearch, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, user_id, name, filter, alert, created_at, checked_at
+		FROM saved_searches
+		`+where+`
+		ORDER BY created_at, id`, args...)
+	if err != nil {
+		observeTimeout(op, err)
+		log.Printf("Ошибка при запросе сохранённых поисков: %v", err)
+		return nil, fmt.Errorf("failed to list saved searches: %v", err)
+	}
+	defer rows.Close()
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		var search models.SavedSearch
+		if err := rows.Scan(&search.ID, &search.UserID, &search.Name, &search.Filter, &search.Alert, &search.CreatedAt, &search.CheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %v", err)
+		}
+		searches = append(searches, search)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout(op, err)
+		return nil, fmt.Errorf("failed to list saved searches: %v", err)
+	}
+	return searches, nil
+}
+
+func (s *PostgresStorage) MarkSavedSearchChecked(ctx context.Context, id string, checkedAt time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `UPDATE saved_searches SET checked_at=$2 WHERE id=$1 AND checked_at < $2`, id, checkedAt)
+	if err != nil {
+		observeTimeout("MarkSavedSearchChecked", err)
+		log.Printf("Ошибка при отметке проверки поиска %s: %v", id, err)
+		return fmt.Errorf("failed to mark saved search checked: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SubscribeToPost(ctx context.Context, userID, postID string, at time.Time) error {
+	log.Printf("Подписка пользователя %s на пост %s", userID, postID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO post_subscriptions (user_id, post_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, post_id) DO NOTHING`, userID, postID, at)
+	if isForeignKeyViolation(err) {
+		return storage.ErrPostNotFound
+	}
+	if err != nil {
+		observeTimeout("SubscribeToPost", err)
+		log.Printf("Ошибка при подписке на пост %s: %v", postID, err)
+		return fmt.Errorf("failed to subscribe to post: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) UnsubscribeFromPost(ctx context.Context, userID, postID string) error {
+	log.Printf("Отписка пользователя %s от поста %s", userID, postID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if _, err := s.pool.Exec(ctx, `DELETE FROM post_subscriptions WHERE user_id=$1 AND post_id=$2`, userID, postID); err != nil {
+		observeTimeout("UnsubscribeFromPost", err)
+		log.Printf("Ошибка при отписке от поста %s: %v", postID, err)
+		return fmt.Errorf("failed to unsubscribe from post: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListDigestSubscribers(ctx context.Context) ([]models.DigestSubscriber, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ps.user_id, d.sent_until
+		FROM post_subscriptions ps
+		LEFT JOIN digest_deliveries d ON d.user_id = ps.user_id
+		ORDER BY ps.user_id`)
+	if err != nil {
+		observeTimeout("ListDigestSubscribers", err)
+		log.Printf("Ошибка при запросе подписчиков сводок: %v", err)
+		return nil, fmt.Errorf("failed to list digest subscribers: %v", err)
+	}
+	defer rows.Close()
+	subscribers := []models.DigestSubscriber{}
+	for rows.Next() {
+		var subscriber models.DigestSubscriber
+		var sentUntil *time.Time
+		if err := rows.Scan(&subscriber.UserID, &sentUntil); err != nil {
+			return nil, fmt.Errorf("failed to scan digest subscriber: %v", err)
+		}
+		if sentUntil != nil {
+			subscriber.LastSentAt = *sentUntil
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("ListDigestSubscribers", err)
+		return nil, fmt.Errorf("failed to list digest subscribers: %v", err)
+	}
+	return subscribers, nil
+}
+
+func (s *PostgresStorage) GetDigestComments(ctx context.Context, userID string, since, until time.Time, limit int) ([]models.Comment, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.id, c.post_id, c.parent_id, c.author_id, c.content, c.format, c.created_at, c.hidden, c.tags, c.spam_status, c.upvotes, c.downvotes, c.language, c.quoted_comment_id, c.approval_status
+		FROM post_subscriptions ps
+		JOIN comments c ON c.post_id = ps.post_id
+		WHERE ps.user_id = $1 AND c.author_id <> $1 AND NOT c.hidden
+			AND c.created_at > $2 AND c.created_at > ps.created_at AND c.created_at <= $3
+		ORDER BY c.post_id, c.created_at, c.id
+		LIMIT $4`, userID, since, until, limit)
+	if err != nil {
+		observeTimeout("GetDigestComments", err)
+		log.Printf("Ошибка при запросе комментариев для сводки пользователя %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to get digest comments: %v", err)
+	}
+	defer rows.Close()
+	comments := []models.Comment{}
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %v", err)
+		}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("GetDigestComments", err)
+		return nil, fmt.Errorf("failed to get digest comments: %v", err)
+	}
+	return comments, nil
+}
+
+func (s *PostgresStorage) MarkDigestSent(ctx context.Context, userID string, until time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO digest_deliveries (user_id, sent_until)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET sent_until = EXCLUDED.sent_until`, userID, until)
+	if err != nil {
+		observeTimeout("MarkDigestSent", err)
+		log.Printf("Ошибка при сохранении отправки сводки пользователю %s: %v", userID, err)
+		return fmt.Errorf("failed to mark digest sent: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) IsShadowBanned(ctx context.Context, userID string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var banned bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM shadow_bans WHERE user_id=$1)`, userID).Scan(&banned)
+	if err != nil {
+		observeTimeout("IsShadowBanned", err)
+		log.Printf("Ошибка при проверке теневого бана пользователя %s: %v", userID, err)
+		return false, fmt.Errorf("failed to check shadow ban: %v", err)
+	}
+	return banned, nil
+}
+
+func (s *PostgresStorage) SetFollow(ctx context.Context, followerID, userID string, follow bool) error {
+	log.Printf("Подписка пользователя %s на %s: %t", followerID, userID, follow)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var err error
+	if follow {
+		_, err = s.pool.Exec(ctx, `
+			INSERT INTO follows (follower_id, user_id, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (follower_id, user_id) DO NOTHING`, followerID, userID, s.clock.Now())
+	} else {
+		_, err = s.pool.Exec(ctx, `DELETE FROM follows WHERE follower_id=$1 AND user_id=$2`, followerID, userID)
+	}
+	if err != nil {
+		observeTimeout("SetFollow", err)
+		log.Printf("Ошибка при изменении подписки пользователя %s на %s: %v", followerID, userID, err)
+		return fmt.Errorf("failed to update follow: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) IsFollowing(ctx context.Context, followerID, userID string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var following bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM follows WHERE follower_id=$1 AND user_id=$2)`, followerID, userID).Scan(&following)
+	if err != nil {
+		observeTimeout("IsFollowing", err)
+		log.Printf("Ошибка при проверке подписки пользователя %s на %s: %v", followerID, userID, err)
+		return false, fmt.Errorf("failed to check follow: %v", err)
+	}
+	return following, nil
+}
+
+func (s *PostgresStorage) ListModerationRules(ctx context.Context) ([]models.ModerationRule, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, kind, pattern, action, tag, created_by, created_at
+		FROM moderation_rules
+		ORDER BY created_at, id`)
+	if err != nil {
+		observeTimeout("ListModerationRules", err)
+		log.Printf("Ошибка при запросе правил автомодерации: %v", err)
+		return nil, fmt.Errorf("failed to query moderation rules: %v", err)
+	}
+	defer rows.Close()
+	rules := []models.ModerationRule{}
+	for rows.Next() {
+		var r models.ModerationRule
+		if err := rows.Scan(&r.ID, &r.Kind, &r.Pattern, &r.Action, &r.Tag, &r.CreatedBy, &r.CreatedAt); err != nil {
+			log.Printf("Ошибка при сканировании правила автомодерации: %v", err)
+			return nil, fmt.Errorf("failed to scan moderation rule: %v", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func (s *PostgresStorage) CreateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	log.Printf("Создание правила автомодерации: ID=%s, Kind=%s, Action=%s", rule.ID, rule.Kind, rule.Action)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO moderation_rules (id, kind, pattern, action, tag, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		rule.ID, rule.Kind, rule.Pattern, rule.Action, rule.Tag, rule.CreatedBy, rule.CreatedAt)
+	if err != nil {
+		observeTimeout("CreateModerationRule", err)
+		log.Printf("Ошибка при создании правила автомодерации ID=%s: %v", rule.ID, err)
+		return fmt.Errorf("failed to insert moderation rule: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) UpdateModerationRule(ctx context.Context, rule *models.ModerationRule) error {
+	log.Printf("Обновление правила автомодерации: ID=%s", rule.ID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	err := s.pool.QueryRow(ctx, `
+		UPDATE moderation_rules
+		SET kind=$2, pattern=$3, action=$4, tag=$5
+		WHERE id=$1
+		RETURNING created_by, created_at`,
+		rule.ID, rule.Kind, rule.Pattern, rule.Action, rule.Tag).Scan(&rule.CreatedBy, &rule.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return storage.ErrRuleNotFound
+	}
+	if err != nil {
+		observeTimeout("UpdateModerationRule", err)
+		log.Printf("Ошибка при обновлении правила автомодерации ID=%s: %v", rule.ID, err)
+		return fmt.Errorf("failed to update moderation rule: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) DeleteModerationRule(ctx context.Context, id string) error {
+	log.Printf("Удаление правила автомодерации: ID=%s", id)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, `DELETE FROM moderation_rules WHERE id=$1`, id)
+	if err != nil {
+		observeTimeout("DeleteModerationRule", err)
+		log.Printf("Ошибка при удалении правила автомодерации ID=%s: %v", id, err)
+		return fmt.Errorf("failed to delete moderation rule: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrRuleNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListAllowedOperations(ctx context.Context) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `SELECT query FROM allowed_operations ORDER BY created_at, hash`)
+	if err != nil {
+		observeTimeout("ListAllowedOperations", err)
+		log.Printf("Ошибка при запросе разрешённых операций: %v", err)
+		return nil, fmt.Errorf("failed to query allowed operations: %v", err)
+	}
+	defer rows.Close()
+	queries := []string{}
+	for rows.Next() {
+		var query string
+		if err := rows.Scan(&query); err != nil {
+			log.Printf("Ошибка при сканировании разрешённой операции: %v", err)
+			return nil, fmt.Errorf("failed to scan allowed operation: %v", err)
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
+
+func (s *PostgresStorage) AddAllowedOperation(ctx context.Context, query string) error {
+	hash := storage.ContentHash(query)
+	log.Printf("Добавление разрешённой операции: %s", hash)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO allowed_operations (hash, query)
+		VALUES ($1, $2)
+		ON CONFLICT (hash) DO NOTHING`,
+		hash, query)
+	if err != nil {
+		observeTimeout("AddAllowedOperation", err)
+		log.Printf("Ошибка при добавлении разрешённой операции %s: %v", hash, err)
+		return fmt.Errorf("failed to insert allowed operation: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) HoldContent(ctx context.Context, item *models.HeldItem) error {
+	log.Printf("Содержимое %s %s задержано правилом %s", item.TargetType, item.TargetID, item.RuleID)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO held_content (id, target_type, target_id, rule_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		item.ID, item.TargetType, item.TargetID, item.RuleID, item.CreatedAt)
+	if err != nil {
+		observeTimeout("HoldContent", err)
+		log.Printf("Ошибка при постановке содержимого %s в очередь проверки: %v", item.TargetID, err)
+		return fmt.Errorf("failed to hold content: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListHeldContent(ctx context.Context, limit int) ([]models.HeldItem, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, target_type, target_id, rule_id, created_at
+		FROM held_content
+		ORDER BY created_at, id
+		LIMIT $1`, limit)
+	if err != nil {
+		observeTimeout("ListHeldContent", err)
+		log.Printf("Ошибка при запросе очереди проверки: %v", err)
+		return nil, fmt.Errorf("failed to query held content: %v", err)
+	}
+	defer rows.Close()
+	items := []models.HeldItem{}
+	for rows.Next() {
+		var item models.HeldItem
+		if err := rows.Scan(&item.ID, &item.TargetType, &item.TargetID, &item.RuleID, &item.CreatedAt); err != nil {
+			log.Printf("Ошибка при сканировании записи очереди проверки: %v", err)
+			return nil, fmt.Errorf("failed to scan held content: %v", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStorage) ResolveHeldContent(ctx context.Context, id string, approve bool) error {
+	log.Printf("Решение по задержанному содержимому %s: approve=%t", id, approve)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("ResolveHeldContent", err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var targetType, targetID string
+	err = tx.QueryRow(ctx, `
+		DELETE FROM held_content
+		WHERE id=$1
+		RETURNING target_type, target_id`, id).Scan(&targetType, &targetID)
+	if err == pgx.ErrNoRows {
+		return storage.ErrHeldItemNotFound
+	}
+	if err != nil {
+		observeTimeout("ResolveHeldContent", err)
+		log.Printf("Ошибка при удалении записи %s из очереди проверки: %v", id, err)
+		return fmt.Errorf("failed to resolve held content: %v", err)
+	}
+	if approve {
+		// Счётчик комментариев обновляется триггером при смене hidden
+		query := `UPDATE posts SET hidden=FALSE WHERE id=$1`
+		if targetType == models.TargetComment {
+			query = `UPDATE comments SET hidden=FALSE WHERE id=$1`
+		}
+		if _, err := tx.Exec(ctx, query, targetID); err != nil {
+			observeTimeout("ResolveHeldContent", err)
+			log.Printf("Ошибка при публикации содержимого %s: %v", targetID, err)
+			return fmt.Errorf("failed to publish held content: %v", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("ResolveHeldContent", err)
+		return fmt.Errorf("failed to commit held content resolution: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, error) {
+	log.Printf("Голос пользователя %s за комментарий %s: %d", vote.UserID, vote.CommentID, vote.Value)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("VoteComment", err)
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка строки комментария сериализует голоса за него, поэтому счётчики
+	// обновляются на разницу с прежним голосом без пересчёта comment_votes
+	var c models.Comment
+	err = tx.QueryRow(ctx, `
+		SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status
+		FROM comments
+		WHERE id=$1
+		FOR UPDATE`, vote.CommentID).Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus)
+	if err == pgx.ErrNoRows {
+		return nil, s.missingCommentError(ctx, tx, vote.CommentID)
+	}
+	if err != nil {
+		observeTimeout("VoteComment", err)
+		log.Printf("Ошибка блокировки комментария %s: %v", vote.CommentID, err)
+		return nil, fmt.Errorf("failed to lock comment: %v", err)
+	}
+	var previous int
+	err = tx.QueryRow(ctx, `SELECT value FROM comment_votes WHERE comment_id=$1 AND user_id=$2`, vote.CommentID, vote.UserID).Scan(&previous)
+	if err != nil && err != pgx.ErrNoRows {
+		observeTimeout("VoteComment", err)
+		return nil, fmt.Errorf("failed to get previous vote: %v", err)
+	}
+	if vote.Value == 0 {
+		_, err = tx.Exec(ctx, `DELETE FROM comment_votes WHERE comment_id=$1 AND user_id=$2`, vote.CommentID, vote.UserID)
+	} else {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO comment_votes (comment_id, user_id, value, created_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (comment_id, user_id) DO UPDATE SET value=EXCLUDED.value, created_at=EXCLUDED.created_at`,
+			vote.CommentID, vote.UserID, vote.Value, vote.CreatedAt)
+	}
+	if err != nil {
+		observeTimeout("VoteComment", err)
+		log.Printf("Ошибка при сохранении голоса за комментарий %s: %v", vote.CommentID, err)
+		return nil, fmt.Errorf("failed to save vote: %v", err)
+	}
+
+	c.Upvotes += voteDelta(previous, vote.Value, 1)
+	c.Downvotes += voteDelta(previous, vote.Value, -1)
+	_, err = tx.Exec(ctx, `UPDATE comments SET upvotes=$2, downvotes=$3, best_score=$4 WHERE id=$1`,
+		c.ID, c.Upvotes, c.Downvotes, storage.WilsonScore(c.Upvotes, c.Downvotes))
+	if err != nil {
+		observeTimeout("VoteComment", err)
+		log.Printf("Ошибка при обновлении счётчиков голосов комментария %s: %v", vote.CommentID, err)
+		return nil, fmt.Errorf("failed to update vote counts: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("VoteComment", err)
+		return nil, fmt.Errorf("failed to commit vote: %v", err)
+	}
+	return &c, nil
+}
+
+// voteDelta возвращает изменение счётчика голосов со значением side при замене голоса previous на current
+func voteDelta(previous, current, side int) int {
+	delta := 0
+	if previous == side {
+		delta--
+	}
+	if current == side {
+		delta++
+	}
+	return delta
+}
+
+func (s *PostgresStorage) SetSpamStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	log.Printf("Статус проверки на спам комментария %s: %s, hidden=%t", commentID, status, hidden)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	// Счётчик комментариев обновляется триггером при смене hidden
+	tag, err := s.pool.Exec(ctx, `UPDATE comments SET spam_status=$2, hidden=$3 WHERE id=$1`, commentID, status, hidden)
+	if err != nil {
+		observeTimeout("SetSpamStatus", err)
+		log.Printf("Ошибка при сохранении статуса проверки комментария %s: %v", commentID, err)
+		return fmt.Errorf("failed to set spam status: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrCommentNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SetToxicityScore(ctx context.Context, score models.ToxicityScore, hold *models.HeldItem) error {
+	log.Printf("Оценка токсичности комментария %s: %.3f, hold=%t", score.CommentID, score.Score, hold != nil)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("SetToxicityScore", err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM comments_all WHERE id=$1)`, score.CommentID).Scan(&exists); err != nil {
+		observeTimeout("SetToxicityScore", err)
+		return fmt.Errorf("failed to check comment: %v", err)
+	}
+	if !exists {
+		return storage.ErrCommentNotFound
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO comment_toxicity (comment_id, score, scored_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (comment_id) DO UPDATE SET score=EXCLUDED.score, scored_at=EXCLUDED.scored_at`,
+		score.CommentID, score.Score, score.ScoredAt)
+	if err != nil {
+		observeTimeout("SetToxicityScore", err)
+		log.Printf("Ошибка при сохранении оценки токсичности комментария %s: %v", score.CommentID, err)
+		return fmt.Errorf("failed to set toxicity score: %v", err)
+	}
+	if hold != nil {
+		// Счётчик комментариев обновляется триггером при смене hidden
+		tag, err := tx.Exec(ctx, `UPDATE comments SET hidden=TRUE WHERE id=$1 AND NOT hidden`, score.CommentID)
+		if err != nil {
+			observeTimeout("SetToxicityScore", err)
+			return fmt.Errorf("failed to hide comment: %v", err)
+		}
+		if tag.RowsAffected() > 0 {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO held_content (id, target_type, target_id, rule_id, created_at)
+				VALUES ($1, $2, $3, $4, $5)`,
+				hold.ID, hold.TargetType, hold.TargetID, hold.RuleID, hold.CreatedAt)
+			if err != nil {
+				observeTimeout("SetToxicityScore", err)
+				return fmt.Errorf("failed to hold comment: %v", err)
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("SetToxicityScore", err)
+		return fmt.Errorf("failed to commit toxicity score: %v", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetToxicityScores(ctx context.Context, commentIDs []string) (map[string]models.ToxicityScore, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT comment_id, score, scored_at
+		FROM comment_toxicity
+		WHERE comment_id = ANY($1)`, commentIDs)
+	if err != nil {
+		observeTimeout("GetToxicityScores", err)
+		log.Printf("Ошибка при получении оценок токсичности: %v", err)
+		return nil, fmt.Errorf("failed to get toxicity scores: %v", err)
+	}
+	defer rows.Close()
+	result := make(map[string]models.ToxicityScore, len(commentIDs))
+	for rows.Next() {
+		var score models.ToxicityScore
+		if err := rows.Scan(&score.CommentID, &score.Score, &score.ScoredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan toxicity score: %v", err)
+		}
+		result[score.CommentID] = score
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStorage) ListCommentsBySpamStatus(ctx context.Context, status string, limit int) ([]models.Comment, error) {
+	log.Printf("Запрос комментариев со статусом проверки %s, limit=%d", status, limit)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status
+		FROM comments
+		WHERE spam_status=$1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, status, limit)
+	if err != nil {
+		observeTimeout("ListCommentsBySpamStatus", err)
+		log.Printf("Ошибка при запросе комментариев со статусом %s: %v", status, err)
+		return nil, fmt.Errorf("failed to list comments by spam status: %v", err)
+	}
+	defer rows.Close()
+	comments := []models.Comment{}
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %v", err)
+		}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("ListCommentsBySpamStatus", err)
+		return nil, fmt.Errorf("failed to list comments by spam status: %v", err)
+	}
+	return comments, nil
+}
+
+func (s *PostgresStorage) SetApprovalStatus(ctx context.Context, commentID, status string, hidden bool) error {
+	log.Printf("Статус премодерации комментария %s: %s, hidden=%t", commentID, status, hidden)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	// Счётчик комментариев обновляется триггером при смене hidden
+	tag, err := s.pool.Exec(ctx, `UPDATE comments SET approval_status=$2, hidden=$3 WHERE id=$1`, commentID, status, hidden)
+	if err != nil {
+		observeTimeout("SetApprovalStatus", err)
+		log.Printf("Ошибка при сохранении статуса премодерации комментария %s: %v", commentID, err)
+		return fmt.Errorf("failed to set approval status: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrCommentNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStorage) ListPendingComments(ctx context.Context, postID string, limit int) ([]models.Comment, error) {
+	log.Printf("Запрос комментариев на премодерации: postID=%q, limit=%d", postID, limit)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	// Комментарии на премодерации не переносятся в архив, поэтому читается только рабочая таблица
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, post_id, parent_id, author_id, content, format, created_at, hidden, tags, spam_status, upvotes, downvotes, language, quoted_comment_id, approval_status
+		FROM comments
+		WHERE approval_status='PENDING' AND ($1::TEXT = '' OR post_id=$1)
+		ORDER BY created_at, id
+		LIMIT $2`, postID, limit)
+	if err != nil {
+		observeTimeout("ListPendingComments", err)
+		log.Printf("Ошибка при запросе комментариев на премодерации: %v", err)
+		return nil, fmt.Errorf("failed to list pending comments: %v", err)
+	}
+	defer rows.Close()
+	comments := []models.Comment{}
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.AuthorID, &c.Content, &c.Format, &c.CreatedAt, &c.Hidden, &c.Tags, &c.SpamStatus, &c.Upvotes, &c.Downvotes, &c.Language, &c.QuotedCommentID, &c.ApprovalStatus); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %v", err)
+		}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("ListPendingComments", err)
+		return nil, fmt.Errorf("failed to list pending comments: %v", err)
+	}
+	return comments, nil
+}
+
+// contentTables возвращает источник для чтения содержимого постов или комментариев и таблицы для его записи:
+// комментарии читаются вместе с архивом, поэтому перешифровываются и архивные записи
+func contentTables(kind string) (string, []string, error) {
+	switch kind {
+	case models.TargetPost:
+		return "posts", []string{"posts"}, nil
+	case models.TargetComment:
+		return "comments_all", []string{"comments", "comments_archive"}, nil
+	}
+	return "", nil, fmt.Errorf("unknown content kind %q", kind)
+}
+
+func (s *PostgresStorage) ListContents(ctx context.Context, kind, afterID string, limit int) ([]storage.ContentRecord, error) {
+	log.Printf("Запрос содержимого %s после ID=%s, limit=%d", kind, afterID, limit)
+	table, _, err := contentTables(kind)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `SELECT id, content FROM `+table+` WHERE id > $1 ORDER BY id LIMIT $2`, afterID, limit)
+	if err != nil {
+		observeTimeout("ListContents", err)
+		log.Printf("Ошибка при запросе содержимого %s: %v", kind, err)
+		return nil, fmt.Errorf("failed to list contents: %v", err)
+	}
+	defer rows.Close()
+	records := []storage.ContentRecord{}
+	for rows.Next() {
+		var record storage.ContentRecord
+		if err := rows.Scan(&record.ID, &record.Content); err != nil {
+			return nil, fmt.Errorf("failed to scan content: %v", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		observeTimeout("ListContents", err)
+		return nil, fmt.Errorf("failed to list contents: %v", err)
+	}
+	return records, nil
+}
+
+func (s *PostgresStorage) ReplaceContent(ctx context.Context, kind, id, previous, content string) (bool, error) {
+	_, tables, err := contentTables(kind)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	for _, table := range tables {
+		tag, err := s.pool.Exec(ctx, `UPDATE `+table+` SET content=$3 WHERE id=$1 AND content=$2`, id, previous, content)
+		if err != nil {
+			observeTimeout("ReplaceContent", err)
+			log.Printf("Ошибка при замене содержимого %s %s: %v", kind, id, err)
+			return false, fmt.Errorf("failed to replace content: %v", err)
+		}
+		if tag.RowsAffected() > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// anonymizeQueries - запросы AnonymizeUserContent: обезличивание пачки записей пользователя с блокировкой строк
+var anonymizeQueries = map[string]string{
+	models.TargetPost: `
+		UPDATE posts p SET author_id=$3, title='', content='', excerpt='', tags='{}', language='', slug=p.id
+		FROM (SELECT id FROM posts WHERE author_id=$1 ORDER BY id LIMIT $2 FOR UPDATE) batch
+		WHERE p.id = batch.id
+		RETURNING p.id`,
+	models.TargetComment: `
+		UPDATE comments c SET author_id=$3, content='', content_hash='', language=''
+		FROM (SELECT id FROM comments WHERE author_id=$1 ORDER BY id LIMIT $2 FOR UPDATE) batch
+		WHERE c.id = batch.id
+		RETURNING c.id`,
+}
+
+// anonymizeArchivedComments обезличивает комментарии пользователя в архиве после рабочей таблицы
+const anonymizeArchivedComments = `
+	UPDATE comments_archive c SET author_id=$3, content='', content_hash='', language=''
+	FROM (SELECT id FROM comments_archive WHERE author_id=$1 ORDER BY id LIMIT $2 FOR UPDATE) batch
+	WHERE c.id = batch.id
+	RETURNING c.id`
+
+func (s *PostgresStorage) AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error) {
+	log.Printf("Обезличивание %s пользователя %s: limit=%d", kind, userID, limit)
+	query, ok := anonymizeQueries[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown content kind %q", kind)
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		observeTimeout("AnonymizeUserContent", err)
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids, err := anonymizeBatch(ctx, tx, query, userID, limit)
+	if err == nil && kind == models.TargetComment && len(ids) < limit {
+		var archived []string
+		archived, err = anonymizeBatch(ctx, tx, anonymizeArchivedComments, userID, limit-len(ids))
+		ids = append(ids, archived...)
+	}
+	if err != nil {
+		observeTimeout("AnonymizeUserContent", err)
+		log.Printf("Ошибка при обезличивании %s пользователя %s: %v", kind, userID, err)
+		return nil, err
+	}
+	if kind == models.TargetPost && len(ids) > 0 {
+		// Прежние slug и превью получены из заголовка и текста поста, поэтому удаляются вместе с ними
+		for _, cleanup := range []string{
+			`DELETE FROM post_slugs WHERE post_id = ANY($1) AND slug <> post_id`,
+			`INSERT INTO post_slugs (slug, post_id, created_at)
+			SELECT id, id, created_at FROM posts WHERE id = ANY($1)
+			ON CONFLICT (slug) DO NOTHING`,
+			`DELETE FROM post_references WHERE source_id = ANY($1)`,
+			`DELETE FROM link_previews WHERE post_id = ANY($1)`,
+		} {
+			if _, err := tx.Exec(ctx, cleanup, ids); err != nil {
+				observeTimeout("AnonymizeUserContent", err)
+				log.Printf("Ошибка при удалении данных обезличенных постов пользователя %s: %v", userID, err)
+				return nil, fmt.Errorf("failed to anonymize user content: %v", err)
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		observeTimeout("AnonymizeUserContent", err)
+		return nil, fmt.Errorf("failed to commit anonymized content: %v", err)
+	}
+	return ids, nil
+}
+
+// anonymizeBatch выполняет запрос обезличивания и возвращает ID обработанных записей
+func anonymizeBatch(ctx context.Context, tx pgx.Tx, query, userID string, limit int) ([]string, error) {
+	rows, err := tx.Query(ctx, query, userID, limit, models.DeletedUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to anonymize user content: %v", err)
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan anonymized id: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to anonymize user content: %v", err)
+	}
+	return ids, nil
+}
+
+func (s *PostgresStorage) Close() error {
+	log.Println("Закрытие соединения с PostgreSQL")
+	if s.reconcileStop != nil {
+		close(s.reconcileStop)
+		<-s.reconcileDone
+		s.reconcileStop = nil
+	}
+	if s.archiveStop != nil {
+		close(s.archiveStop)
+		<-s.archiveDone
+		s.archiveStop = nil
+	}
+	if s.partitionStop != nil {
+		close(s.partitionStop)
+		<-s.partitionDone
+		s.partitionStop = nil
+	}
+	s.pool.Close()
+	log.Println("Соединение с PostgreSQL успешно закрыто")
+	return nil
+}
+
+// formatOrPlain возвращает формат содержимого, по умолчанию PLAIN
+// visibilityOrPublic возвращает видимость поста, по умолчанию PUBLIC
+func visibilityOrPublic(visibility string) string {
+	if visibility == "" {
+		return models.VisibilityPublic
+	}
+	return visibility
+}
+
+// postVisibleCondition возвращает условие на строку posts: пост виден зрителю с ID в параметре viewer,
+// как в storage.PostVisibleToFollower. Подписка на автора проверяется по первичному ключу follows
+func postVisibleCondition(viewer string) string {
+	return `(author_id = ` + viewer + ` OR NOT hidden AND (visibility IN ('PUBLIC', 'UNLISTED')
+		OR visibility = 'FOLLOWERS' AND EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = ` + viewer + ` AND f.user_id = posts.author_id)))`
+}
+
+// postListedCondition возвращает условие на строку posts: пост попадает в списки зрителя viewerID, ID которого
+// передаётся в параметре viewer, как в storage.PostListedTo. Для анонимного зрителя условие сводится
+// к публичным постам, чтобы списки читались по частичному индексу idx_posts_visibility; параметр
+// остаётся в запросе, чтобы не менять нумерацию остальных
+func postListedCondition(viewerID, viewer string) string {
+	if viewerID == "" {
+		return `(NOT hidden AND visibility = 'PUBLIC' AND ` + viewer + `::TEXT = '')`
+	}
+	return `(author_id = ` + viewer + ` OR NOT hidden AND (visibility = 'PUBLIC'
+		OR visibility = 'FOLLOWERS' AND EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = ` + viewer + ` AND f.user_id = posts.author_id)))`
+}
+
+func formatOrPlain(format string) string {
+	if format == "" {
+		return models.FormatPlain
+	}
+	return format
+}
+
+// isForeignKeyViolation сообщает, нарушает ли запись внешний ключ, то есть ссылается на несуществующий пост или категорию
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode
+}
+
+// tagsOrEmpty возвращает теги или пустой срез для nil, так как колонка tags не допускает NULL
+func tagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// stringOrEmpty возвращает значение указателя или пустую строку для nil
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,535 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// schemaDDL создаёт таблицы и индексы, если их ещё нет
+const schemaDDL = `
+	CREATE TABLE IF NOT EXISTS posts (
+		id TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		allow_comments BOOLEAN NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS comments (
+		id TEXT PRIMARY KEY,
+		post_id TEXT REFERENCES posts(id),
+		parent_id TEXT,
+		author_id TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
+	CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT 'PLAIN';
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT 'PLAIN';
+	CREATE TABLE IF NOT EXISTS link_previews (
+		post_id TEXT REFERENCES posts(id),
+		url TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL,
+		image_url TEXT NOT NULL,
+		site_name TEXT NOT NULL,
+		fetched_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (post_id, url)
+	);
+	CREATE TABLE IF NOT EXISTS reactions (
+		target_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (target_id, user_id, emoji)
+	);
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_comments_dedupe ON comments(author_id, post_id, content_hash, created_at);
+	CREATE TABLE IF NOT EXISTS post_comment_counts (
+		post_id TEXT NOT NULL,
+		parent_id TEXT NOT NULL DEFAULT '',
+		comment_count BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (post_id, parent_id)
+	);
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE;
+	CREATE OR REPLACE FUNCTION update_post_comment_counts() RETURNS TRIGGER AS $$
+	BEGIN
+		-- Скрытые комментарии видны только автору и считаются отдельно при чтении
+		IF TG_OP = 'INSERT' THEN
+			IF NEW.hidden THEN
+				RETURN NULL;
+			END IF;
+			INSERT INTO post_comment_counts (post_id, parent_id, comment_count)
+			VALUES (NEW.post_id, COALESCE(NEW.parent_id, ''), 1)
+			ON CONFLICT (post_id, parent_id) DO UPDATE
+			SET comment_count = post_comment_counts.comment_count + 1;
+			RETURN NEW;
+		END IF;
+		IF TG_OP = 'DELETE' THEN
+			-- Комментарий, перенесённый в архив или в другую секцию, продолжает учитываться
+			IF OLD.hidden OR current_setting('system.moving_comments', true) = 'on' THEN
+				RETURN NULL;
+			END IF;
+			UPDATE post_comment_counts
+			SET comment_count = GREATEST(comment_count - 1, 0)
+			WHERE post_id = OLD.post_id AND parent_id = COALESCE(OLD.parent_id, '');
+			RETURN OLD;
+		END IF;
+		-- Одобренный модератором комментарий начинает учитываться, скрытый - перестаёт
+		IF OLD.hidden = NEW.hidden THEN
+			RETURN NULL;
+		END IF;
+		INSERT INTO post_comment_counts (post_id, parent_id, comment_count)
+		VALUES (NEW.post_id, COALESCE(NEW.parent_id, ''), CASE WHEN NEW.hidden THEN 0 ELSE 1 END)
+		ON CONFLICT (post_id, parent_id) DO UPDATE
+		SET comment_count = GREATEST(post_comment_counts.comment_count + CASE WHEN NEW.hidden THEN -1 ELSE 1 END, 0);
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS comments_count_trigger ON comments;
+	CREATE TRIGGER comments_count_trigger
+		AFTER INSERT OR DELETE OR UPDATE OF hidden ON comments
+		FOR EACH ROW EXECUTE FUNCTION update_post_comment_counts();
+	CREATE TABLE IF NOT EXISTS quota_usage (
+		user_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		window_start TIMESTAMP NOT NULL,
+		used INTEGER NOT NULL,
+		PRIMARY KEY (user_id, action, window_start)
+	);
+	CREATE TABLE IF NOT EXISTS shadow_bans (
+		user_id TEXT PRIMARY KEY,
+		created_at TIMESTAMP NOT NULL
+	);
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+	CREATE TABLE IF NOT EXISTS moderation_rules (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		pattern TEXT NOT NULL,
+		action TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS held_content (
+		id TEXT PRIMARY KEY,
+		target_type TEXT NOT NULL,
+		target_id TEXT NOT NULL,
+		rule_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_held_content_created_at ON held_content(created_at);
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS spam_status TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_comments_spam_status ON comments(spam_status, created_at);
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS upvotes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS downvotes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS best_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_comments_best ON comments(post_id, best_score DESC, created_at DESC, id DESC);
+	CREATE TABLE IF NOT EXISTS comment_votes (
+		comment_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		value SMALLINT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (comment_id, user_id)
+	);
+	CREATE TABLE IF NOT EXISTS categories (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		parent_id TEXT REFERENCES categories(id),
+		path TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+	-- text_pattern_ops позволяет использовать индекс для выбора поддерева по префиксу пути через LIKE
+	CREATE INDEX IF NOT EXISTS idx_categories_path ON categories(path text_pattern_ops);
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS category_id TEXT REFERENCES categories(id);
+	CREATE INDEX IF NOT EXISTS idx_posts_category ON posts(category_id, created_at DESC, id DESC);
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug TEXT NOT NULL DEFAULT '';
+	CREATE TABLE IF NOT EXISTS post_slugs (
+		slug TEXT PRIMARY KEY,
+		post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_post_slugs_post_id ON post_slugs(post_id);
+	-- Посты, созданные до появления slug, получают slug, равный ID
+	INSERT INTO post_slugs (slug, post_id, created_at)
+	SELECT id, id, created_at FROM posts WHERE slug = ''
+	ON CONFLICT (slug) DO NOTHING;
+	UPDATE posts SET slug = id WHERE slug = '';
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS idx_posts_title_trgm ON posts USING GIN (title gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_posts_tags ON posts USING GIN (tags);
+	CREATE TABLE IF NOT EXISTS thread_reads (
+		user_id TEXT NOT NULL,
+		post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		read_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (user_id, post_id)
+	);
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id TEXT PRIMARY KEY,
+		email_on_reply BOOLEAN NOT NULL,
+		email_on_mention BOOLEAN NOT NULL,
+		default_comment_sort TEXT NOT NULL,
+		locale TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS email TEXT NOT NULL DEFAULT '';
+	ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS digest_frequency TEXT NOT NULL DEFAULT 'DAILY';
+	CREATE TABLE IF NOT EXISTS post_subscriptions (
+		user_id TEXT NOT NULL,
+		post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (user_id, post_id)
+	);
+	CREATE TABLE IF NOT EXISTS digest_deliveries (
+		user_id TEXT PRIMARY KEY,
+		sent_until TIMESTAMP NOT NULL
+	);
+	ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS push_on_reply BOOLEAN NOT NULL DEFAULT TRUE;
+	ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS push_on_mention BOOLEAN NOT NULL DEFAULT TRUE;
+	CREATE TABLE IF NOT EXISTS device_tokens (
+		token TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		platform TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_device_tokens_user_id ON device_tokens(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at DESC, id DESC);
+	CREATE INDEX IF NOT EXISTS idx_posts_author ON posts(author_id, created_at DESC, id DESC);
+	CREATE INDEX IF NOT EXISTS idx_posts_content_trgm ON posts USING GIN (content gin_trgm_ops);
+	CREATE TABLE IF NOT EXISTS saved_searches (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		filter JSONB NOT NULL,
+		alert BOOLEAN NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		checked_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_saved_searches_user_id ON saved_searches(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_saved_searches_alert ON saved_searches(created_at) WHERE alert;
+	CREATE TABLE IF NOT EXISTS post_references (
+		source_id TEXT NOT NULL REFERENCES posts(id),
+		target_id TEXT NOT NULL REFERENCES posts(id),
+		PRIMARY KEY (source_id, target_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_post_references_target ON post_references(target_id);
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT '';
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_posts_language ON posts(language, created_at DESC);
+	-- Архив старых комментариев: строки переносятся из comments фоновой задачей и только читаются
+	CREATE TABLE IF NOT EXISTS comments_archive (
+		id TEXT PRIMARY KEY,
+		post_id TEXT REFERENCES posts(id),
+		parent_id TEXT,
+		author_id TEXT NOT NULL,
+		content TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		format TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		hidden BOOLEAN NOT NULL,
+		tags TEXT[] NOT NULL,
+		spam_status TEXT NOT NULL,
+		upvotes INTEGER NOT NULL,
+		downvotes INTEGER NOT NULL,
+		best_score DOUBLE PRECISION NOT NULL,
+		language TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_comments_archive_thread ON comments_archive(post_id, parent_id, created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_comments_archive_best ON comments_archive(post_id, best_score DESC, created_at DESC, id DESC);
+	CREATE INDEX IF NOT EXISTS idx_comments_archive_author ON comments_archive(author_id, id);
+	CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments(created_at, id);
+	-- Цитируемый комментарий того же поста; без внешнего ключа, так как он мог уйти в архив
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS quoted_comment_id TEXT;
+	ALTER TABLE comments_archive ADD COLUMN IF NOT EXISTS quoted_comment_id TEXT;
+	-- Премодерация: посты, комментарии к которым ждут решения модератора, и статус решения по комментарию
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS require_approval BOOLEAN NOT NULL DEFAULT FALSE;
+	-- Анонс поста для списков; у постов, созданных раньше, пустой
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS excerpt TEXT NOT NULL DEFAULT '';
+	ALTER TABLE comments ADD COLUMN IF NOT EXISTS approval_status TEXT NOT NULL DEFAULT '';
+	ALTER TABLE comments_archive ADD COLUMN IF NOT EXISTS approval_status TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_comments_pending ON comments(post_id, created_at, id) WHERE approval_status = 'PENDING';
+	-- comments_all объединяет рабочую таблицу и архив для чтения; условия запроса планировщик
+	-- переносит в обе части, поэтому используются индексы каждой таблицы
+	CREATE OR REPLACE VIEW comments_all AS
+		SELECT id, post_id, parent_id, author_id, content, content_hash, format, created_at, hidden, tags, spam_status, upvotes, downvotes, best_score, language, quoted_comment_id, approval_status
+		FROM comments
+		UNION ALL
+		SELECT id, post_id, parent_id, author_id, content, content_hash, format, created_at, hidden, tags, spam_status, upvotes, downvotes, best_score, language, quoted_comment_id, approval_status
+		FROM comments_archive;
+	-- create_comment_partitions создаёт помесячные секции секционированной таблицы comments с from_time по to_time.
+	-- Строки месяца, уже попавшие в секцию по умолчанию, переносятся в новую секцию до её подключения
+	CREATE OR REPLACE FUNCTION create_comment_partitions(from_time TIMESTAMP, to_time TIMESTAMP) RETURNS INTEGER AS $$
+	DECLARE
+		month_start TIMESTAMP := date_trunc('month', from_time);
+		partition_name TEXT;
+		created INTEGER := 0;
+	BEGIN
+		WHILE month_start <= to_time LOOP
+			partition_name := 'comments_' || to_char(month_start, 'YYYY_MM');
+			IF to_regclass(partition_name) IS NULL THEN
+				EXECUTE format('CREATE TABLE %I (LIKE comments INCLUDING DEFAULTS)', partition_name);
+				PERFORM set_config('system.moving_comments', 'on', true);
+				EXECUTE format('WITH moved AS (DELETE FROM comments_default WHERE created_at >= %L AND created_at < %L RETURNING *) INSERT INTO %I SELECT * FROM moved',
+					month_start, month_start + INTERVAL '1 month', partition_name);
+				PERFORM set_config('system.moving_comments', '', true);
+				EXECUTE format('ALTER TABLE comments ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+					partition_name, month_start, month_start + INTERVAL '1 month');
+				created := created + 1;
+			END IF;
+			month_start := month_start + INTERVAL '1 month';
+		END LOOP;
+		RETURN created;
+	END;
+	$$ LANGUAGE plpgsql;
+	CREATE TABLE IF NOT EXISTS tenant_settings (
+		tenant_id TEXT PRIMARY KEY,
+		default_comment_sort TEXT NOT NULL,
+		max_nesting INT NOT NULL,
+		anonymous_policy TEXT NOT NULL,
+		site_name TEXT NOT NULL,
+		tagline TEXT NOT NULL,
+		logo_url TEXT NOT NULL,
+		primary_color TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS tenant_usage (
+		tenant_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		items BIGINT NOT NULL,
+		bytes BIGINT NOT NULL,
+		PRIMARY KEY (tenant_id, kind)
+	);
+	ALTER TABLE posts ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'PUBLIC';
+	CREATE INDEX IF NOT EXISTS idx_posts_visibility ON posts(visibility, created_at DESC, id DESC) WHERE NOT hidden;
+	CREATE TABLE IF NOT EXISTS follows (
+		follower_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (follower_id, user_id)
+	);
+	CREATE TABLE IF NOT EXISTS collections (
+		id TEXT PRIMARY KEY,
+		author_id TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS collection_posts (
+		collection_id TEXT NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+		post_id TEXT NOT NULL REFERENCES posts(id),
+		position INT NOT NULL,
+		PRIMARY KEY (collection_id, post_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_collection_posts_post_id ON collection_posts(post_id);
+	-- Номера коротких ссылок на посты и комментарии из общей последовательности
+	CREATE TABLE IF NOT EXISTS short_ids (
+		seq BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+		kind TEXT NOT NULL,
+		target_id TEXT NOT NULL,
+		UNIQUE (kind, target_id)
+	);
+	-- Последняя оценка токсичности комментария; без внешнего ключа, так как комментарий мог уйти в архив
+	CREATE TABLE IF NOT EXISTS comment_toxicity (
+		comment_id TEXT PRIMARY KEY,
+		score DOUBLE PRECISION NOT NULL,
+		scored_at TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS allowed_operations (
+		hash TEXT PRIMARY KEY,
+		query TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT LOCALTIMESTAMP
+	);
+`
+
+// partitionCommentsDDL заменяет обычную таблицу comments секционированной по created_at: создаёт секции
+// для месяцев с уже записанными комментариями и копирует их. Первичный ключ секционированной таблицы
+// включает created_at, поэтому внешний ключ comment_votes на comments(id) удаляется. Индексы, триггер
+// счётчиков и comments_all после замены создаёт schemaDDL, выполняемый следом
+const partitionCommentsDDL = `
+	DROP VIEW IF EXISTS comments_all;
+	ALTER TABLE comment_votes DROP CONSTRAINT IF EXISTS comment_votes_comment_id_fkey;
+	ALTER TABLE comments RENAME TO comments_unpartitioned;
+	CREATE TABLE comments (
+		LIKE comments_unpartitioned INCLUDING DEFAULTS,
+		PRIMARY KEY (id, created_at),
+		FOREIGN KEY (post_id) REFERENCES posts(id)
+	) PARTITION BY RANGE (created_at);
+	CREATE TABLE comments_default PARTITION OF comments DEFAULT;
+	SELECT create_comment_partitions(COALESCE((SELECT MIN(created_at) FROM comments_unpartitioned), LOCALTIMESTAMP), LOCALTIMESTAMP);
+	INSERT INTO comments SELECT * FROM comments_unpartitioned;
+	DROP TABLE comments_unpartitioned;
+`
+
+// expectedSchema - таблицы и колонки, без которых хранилище не может работать
+var expectedSchema = map[string][]string{
+	"posts":               {"id", "title", "content", "format", "author_id", "allow_comments", "hidden", "tags", "category_id", "slug", "language", "visibility", "require_approval", "excerpt", "created_at"},
+	"comments":            {"id", "post_id", "parent_id", "author_id", "content", "content_hash", "format", "hidden", "tags", "spam_status", "upvotes", "downvotes", "best_score", "language", "quoted_comment_id", "approval_status", "created_at"},
+	"link_previews":       {"post_id", "url", "title", "description", "image_url", "site_name", "fetched_at"},
+	"reactions":           {"target_id", "user_id", "emoji", "created_at"},
+	"post_comment_counts": {"post_id", "parent_id", "comment_count"},
+	"quota_usage":         {"user_id", "action", "window_start", "used"},
+	"shadow_bans":         {"user_id", "created_at"},
+	"moderation_rules":    {"id", "kind", "pattern", "action", "tag", "created_by", "created_at"},
+	"held_content":        {"id", "target_type", "target_id", "rule_id", "created_at"},
+	"comment_votes":       {"comment_id", "user_id", "value", "created_at"},
+	"saved_searches":      {"id", "user_id", "name", "filter", "alert", "created_at", "checked_at"},
+	"categories":          {"id", "name", "parent_id", "path", "created_at"},
+	"post_slugs":          {"slug", "post_id", "created_at"},
+	"thread_reads":        {"user_id", "post_id", "read_at"},
+	"user_preferences":    {"user_id", "email_on_reply", "email_on_mention", "default_comment_sort", "locale", "push_on_reply", "push_on_mention", "email", "digest_frequency", "updated_at"},
+	"device_tokens":       {"token", "user_id", "platform", "created_at"},
+	"post_subscriptions":  {"user_id", "post_id", "created_at"},
+	"digest_deliveries":   {"user_id", "sent_until"},
+	"post_references":     {"source_id", "target_id"},
+	"tenant_settings":     {"tenant_id", "default_comment_sort", "max_nesting", "anonymous_policy", "site_name", "tagline", "logo_url", "primary_color", "updated_at"},
+	"tenant_usage":        {"tenant_id", "kind", "items", "bytes"},
+	"follows":             {"follower_id", "user_id", "created_at"},
+	"short_ids":           {"seq", "kind", "target_id"},
+	"collections":         {"id", "author_id", "title", "description", "created_at", "updated_at"},
+	"collection_posts":    {"collection_id", "post_id", "position"},
+	"comment_toxicity":    {"comment_id", "score", "scored_at"},
+	"allowed_operations":  {"hash", "query", "created_at"},
+	"comments_archive":    {"id", "post_id", "parent_id", "author_id", "content", "content_hash", "format", "hidden", "tags", "spam_status", "upvotes", "downvotes", "best_score", "language", "quoted_comment_id", "approval_status", "created_at"},
+	"comments_all":        {"id", "post_id", "parent_id", "author_id", "content", "content_hash", "format", "hidden", "tags", "spam_status", "upvotes", "downvotes", "best_score", "language", "quoted_comment_id", "approval_status", "created_at"},
+}
+
+// Migrate создаёт недостающие таблицы, колонки и индексы, а при включённом секционировании
+// заменяет comments секционированной таблицей
+func (s *PostgresStorage) Migrate(ctx context.Context) error {
+	log.Println("Создание таблиц")
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	if _, err := s.pool.Exec(ctx, schemaDDL); err != nil {
+		log.Printf("Ошибка создания таблиц: %v", err)
+		return fmt.Errorf("failed to create tables: %v", err)
+	}
+	if s.partitionComments {
+		partitioned, err := s.commentsPartitioned(ctx)
+		if err != nil {
+			return err
+		}
+		if !partitioned {
+			if err := s.partitionCommentsTable(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.backfillShortIDs(ctx); err != nil {
+		return err
+	}
+	log.Println("Таблицы успешно созданы или уже существуют")
+	return nil
+}
+
+// backfillShortIDs выдаёт номера коротких ссылок постам и комментариям, созданным до того, как номера
+// стали выдаваться при создании, в порядке создания
+func (s *PostgresStorage) backfillShortIDs(ctx context.Context) error {
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO short_ids (kind, target_id)
+		SELECT kind, id FROM (
+			SELECT $1::TEXT AS kind, id, created_at FROM posts
+			UNION ALL
+			SELECT $2::TEXT, id, created_at FROM comments_all
+		) t
+		WHERE NOT EXISTS (SELECT 1 FROM short_ids s WHERE s.kind = t.kind AND s.target_id = t.id)
+		ORDER BY created_at, id
+		ON CONFLICT (kind, target_id) DO NOTHING`, models.TargetPost, models.TargetComment)
+	if err != nil {
+		log.Printf("Ошибка выдачи коротких ссылок существующим записям: %v", err)
+		return fmt.Errorf("failed to backfill short ids: %v", err)
+	}
+	if tag.RowsAffected() > 0 {
+		log.Printf("Выданы короткие ссылки существующим записям: %d", tag.RowsAffected())
+	}
+	return nil
+}
+
+// commentsPartitioned проверяет, секционирована ли таблица comments
+func (s *PostgresStorage) commentsPartitioned(ctx context.Context) (bool, error) {
+	var partitioned bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass('comments'))`).Scan(&partitioned)
+	if err != nil {
+		log.Printf("Ошибка проверки секционирования comments: %v", err)
+		return false, fmt.Errorf("failed to check comments partitioning: %v", err)
+	}
+	return partitioned, nil
+}
+
+// partitionCommentsTable переносит комментарии в секционированную таблицу. Копирование блокирует comments
+// на всё время переноса, поэтому на больших таблицах его стоит выполнять в окно обслуживания
+func (s *PostgresStorage) partitionCommentsTable(ctx context.Context) error {
+	log.Println("Перевод таблицы comments на помесячные секции")
+	// Запросы выполняются одной неявной транзакцией: при ошибке остаётся прежняя таблица
+	if _, err := s.pool.Exec(ctx, partitionCommentsDDL+schemaDDL); err != nil {
+		log.Printf("Ошибка секционирования comments: %v", err)
+		return fmt.Errorf("failed to partition comments: %v", err)
+	}
+	log.Println("Таблица comments секционирована")
+	return nil
+}
+
+// CheckSchema проверяет наличие ожидаемых таблиц и колонок, не изменяя схему
+func (s *PostgresStorage) CheckSchema(ctx context.Context) error {
+	log.Println("Проверка схемы базы данных")
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema()`)
+	if err != nil {
+		log.Printf("Ошибка чтения схемы: %v", err)
+		return fmt.Errorf("failed to read schema: %v", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return fmt.Errorf("failed to scan schema: %v", err)
+		}
+		if existing[table] == nil {
+			existing[table] = make(map[string]bool)
+		}
+		existing[table][column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read schema: %v", err)
+	}
+
+	if missing := missingSchema(existing); len(missing) > 0 {
+		log.Printf("Схема базы данных неполная: %s", strings.Join(missing, ", "))
+		return fmt.Errorf("database schema is incomplete, missing: %s; apply migrations with a privileged role or start without skipMigrations", strings.Join(missing, ", "))
+	}
+	log.Println("Схема базы данных соответствует ожидаемой")
+	return nil
+}
+
+// missingSchema возвращает отсортированный список отсутствующих таблиц и колонок
+func missingSchema(existing map[string]map[string]bool) []string {
+	var missing []string
+	for table, columns := range expectedSchema {
+		if existing[table] == nil {
+			missing = append(missing, "table "+table)
+			continue
+		}
+		for _, column := range columns {
+			if !existing[table][column] {
+				missing = append(missing, "column "+table+"."+column)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
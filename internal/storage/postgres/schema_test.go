@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingSchema(t *testing.T) {
+	existing := make(map[string]map[string]bool)
+	for table, columns := range expectedSchema {
+		existing[table] = make(map[string]bool)
+		for _, column := range columns {
+			existing[table][column] = true
+		}
+	}
+	assert.Empty(t, missingSchema(existing), "Полная схема не должна содержать пропусков")
+
+	delete(existing, "reactions")
+	delete(existing["posts"], "format")
+	assert.Equal(t, []string{"column posts.format", "table reactions"}, missingSchema(existing))
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// Stats возвращает оценку количества строк, размер данных и индексов таблиц текущей схемы
+// из pg_stat_user_tables, крупные таблицы первыми. Самые медленные запросы берутся из pg_stat_statements;
+// без расширения или прав на его чтение список запросов пуст, а SlowQueriesAvailable равно false
+func (s *PostgresStorage) Stats(ctx context.Context, slowQueries int) (*models.StorageStats, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rows, err := s.pool.Query(ctx, `
+		SELECT relname, n_live_tup, pg_table_size(relid), pg_indexes_size(relid)
+		FROM pg_stat_user_tables
+		WHERE schemaname = current_schema()
+		ORDER BY pg_total_relation_size(relid) DESC, relname`)
+	if err != nil {
+		observeTimeout("Stats", err)
+		log.Printf("Ошибка при получении статистики таблиц: %v", err)
+		return nil, fmt.Errorf("failed to get table stats: %v", err)
+	}
+	stats := &models.StorageStats{Backend: "postgres"}
+	for rows.Next() {
+		var table models.TableStats
+		if err := rows.Scan(&table.Name, &table.Rows, &table.DataBytes, &table.IndexBytes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table stats: %v", err)
+		}
+		stats.Tables = append(stats.Tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		observeTimeout("Stats", err)
+		return nil, fmt.Errorf("failed to get table stats: %v", err)
+	}
+
+	if slowQueries <= 0 {
+		return stats, nil
+	}
+	var installed bool
+	if err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')`).Scan(&installed); err != nil {
+		observeTimeout("Stats", err)
+		return nil, fmt.Errorf("failed to check pg_stat_statements: %v", err)
+	}
+	if !installed {
+		return stats, nil
+	}
+	queries, err := s.slowQueries(ctx, slowQueries)
+	if err != nil {
+		// Расширение установлено, но не загружено через shared_preload_libraries или недоступно пользователю
+		log.Printf("Статистика запросов pg_stat_statements недоступна: %v", err)
+		return stats, nil
+	}
+	stats.SlowQueriesAvailable = true
+	stats.SlowQueries = queries
+	return stats, nil
+}
+
+// slowQueries возвращает до limit запросов текущей базы с наибольшим средним временем выполнения
+func (s *PostgresStorage) slowQueries(ctx context.Context, limit int) ([]models.QueryStats, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT query, calls, rows, total_exec_time, mean_exec_time
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY mean_exec_time DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var queries []models.QueryStats
+	for rows.Next() {
+		var query models.QueryStats
+		var totalMs, meanMs float64
+		if err := rows.Scan(&query.Query, &query.Calls, &query.Rows, &totalMs, &meanMs); err != nil {
+			return nil, err
+		}
+		query.TotalTime = time.Duration(totalMs * float64(time.Millisecond))
+		query.MeanTime = time.Duration(meanMs * float64(time.Millisecond))
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// queryCanceledCode - SQLSTATE ошибки, возвращаемой при срабатывании statement_timeout
+const queryCanceledCode = "57014"
+
+// withTimeout ограничивает контекст запроса значением QueryTimeout.
+// Если у входящего контекста дедлайн раньше, он остаётся в силе.
+func (s *PostgresStorage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// isTimeout сообщает, прерван ли запрос по дедлайну контекста или по statement_timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode
+}
+
+// observeTimeout учитывает превышение таймаута операцией в метриках
+func observeTimeout(operation string, err error) {
+	if !isTimeout(err) {
+		return
+	}
+	log.Printf("Превышен таймаут запроса к PostgreSQL в %s: %v", operation, err)
+	metrics.DBQueryTimeouts.WithLabelValues(operation).Inc()
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeouts(t *testing.T) {
+	t.Run("isTimeout", func(t *testing.T) {
+		assert.True(t, isTimeout(context.DeadlineExceeded))
+		assert.True(t, isTimeout(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+		assert.True(t, isTimeout(&pgconn.PgError{Code: queryCanceledCode}))
+		assert.False(t, isTimeout(&pgconn.PgError{Code: "23505"}))
+		assert.False(t, isTimeout(errors.New("connection refused")))
+	})
+
+	t.Run("withTimeout", func(t *testing.T) {
+		s := &PostgresStorage{queryTimeout: time.Second}
+		ctx, cancel := s.withTimeout(context.Background())
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok, "Контекст запроса должен иметь дедлайн")
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+
+		s = &PostgresStorage{}
+		ctx, cancel = s.withTimeout(context.Background())
+		defer cancel()
+		_, ok = ctx.Deadline()
+		assert.False(t, ok, "Без QueryTimeout дедлайн не задаётся")
+	})
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName - имя трейсера OpenTelemetry для запросов к PostgreSQL
+const tracerName = "github.com/ButyrinIA/system/internal/storage/postgres"
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql  string
+	args []any
+	at   time.Time
+}
+
+// queryTracer реализует pgx.QueryTracer: пишет запросы в лог, открывает span на каждый запрос
+// и отдельно помечает запросы дольше SlowQueryThreshold
+type queryTracer struct {
+	tracer        trace.Tracer
+	slowThreshold time.Duration
+	logParams     bool
+}
+
+var _ pgx.QueryTracer = &queryTracer{}
+
+func newQueryTracer(opts Options) *queryTracer {
+	return &queryTracer{
+		tracer:        otel.Tracer(tracerName),
+		slowThreshold: opts.SlowQueryThreshold,
+		logParams:     opts.LogQueryParams,
+	}
+}
+
+// TraceQueryStart открывает span запроса и запоминает время начала
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = t.tracer.Start(ctx, "postgres.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", compactSQL(data.SQL)),
+		))
+	return context.WithValue(ctx, queryStartKey{}, &queryStart{sql: data.SQL, args: data.Args, at: time.Now()})
+}
+
+// TraceQueryEnd закрывает span и пишет запрос в лог с длительностью
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	start, ok := ctx.Value(queryStartKey{}).(*queryStart)
+	if !ok {
+		return
+	}
+	duration := time.Since(start.at)
+	slow := t.slowThreshold > 0 && duration >= t.slowThreshold
+	span.SetAttributes(
+		attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()),
+		attribute.Bool("db.slow", slow),
+	)
+
+	sql := compactSQL(start.sql)
+	params := t.formatParams(start.args)
+	switch {
+	case data.Err != nil:
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		log.Printf("SQL ошибка за %v: %s %s: %v", duration, sql, params, data.Err)
+	case slow:
+		log.Printf("МЕДЛЕННЫЙ SQL (%v, порог %v): %s %s", duration, t.slowThreshold, sql, params)
+	default:
+		log.Printf("SQL за %v: %s %s", duration, sql, params)
+	}
+}
+
+// formatParams возвращает параметры запроса для лога; без LogQueryParams значения скрываются
+func (t *queryTracer) formatParams(args []any) string {
+	if len(args) == 0 {
+		return "[]"
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if t.logParams {
+			parts[i] = fmt.Sprintf("$%d=%v", i+1, arg)
+		} else {
+			parts[i] = fmt.Sprintf("$%d=<redacted %T>", i+1, arg)
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// compactSQL схлопывает пробелы и переводы строк в тексте запроса для однострочного лога
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryTracer(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	trace := func(tracer *queryTracer, sleep time.Duration, err error) string {
+		buf.Reset()
+		ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+			SQL:  "SELECT *\n\t\tFROM posts\n\t\tWHERE id=$1",
+			Args: []any{"secret-id"},
+		})
+		time.Sleep(sleep)
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1"), Err: err})
+		return buf.String()
+	}
+
+	t.Run("Parameters redacted by default", func(t *testing.T) {
+		out := trace(newQueryTracer(Options{}), 0, nil)
+		assert.Contains(t, out, "SELECT * FROM posts WHERE id=$1")
+		assert.Contains(t, out, "$1=<redacted string>")
+		assert.NotContains(t, out, "secret-id")
+	})
+
+	t.Run("Parameters logged when enabled", func(t *testing.T) {
+		out := trace(newQueryTracer(Options{LogQueryParams: true}), 0, nil)
+		assert.Contains(t, out, "$1=secret-id")
+	})
+
+	t.Run("Slow query highlighted", func(t *testing.T) {
+		out := trace(newQueryTracer(Options{SlowQueryThreshold: time.Millisecond}), 5*time.Millisecond, nil)
+		assert.Contains(t, out, "МЕДЛЕННЫЙ SQL")
+	})
+
+	t.Run("Error logged", func(t *testing.T) {
+		out := trace(newQueryTracer(Options{}), 0, errors.New("boom"))
+		assert.Contains(t, out, "SQL ошибка")
+		assert.Contains(t, out, "boom")
+	})
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// Веса признаков похожести постов в RelatedPosts. Бэкенды должны считать оценку одинаково
+const (
+	// RelatedTagWeight - вес каждого общего тега
+	RelatedTagWeight = 1.0
+	// RelatedCategoryWeight - вес совпадения категории
+	RelatedCategoryWeight = 1.0
+	// RelatedTextWeight - вес сходства заголовков по триграммам (от 0 до 1)
+	RelatedTextWeight = 2.0
+	// RelatedSimilarityThreshold - минимальное сходство заголовков, при котором пост считается похожим
+	// без общих тегов и категории; совпадает с порогом pg_trgm.similarity_threshold по умолчанию
+	RelatedSimilarityThreshold = 0.3
+)
+
+// RelatedScore возвращает оценку похожести candidate на post и сообщает, считается ли он похожим вообще
+func RelatedScore(post, candidate *models.Post) (float64, bool) {
+	shared := 0
+	for _, tag := range candidate.Tags {
+		for _, own := range post.Tags {
+			if tag == own {
+				shared++
+				break
+			}
+		}
+	}
+	sameCategory := post.CategoryID != nil && candidate.CategoryID != nil && *post.CategoryID == *candidate.CategoryID
+	similarity := TrigramSimilarity(post.Title, candidate.Title)
+	if shared == 0 && !sameCategory && similarity < RelatedSimilarityThreshold {
+		return 0, false
+	}
+	score := float64(shared)*RelatedTagWeight + similarity*RelatedTextWeight
+	if sameCategory {
+		score += RelatedCategoryWeight
+	}
+	return score, true
+}
+
+// SimilarPost - пост с заголовком, похожим на проверяемый
+type SimilarPost struct {
+	Post *models.Post
+	// Similarity - сходство заголовков по TrigramSimilarity, от 0 до 1
+	Similarity float64
+}
+
+// TrigramSimilarity повторяет similarity из pg_trgm: доля общих триграмм слов обеих строк.
+// Слова приводятся к нижнему регистру и дополняются пробелами: два в начале и один в конце
+func TrigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// trigrams возвращает множество триграмм строки по правилам pg_trgm
+func trigrams(s string) map[string]bool {
+	result := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			result[string(runes[i:i+3])] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrigramSimilarity(t *testing.T) {
+	// Значения совпадают с SELECT similarity(...) в PostgreSQL с pg_trgm
+	assert.InDelta(t, 1.0, TrigramSimilarity("Word", "word"), 1e-9)
+	assert.InDelta(t, 0.571429, TrigramSimilarity("word", "words"), 1e-6)
+	assert.InDelta(t, 0.363636, TrigramSimilarity("word", "two words"), 1e-6)
+	assert.Zero(t, TrigramSimilarity("кот", "пёс"))
+	assert.Zero(t, TrigramSimilarity("", "пёс"))
+}
+
+func TestRelatedScore(t *testing.T) {
+	science := "science"
+	post := &models.Post{Title: "Запуск ракеты", Tags: []string{"космос", "новости"}, CategoryID: &science}
+
+	_, ok := RelatedScore(post, &models.Post{Title: "Пирог"})
+	assert.False(t, ok)
+
+	tagged, ok := RelatedScore(post, &models.Post{Title: "Пирог", Tags: []string{"новости"}})
+	assert.True(t, ok)
+	assert.InDelta(t, RelatedTagWeight, tagged, 1e-9)
+
+	both, ok := RelatedScore(post, &models.Post{Title: "Пирог", Tags: []string{"новости", "космос"}, CategoryID: &science})
+	assert.True(t, ok)
+	assert.InDelta(t, 2*RelatedTagWeight+RelatedCategoryWeight, both, 1e-9)
+
+	similar, ok := RelatedScore(post, &models.Post{Title: "Запуск ракеты перенесён"})
+	assert.True(t, ok, "Похожий заголовок достаточен без тегов и категории")
+	assert.Greater(t, similar, 0.0)
+}
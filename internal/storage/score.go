@@ -0,0 +1,19 @@
+package storage
+
+import "math"
+
+// wilsonZ - квантиль нормального распределения для 95% доверительного интервала
+const wilsonZ = 1.96
+
+// WilsonScore возвращает нижнюю границу доверительного интервала Уилсона для доли голосов «за».
+// В отличие от разности голосов оценка учитывает их количество: 10 «за» из 10 выше, чем 1 из 1,
+// а комментарий без голосов получает 0
+func WilsonScore(upvotes, downvotes int) float64 {
+	n := float64(upvotes + downvotes)
+	if n == 0 {
+		return 0
+	}
+	p := float64(upvotes) / n
+	z2 := wilsonZ * wilsonZ
+	return (p + z2/(2*n) - wilsonZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
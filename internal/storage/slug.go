@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SlugHasBase сообщает, построен ли slug из base: совпадает с ним или отличается суффиксом -N
+func SlugHasBase(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok || suffix == "" || suffix[0] == '0' {
+		return false
+	}
+	for _, r := range suffix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// UniqueSlug возвращает base или, если он занят, base-N с наименьшим свободным N начиная с 2.
+// taken - занятые slug с основой base и ID их постов
+func UniqueSlug(base string, taken map[string]string) string {
+	if _, ok := taken[base]; !ok {
+		return base
+	}
+	for n := 2; ; n++ {
+		candidate := base + "-" + strconv.Itoa(n)
+		if _, ok := taken[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// RetitleSlug выбирает slug поста postID после смены заголовка на заголовок с основой base.
+// Если текущий slug построен из base, он сохраняется, чтобы правка опечатки не меняла адрес.
+// Иначе пост получает свой прежний slug с этой основой или новый свободный.
+// taken - занятые slug с основой base и ID их постов
+func RetitleSlug(postID, current, base string, taken map[string]string) string {
+	if SlugHasBase(current, base) {
+		return current
+	}
+	own := []string{}
+	for slug, owner := range taken {
+		if owner == postID && SlugHasBase(slug, base) {
+			own = append(own, slug)
+		}
+	}
+	if len(own) > 0 {
+		sort.Strings(own)
+		return own[0]
+	}
+	return UniqueSlug(base, taken)
+}
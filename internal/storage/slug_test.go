@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugHasBase(t *testing.T) {
+	assert.True(t, SlugHasBase("hello", "hello"))
+	assert.True(t, SlugHasBase("hello-3", "hello"))
+	assert.False(t, SlugHasBase("hello-world", "hello"))
+	assert.False(t, SlugHasBase("hello-03", "hello"))
+	assert.False(t, SlugHasBase("hello-", "hello"))
+	assert.False(t, SlugHasBase("hell", "hello"))
+}
+
+func TestUniqueSlug(t *testing.T) {
+	assert.Equal(t, "hello", UniqueSlug("hello", nil))
+	assert.Equal(t, "hello-2", UniqueSlug("hello", map[string]string{"hello": "p1"}))
+	assert.Equal(t, "hello-3", UniqueSlug("hello", map[string]string{"hello": "p1", "hello-2": "p2", "hello-4": "p4"}))
+}
+
+func TestRetitleSlug(t *testing.T) {
+	taken := map[string]string{"hello": "p1", "hello-2": "p2", "world": "p2"}
+	assert.Equal(t, "hello-2", RetitleSlug("p2", "hello-2", "hello", taken), "Основа не изменилась - slug сохраняется")
+	assert.Equal(t, "hello-2", RetitleSlug("p2", "world", "hello", taken), "Возвращается прежний slug поста")
+	assert.Equal(t, "hello-3", RetitleSlug("p3", "other", "hello", taken))
+	assert.Equal(t, "fresh", RetitleSlug("p1", "hello", "fresh", taken))
+}
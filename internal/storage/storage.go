@@ -2,15 +2,242 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/ButyrinIA/system/internal/models"
 )
 
+// ErrPostNotFound возвращается, если пост с указанным ID не существует
+var ErrPostNotFound = errors.New("post not found")
+
+// ErrCommentNotFound возвращается, если комментарий с указанным ID не существует
+var ErrCommentNotFound = errors.New("comment not found")
+
+// ErrCommentArchived возвращается при изменении комментария, перенесённого в архив: архив доступен только для чтения
+var ErrCommentArchived = errors.New("comment is archived")
+
+// ErrCategoryNotFound возвращается, если категория с указанным ID не существует
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrRuleNotFound возвращается, если правило автомодерации с указанным ID не существует
+var ErrRuleNotFound = errors.New("moderation rule not found")
+
+// ErrSavedSearchNotFound возвращается, если у пользователя нет сохранённого поиска с указанным ID
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// ErrHeldItemNotFound возвращается, если в очереди проверки нет записи с указанным ID
+var ErrHeldItemNotFound = errors.New("held item not found")
+
+// ErrShortIDNotFound возвращается, если короткая ссылка с указанным номером не выдавалась
+var ErrShortIDNotFound = errors.New("short id not found")
+
+// ErrCollectionNotFound возвращается, если серия постов с указанным ID не существует
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// ContentRecord - содержимое поста или комментария в том виде, в каком оно записано в хранилище
+type ContentRecord struct {
+	ID      string
+	Content string
+}
+
 type Storage interface {
+	// CreatePost сохраняет пост; для несуществующей категории возвращает ErrCategoryNotFound.
+	// Slug используется как основа адреса (пустой заменяется ID поста): занятый дополняется
+	// суффиксом -2, -3 и т.д., итоговый записывается в post.Slug
 	CreatePost(ctx context.Context, post *models.Post) error
 	GetPost(ctx context.Context, id string) (*models.Post, error)
-	ListPosts(ctx context.Context, limit int, cursor *string) (*models.PaginatedPosts, error)
+	// GetPostBySlug возвращает пост по текущему или прежнему slug; возвращает ErrPostNotFound
+	GetPostBySlug(ctx context.Context, slug string) (*models.Post, error)
+	// ShortIDs возвращает номера коротких ссылок на посты (kind models.TargetPost) или комментарии
+	// (models.TargetComment) с ID из ids. Номер выдаётся при создании поста или комментария из общей для них
+	// последовательности и больше не меняется; ShortIDs ничего не записывает, а сущностей без номера
+	// в результате нет
+	ShortIDs(ctx context.Context, kind string, ids []string) (map[string]int64, error)
+	// ResolveShortID возвращает вид и ID сущности по номеру короткой ссылки; возвращает ErrShortIDNotFound
+	ResolveShortID(ctx context.Context, seq int64) (kind, id string, err error)
+	// UpdatePostTitle меняет заголовок поста, выбирая slug по основе slugBase через RetitleSlug.
+	// Прежний slug остаётся за постом. Возвращает обновлённый пост или ErrPostNotFound
+	UpdatePostTitle(ctx context.Context, postID, title, slugBase string) (*models.Post, error)
+	// ListPosts возвращает страницу постов, отобранных filter; скрытые посты возвращаются только автору,
+	// заданному через WithViewer. Для несуществующей категории фильтра возвращает ErrCategoryNotFound
+	ListPosts(ctx context.Context, limit int, cursor *string, filter PostFilter) (*models.PaginatedPosts, error)
+	// RelatedPosts возвращает до limit опубликованных постов, похожих на пост postID, по убыванию RelatedScore,
+	// при равной оценке - сначала новые. Скрытые посты не возвращаются никому. Возвращает ErrPostNotFound
+	RelatedPosts(ctx context.Context, postID string, limit int) ([]*models.Post, error)
+	// SimilarPosts возвращает до limit постов с заголовком, похожим на title по триграммам не меньше threshold,
+	// по убыванию сходства, при равном - сначала новые. Возвращаются опубликованные посты PUBLIC и любые посты
+	// viewerID. threshold ниже RelatedSimilarityThreshold действует как RelatedSimilarityThreshold
+	SimilarPosts(ctx context.Context, title, viewerID string, threshold float64, limit int) ([]SimilarPost, error)
+	// SavePostReferences заменяет ссылки поста postID на другие посты списком targetIDs; ссылки читаются
+	// через PostFilter.ReferencedBy и PostFilter.References. Возвращает ErrPostNotFound, если какого-то поста нет
+	SavePostReferences(ctx context.Context, postID string, targetIDs []string) error
+	// SetPostCategory относит пост к категории или, при nil, убирает его из категории;
+	// возвращает ErrPostNotFound или ErrCategoryNotFound
+	SetPostCategory(ctx context.Context, postID string, categoryID *string) error
+	// SetPostRequireApproval включает или выключает премодерацию комментариев поста; уже созданные
+	// комментарии не меняются. Возвращает ErrPostNotFound
+	SetPostRequireApproval(ctx context.Context, postID string, required bool) error
+	// SetPostExcerpt заменяет анонс поста, например кратким содержанием от внешнего сервиса; возвращает ErrPostNotFound
+	SetPostExcerpt(ctx context.Context, postID, excerpt string) error
+	// CreateCategory создаёт категорию и заполняет её Path; для несуществующего родителя возвращает ErrCategoryNotFound
+	CreateCategory(ctx context.Context, category *models.Category) error
+	GetCategory(ctx context.Context, id string) (*models.Category, error)
+	// ListCategories возвращает все категории в порядке создания
+	ListCategories(ctx context.Context) ([]models.Category, error)
 	CreateComment(ctx context.Context, comment *models.Comment) error
-	GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string) (*models.PaginatedComments, error)
+	// CreateComments сохраняет пачку комментариев за одну запись в хранилище и возвращает ошибку для каждого
+	// комментария в том же порядке; ошибки отдельных комментариев такие же, как у CreateComment, и не мешают
+	// сохранить остальные
+	CreateComments(ctx context.Context, comments []*models.Comment) []error
+	GetComment(ctx context.Context, id string) (*models.Comment, error)
+	// GetCommentsByIDs возвращает комментарии, включая архивные и скрытые, по ID; неизвестных ID в результате нет
+	GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error)
+	// GetComments возвращает страницу комментариев; скрытые комментарии возвращаются
+	// и учитываются в TotalCount только для их автора, заданного через WithViewer
+	GetComments(ctx context.Context, postID string, parentID *string, limit int, cursor *string, order models.SortOrder) (*models.PaginatedComments, error)
+	// CountCommentsBefore возвращает число комментариев того же поста и родителя, которые идут перед комментарием
+	// commentID в порядке order. Скрытые комментарии учитываются только для их автора, как в GetComments.
+	// Для несуществующего комментария возвращает ErrCommentNotFound
+	CountCommentsBefore(ctx context.Context, commentID string, order models.SortOrder) (int, error)
+	// CountDescendants возвращает для каждого из commentIDs число видимых ответов на любой глубине.
+	// Ответы скрытых комментариев не учитываются, как и сами скрытые; для неизвестного ID - 0
+	CountDescendants(ctx context.Context, commentIDs []string) (map[string]int, error)
+	SaveLinkPreview(ctx context.Context, preview *models.LinkPreview) error
+	GetLinkPreviews(ctx context.Context, postID string) ([]models.LinkPreview, error)
+	ToggleReaction(ctx context.Context, reaction *models.Reaction) (bool, error)
+	GetReactionCounts(ctx context.Context, targetIDs []string) (map[string][]models.ReactionCount, error)
+	// VoteComment сохраняет голос пользователя за комментарий, заменяя его предыдущий голос; Value 0 снимает голос.
+	// Счётчики голосов обновляются на разницу без пересчёта всех голосов.
+	// Возвращает комментарий с новыми счётчиками, ErrCommentNotFound или ErrCommentArchived
+	VoteComment(ctx context.Context, vote *models.Vote) (*models.Comment, error)
+	// MarkThreadRead запоминает, что пользователь прочитал комментарии поста по состоянию на readAt.
+	// Более ранняя отметка не заменяет сохранённую. Возвращает ErrPostNotFound
+	MarkThreadRead(ctx context.Context, userID, postID string, readAt time.Time) error
+	// GetUnreadCommentCounts возвращает для каждого из postIDs число комментариев, созданных после отметки
+	// MarkThreadRead пользователя, а если отметки нет - всех. Свои и скрытые комментарии не учитываются
+	GetUnreadCommentCounts(ctx context.Context, userID string, postIDs []string) (map[string]int, error)
+	// IncrementQuotaUsage увеличивает счётчик действия пользователя в окне, начатом в windowStart,
+	// и возвращает новое значение. Счётчики предыдущих окон удаляются
+	IncrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) (int, error)
+	// DecrementQuotaUsage возвращает одно действие в счётчик окна, начатого в windowStart. Счётчик другого
+	// окна и нулевой счётчик не меняются
+	DecrementQuotaUsage(ctx context.Context, userID, action string, windowStart time.Time) error
+	// GetPreferences возвращает настройки пользователя или DefaultPreferences, если он их не сохранял
+	GetPreferences(ctx context.Context, userID string) (*models.Preferences, error)
+	// SavePreferences заменяет все настройки пользователя prefs.UserID
+	SavePreferences(ctx context.Context, prefs *models.Preferences) error
+	// GetTenantSettings возвращает настройки сообщества или DefaultTenantSettings, если их не сохраняли
+	GetTenantSettings(ctx context.Context, tenantID string) (*models.TenantSettings, error)
+	// SaveTenantSettings заменяет все настройки сообщества settings.TenantID
+	SaveTenantSettings(ctx context.Context, settings *models.TenantSettings) error
+	// AddTenantUsage учитывает в использовании сообщества одну запись kind (TargetPost или TargetComment)
+	// размером bytes
+	AddTenantUsage(ctx context.Context, tenantID, kind string, bytes int64) error
+	// GetTenantUsage возвращает использование сообщества; для сообщества без записей - нулевое
+	GetTenantUsage(ctx context.Context, tenantID string) (*models.TenantUsage, error)
+	// RegisterDeviceToken сохраняет устройство; токен, уже зарегистрированный другим пользователем, переходит к новому
+	RegisterDeviceToken(ctx context.Context, token *models.DeviceToken) error
+	// UnregisterDeviceToken удаляет устройство пользователя; чужой или неизвестный токен не считается ошибкой
+	UnregisterDeviceToken(ctx context.Context, userID, token string) error
+	// ListDeviceTokens возвращает устройства пользователя в порядке регистрации
+	ListDeviceTokens(ctx context.Context, userID string) ([]models.DeviceToken, error)
+	// SubscribeToPost подписывает пользователя на сводки новых комментариев поста; повторная подписка
+	// не меняет время исходной. Возвращает ErrPostNotFound
+	SubscribeToPost(ctx context.Context, userID, postID string, at time.Time) error
+	// UnsubscribeFromPost снимает подписку; отсутствие подписки не считается ошибкой
+	UnsubscribeFromPost(ctx context.Context, userID, postID string) error
+	// ListDigestSubscribers возвращает пользователей, подписанных хотя бы на один пост
+	ListDigestSubscribers(ctx context.Context) ([]models.DigestSubscriber, error)
+	// GetDigestComments возвращает до limit чужих видимых комментариев к постам, на которые подписан пользователь,
+	// созданных после since и после подписки, но не позже until, по постам и времени создания
+	GetDigestComments(ctx context.Context, userID string, since, until time.Time, limit int) ([]models.Comment, error)
+	// MarkDigestSent запоминает until последней отправленной сводки пользователя
+	MarkDigestSent(ctx context.Context, userID string, until time.Time) error
+	// CreateSavedSearch сохраняет поиск пользователя; CheckedAt задаётся вызывающим
+	CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error
+	// ListSavedSearches возвращает сохранённые поиски пользователя в порядке создания
+	ListSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error)
+	// DeleteSavedSearch удаляет поиск пользователя; чужой или неизвестный поиск - ErrSavedSearchNotFound
+	DeleteSavedSearch(ctx context.Context, userID, id string) error
+	// CreateCollection сохраняет серию постов; для несуществующего поста из PostIDs возвращает ErrPostNotFound
+	CreateCollection(ctx context.Context, collection *models.Collection) error
+	// GetCollection возвращает серию с постами в сохранённом порядке; возвращает ErrCollectionNotFound
+	GetCollection(ctx context.Context, id string) (*models.Collection, error)
+	// UpdateCollection заменяет название, описание, список постов и UpdatedAt серии collection.ID;
+	// возвращает ErrCollectionNotFound или ErrPostNotFound для несуществующего поста
+	UpdateCollection(ctx context.Context, collection *models.Collection) error
+	// DeleteCollection удаляет серию; сами посты не меняются. Возвращает ErrCollectionNotFound
+	DeleteCollection(ctx context.Context, id string) error
+	// ListPostCollections возвращает серии, в которые входит пост, в порядке создания
+	ListPostCollections(ctx context.Context, postID string) ([]models.Collection, error)
+	// ListAlertSavedSearches возвращает все поиски с включёнными уведомлениями в порядке создания
+	ListAlertSavedSearches(ctx context.Context) ([]models.SavedSearch, error)
+	// MarkSavedSearchChecked сдвигает CheckedAt поиска; более раннее время не заменяет сохранённое.
+	// Удалённый поиск не считается ошибкой
+	MarkSavedSearchChecked(ctx context.Context, id string, checkedAt time.Time) error
+	// SetShadowBan включает или снимает теневой бан пользователя
+	SetShadowBan(ctx context.Context, userID string, banned bool) error
+	IsShadowBanned(ctx context.Context, userID string) (bool, error)
+	// SetFollow подписывает пользователя followerID на автора userID (follow: true) или отменяет подписку.
+	// Подписчики автора видят его посты с видимостью FOLLOWERS
+	SetFollow(ctx context.Context, followerID, userID string, follow bool) error
+	IsFollowing(ctx context.Context, followerID, userID string) (bool, error)
+	// ListModerationRules возвращает правила автомодерации в порядке создания
+	ListModerationRules(ctx context.Context) ([]models.ModerationRule, error)
+	CreateModerationRule(ctx context.Context, rule *models.ModerationRule) error
+	// UpdateModerationRule заменяет тип, шаблон, действие и тег правила; возвращает ErrRuleNotFound
+	UpdateModerationRule(ctx context.Context, rule *models.ModerationRule) error
+	DeleteModerationRule(ctx context.Context, id string) error
+	// ListAllowedOperations возвращает документы операций, разрешённых в режиме allowlist, в порядке добавления
+	ListAllowedOperations(ctx context.Context) ([]string, error)
+	// AddAllowedOperation разрешает операцию с документом query; повторное добавление ничего не меняет
+	AddAllowedOperation(ctx context.Context, query string) error
+	// HoldContent ставит скрытый пост или комментарий в очередь проверки
+	HoldContent(ctx context.Context, item *models.HeldItem) error
+	// ListHeldContent возвращает до limit записей очереди проверки, начиная со старых
+	ListHeldContent(ctx context.Context, limit int) ([]models.HeldItem, error)
+	// ResolveHeldContent удаляет запись из очереди; при approve содержимое становится видимым всем.
+	// Отклонённое содержимое остаётся скрытым. Возвращает ErrHeldItemNotFound
+	ResolveHeldContent(ctx context.Context, id string, approve bool) error
+	// SetSpamStatus сохраняет результат проверки комментария на спам вместе с его видимостью;
+	// возвращает ErrCommentNotFound
+	SetSpamStatus(ctx context.Context, commentID, status string, hidden bool) error
+	// ListCommentsBySpamStatus возвращает до limit комментариев с указанным статусом, начиная с новых
+	ListCommentsBySpamStatus(ctx context.Context, status string, limit int) ([]models.Comment, error)
+	// SetToxicityScore сохраняет оценку токсичности комментария, заменяя прежнюю. Если hold не nil и комментарий
+	// ещё виден, он скрывается и ставится в очередь проверки записью hold. Возвращает ErrCommentNotFound
+	SetToxicityScore(ctx context.Context, score models.ToxicityScore, hold *models.HeldItem) error
+	// GetToxicityScores возвращает оценки токсичности комментариев по ID; непроверенных комментариев в результате нет
+	GetToxicityScores(ctx context.Context, commentIDs []string) (map[string]models.ToxicityScore, error)
+	// SetApprovalStatus сохраняет решение по комментарию с премодерацией вместе с его видимостью;
+	// возвращает ErrCommentNotFound
+	SetApprovalStatus(ctx context.Context, commentID, status string, hidden bool) error
+	// ListPendingComments возвращает до limit комментариев со статусом ApprovalPending, начиная со старых;
+	// пустой postID - комментарии всех постов
+	ListPendingComments(ctx context.Context, postID string, limit int) ([]models.Comment, error)
+	// IteratePosts передаёт fn все посты, включая скрытые и недоступные зрителям, по времени создания, при равном - по ID.
+	// Посты читаются потоком без публичной пагинации; первая ошибка fn прекращает обход и возвращается.
+	// Предназначен для фоновых задач вроде выгрузки, переиндексации и переноса данных
+	IteratePosts(ctx context.Context, fn func(post *models.Post) error) error
+	// IterateComments передаёт fn комментарии поста postID, а при пустом postID - комментарии всех постов,
+	// включая скрытые и архивные, в порядке IteratePosts. Для несуществующего поста fn не вызывается
+	IterateComments(ctx context.Context, postID string, fn func(comment *models.Comment) error) error
+	// ListContents возвращает до limit записей содержимого постов (kind models.TargetPost) или комментариев
+	// (models.TargetComment) с ID больше afterID по возрастанию ID, включая скрытые, в том виде, в каком оно сохранено
+	ListContents(ctx context.Context, kind, afterID string, limit int) ([]ContentRecord, error)
+	// ReplaceContent заменяет сохранённое содержимое поста или комментария на content, только если оно
+	// всё ещё равно previous. Возвращает false, если содержимое изменилось или записи нет
+	ReplaceContent(ctx context.Context, kind, id, previous, content string) (bool, error)
+	// AnonymizeUserContent обезличивает до limit постов (kind models.TargetPost) или комментариев (models.TargetComment)
+	// пользователя userID в порядке ID: автором становится models.DeletedUserID, текст и язык стираются.
+	// У постов стираются также заголовок и теги, slug заменяется ID поста, прежние slug, ссылки на другие посты
+	// и превью ссылок удаляются. Ветки обсуждений и чужие комментарии сохраняются.
+	// Возвращает ID обезличенных записей; меньше limit означает, что записей пользователя не осталось
+	AnonymizeUserContent(ctx context.Context, kind, userID string, limit int) ([]string, error)
+	// Stats возвращает количество строк и размер таблиц хранилища и до slowQueries запросов
+	// с наибольшим средним временем выполнения, если хранилище ведёт их статистику
+	Stats(ctx context.Context, slowQueries int) (*models.StorageStats, error)
+	Ping(ctx context.Context) error
 	Close() error
 }
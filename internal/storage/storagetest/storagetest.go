@@ -0,0 +1,1831 @@
+// Package storagetest содержит общий набор тестов, которому должна соответствовать
+// каждая реализация storage.Storage
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory возвращает пустое хранилище для очередного теста
+type Factory func(t *testing.T) storage.Storage
+
+// Run прогоняет набор проверок поведения против хранилища, создаваемого factory.
+// Время округляется до микросекунд в UTC, чтобы результаты не зависели от точности бэкенда
+func Run(t *testing.T, factory Factory) {
+	t.Run("GetPost not found", func(t *testing.T) {
+		store := factory(t)
+		_, err := store.GetPost(context.Background(), uuid.New().String())
+		assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	})
+
+	t.Run("GetComment not found", func(t *testing.T) {
+		store := factory(t)
+		_, err := store.GetComment(context.Background(), uuid.New().String())
+		assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+	})
+
+	t.Run("CreatePost and GetPost round trip", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		post.Format = models.FormatMarkdown
+		require.NoError(t, store.CreatePost(ctx, post))
+
+		got, err := store.GetPost(ctx, post.ID)
+		require.NoError(t, err)
+		assertPost(t, post, got)
+	})
+
+	t.Run("ListPosts empty", func(t *testing.T) {
+		store := factory(t)
+		page, err := store.ListPosts(context.Background(), 10, nil, storage.PostFilter{})
+		require.NoError(t, err)
+		assert.NotNil(t, page.Posts, "Пустая страница должна быть пустым срезом, а не nil")
+		assert.Empty(t, page.Posts)
+		assert.Zero(t, page.TotalCount)
+		assert.Nil(t, page.NextCursor)
+	})
+
+	t.Run("ListPosts order and cursors", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		base := baseTime()
+		// Два поста с одинаковым временем проверяют устойчивость курсора при равенстве createdAt
+		times := []time.Time{base, base.Add(time.Second), base.Add(time.Second), base.Add(2 * time.Second)}
+		var keys []key
+		for _, at := range times {
+			post := newPost(at)
+			require.NoError(t, store.CreatePost(ctx, post))
+			keys = append(keys, key{ID: post.ID, CreatedAt: post.CreatedAt})
+		}
+
+		var ids []string
+		var cursor *string
+		for page := 0; page < len(keys); page++ {
+			result, err := store.ListPosts(ctx, 2, cursor, storage.PostFilter{})
+			require.NoError(t, err)
+			assert.Equal(t, len(keys), result.TotalCount)
+			for _, p := range result.Posts {
+				ids = append(ids, p.ID)
+			}
+			cursor = result.NextCursor
+			if cursor == nil {
+				break
+			}
+		}
+		assert.Equal(t, expectedOrder(keys, models.SortDesc), ids)
+	})
+
+	t.Run("ListPosts invalid cursor", func(t *testing.T) {
+		store := factory(t)
+		bad := "not-a-cursor"
+		_, err := store.ListPosts(context.Background(), 10, &bad, storage.PostFilter{})
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	})
+
+	t.Run("CreateComment for missing post", func(t *testing.T) {
+		store := factory(t)
+		comment := newComment(uuid.New().String(), nil, baseTime())
+		err := store.CreateComment(context.Background(), comment)
+		assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	})
+
+	t.Run("CreateComment and GetComment round trip", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		parent := newComment(post.ID, nil, baseTime())
+		require.NoError(t, store.CreateComment(ctx, parent))
+		reply := newComment(post.ID, &parent.ID, baseTime().Add(time.Second))
+		require.NoError(t, store.CreateComment(ctx, reply))
+
+		got, err := store.GetComment(ctx, reply.ID)
+		require.NoError(t, err)
+		assertComment(t, reply, got)
+	})
+
+	t.Run("GetCommentsByIDs with quoted comment", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		quoted := newComment(post.ID, nil, baseTime())
+		require.NoError(t, store.CreateComment(ctx, quoted))
+		quoting := newComment(post.ID, nil, baseTime().Add(time.Second))
+		quoting.QuotedCommentID = &quoted.ID
+		require.NoError(t, store.CreateComment(ctx, quoting))
+
+		comments, err := store.GetCommentsByIDs(ctx, []string{quoted.ID, quoting.ID, uuid.New().String()})
+		require.NoError(t, err)
+		require.Len(t, comments, 2)
+		assertComment(t, quoted, comments[quoted.ID])
+		assertComment(t, quoting, comments[quoting.ID])
+		assert.Nil(t, comments[quoted.ID].QuotedCommentID)
+		assert.Equal(t, &quoted.ID, comments[quoting.ID].QuotedCommentID)
+
+		got, err := store.GetComment(ctx, quoting.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &quoted.ID, got.QuotedCommentID)
+	})
+
+	t.Run("GetComments empty", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		require.NoError(t, store.CreateComment(ctx, newComment(post.ID, nil, baseTime())))
+
+		missingParent := uuid.New().String()
+		cases := map[string]struct {
+			postID   string
+			parentID *string
+		}{
+			"post without comments": {postID: uuid.New().String()},
+			"no matching replies":   {postID: post.ID, parentID: &missingParent},
+		}
+		for name, tc := range cases {
+			page, err := store.GetComments(ctx, tc.postID, tc.parentID, 10, nil, models.SortDesc)
+			require.NoError(t, err, name)
+			assert.NotNil(t, page.Comments, "%s: пустая страница должна быть пустым срезом, а не nil", name)
+			assert.Empty(t, page.Comments, name)
+			assert.Zero(t, page.TotalCount, name)
+			assert.Nil(t, page.NextCursor, name)
+		}
+	})
+
+	for _, order := range []models.SortOrder{models.SortDesc, models.SortAsc} {
+		t.Run(fmt.Sprintf("GetComments order and cursors %s", order), func(t *testing.T) {
+			store := factory(t)
+			ctx := context.Background()
+			base := baseTime()
+			post := newPost(base)
+			require.NoError(t, store.CreatePost(ctx, post))
+			times := []time.Time{base, base.Add(time.Second), base.Add(time.Second), base.Add(time.Second), base.Add(2 * time.Second)}
+			var keys []key
+			for _, at := range times {
+				comment := newComment(post.ID, nil, at)
+				require.NoError(t, store.CreateComment(ctx, comment))
+				keys = append(keys, key{ID: comment.ID, CreatedAt: comment.CreatedAt})
+			}
+			// Ответ не должен попадать в выборку комментариев верхнего уровня
+			require.NoError(t, store.CreateComment(ctx, newComment(post.ID, &keys[0].ID, base)))
+
+			var ids []string
+			var cursor *string
+			for page := 0; page < len(keys); page++ {
+				result, err := store.GetComments(ctx, post.ID, nil, 2, cursor, order)
+				require.NoError(t, err)
+				assert.Equal(t, len(keys), result.TotalCount)
+				for _, c := range result.Comments {
+					ids = append(ids, c.ID)
+				}
+				cursor = result.NextCursor
+				if cursor == nil {
+					break
+				}
+			}
+			assert.Equal(t, expectedOrder(keys, order), ids)
+		})
+	}
+
+	t.Run("GetComments replies", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		parent := newComment(post.ID, nil, baseTime())
+		require.NoError(t, store.CreateComment(ctx, parent))
+		reply := newComment(post.ID, &parent.ID, baseTime().Add(time.Second))
+		require.NoError(t, store.CreateComment(ctx, reply))
+
+		page, err := store.GetComments(ctx, post.ID, &parent.ID, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		assert.Equal(t, 1, page.TotalCount)
+		require.Len(t, page.Comments, 1)
+		assertComment(t, reply, &page.Comments[0])
+	})
+
+	t.Run("GetComments cursor errors", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		for i := 0; i < 3; i++ {
+			require.NoError(t, store.CreateComment(ctx, newComment(post.ID, nil, baseTime().Add(time.Duration(i)*time.Second))))
+		}
+
+		bad := "not-a-cursor"
+		_, err := store.GetComments(ctx, post.ID, nil, 2, &bad, models.SortDesc)
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+
+		page, err := store.GetComments(ctx, post.ID, nil, 2, nil, models.SortDesc)
+		require.NoError(t, err)
+		require.NotNil(t, page.NextCursor)
+		_, err = store.GetComments(ctx, post.ID, nil, 2, page.NextCursor, models.SortAsc)
+		assert.ErrorIs(t, err, storage.ErrCursorOrderMismatch)
+	})
+
+	t.Run("Offset cursors", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		var keys []key
+		for i := 0; i < 5; i++ {
+			comment := newComment(post.ID, nil, baseTime().Add(time.Duration(i)*time.Second))
+			require.NoError(t, store.CreateComment(ctx, comment))
+			keys = append(keys, key{ID: comment.ID, CreatedAt: comment.CreatedAt})
+		}
+		for _, order := range []models.SortOrder{models.SortAsc, models.SortDesc} {
+			offset := storage.EncodeOffsetCursor(order, 2)
+			page, err := store.GetComments(ctx, post.ID, nil, 2, &offset, order)
+			require.NoError(t, err)
+			assert.Equal(t, 5, page.TotalCount)
+			var ids []string
+			for _, c := range page.Comments {
+				ids = append(ids, c.ID)
+			}
+			assert.Equal(t, expectedOrder(keys, order)[2:4], ids, "Вторая страница в порядке %s", order)
+			require.NotNil(t, page.NextCursor)
+			next, err := store.GetComments(ctx, post.ID, nil, 2, page.NextCursor, order)
+			require.NoError(t, err)
+			require.Len(t, next.Comments, 1, "Следующая страница читается по ключу")
+			assert.Equal(t, expectedOrder(keys, order)[4], next.Comments[0].ID)
+		}
+
+		beyond := storage.EncodeOffsetCursor(models.SortDesc, 10)
+		page, err := store.GetComments(ctx, post.ID, nil, 2, &beyond, models.SortDesc)
+		require.NoError(t, err)
+		assert.Empty(t, page.Comments)
+		assert.Nil(t, page.NextCursor)
+
+		posts, err := store.ListPosts(ctx, 1, &beyond, storage.PostFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, posts.Posts)
+		assert.Equal(t, 1, posts.TotalCount)
+
+		deep := storage.EncodeOffsetCursor(models.SortDesc, storage.MaxOffset+1)
+		_, err = store.GetComments(ctx, post.ID, nil, 2, &deep, models.SortDesc)
+		assert.ErrorIs(t, err, storage.ErrOffsetTooLarge)
+		_, err = store.ListPosts(ctx, 2, &deep, storage.PostFilter{})
+		assert.ErrorIs(t, err, storage.ErrOffsetTooLarge)
+	})
+
+	t.Run("LinkPreviews", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+
+		err := store.SaveLinkPreview(ctx, &models.LinkPreview{PostID: uuid.New().String(), URL: "https://a.example", FetchedAt: baseTime()})
+		assert.ErrorIs(t, err, storage.ErrPostNotFound)
+
+		empty, err := store.GetLinkPreviews(ctx, post.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, empty)
+		assert.Empty(t, empty)
+
+		for _, url := range []string{"https://b.example", "https://a.example"} {
+			require.NoError(t, store.SaveLinkPreview(ctx, &models.LinkPreview{PostID: post.ID, URL: url, Title: "old", FetchedAt: baseTime()}))
+		}
+		require.NoError(t, store.SaveLinkPreview(ctx, &models.LinkPreview{PostID: post.ID, URL: "https://b.example", Title: "new", FetchedAt: baseTime()}))
+
+		previews, err := store.GetLinkPreviews(ctx, post.ID)
+		require.NoError(t, err)
+		require.Len(t, previews, 2)
+		assert.Equal(t, "https://a.example", previews[0].URL, "Превью должны быть упорядочены по URL")
+		assert.Equal(t, "https://b.example", previews[1].URL)
+		assert.Equal(t, "new", previews[1].Title, "Повторное сохранение должно заменять превью")
+	})
+
+	t.Run("Reactions", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		target, other := uuid.New().String(), uuid.New().String()
+		toggle := func(user, emoji string) bool {
+			added, err := store.ToggleReaction(ctx, &models.Reaction{TargetID: target, UserID: user, Emoji: emoji, CreatedAt: baseTime()})
+			require.NoError(t, err)
+			return added
+		}
+		assert.True(t, toggle("user1", "👍"))
+		assert.True(t, toggle("user2", "👍"))
+		assert.True(t, toggle("user1", "❤️"))
+		assert.True(t, toggle("user1", "🎉"))
+		assert.False(t, toggle("user1", "🎉"), "Повторная реакция должна сниматься")
+
+		counts, err := store.GetReactionCounts(ctx, []string{target, other})
+		require.NoError(t, err)
+		assert.Equal(t, []models.ReactionCount{{Emoji: "👍", Count: 2}, {Emoji: "❤️", Count: 1}}, counts[target])
+		assert.NotNil(t, counts[other], "Объект без реакций должен получать пустой срез")
+		assert.Empty(t, counts[other])
+	})
+
+	t.Run("IncrementQuotaUsage", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		window := baseTime().Truncate(time.Minute)
+		for want := 1; want <= 3; want++ {
+			used, err := store.IncrementQuotaUsage(ctx, "user1", "comment", window)
+			require.NoError(t, err)
+			assert.Equal(t, want, used)
+		}
+		used, err := store.IncrementQuotaUsage(ctx, "user1", "post", window)
+		require.NoError(t, err)
+		assert.Equal(t, 1, used, "Счётчики разных действий независимы")
+
+		used, err = store.IncrementQuotaUsage(ctx, "user1", "comment", window.Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 1, used, "Новое окно начинает счётчик заново")
+
+		require.NoError(t, store.DecrementQuotaUsage(ctx, "user1", "comment", window), "Прошедшее окно не меняется")
+		next := window.Add(time.Minute)
+		require.NoError(t, store.DecrementQuotaUsage(ctx, "user1", "comment", next))
+		require.NoError(t, store.DecrementQuotaUsage(ctx, "user1", "comment", next), "Счётчик не уходит ниже нуля")
+		used, err = store.IncrementQuotaUsage(ctx, "user1", "comment", next)
+		require.NoError(t, err)
+		assert.Equal(t, 1, used)
+	})
+
+	t.Run("ShadowBan", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		banned, err := store.IsShadowBanned(ctx, "user2")
+		require.NoError(t, err)
+		assert.False(t, banned)
+
+		require.NoError(t, store.SetShadowBan(ctx, "user2", true))
+		require.NoError(t, store.SetShadowBan(ctx, "user2", true), "Повторный бан не должен быть ошибкой")
+		banned, err = store.IsShadowBanned(ctx, "user2")
+		require.NoError(t, err)
+		assert.True(t, banned)
+
+		require.NoError(t, store.SetShadowBan(ctx, "user2", false))
+		banned, err = store.IsShadowBanned(ctx, "user2")
+		require.NoError(t, err)
+		assert.False(t, banned)
+	})
+
+	t.Run("GetComments hides comments from other viewers", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		visible := newComment(post.ID, nil, baseTime())
+		require.NoError(t, store.CreateComment(ctx, visible))
+		hidden := newComment(post.ID, nil, baseTime().Add(time.Second))
+		hidden.AuthorID = "user2"
+		hidden.Hidden = true
+		require.NoError(t, store.CreateComment(ctx, hidden))
+
+		got, err := store.GetComment(ctx, hidden.ID)
+		require.NoError(t, err)
+		assert.True(t, got.Hidden)
+
+		for _, viewerID := range []string{"", "user1"} {
+			page, err := store.GetComments(storage.WithViewer(ctx, viewerID), post.ID, nil, 10, nil, models.SortDesc)
+			require.NoError(t, err)
+			require.Len(t, page.Comments, 1, "Зритель %q не должен видеть скрытый комментарий", viewerID)
+			assert.Equal(t, visible.ID, page.Comments[0].ID)
+			assert.Equal(t, 1, page.TotalCount)
+		}
+
+		page, err := store.GetComments(storage.WithViewer(ctx, "user2"), post.ID, nil, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		require.Len(t, page.Comments, 2, "Автор видит свой скрытый комментарий")
+		assert.Equal(t, hidden.ID, page.Comments[0].ID)
+		assert.True(t, page.Comments[0].Hidden)
+		assert.Equal(t, 2, page.TotalCount)
+	})
+
+	t.Run("ModerationRules CRUD", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		rules, err := store.ListModerationRules(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, rules)
+
+		first := &models.ModerationRule{ID: uuid.New().String(), Kind: models.RuleKindKeyword, Pattern: "спам", Action: models.RuleActionBlock, CreatedBy: "mod1", CreatedAt: baseTime()}
+		second := &models.ModerationRule{ID: uuid.New().String(), Kind: models.RuleKindRegex, Pattern: "spoiler", Action: models.RuleActionTag, Tag: "spoiler", CreatedBy: "mod1", CreatedAt: baseTime().Add(time.Second)}
+		require.NoError(t, store.CreateModerationRule(ctx, first))
+		require.NoError(t, store.CreateModerationRule(ctx, second))
+
+		update := &models.ModerationRule{ID: first.ID, Kind: models.RuleKindKeyword, Pattern: "реклама", Action: models.RuleActionHold}
+		require.NoError(t, store.UpdateModerationRule(ctx, update))
+		assert.Equal(t, "mod1", update.CreatedBy, "Автор правила сохраняется при обновлении")
+
+		rules, err = store.ListModerationRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 2)
+		assert.Equal(t, first.ID, rules[0].ID, "Правила возвращаются в порядке создания")
+		assert.Equal(t, "реклама", rules[0].Pattern)
+		assert.Equal(t, models.RuleActionHold, rules[0].Action)
+		assert.Equal(t, "spoiler", rules[1].Tag)
+
+		require.NoError(t, store.DeleteModerationRule(ctx, second.ID))
+		assert.ErrorIs(t, store.DeleteModerationRule(ctx, second.ID), storage.ErrRuleNotFound)
+		assert.ErrorIs(t, store.UpdateModerationRule(ctx, second), storage.ErrRuleNotFound)
+		rules, err = store.ListModerationRules(ctx)
+		require.NoError(t, err)
+		assert.Len(t, rules, 1)
+	})
+
+	t.Run("Allowed operations", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		queries, err := store.ListAllowedOperations(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, queries)
+
+		first := "query { posts(limit: 10) { totalCount } }"
+		second := "mutation { createPost(title: \"a\", content: \"b\", allowComments: true) { id } }"
+		require.NoError(t, store.AddAllowedOperation(ctx, first))
+		require.NoError(t, store.AddAllowedOperation(ctx, second))
+		require.NoError(t, store.AddAllowedOperation(ctx, first), "Повторное добавление не ошибка")
+
+		queries, err = store.ListAllowedOperations(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{first, second}, queries)
+	})
+
+	t.Run("Held content review", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		visible := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, visible))
+		held := newPost(baseTime().Add(time.Second))
+		held.AuthorID = "user2"
+		held.Hidden = true
+		held.Tags = []string{"review"}
+		require.NoError(t, store.CreatePost(ctx, held))
+		heldComment := newComment(visible.ID, nil, baseTime())
+		heldComment.Hidden = true
+		require.NoError(t, store.CreateComment(ctx, heldComment))
+
+		page, err := store.ListPosts(ctx, 10, nil, storage.PostFilter{})
+		require.NoError(t, err)
+		require.Len(t, page.Posts, 1, "Задержанный пост не виден другим пользователям")
+		assert.Equal(t, 1, page.TotalCount)
+		page, err = store.ListPosts(storage.WithViewer(ctx, "user2"), 10, nil, storage.PostFilter{})
+		require.NoError(t, err)
+		require.Len(t, page.Posts, 2, "Автор видит свой задержанный пост")
+		assert.True(t, page.Posts[0].Hidden)
+		assert.Equal(t, []string{"review"}, page.Posts[0].Tags)
+
+		postItem := &models.HeldItem{ID: uuid.New().String(), TargetType: models.TargetPost, TargetID: held.ID, RuleID: "rule1", CreatedAt: baseTime()}
+		commentItem := &models.HeldItem{ID: uuid.New().String(), TargetType: models.TargetComment, TargetID: heldComment.ID, RuleID: "rule1", CreatedAt: baseTime().Add(time.Second)}
+		require.NoError(t, store.HoldContent(ctx, postItem))
+		require.NoError(t, store.HoldContent(ctx, commentItem))
+		items, err := store.ListHeldContent(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, postItem.ID, items[0].ID, "Очередь начинается со старых записей")
+
+		require.NoError(t, store.ResolveHeldContent(ctx, postItem.ID, true))
+		require.NoError(t, store.ResolveHeldContent(ctx, commentItem.ID, true))
+		assert.ErrorIs(t, store.ResolveHeldContent(ctx, commentItem.ID, true), storage.ErrHeldItemNotFound)
+		items, err = store.ListHeldContent(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, items)
+
+		page, err = store.ListPosts(ctx, 10, nil, storage.PostFilter{})
+		require.NoError(t, err)
+		assert.Len(t, page.Posts, 2, "Одобренный пост виден всем")
+		comments, err := store.GetComments(ctx, visible.ID, nil, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		assert.Len(t, comments.Comments, 1, "Одобренный комментарий виден всем")
+		assert.Equal(t, 1, comments.TotalCount)
+	})
+
+	t.Run("Spam status", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		older := newComment(post.ID, nil, baseTime())
+		older.SpamStatus = models.SpamStatusPending
+		require.NoError(t, store.CreateComment(ctx, older))
+		newer := newComment(post.ID, nil, baseTime().Add(time.Second))
+		newer.SpamStatus = models.SpamStatusPending
+		require.NoError(t, store.CreateComment(ctx, newer))
+
+		pending, err := store.ListCommentsBySpamStatus(ctx, models.SpamStatusPending, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, newer.ID, pending[0].ID, "Список начинается с новых комментариев")
+
+		require.NoError(t, store.SetSpamStatus(ctx, older.ID, models.SpamStatusSpam, true))
+		assert.ErrorIs(t, store.SetSpamStatus(ctx, "missing", models.SpamStatusHam, false), storage.ErrCommentNotFound)
+		got, err := store.GetComment(ctx, older.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.SpamStatusSpam, got.SpamStatus)
+		assert.True(t, got.Hidden)
+		spam, err := store.ListCommentsBySpamStatus(ctx, models.SpamStatusSpam, 10)
+		require.NoError(t, err)
+		require.Len(t, spam, 1)
+		assert.Equal(t, older.ID, spam[0].ID)
+
+		comments, err := store.GetComments(ctx, post.ID, nil, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		assert.Len(t, comments.Comments, 1, "Спам скрыт от других пользователей")
+		assert.Equal(t, 1, comments.TotalCount)
+
+		require.NoError(t, store.SetSpamStatus(ctx, older.ID, models.SpamStatusHam, false))
+		comments, err = store.GetComments(ctx, post.ID, nil, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		assert.Len(t, comments.Comments, 2, "Комментарий, признанный не спамом, снова виден всем")
+		assert.Equal(t, 2, comments.TotalCount)
+	})
+
+	t.Run("CreateComments batch", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		first := newComment(post.ID, nil, baseTime())
+		orphan := newComment(uuid.New().String(), nil, baseTime())
+		second := newComment(post.ID, nil, baseTime().Add(time.Second))
+
+		errs := store.CreateComments(ctx, []*models.Comment{first, orphan, second})
+		require.Len(t, errs, 3)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], storage.ErrPostNotFound, "Ошибка одного комментария не отменяет пачку")
+		assert.NoError(t, errs[2])
+		comments, err := store.GetComments(ctx, post.ID, nil, 10, nil, models.SortAsc)
+		require.NoError(t, err)
+		require.Len(t, comments.Comments, 2)
+		assert.Equal(t, first.ID, comments.Comments[0].ID)
+		assert.Equal(t, second.ID, comments.Comments[1].ID)
+		assert.Empty(t, store.CreateComments(ctx, nil))
+	})
+
+	t.Run("Excerpt", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		post.Excerpt = "Начало поста"
+		require.NoError(t, store.CreatePost(ctx, post))
+		got, err := store.GetPost(ctx, post.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Начало поста", got.Excerpt)
+
+		require.NoError(t, store.SetPostExcerpt(ctx, post.ID, "Краткое содержание"))
+		assert.ErrorIs(t, store.SetPostExcerpt(ctx, uuid.New().String(), "Анонс"), storage.ErrPostNotFound)
+		page, err := store.ListPosts(ctx, 10, nil, storage.PostFilter{})
+		require.NoError(t, err)
+		require.Len(t, page.Posts, 1)
+		assert.Equal(t, "Краткое содержание", page.Posts[0].Excerpt)
+	})
+
+	t.Run("Approval", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		require.NoError(t, store.SetPostRequireApproval(ctx, post.ID, true))
+		assert.ErrorIs(t, store.SetPostRequireApproval(ctx, uuid.New().String(), true), storage.ErrPostNotFound)
+		got, err := store.GetPost(ctx, post.ID)
+		require.NoError(t, err)
+		assert.True(t, got.RequireApproval)
+
+		other := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, other))
+		pending := func(postID string, at time.Time) *models.Comment {
+			comment := newComment(postID, nil, at)
+			comment.ApprovalStatus = models.ApprovalPending
+			comment.Hidden = true
+			require.NoError(t, store.CreateComment(ctx, comment))
+			return comment
+		}
+		newer := pending(post.ID, baseTime().Add(time.Second))
+		older := pending(post.ID, baseTime())
+		elsewhere := pending(other.ID, baseTime())
+
+		list, err := store.ListPendingComments(ctx, post.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, older.ID, list[0].ID, "Список начинается со старых комментариев")
+		assert.Equal(t, models.ApprovalPending, list[0].ApprovalStatus)
+		all, err := store.ListPendingComments(ctx, "", 10)
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+		comments, err := store.GetComments(ctx, post.ID, nil, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		assert.Empty(t, comments.Comments, "Комментарии на премодерации скрыты")
+
+		require.NoError(t, store.SetApprovalStatus(ctx, older.ID, models.ApprovalApproved, false))
+		require.NoError(t, store.SetApprovalStatus(ctx, newer.ID, models.ApprovalRejected, true))
+		assert.ErrorIs(t, store.SetApprovalStatus(ctx, "missing", models.ApprovalApproved, false), storage.ErrCommentNotFound)
+		comment, err := store.GetComment(ctx, older.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ApprovalApproved, comment.ApprovalStatus)
+		comments, err = store.GetComments(ctx, post.ID, nil, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		require.Len(t, comments.Comments, 1, "Одобренный комментарий виден всем")
+		assert.Equal(t, older.ID, comments.Comments[0].ID)
+		assert.Equal(t, 1, comments.TotalCount)
+		all, err = store.ListPendingComments(ctx, "", 10)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, elsewhere.ID, all[0].ID)
+	})
+
+	t.Run("Toxicity score", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		calm := newComment(post.ID, nil, baseTime())
+		require.NoError(t, store.CreateComment(ctx, calm))
+		toxic := newComment(post.ID, nil, baseTime().Add(time.Second))
+		require.NoError(t, store.CreateComment(ctx, toxic))
+
+		require.NoError(t, store.SetToxicityScore(ctx, models.ToxicityScore{CommentID: calm.ID, Score: 0.1, ScoredAt: baseTime()}, nil))
+		hold := &models.HeldItem{ID: uuid.New().String(), TargetType: models.TargetComment, TargetID: toxic.ID, RuleID: models.ToxicityRuleID, CreatedAt: baseTime()}
+		require.NoError(t, store.SetToxicityScore(ctx, models.ToxicityScore{CommentID: toxic.ID, Score: 0.95, ScoredAt: baseTime()}, hold))
+		assert.ErrorIs(t, store.SetToxicityScore(ctx, models.ToxicityScore{CommentID: "missing", Score: 0.5, ScoredAt: baseTime()}, nil), storage.ErrCommentNotFound)
+
+		scores, err := store.GetToxicityScores(ctx, []string{calm.ID, toxic.ID, "missing"})
+		require.NoError(t, err)
+		require.Len(t, scores, 2)
+		assert.InDelta(t, 0.1, scores[calm.ID].Score, 1e-9)
+		assert.InDelta(t, 0.95, scores[toxic.ID].Score, 1e-9)
+
+		got, err := store.GetComment(ctx, toxic.ID)
+		require.NoError(t, err)
+		assert.True(t, got.Hidden)
+		held, err := store.ListHeldContent(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, held, 1)
+		assert.Equal(t, models.ToxicityRuleID, held[0].RuleID)
+		comments, err := store.GetComments(ctx, post.ID, nil, 10, nil, models.SortDesc)
+		require.NoError(t, err)
+		assert.Equal(t, 1, comments.TotalCount, "Задержанный комментарий скрыт до решения модератора")
+
+		// Повторная оценка обновляет значение, но не ставит скрытый комментарий в очередь второй раз
+		again := *hold
+		again.ID = uuid.New().String()
+		require.NoError(t, store.SetToxicityScore(ctx, models.ToxicityScore{CommentID: toxic.ID, Score: 0.9, ScoredAt: baseTime().Add(time.Minute)}, &again))
+		held, err = store.ListHeldContent(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, held, 1)
+		scores, err = store.GetToxicityScores(ctx, []string{toxic.ID})
+		require.NoError(t, err)
+		assert.InDelta(t, 0.9, scores[toxic.ID].Score, 1e-9)
+	})
+
+	t.Run("AnonymizeUserContent", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		own := newPost(baseTime())
+		own.Title = "Личный пост"
+		own.Slug = "lichnyi-post"
+		own.Tags = []string{"go"}
+		require.NoError(t, store.CreatePost(ctx, own))
+		other := newPost(baseTime())
+		other.AuthorID = "user2"
+		require.NoError(t, store.CreatePost(ctx, other))
+		require.NoError(t, store.SavePostReferences(ctx, own.ID, []string{other.ID}))
+		var owned []*models.Comment
+		for i := 0; i < 3; i++ {
+			comment := newComment(other.ID, nil, baseTime())
+			require.NoError(t, store.CreateComment(ctx, comment))
+			owned = append(owned, comment)
+		}
+		reply := newComment(other.ID, &owned[0].ID, baseTime())
+		reply.AuthorID = "user2"
+		require.NoError(t, store.CreateComment(ctx, reply))
+		sort.Slice(owned, func(i, j int) bool { return owned[i].ID < owned[j].ID })
+
+		ids, err := store.AnonymizeUserContent(ctx, models.TargetComment, "user1", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{owned[0].ID, owned[1].ID}, ids, "Комментарии обезличиваются в порядке ID")
+		ids, err = store.AnonymizeUserContent(ctx, models.TargetComment, "user1", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{owned[2].ID}, ids)
+		ids, err = store.AnonymizeUserContent(ctx, models.TargetComment, "user1", 2)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+		got, err := store.GetComment(ctx, owned[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeletedUserID, got.AuthorID)
+		assert.Empty(t, got.Content)
+		assert.Empty(t, got.Language)
+		got, err = store.GetComment(ctx, reply.ID)
+		require.NoError(t, err)
+		assert.Equal(t, reply.Content, got.Content, "Чужие ответы сохраняются")
+
+		ids, err = store.AnonymizeUserContent(ctx, models.TargetPost, "user1", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{own.ID}, ids)
+		gotPost, err := store.GetPost(ctx, own.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeletedUserID, gotPost.AuthorID)
+		assert.Empty(t, gotPost.Title)
+		assert.Empty(t, gotPost.Content)
+		assert.Empty(t, gotPost.Tags)
+		assert.Equal(t, own.ID, gotPost.Slug)
+		_, err = store.GetPostBySlug(ctx, own.Slug)
+		assert.ErrorIs(t, err, storage.ErrPostNotFound, "Прежний slug больше не ведёт на пост")
+		bySlug, err := store.GetPostBySlug(ctx, own.ID)
+		require.NoError(t, err)
+		assert.Equal(t, own.ID, bySlug.ID)
+		page, err := store.ListPosts(ctx, 10, nil, storage.PostFilter{ReferencedBy: own.ID})
+		require.NoError(t, err)
+		assert.Empty(t, page.Posts, "Ссылки обезличенного поста удаляются")
+		gotPost, err = store.GetPost(ctx, other.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "user2", gotPost.AuthorID)
+
+		_, err = store.AnonymizeUserContent(ctx, "UNKNOWN", "user1", 10)
+		assert.Error(t, err)
+	})
+
+	t.Run("ListContents and ReplaceContent", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		post.Hidden = true
+		require.NoError(t, store.CreatePost(ctx, post))
+		comments := []*models.Comment{newComment(post.ID, nil, baseTime()), newComment(post.ID, nil, baseTime()), newComment(post.ID, nil, baseTime())}
+		for _, comment := range comments {
+			require.NoError(t, store.CreateComment(ctx, comment))
+		}
+		sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+
+		records, err := store.ListContents(ctx, models.TargetPost, "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []storage.ContentRecord{{ID: post.ID, Content: post.Content}}, records, "Скрытые посты тоже возвращаются")
+
+		records, err = store.ListContents(ctx, models.TargetComment, "", 2)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, comments[0].ID, records[0].ID, "Записи упорядочены по ID")
+		records, err = store.ListContents(ctx, models.TargetComment, records[1].ID, 2)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, storage.ContentRecord{ID: comments[2].ID, Content: comments[2].Content}, records[0])
+
+		replaced, err := store.ReplaceContent(ctx, models.TargetComment, comments[2].ID, "другое", "новое")
+		require.NoError(t, err)
+		assert.False(t, replaced, "Изменившееся содержимое не заменяется")
+		replaced, err = store.ReplaceContent(ctx, models.TargetComment, comments[2].ID, comments[2].Content, "новое")
+		require.NoError(t, err)
+		assert.True(t, replaced)
+		got, err := store.GetComment(ctx, comments[2].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "новое", got.Content)
+
+		replaced, err = store.ReplaceContent(ctx, models.TargetPost, post.ID, post.Content, "новый текст")
+		require.NoError(t, err)
+		assert.True(t, replaced)
+		gotPost, err := store.GetPost(storage.WithViewer(ctx, post.AuthorID), post.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "новый текст", gotPost.Content)
+
+		replaced, err = store.ReplaceContent(ctx, models.TargetPost, uuid.New().String(), "", "текст")
+		require.NoError(t, err)
+		assert.False(t, replaced, "Отсутствующая запись не считается ошибкой")
+	})
+
+	t.Run("VoteComment", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		comment := newComment(post.ID, nil, baseTime())
+		require.NoError(t, store.CreateComment(ctx, comment))
+
+		vote := func(userID string, value int) *models.Comment {
+			t.Helper()
+			updated, err := store.VoteComment(ctx, &models.Vote{CommentID: comment.ID, UserID: userID, Value: value, CreatedAt: baseTime()})
+			require.NoError(t, err)
+			return updated
+		}
+		vote("user1", 1)
+		updated := vote("user1", 1)
+		assert.Equal(t, 1, updated.Upvotes, "Повторный голос пользователя не учитывается дважды")
+		vote("user2", 1)
+		updated = vote("user1", -1)
+		assert.Equal(t, 1, updated.Upvotes, "Смена голоса переносит его в другой счётчик")
+		assert.Equal(t, 1, updated.Downvotes)
+		updated = vote("user1", 0)
+		assert.Equal(t, 1, updated.Upvotes)
+		assert.Equal(t, 0, updated.Downvotes, "Голос снимается")
+
+		got, err := store.GetComment(ctx, comment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.Upvotes)
+
+		_, err = store.VoteComment(ctx, &models.Vote{CommentID: "missing", UserID: "user1", Value: 1, CreatedAt: baseTime()})
+		assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+	})
+
+	t.Run("Unread comment counts", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		other := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, other))
+		first := newComment(post.ID, nil, baseTime().Add(-time.Minute))
+		require.NoError(t, store.CreateComment(ctx, first))
+		own := newComment(post.ID, nil, baseTime().Add(-time.Minute))
+		own.AuthorID = "reader"
+		require.NoError(t, store.CreateComment(ctx, own))
+		hidden := newComment(post.ID, nil, baseTime().Add(-time.Minute))
+		hidden.Hidden = true
+		require.NoError(t, store.CreateComment(ctx, hidden))
+
+		counts, err := store.GetUnreadCommentCounts(ctx, "reader", []string{post.ID, other.ID})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{post.ID: 1, other.ID: 0}, counts, "Без отметки непрочитаны все чужие видимые комментарии")
+
+		readAt := baseTime()
+		require.NoError(t, store.MarkThreadRead(ctx, "reader", post.ID, readAt))
+		require.NoError(t, store.MarkThreadRead(ctx, "reader", post.ID, readAt.Add(-time.Hour)), "Более ранняя отметка не сдвигает время прочтения")
+		reply := newComment(post.ID, &first.ID, readAt.Add(time.Second))
+		require.NoError(t, store.CreateComment(ctx, reply))
+
+		counts, err = store.GetUnreadCommentCounts(ctx, "reader", []string{post.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 1, counts[post.ID], "Учитываются ответы после отметки")
+		counts, err = store.GetUnreadCommentCounts(ctx, "user2", []string{post.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 3, counts[post.ID], "Отметка хранится отдельно для каждого пользователя")
+
+		assert.ErrorIs(t, store.MarkThreadRead(ctx, "reader", "missing", readAt), storage.ErrPostNotFound)
+	})
+
+	t.Run("Preferences", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		prefs, err := store.GetPreferences(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, models.DefaultPreferences("user1"), prefs, "Без сохранённых настроек возвращаются настройки по умолчанию")
+
+		saved := &models.Preferences{UserID: "user1", EmailOnMention: true, DefaultCommentSort: models.SortBest, Locale: "en", UpdatedAt: baseTime()}
+		require.NoError(t, store.SavePreferences(ctx, saved))
+		saved.EmailOnReply = true
+		saved.Locale = "ru"
+		require.NoError(t, store.SavePreferences(ctx, saved))
+		prefs, err = store.GetPreferences(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, saved, prefs)
+		prefs, err = store.GetPreferences(ctx, "user2")
+		require.NoError(t, err)
+		assert.True(t, prefs.EmailOnReply, "Настройки хранятся отдельно для каждого пользователя")
+	})
+
+	t.Run("Tenant settings", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		settings, err := store.GetTenantSettings(ctx, "forum")
+		require.NoError(t, err)
+		assert.Equal(t, models.DefaultTenantSettings("forum"), settings, "Без сохранённых настроек возвращаются настройки по умолчанию")
+
+		saved := &models.TenantSettings{TenantID: "forum", DefaultCommentSort: models.SortBest, MaxNesting: 3, AnonymousPolicy: models.AnonymousReadOnly, SiteName: "Форум", UpdatedAt: baseTime()}
+		require.NoError(t, store.SaveTenantSettings(ctx, saved))
+		saved.Tagline = "Обсуждения"
+		saved.PrimaryColor = "#336699"
+		require.NoError(t, store.SaveTenantSettings(ctx, saved))
+		settings, err = store.GetTenantSettings(ctx, "forum")
+		require.NoError(t, err)
+		assert.Equal(t, saved, settings)
+		settings, err = store.GetTenantSettings(ctx, models.DefaultTenant)
+		require.NoError(t, err)
+		assert.Equal(t, models.AnonymousAllow, settings.AnonymousPolicy, "Настройки хранятся отдельно для каждого сообщества")
+	})
+
+	t.Run("Tenant usage", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		usage, err := store.GetTenantUsage(ctx, "forum")
+		require.NoError(t, err)
+		assert.Equal(t, &models.TenantUsage{TenantID: "forum"}, usage)
+
+		require.NoError(t, store.AddTenantUsage(ctx, "forum", models.TargetPost, 100))
+		require.NoError(t, store.AddTenantUsage(ctx, "forum", models.TargetComment, 20))
+		require.NoError(t, store.AddTenantUsage(ctx, "forum", models.TargetComment, 30))
+		require.NoError(t, store.AddTenantUsage(ctx, "other", models.TargetPost, 1000))
+		assert.Error(t, store.AddTenantUsage(ctx, "forum", "ATTACHMENT", 1))
+		usage, err = store.GetTenantUsage(ctx, "forum")
+		require.NoError(t, err)
+		assert.Equal(t, &models.TenantUsage{TenantID: "forum", Posts: 1, Comments: 2, Bytes: 150}, usage)
+	})
+
+	t.Run("Device tokens", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		register := func(token, userID string, at time.Time) {
+			t.Helper()
+			require.NoError(t, store.RegisterDeviceToken(ctx, &models.DeviceToken{Token: token, UserID: userID, Platform: models.PlatformFCM, CreatedAt: at}))
+		}
+		register("phone", "user1", baseTime())
+		register("tablet", "user1", baseTime().Add(time.Second))
+		register("shared", "user1", baseTime().Add(2*time.Second))
+		register("shared", "user2", baseTime().Add(3*time.Second))
+
+		tokens, err := store.ListDeviceTokens(ctx, "user1")
+		require.NoError(t, err)
+		require.Len(t, tokens, 2, "Повторно зарегистрированный токен переходит к новому пользователю")
+		assert.Equal(t, "phone", tokens[0].Token)
+		assert.Equal(t, "tablet", tokens[1].Token)
+		assert.Equal(t, models.PlatformFCM, tokens[0].Platform)
+
+		require.NoError(t, store.UnregisterDeviceToken(ctx, "user1", "shared"), "Чужой токен не удаляется")
+		require.NoError(t, store.UnregisterDeviceToken(ctx, "user1", "phone"))
+		tokens, err = store.ListDeviceTokens(ctx, "user1")
+		require.NoError(t, err)
+		require.Len(t, tokens, 1)
+		tokens, err = store.ListDeviceTokens(ctx, "user2")
+		require.NoError(t, err)
+		require.Len(t, tokens, 1)
+		assert.Equal(t, "shared", tokens[0].Token)
+	})
+
+	t.Run("Saved searches", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		base := baseTime()
+		yes := true
+		first := &models.SavedSearch{
+			ID: uuid.New().String(), UserID: "user1", Name: "Ракеты",
+			Filter:    models.SearchFilter{Text: "ракет", CategoryID: "cat", IncludeSubcategories: true, HasComments: &yes},
+			Alert:     true,
+			CreatedAt: base, CheckedAt: base,
+		}
+		second := &models.SavedSearch{ID: uuid.New().String(), UserID: "user1", Name: "Все", CreatedAt: base.Add(time.Second), CheckedAt: base}
+		foreign := &models.SavedSearch{ID: uuid.New().String(), UserID: "user2", Name: "Чужой", Alert: true, CreatedAt: base.Add(2 * time.Second), CheckedAt: base}
+		for _, search := range []*models.SavedSearch{first, second, foreign} {
+			require.NoError(t, store.CreateSavedSearch(ctx, search))
+		}
+
+		searches, err := store.ListSavedSearches(ctx, "user1")
+		require.NoError(t, err)
+		require.Len(t, searches, 2)
+		assert.Equal(t, *first, searches[0])
+		assert.Equal(t, second.ID, searches[1].ID)
+
+		alerts, err := store.ListAlertSavedSearches(ctx)
+		require.NoError(t, err)
+		require.Len(t, alerts, 2)
+		assert.Equal(t, first.ID, alerts[0].ID)
+		assert.Equal(t, foreign.ID, alerts[1].ID)
+
+		require.NoError(t, store.MarkSavedSearchChecked(ctx, first.ID, base.Add(time.Minute)))
+		require.NoError(t, store.MarkSavedSearchChecked(ctx, first.ID, base), "Более раннее время не заменяет сохранённое")
+		require.NoError(t, store.MarkSavedSearchChecked(ctx, uuid.New().String(), base))
+		searches, err = store.ListSavedSearches(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, base.Add(time.Minute), searches[0].CheckedAt)
+
+		assert.ErrorIs(t, store.DeleteSavedSearch(ctx, "user1", foreign.ID), storage.ErrSavedSearchNotFound)
+		require.NoError(t, store.DeleteSavedSearch(ctx, "user1", first.ID))
+		assert.ErrorIs(t, store.DeleteSavedSearch(ctx, "user1", first.ID), storage.ErrSavedSearchNotFound)
+		searches, err = store.ListSavedSearches(ctx, "user1")
+		require.NoError(t, err)
+		require.Len(t, searches, 1)
+		assert.Equal(t, second.ID, searches[0].ID)
+	})
+
+	t.Run("Post subscriptions and digests", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		other := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, other))
+		before := newComment(post.ID, nil, baseTime().Add(-time.Minute))
+		require.NoError(t, store.CreateComment(ctx, before))
+
+		subscribedAt := baseTime()
+		require.NoError(t, store.SubscribeToPost(ctx, "reader", post.ID, subscribedAt))
+		require.NoError(t, store.SubscribeToPost(ctx, "reader", post.ID, subscribedAt.Add(time.Hour)), "Повторная подписка не сдвигает её время")
+		require.NoError(t, store.SubscribeToPost(ctx, "reader", other.ID, subscribedAt))
+		assert.ErrorIs(t, store.SubscribeToPost(ctx, "reader", "missing", subscribedAt), storage.ErrPostNotFound)
+
+		first := newComment(post.ID, nil, subscribedAt.Add(time.Second))
+		require.NoError(t, store.CreateComment(ctx, first))
+		own := newComment(post.ID, nil, subscribedAt.Add(time.Second))
+		own.AuthorID = "reader"
+		require.NoError(t, store.CreateComment(ctx, own))
+		hidden := newComment(post.ID, nil, subscribedAt.Add(time.Second))
+		hidden.Hidden = true
+		require.NoError(t, store.CreateComment(ctx, hidden))
+		second := newComment(other.ID, nil, subscribedAt.Add(2*time.Second))
+		require.NoError(t, store.CreateComment(ctx, second))
+		late := newComment(post.ID, nil, subscribedAt.Add(time.Hour))
+		require.NoError(t, store.CreateComment(ctx, late))
+
+		subscribers, err := store.ListDigestSubscribers(ctx)
+		require.NoError(t, err)
+		require.Len(t, subscribers, 1)
+		assert.Equal(t, "reader", subscribers[0].UserID)
+		assert.True(t, subscribers[0].LastSentAt.IsZero())
+
+		until := subscribedAt.Add(time.Minute)
+		comments, err := store.GetDigestComments(ctx, "reader", time.Time{}, until, 10)
+		require.NoError(t, err)
+		ids := map[string]bool{}
+		for _, c := range comments {
+			ids[c.ID] = true
+		}
+		assert.Equal(t, map[string]bool{first.ID: true, second.ID: true}, ids, "В сводку попадают только чужие видимые комментарии после подписки")
+		comments, err = store.GetDigestComments(ctx, "reader", time.Time{}, until, 1)
+		require.NoError(t, err)
+		assert.Len(t, comments, 1)
+
+		require.NoError(t, store.MarkDigestSent(ctx, "reader", until))
+		subscribers, err = store.ListDigestSubscribers(ctx)
+		require.NoError(t, err)
+		assert.True(t, until.Equal(subscribers[0].LastSentAt))
+		comments, err = store.GetDigestComments(ctx, "reader", until, until.Add(2*time.Hour), 10)
+		require.NoError(t, err)
+		require.Len(t, comments, 1)
+		assert.Equal(t, late.ID, comments[0].ID)
+
+		require.NoError(t, store.UnsubscribeFromPost(ctx, "reader", post.ID))
+		require.NoError(t, store.UnsubscribeFromPost(ctx, "reader", post.ID))
+		comments, err = store.GetDigestComments(ctx, "reader", until, until.Add(2*time.Hour), 10)
+		require.NoError(t, err)
+		assert.Empty(t, comments)
+	})
+
+	t.Run("GetComments BEST order and cursors", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		// Голоса: popular 3 за, mixed 4 за и 1 против, single 1 за; unvoted и newer без голосов
+		votes := map[string][2]int{"popular": {3, 0}, "mixed": {4, 1}, "single": {1, 0}}
+		ids := map[string]string{}
+		for i, name := range []string{"unvoted", "popular", "mixed", "single", "newer"} {
+			comment := newComment(post.ID, nil, baseTime().Add(time.Duration(i)*time.Second))
+			require.NoError(t, store.CreateComment(ctx, comment))
+			ids[comment.ID] = name
+			for u := 0; u < votes[name][0]; u++ {
+				_, err := store.VoteComment(ctx, &models.Vote{CommentID: comment.ID, UserID: fmt.Sprintf("up%d", u), Value: 1, CreatedAt: baseTime()})
+				require.NoError(t, err)
+			}
+			for u := 0; u < votes[name][1]; u++ {
+				_, err := store.VoteComment(ctx, &models.Vote{CommentID: comment.ID, UserID: fmt.Sprintf("down%d", u), Value: -1, CreatedAt: baseTime()})
+				require.NoError(t, err)
+			}
+		}
+
+		var got []string
+		var cursor *string
+		for {
+			page, err := store.GetComments(ctx, post.ID, nil, 2, cursor, models.SortBest)
+			require.NoError(t, err)
+			assert.Equal(t, 5, page.TotalCount)
+			for _, c := range page.Comments {
+				got = append(got, ids[c.ID])
+			}
+			if page.NextCursor == nil {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"popular", "mixed", "single", "newer", "unvoted"}, got, "Без голосов - сначала новые")
+
+		_, err := store.GetComments(ctx, post.ID, nil, 2, cursor, models.SortDesc)
+		assert.ErrorIs(t, err, storage.ErrCursorOrderMismatch)
+	})
+
+	t.Run("CountCommentsBefore", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		var roots []*models.Comment
+		for i := 0; i < 4; i++ {
+			comment := newComment(post.ID, nil, baseTime().Add(time.Duration(i)*time.Second))
+			require.NoError(t, store.CreateComment(ctx, comment))
+			roots = append(roots, comment)
+		}
+		reply := newComment(post.ID, &roots[0].ID, baseTime().Add(time.Minute))
+		require.NoError(t, store.CreateComment(ctx, reply))
+		hidden := newComment(post.ID, nil, baseTime().Add(time.Hour))
+		hidden.AuthorID = "spammer"
+		hidden.Hidden = true
+		require.NoError(t, store.CreateComment(ctx, hidden))
+
+		for _, order := range []models.SortOrder{models.SortAsc, models.SortDesc, models.SortBest} {
+			page, err := store.GetComments(ctx, post.ID, nil, 10, nil, order)
+			require.NoError(t, err)
+			for i, comment := range page.Comments {
+				count, err := store.CountCommentsBefore(ctx, comment.ID, order)
+				require.NoError(t, err)
+				assert.Equal(t, i, count, "Позиция %s в порядке %s", comment.ID, order)
+			}
+		}
+		count, err := store.CountCommentsBefore(ctx, reply.ID, models.SortDesc)
+		require.NoError(t, err)
+		assert.Zero(t, count, "Считаются только ответы того же родителя")
+
+		count, err = store.CountCommentsBefore(storage.WithViewer(ctx, "spammer"), roots[3].ID, models.SortDesc)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count, "Скрытый комментарий учитывается для его автора")
+
+		_, err = store.CountCommentsBefore(ctx, uuid.New().String(), models.SortDesc)
+		assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+	})
+
+	t.Run("Categories", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		categories, err := store.ListCategories(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, categories)
+
+		root := &models.Category{ID: uuid.New().String(), Name: "Наука", CreatedAt: baseTime()}
+		require.NoError(t, store.CreateCategory(ctx, root))
+		assert.Equal(t, root.ID, root.Path)
+		child := &models.Category{ID: uuid.New().String(), Name: "Физика", ParentID: &root.ID, CreatedAt: baseTime().Add(time.Second)}
+		require.NoError(t, store.CreateCategory(ctx, child))
+		assert.Equal(t, root.ID+"/"+child.ID, child.Path)
+		missing := uuid.New().String()
+		orphan := &models.Category{ID: uuid.New().String(), Name: "Сирота", ParentID: &missing, CreatedAt: baseTime()}
+		assert.ErrorIs(t, store.CreateCategory(ctx, orphan), storage.ErrCategoryNotFound)
+
+		got, err := store.GetCategory(ctx, child.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Физика", got.Name)
+		assert.Equal(t, &root.ID, got.ParentID)
+		assert.Equal(t, child.Path, got.Path)
+		_, err = store.GetCategory(ctx, missing)
+		assert.ErrorIs(t, err, storage.ErrCategoryNotFound)
+
+		categories, err = store.ListCategories(ctx)
+		require.NoError(t, err)
+		require.Len(t, categories, 2)
+		assert.Equal(t, root.ID, categories[0].ID, "Категории возвращаются в порядке создания")
+		assert.Nil(t, categories[0].ParentID)
+		assert.Equal(t, child.ID, categories[1].ID)
+	})
+
+	t.Run("ListPosts category filter", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		root := &models.Category{ID: uuid.New().String(), Name: "Наука", CreatedAt: baseTime()}
+		require.NoError(t, store.CreateCategory(ctx, root))
+		child := &models.Category{ID: uuid.New().String(), Name: "Физика", ParentID: &root.ID, CreatedAt: baseTime()}
+		require.NoError(t, store.CreateCategory(ctx, child))
+		other := &models.Category{ID: uuid.New().String(), Name: "Спорт", CreatedAt: baseTime()}
+		require.NoError(t, store.CreateCategory(ctx, other))
+
+		start := baseTime()
+		inRoot := newPost(start)
+		inRoot.CategoryID = &root.ID
+		inChild := newPost(start.Add(time.Second))
+		inChild.CategoryID = &child.ID
+		inOther := newPost(start.Add(2 * time.Second))
+		inOther.CategoryID = &other.ID
+		uncategorized := newPost(start.Add(3 * time.Second))
+		for _, post := range []*models.Post{inRoot, inChild, inOther, uncategorized} {
+			require.NoError(t, store.CreatePost(ctx, post))
+		}
+		missing := uuid.New().String()
+		orphan := newPost(start)
+		orphan.CategoryID = &missing
+		assert.ErrorIs(t, store.CreatePost(ctx, orphan), storage.ErrCategoryNotFound)
+
+		ids := func(filter storage.PostFilter) []string {
+			page, err := store.ListPosts(ctx, 10, nil, filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(page.Posts), page.TotalCount)
+			result := make([]string, len(page.Posts))
+			for i, post := range page.Posts {
+				result[i] = post.ID
+			}
+			return result
+		}
+		assert.Equal(t, []string{uncategorized.ID, inOther.ID, inChild.ID, inRoot.ID}, ids(storage.PostFilter{}))
+		assert.Equal(t, []string{inRoot.ID}, ids(storage.PostFilter{CategoryID: root.ID}))
+		assert.Equal(t, []string{inChild.ID, inRoot.ID}, ids(storage.PostFilter{CategoryID: root.ID, IncludeSubcategories: true}))
+		assert.Equal(t, []string{inChild.ID}, ids(storage.PostFilter{CategoryID: child.ID, IncludeSubcategories: true}))
+
+		page, err := store.ListPosts(ctx, 1, nil, storage.PostFilter{CategoryID: root.ID, IncludeSubcategories: true})
+		require.NoError(t, err)
+		require.NotNil(t, page.NextCursor)
+		page, err = store.ListPosts(ctx, 1, page.NextCursor, storage.PostFilter{CategoryID: root.ID, IncludeSubcategories: true})
+		require.NoError(t, err)
+		require.Len(t, page.Posts, 1)
+		assert.Equal(t, inRoot.ID, page.Posts[0].ID)
+		assert.Nil(t, page.NextCursor)
+
+		_, err = store.ListPosts(ctx, 10, nil, storage.PostFilter{CategoryID: missing})
+		assert.ErrorIs(t, err, storage.ErrCategoryNotFound)
+
+		require.NoError(t, store.SetPostCategory(ctx, uncategorized.ID, &child.ID))
+		got, err := store.GetPost(ctx, uncategorized.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &child.ID, got.CategoryID)
+		assert.Equal(t, []string{uncategorized.ID, inChild.ID}, ids(storage.PostFilter{CategoryID: child.ID}))
+		require.NoError(t, store.SetPostCategory(ctx, uncategorized.ID, nil))
+		got, err = store.GetPost(ctx, uncategorized.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got.CategoryID)
+		assert.ErrorIs(t, store.SetPostCategory(ctx, uncategorized.ID, &missing), storage.ErrCategoryNotFound)
+		assert.ErrorIs(t, store.SetPostCategory(ctx, missing, nil), storage.ErrPostNotFound)
+	})
+
+	t.Run("ListPosts filters", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		base := baseTime()
+		old := newPost(base)
+		old.Tags = []string{"go", "news"}
+		require.NoError(t, store.CreatePost(ctx, old))
+		other := newPost(base.Add(time.Hour))
+		other.AuthorID = "user2"
+		other.AllowComments = false
+		other.Language = "en"
+		require.NoError(t, store.CreatePost(ctx, other))
+		discussed := newPost(base.Add(2 * time.Hour))
+		discussed.Tags = []string{"go"}
+		discussed.Title = "Запуск: 100% успех"
+		require.NoError(t, store.CreatePost(ctx, discussed))
+		require.NoError(t, store.CreateComment(ctx, newComment(discussed.ID, nil, base.Add(3*time.Hour))))
+		// Скрытый комментарий не делает пост обсуждаемым
+		hidden := newComment(old.ID, nil, base.Add(3*time.Hour))
+		hidden.Hidden = true
+		require.NoError(t, store.CreateComment(ctx, hidden))
+
+		ids := func(filter storage.PostFilter) []string {
+			page, err := store.ListPosts(ctx, 10, nil, filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(page.Posts), page.TotalCount)
+			result := []string{}
+			for _, p := range page.Posts {
+				result = append(result, p.ID)
+			}
+			return result
+		}
+		after, before := base, base.Add(2*time.Hour)
+		yes, no := true, false
+		assert.Equal(t, []string{discussed.ID, other.ID}, ids(storage.PostFilter{CreatedAfter: &after}), "Граница не включается")
+		assert.Equal(t, []string{other.ID, old.ID}, ids(storage.PostFilter{CreatedBefore: &before}))
+		assert.Equal(t, []string{other.ID}, ids(storage.PostFilter{CreatedAfter: &after, CreatedBefore: &before}))
+		asOf := base.Add(time.Hour)
+		assert.Equal(t, []string{other.ID, old.ID}, ids(storage.PostFilter{AsOf: &asOf}), "Граница снимка включается")
+		assert.Equal(t, []string{other.ID}, ids(storage.PostFilter{AuthorID: "user2"}))
+		assert.Equal(t, []string{discussed.ID, old.ID}, ids(storage.PostFilter{Tag: "go"}))
+		assert.Equal(t, []string{old.ID}, ids(storage.PostFilter{Tag: "news"}))
+		assert.Equal(t, []string{discussed.ID}, ids(storage.PostFilter{HasComments: &yes}))
+		assert.Equal(t, []string{other.ID, old.ID}, ids(storage.PostFilter{HasComments: &no}))
+		assert.Equal(t, []string{other.ID}, ids(storage.PostFilter{AllowComments: &no}))
+		assert.Equal(t, []string{other.ID}, ids(storage.PostFilter{Language: "en"}))
+		assert.Equal(t, []string{}, ids(storage.PostFilter{Language: "de"}))
+		assert.Equal(t, []string{old.ID}, ids(storage.PostFilter{Tag: "go", HasComments: &no, AuthorID: "user1"}))
+		assert.Equal(t, []string{discussed.ID}, ids(storage.PostFilter{Text: "ЗАПУСК"}), "Текст ищется без учёта регистра")
+		assert.Equal(t, []string{discussed.ID}, ids(storage.PostFilter{Text: "0%"}), "Спецсимволы шаблона ищутся буквально")
+		assert.Equal(t, []string{}, ids(storage.PostFilter{Text: "_"}))
+		assert.Equal(t, []string{discussed.ID, other.ID, old.ID}, ids(storage.PostFilter{Text: "содержимое"}), "Текст ищется и в содержимом")
+		assert.Equal(t, []string{discussed.ID, old.ID}, ids(storage.PostFilter{IDs: []string{old.ID, discussed.ID, "missing"}}))
+		assert.Equal(t, []string{old.ID}, ids(storage.PostFilter{IDs: []string{old.ID, other.ID}, Tag: "go"}))
+		assert.Equal(t, []string{}, ids(storage.PostFilter{IDs: []string{}}), "Пустой список ID не находит ничего")
+
+		page, err := store.ListPosts(ctx, 1, nil, storage.PostFilter{AllowComments: &yes})
+		require.NoError(t, err)
+		assert.Equal(t, 2, page.TotalCount)
+		require.NotNil(t, page.NextCursor)
+		page, err = store.ListPosts(ctx, 1, page.NextCursor, storage.PostFilter{AllowComments: &yes})
+		require.NoError(t, err)
+		require.Len(t, page.Posts, 1)
+		assert.Equal(t, old.ID, page.Posts[0].ID)
+	})
+
+	t.Run("Post references", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		var posts []*models.Post
+		for i := 0; i < 3; i++ {
+			post := newPost(baseTime().Add(time.Duration(i) * time.Second))
+			require.NoError(t, store.CreatePost(ctx, post))
+			posts = append(posts, post)
+		}
+		ids := func(filter storage.PostFilter) []string {
+			page, err := store.ListPosts(ctx, 10, nil, filter)
+			require.NoError(t, err)
+			var result []string
+			for _, p := range page.Posts {
+				result = append(result, p.ID)
+			}
+			return result
+		}
+
+		require.NoError(t, store.SavePostReferences(ctx, posts[2].ID, []string{posts[0].ID, posts[1].ID}))
+		require.NoError(t, store.SavePostReferences(ctx, posts[1].ID, []string{posts[0].ID}))
+		assert.Equal(t, []string{posts[1].ID, posts[0].ID}, ids(storage.PostFilter{ReferencedBy: posts[2].ID}))
+		assert.Equal(t, []string{posts[2].ID, posts[1].ID}, ids(storage.PostFilter{References: posts[0].ID}))
+		assert.Empty(t, ids(storage.PostFilter{References: posts[2].ID}))
+
+		require.NoError(t, store.SavePostReferences(ctx, posts[2].ID, nil))
+		assert.Equal(t, []string{posts[1].ID}, ids(storage.PostFilter{References: posts[0].ID}), "Ссылки поста заменяются целиком")
+
+		err := store.SavePostReferences(ctx, posts[0].ID, []string{uuid.New().String()})
+		assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	})
+
+	t.Run("Post slugs", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		first := newPost(baseTime())
+		first.Slug = "hello"
+		require.NoError(t, store.CreatePost(ctx, first))
+		assert.Equal(t, "hello", first.Slug)
+		second := newPost(baseTime())
+		second.Slug = "hello"
+		require.NoError(t, store.CreatePost(ctx, second))
+		assert.Equal(t, "hello-2", second.Slug, "Занятый slug получает суффикс")
+		unnamed := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, unnamed))
+		assert.Equal(t, unnamed.ID, unnamed.Slug, "Пустой slug заменяется ID")
+
+		got, err := store.GetPostBySlug(ctx, "hello-2")
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, got.ID)
+		assert.Equal(t, "hello-2", got.Slug)
+		_, err = store.GetPostBySlug(ctx, "missing")
+		assert.ErrorIs(t, err, storage.ErrPostNotFound)
+
+		updated, err := store.UpdatePostTitle(ctx, second.ID, "Hello!", "hello")
+		require.NoError(t, err)
+		assert.Equal(t, "Hello!", updated.Title)
+		assert.Equal(t, "hello-2", updated.Slug, "Slug с той же основой сохраняется")
+
+		updated, err = store.UpdatePostTitle(ctx, second.ID, "World", "world")
+		require.NoError(t, err)
+		assert.Equal(t, "world", updated.Slug)
+		got, err = store.GetPost(ctx, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "World", got.Title)
+		assert.Equal(t, "world", got.Slug)
+		got, err = store.GetPostBySlug(ctx, "hello-2")
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, got.ID, "Прежний slug продолжает вести на пост")
+		assert.Equal(t, "world", got.Slug)
+
+		updated, err = store.UpdatePostTitle(ctx, second.ID, "Hello again", "hello")
+		require.NoError(t, err)
+		assert.Equal(t, "hello-2", updated.Slug, "Пост возвращает свой прежний slug")
+		third := newPost(baseTime())
+		third.Slug = "world"
+		require.NoError(t, store.CreatePost(ctx, third))
+		assert.Equal(t, "world-2", third.Slug, "Прежний slug другого поста остаётся занятым")
+
+		_, err = store.UpdatePostTitle(ctx, uuid.New().String(), "Title", "title")
+		assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	})
+
+	t.Run("RelatedPosts", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		category := &models.Category{ID: uuid.New().String(), Name: "Наука", CreatedAt: baseTime()}
+		require.NoError(t, store.CreateCategory(ctx, category))
+		start := baseTime()
+		post := newPost(start)
+		post.Title = "Запуск ракеты"
+		post.Tags = []string{"космос", "новости"}
+		post.CategoryID = &category.ID
+		require.NoError(t, store.CreatePost(ctx, post))
+
+		create := func(title string, tags []string, categoryID *string, hidden bool, offset time.Duration) *models.Post {
+			p := newPost(start.Add(offset))
+			p.Title, p.Tags, p.CategoryID, p.Hidden = title, tags, categoryID, hidden
+			require.NoError(t, store.CreatePost(ctx, p))
+			return p
+		}
+		twoTags := create("Пирог", []string{"космос", "новости"}, nil, false, time.Second)
+		sameCategory := create("Пирог", nil, &category.ID, false, 2*time.Second)
+		similarTitle := create("Запуск ракеты перенесён", nil, nil, false, 3*time.Second)
+		oneTag := create("Пирог", []string{"новости"}, nil, false, 4*time.Second)
+		create("Пирог", nil, nil, false, 5*time.Second)
+		create("Пирог", []string{"космос"}, nil, true, 6*time.Second)
+
+		related, err := store.RelatedPosts(ctx, post.ID, 10)
+		require.NoError(t, err)
+		ids := make([]string, len(related))
+		for i, p := range related {
+			ids[i] = p.ID
+		}
+		// sameCategory и oneTag получают равную оценку и упорядочиваются по времени создания
+		assert.Equal(t, []string{twoTags.ID, similarTitle.ID, oneTag.ID, sameCategory.ID}, ids)
+
+		related, err = store.RelatedPosts(ctx, post.ID, 2)
+		require.NoError(t, err)
+		assert.Len(t, related, 2)
+		_, err = store.RelatedPosts(ctx, uuid.New().String(), 10)
+		assert.ErrorIs(t, err, storage.ErrPostNotFound)
+	})
+
+	t.Run("SimilarPosts", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		start := baseTime()
+		create := func(title, authorID string, hidden bool, visibility string, offset time.Duration) *models.Post {
+			p := newPost(start.Add(offset))
+			p.Title, p.AuthorID, p.Hidden, p.Visibility = title, authorID, hidden, visibility
+			require.NoError(t, store.CreatePost(ctx, p))
+			return p
+		}
+		same := create("Запуск ракеты", "user1", false, models.VisibilityPublic, 0)
+		newerSame := create("Запуск ракеты", "user1", false, models.VisibilityPublic, time.Second)
+		postponed := create("Запуск ракеты отложен", "user1", false, models.VisibilityPublic, 2*time.Second)
+		create("Пирог", "user1", false, models.VisibilityPublic, 3*time.Second)
+		create("Запуск ракеты", "user1", true, models.VisibilityPublic, 4*time.Second)
+		own := create("Запуск ракеты", "user2", false, models.VisibilityPrivate, 5*time.Second)
+
+		ids := func(similar []storage.SimilarPost) []string {
+			ids := make([]string, len(similar))
+			for i, p := range similar {
+				ids[i] = p.Post.ID
+			}
+			return ids
+		}
+		similar, err := store.SimilarPosts(ctx, "Запуск ракеты", "", 0.3, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{newerSame.ID, same.ID, postponed.ID}, ids(similar))
+		assert.InDelta(t, 1, similar[0].Similarity, 0.001)
+		assert.InDelta(t, 0.636, similar[2].Similarity, 0.001)
+		assert.Equal(t, "Запуск ракеты", similar[0].Post.Title)
+
+		similar, err = store.SimilarPosts(ctx, "Запуск ракеты", "user2", 0.3, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{own.ID, newerSame.ID, same.ID, postponed.ID}, ids(similar), "Свои скрытые от других посты тоже учитываются")
+
+		similar, err = store.SimilarPosts(ctx, "Запуск ракеты", "", 0.9, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{newerSame.ID, same.ID}, ids(similar))
+
+		similar, err = store.SimilarPosts(ctx, "Запуск ракеты", "", 0.3, 1)
+		require.NoError(t, err)
+		assert.Len(t, similar, 1)
+
+		similar, err = store.SimilarPosts(ctx, "Рецепт борща", "", 0.3, 10)
+		require.NoError(t, err)
+		assert.Empty(t, similar)
+	})
+
+	t.Run("CountDescendants", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		start := baseTime()
+		post := newPost(start)
+		require.NoError(t, store.CreatePost(ctx, post))
+		root := newComment(post.ID, nil, start)
+		require.NoError(t, store.CreateComment(ctx, root))
+		child := newComment(post.ID, &root.ID, start.Add(time.Second))
+		require.NoError(t, store.CreateComment(ctx, child))
+		grandchild := newComment(post.ID, &child.ID, start.Add(2*time.Second))
+		require.NoError(t, store.CreateComment(ctx, grandchild))
+		// Скрытый ответ не учитывается вместе со своими ответами
+		hidden := newComment(post.ID, &root.ID, start.Add(3*time.Second))
+		hidden.Hidden = true
+		require.NoError(t, store.CreateComment(ctx, hidden))
+		require.NoError(t, store.CreateComment(ctx, newComment(post.ID, &hidden.ID, start.Add(4*time.Second))))
+
+		unknown := uuid.New().String()
+		counts, err := store.CountDescendants(ctx, []string{root.ID, child.ID, grandchild.ID, unknown})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{root.ID: 2, child.ID: 1, grandchild.ID: 0, unknown: 0}, counts)
+	})
+
+	t.Run("Visibility", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		start := baseTime()
+		posts := make(map[string]*models.Post)
+		for i, visibility := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityFollowers, models.VisibilityPrivate} {
+			post := newPost(start.Add(time.Duration(i) * time.Second))
+			post.Visibility = visibility
+			require.NoError(t, store.CreatePost(ctx, post))
+			require.NoError(t, store.CreateComment(ctx, newComment(post.ID, nil, start.Add(time.Minute))))
+			posts[visibility] = post
+		}
+		stored, err := store.GetPost(ctx, posts[models.VisibilityFollowers].ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.VisibilityFollowers, stored.Visibility)
+
+		listed := func(viewerID string) []string {
+			page, err := store.ListPosts(storage.WithViewer(ctx, viewerID), 10, nil, storage.PostFilter{})
+			require.NoError(t, err)
+			visibilities := make([]string, 0, len(page.Posts))
+			for _, post := range page.Posts {
+				visibilities = append(visibilities, post.Visibility)
+			}
+			return visibilities
+		}
+		assert.Equal(t, []string{models.VisibilityPublic}, listed(""))
+		assert.Equal(t, []string{models.VisibilityPublic}, listed("user2"))
+		assert.Equal(t, []string{models.VisibilityPrivate, models.VisibilityFollowers, models.VisibilityUnlisted, models.VisibilityPublic}, listed("user1"))
+
+		following, err := store.IsFollowing(ctx, "user2", "user1")
+		require.NoError(t, err)
+		assert.False(t, following)
+		require.NoError(t, store.SetFollow(ctx, "user2", "user1", true))
+		require.NoError(t, store.SetFollow(ctx, "user2", "user1", true))
+		following, err = store.IsFollowing(ctx, "user2", "user1")
+		require.NoError(t, err)
+		assert.True(t, following)
+		assert.Equal(t, []string{models.VisibilityFollowers, models.VisibilityPublic}, listed("user2"))
+
+		// Комментарии поста, который зритель не видит, не возвращаются; UNLISTED виден по ID
+		comments := func(viewerID, visibility string) int {
+			page, err := store.GetComments(storage.WithViewer(ctx, viewerID), posts[visibility].ID, nil, 10, nil, models.SortDesc)
+			require.NoError(t, err)
+			return len(page.Comments)
+		}
+		assert.Equal(t, 1, comments("", models.VisibilityUnlisted))
+		assert.Equal(t, 1, comments("user2", models.VisibilityFollowers))
+		assert.Equal(t, 0, comments("user3", models.VisibilityFollowers))
+		assert.Equal(t, 0, comments("user2", models.VisibilityPrivate))
+		assert.Equal(t, 1, comments("user1", models.VisibilityPrivate))
+
+		require.NoError(t, store.SetFollow(ctx, "user2", "user1", false))
+		assert.Equal(t, []string{models.VisibilityPublic}, listed("user2"))
+		assert.Equal(t, 0, comments("user2", models.VisibilityFollowers))
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		require.NoError(t, store.CreatePost(ctx, newPost(baseTime())))
+		stats, err := store.Stats(ctx, 5)
+		require.NoError(t, err)
+		assert.NotEmpty(t, stats.Backend)
+		names := make([]string, 0, len(stats.Tables))
+		for _, table := range stats.Tables {
+			names = append(names, table.Name)
+			assert.GreaterOrEqual(t, table.Rows, int64(0))
+		}
+		assert.Contains(t, names, "posts")
+		assert.LessOrEqual(t, len(stats.SlowQueries), 5)
+		if !stats.SlowQueriesAvailable {
+			assert.Empty(t, stats.SlowQueries)
+		}
+	})
+
+	t.Run("Collections", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		var posts []string
+		for i := 0; i < 3; i++ {
+			post := newPost(baseTime().Add(time.Duration(i) * time.Second))
+			require.NoError(t, store.CreatePost(ctx, post))
+			posts = append(posts, post.ID)
+		}
+		_, err := store.GetCollection(ctx, "missing")
+		assert.ErrorIs(t, err, storage.ErrCollectionNotFound)
+
+		created := baseTime()
+		series := &models.Collection{ID: uuid.New().String(), AuthorID: "user1", Title: "Серия", Description: "Описание",
+			PostIDs: []string{posts[2], posts[0]}, CreatedAt: created, UpdatedAt: created}
+		require.NoError(t, store.CreateCollection(ctx, series))
+		other := &models.Collection{ID: uuid.New().String(), AuthorID: "user2", Title: "Другая", PostIDs: []string{},
+			CreatedAt: created.Add(time.Second), UpdatedAt: created.Add(time.Second)}
+		require.NoError(t, store.CreateCollection(ctx, other))
+		got, err := store.GetCollection(ctx, series.ID)
+		require.NoError(t, err)
+		assert.Equal(t, series, got)
+		got, err = store.GetCollection(ctx, other.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.PostIDs)
+
+		updated := *series
+		updated.Title = "Новая серия"
+		updated.PostIDs = []string{posts[0], posts[1], posts[2]}
+		updated.UpdatedAt = created.Add(time.Minute)
+		require.NoError(t, store.UpdateCollection(ctx, &updated))
+		got, err = store.GetCollection(ctx, series.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &updated, got, "Список постов заменяется в новом порядке")
+
+		other.PostIDs = []string{posts[1]}
+		require.NoError(t, store.UpdateCollection(ctx, other))
+		collections, err := store.ListPostCollections(ctx, posts[1])
+		require.NoError(t, err)
+		require.Len(t, collections, 2)
+		assert.Equal(t, series.ID, collections[0].ID, "Серии упорядочены по времени создания")
+		assert.Equal(t, []string{posts[1]}, collections[1].PostIDs)
+		collections, err = store.ListPostCollections(ctx, posts[2])
+		require.NoError(t, err)
+		require.Len(t, collections, 1)
+
+		bad := updated
+		bad.PostIDs = []string{posts[0], "missing"}
+		assert.ErrorIs(t, store.UpdateCollection(ctx, &bad), storage.ErrPostNotFound)
+		got, err = store.GetCollection(ctx, series.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updated.PostIDs, got.PostIDs, "Неудачное изменение не меняет серию")
+		bad.ID = "missing"
+		bad.PostIDs = nil
+		assert.ErrorIs(t, store.UpdateCollection(ctx, &bad), storage.ErrCollectionNotFound)
+		assert.ErrorIs(t, store.CreateCollection(ctx, &models.Collection{ID: uuid.New().String(), AuthorID: "user1", PostIDs: []string{"missing"}, CreatedAt: created, UpdatedAt: created}), storage.ErrPostNotFound)
+
+		require.NoError(t, store.DeleteCollection(ctx, series.ID))
+		assert.ErrorIs(t, store.DeleteCollection(ctx, series.ID), storage.ErrCollectionNotFound)
+		collections, err = store.ListPostCollections(ctx, posts[2])
+		require.NoError(t, err)
+		assert.Empty(t, collections)
+	})
+
+	t.Run("Short IDs", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		post := newPost(baseTime())
+		require.NoError(t, store.CreatePost(ctx, post))
+		comment := newComment(post.ID, nil, baseTime())
+		require.NoError(t, store.CreateComment(ctx, comment))
+
+		posts, err := store.ShortIDs(ctx, models.TargetPost, []string{post.ID, post.ID})
+		require.NoError(t, err)
+		require.Len(t, posts, 1)
+		comments, err := store.ShortIDs(ctx, models.TargetComment, []string{comment.ID})
+		require.NoError(t, err)
+		assert.Greater(t, comments[comment.ID], posts[post.ID], "Номера выдаются при создании из общей последовательности")
+		unknown := uuid.New().String()
+		missing, err := store.ShortIDs(ctx, models.TargetPost, []string{unknown})
+		require.NoError(t, err)
+		assert.Empty(t, missing, "Чтение не выдаёт номеров")
+		later := newPost(baseTime().Add(time.Second))
+		require.NoError(t, store.CreatePost(ctx, later))
+		latest, err := store.ShortIDs(ctx, models.TargetPost, []string{later.ID})
+		require.NoError(t, err)
+		assert.Greater(t, latest[later.ID], comments[comment.ID])
+		again, err := store.ShortIDs(ctx, models.TargetPost, []string{post.ID})
+		require.NoError(t, err)
+		assert.Equal(t, posts, again, "Выданный номер не меняется")
+		empty, err := store.ShortIDs(ctx, models.TargetPost, nil)
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+
+		kind, id, err := store.ResolveShortID(ctx, posts[post.ID])
+		require.NoError(t, err)
+		assert.Equal(t, models.TargetPost, kind)
+		assert.Equal(t, post.ID, id)
+		kind, id, err = store.ResolveShortID(ctx, comments[comment.ID])
+		require.NoError(t, err)
+		assert.Equal(t, models.TargetComment, kind)
+		assert.Equal(t, comment.ID, id)
+		_, _, err = store.ResolveShortID(ctx, comments[comment.ID]+100)
+		assert.ErrorIs(t, err, storage.ErrShortIDNotFound)
+	})
+
+	t.Run("IteratePosts and IterateComments", func(t *testing.T) {
+		store := factory(t)
+		ctx := context.Background()
+		base := baseTime()
+		later := newPost(base.Add(time.Minute))
+		earlier := newPost(base)
+		earlier.Hidden = true
+		earlier.Visibility = models.VisibilityPrivate
+		require.NoError(t, store.CreatePost(ctx, later))
+		require.NoError(t, store.CreatePost(ctx, earlier))
+		root := newComment(later.ID, nil, base.Add(2*time.Minute))
+		reply := newComment(later.ID, &root.ID, base.Add(3*time.Minute))
+		reply.Hidden = true
+		other := newComment(earlier.ID, nil, base.Add(time.Minute))
+		for _, comment := range []*models.Comment{reply, root, other} {
+			require.NoError(t, store.CreateComment(ctx, comment))
+		}
+
+		var postIDs []string
+		require.NoError(t, store.IteratePosts(ctx, func(post *models.Post) error {
+			postIDs = append(postIDs, post.ID)
+			return nil
+		}))
+		assert.Equal(t, []string{earlier.ID, later.ID}, postIDs, "Скрытые посты тоже возвращаются, по времени создания")
+
+		var comments []*models.Comment
+		require.NoError(t, store.IterateComments(ctx, later.ID, func(comment *models.Comment) error {
+			comments = append(comments, comment)
+			return nil
+		}))
+		require.Len(t, comments, 2)
+		assertComment(t, root, comments[0])
+		assertComment(t, reply, comments[1])
+		assert.Equal(t, &root.ID, comments[1].ParentID)
+
+		var all []string
+		require.NoError(t, store.IterateComments(ctx, "", func(comment *models.Comment) error {
+			all = append(all, comment.ID)
+			return nil
+		}))
+		assert.Equal(t, []string{other.ID, root.ID, reply.ID}, all)
+
+		stop := errors.New("stop")
+		calls := 0
+		err := store.IteratePosts(ctx, func(post *models.Post) error {
+			calls++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop, "Ошибка fn прекращает обход")
+		assert.Equal(t, 1, calls)
+
+		require.NoError(t, store.IterateComments(ctx, "missing", func(comment *models.Comment) error {
+			t.Fatal("Для несуществующего поста fn не вызывается")
+			return nil
+		}))
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		store := factory(t)
+		assert.NoError(t, store.Ping(context.Background()))
+	})
+}
+
+// baseTime возвращает текущее время с точностью, которую сохраняют все бэкенды
+func baseTime() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func newPost(createdAt time.Time) *models.Post {
+	return &models.Post{
+		ID:            uuid.New().String(),
+		Title:         "Пост",
+		Content:       "Содержимое",
+		Format:        models.FormatPlain,
+		AuthorID:      "user1",
+		AllowComments: true,
+		CreatedAt:     createdAt,
+	}
+}
+
+func newComment(postID string, parentID *string, createdAt time.Time) *models.Comment {
+	return &models.Comment{
+		ID:        uuid.New().String(),
+		PostID:    postID,
+		ParentID:  parentID,
+		AuthorID:  "user1",
+		Content:   "Комментарий " + uuid.New().String(),
+		Format:    models.FormatPlain,
+		CreatedAt: createdAt,
+		Language:  "ru",
+	}
+}
+
+// key - ключ сортировки элемента ленты
+type key struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+// expectedOrder возвращает ID в порядке (createdAt, id), который должны соблюдать все бэкенды
+func expectedOrder(keys []key, order models.SortOrder) []string {
+	sorted := make([]key, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if order == models.SortDesc {
+			a, b = b, a
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+	ids := make([]string, len(sorted))
+	for i, k := range sorted {
+		ids[i] = k.ID
+	}
+	return ids
+}
+
+func assertPost(t *testing.T, want, got *models.Post) {
+	t.Helper()
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.Title, got.Title)
+	assert.Equal(t, want.Content, got.Content)
+	assert.Equal(t, want.Format, got.Format)
+	assert.Equal(t, want.AuthorID, got.AuthorID)
+	assert.Equal(t, want.AllowComments, got.AllowComments)
+	assert.Equal(t, want.Language, got.Language)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "createdAt: ожидалось %v, получено %v", want.CreatedAt, got.CreatedAt)
+}
+
+func assertComment(t *testing.T, want, got *models.Comment) {
+	t.Helper()
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.PostID, got.PostID)
+	assert.Equal(t, want.ParentID, got.ParentID)
+	assert.Equal(t, want.AuthorID, got.AuthorID)
+	assert.Equal(t, want.Content, got.Content)
+	assert.Equal(t, want.Format, got.Format)
+	assert.Equal(t, want.Language, got.Language)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "createdAt: ожидалось %v, получено %v", want.CreatedAt, got.CreatedAt)
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ButyrinIA/system/internal/models"
+)
+
+// viewerKey - ключ контекста с ID пользователя, от имени которого читается содержимое
+type viewerKey struct{}
+
+// WithViewer возвращает контекст, в котором ListPosts и GetComments возвращают скрытое содержимое viewerID.
+// Без зрителя скрытое содержимое не возвращается никому
+func WithViewer(ctx context.Context, viewerID string) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewerID)
+}
+
+// Viewer возвращает ID зрителя из контекста или пустую строку
+func Viewer(ctx context.Context) string {
+	viewerID, _ := ctx.Value(viewerKey{}).(string)
+	return viewerID
+}
+
+// estimateKey - ключ контекста, разрешающего приблизительные счётчики
+type estimateKey struct{}
+
+// WithEstimatedCounts возвращает контекст, в котором GetComments может вернуть приблизительный TotalCount:
+// только видимые всем комментарии по поддерживаемому счётчику, без дорогого досчёта скрытых комментариев зрителя.
+// Хранилище, которое воспользовалось разрешением, отмечает результат Estimated
+func WithEstimatedCounts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, estimateKey{}, true)
+}
+
+// EstimatedCounts сообщает, разрешены ли в контексте приблизительные счётчики
+func EstimatedCounts(ctx context.Context) bool {
+	estimated, _ := ctx.Value(estimateKey{}).(bool)
+	return estimated
+}
+
+// CommentVisibleTo сообщает, видит ли пользователь viewerID комментарий: скрытый комментарий виден только автору
+func CommentVisibleTo(comment *models.Comment, viewerID string) bool {
+	return !comment.Hidden || viewerID != "" && comment.AuthorID == viewerID
+}
+
+// PostVisibleTo сообщает, видит ли пользователь viewerID пост, не проверяя его подписки: автор видит свои
+// посты всегда, остальные - не скрытые посты PUBLIC и UNLISTED. Пост FOLLOWERS с учётом подписки проверяет ViewPost
+func PostVisibleTo(post *models.Post, viewerID string) bool {
+	return PostVisibleToFollower(post, viewerID, false)
+}
+
+// PostVisibleToFollower сообщает, видит ли пост пользователь viewerID, который подписан (following)
+// или не подписан на автора поста
+func PostVisibleToFollower(post *models.Post, viewerID string, following bool) bool {
+	if viewerID != "" && post.AuthorID == viewerID {
+		return true
+	}
+	if post.Hidden {
+		return false
+	}
+	switch post.Visibility {
+	case models.VisibilityPrivate:
+		return false
+	case models.VisibilityFollowers:
+		return following
+	default:
+		return true
+	}
+}
+
+// PostListedTo сообщает, попадает ли пост в списки постов пользователя viewerID: как PostVisibleToFollower,
+// но пост UNLISTED в списках видит только автор
+func PostListedTo(post *models.Post, viewerID string, following bool) bool {
+	if post.Visibility == models.VisibilityUnlisted && (viewerID == "" || post.AuthorID != viewerID) {
+		return false
+	}
+	return PostVisibleToFollower(post, viewerID, following)
+}
+
+// ViewPost возвращает пост id, если его видит пользователь viewerID, иначе ErrPostNotFound:
+// для поста FOLLOWERS проверяется подписка зрителя на автора
+func ViewPost(ctx context.Context, store Storage, id, viewerID string) (*models.Post, error) {
+	post, err := store.GetPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckPostVisible(ctx, store, post, viewerID); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// ViewComment возвращает комментарий id, если пользователь viewerID видит и сам комментарий, и его пост,
+// иначе ErrCommentNotFound
+func ViewComment(ctx context.Context, store Storage, id, viewerID string) (*models.Comment, error) {
+	comment, err := store.GetComment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !CommentVisibleTo(comment, viewerID) {
+		return nil, ErrCommentNotFound
+	}
+	if _, err := ViewPost(ctx, store, comment.PostID, viewerID); errors.Is(err, ErrPostNotFound) {
+		return nil, ErrCommentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// CheckPostVisible возвращает ErrPostNotFound, если пользователь viewerID не видит уже загруженный пост
+func CheckPostVisible(ctx context.Context, store Storage, post *models.Post, viewerID string) error {
+	following := false
+	if post.Visibility == models.VisibilityFollowers && viewerID != "" && viewerID != post.AuthorID && !post.Hidden {
+		var err error
+		if following, err = store.IsFollowing(ctx, viewerID, post.AuthorID); err != nil {
+			return err
+		}
+	}
+	if !PostVisibleToFollower(post, viewerID, following) {
+		return ErrPostNotFound
+	}
+	return nil
+}
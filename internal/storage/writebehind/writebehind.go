@@ -0,0 +1,449 @@
+// Package writebehind сглаживает всплески вставок комментариев: CreateComment записывает комментарий
+// в журнал на диске и ставит его в ограниченную очередь, а фоновый обработчик сохраняет очередь
+// в хранилище пачками через CreateComments. Если очередь заполнена, комментарий пишется сразу.
+//
+// Журнал защищает подтверждённые комментарии от падения процесса: при запуске комментарии из журнала,
+// которых нет в хранилище, сохраняются повторно, а после записи каждой пачки журнал переписывается
+// оставшейся очередью. Журнал принадлежит одному процессу и не должен использоваться несколькими экземплярами.
+//
+// Пока комментарий в очереди, его возвращают GetComment и GetCommentsByIDs, но не списки комментариев.
+// Существование поста проверяется до постановки в очередь. Ошибки, которые хранилище находит при записи
+// пачки, вызывающий уже не получает: окончательно отклонённый комментарий отбрасывается, а временные
+// ошибки приводят к повторной записи. Поэтому буфер не сочетается с дедупликацией комментариев хранилища:
+// повтор нашёлся бы только после подтверждения, и конфигурация такое сочетание отклоняет
+package writebehind
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Options задаёт параметры буфера; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// QueueSize - сколько комментариев может ждать записи; сверх него комментарии пишутся синхронно
+	QueueSize int
+	// FlushInterval - наибольшее время ожидания комментария в очереди; MaxBatch - размер пачки записи
+	FlushInterval time.Duration
+	MaxBatch      int
+	// JournalPath - файл журнала; пустой путь отключает защиту от падения процесса
+	JournalPath string
+	// Clock - источник времени приёма и сохранения комментария для метрики задержки, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 50 * time.Millisecond
+	}
+	if o.MaxBatch <= 0 {
+		o.MaxBatch = 100
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// entry - комментарий в очереди и время его приёма для метрики задержки
+type entry struct {
+	comment    *models.Comment
+	acceptedAt time.Time
+}
+
+// Storage - обёртка над хранилищем с отложенной записью комментариев
+type Storage struct {
+	storage.Storage
+	opts Options
+
+	// mu защищает очередь, индекс ожидающих комментариев и запись в журнал
+	mu      sync.Mutex
+	queue   []entry
+	pending map[string]*models.Comment
+	journal *os.File
+	// flushMu не даёт двум записям взять одну и ту же пачку
+	flushMu sync.Mutex
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var _ storage.Storage = &Storage{}
+
+// New оборачивает хранилище отложенной записью, сохраняет комментарии, оставшиеся в журнале
+// после падения, и запускает фоновую запись
+func New(store storage.Storage, opts Options) (*Storage, error) {
+	opts = opts.withDefaults()
+	log.Printf("Создание буфера отложенной записи комментариев: очередь %d, интервал %v, пачка до %d, журнал %q",
+		opts.QueueSize, opts.FlushInterval, opts.MaxBatch, opts.JournalPath)
+	s := &Storage{
+		Storage: store,
+		opts:    opts,
+		pending: make(map[string]*models.Comment),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts.JournalPath != "" {
+		if err := s.recover(opts.JournalPath); err != nil {
+			return nil, err
+		}
+		journal, err := os.OpenFile(opts.JournalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open write-behind journal: %v", err)
+		}
+		s.journal = journal
+	}
+	go s.flusher()
+	return s, nil
+}
+
+// recover сохраняет комментарии из журнала, которых ещё нет в хранилище. Неполная последняя строка
+// остаётся от записи, прерванной падением: такой комментарий не был подтверждён и пропускается
+func (s *Storage) recover(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open write-behind journal: %v", err)
+	}
+	defer f.Close()
+	var comments []*models.Comment
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var comment models.Comment
+		if err := json.Unmarshal(scanner.Bytes(), &comment); err != nil {
+			log.Printf("Пропуск повреждённой записи журнала отложенной записи: %v", err)
+			continue
+		}
+		comments = append(comments, &comment)
+		ids = append(ids, comment.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read write-behind journal: %v", err)
+	}
+	if len(comments) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	existing, err := s.Storage.GetCommentsByIDs(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to check journaled comments: %v", err)
+	}
+	var missing []*models.Comment
+	for _, comment := range comments {
+		if _, ok := existing[comment.ID]; !ok {
+			missing = append(missing, comment)
+		}
+	}
+	if len(missing) == 0 {
+		log.Printf("Все %d комментариев из журнала отложенной записи уже сохранены", len(comments))
+		return nil
+	}
+	restored := 0
+	for i, err := range s.Storage.CreateComments(ctx, missing) {
+		switch {
+		case err == nil:
+			restored++
+		case permanent(err):
+			log.Printf("Комментарий %s из журнала отброшен: %v", missing[i].ID, err)
+		default:
+			return fmt.Errorf("failed to restore journaled comment %s: %v", missing[i].ID, err)
+		}
+	}
+	log.Printf("Восстановлено комментариев из журнала отложенной записи: %d из %d", restored, len(comments))
+	return nil
+}
+
+// CreateComment проверяет пост, записывает комментарий в журнал и ставит его в очередь; при заполненной
+// очереди или ошибке журнала комментарий сохраняется сразу
+func (s *Storage) CreateComment(ctx context.Context, comment *models.Comment) error {
+	accepted := s.opts.Clock.Now()
+	if _, err := s.Storage.GetPost(ctx, comment.PostID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if len(s.queue) >= s.opts.QueueSize {
+		s.mu.Unlock()
+		return s.createNow(ctx, comment, accepted)
+	}
+	if err := s.appendJournal(comment); err != nil {
+		s.mu.Unlock()
+		log.Printf("Ошибка записи комментария %s в журнал, сохранение без очереди: %v", comment.ID, err)
+		return s.createNow(ctx, comment, accepted)
+	}
+	comment.PrecomputeTimes()
+	s.queue = append(s.queue, entry{comment: comment, acceptedAt: accepted})
+	s.pending[comment.ID] = comment
+	depth := len(s.queue)
+	journal := s.journal
+	s.mu.Unlock()
+	metrics.WriteBehindWrites.WithLabelValues("buffered").Inc()
+	metrics.WriteBehindQueueDepth.Set(float64(depth))
+	if depth >= s.opts.MaxBatch {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	syncJournal(journal)
+	return nil
+}
+
+// createNow сохраняет комментарий в обход очереди
+func (s *Storage) createNow(ctx context.Context, comment *models.Comment, accepted time.Time) error {
+	metrics.WriteBehindWrites.WithLabelValues("sync").Inc()
+	err := s.Storage.CreateComment(ctx, comment)
+	metrics.WriteBehindLatency.WithLabelValues("sync").Observe(s.opts.Clock.Now().Sub(accepted).Seconds())
+	return err
+}
+
+// appendJournal дописывает комментарий в журнал; вызывается под s.mu
+func (s *Storage) appendJournal(comment *models.Comment) error {
+	if s.journal == nil {
+		return nil
+	}
+	line, err := json.Marshal(comment)
+	if err != nil {
+		return err
+	}
+	_, err = s.journal.Write(append(line, '\n'))
+	return err
+}
+
+// syncJournal сбрасывает журнал на диск. Выполняется вне блокировки, чтобы одновременные вставки
+// дожидались одного сброса, а не выстраивались в очередь за ним. Комментарий уже в очереди,
+// поэтому ошибка сброса означает только потерю защиты от падения. Если журнал успели переписать,
+// старый файл закрыт, а комментарий уже сброшен на диск в новом
+func syncJournal(journal *os.File) {
+	if journal == nil {
+		return
+	}
+	if err := journal.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+		log.Printf("Ошибка сброса журнала отложенной записи на диск: %v", err)
+	}
+}
+
+// GetComment возвращает комментарий из очереди или из хранилища
+func (s *Storage) GetComment(ctx context.Context, id string) (*models.Comment, error) {
+	s.mu.Lock()
+	comment, ok := s.pending[id]
+	s.mu.Unlock()
+	if ok {
+		return comment, nil
+	}
+	return s.Storage.GetComment(ctx, id)
+}
+
+// GetCommentsByIDs дополняет результат хранилища комментариями из очереди
+func (s *Storage) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*models.Comment, error) {
+	result, err := s.Storage.GetCommentsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if comment, ok := s.pending[id]; ok {
+			result[id] = comment
+		}
+	}
+	return result, nil
+}
+
+// Flush сохраняет все комментарии из очереди; возвращает ошибку, если часть осталась для повтора
+func (s *Storage) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	for {
+		written, err := s.flushBatch()
+		if err != nil || written == 0 {
+			return err
+		}
+	}
+}
+
+// Close сохраняет очередь, останавливает фоновую запись и закрывает обёрнутое хранилище.
+// Несохранённые комментарии остаются в журнале до следующего запуска
+func (s *Storage) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		if err := s.Flush(); err != nil {
+			log.Printf("Не все комментарии сохранены при остановке, они остаются в журнале: %v", err)
+		}
+		if s.journal != nil {
+			s.journal.Close()
+		}
+		log.Println("Буфер отложенной записи комментариев остановлен")
+	})
+	return s.Storage.Close()
+}
+
+// flusher сохраняет очередь раз в FlushInterval или сразу, когда набралась пачка
+func (s *Storage) flusher() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-s.stop:
+			return
+		}
+		if err := s.Flush(); err != nil {
+			log.Printf("Ошибка записи очереди комментариев, повтор через %v: %v", s.opts.FlushInterval, err)
+		}
+	}
+}
+
+// flushBatch сохраняет одну пачку из начала очереди и возвращает число сохранённых комментариев.
+// Комментарии с временными ошибками возвращаются в начало очереди
+func (s *Storage) flushBatch() (int, error) {
+	s.mu.Lock()
+	n := min(len(s.queue), s.opts.MaxBatch)
+	batch := append([]entry(nil), s.queue[:n]...)
+	s.mu.Unlock()
+	if n == 0 {
+		return 0, nil
+	}
+	comments := make([]*models.Comment, n)
+	for i, e := range batch {
+		comments[i] = e.comment
+	}
+	errs := s.Storage.CreateComments(context.Background(), comments)
+
+	var retry []entry
+	var lastErr error
+	written := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			written++
+			metrics.WriteBehindFlushed.WithLabelValues("ok").Inc()
+			metrics.WriteBehindLatency.WithLabelValues("buffered").Observe(s.opts.Clock.Now().Sub(batch[i].acceptedAt).Seconds())
+		case permanent(err):
+			log.Printf("Комментарий %s из очереди отброшен: %v", batch[i].comment.ID, err)
+			metrics.WriteBehindFlushed.WithLabelValues("dropped").Inc()
+		default:
+			retry = append(retry, batch[i])
+			lastErr = err
+			metrics.WriteBehindFlushed.WithLabelValues("retry").Inc()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Пока шла запись, очередь только пополнялась с конца, поэтому пачка всё ещё в её начале
+	s.queue = append(retry, s.queue[n:]...)
+	for _, e := range batch {
+		delete(s.pending, e.comment.ID)
+	}
+	for _, e := range retry {
+		s.pending[e.comment.ID] = e.comment
+	}
+	metrics.WriteBehindQueueDepth.Set(float64(len(s.queue)))
+	s.rotateJournal()
+	if lastErr != nil {
+		return written, lastErr
+	}
+	return n, nil
+}
+
+// rotateJournal убирает из журнала сохранённые комментарии после записи пачки, чтобы при постоянной
+// нагрузке он не рос без ограничений: пустой журнал очищается, иначе оставшаяся очередь записывается
+// во временный файл, который заменяет журнал. При ошибке журнал остаётся прежним, лишние записи
+// в нём безопасны, потому что при восстановлении сохранённые комментарии пропускаются. Вызывается под s.mu
+func (s *Storage) rotateJournal() {
+	if s.journal == nil {
+		return
+	}
+	if len(s.queue) == 0 {
+		s.truncateJournal()
+		return
+	}
+	if err := s.rewriteJournal(); err != nil {
+		log.Printf("Ошибка перезаписи журнала отложенной записи: %v", err)
+	}
+}
+
+// rewriteJournal заменяет журнал файлом с комментариями из очереди; вызывается под s.mu
+func (s *Storage) rewriteJournal() error {
+	path := s.opts.JournalPath
+	tmp, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range s.queue {
+		line, err := json.Marshal(e.comment)
+		if err == nil {
+			_, err = w.Write(append(line, '\n'))
+		}
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	journal, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		// Журнал уже заменён, но дописывать в него нечем: защита от падения отключается
+		s.journal.Close()
+		s.journal = nil
+		return err
+	}
+	s.journal.Close()
+	s.journal = journal
+	return nil
+}
+
+// truncateJournal очищает журнал, когда все комментарии из него сохранены; вызывается под s.mu
+func (s *Storage) truncateJournal() {
+	if err := s.journal.Truncate(0); err != nil {
+		log.Printf("Ошибка очистки журнала отложенной записи: %v", err)
+		return
+	}
+	if _, err := s.journal.Seek(0, 0); err != nil {
+		log.Printf("Ошибка очистки журнала отложенной записи: %v", err)
+	}
+}
+
+// permanent сообщает, что повторная запись комментария не поможет
+func permanent(err error) bool {
+	var duplicate *storage.DuplicateCommentError
+	return errors.Is(err, storage.ErrPostNotFound) || errors.As(err, &duplicate)
+}
@@ -0,0 +1,189 @@
+package writebehind
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPost(t *testing.T, store storage.Storage) *models.Post {
+	post := &models.Post{ID: uuid.New().String(), Title: "Пост", AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(context.Background(), post))
+	return post
+}
+
+func newComment(postID string) *models.Comment {
+	return &models.Comment{ID: uuid.New().String(), PostID: postID, AuthorID: "author", Content: uuid.New().String(), CreatedAt: time.Now()}
+}
+
+// latencySum возвращает сумму наблюдений writebehind_latency_seconds для способа записи mode
+func latencySum(t *testing.T, mode string) float64 {
+	var m dto.Metric
+	require.NoError(t, metrics.WriteBehindLatency.WithLabelValues(mode).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleSum()
+}
+
+func TestWriteBehind(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Buffered until flush", func(t *testing.T) {
+		inner := memory.New()
+		journal := filepath.Join(t.TempDir(), "comments.wal")
+		// Фоновая запись не срабатывает во время теста, очередь сохраняется явным Flush
+		store, err := New(inner, Options{FlushInterval: time.Hour, MaxBatch: 10, JournalPath: journal})
+		require.NoError(t, err)
+		post := newPost(t, store)
+		comments := []*models.Comment{newComment(post.ID), newComment(post.ID)}
+		for _, comment := range comments {
+			require.NoError(t, store.CreateComment(ctx, comment))
+		}
+		orphan := newComment(uuid.New().String())
+		err = store.CreateComment(ctx, orphan)
+		assert.ErrorIs(t, err, storage.ErrPostNotFound, "Пост проверяется до постановки в очередь")
+
+		got, err := store.GetComment(ctx, comments[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, comments[0].Content, got.Content, "Комментарий из очереди виден по ID")
+		page, err := inner.GetComments(ctx, post.ID, nil, 10, nil, models.SortAsc)
+		require.NoError(t, err)
+		assert.Empty(t, page.Comments, "До записи очереди хранилище не меняется")
+		data, err := os.ReadFile(journal)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "\n"), "Каждый принятый комментарий попадает в журнал")
+
+		require.NoError(t, store.Flush())
+		page, err = inner.GetComments(ctx, post.ID, nil, 10, nil, models.SortAsc)
+		require.NoError(t, err)
+		assert.Len(t, page.Comments, 2)
+		_, err = store.GetComment(ctx, orphan.ID)
+		assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+		data, err = os.ReadFile(journal)
+		require.NoError(t, err)
+		assert.Empty(t, data, "Журнал очищается после записи очереди")
+	})
+
+	t.Run("Journal rotated per batch", func(t *testing.T) {
+		inner := memory.New()
+		journal := filepath.Join(t.TempDir(), "comments.wal")
+		store, err := New(inner, Options{FlushInterval: time.Hour, MaxBatch: 2, QueueSize: 10, JournalPath: journal})
+		require.NoError(t, err)
+		post := newPost(t, store)
+		// Полная пачка будит фоновую запись: она ждёт, пока тест записывает пачки сам
+		store.flushMu.Lock()
+		comments := make([]*models.Comment, 3)
+		for i := range comments {
+			comments[i] = newComment(post.ID)
+			require.NoError(t, store.CreateComment(ctx, comments[i]))
+		}
+
+		written, err := store.flushBatch()
+		require.NoError(t, err)
+		assert.Equal(t, 2, written)
+		data, err := os.ReadFile(journal)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(data), "\n"), "Сохранённая пачка убирается из журнала")
+		assert.Contains(t, string(data), comments[2].ID)
+
+		last := newComment(post.ID)
+		require.NoError(t, store.CreateComment(ctx, last))
+		data, err = os.ReadFile(journal)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "\n"), "Новые комментарии дописываются в новый журнал")
+		assert.Contains(t, string(data), last.ID)
+		store.flushMu.Unlock()
+		require.NoError(t, store.Close())
+	})
+
+	t.Run("Latency measured by clock", func(t *testing.T) {
+		inner := memory.New()
+		clk := clock.NewFake(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+		store, err := New(inner, Options{FlushInterval: time.Hour, Clock: clk})
+		require.NoError(t, err)
+		post := newPost(t, store)
+		before := latencySum(t, "buffered")
+
+		require.NoError(t, store.CreateComment(ctx, newComment(post.ID)))
+		clk.Advance(3 * time.Second)
+		require.NoError(t, store.Flush())
+		assert.InDelta(t, 3, latencySum(t, "buffered")-before, 1e-9, "Задержка считается по часам буфера")
+		require.NoError(t, store.Close())
+	})
+
+	t.Run("Synchronous fallback when queue is full", func(t *testing.T) {
+		inner := memory.New()
+		store, err := New(inner, Options{QueueSize: 1, FlushInterval: time.Hour})
+		require.NoError(t, err)
+		post := newPost(t, store)
+		syncWrites := testutil.ToFloat64(metrics.WriteBehindWrites.WithLabelValues("sync"))
+
+		queued, direct := newComment(post.ID), newComment(post.ID)
+		require.NoError(t, store.CreateComment(ctx, queued))
+		require.NoError(t, store.CreateComment(ctx, direct))
+		_, err = inner.GetComment(ctx, direct.ID)
+		assert.NoError(t, err, "Сверх очереди комментарий сохраняется сразу")
+		_, err = inner.GetComment(ctx, queued.ID)
+		assert.ErrorIs(t, err, storage.ErrCommentNotFound)
+		assert.Equal(t, syncWrites+1, testutil.ToFloat64(metrics.WriteBehindWrites.WithLabelValues("sync")))
+
+		err = store.CreateComment(ctx, newComment(uuid.New().String()))
+		assert.ErrorIs(t, err, storage.ErrPostNotFound, "Синхронная запись возвращает ошибку хранилища")
+	})
+
+	t.Run("Journal restored after crash", func(t *testing.T) {
+		inner := memory.New()
+		post := newPost(t, inner)
+		saved, lost := newComment(post.ID), newComment(post.ID)
+		require.NoError(t, inner.CreateComment(ctx, saved))
+		journal := filepath.Join(t.TempDir(), "comments.wal")
+		var lines []byte
+		for _, comment := range []*models.Comment{saved, lost} {
+			line, err := json.Marshal(comment)
+			require.NoError(t, err)
+			lines = append(append(lines, line...), '\n')
+		}
+		// Последняя строка оборвана падением во время записи
+		lines = append(lines, []byte(`{"id":"broken","postId`)...)
+		require.NoError(t, os.WriteFile(journal, lines, 0o600))
+
+		store, err := New(inner, Options{FlushInterval: time.Hour, JournalPath: journal})
+		require.NoError(t, err)
+		defer store.Close()
+		page, err := inner.GetComments(ctx, post.ID, nil, 10, nil, models.SortAsc)
+		require.NoError(t, err)
+		require.Len(t, page.Comments, 2, "Сохранённый комментарий не дублируется")
+		assert.Equal(t, lost.ID, page.Comments[1].ID)
+		data, err := os.ReadFile(journal)
+		require.NoError(t, err)
+		assert.Empty(t, data)
+	})
+
+	t.Run("Background flush by batch size", func(t *testing.T) {
+		inner := memory.New()
+		store, err := New(inner, Options{FlushInterval: time.Hour, MaxBatch: 2})
+		require.NoError(t, err)
+		post := newPost(t, store)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, store.CreateComment(ctx, newComment(post.ID)))
+		}
+		assert.Eventually(t, func() bool {
+			page, err := inner.GetComments(ctx, post.ID, nil, 10, nil, models.SortAsc)
+			return err == nil && len(page.Comments) == 2
+		}, time.Second, 10*time.Millisecond, "Полная пачка записывается не дожидаясь интервала")
+	})
+}
@@ -0,0 +1,118 @@
+// Package storm распознаёт всплески нагрузки («штормы»): частота событий по ключу считается
+// в окнах фиксированной длины. Шторм начинается, когда за окно набирается Threshold событий,
+// и заканчивается только после окна, в котором событий меньше половины порога, чтобы режим
+// не переключался на каждом колебании нагрузки
+package storm
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/metrics"
+)
+
+// Options задаёт параметры детектора; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// Scope - метка метрики storm_active, например comment_writes
+	Scope string
+	// Threshold - число событий за окно, с которого начинается шторм
+	Threshold int
+	Window    time.Duration
+	Clock     clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.Threshold <= 0 {
+		o.Threshold = 100
+	}
+	if o.Window <= 0 {
+		o.Window = time.Second
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// rate - счётчик событий ключа в текущем окне
+type rate struct {
+	start  time.Time
+	count  int
+	active bool
+}
+
+// Detector отслеживает частоту событий по ключам. Безопасен для одновременного использования
+type Detector struct {
+	opts Options
+	mu   sync.Mutex
+	keys map[string]*rate
+}
+
+// New создаёт детектор
+func New(opts Options) *Detector {
+	opts = opts.withDefaults()
+	log.Printf("Создание детектора штормов %s: порог %d событий за %v", opts.Scope, opts.Threshold, opts.Window)
+	return &Detector{opts: opts, keys: make(map[string]*rate)}
+}
+
+// Observe учитывает событие по ключу key и сообщает, идёт ли по нему шторм с учётом этого события
+func (d *Detector) Observe(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.keys[key]
+	if !ok {
+		r = &rate{start: d.opts.Clock.Now()}
+		d.keys[key] = r
+	}
+	d.roll(key, r)
+	r.count++
+	if !r.active && r.count >= d.opts.Threshold {
+		r.active = true
+		metrics.StormActive.WithLabelValues(d.opts.Scope).Inc()
+		log.Printf("Начало шторма %s по ключу %q: %d событий за %v", d.opts.Scope, key, r.count, d.opts.Window)
+	}
+	return r.active
+}
+
+// Active сообщает, идёт ли шторм по ключу key, не учитывая новое событие
+func (d *Detector) Active(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.keys[key]
+	if !ok {
+		return false
+	}
+	d.roll(key, r)
+	return r.active
+}
+
+// Sweep забывает ключи без шторма, по которым не было событий дольше окна
+func (d *Detector) Sweep() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.opts.Clock.Now()
+	for key, r := range d.keys {
+		if !r.active && now.Sub(r.start) >= 2*d.opts.Window {
+			delete(d.keys, key)
+		}
+	}
+}
+
+// roll начинает новое окно, если текущее закончилось. Шторм заканчивается, если в закончившемся
+// окне было меньше половины порога событий или после него прошло целое окно без событий
+func (d *Detector) roll(key string, r *rate) {
+	now := d.opts.Clock.Now()
+	elapsed := now.Sub(r.start)
+	if elapsed < d.opts.Window {
+		return
+	}
+	if r.active && (elapsed >= 2*d.opts.Window || 2*r.count < d.opts.Threshold) {
+		r.active = false
+		metrics.StormActive.WithLabelValues(d.opts.Scope).Dec()
+		log.Printf("Конец шторма %s по ключу %q", d.opts.Scope, key)
+	}
+	r.start = now
+	r.count = 0
+}
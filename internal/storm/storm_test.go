@@ -0,0 +1,43 @@
+package storm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetector(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := New(Options{Scope: "test", Threshold: 4, Window: time.Second, Clock: clk})
+
+	for i := 0; i < 3; i++ {
+		assert.False(t, d.Observe("post"))
+	}
+	assert.True(t, d.Observe("post"), "Шторм начинается на пороговом событии")
+	assert.False(t, d.Active("other"), "Ключи считаются отдельно")
+
+	// В следующем окне событий не меньше половины порога: шторм продолжается
+	clk.Advance(time.Second)
+	assert.True(t, d.Observe("post"))
+	d.Observe("post")
+	clk.Advance(time.Second)
+	assert.True(t, d.Active("post"))
+
+	// Окно с одним событием завершает шторм
+	d.Observe("post")
+	clk.Advance(time.Second)
+	assert.False(t, d.Active("post"))
+
+	// После паузы дольше окна шторм заканчивается без новых событий
+	for i := 0; i < 4; i++ {
+		d.Observe("post")
+	}
+	clk.Advance(3 * time.Second)
+	assert.False(t, d.Active("post"))
+
+	clk.Advance(2 * time.Second)
+	d.Sweep()
+	assert.Empty(t, d.keys, "Затихшие ключи забываются")
+}
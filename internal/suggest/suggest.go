@@ -0,0 +1,295 @@
+// Package suggest подсказывает продолжения для строки поиска: заголовки постов, теги и авторов.
+// Подсказки ищутся в префиксном индексе в памяти, который строится обходом всех постов хранилища
+// и перестраивается в фоне по истечении RefreshInterval, поэтому запрос не обращается к хранилищу
+package suggest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Виды подсказок
+const (
+	KindTitle  = "TITLE"
+	KindTag    = "TAG"
+	KindAuthor = "AUTHOR"
+)
+
+// shortPrefix - длина префикса в символах, для которого лучшие подсказки рассчитываются заранее:
+// коротким префиксам соответствует большая часть индекса
+const shortPrefix = 2
+
+// ErrUnknownKind возвращается для неизвестного вида подсказок
+var ErrUnknownKind = errors.New("unknown suggestion kind")
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// RefreshInterval - как долго используется построенный индекс до перестроения в фоне;
+	// новые и изменённые посты попадают в подсказки после перестроения
+	RefreshInterval time.Duration
+	// MaxLimit - наибольшее число подсказок в ответе; большие limit ограничиваются им
+	MaxLimit int
+	// Clock - источник времени для срока жизни индекса, по умолчанию системные часы
+	Clock clock.Clock
+}
+
+func (o Options) withDefaults() Options {
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = time.Minute
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 10
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	return o
+}
+
+// Suggestion - подсказка: текст для строки поиска и число постов с ним
+type Suggestion struct {
+	Text string
+	Kind string
+	// Count - число постов с тегом или автором; для заголовка 1
+	Count int
+	// PostID - пост с заголовком Text; пуст для тегов и авторов
+	PostID string
+}
+
+// entry - подсказка в индексе под ключом key в нижнем регистре
+type entry struct {
+	key        string
+	suggestion *Suggestion
+	// rank - место подсказки при сортировке по убыванию Count, свежести и тексту
+	rank int
+}
+
+// kindIndex - подсказки одного вида
+type kindIndex struct {
+	// entries упорядочены по key для поиска диапазона с префиксом
+	entries []entry
+	// short - лучшие MaxLimit подсказок для каждого префикса не длиннее shortPrefix
+	short map[string][]*Suggestion
+}
+
+// index - построенный индекс всех видов подсказок
+type index struct {
+	kinds   map[string]*kindIndex
+	builtAt time.Time
+}
+
+// Service отвечает на запросы подсказок
+type Service struct {
+	store storage.Storage
+	opts  Options
+	// current - последний построенный индекс; nil до первого построения
+	current atomic.Pointer[index]
+	// building не даёт запустить несколько фоновых перестроений сразу
+	building atomic.Bool
+	// mu сериализует первое построение индекса
+	mu sync.Mutex
+}
+
+// New создаёт сервис подсказок поверх хранилища; индекс строится при первом запросе
+func New(store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Suggest Service: интервал перестроения=%v, подсказок не больше %d", opts.RefreshInterval, opts.MaxLimit)
+	return &Service{store: store, opts: opts}
+}
+
+// Suggest возвращает до limit подсказок вида kind, начинающихся с prefix без учёта регистра.
+// Заголовки подсказываются и с начала любого слова. Подсказки упорядочены по убыванию числа постов,
+// затем от новых постов к старым
+func (s *Service) Suggest(ctx context.Context, prefix, kind string, limit int) ([]Suggestion, error) {
+	if kind != KindTitle && kind != KindTag && kind != KindAuthor {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+	if limit > s.opts.MaxLimit {
+		limit = s.opts.MaxLimit
+	}
+	idx, err := s.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	result := []Suggestion{}
+	if prefix == "" || limit <= 0 {
+		return result, nil
+	}
+	ki := idx.kinds[kind]
+	if utf8.RuneCountInString(prefix) <= shortPrefix {
+		for _, suggestion := range ki.short[prefix][:min(limit, len(ki.short[prefix]))] {
+			result = append(result, *suggestion)
+		}
+		return result, nil
+	}
+	start, _ := slices.BinarySearchFunc(ki.entries, prefix, func(e entry, prefix string) int {
+		return cmp.Compare(e.key, prefix)
+	})
+	var matches []entry
+	for _, e := range ki.entries[start:] {
+		if !strings.HasPrefix(e.key, prefix) {
+			break
+		}
+		matches = append(matches, e)
+	}
+	slices.SortFunc(matches, func(a, b entry) int { return cmp.Compare(a.rank, b.rank) })
+	for _, e := range matches {
+		if len(result) == limit {
+			break
+		}
+		// Заголовок из нескольких слов с префиксом попадает в диапазон несколько раз
+		if !slices.ContainsFunc(result, func(s Suggestion) bool { return s == *e.suggestion }) {
+			result = append(result, *e.suggestion)
+		}
+	}
+	return result, nil
+}
+
+// index возвращает текущий индекс. Первый запрос строит индекс сам, устаревший индекс
+// перестраивается в фоне, а запросы до конца перестроения получают прежний
+func (s *Service) index(ctx context.Context) (*index, error) {
+	if idx := s.current.Load(); idx != nil {
+		if s.opts.Clock.Now().Sub(idx.builtAt) >= s.opts.RefreshInterval && s.building.CompareAndSwap(false, true) {
+			go func() {
+				defer s.building.Store(false)
+				if err := s.Rebuild(context.Background()); err != nil {
+					log.Printf("Не удалось перестроить индекс подсказок, используется прежний: %v", err)
+				}
+			}()
+		}
+		return idx, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx := s.current.Load(); idx != nil {
+		return idx, nil
+	}
+	if err := s.Rebuild(ctx); err != nil {
+		return nil, err
+	}
+	return s.current.Load(), nil
+}
+
+// Rebuild строит индекс заново обходом всех постов. В подсказки попадают только публичные
+// нескрытые посты, чтобы подсказки не раскрывали заголовки, теги и авторов недоступных постов
+func (s *Service) Rebuild(ctx context.Context) error {
+	started := time.Now()
+	titles := map[string]*Suggestion{}
+	tags := map[string]*Suggestion{}
+	authors := map[string]*Suggestion{}
+	// latest - время создания самого нового поста подсказки для упорядочивания
+	latest := map[*Suggestion]time.Time{}
+	add := func(values map[string]*Suggestion, key string, suggestion Suggestion, createdAt time.Time) {
+		existing, ok := values[key]
+		if !ok {
+			existing = &suggestion
+			values[key] = existing
+		}
+		existing.Count++
+		if createdAt.After(latest[existing]) {
+			latest[existing] = createdAt
+		}
+	}
+	err := s.store.IteratePosts(ctx, func(post *models.Post) error {
+		if post.Hidden || (post.Visibility != "" && post.Visibility != models.VisibilityPublic) {
+			return nil
+		}
+		add(titles, post.ID, Suggestion{Text: post.Title, Kind: KindTitle, PostID: post.ID}, post.CreatedAt)
+		for _, tag := range post.Tags {
+			add(tags, strings.ToLower(tag), Suggestion{Text: tag, Kind: KindTag}, post.CreatedAt)
+		}
+		add(authors, post.AuthorID, Suggestion{Text: post.AuthorID, Kind: KindAuthor}, post.CreatedAt)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to iterate posts: %v", err)
+	}
+	idx := &index{
+		kinds: map[string]*kindIndex{
+			KindTitle:  s.build(titles, latest, wordStarts),
+			KindTag:    s.build(tags, latest, wholeText),
+			KindAuthor: s.build(authors, latest, wholeText),
+		},
+		builtAt: s.opts.Clock.Now(),
+	}
+	s.current.Store(idx)
+	log.Printf("Индекс подсказок построен за %v: заголовков %d, тегов %d, авторов %d",
+		time.Since(started), len(titles), len(tags), len(authors))
+	return nil
+}
+
+// build упорядочивает подсказки одного вида и раскладывает их по ключам из keys
+func (s *Service) build(values map[string]*Suggestion, latest map[*Suggestion]time.Time, keys func(text string) []string) *kindIndex {
+	ranked := make([]*Suggestion, 0, len(values))
+	for _, suggestion := range values {
+		ranked = append(ranked, suggestion)
+	}
+	slices.SortFunc(ranked, func(a, b *Suggestion) int {
+		return cmp.Or(
+			cmp.Compare(b.Count, a.Count),
+			latest[b].Compare(latest[a]),
+			cmp.Compare(a.Text, b.Text),
+			cmp.Compare(a.PostID, b.PostID),
+		)
+	})
+	ki := &kindIndex{short: map[string][]*Suggestion{}}
+	for rank, suggestion := range ranked {
+		for _, key := range keys(suggestion.Text) {
+			ki.entries = append(ki.entries, entry{key: key, suggestion: suggestion, rank: rank})
+			for n := 1; n <= shortPrefix && n <= utf8.RuneCountInString(key); n++ {
+				short := prefixRunes(key, n)
+				if len(ki.short[short]) < s.opts.MaxLimit && !slices.Contains(ki.short[short], suggestion) {
+					ki.short[short] = append(ki.short[short], suggestion)
+				}
+			}
+		}
+	}
+	slices.SortFunc(ki.entries, func(a, b entry) int { return cmp.Compare(a.key, b.key) })
+	return ki
+}
+
+// wholeText - ключ подсказки: весь текст в нижнем регистре
+func wholeText(text string) []string {
+	return []string{strings.ToLower(text)}
+}
+
+// wordStarts - ключи подсказки: текст в нижнем регистре с начала каждого слова
+func wordStarts(text string) []string {
+	lower := strings.ToLower(text)
+	var keys []string
+	inWord := false
+	for i, r := range lower {
+		space := unicode.IsSpace(r)
+		if !space && !inWord {
+			keys = append(keys, lower[i:])
+		}
+		inWord = !space
+	}
+	return keys
+}
+
+// prefixRunes возвращает первые n символов s
+func prefixRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package suggest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func texts(suggestions []Suggestion) []string {
+	result := []string{}
+	for _, s := range suggestions {
+		result = append(result, s.Text)
+	}
+	return result
+}
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	base := time.Now().Add(-time.Hour)
+	add := func(id, title, author string, minute int, tags []string, hidden bool, visibility string) {
+		require.NoError(t, store.CreatePost(ctx, &models.Post{
+			ID: id, Title: title, Content: "Содержимое", AuthorID: author, AllowComments: true,
+			CreatedAt: base.Add(time.Duration(minute) * time.Minute), Tags: tags, Hidden: hidden, Visibility: visibility,
+		}))
+	}
+	add("p1", "Запуск ракеты", "alice", 1, []string{"Космос", "go"}, false, "")
+	add("p2", "Новый запуск Зонда", "bob", 2, []string{"космос"}, false, models.VisibilityPublic)
+	add("p3", "Запас прочности", "alice", 3, []string{"golang"}, false, "")
+	add("p4", "Запуск по секрету", "carol", 4, []string{"закрытое"}, false, models.VisibilityPrivate)
+	add("p5", "Запуск скрыт", "dave", 5, []string{"скрытое"}, true, "")
+
+	clk := clock.NewFake(time.Now())
+	service := New(store, Options{MaxLimit: 3, Clock: clk})
+
+	suggestions, err := service.Suggest(ctx, "ЗАПУ", KindTitle, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Новый запуск Зонда", "Запуск ракеты"}, texts(suggestions), "Заголовок подсказывается с начала любого слова, новые выше")
+	assert.Equal(t, "p2", suggestions[0].PostID)
+	assert.Equal(t, 1, suggestions[0].Count)
+
+	suggestions, err = service.Suggest(ctx, "за", KindTitle, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Запас прочности", "Новый запуск Зонда", "Запуск ракеты"}, texts(suggestions), "Короткий префикс берётся из заранее рассчитанных подсказок")
+	suggestions, err = service.Suggest(ctx, "за", KindTitle, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Запас прочности"}, texts(suggestions))
+
+	suggestions, err = service.Suggest(ctx, "ко", KindTag, 5)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1, "Теги сравниваются без учёта регистра")
+	assert.Equal(t, 2, suggestions[0].Count)
+	suggestions, err = service.Suggest(ctx, "go", KindTag, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"golang", "go"}, texts(suggestions))
+
+	suggestions, err = service.Suggest(ctx, "a", KindAuthor, 5)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, Suggestion{Text: "alice", Kind: KindAuthor, Count: 2}, suggestions[0])
+
+	for _, prefix := range []string{"секрет", "скрыт", "закрыт"} {
+		suggestions, err = service.Suggest(ctx, prefix, KindTitle, 5)
+		require.NoError(t, err)
+		assert.Empty(t, suggestions, "Скрытые и непубличные посты не подсказываются")
+	}
+	suggestions, err = service.Suggest(ctx, "c", KindAuthor, 5)
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+	suggestions, err = service.Suggest(ctx, " ", KindTitle, 5)
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+
+	_, err = service.Suggest(ctx, "за", "USER", 5)
+	assert.ErrorIs(t, err, ErrUnknownKind)
+
+	// Новый пост попадает в подсказки после перестроения устаревшего индекса
+	add("p6", "Запуск завтра", "erin", 6, nil, false, "")
+	suggestions, err = service.Suggest(ctx, "запуск з", KindTitle, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Новый запуск Зонда"}, texts(suggestions))
+	clk.Advance(time.Minute)
+	_, err = service.Suggest(ctx, "запуск з", KindTitle, 5)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		suggestions, err := service.Suggest(ctx, "запуск з", KindTitle, 5)
+		return err == nil && len(suggestions) == 2 && suggestions[0].Text == "Запуск завтра"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func BenchmarkSuggest(b *testing.B) {
+	ctx := context.Background()
+	store := memory.New()
+	base := time.Now().Add(-time.Hour)
+	words := []string{"запуск", "ракета", "зонд", "новости", "погода", "космос", "станция", "орбита"}
+	for i := 0; i < 5000; i++ {
+		title := fmt.Sprintf("%s %s %d", words[i%len(words)], words[(i/len(words))%len(words)], i)
+		post := &models.Post{ID: fmt.Sprint(i), Title: title, AuthorID: fmt.Sprintf("user%d", i%500), CreatedAt: base.Add(time.Duration(i) * time.Millisecond), Tags: []string{words[i%3]}}
+		require.NoError(b, store.CreatePost(ctx, post))
+	}
+	service := New(store, Options{})
+	_, err := service.Suggest(ctx, "за", KindTitle, 10)
+	require.NoError(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := service.Suggest(ctx, "запуск ра", KindTitle, 10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
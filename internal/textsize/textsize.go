@@ -0,0 +1,126 @@
+// Package textsize считает длину заголовков и текстов постов и комментариев и проверяет её по лимитам
+// сообществ. Длина считается в символах (рунах) или байтах UTF-8: при подсчёте в байтах кириллический
+// текст получает вдвое меньший лимит, чем латинский. Для анонсов текст обрезается по границам
+// графем, чтобы не разрывать составные эмодзи и буквы с диакритикой
+package textsize
+
+import (
+	"fmt"
+	"log"
+	"unicode/utf8"
+)
+
+// Unit - единица длины текста
+type Unit string
+
+const (
+	// Runes - символы Unicode (кодовые точки)
+	Runes Unit = "runes"
+	// Bytes - байты в кодировке UTF-8
+	Bytes Unit = "bytes"
+)
+
+// Count возвращает длину s в единицах u; неизвестная единица считается как Runes
+func (u Unit) Count(s string) int {
+	if u == Bytes {
+		return len(s)
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// noun возвращает единицу для текста ошибки
+func (u Unit) noun() string {
+	if u == Bytes {
+		return "bytes"
+	}
+	return "characters"
+}
+
+// Limits - наибольшая длина заголовка поста, текста поста и текста комментария; 0 означает отсутствие ограничения
+type Limits struct {
+	Title   int
+	Post    int
+	Comment int
+}
+
+// DefaultLimits - лимиты, действовавшие до появления настройки
+var DefaultLimits = Limits{Title: 200, Post: 2000, Comment: 2000}
+
+// Options задаёт единицу длины, лимиты по умолчанию и отдельные лимиты сообществ
+type Options struct {
+	// Unit - единица длины; по умолчанию Runes
+	Unit    Unit
+	Default Limits
+	Tenants map[string]Limits
+}
+
+func (o Options) withDefaults() Options {
+	if o.Unit == "" {
+		o.Unit = Runes
+	}
+	return o
+}
+
+// TooLongError возвращается, если текст длиннее лимита
+type TooLongError struct {
+	// Field - проверяемое поле в тексте ошибки: title, content или comment content
+	Field string
+	Limit int
+	Unit  Unit
+}
+
+func (e *TooLongError) Error() string {
+	return fmt.Sprintf("%s exceeds %d %s", e.Field, e.Limit, e.Unit.noun())
+}
+
+// Policy проверяет длину текстов по лимитам сообщества
+type Policy struct {
+	opts Options
+}
+
+// New создаёт политику лимитов длины
+func New(opts Options) *Policy {
+	opts = opts.withDefaults()
+	log.Printf("Создание политики длины текстов: единица=%s, заголовок=%d, пост=%d, комментарий=%d, отдельных лимитов=%d",
+		opts.Unit, opts.Default.Title, opts.Default.Post, opts.Default.Comment, len(opts.Tenants))
+	return &Policy{opts: opts}
+}
+
+// Unit возвращает единицу длины политики
+func (p *Policy) Unit() Unit {
+	return p.opts.Unit
+}
+
+// Limits возвращает лимиты сообщества
+func (p *Policy) Limits(tenantID string) Limits {
+	if limits, ok := p.opts.Tenants[tenantID]; ok {
+		return limits
+	}
+	return p.opts.Default
+}
+
+// CheckTitle проверяет длину заголовка поста
+func (p *Policy) CheckTitle(tenantID, title string) error {
+	return p.check("title", title, p.Limits(tenantID).Title)
+}
+
+// CheckPost проверяет длину текста поста
+func (p *Policy) CheckPost(tenantID, content string) error {
+	return p.check("content", content, p.Limits(tenantID).Post)
+}
+
+// CheckComment проверяет длину текста комментария
+func (p *Policy) CheckComment(tenantID, content string) error {
+	return p.check("comment content", content, p.Limits(tenantID).Comment)
+}
+
+func (p *Policy) check(field, s string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	// Руна занимает не больше 4 байт, поэтому короткий текст не нужно пересчитывать
+	if len(s) <= limit || p.opts.Unit.Count(s) <= limit {
+		return nil
+	}
+	return &TooLongError{Field: field, Limit: limit, Unit: p.opts.Unit}
+}
@@ -0,0 +1,66 @@
+package textsize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCount(t *testing.T) {
+	assert.Equal(t, 6, Runes.Count("привет"))
+	assert.Equal(t, 12, Bytes.Count("привет"))
+	assert.Equal(t, 6, Unit("").Count("привет"), "Неизвестная единица считается в символах")
+}
+
+func TestPolicy(t *testing.T) {
+	t.Run("Cyrillic gets the same allowance in runes", func(t *testing.T) {
+		p := New(Options{Default: Limits{Title: 10}})
+		assert.NoError(t, p.CheckTitle("default", strings.Repeat("я", 10)))
+		err := p.CheckTitle("default", strings.Repeat("я", 11))
+		assert.EqualError(t, err, "title exceeds 10 characters")
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		p := New(Options{Unit: Bytes, Default: Limits{Comment: 10}})
+		assert.NoError(t, p.CheckComment("default", strings.Repeat("a", 10)))
+		err := p.CheckComment("default", strings.Repeat("я", 6))
+		assert.EqualError(t, err, "comment content exceeds 10 bytes")
+	})
+
+	t.Run("Tenant overrides", func(t *testing.T) {
+		p := New(Options{Default: Limits{Post: 5}, Tenants: map[string]Limits{"forum": {Post: 20}}})
+		assert.Error(t, p.CheckPost("default", strings.Repeat("a", 10)))
+		assert.NoError(t, p.CheckPost("forum", strings.Repeat("a", 10)))
+		assert.NoError(t, p.CheckTitle("forum", strings.Repeat("a", 1000)), "0 означает отсутствие ограничения")
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{"Short text unchanged", "привет", 6, "привет"},
+		{"Cyrillic", "привет мир", 8, "привет…"},
+		{"Trailing space trimmed", "один два", 6, "один…"},
+		{"Combining accent kept", "е́е́е́е́", 3, "е́е́…"},
+		{"ZWJ family is one grapheme", "👨‍👩‍👧 семья", 2, "👨‍👩‍👧…"},
+		{"Skin tone modifier", "👍🏽👍🏽👍🏽", 2, "👍🏽…"},
+		{"Flags are pairs", "🇷🇺🇺🇸🇩🇪", 2, "🇷🇺…"},
+		{"CRLF", "a\r\nb", 3, "a\r\nb"},
+		{"Zero", "текст", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Truncate(tt.in, tt.n))
+		})
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	assert.Equal(t, 0, Graphemes(""))
+	assert.Equal(t, 3, Graphemes("ё̈👨‍👩‍👧🇷🇺"))
+}
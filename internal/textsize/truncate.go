@@ -0,0 +1,95 @@
+package textsize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ellipsis добавляется к обрезанному тексту
+const Ellipsis = "…"
+
+const zwj = '‍'
+
+// Graphemes возвращает число графем в s - того, что читатель видит как один символ
+func Graphemes(s string) int {
+	n := 0
+	for s != "" {
+		s = s[nextGrapheme(s):]
+		n++
+	}
+	return n
+}
+
+// Truncate обрезает s до n графем вместе с многоточием. Текст не длиннее n графем возвращается
+// без изменений, а у обрезанного отбрасываются пробелы перед многоточием
+func Truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	end, count := 0, 0
+	for end < len(s) && count < n-1 {
+		end += nextGrapheme(s[end:])
+		count++
+	}
+	// Остаток помещается целиком, если это не больше одной графемы
+	if end == len(s) || end+nextGrapheme(s[end:]) == len(s) {
+		return s
+	}
+	return strings.TrimRightFunc(s[:end], unicode.IsSpace) + Ellipsis
+}
+
+// nextGrapheme возвращает длину в байтах первой графемы непустой строки s. Это упрощение правил
+// UAX #29, достаточное для анонсов: к символу присоединяются комбинируемые знаки, селекторы вариантов,
+// модификаторы цвета кожи и теги, символы через ZWJ; флаги из пары региональных индикаторов и CRLF
+// не разрываются. Слоги хангыля из отдельных чамо и индийские кластеры согласных считаются по символам
+func nextGrapheme(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == '\r' && len(s) > size && s[size] == '\n' {
+		return size + 1
+	}
+	if r == '\r' || r == '\n' {
+		return size
+	}
+	if isRegionalIndicator(r) {
+		if next, n := utf8.DecodeRuneInString(s[size:]); isRegionalIndicator(next) {
+			size += n
+		}
+	}
+	for size < len(s) {
+		next, n := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case isExtend(next):
+			size += n
+		case r == zwj && next != '\r' && next != '\n':
+			// Символ после ZWJ - часть составного эмодзи
+			size += n
+		default:
+			return size
+		}
+		r = next
+	}
+	return size
+}
+
+// isExtend сообщает, присоединяется ли символ к предыдущему
+func isExtend(r rune) bool {
+	switch {
+	case r == zwj:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF:
+		// Селекторы вариантов, например текстовое или эмодзи-представление
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		// Модификаторы цвета кожи
+		return true
+	case r >= 0xE0020 && r <= 0xE007F:
+		// Теги флагов регионов
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,110 @@
+package toxicity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultPerspectiveEndpoint - адрес метода comments:analyze Perspective API
+const DefaultPerspectiveEndpoint = "https://commentanalyzer.googleapis.com/v1alpha1/comments:analyze"
+
+// maxPerspectiveResponse ограничивает объём читаемого ответа сервиса
+const maxPerspectiveResponse = 64 << 10
+
+// PerspectiveOptions задаёт ключ API и адрес сервиса оценки
+type PerspectiveOptions struct {
+	APIKey   string
+	Endpoint string
+	Timeout  time.Duration
+}
+
+func (o PerspectiveOptions) withDefaults() PerspectiveOptions {
+	if o.Endpoint == "" {
+		o.Endpoint = DefaultPerspectiveEndpoint
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	return o
+}
+
+// Perspective оценивает текст атрибутом TOXICITY сервиса в формате Perspective API: запрос
+// POST {"comment": {"text"}, "languages": [...], "requestedAttributes": {"TOXICITY": {}}},
+// оценка берётся из attributeScores.TOXICITY.summaryScore.value
+type Perspective struct {
+	opts   PerspectiveOptions
+	client *http.Client
+}
+
+var _ Scorer = &Perspective{}
+
+// NewPerspective создаёт клиент сервиса оценки
+func NewPerspective(opts PerspectiveOptions) *Perspective {
+	opts = opts.withDefaults()
+	return &Perspective{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// perspectiveRequest - тело запроса comments:analyze
+type perspectiveRequest struct {
+	Comment struct {
+		Text string `json:"text"`
+	} `json:"comment"`
+	// Languages не передаётся для неизвестного языка: сервис определит его сам
+	Languages           []string            `json:"languages,omitempty"`
+	RequestedAttributes map[string]struct{} `json:"requestedAttributes"`
+}
+
+// Score реализует Scorer
+func (p *Perspective) Score(ctx context.Context, text, language string) (float64, error) {
+	request := perspectiveRequest{RequestedAttributes: map[string]struct{}{"TOXICITY": {}}}
+	request.Comment.Text = text
+	if language != "" {
+		request.Languages = []string{language}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode toxicity request: %v", err)
+	}
+	endpoint := p.opts.Endpoint
+	if p.opts.APIKey != "" {
+		endpoint += "?key=" + url.QueryEscape(p.opts.APIKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build toxicity request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("toxicity request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("toxicity service returned %d: %s", resp.StatusCode, data)
+	}
+	var result struct {
+		AttributeScores map[string]struct {
+			SummaryScore *struct {
+				Value float64 `json:"value"`
+			} `json:"summaryScore"`
+		} `json:"attributeScores"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxPerspectiveResponse)).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode toxicity response: %v", err)
+	}
+	summary := result.AttributeScores["TOXICITY"].SummaryScore
+	if summary == nil {
+		return 0, fmt.Errorf("toxicity response has no TOXICITY summary score")
+	}
+	if summary.Value < 0 || summary.Value > 1 {
+		return 0, fmt.Errorf("toxicity score %v is out of range", summary.Value)
+	}
+	return summary.Value, nil
+}
@@ -0,0 +1,167 @@
+// Package toxicity оценивает токсичность комментариев внешним сервисом. Оценка выполняется в фоне
+// после создания комментария или по запросу модератора; результат сохраняется вместе с комментарием,
+// а комментарий с оценкой не ниже порога скрывается и ставится в очередь проверки модераторами
+package toxicity
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/ids"
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage"
+)
+
+// Scorer оценивает токсичность текста
+type Scorer interface {
+	// Score возвращает оценку от 0 до 1; language - код ISO 639-1 или пустая строка, если язык неизвестен
+	Score(ctx context.Context, text, language string) (float64, error)
+}
+
+// Options задаёт параметры сервиса; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	Timeout   time.Duration
+	Workers   int
+	QueueSize int
+	// HoldThreshold - оценка, начиная с которой комментарий задерживается до решения модератора
+	HoldThreshold float64
+	// Clock - источник времени оценок и записей очереди проверки, по умолчанию системные часы
+	Clock clock.Clock
+	// IDs создаёт идентификаторы записей очереди проверки
+	IDs ids.Generator
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.Workers <= 0 {
+		o.Workers = 2
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	if o.HoldThreshold <= 0 {
+		o.HoldThreshold = 0.9
+	}
+	if o.Clock == nil {
+		o.Clock = clock.Real()
+	}
+	if o.IDs == nil {
+		o.IDs = ids.Default()
+	}
+	return o
+}
+
+// Service оценивает комментарии фоновыми обработчиками и сохраняет результат
+type Service struct {
+	scorer Scorer
+	store  storage.Storage
+	opts   Options
+	jobs   chan models.Comment
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// New создаёт сервис и запускает фоновые обработчики
+func New(scorer Scorer, store storage.Storage, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Toxicity Service: workers=%d, timeout=%v, порог задержки %.2f", opts.Workers, opts.Timeout, opts.HoldThreshold)
+	s := &Service{scorer: scorer, store: store, opts: opts, jobs: make(chan models.Comment, opts.QueueSize)}
+	for i := 0; i < opts.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// Enqueue ставит сохранённый комментарий на оценку и возвращает false, если очередь переполнена.
+// Такой комментарий остаётся без оценки до повторной оценки модератором
+func (s *Service) Enqueue(comment *models.Comment) bool {
+	select {
+	case s.jobs <- *comment:
+		log.Printf("Комментарий %s поставлен в очередь оценки токсичности", comment.ID)
+		return true
+	default:
+		log.Printf("Очередь оценки токсичности переполнена, комментарий %s не оценён", comment.ID)
+		metrics.ToxicityChecks.WithLabelValues("dropped").Inc()
+		return false
+	}
+}
+
+// Rescore ставит на повторную оценку все комментарии поста postID и возвращает число поставленных
+// в очередь. Обход прекращается, когда очередь переполнена
+func (s *Service) Rescore(ctx context.Context, postID string) (int, error) {
+	queued := 0
+	err := s.store.IterateComments(ctx, postID, func(comment *models.Comment) error {
+		if !s.Enqueue(comment) {
+			return errQueueFull
+		}
+		queued++
+		return nil
+	})
+	if errors.Is(err, errQueueFull) {
+		err = nil
+	}
+	log.Printf("Повторная оценка токсичности комментариев поста %s: в очереди %d", postID, queued)
+	return queued, err
+}
+
+// errQueueFull прекращает обход комментариев в Rescore
+var errQueueFull = errors.New("toxicity queue is full")
+
+// Close останавливает обработчики после завершения текущих оценок
+func (s *Service) Close() {
+	s.once.Do(func() {
+		close(s.jobs)
+		s.wg.Wait()
+		log.Println("Toxicity Service остановлен")
+	})
+}
+
+func (s *Service) worker() {
+	defer s.wg.Done()
+	for comment := range s.jobs {
+		s.process(comment)
+	}
+}
+
+// process оценивает комментарий и сохраняет оценку. Комментарий с оценкой не ниже порога скрывается
+// и ставится в очередь проверки; уже скрытый комментарий хранилище повторно не задерживает
+func (s *Service) process(comment models.Comment) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	score, err := s.scorer.Score(ctx, comment.Content, comment.Language)
+	if err != nil {
+		log.Printf("Ошибка оценки токсичности комментария %s: %v", comment.ID, err)
+		metrics.ToxicityChecks.WithLabelValues("error").Inc()
+		return
+	}
+	metrics.ToxicityScores.Observe(score)
+	now := s.opts.Clock.Now()
+	var hold *models.HeldItem
+	result := "ok"
+	if score >= s.opts.HoldThreshold {
+		log.Printf("Комментарий %s задержан по оценке токсичности %.3f", comment.ID, score)
+		result = "held"
+		hold = &models.HeldItem{
+			ID:         s.opts.IDs.New(),
+			TargetType: models.TargetComment,
+			TargetID:   comment.ID,
+			RuleID:     models.ToxicityRuleID,
+			CreatedAt:  now,
+		}
+	}
+	err = s.store.SetToxicityScore(context.Background(), models.ToxicityScore{CommentID: comment.ID, Score: score, ScoredAt: now}, hold)
+	if err != nil {
+		log.Printf("Ошибка сохранения оценки токсичности комментария %s: %v", comment.ID, err)
+		metrics.ToxicityChecks.WithLabelValues("error").Inc()
+		return
+	}
+	metrics.ToxicityChecks.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,134 @@
+package toxicity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/clock"
+	"github.com/ButyrinIA/system/internal/models"
+	"github.com/ButyrinIA/system/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scorerFunc позволяет задать Scorer функцией
+type scorerFunc func(text, language string) (float64, error)
+
+func (f scorerFunc) Score(ctx context.Context, text, language string) (float64, error) {
+	return f(text, language)
+}
+
+func TestPerspective(t *testing.T) {
+	var received perspectiveRequest
+	var key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.URL.Query().Get("key")
+		received = perspectiveRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		switch received.Comment.Text {
+		case "broken":
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+		case "empty":
+			w.Write([]byte(`{"attributeScores":{}}`))
+		default:
+			w.Write([]byte(`{"attributeScores":{"TOXICITY":{"summaryScore":{"value":0.87,"type":"PROBABILITY"}}}}`))
+		}
+	}))
+	defer server.Close()
+
+	scorer := NewPerspective(PerspectiveOptions{APIKey: "secret", Endpoint: server.URL, Timeout: time.Second})
+	score, err := scorer.Score(context.Background(), "Ты ничего не понимаешь", "ru")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.87, score, 1e-9)
+	assert.Equal(t, "secret", key)
+	assert.Equal(t, []string{"ru"}, received.Languages)
+	assert.Contains(t, received.RequestedAttributes, "TOXICITY")
+
+	_, err = scorer.Score(context.Background(), "text", "")
+	require.NoError(t, err)
+	assert.Empty(t, received.Languages, "Неизвестный язык определяет сервис")
+	_, err = scorer.Score(context.Background(), "broken", "")
+	assert.ErrorContains(t, err, "429")
+	_, err = scorer.Score(context.Background(), "empty", "")
+	assert.Error(t, err)
+}
+
+func TestService(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	post := &models.Post{ID: uuid.New().String(), Title: "Пост", AuthorID: "author", AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(ctx, post))
+	newComment := func(content string) *models.Comment {
+		comment := &models.Comment{ID: uuid.New().String(), PostID: post.ID, AuthorID: "author", Content: content, CreatedAt: time.Now()}
+		require.NoError(t, store.CreateComment(ctx, comment))
+		return comment
+	}
+	calm := newComment("Спасибо за статью")
+	toxic := newComment("Ужасный комментарий")
+	failing := newComment("Сервис не ответил")
+
+	scores := map[string]float64{calm.Content: 0.05, toxic.Content: 0.95}
+	scorer := scorerFunc(func(text, language string) (float64, error) {
+		if score, ok := scores[text]; ok {
+			return score, nil
+		}
+		return 0, errors.New("unavailable")
+	})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := New(scorer, store, Options{HoldThreshold: 0.8, Clock: clock.NewFake(now)})
+	for _, comment := range []*models.Comment{calm, toxic, failing} {
+		assert.True(t, service.Enqueue(comment))
+	}
+	service.Close()
+
+	result, err := store.GetToxicityScores(ctx, []string{calm.ID, toxic.ID, failing.ID})
+	require.NoError(t, err)
+	assert.Equal(t, models.ToxicityScore{CommentID: calm.ID, Score: 0.05, ScoredAt: now}, result[calm.ID])
+	assert.InDelta(t, 0.95, result[toxic.ID].Score, 1e-9)
+	assert.NotContains(t, result, failing.ID, "Ошибка сервиса не сохраняет оценку")
+
+	held, err := store.ListHeldContent(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	assert.Equal(t, toxic.ID, held[0].TargetID)
+	assert.Equal(t, models.ToxicityRuleID, held[0].RuleID)
+	got, err := store.GetComment(ctx, toxic.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Hidden)
+	got, err = store.GetComment(ctx, calm.ID)
+	require.NoError(t, err)
+	assert.False(t, got.Hidden)
+}
+
+func TestRescore(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	post := &models.Post{ID: uuid.New().String(), Title: "Пост", AuthorID: "author", AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, store.CreatePost(ctx, post))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.CreateComment(ctx, &models.Comment{ID: uuid.New().String(), PostID: post.ID, AuthorID: "author", Content: "Текст", CreatedAt: time.Now()}))
+	}
+	release := make(chan struct{})
+	scorer := scorerFunc(func(text, language string) (float64, error) {
+		<-release
+		return 0.1, nil
+	})
+	// Один обработчик занят первым комментарием, в очереди помещается ещё один
+	service := New(scorer, store, Options{Workers: 1, QueueSize: 1})
+	queued, err := service.Rescore(ctx, post.ID)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, queued, 1)
+	assert.Less(t, queued, 3, "Обход прекращается при переполнении очереди")
+	close(release)
+	service.Close()
+
+	queued, err = New(scorer, store, Options{QueueSize: 10}).Rescore(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, queued)
+}
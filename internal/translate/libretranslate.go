@@ -0,0 +1,97 @@
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultLibreTranslateEndpoint - адрес публичного сервера LibreTranslate; он требует ключ API
+const DefaultLibreTranslateEndpoint = "https://libretranslate.com"
+
+// maxLibreTranslateResponse ограничивает объём читаемого ответа LibreTranslate
+const maxLibreTranslateResponse = 1 << 20
+
+// LibreTranslateOptions задаёт сервер LibreTranslate и ключ API; собственный сервер может работать без ключа
+type LibreTranslateOptions struct {
+	Endpoint string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+func (o LibreTranslateOptions) withDefaults() LibreTranslateOptions {
+	if o.Endpoint == "" {
+		o.Endpoint = DefaultLibreTranslateEndpoint
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	return o
+}
+
+// LibreTranslate переводит тексты методом /translate API LibreTranslate
+type LibreTranslate struct {
+	opts   LibreTranslateOptions
+	client *http.Client
+}
+
+// NewLibreTranslate создаёт клиент LibreTranslate
+func NewLibreTranslate(opts LibreTranslateOptions) *LibreTranslate {
+	opts = opts.withDefaults()
+	return &LibreTranslate{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// libreTranslateRequest - тело запроса /translate
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// libreTranslateResponse - ответ /translate; при ошибке заполнено только Error
+type libreTranslateResponse struct {
+	TranslatedText string `json:"translatedText"`
+	Error          string `json:"error"`
+}
+
+// Translate реализует Translator; неизвестный язык текста LibreTranslate определяет сам (source=auto)
+func (l *LibreTranslate) Translate(ctx context.Context, text, source, target string) (string, error) {
+	if source == "" {
+		source = "auto"
+	}
+	body, err := json.Marshal(libreTranslateRequest{Q: text, Source: source, Target: target, Format: "text", APIKey: l.opts.APIKey})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode libretranslate request: %v", err)
+	}
+	endpoint := strings.TrimSuffix(l.opts.Endpoint, "/") + "/translate"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build libretranslate request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "system-translate/1.0")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call libretranslate: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLibreTranslateResponse))
+	if err != nil {
+		return "", fmt.Errorf("failed to read libretranslate response: %v", err)
+	}
+	var result libreTranslateResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to decode libretranslate response with status %d: %v", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d from libretranslate: %s", resp.StatusCode, result.Error)
+	}
+	return result.TranslatedText, nil
+}
@@ -0,0 +1,83 @@
+// Package translate переводит содержимое постов и комментариев внешним сервисом машинного перевода.
+// Сервис подключается через интерфейс Translator; переводы кешируются по тексту и паре языков,
+// поэтому повторные запросы одного поста на одном языке не обращаются к сервису
+package translate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/ButyrinIA/system/internal/metrics"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// cacheName - метка кеша переводов в метриках
+const cacheName = "translation"
+
+// Translator переводит текст с языка source на язык target. Пустой source означает,
+// что язык текста неизвестен и сервис должен определить его сам
+type Translator interface {
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// Options задаёт параметры кеша; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// CacheSize - число хранимых переводов
+	CacheSize int
+	// CacheTTL - как долго используется перевод; после правки текста у него другой ключ,
+	// поэтому срок ограничивает только память, занятую устаревшими переводами
+	CacheTTL time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.CacheSize <= 0 {
+		o.CacheSize = 10000
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = 24 * time.Hour
+	}
+	return o
+}
+
+// Service переводит тексты через Translator с кешированием результатов
+type Service struct {
+	translator Translator
+	cache      *expirable.LRU[string, string]
+}
+
+// New создаёт сервис перевода поверх translator
+func New(translator Translator, opts Options) *Service {
+	opts = opts.withDefaults()
+	log.Printf("Создание Translate Service: размер кеша=%d, срок хранения=%v", opts.CacheSize, opts.CacheTTL)
+	return &Service{translator: translator, cache: expirable.NewLRU[string, string](opts.CacheSize, nil, opts.CacheTTL)}
+}
+
+// Translate возвращает перевод text на язык target. Текст на языке target и пустой текст возвращаются без перевода
+func (s *Service) Translate(ctx context.Context, text, source, target string) (string, error) {
+	if text == "" || source == target {
+		return text, nil
+	}
+	key := cacheKey(text, source, target)
+	if translated, ok := s.cache.Get(key); ok {
+		metrics.CacheRequests.WithLabelValues(cacheName, "hit").Inc()
+		return translated, nil
+	}
+	metrics.CacheRequests.WithLabelValues(cacheName, "miss").Inc()
+	translated, err := s.translator.Translate(ctx, text, source, target)
+	if err != nil {
+		metrics.Translations.WithLabelValues("error").Inc()
+		return "", err
+	}
+	metrics.Translations.WithLabelValues("translated").Inc()
+	s.cache.Add(key, translated)
+	return translated, nil
+}
+
+// cacheKey возвращает ключ перевода: хеш текста вместо самого текста ограничивает память под ключи
+func cacheKey(text, source, target string) string {
+	sum := sha256.Sum256([]byte(text))
+	return source + ":" + target + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,86 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubTranslator добавляет к тексту язык перевода и считает вызовы
+type stubTranslator struct {
+	calls int
+	err   error
+}
+
+func (s *stubTranslator) Translate(ctx context.Context, text, source, target string) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	return "[" + target + "] " + text, nil
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubTranslator{}
+	service := New(stub, Options{})
+
+	translated, err := service.Translate(ctx, "Привет", "ru", "en")
+	require.NoError(t, err)
+	assert.Equal(t, "[en] Привет", translated)
+	translated, err = service.Translate(ctx, "Привет", "ru", "en")
+	require.NoError(t, err)
+	assert.Equal(t, "[en] Привет", translated)
+	assert.Equal(t, 1, stub.calls, "Повторный перевод берётся из кеша")
+
+	_, err = service.Translate(ctx, "Привет", "ru", "de")
+	require.NoError(t, err)
+	_, err = service.Translate(ctx, "Пока", "ru", "en")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stub.calls, "Ключ кеша учитывает текст и язык")
+
+	translated, err = service.Translate(ctx, "Привет", "ru", "ru")
+	require.NoError(t, err)
+	assert.Equal(t, "Привет", translated, "Текст на нужном языке не переводится")
+	assert.Equal(t, 3, stub.calls)
+
+	stub.err = errors.New("unavailable")
+	_, err = service.Translate(ctx, "Новый текст", "ru", "en")
+	assert.Error(t, err)
+	stub.err = nil
+	_, err = service.Translate(ctx, "Новый текст", "ru", "en")
+	require.NoError(t, err)
+	assert.Equal(t, 5, stub.calls, "Ошибки не кешируются")
+}
+
+func TestLibreTranslate(t *testing.T) {
+	var got libreTranslateRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/translate", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got.Target == "xx" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "xx is not supported"}`))
+			return
+		}
+		w.Write([]byte(`{"translatedText": "Hello"}`))
+	}))
+	defer srv.Close()
+	client := NewLibreTranslate(LibreTranslateOptions{Endpoint: srv.URL + "/", APIKey: "key"})
+	ctx := context.Background()
+
+	translated, err := client.Translate(ctx, "Привет", "", "en")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", translated)
+	assert.Equal(t, libreTranslateRequest{Q: "Привет", Source: "auto", Target: "en", Format: "text", APIKey: "key"}, got)
+
+	_, err = client.Translate(ctx, "Привет", "ru", "xx")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "xx is not supported")
+}